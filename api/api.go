@@ -156,11 +156,46 @@ func (ctrl *Controller) ArchiveTaskHandler(c *gin.Context) {
 
 func (ctrl *Controller) GetModelsHandler(c *gin.Context) {
 	data, err := common.LoadModelsDev()
-	if err != nil {
+	localModels := getOllamaProviderModels(c.Request.Context())
+	if err != nil && len(localModels) == 0 {
 		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
 		return
 	}
-	c.JSON(http.StatusOK, data)
+
+	// local models aren't on models.dev, so they're listed under the name of
+	// each configured ollama provider
+	models := make(map[string]common.ProviderInfo, len(data)+len(localModels))
+	for provider, info := range data {
+		models[provider] = info
+	}
+	for provider, providerModels := range localModels {
+		models[provider] = common.ProviderInfo{Models: providerModels}
+	}
+	c.JSON(http.StatusOK, models)
+}
+
+// getOllamaProviderModels lists the models installed for each ollama provider
+// in the local config, skipping ollama servers that can't be reached
+func getOllamaProviderModels(ctx context.Context) map[string]map[string]common.ModelInfo {
+	config, err := common.LoadSidekickConfig(common.GetSidekickConfigPath())
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to load local config for ollama models")
+		return nil
+	}
+
+	result := make(map[string]map[string]common.ModelInfo)
+	for _, p := range config.Providers {
+		if p.Type != string(common.OllamaChatProvider) {
+			continue
+		}
+		models, err := common.ListOllamaModels(ctx, p.BaseURL)
+		if err != nil {
+			log.Warn().Err(err).Str("provider", p.Name).Msg("failed to list ollama models")
+			continue
+		}
+		result[p.Name] = models
+	}
+	return result
 }
 
 // ArchiveFinishedTasksHandler handles the request to archive all finished tasks
@@ -212,10 +247,14 @@ func DefineRoutes(ctrl Controller, allowedOrigins *AllowedOrigins) *gin.Engine {
 	r.GET("/api/v1/providers", ctrl.GetProvidersHandler)
 	r.GET("/api/v1/models", ctrl.GetModelsHandler)
 	r.GET("/api/v1/off_hours", ctrl.GetOffHoursHandler)
+	r.GET("/api/v1/flow_types", ctrl.GetFlowTypesHandler)
 	r.POST("/api/v1/open-in-ide", ctrl.OpenInIdeHandler)
+	r.GET("/api/v1/callbacks/:token", ctrl.CallbackPageHandler)
+	r.POST("/api/v1/callbacks/:token", ctrl.CallbackHandler)
 
 	workspaceApiRoutes := DefineWorkspaceApiRoutes(r, &ctrl)
 	workspaceApiRoutes.GET("/archived_tasks", ctrl.GetArchivedTasksHandler)
+	workspaceApiRoutes.GET("/usage", ctrl.GetWorkspaceUsageHandler)
 
 	taskRoutes := workspaceApiRoutes.Group("/tasks")
 	taskRoutes.POST("/", ctrl.CreateTaskHandler)
@@ -225,6 +264,9 @@ func DefineRoutes(ctrl Controller, allowedOrigins *AllowedOrigins) *gin.Engine {
 	taskRoutes.DELETE("/:id", ctrl.DeleteTaskHandler)
 	taskRoutes.POST("/:id/archive", ctrl.ArchiveTaskHandler)
 	taskRoutes.POST("/:id/cancel", ctrl.CancelTaskHandler)
+	taskRoutes.GET("/:id/usage", ctrl.GetTaskUsageHandler)
+	taskRoutes.POST("/:id/links", ctrl.AddTaskLinkHandler)
+	taskRoutes.DELETE("/:id/links/:linkType/:targetTaskId", ctrl.RemoveTaskLinkHandler)
 	taskRoutes.POST("/archive_finished", ctrl.ArchiveFinishedTasksHandler)
 
 	flowRoutes := workspaceApiRoutes.Group("/flows")
@@ -236,6 +278,7 @@ func DefineRoutes(ctrl Controller, allowedOrigins *AllowedOrigins) *gin.Engine {
 	flowRoutes.GET("/:id/history", ctrl.GetFlowHistoryHandler)
 	flowRoutes.POST("/:id/reset", ctrl.ResetFlowHandler)
 	flowRoutes.GET("/:id/subflows", ctrl.GetFlowSubflowsHandler)
+	flowRoutes.GET("/:id/usage", ctrl.GetFlowUsageHandler)
 	flowRoutes.POST("/:id/query", ctrl.QueryFlowHandler)
 	flowRoutes.POST("/:id/chat_history/hydrate", ctrl.HydrateChatHistoryHandler)
 
@@ -400,8 +443,10 @@ func (ctrl *Controller) CancelTaskHandler(c *gin.Context) {
 	}
 
 	// Update the task status to 'canceled' and agent type to 'none'
+	wasQueued := task.QueuePosition > 0
 	task.Status = domain.TaskStatusCanceled
 	task.AgentType = domain.AgentTypeNone
+	task.QueuePosition = 0
 	task.Updated = time.Now()
 	err = ctrl.service.PersistTask(c.Request.Context(), task)
 	if err != nil {
@@ -409,6 +454,32 @@ func (ctrl *Controller) CancelTaskHandler(c *gin.Context) {
 		return
 	}
 
+	if wasQueued {
+		if err := dev.UpdateQueuePositions(c.Request.Context(), ctrl.service, workspaceId); err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to update queue positions: %w", err))
+			return
+		}
+	}
+
+	// tasks waiting on this one can no longer start, so cancel them too
+	if _, err := dev.ResolveDependentTasks(c.Request.Context(), ctrl.service, workspaceId, taskId); err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to cancel dependent tasks: %w", err))
+		return
+	}
+
+	// the parent task is finished if this was its last unfinished child task
+	finishedParentIds, err := dev.UpdateParentTaskStatuses(c.Request.Context(), ctrl.service, workspaceId, taskId)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to update parent task status: %w", err))
+		return
+	}
+	for _, parentId := range finishedParentIds {
+		if _, err := dev.ResolveDependentTasks(c.Request.Context(), ctrl.service, workspaceId, parentId); err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to cancel dependent tasks: %w", err))
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Task canceled successfully"})
 }
 
@@ -632,6 +703,11 @@ type TaskRequest struct {
 	AgentType   string                 `json:"agentType"`
 	Status      string                 `json:"status"`
 	FlowOptions map[string]interface{} `json:"flowOptions"`
+	// Links to other tasks, only used when creating a task
+	Links []domain.TaskLink `json:"links,omitempty"`
+	// Priority orders the task in its workspace's queue, left unchanged on
+	// update if not provided
+	Priority *int `json:"priority,omitempty"`
 }
 
 func (ctrl *Controller) CreateTaskHandler(c *gin.Context) {
@@ -672,6 +748,10 @@ func (ctrl *Controller) CreateTaskHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := domain.ValidateFlowOptions(flowType, taskReq.FlowOptions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	task := domain.Task{
 		WorkspaceId: workspaceId,
@@ -686,13 +766,61 @@ func (ctrl *Controller) CreateTaskHandler(c *gin.Context) {
 		FlowType:    flowType,
 		FlowOptions: taskReq.FlowOptions,
 	}
+	if taskReq.Priority != nil {
+		task.Priority = *taskReq.Priority
+	}
 
+	// links are all validated and added before anything is persisted, so a
+	// rejected link doesn't leave a half-created task behind
+	targets := make(map[string]*domain.Task, len(taskReq.Links))
+	targetIds := make([]string, 0, len(taskReq.Links))
+	for _, link := range taskReq.Links {
+		if _, err := domain.InverseLinkType(link.LinkType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		target, ok := targets[link.TargetTaskId]
+		if !ok {
+			linked, err := ctrl.service.GetTask(c, workspaceId, link.TargetTaskId)
+			if err != nil {
+				if errors.Is(err, srv.ErrNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("linked task %s not found", link.TargetTaskId)})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+			target = &linked
+			targets[link.TargetTaskId] = target
+			targetIds = append(targetIds, link.TargetTaskId)
+		}
+		if err := ctrl.linkTasks(c, &task, link.LinkType, target, targets); err != nil {
+			return
+		}
+	}
+
+	held := false
+	if agentType == domain.AgentTypeLLM {
+		held, err = ctrl.holdIfBlocked(c, &task)
+		if err != nil {
+			return
+		}
+	}
+
+	// linked tasks are persisted first, since links to a task that doesn't
+	// exist are ignored, while the new task's links must have their inverse
+	for _, targetId := range targetIds {
+		if err := ctrl.service.PersistTask(c, *targets[targetId]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update linked task %s", targetId)})
+			return
+		}
+	}
 	if err := ctrl.service.PersistTask(c, task); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
 		return
 	}
 
-	if agentType == domain.AgentTypeLLM {
+	if agentType == domain.AgentTypeLLM && !held {
 		if err := ctrl.startTaskWithTimeout(c, &task); err != nil {
 			return
 		}
@@ -1108,6 +1236,19 @@ func (ctrl *Controller) AgentHandleNewTask(ctx context.Context, task *domain.Tas
 		return err
 	}
 
+	// the task is queued rather than started when concurrency limits are
+	// reached, in which case it stays to_do until it is dequeued
+	stored, err := ctrl.service.GetTask(ctx, task.WorkspaceId, task.Id)
+	if err != nil {
+		return err
+	}
+	task.QueuePosition = stored.QueuePosition
+	if task.QueuePosition > 0 {
+		task.Status = domain.TaskStatusToDo
+		task.Updated = time.Now()
+		return ctrl.service.PersistTask(ctx, *task)
+	}
+
 	// Update the task status to in progress
 	task.Status = domain.TaskStatusInProgress
 	task.Updated = time.Now()
@@ -1147,6 +1288,17 @@ func (ctrl *Controller) GetFlowActionsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"flowActions": flowActions})
 }
 
+// CompleteFlowActionRequest is the body of a request to complete a pending
+// human flow action
+type CompleteFlowActionRequest struct {
+	UserResponse struct {
+		Content  string                 `json:"content"`
+		Approved *bool                  `json:"approved"`
+		Choice   string                 `json:"choice"`
+		Params   map[string]interface{} `json:"params"`
+	} `json:"userResponse"`
+}
+
 func (ctrl *Controller) CompleteFlowActionHandler(c *gin.Context) {
 	flowActionId := c.Param("id")
 
@@ -1160,58 +1312,82 @@ func (ctrl *Controller) CompleteFlowActionHandler(c *gin.Context) {
 		return
 	}
 
-	// minimal validation
-	if !flowAction.IsCallbackAction {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "This flow action doesn't support callback-based completion"})
-		return
-	} else if !flowAction.IsHumanAction {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "For now, only human actions can be completed via this endpoint"})
-		return
-	} else if flowAction.ActionStatus != domain.ActionStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Flow action status is not pending"})
+	if err := validateCompletableFlowAction(flowAction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var body struct {
-		UserResponse struct {
-			Content  string                 `json:"content"`
-			Approved *bool                  `json:"approved"`
-			Choice   string                 `json:"choice"`
-			Params   map[string]interface{} `json:"params"`
-		} `json:"userResponse"`
-	}
+	var body CompleteFlowActionRequest
 	if err := c.BindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
+	flowAction, status, err := ctrl.completeFlowAction(ctx, flowAction, body)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flowAction)
+}
+
+// validateCompletableFlowAction checks that the flow action is waiting on a
+// user's response
+func validateCompletableFlowAction(flowAction domain.FlowAction) error {
+	if !flowAction.IsCallbackAction {
+		return errors.New("This flow action doesn't support callback-based completion")
+	} else if !flowAction.IsHumanAction {
+		return errors.New("For now, only human actions can be completed via this endpoint")
+	} else if flowAction.ActionStatus != domain.ActionStatusPending {
+		return errors.New("Flow action status is not pending")
+	}
+	return nil
+}
+
+// validateUserResponse checks that the response has what the kind of request
+// needs
+func validateUserResponse(flowAction domain.FlowAction, body CompleteFlowActionRequest) error {
 	requestKindString, ok := flowAction.ActionParams["requestKind"].(string)
-	if ok {
-		switch flow_action.RequestKind(requestKindString) {
-		case flow_action.RequestKindFreeForm:
-			if strings.TrimSpace(body.UserResponse.Content) == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "User response cannot be empty"})
-				return
-			}
-		case flow_action.RequestKindApproval:
-			if body.UserResponse.Approved == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Approved cannot be empty"})
-				return
-			}
-		case flow_action.RequestKindMergeApproval:
-			if body.UserResponse.Approved == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Approved cannot be empty"})
-				return
-			}
-			if body.UserResponse.Params["targetBranch"] == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Target branch cannot be empty"})
-			}
-		case flow_action.RequestKindMultipleChoice:
-			if strings.TrimSpace(body.UserResponse.Choice) == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "User choice cannot be empty"})
-				return
-			}
+	if !ok {
+		return nil
+	}
+	switch flow_action.RequestKind(requestKindString) {
+	case flow_action.RequestKindFreeForm:
+		if strings.TrimSpace(body.UserResponse.Content) == "" {
+			return errors.New("User response cannot be empty")
 		}
+	case flow_action.RequestKindApproval:
+		if body.UserResponse.Approved == nil {
+			return errors.New("Approved cannot be empty")
+		}
+	case flow_action.RequestKindMergeApproval:
+		if body.UserResponse.Approved == nil {
+			return errors.New("Approved cannot be empty")
+		}
+		if body.UserResponse.Params["targetBranch"] == nil {
+			return errors.New("Target branch cannot be empty")
+		}
+	case flow_action.RequestKindMultipleChoice:
+		if strings.TrimSpace(body.UserResponse.Choice) == "" {
+			return errors.New("User choice cannot be empty")
+		}
+	}
+	return nil
+}
+
+// completeFlowAction relays the user's response to a pending human flow action
+// to its flow and resumes the flow, returning the completed flow action. On
+// failure, it returns the HTTP status to respond with, along with an error
+// message suitable for the user.
+func (ctrl *Controller) completeFlowAction(ctx context.Context, flowAction domain.FlowAction, body CompleteFlowActionRequest) (domain.FlowAction, int, error) {
+	workspaceId := flowAction.WorkspaceId
+
+	// minimal validation
+	if err := validateCompletableFlowAction(flowAction); err != nil {
+		return flowAction, http.StatusBadRequest, err
+	}
+	if err := validateUserResponse(flowAction, body); err != nil {
+		return flowAction, http.StatusBadRequest, err
 	}
 
 	devAgent := dev.DevAgent{
@@ -1228,8 +1404,7 @@ func (ctrl *Controller) CompleteFlowActionHandler(c *gin.Context) {
 		Params:           body.UserResponse.Params,
 	}
 	if err := devAgent.RelayResponse(ctx, userResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to relay user response"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to relay user response")
 	}
 
 	// NOTE persisting explicitly shouldn't be required normally, i.e. when
@@ -1237,37 +1412,32 @@ func (ctrl *Controller) CompleteFlowActionHandler(c *gin.Context) {
 	// persist explicitly and it doesn't hurt to do so here.
 	userResponseJson, err := json.Marshal(userResponse)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to serialize user response"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to serialize user response")
 	}
 	flowAction.ActionResult = string(userResponseJson)
 	flowAction.ActionStatus = domain.ActionStatusComplete
 
 	if err := ctrl.service.PersistFlowAction(ctx, flowAction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flow action"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to update flow action")
 	}
 
 	// Retrieve the flow and then task associated with the flow action
 	flow, err := ctrl.service.GetFlow(ctx, workspaceId, flowAction.FlowId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flow"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to retrieve flow")
 	}
 
 	// If flow was paused, set it back to in_progress when completing an action
 	if flow.Status == "paused" {
 		flow.Status = "in_progress"
 		if err := ctrl.service.PersistFlow(ctx, flow); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flow status"})
-			return
+			return flowAction, http.StatusInternalServerError, errors.New("Failed to update flow status")
 		}
 	}
 
 	task, err := ctrl.service.GetTask(ctx, workspaceId, flow.ParentId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve task"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to retrieve task")
 	}
 
 	// Update the task status and agent type
@@ -1275,11 +1445,10 @@ func (ctrl *Controller) CompleteFlowActionHandler(c *gin.Context) {
 	task.AgentType = domain.AgentTypeLLM
 	task.Updated = time.Now()
 	if err := ctrl.service.PersistTask(ctx, task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
-		return
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to update task")
 	}
 
-	c.JSON(http.StatusOK, flowAction)
+	return flowAction, http.StatusOK, nil
 }
 
 func (ctrl *Controller) UpdateFlowActionHandler(c *gin.Context) {
@@ -1375,6 +1544,12 @@ func (ctrl *Controller) UpdateTaskHandler(c *gin.Context) {
 		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
 		return
 	}
+	if task.FlowType != "" {
+		if err := domain.ValidateFlowOptions(task.FlowType, taskReq.FlowOptions); err != nil {
+			ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+			return
+		}
+	}
 
 	// Update the 'updated' field to the current time before persisting
 	task.Updated = time.Now()
@@ -1383,6 +1558,15 @@ func (ctrl *Controller) UpdateTaskHandler(c *gin.Context) {
 	task.AgentType = agentType
 	task.Status = status
 	task.FlowOptions = taskReq.FlowOptions
+	if taskReq.Priority != nil {
+		task.Priority = *taskReq.Priority
+	}
+
+	// a queued task moved out of to_do, eg back to drafting, leaves the queue
+	leftQueue := task.QueuePosition > 0 && task.Status != domain.TaskStatusToDo
+	if leftQueue {
+		task.QueuePosition = 0
+	}
 
 	// If the task status is 'to_do' and there is no flow record, start the flow
 	flows, err := ctrl.service.GetFlowsForTask(requestCtx, workspaceId, task.Id)
@@ -1392,9 +1576,15 @@ func (ctrl *Controller) UpdateTaskHandler(c *gin.Context) {
 	}
 
 	if task.Status == domain.TaskStatusToDo && len(flows) == 0 {
-		if err := ctrl.startTaskWithTimeout(c, &task); err != nil {
+		held, err := ctrl.holdIfBlocked(c, &task)
+		if err != nil {
 			return
 		}
+		if !held {
+			if err := ctrl.startTaskWithTimeout(c, &task); err != nil {
+				return
+			}
+		}
 	}
 
 	if err := ctrl.service.PersistTask(requestCtx, task); err != nil {
@@ -1402,6 +1592,13 @@ func (ctrl *Controller) UpdateTaskHandler(c *gin.Context) {
 		return
 	}
 
+	if leftQueue {
+		if err := dev.UpdateQueuePositions(requestCtx, ctrl.service, workspaceId); err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to update queue positions: %w", err))
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"task": task})
 }
 
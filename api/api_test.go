@@ -177,6 +177,18 @@ func TestCreateTaskHandler(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "InvalidFlowOptionsNotAllowed",
+			taskRequest: TaskRequest{
+				Description: "test description",
+				FlowType:    domain.FlowTypeBasicDev,
+				FlowOptions: map[string]interface{}{
+					"determineRequirements": "yes",
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid flow options for flow type \"basic_dev\": determineRequirements: expected boolean, got string",
+		},
 		{
 			name: "NoneAgentTypeNotAllowed",
 			taskRequest: TaskRequest{
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/flow_action"
+	"sidekick/notify"
+	"sidekick/srv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rs/zerolog/log"
+)
+
+// maxCallbackBodySize bounds callback request bodies, which are read in full
+// before their signature is verified
+const maxCallbackBodySize = 64 * 1024
+
+// callbackMutex serializes callbacks, so that a token can't be used twice by
+// concurrent requests
+var callbackMutex sync.Mutex
+
+// errCallbacksDisabled is returned when no callback secret is configured
+var errCallbacksDisabled = errors.New("Callbacks are not enabled")
+
+func callbackSecret() string {
+	config, err := common.LoadSidekickConfig(common.GetSidekickConfigPath())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load sidekick config for callbacks")
+	}
+	return config.Notifications.GetCallbackSecret()
+}
+
+// CallbackHandler answers a pending request for user sent to the callback URL
+// included in its notification, either with a signed notify.CallbackReply, or
+// with the form on the page served by CallbackPageHandler, which only needs
+// the one-time token. This lets users unblock flows from chat or email,
+// without opening Sidekick.
+func (ctrl *Controller) CallbackHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	token := c.Param("token")
+	fromPage := c.ContentType() == binding.MIMEPOSTForm
+	respondError := func(status int, err error) {
+		if fromPage {
+			renderCallbackPage(c, status, callbackPage{Message: err.Error()})
+		} else {
+			c.JSON(status, gin.H{"error": err.Error()})
+		}
+	}
+
+	secret := callbackSecret()
+	if secret == "" {
+		respondError(http.StatusNotFound, errCallbacksDisabled)
+		return
+	}
+
+	var reply notify.CallbackReply
+	if fromPage {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCallbackBodySize)
+		if err := c.Request.ParseForm(); err != nil {
+			respondError(http.StatusBadRequest, errors.New("Invalid form"))
+			return
+		}
+		reply = notify.CallbackReply{
+			Action:  c.Request.PostForm.Get("action"),
+			Content: c.Request.PostForm.Get("content"),
+			Choice:  c.Request.PostForm.Get("choice"),
+		}
+		if targetBranch := strings.TrimSpace(c.Request.PostForm.Get("targetBranch")); targetBranch != "" {
+			reply.Params = map[string]interface{}{"targetBranch": targetBranch}
+		}
+	} else {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCallbackBodySize))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		timestamp := c.GetHeader("X-Sidekick-Timestamp")
+		signature := c.GetHeader("X-Sidekick-Signature")
+		if err := notify.VerifySignature(secret, timestamp, signature, body, time.Now()); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	callbackMutex.Lock()
+	defer callbackMutex.Unlock()
+
+	flowAction, status, err := ctrl.getCallbackFlowAction(c, token)
+	if err != nil {
+		respondError(status, err)
+		return
+	}
+
+	request, err := callbackReplyToRequest(flowAction, reply)
+	if err == nil {
+		err = validateUserResponse(flowAction, request)
+	}
+	if err != nil {
+		respondError(http.StatusBadRequest, err)
+		return
+	}
+
+	// the token is used up before relaying the response, so that it can't
+	// answer again even if completing the flow action fails part way
+	if err := notify.RevokeCallbackToken(ctx, ctrl.service, token); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke callback token")
+		respondError(http.StatusInternalServerError, errors.New("Failed to use callback token"))
+		return
+	}
+
+	flowAction, status, err = ctrl.completeFlowAction(ctx, flowAction, request)
+	if err != nil {
+		respondError(status, err)
+		return
+	}
+	if fromPage {
+		renderCallbackPage(c, http.StatusOK, callbackPage{Message: "Thanks, your response was sent to Sidekick."})
+		return
+	}
+	c.JSON(http.StatusOK, flowAction)
+}
+
+// CallbackPageHandler serves a page with the request for user a callback URL
+// answers, and a form to answer it. Opening the page doesn't use up the
+// token, so that link previews in chat apps don't.
+func (ctrl *Controller) CallbackPageHandler(c *gin.Context) {
+	if callbackSecret() == "" {
+		renderCallbackPage(c, http.StatusNotFound, callbackPage{Message: errCallbacksDisabled.Error()})
+		return
+	}
+
+	flowAction, status, err := ctrl.getCallbackFlowAction(c, c.Param("token"))
+	if err != nil {
+		renderCallbackPage(c, status, callbackPage{Message: err.Error()})
+		return
+	}
+	renderCallbackPage(c, http.StatusOK, newCallbackPage(flowAction))
+}
+
+// getCallbackFlowAction returns the pending flow action the token answers, or
+// the HTTP status and error to respond with
+func (ctrl *Controller) getCallbackFlowAction(c *gin.Context, token string) (domain.FlowAction, int, error) {
+	ctx := c.Request.Context()
+	callbackToken, err := notify.LookupCallbackToken(ctx, ctrl.service, token)
+	if errors.Is(err, notify.ErrInvalidCallbackToken) {
+		return domain.FlowAction{}, http.StatusNotFound, err
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to look up callback token")
+		return domain.FlowAction{}, http.StatusInternalServerError, errors.New("Failed to look up callback token")
+	}
+
+	flowAction, err := ctrl.service.GetFlowAction(ctx, callbackToken.WorkspaceId, callbackToken.FlowActionId)
+	if errors.Is(err, srv.ErrNotFound) {
+		return flowAction, http.StatusNotFound, errors.New("Flow action not found")
+	} else if err != nil {
+		return flowAction, http.StatusInternalServerError, errors.New("Failed to retrieve flow action")
+	}
+	if flowAction.FlowId != callbackToken.FlowId {
+		return flowAction, http.StatusNotFound, errors.New("Flow action not found")
+	}
+	if flowAction.ActionStatus != domain.ActionStatusPending {
+		// answered some other way, eg in the UI
+		if err := notify.RevokeCallbackToken(ctx, ctrl.service, token); err != nil {
+			log.Error().Err(err).Msg("Failed to revoke callback token")
+		}
+		return flowAction, http.StatusGone, errors.New("Flow action status is not pending")
+	}
+	return flowAction, http.StatusOK, nil
+}
+
+// callbackPage is what the callback page template shows: either a message, or
+// a request for user with a form to answer it
+type callbackPage struct {
+	Message      string
+	Content      string
+	RequestKind  string
+	ContentLabel string
+	TargetBranch string
+	Actions      []callbackPageAction
+}
+
+type callbackPageAction struct {
+	Action notify.CallbackAction
+	Label  string
+}
+
+func newCallbackPage(flowAction domain.FlowAction) callbackPage {
+	requestKind, _ := flowAction.ActionParams["requestKind"].(string)
+	content, _ := flowAction.ActionParams["requestContent"].(string)
+	page := callbackPage{Content: strings.TrimSpace(content), RequestKind: requestKind}
+
+	switch flow_action.RequestKind(requestKind) {
+	case flow_action.RequestKindApproval, flow_action.RequestKindMergeApproval:
+		page.ContentLabel = "Feedback (optional)"
+		page.Actions = []callbackPageAction{{notify.CallbackActionApprove, "Approve"}, {notify.CallbackActionReject, "Reject"}}
+		if flow_action.RequestKind(requestKind) == flow_action.RequestKindMergeApproval {
+			params := withDefaultTargetBranch(flowAction, nil)
+			page.TargetBranch, _ = params["targetBranch"].(string)
+		}
+	case flow_action.RequestKindFreeForm:
+		page.ContentLabel = "Answer"
+		page.Actions = []callbackPageAction{{notify.CallbackActionAnswer, "Send"}}
+	case flow_action.RequestKindMultipleChoice:
+		page.Actions = []callbackPageAction{{notify.CallbackActionChoose, "Choose"}}
+	case flow_action.RequestKindContinue:
+		page.ContentLabel = "Message (optional)"
+		page.Actions = []callbackPageAction{{notify.CallbackActionAnswer, "Continue"}}
+	default:
+		page.Message = fmt.Sprintf("Requests of kind %q can't be answered via callbacks", requestKind)
+	}
+	return page
+}
+
+var callbackPageTemplate = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sidekick</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; }
+pre { white-space: pre-wrap; background: #f4f4f4; padding: 1rem; }
+label { display: block; margin-top: 1rem; }
+input, textarea { display: block; width: 100%; box-sizing: border-box; }
+button { margin: 1rem 0.5rem 0 0; }
+</style>
+</head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{else}}<h1>Sidekick needs your input</h1>
+{{if .Content}}<pre>{{.Content}}</pre>{{end}}
+<form method="post">
+{{if eq .RequestKind "merge_approval"}}<label>Target branch <input type="text" name="targetBranch" value="{{.TargetBranch}}" required></label>{{end}}
+{{if eq .RequestKind "multiple_choice"}}<label>Choice <input type="text" name="choice" required></label>{{end}}
+{{if .ContentLabel}}<label>{{.ContentLabel}} <textarea name="content" rows="5"{{if eq .RequestKind "free_form"}} required{{end}}></textarea></label>{{end}}
+{{range .Actions}}<button type="submit" name="action" value="{{.Action}}">{{.Label}}</button>{{end}}
+</form>{{end}}
+</body>
+</html>
+`))
+
+func renderCallbackPage(c *gin.Context, status int, page callbackPage) {
+	var buf bytes.Buffer
+	if err := callbackPageTemplate.Execute(&buf, page); err != nil {
+		log.Error().Err(err).Msg("Failed to render callback page")
+		c.String(http.StatusInternalServerError, "Failed to render page")
+		return
+	}
+	// the URL holds the token, which mustn't leak to other sites or caches
+	c.Header("Referrer-Policy", "same-origin")
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// callbackReplyToRequest maps a callback reply onto the request the UI would
+// send to complete the flow action, checking that the reply's action makes
+// sense for the kind of request
+func callbackReplyToRequest(flowAction domain.FlowAction, reply notify.CallbackReply) (CompleteFlowActionRequest, error) {
+	var request CompleteFlowActionRequest
+	request.UserResponse.Content = reply.Content
+	request.UserResponse.Params = reply.Params
+
+	requestKind, _ := flowAction.ActionParams["requestKind"].(string)
+	var allowed []notify.CallbackAction
+	switch flow_action.RequestKind(requestKind) {
+	case flow_action.RequestKindApproval, flow_action.RequestKindMergeApproval:
+		allowed = []notify.CallbackAction{notify.CallbackActionApprove, notify.CallbackActionReject}
+		approved := reply.Action == notify.CallbackActionApprove
+		request.UserResponse.Approved = &approved
+		if flow_action.RequestKind(requestKind) == flow_action.RequestKindMergeApproval {
+			request.UserResponse.Params = withDefaultTargetBranch(flowAction, reply.Params)
+		}
+	case flow_action.RequestKindFreeForm:
+		allowed = []notify.CallbackAction{notify.CallbackActionAnswer}
+	case flow_action.RequestKindMultipleChoice:
+		allowed = []notify.CallbackAction{notify.CallbackActionChoose}
+		request.UserResponse.Choice = reply.Choice
+	case flow_action.RequestKindContinue:
+		allowed = []notify.CallbackAction{notify.CallbackActionAnswer, notify.CallbackActionApprove}
+	default:
+		return request, fmt.Errorf("Requests of kind %q can't be answered via callbacks", requestKind)
+	}
+
+	if slices.Contains(allowed, reply.Action) {
+		return request, nil
+	}
+	quoted := make([]string, len(allowed))
+	for i, action := range allowed {
+		quoted[i] = fmt.Sprintf("%q", action)
+	}
+	return request, fmt.Errorf("Action must be one of %s for %s requests", strings.Join(quoted, ", "), requestKind)
+}
+
+// withDefaultTargetBranch fills in the merge's target branch when the reply
+// doesn't override it, as chat replies can't easily confirm it the way the UI
+// does
+func withDefaultTargetBranch(flowAction domain.FlowAction, params map[string]interface{}) map[string]interface{} {
+	if params != nil && params["targetBranch"] != nil {
+		return params
+	}
+	mergeApprovalInfo, _ := flowAction.ActionParams["mergeApprovalInfo"].(map[string]interface{})
+	defaultTargetBranch, _ := mergeApprovalInfo["defaultTargetBranch"].(string)
+	if defaultTargetBranch == "" {
+		return params
+	}
+	withTarget := make(map[string]interface{}, len(params)+1)
+	for key, value := range params {
+		withTarget[key] = value
+	}
+	withTarget["targetBranch"] = defaultTargetBranch
+	return withTarget
+}
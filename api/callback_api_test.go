@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sidekick/domain"
+	"sidekick/flow_action"
+	"sidekick/notify"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackReplyToRequest(t *testing.T) {
+	t.Parallel()
+	flowActionOfKind := func(kind flow_action.RequestKind) domain.FlowAction {
+		return domain.FlowAction{ActionParams: map[string]interface{}{"requestKind": string(kind)}}
+	}
+
+	t.Run("approve", func(t *testing.T) {
+		request, err := callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindApproval), notify.CallbackReply{Action: notify.CallbackActionApprove})
+		require.NoError(t, err)
+		require.NotNil(t, request.UserResponse.Approved)
+		assert.True(t, *request.UserResponse.Approved)
+	})
+
+	t.Run("reject with feedback", func(t *testing.T) {
+		request, err := callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindApproval), notify.CallbackReply{Action: notify.CallbackActionReject, Content: "Add tests"})
+		require.NoError(t, err)
+		require.NotNil(t, request.UserResponse.Approved)
+		assert.False(t, *request.UserResponse.Approved)
+		assert.Equal(t, "Add tests", request.UserResponse.Content)
+	})
+
+	t.Run("merge approval defaults the target branch", func(t *testing.T) {
+		flowAction := flowActionOfKind(flow_action.RequestKindMergeApproval)
+		flowAction.ActionParams["mergeApprovalInfo"] = map[string]interface{}{"defaultTargetBranch": "main"}
+
+		request, err := callbackReplyToRequest(flowAction, notify.CallbackReply{Action: notify.CallbackActionApprove})
+		require.NoError(t, err)
+		assert.Equal(t, "main", request.UserResponse.Params["targetBranch"])
+
+		request, err = callbackReplyToRequest(flowAction, notify.CallbackReply{Action: notify.CallbackActionApprove, Params: map[string]interface{}{"targetBranch": "develop"}})
+		require.NoError(t, err)
+		assert.Equal(t, "develop", request.UserResponse.Params["targetBranch"])
+	})
+
+	t.Run("free-form answer", func(t *testing.T) {
+		request, err := callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindFreeForm), notify.CallbackReply{Action: notify.CallbackActionAnswer, Content: "Use postgres"})
+		require.NoError(t, err)
+		assert.Equal(t, "Use postgres", request.UserResponse.Content)
+		assert.Nil(t, request.UserResponse.Approved)
+	})
+
+	t.Run("multiple-choice pick", func(t *testing.T) {
+		request, err := callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindMultipleChoice), notify.CallbackReply{Action: notify.CallbackActionChoose, Choice: "B"})
+		require.NoError(t, err)
+		assert.Equal(t, "B", request.UserResponse.Choice)
+	})
+
+	t.Run("action not matching the request kind", func(t *testing.T) {
+		_, err := callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindFreeForm), notify.CallbackReply{Action: notify.CallbackActionApprove})
+		assert.ErrorContains(t, err, `"answer"`)
+		_, err = callbackReplyToRequest(flowActionOfKind(flow_action.RequestKindApproval), notify.CallbackReply{Action: "maybe"})
+		assert.ErrorContains(t, err, `"approve", "reject"`)
+		_, err = callbackReplyToRequest(domain.FlowAction{}, notify.CallbackReply{Action: notify.CallbackActionAnswer})
+		assert.Error(t, err)
+	})
+}
+
+func TestCallbackHandler(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "sidekick")
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	configYAML := `
+notifications:
+  callback_secret: s3cret
+`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(configYAML), 0644))
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("XDG_CONFIG_DIRS", tmpDir)
+	xdg.Reload()
+
+	ctrl := NewMockController(t)
+	router := DefineRoutes(ctrl, TestAllowedOrigins())
+	ctx := context.Background()
+	workspaceId := "ws_123"
+
+	task := domain.Task{WorkspaceId: workspaceId, Id: "task_1", Status: domain.TaskStatusInProgress, AgentType: domain.AgentTypeHuman}
+	flow := domain.Flow{WorkspaceId: workspaceId, Id: "flow_1", ParentId: task.Id, Status: "paused"}
+	flowAction := domain.FlowAction{
+		WorkspaceId:      workspaceId,
+		FlowId:           flow.Id,
+		Id:               "flow_action_1",
+		ActionStatus:     domain.ActionStatusPending,
+		ActionType:       "user_request.approve.plan",
+		ActionParams:     map[string]interface{}{"requestKind": string(flow_action.RequestKindApproval)},
+		IsHumanAction:    true,
+		IsCallbackAction: true,
+	}
+	require.NoError(t, ctrl.service.PersistTask(ctx, task))
+	require.NoError(t, ctrl.service.PersistFlow(ctx, flow))
+	require.NoError(t, ctrl.service.PersistFlowAction(ctx, flowAction))
+
+	token, err := notify.IssueCallbackToken(ctx, ctrl.service, notify.CallbackToken{WorkspaceId: workspaceId, FlowId: flow.Id, FlowActionId: flowAction.Id})
+	require.NoError(t, err)
+
+	send := func(token, secret, body string) *httptest.ResponseRecorder {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest("POST", "/api/v1/callbacks/"+token, strings.NewReader(body))
+		req.Header.Set("X-Sidekick-Timestamp", timestamp)
+		req.Header.Set("X-Sidekick-Signature", notify.SignPayload(secret, timestamp, []byte(body)))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := send(token, "wrong", `{"action":"approve"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = send("unknown", "s3cret", `{"action":"approve"}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = send(token, "s3cret", `{"action":"answer"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = send(token, "s3cret", `{"action":"reject","content":"Split it into smaller steps"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `"actionStatus":"complete"`)
+
+	retrievedFlowAction, err := ctrl.service.GetFlowAction(ctx, workspaceId, flowAction.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ActionStatusComplete, retrievedFlowAction.ActionStatus)
+	assert.Contains(t, retrievedFlowAction.ActionResult, `"Content":"Split it into smaller steps","Approved":false`)
+	retrievedFlow, err := ctrl.service.GetFlow(ctx, workspaceId, flow.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "in_progress", retrievedFlow.Status)
+	retrievedTask, err := ctrl.service.GetTask(ctx, workspaceId, task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AgentTypeLLM, retrievedTask.AgentType)
+
+	// tokens can only be used once
+	resp = send(token, "s3cret", `{"action":"approve"}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// without a signature, requests are answered from the callback page
+	question := domain.FlowAction{
+		WorkspaceId:      workspaceId,
+		FlowId:           flow.Id,
+		Id:               "flow_action_2",
+		ActionStatus:     domain.ActionStatusPending,
+		ActionType:       "user_request",
+		ActionParams:     map[string]interface{}{"requestKind": string(flow_action.RequestKindFreeForm), "requestContent": "Which <database>?"},
+		IsHumanAction:    true,
+		IsCallbackAction: true,
+	}
+	require.NoError(t, ctrl.service.PersistFlowAction(ctx, question))
+	token, err = notify.IssueCallbackToken(ctx, ctrl.service, notify.CallbackToken{WorkspaceId: workspaceId, FlowId: flow.Id, FlowActionId: question.Id})
+	require.NoError(t, err)
+
+	open := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/callbacks/"+token, nil))
+		return resp
+	}
+	submit := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/callbacks/"+token, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp = open()
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "Which &lt;database&gt;?")
+	assert.Contains(t, resp.Body.String(), `value="answer"`)
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+
+	// invalid answers don't use up the token
+	resp = submit(url.Values{"action": {"answer"}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "User response cannot be empty")
+	resp = submit(url.Values{"action": {"answer"}, "content": {"Postgres"}})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "your response was sent")
+
+	retrievedFlowAction, err = ctrl.service.GetFlowAction(ctx, workspaceId, question.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ActionStatusComplete, retrievedFlowAction.ActionStatus)
+	assert.Contains(t, retrievedFlowAction.ActionResult, `"Content":"Postgres"`)
+	assert.Equal(t, http.StatusNotFound, open().Code)
+}
+
+func TestNewCallbackPage(t *testing.T) {
+	t.Parallel()
+	page := newCallbackPage(domain.FlowAction{ActionParams: map[string]interface{}{
+		"requestKind":       string(flow_action.RequestKindMergeApproval),
+		"requestContent":    "Merge?\n",
+		"mergeApprovalInfo": map[string]interface{}{"defaultTargetBranch": "main"},
+	}})
+	assert.Equal(t, "Merge?", page.Content)
+	assert.Equal(t, "main", page.TargetBranch)
+	assert.Equal(t, []callbackPageAction{{notify.CallbackActionApprove, "Approve"}, {notify.CallbackActionReject, "Reject"}}, page.Actions)
+	assert.Empty(t, page.Message)
+
+	resp := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(resp)
+	renderCallbackPage(c, http.StatusOK, page)
+	assert.Contains(t, resp.Body.String(), `name="targetBranch" value="main" required`)
+	assert.Contains(t, resp.Body.String(), `<button type="submit" name="action" value="reject">Reject</button>`)
+
+	page = newCallbackPage(domain.FlowAction{})
+	assert.Contains(t, page.Message, "can't be answered via callbacks")
+}
+
+func TestCallbackHandler_Disabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sidekick"), 0755))
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("XDG_CONFIG_DIRS", tmpDir)
+	xdg.Reload()
+
+	router := DefineRoutes(NewMockController(t), TestAllowedOrigins())
+	req := httptest.NewRequest("POST", "/api/v1/callbacks/anything", strings.NewReader(`{"action":"approve"}`))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Callbacks are not enabled")
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"sidekick/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlowTypesResponse is the JSON response for the flow types endpoint.
+type FlowTypesResponse struct {
+	FlowTypes []domain.FlowTypeDefinition `json:"flowTypes"`
+}
+
+// GetFlowTypesHandler lists the registered flow types along with the JSON
+// schema of their flow options, so clients can offer and validate them
+// generically.
+func (ctrl *Controller) GetFlowTypesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, FlowTypesResponse{FlowTypes: domain.ListFlowTypes()})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sidekick/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFlowTypesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// the handler is stateless, so doesn't need a controller with a service
+	router := DefineRoutes(Controller{}, TestAllowedOrigins())
+
+	req, _ := http.NewRequest("GET", "/api/v1/flow_types", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		FlowTypes []struct {
+			Name          string         `json:"name"`
+			Label         string         `json:"label"`
+			OptionsSchema map[string]any `json:"optionsSchema"`
+		} `json:"flowTypes"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+	names := make([]string, 0, len(response.FlowTypes))
+	for _, flowType := range response.FlowTypes {
+		names = append(names, flowType.Name)
+		assert.NotEmpty(t, flowType.Label)
+		assert.Equal(t, "object", flowType.OptionsSchema["type"])
+	}
+	assert.Contains(t, names, domain.FlowTypeBasicDev)
+	assert.Contains(t, names, domain.FlowTypePlannedDev)
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sidekick/dev"
+	"sidekick/domain"
+	"sidekick/srv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskLinkRequest struct {
+	LinkType     string `json:"linkType"`
+	TargetTaskId string `json:"targetTaskId"`
+}
+
+// AddTaskLinkHandler links a task to another task, adding the inverse link to
+// the target task. Links that would create a cycle of blocking or parent/child
+// links are rejected.
+func (ctrl *Controller) AddTaskLinkHandler(c *gin.Context) {
+	workspaceId := c.Param("workspaceId")
+	taskId := c.Param("id")
+
+	var linkReq TaskLinkRequest
+	if err := c.ShouldBindJSON(&linkReq); err != nil {
+		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+		return
+	}
+
+	task, target, ok := ctrl.getLinkedTasks(c, workspaceId, taskId, linkReq.LinkType, linkReq.TargetTaskId)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.addTaskLink(c, &task, linkReq.LinkType, &target); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task": task})
+}
+
+// RemoveTaskLinkHandler removes a link between two tasks. If the task that
+// was blocked is no longer blocked by any incomplete task, it is started.
+func (ctrl *Controller) RemoveTaskLinkHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	workspaceId := c.Param("workspaceId")
+	taskId := c.Param("id")
+	linkType := c.Param("linkType")
+
+	task, target, ok := ctrl.getLinkedTasks(c, workspaceId, taskId, linkType, c.Param("targetTaskId"))
+	if !ok {
+		return
+	}
+
+	inverseLinkType, _ := domain.InverseLinkType(linkType)
+	if !task.RemoveLink(linkType, target.Id) {
+		ctrl.ErrorHandler(c, http.StatusNotFound, fmt.Errorf("task %s has no %s link to task %s", task.Id, linkType, target.Id))
+		return
+	}
+	target.RemoveLink(inverseLinkType, task.Id)
+
+	now := time.Now()
+	task.Updated = now
+	target.Updated = now
+	for _, t := range []domain.Task{target, task} {
+		if err := ctrl.service.PersistTask(ctx, t); err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to update task %s: %w", t.Id, err))
+			return
+		}
+	}
+
+	// start the task that was blocked if it was only waiting on this link
+	var blocked *domain.Task
+	switch linkType {
+	case domain.LinkTypeBlockedBy:
+		blocked = &task
+	case domain.LinkTypeBlocks:
+		blocked = &target
+	}
+	if blocked != nil {
+		if err := ctrl.startIfUnblocked(c, blocked); err != nil {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task": task})
+}
+
+// getLinkedTasks validates the link type and retrieves both tasks of a link,
+// sending an error response and returning false if that fails.
+func (ctrl *Controller) getLinkedTasks(c *gin.Context, workspaceId, taskId, linkType, targetTaskId string) (domain.Task, domain.Task, bool) {
+	ctx := c.Request.Context()
+	if _, err := domain.InverseLinkType(linkType); err != nil {
+		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+		return domain.Task{}, domain.Task{}, false
+	}
+	if targetTaskId == "" {
+		ctrl.ErrorHandler(c, http.StatusBadRequest, errors.New("targetTaskId is required"))
+		return domain.Task{}, domain.Task{}, false
+	}
+	if targetTaskId == taskId {
+		ctrl.ErrorHandler(c, http.StatusBadRequest, errors.New("a task can't be linked to itself"))
+		return domain.Task{}, domain.Task{}, false
+	}
+
+	task, err := ctrl.service.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		if errors.Is(err, srv.ErrNotFound) {
+			ctrl.ErrorHandler(c, http.StatusNotFound, fmt.Errorf("task %s not found", taskId))
+		} else {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		}
+		return domain.Task{}, domain.Task{}, false
+	}
+
+	target, err := ctrl.service.GetTask(ctx, workspaceId, targetTaskId)
+	if err != nil {
+		if errors.Is(err, srv.ErrNotFound) {
+			ctrl.ErrorHandler(c, http.StatusBadRequest, fmt.Errorf("target task %s not found", targetTaskId))
+		} else {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		}
+		return domain.Task{}, domain.Task{}, false
+	}
+
+	return task, target, true
+}
+
+// addTaskLink adds a link from task to target and its inverse, persisting
+// both tasks. An error response is sent if the link isn't allowed or fails to
+// persist.
+func (ctrl *Controller) addTaskLink(c *gin.Context, task *domain.Task, linkType domain.LinkType, target *domain.Task) error {
+	ctx := c.Request.Context()
+	if task.HasLink(linkType, target.Id) {
+		return nil
+	}
+	if err := ctrl.linkTasks(c, task, linkType, target, nil); err != nil {
+		return err
+	}
+
+	for _, t := range []domain.Task{*target, *task} {
+		if err := ctrl.service.PersistTask(ctx, t); err != nil {
+			err = fmt.Errorf("failed to update task %s: %w", t.Id, err)
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// linkTasks adds a link from task to target and its inverse without
+// persisting either task. When checking for cycles, pending holds tasks
+// already linked in memory, which take precedence over the stored ones. An
+// error response is sent if the link isn't allowed.
+func (ctrl *Controller) linkTasks(c *gin.Context, task *domain.Task, linkType domain.LinkType, target *domain.Task, pending map[string]*domain.Task) error {
+	ctx := c.Request.Context()
+	if task.HasLink(linkType, target.Id) {
+		return nil
+	}
+
+	cycle, err := domain.FindLinkCycle(task.Id, linkType, target.Id, func(id string) (domain.Task, error) {
+		switch id {
+		case task.Id:
+			return *task, nil
+		case target.Id:
+			return *target, nil
+		}
+		if linked, ok := pending[id]; ok {
+			return *linked, nil
+		}
+		linked, err := ctrl.service.GetTask(ctx, task.WorkspaceId, id)
+		if errors.Is(err, srv.ErrNotFound) {
+			// links to deleted tasks can't be part of a cycle
+			return domain.Task{Id: id}, nil
+		}
+		return linked, err
+	})
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		return err
+	}
+	if cycle != nil {
+		err := fmt.Errorf("adding a %s link from task %s to task %s would create a cycle: %s", linkType, task.Id, target.Id, domain.FormatLinkCycle(cycle))
+		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+		return err
+	}
+
+	blocker := target
+	if linkType == domain.LinkTypeBlocks {
+		blocker = task
+	}
+	if (linkType == domain.LinkTypeBlocks || linkType == domain.LinkTypeBlockedBy) &&
+		(blocker.Status == domain.TaskStatusFailed || blocker.Status == domain.TaskStatusCanceled) {
+		err := fmt.Errorf("task %s has status '%s' and can't block other tasks", blocker.Id, blocker.Status)
+		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+		return err
+	}
+
+	inverseLinkType, _ := domain.InverseLinkType(linkType)
+	task.AddLink(linkType, target.Id)
+	target.AddLink(inverseLinkType, task.Id)
+
+	now := time.Now()
+	task.Updated = now
+	target.Updated = now
+	return nil
+}
+
+// holdIfBlocked sets a task that is about to be started to blocked instead,
+// if any of the tasks blocking it are incomplete, returning whether it did.
+// The task is started once its blockers complete. An error response is sent
+// if checking the blockers fails.
+func (ctrl *Controller) holdIfBlocked(c *gin.Context, task *domain.Task) (bool, error) {
+	incomplete, err := dev.GetIncompleteBlockers(c.Request.Context(), ctrl.service, *task)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		return false, err
+	}
+	if len(incomplete) == 0 {
+		return false, nil
+	}
+	task.Status = domain.TaskStatusBlocked
+	task.AgentType = domain.AgentTypeLLM
+	task.Updated = time.Now()
+	return true, nil
+}
+
+// startIfUnblocked starts a task that was held back from starting by its
+// blockers if none of them are incomplete anymore. An error response is sent
+// if that fails.
+func (ctrl *Controller) startIfUnblocked(c *gin.Context, task *domain.Task) error {
+	ctx := c.Request.Context()
+	if task.Status != domain.TaskStatusBlocked {
+		return nil
+	}
+
+	// a blocked task with flows is waiting on user input rather than blockers
+	flows, err := ctrl.service.GetFlowsForTask(ctx, task.WorkspaceId, task.Id)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		return err
+	}
+	if len(flows) > 0 {
+		return nil
+	}
+
+	held, err := ctrl.holdIfBlocked(c, task)
+	if err != nil || held {
+		return err
+	}
+	return ctrl.startTaskWithTimeout(c, task)
+}
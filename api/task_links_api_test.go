@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/domain"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLinkTestTask(workspaceId string, status domain.TaskStatus, links ...domain.TaskLink) domain.Task {
+	return domain.Task{
+		WorkspaceId: workspaceId,
+		Id:          "task_" + ksuid.New().String(),
+		Description: "test description",
+		Status:      status,
+		AgentType:   domain.AgentTypeLLM,
+		FlowType:    domain.FlowTypeBasicDev,
+		Links:       links,
+	}
+}
+
+func addTaskLink(ctrl *Controller, workspaceId, taskId string, linkReq TaskLinkRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(linkReq)
+	resp := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(resp)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/workspaces/"+workspaceId+"/tasks/"+taskId+"/links", bytes.NewBuffer(body))
+	ginCtx.Params = []gin.Param{
+		{Key: "workspaceId", Value: workspaceId},
+		{Key: "id", Value: taskId},
+	}
+	ctrl.AddTaskLinkHandler(ginCtx)
+	return resp
+}
+
+func TestAddTaskLinkHandler(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	ctx := context.Background()
+	workspaceId := "ws_" + ksuid.New().String()
+
+	a := newLinkTestTask(workspaceId, domain.TaskStatusInProgress)
+	b := newLinkTestTask(workspaceId, domain.TaskStatusToDo)
+	require.NoError(t, ctrl.service.PersistTask(ctx, a))
+	require.NoError(t, ctrl.service.PersistTask(ctx, b))
+
+	resp := addTaskLink(&ctrl, workspaceId, b.Id, TaskLinkRequest{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: a.Id})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	b, err := ctrl.service.GetTask(ctx, workspaceId, b.Id)
+	require.NoError(t, err)
+	assert.True(t, b.HasLink(domain.LinkTypeBlockedBy, a.Id))
+	a, err = ctrl.service.GetTask(ctx, workspaceId, a.Id)
+	require.NoError(t, err)
+	assert.True(t, a.HasLink(domain.LinkTypeBlocks, b.Id))
+
+	// the reverse link would create a cycle
+	resp = addTaskLink(&ctrl, workspaceId, a.Id, TaskLinkRequest{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: b.Id})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "would create a cycle")
+
+	resp = addTaskLink(&ctrl, workspaceId, a.Id, TaskLinkRequest{LinkType: "related", TargetTaskId: b.Id})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = addTaskLink(&ctrl, workspaceId, a.Id, TaskLinkRequest{LinkType: domain.LinkTypeBlocks, TargetTaskId: a.Id})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = addTaskLink(&ctrl, workspaceId, a.Id, TaskLinkRequest{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_missing"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestAddTaskLinkHandler_RejectsFailedBlocker(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	ctx := context.Background()
+	workspaceId := "ws_" + ksuid.New().String()
+
+	a := newLinkTestTask(workspaceId, domain.TaskStatusFailed)
+	b := newLinkTestTask(workspaceId, domain.TaskStatusToDo)
+	require.NoError(t, ctrl.service.PersistTask(ctx, a))
+	require.NoError(t, ctrl.service.PersistTask(ctx, b))
+
+	resp := addTaskLink(&ctrl, workspaceId, a.Id, TaskLinkRequest{LinkType: domain.LinkTypeBlocks, TargetTaskId: b.Id})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "can't block other tasks")
+}
+
+func TestRemoveTaskLinkHandler_StartsUnblockedTask(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	ctx := context.Background()
+	workspaceId := "ws_" + ksuid.New().String()
+
+	a := newLinkTestTask(workspaceId, domain.TaskStatusInProgress)
+	b := newLinkTestTask(workspaceId, domain.TaskStatusBlocked, domain.TaskLink{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: a.Id})
+	a.Links = []domain.TaskLink{{LinkType: domain.LinkTypeBlocks, TargetTaskId: b.Id}}
+	require.NoError(t, ctrl.service.PersistTask(ctx, a))
+	require.NoError(t, ctrl.service.PersistTask(ctx, b))
+
+	resp := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(resp)
+	ginCtx.Request = httptest.NewRequest(http.MethodDelete, "/workspaces/"+workspaceId+"/tasks/"+a.Id+"/links/blocks/"+b.Id, nil)
+	ginCtx.Params = []gin.Param{
+		{Key: "workspaceId", Value: workspaceId},
+		{Key: "id", Value: a.Id},
+		{Key: "linkType", Value: domain.LinkTypeBlocks},
+		{Key: "targetTaskId", Value: b.Id},
+	}
+	ctrl.RemoveTaskLinkHandler(ginCtx)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	b, err := ctrl.service.GetTask(ctx, workspaceId, b.Id)
+	require.NoError(t, err)
+	assert.Empty(t, b.Links)
+	assert.Equal(t, domain.TaskStatusInProgress, b.Status)
+}
+
+func TestCreateTaskHandler_HoldsBlockedTask(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	ctx := context.Background()
+	workspaceId := "ws_" + ksuid.New().String()
+
+	blocker := newLinkTestTask(workspaceId, domain.TaskStatusInProgress)
+	require.NoError(t, ctrl.service.PersistTask(ctx, blocker))
+
+	body, _ := json.Marshal(TaskRequest{
+		Description: "test description",
+		FlowType:    domain.FlowTypeBasicDev,
+		Links:       []domain.TaskLink{{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: blocker.Id}},
+	})
+	resp := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(resp)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/workspaces/"+workspaceId+"/tasks", bytes.NewBuffer(body))
+	ginCtx.Params = []gin.Param{{Key: "workspaceId", Value: workspaceId}}
+	ctrl.CreateTaskHandler(ginCtx)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var result struct {
+		Task domain.Task `json:"task"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	task, err := ctrl.service.GetTask(ctx, workspaceId, result.Task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatusBlocked, task.Status)
+	assert.True(t, task.HasLink(domain.LinkTypeBlockedBy, blocker.Id))
+
+	flows, err := ctrl.service.GetFlowsForTask(ctx, workspaceId, task.Id)
+	require.NoError(t, err)
+	assert.Empty(t, flows)
+}
+
+func TestCreateTaskHandler_RejectedLinkCreatesNothing(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	ctx := context.Background()
+	workspaceId := "ws_" + ksuid.New().String()
+
+	blocker := newLinkTestTask(workspaceId, domain.TaskStatusInProgress)
+	failed := newLinkTestTask(workspaceId, domain.TaskStatusFailed)
+	require.NoError(t, ctrl.service.PersistTask(ctx, blocker))
+	require.NoError(t, ctrl.service.PersistTask(ctx, failed))
+
+	body, _ := json.Marshal(TaskRequest{
+		Description: "test description",
+		FlowType:    domain.FlowTypeBasicDev,
+		Links: []domain.TaskLink{
+			{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: blocker.Id},
+			{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: failed.Id},
+		},
+	})
+	resp := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(resp)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/workspaces/"+workspaceId+"/tasks", bytes.NewBuffer(body))
+	ginCtx.Params = []gin.Param{{Key: "workspaceId", Value: workspaceId}}
+	ctrl.CreateTaskHandler(ginCtx)
+	require.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "can't block other tasks")
+
+	tasks, err := ctrl.service.GetTasks(ctx, workspaceId, domain.AllTaskStatuses)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+	blocker, err = ctrl.service.GetTask(ctx, workspaceId, blocker.Id)
+	require.NoError(t, err)
+	assert.Empty(t, blocker.Links)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sidekick/domain"
+	"sidekick/srv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const archivedTasksUsagePageSize = 100
+
+// GetTaskUsageHandler returns the LLM token usage and cost of a task, broken
+// down by flow and subflow.
+func (ctrl *Controller) GetTaskUsageHandler(c *gin.Context) {
+	workspaceId := c.Param("workspaceId")
+	taskId := c.Param("id")
+
+	if workspaceId == "" || taskId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Workspace ID and Task ID are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	task, err := ctrl.service.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		if errors.Is(err, srv.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	taskUsage, err := ctrl.getTaskLlmUsage(ctx, task)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": taskUsage})
+}
+
+// GetFlowUsageHandler returns the LLM token usage and cost of a flow, broken
+// down by subflow.
+func (ctrl *Controller) GetFlowUsageHandler(c *gin.Context) {
+	workspaceId := c.Param("workspaceId")
+	flowId := c.Param("id")
+
+	if workspaceId == "" || flowId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Workspace ID and Flow ID are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := ctrl.service.GetFlow(ctx, workspaceId, flowId); err != nil {
+		if errors.Is(err, srv.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Flow not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get flow"})
+		}
+		return
+	}
+
+	flowUsage, err := ctrl.getFlowLlmUsage(ctx, workspaceId, flowId)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": flowUsage})
+}
+
+// GetWorkspaceUsageHandler returns the LLM token usage and cost of all tasks in
+// a workspace, most expensive first. Archived tasks are only included when the
+// includeArchived query param is true. The optional since query param (RFC3339)
+// limits the result to tasks created at or after that time.
+func (ctrl *Controller) GetWorkspaceUsageHandler(c *gin.Context) {
+	workspaceId := c.Param("workspaceId")
+	if workspaceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Workspace ID is required"})
+		return
+	}
+
+	var since time.Time
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since parameter, expected RFC3339 timestamp: %v", err)})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	tasks, err := ctrl.service.GetTasks(ctx, workspaceId, domain.AllTaskStatuses)
+	if err != nil {
+		ctrl.ErrorHandler(c, http.StatusInternalServerError, fmt.Errorf("failed to get tasks: %w", err))
+		return
+	}
+
+	if c.Query("includeArchived") == "true" {
+		archivedTasks, err := ctrl.getAllArchivedTasks(ctx, workspaceId)
+		if err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+			return
+		}
+		tasks = append(tasks, archivedTasks...)
+	}
+
+	taskUsages := make([]domain.TaskLlmUsage, 0, len(tasks))
+	for _, task := range tasks {
+		if !since.IsZero() && task.Created.Before(since) {
+			continue
+		}
+		taskUsage, err := ctrl.getTaskLlmUsage(ctx, task)
+		if err != nil {
+			ctrl.ErrorHandler(c, http.StatusInternalServerError, err)
+			return
+		}
+		// the per-flow breakdown is available from the task usage endpoint
+		taskUsage.Flows = nil
+		taskUsages = append(taskUsages, taskUsage)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": domain.SummarizeWorkspaceLlmUsage(workspaceId, taskUsages)})
+}
+
+func (ctrl *Controller) getTaskLlmUsage(ctx context.Context, task domain.Task) (domain.TaskLlmUsage, error) {
+	flows, err := ctrl.service.GetFlowsForTask(ctx, task.WorkspaceId, task.Id)
+	if err != nil {
+		return domain.TaskLlmUsage{}, fmt.Errorf("failed to get flows for task %s: %w", task.Id, err)
+	}
+
+	flowUsages := make([]domain.FlowLlmUsage, 0, len(flows))
+	for _, flow := range flows {
+		flowUsage, err := ctrl.getFlowLlmUsage(ctx, task.WorkspaceId, flow.Id)
+		if err != nil {
+			return domain.TaskLlmUsage{}, err
+		}
+		flowUsages = append(flowUsages, flowUsage)
+	}
+
+	return domain.SummarizeTaskLlmUsage(task, flowUsages), nil
+}
+
+func (ctrl *Controller) getFlowLlmUsage(ctx context.Context, workspaceId, flowId string) (domain.FlowLlmUsage, error) {
+	flowActions, err := ctrl.service.GetFlowActions(ctx, workspaceId, flowId)
+	if err != nil {
+		return domain.FlowLlmUsage{}, fmt.Errorf("failed to get flow actions for flow %s: %w", flowId, err)
+	}
+	subflows, err := ctrl.service.GetSubflows(ctx, workspaceId, flowId)
+	if err != nil {
+		return domain.FlowLlmUsage{}, fmt.Errorf("failed to get subflows for flow %s: %w", flowId, err)
+	}
+	return domain.SummarizeFlowLlmUsage(flowId, subflows, flowActions), nil
+}
+
+func (ctrl *Controller) getAllArchivedTasks(ctx context.Context, workspaceId string) ([]domain.Task, error) {
+	var archivedTasks []domain.Task
+	for page := int64(1); ; page++ {
+		tasks, totalCount, err := ctrl.service.GetArchivedTasks(ctx, workspaceId, page, archivedTasksUsagePageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get archived tasks: %w", err)
+		}
+		archivedTasks = append(archivedTasks, tasks...)
+		if len(tasks) == 0 || int64(len(archivedTasks)) >= totalCount {
+			return archivedTasks, nil
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sidekick/common"
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedUsageTask(t *testing.T, ctrl Controller, workspaceId, taskId string, costs ...float64) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	require.NoError(t, ctrl.service.PersistTask(ctx, domain.Task{
+		WorkspaceId: workspaceId,
+		Id:          taskId,
+		Title:       "Task " + taskId,
+		Status:      domain.TaskStatusComplete,
+		Created:     now,
+		Updated:     now,
+	}))
+
+	flowId := "flow_" + taskId
+	require.NoError(t, ctrl.service.PersistFlow(ctx, domain.Flow{
+		WorkspaceId: workspaceId,
+		Id:          flowId,
+		ParentId:    taskId,
+		Type:        domain.FlowTypeBasicDev,
+		Status:      "completed",
+	}))
+
+	subflowType := "step"
+	require.NoError(t, ctrl.service.PersistSubflow(ctx, domain.Subflow{
+		WorkspaceId: workspaceId,
+		Id:          "sf_" + taskId,
+		Name:        "Coding",
+		Type:        &subflowType,
+		FlowId:      flowId,
+		Status:      domain.SubflowStatusComplete,
+	}))
+
+	for i, cost := range costs {
+		require.NoError(t, ctrl.service.PersistFlowAction(ctx, domain.FlowAction{
+			WorkspaceId:  workspaceId,
+			FlowId:       flowId,
+			SubflowId:    "sf_" + taskId,
+			Id:           "fa_" + taskId + "_" + string(rune('a'+i)),
+			ActionType:   "generate.code",
+			ActionStatus: domain.ActionStatusComplete,
+			ActionParams: map[string]interface{}{},
+			Created:      now,
+			Updated:      now,
+			LlmUsage: &common.LlmUsage{
+				Calls:        1,
+				InputTokens:  1000,
+				OutputTokens: 100,
+				Cost:         cost,
+			},
+		}))
+	}
+}
+
+func TestGetTaskUsageHandler(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	router := DefineRoutes(ctrl, TestAllowedOrigins())
+	workspaceId := "ws_usage"
+	seedUsageTask(t, ctrl, workspaceId, "task_1", 0.5, 0.25)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/"+workspaceId+"/tasks/task_1/usage", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Usage domain.TaskLlmUsage `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Equal(t, "task_1", resp.Usage.TaskId)
+	assert.Equal(t, 2, resp.Usage.Usage.Calls)
+	assert.Equal(t, 2000, resp.Usage.Usage.InputTokens)
+	assert.InDelta(t, 0.75, resp.Usage.Usage.Cost, 1e-9)
+	require.Len(t, resp.Usage.Flows, 1)
+	require.Len(t, resp.Usage.Flows[0].Subflows, 1)
+	assert.Equal(t, "Coding", resp.Usage.Flows[0].Subflows[0].Name)
+	assert.InDelta(t, 0.75, resp.Usage.Flows[0].Subflows[0].Usage.Cost, 1e-9)
+}
+
+func TestGetTaskUsageHandler_NotFound(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	router := DefineRoutes(ctrl, TestAllowedOrigins())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/ws_usage/tasks/missing/usage", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetFlowUsageHandler(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	router := DefineRoutes(ctrl, TestAllowedOrigins())
+	workspaceId := "ws_usage"
+	seedUsageTask(t, ctrl, workspaceId, "task_1", 0.5)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/"+workspaceId+"/flows/flow_task_1/usage", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Usage domain.FlowLlmUsage `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "flow_task_1", resp.Usage.FlowId)
+	assert.Equal(t, 1, resp.Usage.Usage.Calls)
+	assert.InDelta(t, 0.5, resp.Usage.Usage.Cost, 1e-9)
+}
+
+func TestGetWorkspaceUsageHandler(t *testing.T) {
+	t.Parallel()
+	ctrl := NewMockController(t)
+	router := DefineRoutes(ctrl, TestAllowedOrigins())
+	workspaceId := "ws_usage"
+	seedUsageTask(t, ctrl, workspaceId, "task_cheap", 0.1)
+	seedUsageTask(t, ctrl, workspaceId, "task_expensive", 1, 2)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/"+workspaceId+"/usage", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Usage domain.WorkspaceLlmUsage `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Usage.Usage.Calls)
+	assert.InDelta(t, 3.1, resp.Usage.Usage.Cost, 1e-9)
+	require.Len(t, resp.Usage.Tasks, 2)
+	assert.Equal(t, "task_expensive", resp.Usage.Tasks[0].TaskId)
+	assert.Nil(t, resp.Usage.Tasks[0].Flows)
+
+	t.Run("since filters out older tasks", func(t *testing.T) {
+		since := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/"+workspaceId+"/usage?since="+since, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Usage domain.WorkspaceLlmUsage `json:"usage"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Empty(t, resp.Usage.Tasks)
+	})
+
+	t.Run("invalid since", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/"+workspaceId+"/usage?since=yesterday", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
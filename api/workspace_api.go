@@ -161,6 +161,10 @@ func (ctrl *Controller) CreateWorkspaceHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ConfigMode must be one of: 'local', 'workspace', 'merge'"})
 		return
 	}
+	if err := workspaceReq.EmbeddingConfig.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	workspace := domain.Workspace{
 		Id:           "ws_" + ksuid.New().String(),
@@ -181,7 +185,8 @@ func (ctrl *Controller) CreateWorkspaceHandler(c *gin.Context) {
 			Defaults: workspaceReq.LLMConfig.Defaults,
 		},
 		Embedding: common.EmbeddingConfig{
-			Defaults: workspaceReq.EmbeddingConfig.Defaults,
+			Defaults:  workspaceReq.EmbeddingConfig.Defaults,
+			Retrieval: workspaceReq.EmbeddingConfig.Retrieval,
 		},
 	}
 
@@ -316,6 +321,10 @@ func (ctrl *Controller) UpdateWorkspaceHandler(c *gin.Context) {
 		ctrl.ErrorHandler(c, http.StatusBadRequest, errors.New("ConfigMode must be one of: 'local', 'workspace', 'merge'"))
 		return
 	}
+	if err := workspaceReq.EmbeddingConfig.Validate(); err != nil {
+		ctrl.ErrorHandler(c, http.StatusBadRequest, err)
+		return
+	}
 
 	workspaceConfig, err := ctrl.service.GetWorkspaceConfig(c, workspaceId)
 	if err != nil {
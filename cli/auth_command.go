@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sidekick/common"
 	"sidekick/llm"
 	"strings"
 	"time"
@@ -60,7 +61,7 @@ func NewAuthCommand() *cli.Command {
 }
 
 func handleAuthCommand() error {
-	providerSelection := selection.New("Select your LLM API provider", []string{"OpenAI", "Google", "Anthropic"})
+	providerSelection := selection.New("Select your LLM API provider", []string{"OpenAI", "Google", "Anthropic", "AWS Bedrock", "Azure OpenAI"})
 	provider, err := providerSelection.RunPrompt()
 	if err != nil {
 		return fmt.Errorf("provider selection failed: %w", err)
@@ -73,6 +74,10 @@ func handleAuthCommand() error {
 		return handleGoogleAuth()
 	case "Anthropic":
 		return handleAnthropicAuth()
+	case "AWS Bedrock":
+		return handleBedrockAuth()
+	case "Azure OpenAI":
+		return handleAzureOpenAIAuth()
 	default:
 		return fmt.Errorf("unknown provider: %s", provider)
 	}
@@ -109,6 +114,129 @@ func handleAnthropicAuth() error {
 	}
 }
 
+func handleBedrockAuth() error {
+	secretPrefix, err := promptProviderSecretPrefix(string(common.BedrockChatProvider))
+	if err != nil {
+		return err
+	}
+
+	methodSelection := selection.New("Select authentication method", []string{
+		"Bedrock API key",
+		"AWS access keys",
+		"Default AWS credentials (environment, profile or SSO)",
+	})
+	method, err := methodSelection.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("authentication method selection failed: %w", err)
+	}
+
+	switch method {
+	case "Bedrock API key":
+		return handleManualAPIKeyAuth("Bedrock", secretPrefix+"_API_KEY")
+	case "AWS access keys":
+		return promptAndStoreSecrets("AWS access keys", []secretPrompt{
+			{label: "AWS access key ID", secretName: secretPrefix + "_AWS_ACCESS_KEY_ID"},
+			{label: "AWS secret access key", secretName: secretPrefix + "_AWS_SECRET_ACCESS_KEY", hidden: true},
+			{label: "AWS session token (optional)", secretName: secretPrefix + "_AWS_SESSION_TOKEN", hidden: true, optional: true},
+		})
+	case "Default AWS credentials (environment, profile or SSO)":
+		fmt.Println("✔ Bedrock requests will use the default AWS credential chain. Make sure the region is set in the provider config or AWS_REGION.")
+		return nil
+	default:
+		return fmt.Errorf("unknown authentication method: %s", method)
+	}
+}
+
+func handleAzureOpenAIAuth() error {
+	secretPrefix, err := promptProviderSecretPrefix(string(common.AzureOpenaiChatProvider))
+	if err != nil {
+		return err
+	}
+
+	methodSelection := selection.New("Select authentication method", []string{
+		"API key",
+		"Microsoft Entra ID service principal",
+	})
+	method, err := methodSelection.RunPrompt()
+	if err != nil {
+		return fmt.Errorf("authentication method selection failed: %w", err)
+	}
+
+	switch method {
+	case "API key":
+		return handleManualAPIKeyAuth("Azure OpenAI", secretPrefix+"_API_KEY")
+	case "Microsoft Entra ID service principal":
+		return promptAndStoreSecrets("Entra ID service principal", []secretPrompt{
+			{label: "Tenant ID", secretName: secretPrefix + "_AZURE_TENANT_ID"},
+			{label: "Client ID", secretName: secretPrefix + "_AZURE_CLIENT_ID"},
+			{label: "Client secret", secretName: secretPrefix + "_AZURE_CLIENT_SECRET", hidden: true},
+		})
+	default:
+		return fmt.Errorf("unknown authentication method: %s", method)
+	}
+}
+
+// promptProviderSecretPrefix asks for the name of the provider in the config,
+// since secrets for bedrock and azure_openai providers are looked up by
+// provider name rather than type
+func promptProviderSecretPrefix(defaultName string) (string, error) {
+	nameInput := textinput.New("Provider name, as configured in providers: ")
+	nameInput.InitialValue = defaultName
+	name, err := nameInput.RunPrompt()
+	if err != nil {
+		return "", fmt.Errorf("failed to get provider name: %w", err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("provider name not provided")
+	}
+	return common.ModelConfig{Provider: name}.NormalizedProviderName(), nil
+}
+
+type secretPrompt struct {
+	label      string
+	secretName string
+	hidden     bool
+	optional   bool
+}
+
+func promptAndStoreSecrets(description string, prompts []secretPrompt) error {
+	values := make([]string, len(prompts))
+	for i, prompt := range prompts {
+		input := textinput.New(fmt.Sprintf("Enter your %s: ", prompt.label))
+		input.Hidden = prompt.hidden
+		if prompt.optional {
+			input.Validate = nil
+		}
+		value, err := input.RunPrompt()
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", prompt.label, err)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" && !prompt.optional {
+			return fmt.Errorf("%s not provided", prompt.label)
+		}
+		values[i] = value
+	}
+
+	for i, prompt := range prompts {
+		if values[i] == "" {
+			// clear any previously stored optional value so it isn't mixed
+			// with the new credentials
+			if err := keyring.Delete(keyringService, prompt.secretName); err != nil && err != keyring.ErrNotFound {
+				return fmt.Errorf("error removing %s from keyring: %w", prompt.label, err)
+			}
+			continue
+		}
+		if err := keyring.Set(keyringService, prompt.secretName, values[i]); err != nil {
+			return fmt.Errorf("error storing %s in keyring: %w", prompt.label, err)
+		}
+	}
+
+	fmt.Printf("✔ %s saved.\n", description)
+	return nil
+}
+
 func handleAnthropicOAuthSubscription() error {
 	existingCreds, err := keyring.Get(keyringService, AnthropicOAuthSecretName)
 	if err != nil && err != keyring.ErrNotFound {
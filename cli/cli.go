@@ -153,6 +153,8 @@ func setupAndRunInteractiveCli(args []string) error {
 			},
 			NewTaskCommand(),
 			NewAuthCommand(),
+			NewUsageCommand(),
+			NewMcpCommand(),
 		},
 	}
 	return cliApp.Run(context.Background(), args)
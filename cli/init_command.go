@@ -774,8 +774,10 @@ func selectEmbeddingProvider(localConfig common.LocalConfig) (string, error) {
 	return strings.ToLower(selected), nil
 }
 
+const localEmbeddingOption = "Local (offline, lower quality)"
+
 func selectAndAuthEmbeddingProvider() (string, error) {
-	embeddingOptions := []string{"OpenAI", "Google"}
+	embeddingOptions := []string{"OpenAI", "Google", localEmbeddingOption}
 	providerSelection := selection.New("Select embedding provider to configure", embeddingOptions)
 	selected, err := providerSelection.RunPrompt()
 	if err != nil {
@@ -793,6 +795,9 @@ func selectAndAuthEmbeddingProvider() (string, error) {
 			return "", err
 		}
 		return "google", nil
+	case localEmbeddingOption:
+		// runs in-process, so there are no credentials to set up
+		return common.LocalEmbeddingProvider, nil
 	}
 
 	return "", fmt.Errorf("unknown provider selected: %s", selected)
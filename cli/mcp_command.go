@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"sidekick/client"
+	"sidekick/coding/git"
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/mcp"
+
+	"github.com/urfave/cli/v3"
+)
+
+const mcpServerInstructions = `Sidekick runs long-running software engineering tasks in the current repository.
+Create a task with create_task, then poll get_task to follow its progress. Tasks
+may ask for human input or approval: get_task lists these pending requests,
+which can be answered with respond_to_request. Use list_flow_actions and
+get_subflow_results to see what a task's flow has done in detail.`
+
+// maxActionResultLength limits the length of flow action results returned
+// when listing flow actions, as results can be very long
+const maxActionResultLength = 2000
+
+func NewMcpCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "mcp",
+		Usage: "Serve the Sidekick API over MCP stdio, so AI assistants can hand off tasks to Sidekick",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			currentDir, err := os.Getwd()
+			if err != nil {
+				return cli.Exit(fmt.Errorf("Error getting current working directory: %w", err), 1)
+			}
+			c := client.NewClient(fmt.Sprintf("http://localhost:%d", common.GetServerPort()))
+			server := newSidekickMcpServer(c, currentDir)
+			if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil {
+				return cli.Exit(err, 1)
+			}
+			return nil
+		},
+	}
+}
+
+// sidekickMcpTools implements the tools of the Sidekick MCP server, for the
+// workspace of the directory the server was started in.
+type sidekickMcpTools struct {
+	client client.Client
+	dir    string
+
+	mu        sync.Mutex
+	workspace *domain.Workspace
+}
+
+func newSidekickMcpServer(c client.Client, dir string) *mcp.Server {
+	tools := &sidekickMcpTools{client: c, dir: dir}
+	server := mcp.NewServer(mcp.Implementation{Name: "sidekick", Version: version}, mcpServerInstructions)
+
+	server.AddTool(mcp.Tool{
+		Name:        "create_task",
+		Description: "Creates a Sidekick task, which starts a flow working on it in the background. Returns the task, including its id.",
+		InputSchema: mcp.InputSchema(&mcpCreateTaskArgs{}),
+	}, withArgs(tools.createTask))
+	server.AddTool(mcp.Tool{
+		Name:        "get_task",
+		Description: "Gets the status of a task, its flows and any requests for human input or approval that are pending.",
+		InputSchema: mcp.InputSchema(&mcpGetTaskArgs{}),
+	}, withArgs(tools.getTask))
+	server.AddTool(mcp.Tool{
+		Name:        "list_flow_actions",
+		Description: "Lists the actions taken by a flow, e.g. LLM calls, tool calls and requests for human input, in the order they were started.",
+		InputSchema: mcp.InputSchema(&mcpListFlowActionsArgs{}),
+	}, withArgs(tools.listFlowActions))
+	server.AddTool(mcp.Tool{
+		Name:        "respond_to_request",
+		Description: "Responds to a pending request for human input or approval, given the id of its flow action.",
+		InputSchema: mcp.InputSchema(&mcpRespondToRequestArgs{}),
+	}, withArgs(tools.respondToRequest))
+	server.AddTool(mcp.Tool{
+		Name:        "get_subflow_results",
+		Description: "Gets the status and results of a flow's subflows, i.e. its steps, or of a single subflow.",
+		InputSchema: mcp.InputSchema(&mcpGetSubflowResultsArgs{}),
+	}, withArgs(tools.getSubflowResults))
+
+	return server
+}
+
+// withArgs adapts a tool implementation taking typed arguments to a handler
+func withArgs[T any](fn func(ctx context.Context, args T) (any, error)) mcp.ToolHandler {
+	return func(ctx context.Context, arguments json.RawMessage) (mcp.CallToolResult, error) {
+		var args T
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return mcp.CallToolResult{}, fmt.Errorf("invalid arguments: %w", err)
+		}
+		result, err := fn(ctx, args)
+		if err != nil {
+			return mcp.CallToolResult{}, err
+		}
+		return mcp.JSONResult(result)
+	}
+}
+
+// getWorkspace finds the workspace lazily, so that the MCP server can start
+// before the Sidekick server does
+func (t *sidekickMcpTools) getWorkspace(ctx context.Context) (domain.Workspace, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.workspace != nil {
+		return *t.workspace, nil
+	}
+	workspace, err := findWorkspaceForDir(ctx, t.client, t.dir)
+	if err != nil {
+		return domain.Workspace{}, fmt.Errorf("%w (is the Sidekick server running? start it with `side start`)", err)
+	}
+	t.workspace = &workspace
+	return workspace, nil
+}
+
+type mcpCreateTaskArgs struct {
+	Description string                 `json:"description" jsonschema:"description=What the task should accomplish in as much detail as available"`
+	Title       string                 `json:"title,omitempty" jsonschema:"description=Short title of the task. Generated from the description if omitted"`
+	FlowType    string                 `json:"flowType,omitempty" jsonschema:"description=The flow type to run: basic_dev (the default) or planned_dev for larger tasks"`
+	FlowOptions map[string]interface{} `json:"flowOptions,omitempty" jsonschema:"description=Options of the flow type such as {\"determineRequirements\": false}"`
+	Worktree    bool                   `json:"worktree,omitempty" jsonschema:"description=Work in a separate git worktree starting from the current branch unless startBranch is given"`
+	StartBranch string                 `json:"startBranch,omitempty" jsonschema:"description=The branch the worktree starts from. Implies worktree"`
+	Priority    int                    `json:"priority,omitempty" jsonschema:"description=Priority of the task when queued. Higher priority tasks start first"`
+	BlockedBy   []string               `json:"blockedBy,omitempty" jsonschema:"description=Ids of tasks that must complete before this task starts"`
+}
+
+func (t *sidekickMcpTools) createTask(ctx context.Context, args mcpCreateTaskArgs) (any, error) {
+	if args.Description == "" {
+		return nil, fmt.Errorf("description is required")
+	}
+	workspace, err := t.getWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	flowType := args.FlowType
+	if flowType == "" {
+		flowType = string(domain.FlowTypeBasicDev)
+	}
+	flowOptions := map[string]interface{}{"determineRequirements": true}
+	for key, value := range args.FlowOptions {
+		flowOptions[key] = value
+	}
+	if args.Worktree || args.StartBranch != "" {
+		flowOptions["envType"] = "local_git_worktree"
+		startBranch := args.StartBranch
+		if startBranch == "" {
+			branchState, err := git.GetCurrentBranch(ctx, t.dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to detect current git branch for worktree: %w", err)
+			}
+			if branchState.IsDetached {
+				return nil, fmt.Errorf("cannot use worktree with detached HEAD state, please specify startBranch")
+			}
+			startBranch = branchState.Name
+		}
+		flowOptions["startBranch"] = startBranch
+	}
+
+	req := &client.CreateTaskRequest{
+		Title:       args.Title,
+		Description: args.Description,
+		FlowType:    flowType,
+		FlowOptions: flowOptions,
+		Priority:    args.Priority,
+	}
+	for _, blockerId := range args.BlockedBy {
+		req.Links = append(req.Links, domain.TaskLink{LinkType: string(domain.LinkTypeBlockedBy), TargetTaskId: blockerId})
+	}
+
+	task, err := t.client.CreateTask(workspace.Id, req)
+	if err != nil {
+		return nil, err
+	}
+	return newMcpTaskStatus(task, nil), nil
+}
+
+type mcpGetTaskArgs struct {
+	TaskId string `json:"taskId" jsonschema:"description=The id of the task"`
+}
+
+// mcpTaskStatus summarizes a task and its flows for MCP clients
+type mcpTaskStatus struct {
+	Id              string              `json:"id"`
+	Title           string              `json:"title"`
+	Status          domain.TaskStatus   `json:"status"`
+	AgentType       domain.AgentType    `json:"agentType"`
+	QueuePosition   int                 `json:"queuePosition,omitempty"`
+	Links           []domain.TaskLink   `json:"links,omitempty"`
+	Flows           []domain.Flow       `json:"flows"`
+	PendingRequests []mcpFlowActionInfo `json:"pendingRequests,omitempty"`
+}
+
+func newMcpTaskStatus(task client.Task, pendingRequests []mcpFlowActionInfo) mcpTaskStatus {
+	flows := task.Flows
+	if flows == nil {
+		flows = []domain.Flow{}
+	}
+	return mcpTaskStatus{
+		Id:              task.Id,
+		Title:           task.Title,
+		Status:          task.Status,
+		AgentType:       task.AgentType,
+		QueuePosition:   task.QueuePosition,
+		Links:           task.Links,
+		Flows:           flows,
+		PendingRequests: pendingRequests,
+	}
+}
+
+func (t *sidekickMcpTools) getTask(ctx context.Context, args mcpGetTaskArgs) (any, error) {
+	workspace, err := t.getWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	task, err := t.client.GetTask(workspace.Id, args.TaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pendingRequests []mcpFlowActionInfo
+	for _, flow := range task.Flows {
+		actions, err := t.client.GetFlowActions(workspace.Id, flow.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get actions of flow %s: %w", flow.Id, err)
+		}
+		for _, action := range actions {
+			if isPendingRequest(action) {
+				pendingRequests = append(pendingRequests, newMcpFlowActionInfo(action))
+			}
+		}
+	}
+	return newMcpTaskStatus(task, pendingRequests), nil
+}
+
+type mcpListFlowActionsArgs struct {
+	FlowId      string `json:"flowId" jsonschema:"description=The id of the flow as listed in the task's flows"`
+	PendingOnly bool   `json:"pendingOnly,omitempty" jsonschema:"description=Only list pending requests for human input or approval"`
+}
+
+// mcpFlowActionInfo summarizes a flow action for MCP clients. Params are only
+// included for human actions, as they describe the request.
+type mcpFlowActionInfo struct {
+	Id            string                 `json:"id"`
+	SubflowId     string                 `json:"subflowId,omitempty"`
+	ActionType    string                 `json:"actionType"`
+	ActionStatus  domain.ActionStatus    `json:"actionStatus"`
+	IsHumanAction bool                   `json:"isHumanAction,omitempty"`
+	ActionParams  map[string]interface{} `json:"actionParams,omitempty"`
+	ActionResult  string                 `json:"actionResult,omitempty"`
+	Created       time.Time              `json:"created"`
+}
+
+func newMcpFlowActionInfo(action client.FlowAction) mcpFlowActionInfo {
+	info := mcpFlowActionInfo{
+		Id:            action.Id,
+		SubflowId:     action.SubflowId,
+		ActionType:    action.ActionType,
+		ActionStatus:  action.ActionStatus,
+		IsHumanAction: action.IsHumanAction,
+		ActionResult:  action.ActionResult,
+		Created:       action.Created,
+	}
+	if action.IsHumanAction {
+		info.ActionParams = action.ActionParams
+	}
+	if len(info.ActionResult) > maxActionResultLength {
+		info.ActionResult = info.ActionResult[:maxActionResultLength] + "\n... (truncated)"
+	}
+	return info
+}
+
+func isPendingRequest(action client.FlowAction) bool {
+	return action.IsHumanAction && action.ActionStatus == domain.ActionStatusPending
+}
+
+func (t *sidekickMcpTools) listFlowActions(ctx context.Context, args mcpListFlowActionsArgs) (any, error) {
+	workspace, err := t.getWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := t.client.GetFlowActions(workspace.Id, args.FlowId)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Created.Before(actions[j].Created)
+	})
+
+	infos := []mcpFlowActionInfo{}
+	for _, action := range actions {
+		if args.PendingOnly && !isPendingRequest(action) {
+			continue
+		}
+		infos = append(infos, newMcpFlowActionInfo(action))
+	}
+	return map[string]any{"flowActions": infos}, nil
+}
+
+type mcpRespondToRequestArgs struct {
+	FlowActionId string                 `json:"flowActionId" jsonschema:"description=The id of the pending request's flow action"`
+	Content      string                 `json:"content,omitempty" jsonschema:"description=The response to the request or the reason for rejecting an approval request"`
+	Approved     *bool                  `json:"approved,omitempty" jsonschema:"description=Whether to approve the request. Only for approval requests"`
+	Choice       string                 `json:"choice,omitempty" jsonschema:"description=The selected option. Only for multiple choice requests"`
+	Params       map[string]interface{} `json:"params,omitempty" jsonschema:"description=Additional response parameters such as targetBranch for merge approvals"`
+}
+
+func (t *sidekickMcpTools) respondToRequest(ctx context.Context, args mcpRespondToRequestArgs) (any, error) {
+	workspace, err := t.getWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = t.client.CompleteFlowAction(workspace.Id, args.FlowActionId, client.UserResponse{
+		Content:  args.Content,
+		Approved: args.Approved,
+		Choice:   args.Choice,
+		Params:   args.Params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"flowActionId": args.FlowActionId, "status": "responded"}, nil
+}
+
+type mcpGetSubflowResultsArgs struct {
+	FlowId    string `json:"flowId" jsonschema:"description=The id of the flow"`
+	SubflowId string `json:"subflowId,omitempty" jsonschema:"description=The id of a single subflow to get"`
+}
+
+func (t *sidekickMcpTools) getSubflowResults(ctx context.Context, args mcpGetSubflowResultsArgs) (any, error) {
+	workspace, err := t.getWorkspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if args.SubflowId != "" {
+		subflow, err := t.client.GetSubflow(workspace.Id, args.SubflowId)
+		if err != nil {
+			return nil, err
+		}
+		return subflow, nil
+	}
+
+	subflows, err := t.client.GetSubflows(workspace.Id, args.FlowId)
+	if err != nil {
+		return nil, err
+	}
+	if subflows == nil {
+		subflows = []domain.Subflow{}
+	}
+	return map[string]any{"subflows": subflows}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sidekick/client"
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMcpFlowActionInfo(t *testing.T) {
+	humanAction := client.FlowAction{
+		Id:            "fa_1",
+		ActionType:    "user_request.approve",
+		ActionStatus:  domain.ActionStatusPending,
+		ActionParams:  map[string]interface{}{"requestContent": "Approve the plan?"},
+		IsHumanAction: true,
+	}
+	info := newMcpFlowActionInfo(humanAction)
+	assert.Equal(t, "fa_1", info.Id)
+	assert.Equal(t, "Approve the plan?", info.ActionParams["requestContent"])
+	assert.True(t, isPendingRequest(humanAction))
+
+	llmAction := client.FlowAction{
+		Id:           "fa_2",
+		ActionType:   "generate.code",
+		ActionStatus: domain.ActionStatusComplete,
+		ActionParams: map[string]interface{}{"messages": "..."},
+		ActionResult: strings.Repeat("x", maxActionResultLength+10),
+	}
+	info = newMcpFlowActionInfo(llmAction)
+	assert.Nil(t, info.ActionParams)
+	assert.True(t, strings.HasPrefix(info.ActionResult, strings.Repeat("x", maxActionResultLength)))
+	assert.True(t, strings.HasSuffix(info.ActionResult, "(truncated)"))
+	assert.False(t, isPendingRequest(llmAction))
+}
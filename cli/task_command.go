@@ -10,6 +10,7 @@ import (
 	"sidekick/client"
 	"sidekick/coding/git"
 	"sidekick/common"
+	"sidekick/domain"
 	"sidekick/tui"
 
 	"github.com/urfave/cli/v3"
@@ -23,13 +24,17 @@ func NewTaskCommand() *cli.Command {
 		Flags: []cli.Flag{
 			&cli.BoolFlag{Name: "disable-human-in-the-loop", Usage: "Disable human-in-the-loop prompts"},
 			&cli.BoolFlag{Name: "async", Usage: "Run task asynchronously and exit immediately"},
-			&cli.StringFlag{Name: "flow", Value: "basic_dev", Usage: "Specify flow type (e.g., basic_dev, planned_dev)"},
+			&cli.StringFlag{Name: "flow", Value: "basic_dev", Usage: "Specify flow type (e.g., basic_dev, planned_dev, or any other registered flow type)"},
 			&cli.BoolFlag{Name: "plan", Aliases: []string{"p"}, Usage: "Shorthand for --flow planned_dev"},
 			&cli.StringFlag{Name: "flow-options", Value: `{"determineRequirements": true}`, Usage: "JSON string for flow options"},
 			&cli.StringSliceFlag{Name: "flow-option", Aliases: []string{"O"}, Usage: "Add flow option (key=value), can be specified multiple times"},
 			&cli.BoolFlag{Name: "no-requirements", Aliases: []string{"n"}, Usage: "Shorthand to set determineRequirements to false in flow options"},
 			&cli.BoolFlag{Name: "worktree", Aliases: []string{"w"}, Usage: "Use a git worktree. Sets --start-branch to the current branch if not specified."},
 			&cli.StringFlag{Name: "start-branch", Aliases: []string{"B"}, Usage: "The worktree start branch. Implies --worktree"},
+			&cli.BoolFlag{Name: "container", Usage: "Use a git worktree mounted into a docker/podman container configured via container.image in the repo config. Sets --start-branch like --worktree."},
+			&cli.BoolFlag{Name: "devpod", Usage: "Use a git worktree provisioned as a DevPod workspace, configured via devpod in the repo config. Sets --start-branch like --worktree."},
+			&cli.StringSliceFlag{Name: "blocked-by", Usage: "Id of a task that must complete before this task starts, can be specified multiple times. Best combined with --async"},
+			&cli.IntFlag{Name: "priority", Usage: "Priority of the task when queued due to concurrency limits, higher priority tasks start first"},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			c := client.NewClient(fmt.Sprintf("http://localhost:%d", common.GetServerPort()))
@@ -81,8 +86,14 @@ func parseFlowOptions(ctx context.Context, cmd *cli.Command, currentDir string)
 		flowOpts[key] = valueStr
 	}
 
-	// --worktree flag or --start-branch flag implies worktree environment
-	if cmd.Bool("worktree") || cmd.String("start-branch") != "" {
+	// --container and --devpod use a worktree mounted into a container or
+	// DevPod workspace, otherwise the --worktree flag or --start-branch flag
+	// implies worktree environment
+	if cmd.Bool("container") {
+		flowOpts["envType"] = "container"
+	} else if cmd.Bool("devpod") {
+		flowOpts["envType"] = "devpod"
+	} else if cmd.Bool("worktree") || cmd.String("start-branch") != "" {
 		flowOpts["envType"] = "local_git_worktree"
 	}
 
@@ -91,8 +102,8 @@ func parseFlowOptions(ctx context.Context, cmd *cli.Command, currentDir string)
 		flowOpts["startBranch"] = startBranch
 	}
 
-	// If envType is local_git_worktree but startBranch is not set, detect current branch
-	if envType, ok := flowOpts["envType"]; ok && envType == "local_git_worktree" {
+	// If the envType uses a worktree but startBranch is not set, detect current branch
+	if envType, ok := flowOpts["envType"]; ok && (envType == "local_git_worktree" || envType == "container" || envType == "devpod") {
 		if _, hasStartBranch := flowOpts["startBranch"]; !hasStartBranch {
 			branchState, err := git.GetCurrentBranch(ctx, currentDir)
 			if err != nil {
@@ -147,6 +158,10 @@ func buildCreateTaskRequest(ctx context.Context, cmd *cli.Command, currentDir st
 		Description: taskDescription,
 		FlowType:    flowType,
 		FlowOptions: flowOpts,
+		Priority:    cmd.Int("priority"),
+	}
+	for _, blockerId := range cmd.StringSlice("blocked-by") {
+		req.Links = append(req.Links, domain.TaskLink{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: blockerId})
 	}
 	return req, nil
 }
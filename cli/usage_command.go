@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sidekick/client"
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+func NewUsageCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "usage",
+		Usage: "Show LLM token usage and cost for tasks in the current workspace",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "task", Aliases: []string{"t"}, Usage: "Show a per-flow and per-subflow breakdown for a single task ID"},
+			&cli.StringFlag{Name: "since", Usage: "Only include tasks created since this time, either RFC3339 or a duration such as 24h"},
+			&cli.BoolFlag{Name: "include-archived", Aliases: []string{"a"}, Usage: "Include archived tasks"},
+			&cli.BoolFlag{Name: "json", Usage: "Print usage as JSON"},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			c := client.NewClient(fmt.Sprintf("http://localhost:%d", common.GetServerPort()))
+			return executeUsageCommand(ctx, c, cmd, os.Stdout)
+		},
+	}
+}
+
+func executeUsageCommand(ctx context.Context, c client.Client, cmd *cli.Command, out io.Writer) error {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("Error getting current working directory: %w", err), 1)
+	}
+
+	workspace, err := findWorkspaceForDir(ctx, c, currentDir)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	var usage any
+	if taskId := cmd.String("task"); taskId != "" {
+		taskUsage, err := c.GetTaskUsage(workspace.Id, taskId)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("Error getting task usage: %w", err), 1)
+		}
+		usage = taskUsage
+	} else {
+		since, err := parseSince(cmd.String("since"), time.Now())
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		workspaceUsage, err := c.GetWorkspaceUsage(workspace.Id, since, cmd.Bool("include-archived"))
+		if err != nil {
+			return cli.Exit(fmt.Errorf("Error getting workspace usage: %w", err), 1)
+		}
+		usage = workspaceUsage
+	}
+
+	if cmd.Bool("json") {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(usage)
+	}
+
+	switch u := usage.(type) {
+	case domain.TaskLlmUsage:
+		printTaskUsage(out, u)
+	case domain.WorkspaceLlmUsage:
+		printWorkspaceUsage(out, u)
+	}
+	return nil
+}
+
+// findWorkspaceForDir finds the workspace whose local repo dir matches the
+// given directory or the repository it belongs to.
+func findWorkspaceForDir(ctx context.Context, c client.Client, dir string) (domain.Workspace, error) {
+	repoPaths, err := utils.GetRepositoryPaths(ctx, dir)
+	if err != nil {
+		return domain.Workspace{}, fmt.Errorf("failed to get repository paths: %w", err)
+	}
+
+	workspaces, err := c.GetAllWorkspaces(ctx)
+	if err != nil {
+		return domain.Workspace{}, fmt.Errorf("failed to retrieve workspaces: %w", err)
+	}
+
+	for _, path := range repoPaths {
+		for _, ws := range workspaces {
+			if filepath.Clean(ws.LocalRepoDir) == filepath.Clean(path) {
+				return ws, nil
+			}
+		}
+	}
+	return domain.Workspace{}, fmt.Errorf("no workspace found for %s, run `side task` here first", dir)
+}
+
+// parseSince accepts either an RFC3339 timestamp or a duration relative to now.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q: expected an RFC3339 timestamp or a duration such as 24h", since)
+}
+
+func formatUsageCost(usage common.LlmUsage) string {
+	cost := fmt.Sprintf("$%.4f", usage.Cost)
+	if usage.UnpricedCalls > 0 {
+		cost += fmt.Sprintf(" (+%d unpriced)", usage.UnpricedCalls)
+	}
+	return cost
+}
+
+func printWorkspaceUsage(out io.Writer, usage domain.WorkspaceLlmUsage) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSTATUS\tCALLS\tINPUT\tOUTPUT\tCOST\tTITLE")
+	for _, task := range usage.Tasks {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n", task.TaskId, task.Status, task.Usage.Calls, task.Usage.InputTokens, task.Usage.OutputTokens, formatUsageCost(task.Usage), task.Title)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%s\t\n", usage.Usage.Calls, usage.Usage.InputTokens, usage.Usage.OutputTokens, formatUsageCost(usage.Usage))
+	w.Flush()
+}
+
+func printTaskUsage(out io.Writer, usage domain.TaskLlmUsage) {
+	fmt.Fprintf(out, "%s: %s\n\n", usage.TaskId, usage.Title)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCALLS\tINPUT\tOUTPUT\tCACHE READ\tCOST")
+	for _, flow := range usage.Flows {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", flow.FlowId, flow.Usage.Calls, flow.Usage.InputTokens, flow.Usage.OutputTokens, flow.Usage.CacheReadInputTokens, formatUsageCost(flow.Usage))
+
+		depthBySubflowId := make(map[string]int, len(flow.Subflows))
+		for _, subflow := range flow.Subflows {
+			depth := 1
+			if parentDepth, ok := depthBySubflowId[subflow.ParentSubflowId]; ok {
+				depth = parentDepth + 1
+			}
+			depthBySubflowId[subflow.SubflowId] = depth
+			name := strings.Repeat("  ", depth) + subflow.Name
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", name, subflow.Usage.Calls, subflow.Usage.InputTokens, subflow.Usage.OutputTokens, subflow.Usage.CacheReadInputTokens, formatUsageCost(subflow.Usage))
+		}
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%d\t%s\n", usage.Usage.Calls, usage.Usage.InputTokens, usage.Usage.OutputTokens, usage.Usage.CacheReadInputTokens, formatUsageCost(usage.Usage))
+	w.Flush()
+}
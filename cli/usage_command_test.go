@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"sidekick/common"
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("", now)
+	require.NoError(t, err)
+	assert.True(t, since.IsZero())
+
+	since, err = parseSince("2025-06-01T00:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), since)
+
+	since, err = parseSince("24h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), since)
+
+	_, err = parseSince("last week", now)
+	assert.Error(t, err)
+}
+
+func TestPrintWorkspaceUsage(t *testing.T) {
+	var out bytes.Buffer
+	printWorkspaceUsage(&out, domain.WorkspaceLlmUsage{
+		Usage: common.LlmUsage{Calls: 3, InputTokens: 300, OutputTokens: 30, Cost: 1.5, UnpricedCalls: 1},
+		Tasks: []domain.TaskLlmUsage{
+			{TaskId: "task_1", Title: "Fix tests", Status: domain.TaskStatusComplete, Usage: common.LlmUsage{Calls: 3, InputTokens: 300, OutputTokens: 30, Cost: 1.5, UnpricedCalls: 1}},
+		},
+	})
+
+	assert.Contains(t, out.String(), "task_1")
+	assert.Contains(t, out.String(), "Fix tests")
+	assert.Contains(t, out.String(), "$1.5000 (+1 unpriced)")
+	assert.Contains(t, out.String(), "TOTAL")
+}
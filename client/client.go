@@ -20,7 +20,11 @@ type Client interface {
 	CompleteFlowAction(workspaceID, flowActionID string, response UserResponse) error
 	SendUserAction(workspaceID, flowID, actionType string) error
 	GetSubflow(workspaceID, subflowID string) (domain.Subflow, error)
+	GetSubflows(workspaceID, flowID string) ([]domain.Subflow, error)
+	GetFlowActions(workspaceID, flowID string) ([]FlowAction, error)
 	QueryFlow(workspaceID, flowID, query string, args any) (any, error)
+	GetTaskUsage(workspaceID, taskID string) (domain.TaskLlmUsage, error)
+	GetWorkspaceUsage(workspaceID string, since time.Time, includeArchived bool) (domain.WorkspaceLlmUsage, error)
 }
 
 type clientImpl struct {
@@ -68,6 +72,17 @@ func (c *clientImpl) GetSubflow(workspaceID, subflowID string) (domain.Subflow,
 	return response.Subflow, nil
 }
 
+func (c *clientImpl) GetSubflows(workspaceID, flowID string) ([]domain.Subflow, error) {
+	var response struct {
+		Subflows []domain.Subflow `json:"subflows"`
+	}
+	err := c.get(context.Background(), fmt.Sprintf("/api/v1/workspaces/%s/flows/%s/subflows", workspaceID, flowID), &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Subflows, nil
+}
+
 // NewClient creates a new Sidekick API client.
 func NewClient(baseURL string) Client {
 	return &clientImpl{
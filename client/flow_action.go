@@ -2,10 +2,12 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sidekick/common"
 	"sidekick/domain"
 	"time"
 )
@@ -25,6 +27,7 @@ type FlowAction struct {
 	ActionResult     string                 `json:"actionResult"`
 	IsHumanAction    bool                   `json:"isHumanAction"`
 	IsCallbackAction bool                   `json:"isCallbackAction"`
+	LlmUsage         *common.LlmUsage       `json:"llmUsage,omitempty"`
 }
 
 // UserResponse represents the user's response to a flow action that requires human input.
@@ -35,6 +38,18 @@ type UserResponse struct {
 	Params   map[string]interface{} `json:"params,omitempty"`
 }
 
+// GetFlowActions fetches all flow actions of the given flow.
+func (c *clientImpl) GetFlowActions(workspaceID, flowID string) ([]FlowAction, error) {
+	var response struct {
+		FlowActions []FlowAction `json:"flowActions"`
+	}
+	err := c.get(context.Background(), fmt.Sprintf("/api/v1/workspaces/%s/flows/%s/actions", workspaceID, flowID), &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.FlowActions, nil
+}
+
 type completeFlowActionRequest struct {
 	UserResponse UserResponse `json:"userResponse"`
 }
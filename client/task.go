@@ -15,6 +15,8 @@ type CreateTaskRequest struct {
 	Description string                 `json:"description"`
 	FlowType    string                 `json:"flowType"`
 	FlowOptions map[string]interface{} `json:"flowOptions"`
+	Links       []domain.TaskLink      `json:"links,omitempty"`
+	Priority    int                    `json:"priority,omitempty"`
 }
 
 // CreateTaskResponse is the response from the CreateTask API.
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sidekick/domain"
+	"time"
+)
+
+// GetTaskUsage fetches the LLM token usage and cost of a task, broken down by
+// flow and subflow.
+func (c *clientImpl) GetTaskUsage(workspaceID, taskID string) (domain.TaskLlmUsage, error) {
+	var response struct {
+		Usage domain.TaskLlmUsage `json:"usage"`
+	}
+	err := c.get(context.Background(), fmt.Sprintf("/api/v1/workspaces/%s/tasks/%s/usage", workspaceID, taskID), &response)
+	if err != nil {
+		return domain.TaskLlmUsage{}, err
+	}
+	return response.Usage, nil
+}
+
+// GetWorkspaceUsage fetches the LLM token usage and cost of all tasks in a
+// workspace. A zero since includes tasks regardless of when they were created.
+func (c *clientImpl) GetWorkspaceUsage(workspaceID string, since time.Time, includeArchived bool) (domain.WorkspaceLlmUsage, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	if includeArchived {
+		query.Set("includeArchived", "true")
+	}
+	path := fmt.Sprintf("/api/v1/workspaces/%s/usage", workspaceID)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var response struct {
+		Usage domain.WorkspaceLlmUsage `json:"usage"`
+	}
+	if err := c.get(context.Background(), path, &response); err != nil {
+		return domain.WorkspaceLlmUsage{}, err
+	}
+	return response.Usage, nil
+}
@@ -0,0 +1,313 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sidekick/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider is a git hosting service with a pull request API
+type Provider = string
+
+const (
+	ProviderGitHub Provider = "github"
+	ProviderGitLab Provider = "gitlab"
+	ProviderGitea  Provider = "gitea"
+)
+
+// Secret names of the access tokens used with each provider's API and to push
+// branches over https. The GitHub token is the same one used to clone repos.
+const (
+	GithubAccessTokenSecretName = "GITHUB_ACCESS_TOKEN"
+	GitlabAccessTokenSecretName = "GITLAB_ACCESS_TOKEN"
+	GiteaAccessTokenSecretName  = "GITEA_ACCESS_TOKEN"
+)
+
+// Repository identifies a repository on a hosting service
+type Repository struct {
+	Provider Provider `json:"provider"`
+	// ApiURL is the base URL of the provider's REST API, without a trailing
+	// slash, eg "https://api.github.com"
+	ApiURL string `json:"apiUrl"`
+	// Owner is the user or organization owning the repository, or for GitLab,
+	// the full path of its group, eg "group/subgroup"
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// FullName is the repository's path on the hosting service, eg "owner/name"
+func (r Repository) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// TokenSecretName is the name of the secret holding the provider's access
+// token
+func (r Repository) TokenSecretName() string {
+	switch r.Provider {
+	case ProviderGitLab:
+		return GitlabAccessTokenSecretName
+	case ProviderGitea:
+		return GiteaAccessTokenSecretName
+	default:
+		return GithubAccessTokenSecretName
+	}
+}
+
+// PullRequest is a pull request, or for GitLab, a merge request
+type PullRequest struct {
+	Number       int    `json:"number"`
+	URL          string `json:"url"`
+	Title        string `json:"title"`
+	SourceBranch string `json:"sourceBranch"`
+	TargetBranch string `json:"targetBranch"`
+}
+
+// NewPullRequest describes a pull request to open
+type NewPullRequest struct {
+	SourceBranch string
+	TargetBranch string
+	Title        string
+	Body         string
+}
+
+// ReviewThread is a conversation started by a review comment on a line of a
+// pull request's diff
+type ReviewThread struct {
+	// Id identifies the thread when replying: the id of its first comment on
+	// GitHub and Gitea, or of its discussion on GitLab
+	Id   string `json:"id"`
+	Path string `json:"path"`
+	// Line is the commented line in the file as of CommitSha, or 0 if the
+	// comment isn't on a line of the new version of the file
+	Line      int             `json:"line"`
+	CommitSha string          `json:"commitSha"`
+	Resolved  bool            `json:"resolved"`
+	Comments  []ReviewComment `json:"comments"`
+}
+
+type ReviewComment struct {
+	Id     string `json:"id"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// replyMarkerPrefix starts the hidden marker appended to replies, which tells
+// which threads were already replied to
+const replyMarkerPrefix = "<!-- sidekick-reply:"
+
+var replyMarkerRegex = regexp.MustCompile(regexp.QuoteMeta(replyMarkerPrefix) + `(\S+) -->`)
+
+func markReply(threadId, body string) string {
+	return body + "\n\n" + replyMarkerPrefix + threadId + " -->"
+}
+
+// replyThreadId returns the id of the thread a marked reply is for, or an
+// empty string if the comment isn't a marked reply
+func replyThreadId(body string) string {
+	if matches := replyMarkerRegex.FindStringSubmatch(body); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// NeedsAddressing reports whether the thread is unresolved and has comments
+// since the last reply from Sidekick
+func (t ReviewThread) NeedsAddressing() bool {
+	if t.Resolved || len(t.Comments) == 0 {
+		return false
+	}
+	return !strings.Contains(t.Comments[len(t.Comments)-1].Body, replyMarkerPrefix)
+}
+
+// Client calls a hosting service's pull request API for a single repository
+type Client interface {
+	// FindOpenPullRequest returns the open pull request from the given branch,
+	// or nil if there is none
+	FindOpenPullRequest(ctx context.Context, sourceBranch string) (*PullRequest, error)
+	CreatePullRequest(ctx context.Context, pr NewPullRequest) (PullRequest, error)
+	GetPullRequest(ctx context.Context, number int) (PullRequest, error)
+	// ListReviewThreads returns the threads of line comments on the pull
+	// request, oldest first
+	ListReviewThreads(ctx context.Context, number int) ([]ReviewThread, error)
+	ReplyToReviewThread(ctx context.Context, number int, thread ReviewThread, body string) error
+}
+
+// NewClient returns a client for the repository's provider, authenticating
+// with the given token
+func NewClient(repo Repository, token string) (Client, error) {
+	api := apiClient{baseURL: repo.ApiURL, httpClient: &http.Client{Timeout: 30 * time.Second}}
+	switch repo.Provider {
+	case ProviderGitHub:
+		api.headers = map[string]string{
+			"Authorization":        "Bearer " + token,
+			"Accept":               "application/vnd.github+json",
+			"X-GitHub-Api-Version": "2022-11-28",
+		}
+		return githubClient{api: api, repo: repo}, nil
+	case ProviderGitLab:
+		api.headers = map[string]string{"PRIVATE-TOKEN": token}
+		return gitlabClient{api: api, repo: repo}, nil
+	case ProviderGitea:
+		api.headers = map[string]string{"Authorization": "token " + token}
+		return giteaClient{api: api, repo: repo}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pull request provider %q", repo.Provider)
+	}
+}
+
+// scpLikeURLRegex matches remotes like "git@github.com:owner/name.git"
+var scpLikeURLRegex = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// ParseRemoteURL identifies the repository a git remote URL points to. The
+// provider and API URL are detected from the remote's host, unless configured.
+func ParseRemoteURL(remoteURL string, config common.PullRequestConfig) (Repository, error) {
+	remoteURL = strings.TrimSpace(remoteURL)
+	var scheme, host, apiHost, repoPath string
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil {
+			return Repository{}, fmt.Errorf("invalid remote URL %q: %w", remoteURL, err)
+		}
+		scheme, host, apiHost, repoPath = u.Scheme, u.Hostname(), u.Hostname(), u.Path
+		if scheme == "http" || scheme == "https" {
+			// keep any port, as the API is served alongside git over http
+			apiHost = u.Host
+		}
+	} else if matches := scpLikeURLRegex.FindStringSubmatch(remoteURL); matches != nil {
+		host, apiHost, repoPath = matches[1], matches[1], matches[2]
+	} else {
+		return Repository{}, fmt.Errorf("unsupported remote URL %q", remoteURL)
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	lastSlash := strings.LastIndex(repoPath, "/")
+	if host == "" || lastSlash <= 0 || lastSlash == len(repoPath)-1 {
+		return Repository{}, fmt.Errorf("remote URL %q doesn't point to a repository", remoteURL)
+	}
+	repo := Repository{
+		Provider: config.Provider,
+		ApiURL:   strings.TrimSuffix(config.ApiURL, "/"),
+		Owner:    repoPath[:lastSlash],
+		Name:     repoPath[lastSlash+1:],
+	}
+
+	if repo.Provider == "" {
+		repo.Provider = detectProvider(host)
+		if repo.Provider == "" {
+			return Repository{}, fmt.Errorf("can't tell which hosting service %s is, set pull_request.provider in the repo config", host)
+		}
+	}
+	if repo.ApiURL == "" {
+		if scheme != "http" {
+			scheme = "https"
+		}
+		switch {
+		case repo.Provider == ProviderGitHub && host == "github.com":
+			repo.ApiURL = "https://api.github.com"
+		case repo.Provider == ProviderGitHub:
+			// GitHub Enterprise Server
+			repo.ApiURL = scheme + "://" + apiHost + "/api/v3"
+		case repo.Provider == ProviderGitLab:
+			repo.ApiURL = scheme + "://" + apiHost + "/api/v4"
+		case repo.Provider == ProviderGitea:
+			repo.ApiURL = scheme + "://" + apiHost + "/api/v1"
+		default:
+			return Repository{}, fmt.Errorf("unsupported pull request provider %q", repo.Provider)
+		}
+	}
+	return repo, nil
+}
+
+// pullRequestNumberRegex matches the number at the end of a pull request's
+// web URL, eg ".../pull/8", ".../-/merge_requests/3" or ".../pulls/4"
+var pullRequestNumberRegex = regexp.MustCompile(`/(?:pull|pulls|merge_requests)/(\d+)/?(?:[?#].*)?$`)
+
+// ParsePullRequestNumber returns the number of the pull request with the given
+// web URL
+func ParsePullRequestNumber(pullRequestURL string) (int, error) {
+	matches := pullRequestNumberRegex.FindStringSubmatch(strings.TrimSpace(pullRequestURL))
+	if matches == nil {
+		return 0, fmt.Errorf("%q is not a pull request URL", pullRequestURL)
+	}
+	return strconv.Atoi(matches[1])
+}
+
+func detectProvider(host string) Provider {
+	host = strings.ToLower(host)
+	switch {
+	case host == "github.com":
+		return ProviderGitHub
+	case strings.Contains(host, "gitlab"):
+		return ProviderGitLab
+	case strings.Contains(host, "gitea"), host == "codeberg.org":
+		return ProviderGitea
+	default:
+		return ""
+	}
+}
+
+// apiClient makes JSON requests to a provider's REST API
+type apiClient struct {
+	baseURL    string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// APIError is a non-successful response from a provider's API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+func (c apiClient) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		bodyJson, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(bodyJson)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		remoteURL string
+		config    common.PullRequestConfig
+		expected  Repository
+	}{
+		{
+			remoteURL: "git@github.com:sidekick/side.git",
+			expected:  Repository{Provider: ProviderGitHub, ApiURL: "https://api.github.com", Owner: "sidekick", Name: "side"},
+		},
+		{
+			remoteURL: "https://github.com/sidekick/side",
+			expected:  Repository{Provider: ProviderGitHub, ApiURL: "https://api.github.com", Owner: "sidekick", Name: "side"},
+		},
+		{
+			remoteURL: "ssh://git@gitlab.com:2222/group/subgroup/side.git",
+			expected:  Repository{Provider: ProviderGitLab, ApiURL: "https://gitlab.com/api/v4", Owner: "group/subgroup", Name: "side"},
+		},
+		{
+			remoteURL: "http://gitea.internal:3000/team/side.git/",
+			expected:  Repository{Provider: ProviderGitea, ApiURL: "http://gitea.internal:3000/api/v1", Owner: "team", Name: "side"},
+		},
+		{
+			remoteURL: "https://codeberg.org/team/side.git",
+			expected:  Repository{Provider: ProviderGitea, ApiURL: "https://codeberg.org/api/v1", Owner: "team", Name: "side"},
+		},
+		{
+			remoteURL: "git@ghe.example.com:team/side.git",
+			config:    common.PullRequestConfig{Provider: ProviderGitHub},
+			expected:  Repository{Provider: ProviderGitHub, ApiURL: "https://ghe.example.com/api/v3", Owner: "team", Name: "side"},
+		},
+		{
+			remoteURL: "https://git.example.com/team/side.git",
+			config:    common.PullRequestConfig{Provider: ProviderGitea, ApiURL: "https://api.example.com/v1/"},
+			expected:  Repository{Provider: ProviderGitea, ApiURL: "https://api.example.com/v1", Owner: "team", Name: "side"},
+		},
+	}
+	for _, tt := range tests {
+		repo, err := ParseRemoteURL(tt.remoteURL, tt.config)
+		require.NoError(t, err, tt.remoteURL)
+		assert.Equal(t, tt.expected, repo, tt.remoteURL)
+	}
+
+	for _, remoteURL := range []string{"https://git.example.com/team/side.git", "https://github.com/side", "/srv/git/side.git", ""} {
+		_, err := ParseRemoteURL(remoteURL, common.PullRequestConfig{})
+		assert.Error(t, err, remoteURL)
+	}
+}
+
+type recordedRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   map[string]string
+}
+
+// newFakeAPI serves canned responses keyed by method and request URI
+func newFakeAPI(t *testing.T, responses map[string]string) (*httptest.Server, *[]recordedRequest) {
+	t.Helper()
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := recordedRequest{method: r.Method, uri: r.RequestURI, header: r.Header}
+		if r.Method == "POST" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request.body))
+		}
+		requests = append(requests, request)
+		response, ok := responses[r.Method+" "+r.RequestURI]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestGithubClient(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls?head=team%3Aside%2Fexisting&state=open": `[{"number":7,"html_url":"https://github.com/team/side/pull/7","title":"Existing","head":{"ref":"side/existing"},"base":{"ref":"main"}}]`,
+		"GET /repos/team/side/pulls?head=team%3Aside%2Fnew&state=open":      `[]`,
+		"POST /repos/team/side/pulls":                                       `{"number":8,"html_url":"https://github.com/team/side/pull/8","title":"Add login","head":{"ref":"side/new"},"base":{"ref":"main"}}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitHub, ApiURL: server.URL, Owner: "team", Name: "side"}, "gh_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	existing, err := client.FindOpenPullRequest(ctx, "side/existing")
+	require.NoError(t, err)
+	assert.Equal(t, &PullRequest{Number: 7, URL: "https://github.com/team/side/pull/7", Title: "Existing", SourceBranch: "side/existing", TargetBranch: "main"}, existing)
+
+	existing, err = client.FindOpenPullRequest(ctx, "side/new")
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	created, err := client.CreatePullRequest(ctx, NewPullRequest{SourceBranch: "side/new", TargetBranch: "main", Title: "Add login", Body: "Adds a login page"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/team/side/pull/8", created.URL)
+
+	post := (*requests)[2]
+	assert.Equal(t, map[string]string{"title": "Add login", "body": "Adds a login page", "head": "side/new", "base": "main"}, post.body)
+	assert.Equal(t, "Bearer gh_token", post.header.Get("Authorization"))
+}
+
+func TestGitlabClient(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /projects/group%2Fsub%2Fside/merge_requests?source_branch=side%2Fnew&state=opened": `[]`,
+		"POST /projects/group%2Fsub%2Fside/merge_requests":                                      `{"iid":3,"web_url":"https://gitlab.com/group/sub/side/-/merge_requests/3","title":"Add login","source_branch":"side/new","target_branch":"main"}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitLab, ApiURL: server.URL, Owner: "group/sub", Name: "side"}, "gl_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	existing, err := client.FindOpenPullRequest(ctx, "side/new")
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	created, err := client.CreatePullRequest(ctx, NewPullRequest{SourceBranch: "side/new", TargetBranch: "main", Title: "Add login", Body: "Adds a login page"})
+	require.NoError(t, err)
+	assert.Equal(t, PullRequest{Number: 3, URL: "https://gitlab.com/group/sub/side/-/merge_requests/3", Title: "Add login", SourceBranch: "side/new", TargetBranch: "main"}, created)
+
+	post := (*requests)[1]
+	assert.Equal(t, map[string]string{"title": "Add login", "description": "Adds a login page", "source_branch": "side/new", "target_branch": "main"}, post.body)
+	assert.Equal(t, "gl_token", post.header.Get("PRIVATE-TOKEN"))
+}
+
+func TestGiteaClient(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls?limit=50&page=1&state=open": `[{"number":1,"html_url":"https://gitea.example/team/side/pulls/1","head":{"ref":"other"},"base":{"ref":"main"}}]`,
+		"POST /repos/team/side/pulls":                           `{"number":2,"html_url":"https://gitea.example/team/side/pulls/2","title":"Add login","head":{"ref":"side/new"},"base":{"ref":"main"}}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitea, ApiURL: server.URL, Owner: "team", Name: "side"}, "gt_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	existing, err := client.FindOpenPullRequest(ctx, "side/new")
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	created, err := client.CreatePullRequest(ctx, NewPullRequest{SourceBranch: "side/new", TargetBranch: "main", Title: "Add login"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, created.Number)
+	assert.Equal(t, "token gt_token", (*requests)[1].header.Get("Authorization"))
+}
+
+func TestClientAPIError(t *testing.T) {
+	t.Parallel()
+	server, _ := newFakeAPI(t, nil)
+	client, err := NewClient(Repository{Provider: ProviderGitHub, ApiURL: server.URL, Owner: "team", Name: "side"}, "gh_token")
+	require.NoError(t, err)
+
+	_, err = client.CreatePullRequest(context.Background(), NewPullRequest{SourceBranch: "a", TargetBranch: "b"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Contains(t, err.Error(), "not found")
+}
@@ -0,0 +1,201 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// giteaPageSize is the most pull requests listed per page, which Gitea caps
+// at 50 by default
+const giteaPageSize = 50
+
+type giteaClient struct {
+	api  apiClient
+	repo Repository
+}
+
+func (c giteaClient) pullsPath() string {
+	return fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(c.repo.Owner), url.PathEscape(c.repo.Name))
+}
+
+// FindOpenPullRequest pages through open pull requests, as Gitea's API can't
+// filter them by head branch
+func (c giteaClient) FindOpenPullRequest(ctx context.Context, sourceBranch string) (*PullRequest, error) {
+	for page := 1; ; page++ {
+		query := url.Values{"state": {"open"}, "limit": {fmt.Sprint(giteaPageSize)}, "page": {fmt.Sprint(page)}}
+		var pulls []githubPullRequest
+		if err := c.api.do(ctx, "GET", c.pullsPath()+"?"+query.Encode(), nil, &pulls); err != nil {
+			return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		for _, pull := range pulls {
+			if pull.Head.Ref == sourceBranch {
+				pr := pull.toPullRequest()
+				return &pr, nil
+			}
+		}
+		if len(pulls) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
+
+func (c giteaClient) CreatePullRequest(ctx context.Context, pr NewPullRequest) (PullRequest, error) {
+	body := map[string]string{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.SourceBranch,
+		"base":  pr.TargetBranch,
+	}
+	var created githubPullRequest
+	if err := c.api.do(ctx, "POST", c.pullsPath(), body, &created); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return created.toPullRequest(), nil
+}
+
+func (c giteaClient) GetPullRequest(ctx context.Context, number int) (PullRequest, error) {
+	var pull githubPullRequest
+	if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d", c.pullsPath(), number), nil, &pull); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	return pull.toPullRequest(), nil
+}
+
+// giteaReviewComment is a pull request review comment as returned by the
+// Gitea API
+type giteaReviewComment struct {
+	Id   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Path string `json:"path"`
+	// Position is the line in the new version of the file, and is 0 once the
+	// comment is outdated, in which case the original one still applies
+	Position         int       `json:"position"`
+	CommitId         string    `json:"commit_id"`
+	OriginalPosition int       `json:"original_position"`
+	OriginalCommitId string    `json:"original_commit_id"`
+	Resolver         *struct{} `json:"resolver"`
+	Created          time.Time `json:"created_at"`
+}
+
+// giteaIssueComment is a comment on a pull request's conversation
+type giteaIssueComment struct {
+	Id   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Created time.Time `json:"created_at"`
+}
+
+// ListReviewThreads groups the comments of all reviews into threads by the
+// line they're on, the way Gitea shows them as conversations. Gitea has no API
+// to reply within a conversation, so replies are left as pull request
+// comments and matched back to their thread by their marker.
+func (c giteaClient) ListReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	type threadKey struct {
+		path string
+		line int
+	}
+	var threads []ReviewThread
+	var created [][]time.Time
+	threadIndexes := map[threadKey]int{}
+	threadIdIndexes := map[string]int{}
+
+	for page := 1; ; page++ {
+		query := url.Values{"limit": {fmt.Sprint(giteaPageSize)}, "page": {fmt.Sprint(page)}}
+		var reviews []struct {
+			Id int64 `json:"id"`
+		}
+		if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d/reviews?%s", c.pullsPath(), number, query.Encode()), nil, &reviews); err != nil {
+			return nil, fmt.Errorf("failed to list pull request reviews: %w", err)
+		}
+		for _, review := range reviews {
+			var comments []giteaReviewComment
+			if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d/reviews/%d/comments", c.pullsPath(), number, review.Id), nil, &comments); err != nil {
+				return nil, fmt.Errorf("failed to list review comments: %w", err)
+			}
+			for _, comment := range comments {
+				line, commitSha := comment.Position, comment.CommitId
+				if line == 0 {
+					line, commitSha = comment.OriginalPosition, comment.OriginalCommitId
+				}
+				reviewComment := ReviewComment{Id: fmt.Sprint(comment.Id), Author: comment.User.Login, Body: comment.Body}
+				key := threadKey{path: comment.Path, line: line}
+				i, ok := threadIndexes[key]
+				if !ok {
+					i = len(threads)
+					threadIndexes[key] = i
+					threadIdIndexes[reviewComment.Id] = i
+					threads = append(threads, ReviewThread{Id: reviewComment.Id, Path: comment.Path, Line: line, CommitSha: commitSha})
+					created = append(created, nil)
+				}
+				threads[i].Comments = append(threads[i].Comments, reviewComment)
+				threads[i].Resolved = comment.Resolver != nil
+				created[i] = append(created[i], comment.Created)
+			}
+		}
+		if len(reviews) < giteaPageSize {
+			break
+		}
+	}
+	if len(threads) == 0 {
+		return threads, nil
+	}
+
+	var issueComments []giteaIssueComment
+	if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d/comments", c.issuesPath(), number), nil, &issueComments); err != nil {
+		return nil, fmt.Errorf("failed to list pull request comments: %w", err)
+	}
+	for _, comment := range issueComments {
+		threadId := replyThreadId(comment.Body)
+		i, ok := threadIdIndexes[threadId]
+		if threadId == "" || !ok {
+			continue
+		}
+		threads[i].Comments = append(threads[i].Comments, ReviewComment{Id: fmt.Sprint(comment.Id), Author: comment.User.Login, Body: comment.Body})
+		created[i] = append(created[i], comment.Created)
+	}
+	for i := range threads {
+		sort.Stable(commentsByCreated{comments: threads[i].Comments, created: created[i]})
+	}
+	return threads, nil
+}
+
+// ReplyToReviewThread comments on the pull request, quoting the line the
+// thread is on
+func (c giteaClient) ReplyToReviewThread(ctx context.Context, number int, thread ReviewThread, body string) error {
+	reference := thread.Path
+	if thread.Line > 0 {
+		reference = fmt.Sprintf("%s:%d", thread.Path, thread.Line)
+	}
+	if len(thread.Comments) > 0 {
+		reference += " (" + thread.Comments[0].Author + ")"
+	}
+	body = "> " + reference + "\n\n" + body
+	if err := c.api.do(ctx, "POST", fmt.Sprintf("%s/%d/comments", c.issuesPath(), number), map[string]string{"body": markReply(thread.Id, body)}, nil); err != nil {
+		return fmt.Errorf("failed to comment on pull request: %w", err)
+	}
+	return nil
+}
+
+func (c giteaClient) issuesPath() string {
+	return fmt.Sprintf("/repos/%s/%s/issues", url.PathEscape(c.repo.Owner), url.PathEscape(c.repo.Name))
+}
+
+type commentsByCreated struct {
+	comments []ReviewComment
+	created  []time.Time
+}
+
+func (c commentsByCreated) Len() int           { return len(c.comments) }
+func (c commentsByCreated) Less(i, j int) bool { return c.created[i].Before(c.created[j]) }
+func (c commentsByCreated) Swap(i, j int) {
+	c.comments[i], c.comments[j] = c.comments[j], c.comments[i]
+	c.created[i], c.created[j] = c.created[j], c.created[i]
+}
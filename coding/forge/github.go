@@ -0,0 +1,137 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// githubPullRequest is a pull request as returned by the GitHub and Gitea APIs
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (pr githubPullRequest) toPullRequest() PullRequest {
+	return PullRequest{
+		Number:       pr.Number,
+		URL:          pr.HTMLURL,
+		Title:        pr.Title,
+		SourceBranch: pr.Head.Ref,
+		TargetBranch: pr.Base.Ref,
+	}
+}
+
+type githubClient struct {
+	api  apiClient
+	repo Repository
+}
+
+func (c githubClient) pullsPath() string {
+	return fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(c.repo.Owner), url.PathEscape(c.repo.Name))
+}
+
+func (c githubClient) FindOpenPullRequest(ctx context.Context, sourceBranch string) (*PullRequest, error) {
+	query := url.Values{"state": {"open"}, "head": {c.repo.Owner + ":" + sourceBranch}}
+	var pulls []githubPullRequest
+	if err := c.api.do(ctx, "GET", c.pullsPath()+"?"+query.Encode(), nil, &pulls); err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	for _, pull := range pulls {
+		if pull.Head.Ref == sourceBranch {
+			pr := pull.toPullRequest()
+			return &pr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c githubClient) CreatePullRequest(ctx context.Context, pr NewPullRequest) (PullRequest, error) {
+	body := map[string]string{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.SourceBranch,
+		"base":  pr.TargetBranch,
+	}
+	var created githubPullRequest
+	if err := c.api.do(ctx, "POST", c.pullsPath(), body, &created); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return created.toPullRequest(), nil
+}
+
+func (c githubClient) GetPullRequest(ctx context.Context, number int) (PullRequest, error) {
+	var pull githubPullRequest
+	if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d", c.pullsPath(), number), nil, &pull); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	return pull.toPullRequest(), nil
+}
+
+// githubPageSize is the most review comments listed per page
+const githubPageSize = 100
+
+// githubReviewComment is a pull request review comment as returned by the
+// GitHub API
+type githubReviewComment struct {
+	Id          int64  `json:"id"`
+	InReplyToId int64  `json:"in_reply_to_id"`
+	Path        string `json:"path"`
+	// Line and CommitId are null once the comment is outdated by later
+	// commits, in which case the original ones still apply
+	Line             *int   `json:"line"`
+	CommitId         string `json:"commit_id"`
+	OriginalLine     *int   `json:"original_line"`
+	OriginalCommitId string `json:"original_commit_id"`
+	Body             string `json:"body"`
+	User             struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// ListReviewThreads groups review comments into threads by the comment they
+// reply to. GitHub's REST API doesn't expose whether a thread is resolved.
+func (c githubClient) ListReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	threadIndexes := map[int64]int{}
+	for page := 1; ; page++ {
+		query := url.Values{"per_page": {fmt.Sprint(githubPageSize)}, "page": {fmt.Sprint(page)}}
+		var comments []githubReviewComment
+		if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d/comments?%s", c.pullsPath(), number, query.Encode()), nil, &comments); err != nil {
+			return nil, fmt.Errorf("failed to list review comments: %w", err)
+		}
+		for _, comment := range comments {
+			reviewComment := ReviewComment{Id: fmt.Sprint(comment.Id), Author: comment.User.Login, Body: comment.Body}
+			if i, ok := threadIndexes[comment.InReplyToId]; ok && comment.InReplyToId != 0 {
+				threads[i].Comments = append(threads[i].Comments, reviewComment)
+				continue
+			}
+			thread := ReviewThread{Id: fmt.Sprint(comment.Id), Path: comment.Path, Comments: []ReviewComment{reviewComment}}
+			if comment.Line != nil {
+				thread.Line, thread.CommitSha = *comment.Line, comment.CommitId
+			} else if comment.OriginalLine != nil {
+				thread.Line, thread.CommitSha = *comment.OriginalLine, comment.OriginalCommitId
+			}
+			threadIndexes[comment.Id] = len(threads)
+			threads = append(threads, thread)
+		}
+		if len(comments) < githubPageSize {
+			return threads, nil
+		}
+	}
+}
+
+func (c githubClient) ReplyToReviewThread(ctx context.Context, number int, thread ReviewThread, body string) error {
+	path := fmt.Sprintf("%s/%d/comments/%s/replies", c.pullsPath(), number, url.PathEscape(thread.Id))
+	if err := c.api.do(ctx, "POST", path, map[string]string{"body": markReply(thread.Id, body)}, nil); err != nil {
+		return fmt.Errorf("failed to reply to review comment: %w", err)
+	}
+	return nil
+}
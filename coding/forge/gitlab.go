@@ -0,0 +1,142 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// gitlabMergeRequest is a merge request as returned by the GitLab API
+type gitlabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	Title        string `json:"title"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+}
+
+func (mr gitlabMergeRequest) toPullRequest() PullRequest {
+	return PullRequest{
+		Number:       mr.IID,
+		URL:          mr.WebURL,
+		Title:        mr.Title,
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+	}
+}
+
+type gitlabClient struct {
+	api  apiClient
+	repo Repository
+}
+
+// mergeRequestsPath identifies the project by its URL-encoded path, which
+// GitLab accepts in place of its numeric id
+func (c gitlabClient) mergeRequestsPath() string {
+	return "/projects/" + url.PathEscape(c.repo.FullName()) + "/merge_requests"
+}
+
+func (c gitlabClient) FindOpenPullRequest(ctx context.Context, sourceBranch string) (*PullRequest, error) {
+	query := url.Values{"state": {"opened"}, "source_branch": {sourceBranch}}
+	var mergeRequests []gitlabMergeRequest
+	if err := c.api.do(ctx, "GET", c.mergeRequestsPath()+"?"+query.Encode(), nil, &mergeRequests); err != nil {
+		return nil, fmt.Errorf("failed to list merge requests: %w", err)
+	}
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.SourceBranch == sourceBranch {
+			pr := mergeRequest.toPullRequest()
+			return &pr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c gitlabClient) CreatePullRequest(ctx context.Context, pr NewPullRequest) (PullRequest, error) {
+	body := map[string]string{
+		"title":         pr.Title,
+		"description":   pr.Body,
+		"source_branch": pr.SourceBranch,
+		"target_branch": pr.TargetBranch,
+	}
+	var created gitlabMergeRequest
+	if err := c.api.do(ctx, "POST", c.mergeRequestsPath(), body, &created); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create merge request: %w", err)
+	}
+	return created.toPullRequest(), nil
+}
+
+func (c gitlabClient) GetPullRequest(ctx context.Context, number int) (PullRequest, error) {
+	var mergeRequest gitlabMergeRequest
+	if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d", c.mergeRequestsPath(), number), nil, &mergeRequest); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to get merge request: %w", err)
+	}
+	return mergeRequest.toPullRequest(), nil
+}
+
+// gitlabPageSize is the most discussions listed per page
+const gitlabPageSize = 100
+
+// gitlabDiscussion is a merge request discussion as returned by the GitLab API
+type gitlabDiscussion struct {
+	Id    string `json:"id"`
+	Notes []struct {
+		Id     int64  `json:"id"`
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		Resolved bool `json:"resolved"`
+		Position *struct {
+			NewPath string `json:"new_path"`
+			NewLine *int   `json:"new_line"`
+			HeadSha string `json:"head_sha"`
+		} `json:"position"`
+	} `json:"notes"`
+}
+
+// ListReviewThreads returns the discussions started on a line of the diff,
+// skipping general comments and system notes
+func (c gitlabClient) ListReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	for page := 1; ; page++ {
+		query := url.Values{"per_page": {fmt.Sprint(gitlabPageSize)}, "page": {fmt.Sprint(page)}}
+		var discussions []gitlabDiscussion
+		if err := c.api.do(ctx, "GET", fmt.Sprintf("%s/%d/discussions?%s", c.mergeRequestsPath(), number, query.Encode()), nil, &discussions); err != nil {
+			return nil, fmt.Errorf("failed to list merge request discussions: %w", err)
+		}
+		for _, discussion := range discussions {
+			if len(discussion.Notes) == 0 || discussion.Notes[0].System || discussion.Notes[0].Position == nil {
+				continue
+			}
+			position := discussion.Notes[0].Position
+			thread := ReviewThread{
+				Id:        discussion.Id,
+				Path:      position.NewPath,
+				CommitSha: position.HeadSha,
+				Resolved:  discussion.Notes[0].Resolved,
+			}
+			if position.NewLine != nil {
+				thread.Line = *position.NewLine
+			}
+			for _, note := range discussion.Notes {
+				if note.System {
+					continue
+				}
+				thread.Comments = append(thread.Comments, ReviewComment{Id: fmt.Sprint(note.Id), Author: note.Author.Username, Body: note.Body})
+			}
+			threads = append(threads, thread)
+		}
+		if len(discussions) < gitlabPageSize {
+			return threads, nil
+		}
+	}
+}
+
+func (c gitlabClient) ReplyToReviewThread(ctx context.Context, number int, thread ReviewThread, body string) error {
+	path := fmt.Sprintf("%s/%d/discussions/%s/notes", c.mergeRequestsPath(), number, url.PathEscape(thread.Id))
+	if err := c.api.do(ctx, "POST", path, map[string]string{"body": markReply(thread.Id, body)}, nil); err != nil {
+		return fmt.Errorf("failed to reply to merge request discussion: %w", err)
+	}
+	return nil
+}
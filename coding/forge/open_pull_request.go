@@ -0,0 +1,118 @@
+package forge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sidekick/common"
+	"sidekick/env"
+	"sidekick/secret_manager"
+	"strings"
+)
+
+type OpenPullRequestActivityInput struct {
+	EnvContainer env.EnvContainer
+	Secrets      secret_manager.SecretManagerContainer
+	Config       common.PullRequestConfig
+	SourceBranch string
+	TargetBranch string
+	Title        string
+	Body         string
+}
+
+// OpenPullRequestActivity pushes the source branch to the configured remote
+// and opens a pull request from it into the target branch, authenticating with
+// the provider's access token from the secret manager. If a pull request from
+// the branch is already open, eg when retrying, that one is returned instead.
+func OpenPullRequestActivity(ctx context.Context, input OpenPullRequestActivityInput) (PullRequest, error) {
+	if input.SourceBranch == "" || input.TargetBranch == "" {
+		return PullRequest{}, fmt.Errorf("both source and target branches are required to open a pull request")
+	}
+
+	remote, err := resolveRemote(ctx, input.EnvContainer, input.Secrets, input.Config)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	if err := PushBranch(ctx, input.EnvContainer, remote.name, remote.url, remote.repo.Provider, remote.token, input.SourceBranch); err != nil {
+		return PullRequest{}, err
+	}
+
+	client, err := NewClient(remote.repo, remote.token)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	existing, err := client.FindOpenPullRequest(ctx, input.SourceBranch)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	if existing != nil {
+		return *existing, nil
+	}
+	return client.CreatePullRequest(ctx, NewPullRequest{
+		SourceBranch: input.SourceBranch,
+		TargetBranch: input.TargetBranch,
+		Title:        input.Title,
+		Body:         input.Body,
+	})
+}
+
+// GetRemoteURL returns the URL of the given git remote
+func GetRemoteURL(ctx context.Context, envContainer env.EnvContainer, remote string) (string, error) {
+	output, err := env.EnvRunCommandActivity(ctx, env.EnvRunCommandActivityInput{
+		EnvContainer:       envContainer,
+		RelativeWorkingDir: "./",
+		Command:            "git",
+		Args:               []string{"remote", "get-url", remote},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get URL of remote %s: %w", remote, err)
+	}
+	if output.ExitStatus != 0 {
+		return "", fmt.Errorf("failed to get URL of remote %s: %s", remote, strings.TrimSpace(output.Stderr))
+	}
+	return strings.TrimSpace(output.Stdout), nil
+}
+
+// PushBranch pushes the branch to the remote. Pushes over https authenticate
+// with the access token, while other remotes rely on the user's git setup, eg
+// their ssh keys.
+func PushBranch(ctx context.Context, envContainer env.EnvContainer, remote, remoteURL string, provider Provider, token, branch string) error {
+	envVars := []string{"GIT_TERMINAL_PROMPT=0"}
+	if strings.HasPrefix(remoteURL, "https://") || strings.HasPrefix(remoteURL, "http://") {
+		// passed via the environment rather than args, so the token isn't
+		// visible in the process list
+		envVars = append(envVars,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+basicAuth(provider, token),
+		)
+	}
+	refspec := "refs/heads/" + branch + ":refs/heads/" + branch
+	output, err := env.EnvRunCommandActivity(ctx, env.EnvRunCommandActivityInput{
+		EnvContainer:       envContainer,
+		RelativeWorkingDir: "./",
+		Command:            "git",
+		Args:               []string{"push", remote, refspec},
+		EnvVars:            envVars,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push branch %s: %w", branch, err)
+	}
+	if output.ExitStatus != 0 {
+		return fmt.Errorf("failed to push branch %s to %s: %s", branch, remote, strings.TrimSpace(output.Stderr))
+	}
+	return nil
+}
+
+// basicAuth encodes the token as http basic auth credentials, with the
+// username each provider expects for token authentication
+func basicAuth(provider Provider, token string) string {
+	username := "x-access-token"
+	switch provider {
+	case ProviderGitLab:
+		username = "oauth2"
+	case ProviderGitea:
+		username = "sidekick" // any non-empty username works
+	}
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + token))
+}
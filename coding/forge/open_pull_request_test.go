@@ -0,0 +1,85 @@
+package forge
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/env"
+	"sidekick/secret_manager"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(),
+		"GIT_AUTHOR_NAME=Test User",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v failed: %s", args, output)
+	return strings.TrimSpace(string(output))
+}
+
+type fakeSecretManager map[string]string
+
+func (m fakeSecretManager) GetSecret(secretName string) (string, error) {
+	if secret, ok := m[secretName]; ok {
+		return secret, nil
+	}
+	return "", secret_manager.ErrSecretNotFound
+}
+
+func (m fakeSecretManager) GetType() secret_manager.SecretManagerType {
+	return secret_manager.MockSecretManagerType
+}
+
+func TestOpenPullRequestActivity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// the remote's URL identifies the repo on the hosting service, while
+	// pushes go to a local bare repo
+	bareDir := filepath.Join(t.TempDir(), "side.git")
+	runGit(t, t.TempDir(), "init", "--bare", "-b", "main", bareDir)
+	repoDir := t.TempDir()
+	runGit(t, repoDir, "init", "-b", "main")
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "initial")
+	runGit(t, repoDir, "checkout", "-b", "side/add-login")
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "add login")
+	runGit(t, repoDir, "remote", "add", "upstream", "https://gitea.example/team/side.git")
+	runGit(t, repoDir, "remote", "set-url", "--push", "upstream", bareDir)
+
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls?limit=50&page=1&state=open": `[]`,
+		"POST /repos/team/side/pulls":                           `{"number":4,"html_url":"https://gitea.example/team/side/pulls/4","title":"Add login","head":{"ref":"side/add-login"},"base":{"ref":"main"}}`,
+	})
+
+	devEnv, err := env.NewLocalEnv(ctx, env.LocalEnvParams{RepoDir: repoDir})
+	require.NoError(t, err)
+	pr, err := OpenPullRequestActivity(ctx, OpenPullRequestActivityInput{
+		EnvContainer: env.EnvContainer{Env: devEnv},
+		Secrets:      secret_manager.SecretManagerContainer{SecretManager: fakeSecretManager{GiteaAccessTokenSecretName: "gt_token"}},
+		Config:       common.PullRequestConfig{Remote: "upstream", ApiURL: server.URL},
+		SourceBranch: "side/add-login",
+		TargetBranch: "main",
+		Title:        "Add login",
+		Body:         "Adds a login page",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitea.example/team/side/pulls/4", pr.URL)
+
+	assert.Equal(t, runGit(t, repoDir, "rev-parse", "side/add-login"), runGit(t, bareDir, "rev-parse", "side/add-login"))
+	require.Len(t, *requests, 2)
+	assert.Equal(t, "side/add-login", (*requests)[1].body["head"])
+	assert.Equal(t, "Adds a login page", (*requests)[1].body["body"])
+	assert.Equal(t, "token gt_token", (*requests)[1].header.Get("Authorization"))
+}
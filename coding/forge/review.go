@@ -0,0 +1,265 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sidekick/coding/diffanalysis"
+	"sidekick/common"
+	"sidekick/env"
+	"sidekick/secret_manager"
+	"strings"
+)
+
+var commitShaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// reviewSnippetContext is the number of lines shown before and after the
+// commented line in a located thread's snippet
+const reviewSnippetContext = 3
+
+type PullRequestReview struct {
+	PullRequest PullRequest
+	Threads     []ReviewThread
+}
+
+type GetPullRequestReviewActivityInput struct {
+	EnvContainer   env.EnvContainer
+	Secrets        secret_manager.SecretManagerContainer
+	Config         common.PullRequestConfig
+	PullRequestURL string
+}
+
+// GetPullRequestReviewActivity fetches the pull request with the given URL and
+// its review threads from the repository's hosting service
+func GetPullRequestReviewActivity(ctx context.Context, input GetPullRequestReviewActivityInput) (PullRequestReview, error) {
+	number, err := ParsePullRequestNumber(input.PullRequestURL)
+	if err != nil {
+		return PullRequestReview{}, err
+	}
+	client, err := newRemoteClient(ctx, input.EnvContainer, input.Secrets, input.Config)
+	if err != nil {
+		return PullRequestReview{}, err
+	}
+	pr, err := client.GetPullRequest(ctx, number)
+	if err != nil {
+		return PullRequestReview{}, err
+	}
+	threads, err := client.ListReviewThreads(ctx, number)
+	if err != nil {
+		return PullRequestReview{}, err
+	}
+	return PullRequestReview{PullRequest: pr, Threads: threads}, nil
+}
+
+// LocatedReviewThread is a review thread along with where its line is now in
+// the working tree, which may have moved since the comment was made
+type LocatedReviewThread struct {
+	ReviewThread
+	// CurrentLine is the commented line in the working tree, or 0 if it was
+	// changed or removed since
+	CurrentLine int
+	// Approximate is set when the line couldn't be mapped and is the original
+	// one, eg when the commit the comment was made on isn't known locally
+	Approximate bool
+	FileDeleted bool
+	// Snippet is the code around the current line, prefixed with line numbers
+	Snippet string
+}
+
+type LocateReviewThreadsActivityInput struct {
+	EnvContainer env.EnvContainer
+	Threads      []ReviewThread
+}
+
+// LocateReviewThreadsActivity maps each thread's line, as of the commit it was
+// commented on, to the corresponding line in the working tree by diffing the
+// file against that commit
+func LocateReviewThreadsActivity(ctx context.Context, input LocateReviewThreadsActivityInput) ([]LocatedReviewThread, error) {
+	located := make([]LocatedReviewThread, 0, len(input.Threads))
+	for _, thread := range input.Threads {
+		locatedThread, err := locateReviewThread(ctx, input.EnvContainer, thread)
+		if err != nil {
+			return nil, err
+		}
+		located = append(located, locatedThread)
+	}
+	return located, nil
+}
+
+func locateReviewThread(ctx context.Context, envContainer env.EnvContainer, thread ReviewThread) (LocatedReviewThread, error) {
+	located := LocatedReviewThread{ReviewThread: thread, CurrentLine: thread.Line}
+	if thread.Path == "" {
+		return located, nil
+	}
+	// paths come from the hosting service, and mustn't point outside the
+	// working tree
+	if !filepath.IsLocal(thread.Path) {
+		located.Approximate = true
+		return located, nil
+	}
+
+	// nor should commits be mistaken for git options
+	if !commitShaPattern.MatchString(thread.CommitSha) {
+		located.Approximate = true
+	} else {
+		output, err := env.EnvRunCommandActivity(ctx, env.EnvRunCommandActivityInput{
+			EnvContainer:       envContainer,
+			RelativeWorkingDir: "./",
+			Command:            "git",
+			Args:               []string{"diff", "--no-color", "--no-ext-diff", "--find-renames", thread.CommitSha, "--", thread.Path},
+		})
+		if err != nil {
+			return LocatedReviewThread{}, fmt.Errorf("failed to diff %s: %w", thread.Path, err)
+		}
+		if output.ExitStatus != 0 {
+			// most likely the commit was never fetched locally, eg when it was
+			// pushed by someone else
+			located.Approximate = true
+		} else {
+			fileDiffs, err := diffanalysis.ParseUnifiedDiff(output.Stdout)
+			if err != nil {
+				return LocatedReviewThread{}, fmt.Errorf("failed to parse diff of %s: %w", thread.Path, err)
+			}
+			for _, fileDiff := range fileDiffs {
+				if fileDiff.IsDeleted {
+					located.FileDeleted = true
+					located.CurrentLine = 0
+					return located, nil
+				}
+				if thread.Line > 0 {
+					newLine, exists := fileDiff.MapOldLineToNew(thread.Line)
+					if !exists {
+						newLine = 0
+					}
+					located.CurrentLine = newLine
+				}
+			}
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(envContainer.Env.GetWorkingDirectory(), thread.Path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			located.FileDeleted = true
+			located.CurrentLine = 0
+			return located, nil
+		}
+		return LocatedReviewThread{}, fmt.Errorf("failed to read %s: %w", thread.Path, err)
+	}
+	if located.CurrentLine > 0 {
+		located.Snippet = snippetAround(string(content), located.CurrentLine, reviewSnippetContext)
+	}
+	return located, nil
+}
+
+// snippetAround returns the lines around the given 1-indexed line, each
+// prefixed with its line number
+func snippetAround(content string, line, context int) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if line > len(lines) {
+		return ""
+	}
+	start := max(line-context, 1)
+	end := min(line+context, len(lines))
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%d\t%s\n", i, lines[i-1])
+	}
+	return sb.String()
+}
+
+type PushBranchActivityInput struct {
+	EnvContainer env.EnvContainer
+	Secrets      secret_manager.SecretManagerContainer
+	Config       common.PullRequestConfig
+	Branch       string
+}
+
+// PushBranchActivity pushes the branch to the configured remote, eg to update
+// an open pull request
+func PushBranchActivity(ctx context.Context, input PushBranchActivityInput) error {
+	remote, err := resolveRemote(ctx, input.EnvContainer, input.Secrets, input.Config)
+	if err != nil {
+		return err
+	}
+	return PushBranch(ctx, input.EnvContainer, remote.name, remote.url, remote.repo.Provider, remote.token, input.Branch)
+}
+
+type ReviewThreadReply struct {
+	Thread ReviewThread
+	Body   string
+}
+
+type ReplyToReviewThreadsActivityInput struct {
+	EnvContainer      env.EnvContainer
+	Secrets           secret_manager.SecretManagerContainer
+	Config            common.PullRequestConfig
+	PullRequestNumber int
+	Replies           []ReviewThreadReply
+}
+
+// ReplyToReviewThreadsActivity replies to each of the given threads. Threads
+// that no longer need addressing, eg as they were already replied to before a
+// retry, are skipped.
+func ReplyToReviewThreadsActivity(ctx context.Context, input ReplyToReviewThreadsActivityInput) error {
+	client, err := newRemoteClient(ctx, input.EnvContainer, input.Secrets, input.Config)
+	if err != nil {
+		return err
+	}
+	threads, err := client.ListReviewThreads(ctx, input.PullRequestNumber)
+	if err != nil {
+		return err
+	}
+	replied := map[string]bool{}
+	for _, thread := range threads {
+		if !thread.NeedsAddressing() {
+			replied[thread.Id] = true
+		}
+	}
+	for _, reply := range input.Replies {
+		if replied[reply.Thread.Id] {
+			continue
+		}
+		if err := client.ReplyToReviewThread(ctx, input.PullRequestNumber, reply.Thread, reply.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteAccess is the configured remote and the repository and access token
+// needed to call its hosting service
+type remoteAccess struct {
+	name  string
+	url   string
+	repo  Repository
+	token string
+}
+
+func resolveRemote(ctx context.Context, envContainer env.EnvContainer, secrets secret_manager.SecretManagerContainer, config common.PullRequestConfig) (remoteAccess, error) {
+	remote := remoteAccess{name: config.GetRemote()}
+	var err error
+	remote.url, err = GetRemoteURL(ctx, envContainer, remote.name)
+	if err != nil {
+		return remoteAccess{}, err
+	}
+	remote.repo, err = ParseRemoteURL(remote.url, config)
+	if err != nil {
+		return remoteAccess{}, err
+	}
+	remote.token, err = secrets.SecretManager.GetSecret(remote.repo.TokenSecretName())
+	if err != nil {
+		return remoteAccess{}, fmt.Errorf("failed to get %s access token: %w", remote.repo.Provider, err)
+	}
+	return remote, nil
+}
+
+func newRemoteClient(ctx context.Context, envContainer env.EnvContainer, secrets secret_manager.SecretManagerContainer, config common.PullRequestConfig) (Client, error) {
+	remote, err := resolveRemote(ctx, envContainer, secrets, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(remote.repo, remote.token)
+}
@@ -0,0 +1,244 @@
+package forge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/env"
+	"sidekick/secret_manager"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePullRequestNumber(t *testing.T) {
+	t.Parallel()
+	for pullRequestURL, expected := range map[string]int{
+		"https://github.com/team/side/pull/8":                  8,
+		"https://gitlab.com/group/sub/side/-/merge_requests/3": 3,
+		"https://gitea.example/team/side/pulls/4/":             4,
+		"https://github.com/team/side/pull/12#discussion_r1":   12,
+	} {
+		number, err := ParsePullRequestNumber(pullRequestURL)
+		require.NoError(t, err, pullRequestURL)
+		assert.Equal(t, expected, number, pullRequestURL)
+	}
+
+	for _, pullRequestURL := range []string{"", "https://github.com/team/side", "https://github.com/team/side/pull/new"} {
+		_, err := ParsePullRequestNumber(pullRequestURL)
+		assert.Error(t, err, pullRequestURL)
+	}
+}
+
+func TestReviewThreadNeedsAddressing(t *testing.T) {
+	t.Parallel()
+	comment := ReviewComment{Id: "1", Author: "reviewer", Body: "Rename this"}
+	reply := ReviewComment{Id: "2", Author: "sidekick", Body: markReply("1", "Addressed in abc1234.")}
+
+	assert.True(t, ReviewThread{Id: "1", Comments: []ReviewComment{comment}}.NeedsAddressing())
+	assert.False(t, ReviewThread{Id: "1", Comments: []ReviewComment{comment}, Resolved: true}.NeedsAddressing())
+	assert.False(t, ReviewThread{Id: "1", Comments: []ReviewComment{comment, reply}}.NeedsAddressing())
+	assert.True(t, ReviewThread{Id: "1", Comments: []ReviewComment{comment, reply, {Id: "3", Body: "Not quite"}}}.NeedsAddressing())
+	assert.False(t, ReviewThread{Id: "1"}.NeedsAddressing())
+	assert.Equal(t, "1", replyThreadId(reply.Body))
+}
+
+func TestGithubClientReviewThreads(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls/8/comments?page=1&per_page=100": `[
+			{"id":11,"path":"main.go","line":12,"commit_id":"abc","body":"Rename this","user":{"login":"reviewer"}},
+			{"id":12,"path":"util.go","line":null,"commit_id":"def","original_line":3,"original_commit_id":"abc","body":"Unused","user":{"login":"reviewer"}},
+			{"id":13,"in_reply_to_id":11,"path":"main.go","line":12,"commit_id":"abc","body":"To what?","user":{"login":"author"}}
+		]`,
+		"POST /repos/team/side/pulls/8/comments/11/replies": `{"id":14}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitHub, ApiURL: server.URL, Owner: "team", Name: "side"}, "gh_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	threads, err := client.ListReviewThreads(ctx, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []ReviewThread{
+		{Id: "11", Path: "main.go", Line: 12, CommitSha: "abc", Comments: []ReviewComment{
+			{Id: "11", Author: "reviewer", Body: "Rename this"},
+			{Id: "13", Author: "author", Body: "To what?"},
+		}},
+		{Id: "12", Path: "util.go", Line: 3, CommitSha: "abc", Comments: []ReviewComment{
+			{Id: "12", Author: "reviewer", Body: "Unused"},
+		}},
+	}, threads)
+
+	require.NoError(t, client.ReplyToReviewThread(ctx, 8, threads[0], "Addressed in abc1234."))
+	post := (*requests)[1]
+	assert.Equal(t, markReply("11", "Addressed in abc1234."), post.body["body"])
+}
+
+func TestGitlabClientReviewThreads(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /projects/group%2Fside/merge_requests/3/discussions?page=1&per_page=100": `[
+			{"id":"d1","notes":[
+				{"id":1,"body":"Rename this","author":{"username":"reviewer"},"resolved":false,"position":{"new_path":"main.go","new_line":12,"head_sha":"abc"}},
+				{"id":2,"body":"changed this line in version 2","system":true,"author":{"username":"author"}},
+				{"id":3,"body":"Done?","author":{"username":"author"},"resolved":false,"position":{"new_path":"main.go","new_line":12,"head_sha":"abc"}}
+			]},
+			{"id":"d2","notes":[{"id":4,"body":"Looks good overall","author":{"username":"reviewer"}}]},
+			{"id":"d3","notes":[{"id":5,"body":"Typo","author":{"username":"reviewer"},"resolved":true,"position":{"new_path":"README.md","new_line":null,"head_sha":"abc"}}]}
+		]`,
+		"POST /projects/group%2Fside/merge_requests/3/discussions/d1/notes": `{"id":6}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitLab, ApiURL: server.URL, Owner: "group", Name: "side"}, "gl_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	threads, err := client.ListReviewThreads(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []ReviewThread{
+		{Id: "d1", Path: "main.go", Line: 12, CommitSha: "abc", Comments: []ReviewComment{
+			{Id: "1", Author: "reviewer", Body: "Rename this"},
+			{Id: "3", Author: "author", Body: "Done?"},
+		}},
+		{Id: "d3", Path: "README.md", CommitSha: "abc", Resolved: true, Comments: []ReviewComment{
+			{Id: "5", Author: "reviewer", Body: "Typo"},
+		}},
+	}, threads)
+
+	require.NoError(t, client.ReplyToReviewThread(ctx, 3, threads[0], "Addressed in abc1234."))
+	assert.Equal(t, markReply("d1", "Addressed in abc1234."), (*requests)[1].body["body"])
+}
+
+func TestGiteaClientReviewThreads(t *testing.T) {
+	t.Parallel()
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls/4/reviews?limit=50&page=1": `[{"id":1},{"id":2}]`,
+		"GET /repos/team/side/pulls/4/reviews/1/comments": `[
+			{"id":10,"body":"Rename this","user":{"login":"reviewer"},"path":"main.go","position":12,"commit_id":"abc","created_at":"2024-01-01T10:00:00Z"}
+		]`,
+		"GET /repos/team/side/pulls/4/reviews/2/comments": `[
+			{"id":20,"body":"Still not renamed","user":{"login":"reviewer"},"path":"main.go","position":12,"commit_id":"abc","created_at":"2024-01-03T10:00:00Z"},
+			{"id":21,"body":"Unused","user":{"login":"reviewer"},"path":"util.go","position":0,"original_position":3,"original_commit_id":"def","resolver":{"login":"author"},"created_at":"2024-01-03T10:00:00Z"}
+		]`,
+		"GET /repos/team/side/issues/4/comments": `[
+			{"id":30,"body":"Thanks!","user":{"login":"author"},"created_at":"2024-01-01T11:00:00Z"},
+			{"id":31,"body":"Addressed in abc1234.\n\n<!-- sidekick-reply:10 -->","user":{"login":"sidekick"},"created_at":"2024-01-02T10:00:00Z"}
+		]`,
+		"POST /repos/team/side/issues/4/comments": `{"id":32}`,
+	})
+	client, err := NewClient(Repository{Provider: ProviderGitea, ApiURL: server.URL, Owner: "team", Name: "side"}, "gt_token")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	threads, err := client.ListReviewThreads(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []ReviewThread{
+		{Id: "10", Path: "main.go", Line: 12, CommitSha: "abc", Comments: []ReviewComment{
+			{Id: "10", Author: "reviewer", Body: "Rename this"},
+			{Id: "31", Author: "sidekick", Body: "Addressed in abc1234.\n\n<!-- sidekick-reply:10 -->"},
+			{Id: "20", Author: "reviewer", Body: "Still not renamed"},
+		}},
+		{Id: "21", Path: "util.go", Line: 3, CommitSha: "def", Resolved: true, Comments: []ReviewComment{
+			{Id: "21", Author: "reviewer", Body: "Unused"},
+		}},
+	}, threads)
+	assert.True(t, threads[0].NeedsAddressing())
+
+	require.NoError(t, client.ReplyToReviewThread(ctx, 4, threads[0], "Renamed in def5678."))
+	post := (*requests)[len(*requests)-1]
+	assert.Equal(t, "POST", post.method)
+	assert.Equal(t, markReply("10", "> main.go:12 (reviewer)\n\nRenamed in def5678."), post.body["body"])
+}
+
+func TestLocateReviewThreadsActivity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repoDir := t.TempDir()
+	runGit(t, repoDir, "init", "-b", "main")
+	writeFile := func(name string, lines ...string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, name), []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	}
+	writeFile("main.go", "package main", "", "func a() {}", "", "func b() {}", "", "func c() {}")
+	writeFile("old.go", "package main")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-m", "initial")
+	reviewedSha := runGit(t, repoDir, "rev-parse", "HEAD")
+
+	// two lines are added above func c and func b is changed, partly in an
+	// uncommitted change
+	writeFile("main.go", "package main", "", "import \"fmt\"", "", "func a() {}", "", "func b() { fmt.Println() }", "", "func c() {}")
+	require.NoError(t, os.Remove(filepath.Join(repoDir, "old.go")))
+
+	devEnv, err := env.NewLocalEnv(ctx, env.LocalEnvParams{RepoDir: repoDir})
+	require.NoError(t, err)
+	located, err := LocateReviewThreadsActivity(ctx, LocateReviewThreadsActivityInput{
+		EnvContainer: env.EnvContainer{Env: devEnv},
+		Threads: []ReviewThread{
+			{Id: "1", Path: "main.go", Line: 7, CommitSha: reviewedSha},
+			{Id: "2", Path: "main.go", Line: 5, CommitSha: reviewedSha},
+			{Id: "3", Path: "old.go", Line: 1, CommitSha: reviewedSha},
+			{Id: "4", Path: "main.go", Line: 3, CommitSha: "0123456789abcdef0123456789abcdef01234567"},
+			{Id: "5", Path: "main.go", Line: 3, CommitSha: "--output=" + filepath.Join(repoDir, "clobbered")},
+			{Id: "6", Path: "../outside.go", Line: 1, CommitSha: reviewedSha},
+			{Id: "7", Path: filepath.Join(repoDir, "main.go"), Line: 1, CommitSha: reviewedSha},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, located, 7)
+
+	assert.Equal(t, 9, located[0].CurrentLine)
+	assert.False(t, located[0].Approximate)
+	assert.Equal(t, "6\t\n7\tfunc b() { fmt.Println() }\n8\t\n9\tfunc c() {}\n", located[0].Snippet)
+
+	assert.Equal(t, 0, located[1].CurrentLine, "the commented line was changed")
+	assert.Empty(t, located[1].Snippet)
+
+	assert.True(t, located[2].FileDeleted)
+
+	assert.True(t, located[3].Approximate, "the commit is unknown")
+	assert.Equal(t, 3, located[3].CurrentLine)
+
+	assert.True(t, located[4].Approximate, "the commit isn't a sha")
+	assert.NoFileExists(t, filepath.Join(repoDir, "clobbered"))
+	for _, outside := range located[5:] {
+		assert.True(t, outside.Approximate, outside.Path)
+		assert.Empty(t, outside.Snippet, outside.Path)
+	}
+}
+
+func TestReplyToReviewThreadsActivity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repoDir := t.TempDir()
+	runGit(t, repoDir, "init", "-b", "main")
+	runGit(t, repoDir, "remote", "add", "origin", "https://github.com/team/side.git")
+
+	server, requests := newFakeAPI(t, map[string]string{
+		"GET /repos/team/side/pulls/8/comments?page=1&per_page=100": `[
+			{"id":11,"path":"main.go","line":12,"commit_id":"abc","body":"Rename this","user":{"login":"reviewer"}},
+			{"id":12,"path":"util.go","line":3,"commit_id":"abc","body":"Unused","user":{"login":"reviewer"}},
+			{"id":13,"in_reply_to_id":12,"path":"util.go","line":3,"commit_id":"abc","body":"Addressed in abc1234.\n\n<!-- sidekick-reply:12 -->","user":{"login":"sidekick"}}
+		]`,
+		"POST /repos/team/side/pulls/8/comments/11/replies": `{"id":14}`,
+	})
+
+	devEnv, err := env.NewLocalEnv(ctx, env.LocalEnvParams{RepoDir: repoDir})
+	require.NoError(t, err)
+	err = ReplyToReviewThreadsActivity(ctx, ReplyToReviewThreadsActivityInput{
+		EnvContainer:      env.EnvContainer{Env: devEnv},
+		Secrets:           secret_manager.SecretManagerContainer{SecretManager: fakeSecretManager{GithubAccessTokenSecretName: "gh_token"}},
+		Config:            common.PullRequestConfig{ApiURL: server.URL},
+		PullRequestNumber: 8,
+		Replies: []ReviewThreadReply{
+			{Thread: ReviewThread{Id: "11"}, Body: "Addressed in abc1234."},
+			{Thread: ReviewThread{Id: "12"}, Body: "Addressed in abc1234."},
+		},
+	})
+	require.NoError(t, err)
+
+	// the second thread was already replied to, eg by an earlier attempt
+	require.Len(t, *requests, 2)
+	assert.Equal(t, "/repos/team/side/pulls/8/comments/11/replies", (*requests)[1].uri)
+}
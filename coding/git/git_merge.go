@@ -44,7 +44,7 @@ func GitMergeActivity(ctx context.Context, envContainer env.EnvContainer, params
 	committerName, committerEmail := params.CommitterName, params.CommitterEmail
 	if committerName == "" || committerEmail == "" {
 		envType := envContainer.Env.GetType()
-		if envType == env.EnvTypeLocal || envType == env.EnvTypeLocalGitWorktree {
+		if envType == env.EnvTypeLocal || envType.UsesGitWorktree() {
 			name, email, err := getGitUserConfig(ctx, envContainer)
 			if err == nil {
 				if committerName == "" {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"sidekick/env"
@@ -39,7 +40,11 @@ func (lspa *LSPActivities) AutofixActivity(ctx context.Context, input AutofixAct
 
 	// step 1: initialize
 	langName := utils.InferLanguageNameFromFilePath(input.DocumentURI)
-	lspClient, err := lspa.findOrInitClient(ctx, input.EnvContainer.Env.GetWorkingDirectory(), langName)
+	var filePath string
+	if u, err := url.Parse(input.DocumentURI); err == nil {
+		filePath = u.Path
+	}
+	lspClient, err := lspa.findOrInitClient(ctx, input.EnvContainer.Env.GetWorkingDirectory(), filePath, langName)
 	if err != nil {
 		if errors.Is(err, ErrUnsupportedLanguage) {
 			span.SetAttributes(attribute.Bool("skipped", true), attribute.String("reason", "unsupported language"))
@@ -49,7 +54,7 @@ func (lspa *LSPActivities) AutofixActivity(ctx context.Context, input AutofixAct
 	}
 
 	// step 2: get code actions
-	codeActions, err := getAutofixCodeActions(ctx, lspClient, input.DocumentURI)
+	codeActions, err := getAutofixCodeActions(ctx, lspClient, input.DocumentURI, langName)
 	if err != nil {
 		return AutofixActivityOutput{}, err
 	}
@@ -63,7 +68,7 @@ func (lspa *LSPActivities) AutofixActivity(ctx context.Context, input AutofixAct
 	return output, nil
 }
 
-func getAutofixCodeActions(ctx context.Context, lspClient LSPClient, documentURI string) ([]CodeAction, error) {
+func getAutofixCodeActions(ctx context.Context, lspClient LSPClient, documentURI string, langName string) ([]CodeAction, error) {
 	ctx, span := autofixTracer.Start(ctx, "getAutofixCodeActions")
 	defer span.End()
 
@@ -75,6 +80,14 @@ func getAutofixCodeActions(ctx context.Context, lspClient LSPClient, documentURI
 		span.SetStatus(codes.Error, err.Error())
 		return []CodeAction{}, err
 	}
+	closeDocument, err := openDocument(ctx, lspClient, documentURI, langName, string(fileContent))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return []CodeAction{}, err
+	}
+	defer closeDocument()
+
 	lines := strings.Split(string(fileContent), "\n")
 	endLine := len(lines) - 1
 	endCharacter := len(lines[endLine])
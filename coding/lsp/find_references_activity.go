@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -41,20 +42,18 @@ type FindReferencesActivityInput struct {
 func (lspa *LSPActivities) FindReferencesActivity(ctx context.Context, input FindReferencesActivityInput) ([]Location, error) {
 	baseDir := input.EnvContainer.Env.GetWorkingDirectory()
 	lang := utils.InferLanguageNameFromFilePath(input.RelativeFilePath)
-	lspClient, err := lspa.findOrInitClient(ctx, baseDir, lang)
+	lspClient, err := lspa.findOrInitClient(ctx, baseDir, input.RelativeFilePath, lang)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find or initialize lsp client: %w", err)
 	}
 
 	absoluteFilepath := filepath.Join(baseDir, input.RelativeFilePath)
-	file, err := os.Open(absoluteFilepath)
+	content, err := os.ReadFile(absoluteFilepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
-	reader := bufio.NewReader(file)
 
-	position, err := findSymbolPosition(reader, input.Range, input.SymbolText)
+	position, err := findSymbolPosition(bytes.NewReader(content), input.Range, input.SymbolText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find symbol position: %w", err)
 	}
@@ -63,6 +62,12 @@ func (lspa *LSPActivities) FindReferencesActivity(ctx context.Context, input Fin
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse uri file://%s: %w", absoluteFilepath, err)
 	}
+	closeDocument, err := openDocument(ctx, lspClient, uri.String(), lang, string(content))
+	if err != nil {
+		return nil, err
+	}
+	defer closeDocument()
+
 	references, err := lspClient.TextDocumentReferences(ctx, uri.String(), position.Line, position.Character)
 	if err != nil {
 		return nil, fmt.Errorf("failed to invoke lsp text document references: %w", err)
@@ -71,14 +76,21 @@ func (lspa *LSPActivities) FindReferencesActivity(ctx context.Context, input Fin
 	return references, nil
 }
 
-func (lspa *LSPActivities) findOrInitClient(ctx context.Context, baseDir string, lang string) (LSPClient, error) {
+// findOrInitClient returns an initialized LSP client for the given language,
+// rooted at the nearest directory containing one of the language server's root
+// markers, starting from filePath's directory and walking up to baseDir.
+// filePath may be absolute or relative to baseDir.
+func (lspa *LSPActivities) findOrInitClient(ctx context.Context, baseDir string, filePath string, lang string) (LSPClient, error) {
 	_, span := lspTracer.Start(ctx, "findOrInitClient")
 	defer span.End()
 	span.SetAttributes(attribute.String("language", lang), attribute.String("baseDir", baseDir))
 
-	key := baseDir + ":" + lang
+	rootDir := findRootDir(baseDir, filePath, languageServerRootMarkers(lang, loadLanguageServerOverrides()))
+	span.SetAttributes(attribute.String("rootDir", rootDir))
+
+	key := rootDir + ":" + lang
 
-	// init lsp client once per baseDir and lang
+	// init lsp client once per root dir and lang
 	locker, _ := lspa.InitializationLockers.LoadOrStore(key, &sync.Mutex{})
 	locker.(*sync.Mutex).Lock()
 	defer locker.(*sync.Mutex).Unlock()
@@ -92,9 +104,9 @@ func (lspa *LSPActivities) findOrInitClient(ctx context.Context, baseDir string,
 		span.SetAttributes(attribute.Bool("initialized", true))
 		// Initialize LSP client
 		lspClient = lspa.LSPClientProvider(lang)
-		rootUri, err := url.Parse("file://" + baseDir)
+		rootUri, err := url.Parse("file://" + rootDir)
 		if err != nil {
-			err = fmt.Errorf("failed to parse rootUri file://%s: %w", baseDir, err)
+			err = fmt.Errorf("failed to parse rootUri file://%s: %w", rootDir, err)
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
 			return nil, err
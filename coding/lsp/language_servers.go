@@ -0,0 +1,223 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sidekick/common"
+
+	"github.com/rs/zerolog/log"
+)
+
+var typescriptLanguageServer = common.LanguageServerConfig{
+	Command:     "typescript-language-server",
+	Args:        []string{"--stdio"},
+	RootMarkers: []string{"tsconfig.json", "jsconfig.json", "package.json"},
+}
+
+var pythonRootMarkers = []string{"pyproject.toml", "setup.py", "setup.cfg", "pyrightconfig.json", "requirements.txt"}
+
+var jvmRootMarkers = []string{"settings.gradle", "settings.gradle.kts", "pom.xml", "build.gradle", "build.gradle.kts"}
+
+// builtinLanguageServers lists the language servers supported out of the box,
+// keyed by language name as returned by utils.InferLanguageNameFromFilePath.
+// When there are multiple servers for a language, the first one that is
+// installed is used.
+var builtinLanguageServers = map[string][]common.LanguageServerConfig{
+	"golang": {
+		{
+			Command: "gopls",
+			Args:    []string{"-remote=auto", "-logfile=auto", "-debug=:0", "-remote.debug=:0", "-rpc.trace", "-remote.listen.timeout=0"},
+		},
+	},
+	"typescript": {typescriptLanguageServer},
+	"tsx":        {typescriptLanguageServer},
+	"javascript": {typescriptLanguageServer},
+	"jsx":        {typescriptLanguageServer},
+	"python": {
+		{
+			Command:     "pyright-langserver",
+			Args:        []string{"--stdio"},
+			RootMarkers: pythonRootMarkers,
+		},
+		{
+			Command:     "pylsp",
+			RootMarkers: pythonRootMarkers,
+		},
+	},
+	"java": {
+		{
+			Command:     "jdtls",
+			RootMarkers: jvmRootMarkers,
+		},
+	},
+	"kotlin": {
+		{
+			Command:     "kotlin-language-server",
+			RootMarkers: jvmRootMarkers,
+		},
+	},
+}
+
+// lspLanguageIds maps the language names returned by
+// utils.InferLanguageNameFromFilePath to LSP language identifiers, where they
+// differ.
+var lspLanguageIds = map[string]string{
+	"golang": "go",
+	"tsx":    "typescriptreact",
+	"jsx":    "javascriptreact",
+}
+
+// lspLanguageId returns the LSP language identifier for the given language.
+func lspLanguageId(languageName string) string {
+	if id, ok := lspLanguageIds[languageName]; ok {
+		return id
+	}
+	return languageName
+}
+
+// languageServerCandidates returns the language servers to try for the given
+// language, in order of preference, with any override applied.
+func languageServerCandidates(languageName string, overrides map[string]common.LanguageServerConfig) []common.LanguageServerConfig {
+	candidates := builtinLanguageServers[languageName]
+	override, ok := overrides[languageName]
+	if !ok {
+		return candidates
+	}
+	if override.Command != "" {
+		return []common.LanguageServerConfig{override}
+	}
+
+	merged := make([]common.LanguageServerConfig, 0, len(candidates))
+	for _, candidate := range candidates {
+		if override.Args != nil {
+			candidate.Args = override.Args
+		}
+		if override.InitializationOptions != nil {
+			candidate.InitializationOptions = override.InitializationOptions
+		}
+		if override.RootMarkers != nil {
+			candidate.RootMarkers = override.RootMarkers
+		}
+		merged = append(merged, candidate)
+	}
+	return merged
+}
+
+// resolveLanguageServer picks the first installed language server for the
+// given language. ErrUnsupportedLanguage is returned when there is no
+// language server configured for the language or none of them are installed.
+func resolveLanguageServer(languageName string, overrides map[string]common.LanguageServerConfig) (common.LanguageServerConfig, error) {
+	candidates := languageServerCandidates(languageName, overrides)
+	if len(candidates) == 0 {
+		return common.LanguageServerConfig{}, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, languageName)
+	}
+
+	commands := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Command == "gopls" {
+			goplsPath, err := common.FindOrInstallGopls()
+			if err != nil {
+				return common.LanguageServerConfig{}, fmt.Errorf("failed to find or install gopls: %w", err)
+			}
+			candidate.Command = goplsPath
+			return candidate, nil
+		}
+		if _, err := exec.LookPath(candidate.Command); err == nil {
+			return candidate, nil
+		}
+		commands = append(commands, candidate.Command)
+	}
+
+	return common.LanguageServerConfig{}, fmt.Errorf("%w: %s: no language server found in PATH, tried: %s", ErrUnsupportedLanguage, languageName, strings.Join(commands, ", "))
+}
+
+// languageServerRootMarkers returns the root markers configured for the
+// preferred language server of the given language, without checking whether
+// it is installed.
+func languageServerRootMarkers(languageName string, overrides map[string]common.LanguageServerConfig) []string {
+	candidates := languageServerCandidates(languageName, overrides)
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[0].RootMarkers
+}
+
+// findRootDir returns the nearest directory containing one of the root
+// markers, starting at the directory of the given file and walking up to, and
+// including, baseDir. baseDir is returned if no marker is found or the file is
+// outside of baseDir.
+func findRootDir(baseDir, filePath string, rootMarkers []string) string {
+	if len(rootMarkers) == 0 || filePath == "" {
+		return baseDir
+	}
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(baseDir, filePath)
+	}
+
+	cleanBaseDir := filepath.Clean(baseDir)
+	dir := filepath.Dir(filepath.Clean(filePath))
+	if rel, err := filepath.Rel(cleanBaseDir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return baseDir
+	}
+
+	for {
+		for _, marker := range rootMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		if dir == cleanBaseDir {
+			return baseDir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return baseDir
+		}
+		dir = parent
+	}
+}
+
+// languageServerOverridesCache holds the overrides last loaded from the local
+// config, so that it's only re-read once the file changes.
+var languageServerOverridesCache struct {
+	sync.Mutex
+	path      string
+	modTime   time.Time
+	overrides map[string]common.LanguageServerConfig
+}
+
+// loadLanguageServerOverrides reads language server overrides from the local
+// sidekick config. An invalid config is logged and ignored, leaving only the
+// builtin language servers, so that it doesn't break every LSP feature.
+func loadLanguageServerOverrides() map[string]common.LanguageServerConfig {
+	return loadLanguageServerOverridesFrom(common.GetSidekickConfigPath())
+}
+
+func loadLanguageServerOverridesFrom(configPath string) map[string]common.LanguageServerConfig {
+	var modTime time.Time
+	if info, err := os.Stat(configPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	cache := &languageServerOverridesCache
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.path == configPath && cache.modTime.Equal(modTime) {
+		return cache.overrides
+	}
+
+	localConfig, err := common.LoadSidekickConfig(configPath)
+	if err != nil {
+		log.Warn().Err(err).Str("path", configPath).Msg("Failed to load language server overrides from local config; using builtin language servers")
+	}
+	cache.path = configPath
+	cache.modTime = modTime
+	cache.overrides = localConfig.LanguageServers
+	return cache.overrides
+}
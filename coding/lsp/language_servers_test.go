@@ -0,0 +1,163 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sidekick/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFakeExecutable(t *testing.T, dir, name string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755))
+}
+
+func TestLanguageServerCandidates(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		candidates := languageServerCandidates("python", nil)
+		require.Len(t, candidates, 2)
+		assert.Equal(t, "pyright-langserver", candidates[0].Command)
+		assert.Equal(t, "pylsp", candidates[1].Command)
+	})
+
+	t.Run("override with command replaces builtin", func(t *testing.T) {
+		overrides := map[string]common.LanguageServerConfig{
+			"python": {Command: "jedi-language-server"},
+		}
+		candidates := languageServerCandidates("python", overrides)
+		require.Len(t, candidates, 1)
+		assert.Equal(t, "jedi-language-server", candidates[0].Command)
+	})
+
+	t.Run("override without command merges into builtin", func(t *testing.T) {
+		overrides := map[string]common.LanguageServerConfig{
+			"typescript": {
+				RootMarkers:           []string{"nx.json"},
+				InitializationOptions: map[string]interface{}{"maxTsServerMemory": 4096},
+			},
+		}
+		candidates := languageServerCandidates("typescript", overrides)
+		require.Len(t, candidates, 1)
+		assert.Equal(t, "typescript-language-server", candidates[0].Command)
+		assert.Equal(t, []string{"--stdio"}, candidates[0].Args)
+		assert.Equal(t, []string{"nx.json"}, candidates[0].RootMarkers)
+		assert.Equal(t, 4096, candidates[0].InitializationOptions["maxTsServerMemory"])
+
+		// the builtin config must not be modified
+		assert.Equal(t, []string{"tsconfig.json", "jsconfig.json", "package.json"}, languageServerCandidates("typescript", nil)[0].RootMarkers)
+	})
+
+	t.Run("override for language without builtin", func(t *testing.T) {
+		overrides := map[string]common.LanguageServerConfig{
+			"vue": {Command: "vue-language-server", Args: []string{"--stdio"}},
+		}
+		candidates := languageServerCandidates("vue", overrides)
+		require.Len(t, candidates, 1)
+		assert.Equal(t, "vue-language-server", candidates[0].Command)
+	})
+}
+
+func TestResolveLanguageServer(t *testing.T) {
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+
+	t.Run("unknown language", func(t *testing.T) {
+		_, err := resolveLanguageServer("markdown", nil)
+		assert.ErrorIs(t, err, ErrUnsupportedLanguage)
+	})
+
+	t.Run("no server installed", func(t *testing.T) {
+		_, err := resolveLanguageServer("kotlin", nil)
+		assert.ErrorIs(t, err, ErrUnsupportedLanguage)
+		assert.Contains(t, err.Error(), "kotlin-language-server")
+	})
+
+	t.Run("falls back to next installed server", func(t *testing.T) {
+		writeFakeExecutable(t, binDir, "pylsp")
+		server, err := resolveLanguageServer("python", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "pylsp", server.Command)
+
+		writeFakeExecutable(t, binDir, "pyright-langserver")
+		server, err = resolveLanguageServer("python", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "pyright-langserver", server.Command)
+		assert.Equal(t, []string{"--stdio"}, server.Args)
+	})
+
+	t.Run("typescript", func(t *testing.T) {
+		writeFakeExecutable(t, binDir, "typescript-language-server")
+		for _, lang := range []string{"typescript", "tsx", "javascript", "jsx"} {
+			server, err := resolveLanguageServer(lang, nil)
+			require.NoError(t, err, lang)
+			assert.Equal(t, "typescript-language-server", server.Command)
+		}
+	})
+}
+
+func TestLoadLanguageServerOverrides(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(configPath, modTime, modTime))
+	}
+	modTime := time.Now().Add(-time.Hour)
+
+	assert.Nil(t, loadLanguageServerOverridesFrom(configPath), "missing config")
+
+	writeConfig("language_servers:\n  python:\n    command: pylsp\n", modTime)
+	overrides := loadLanguageServerOverridesFrom(configPath)
+	assert.Equal(t, "pylsp", overrides["python"].Command)
+
+	// unchanged files aren't re-read
+	writeConfig("language_servers:\n  python:\n    command: pyright-langserver\n", modTime)
+	overrides = loadLanguageServerOverridesFrom(configPath)
+	assert.Equal(t, "pylsp", overrides["python"].Command)
+
+	modTime = modTime.Add(time.Minute)
+	writeConfig("language_servers: [", modTime)
+	assert.Nil(t, loadLanguageServerOverridesFrom(configPath), "invalid config falls back to builtin servers")
+
+	modTime = modTime.Add(time.Minute)
+	writeConfig("language_servers:\n  python:\n    command: pyright-langserver\n", modTime)
+	overrides = loadLanguageServerOverridesFrom(configPath)
+	assert.Equal(t, "pyright-langserver", overrides["python"].Command)
+}
+
+func TestFindRootDir(t *testing.T) {
+	baseDir := t.TempDir()
+	pkgDir := filepath.Join(baseDir, "packages", "web")
+	srcDir := filepath.Join(pkgDir, "src", "components")
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "package.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "tsconfig.json"), []byte("{}"), 0644))
+
+	markers := []string{"tsconfig.json", "package.json"}
+
+	tests := []struct {
+		name     string
+		filePath string
+		markers  []string
+		expected string
+	}{
+		{"nearest marker for relative path", "packages/web/src/components/Button.tsx", markers, pkgDir},
+		{"nearest marker for absolute path", filepath.Join(srcDir, "Button.tsx"), markers, pkgDir},
+		{"marker in base dir", "scripts/build.ts", markers, baseDir},
+		{"no markers", "packages/web/src/components/Button.tsx", nil, baseDir},
+		{"no matching marker", "packages/web/src/index.ts", []string{"deno.json"}, baseDir},
+		{"file outside base dir", filepath.Join(filepath.Dir(baseDir), "other", "index.ts"), markers, baseDir},
+		{"empty file path", "", markers, baseDir},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, findRootDir(baseDir, tt.filePath, tt.markers))
+		})
+	}
+}
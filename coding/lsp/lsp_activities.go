@@ -3,6 +3,7 @@ package lsp
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"os"
 	"path"
 	"sidekick/utils"
@@ -51,18 +52,28 @@ func (la *LSPActivities) GetSymbolDefinitionLocations(ctx context.Context, reque
 
 func (la *LSPActivities) GetSingleFileDefinitions(ctx context.Context, request LSPDefinitionLocationsRequest) ([]SymbolDefinitionLocation, error) {
 	// Step 1: Find the Position of each symbol in the file.
-	positions, err := findSymbolPositions(ctx, request.FilePath, request.Symbols)
+	positions, err := findSymbolPositions(ctx, path.Join(request.RepoDir, request.FilePath), request.Symbols)
 	if err != nil {
 		return []SymbolDefinitionLocation{}, err
 	}
 
 	// Step 2: Initialize the lsp client and invoke its TextDocumentDefinition function to get the definition of each symbol.
 	langName := utils.InferLanguageNameFromFilePath(request.FilePath)
-	lspClient, err := la.findOrInitClient(ctx, request.RepoDir, langName)
+	lspClient, err := la.findOrInitClient(ctx, request.RepoDir, request.FilePath, langName)
 	if err != nil {
 		return []SymbolDefinitionLocation{}, err
 	}
 	fileURI := convertFilePathToURI(request.RepoDir, request.FilePath)
+	content, err := os.ReadFile(path.Join(request.RepoDir, request.FilePath))
+	if err != nil {
+		return []SymbolDefinitionLocation{}, err
+	}
+	closeDocument, err := openDocument(ctx, lspClient, fileURI, langName, string(content))
+	if err != nil {
+		return []SymbolDefinitionLocation{}, err
+	}
+	defer closeDocument()
+
 	symbolDefinitions := make([]SymbolDefinitionLocation, 0, len(positions))
 	for _, position := range positions {
 		locations, err := lspClient.TextDocumentDefinition(ctx, fileURI, position.Line, position.Character)
@@ -130,6 +141,45 @@ func convertFilePathToURI(repoDir, filePath string) string {
 	return "file://" + path.Join(repoDir, filePath)
 }
 
+// supportsOpenClose reports whether the server wants didOpen and didClose
+// notifications
+func supportsOpenClose(capabilities ServerCapabilities) bool {
+	syncOptions, ok := capabilities.TextDocumentSync.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	openClose, ok := syncOptions["openClose"].(bool)
+	return ok && openClose
+}
+
+// openDocument sends a didOpen notification with the given content before
+// requests about the document, as some language servers (eg tsserver and
+// pyright) don't answer requests about documents that aren't open. The
+// returned function sends the matching didClose notification.
+func openDocument(ctx context.Context, lspClient LSPClient, uri string, langName string, text string) (func(), error) {
+	if !supportsOpenClose(lspClient.GetServerCapabilities()) {
+		return func() {}, nil
+	}
+
+	err := lspClient.TextDocumentDidOpen(ctx, DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:        uri,
+			LanguageID: lspLanguageId(langName),
+			Version:    1,
+			Text:       text,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document %s: %w", uri, err)
+	}
+
+	return func() {
+		_ = lspClient.TextDocumentDidClose(ctx, DidCloseTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+	}, nil
+}
+
 // TextDocumentDidOpenActivityInput represents input for the TextDocumentDidOpen notification.
 type TextDocumentDidOpenActivityInput struct {
 	RepoDir    string `json:"repo_dir"`
@@ -142,28 +192,24 @@ type TextDocumentDidOpenActivityInput struct {
 // TextDocumentDidOpenActivity sends a textDocument/didOpen notification to the LSP server.
 func (lspa *LSPActivities) TextDocumentDidOpenActivity(ctx context.Context, input TextDocumentDidOpenActivityInput) error {
 	langName := utils.InferLanguageNameFromFilePath(input.FilePath)
-	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, langName)
+	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, input.FilePath, langName)
 	if err != nil {
 		return err
 	}
 
-	// Check if server supports open/close notifications
-	capabilities := lspClient.GetServerCapabilities()
-	if syncOptions, ok := capabilities.TextDocumentSync.(map[string]interface{}); ok {
-		if openClose, exists := syncOptions["openClose"]; exists {
-			if openCloseBool, ok := openClose.(bool); ok && !openCloseBool {
-				return nil // Server doesn't support open/close notifications
-			}
-		} else {
-			return nil // Server doesn't support open/close notifications
-		}
+	if !supportsOpenClose(lspClient.GetServerCapabilities()) {
+		return nil
 	}
 
+	languageId := input.LanguageID
+	if languageId == "" {
+		languageId = lspLanguageId(langName)
+	}
 	fileURI := convertFilePathToURI(input.RepoDir, input.FilePath)
 	params := DidOpenTextDocumentParams{
 		TextDocument: TextDocumentItem{
 			URI:        fileURI,
-			LanguageID: input.LanguageID,
+			LanguageID: languageId,
 			Version:    input.Version,
 			Text:       input.Text,
 		},
@@ -181,21 +227,13 @@ type TextDocumentDidCloseActivityInput struct {
 // TextDocumentDidCloseActivity sends a textDocument/didClose notification to the LSP server.
 func (lspa *LSPActivities) TextDocumentDidCloseActivity(ctx context.Context, input TextDocumentDidCloseActivityInput) error {
 	langName := utils.InferLanguageNameFromFilePath(input.FilePath)
-	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, langName)
+	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, input.FilePath, langName)
 	if err != nil {
 		return err
 	}
 
-	// Check if server supports open/close notifications
-	capabilities := lspClient.GetServerCapabilities()
-	if syncOptions, ok := capabilities.TextDocumentSync.(map[string]interface{}); ok {
-		if openClose, exists := syncOptions["openClose"]; exists {
-			if openCloseBool, ok := openClose.(bool); ok && !openCloseBool {
-				return nil // Server doesn't support open/close notifications
-			}
-		} else {
-			return nil // Server doesn't support open/close notifications
-		}
+	if !supportsOpenClose(lspClient.GetServerCapabilities()) {
+		return nil
 	}
 
 	fileURI := convertFilePathToURI(input.RepoDir, input.FilePath)
@@ -219,7 +257,7 @@ type TextDocumentDidChangeActivityInput struct {
 // TextDocumentDidChangeActivity sends a textDocument/didChange notification to the LSP server.
 func (lspa *LSPActivities) TextDocumentDidChangeActivity(ctx context.Context, input TextDocumentDidChangeActivityInput) error {
 	langName := utils.InferLanguageNameFromFilePath(input.FilePath)
-	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, langName)
+	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, input.FilePath, langName)
 	if err != nil {
 		return err
 	}
@@ -260,7 +298,7 @@ type TextDocumentDidSaveActivityInput struct {
 // TextDocumentDidSaveActivity sends a textDocument/didSave notification to the LSP server.
 func (lspa *LSPActivities) TextDocumentDidSaveActivity(ctx context.Context, input TextDocumentDidSaveActivityInput) error {
 	langName := utils.InferLanguageNameFromFilePath(input.FilePath)
-	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, langName)
+	lspClient, err := lspa.findOrInitClient(ctx, input.RepoDir, input.FilePath, langName)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,154 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"sidekick/env"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLanguageServer records the methods it receives, in order, and answers
+// requests with empty results, like language servers that only know about
+// open documents
+type fakeLanguageServer struct {
+	mu          sync.Mutex
+	methods     []string
+	languageIds []string
+}
+
+func (s *fakeLanguageServer) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	s.mu.Lock()
+	s.methods = append(s.methods, req.Method)
+	if req.Method == "textDocument/didOpen" && req.Params != nil {
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			s.languageIds = append(s.languageIds, params.TextDocument.LanguageID)
+		}
+	}
+	s.mu.Unlock()
+
+	if req.Notif {
+		return
+	}
+	var result interface{} = []interface{}{}
+	if req.Method == "initialize" {
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{"openClose": true, "change": 1},
+			},
+		}
+	}
+	_ = conn.Reply(ctx, req.ID, result)
+}
+
+func (s *fakeLanguageServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.methods...)
+}
+
+func newFakeLanguageServerActivities(t *testing.T, server *fakeLanguageServer) *LSPActivities {
+	return NewLSPActivities(func(language string) LSPClient {
+		serverSide, clientSide := net.Pipe()
+		serverConn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}), server)
+		clientConn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), noopHandler{})
+		t.Cleanup(func() {
+			clientConn.Close()
+			serverConn.Close()
+		})
+		return &Jsonrpc2LSPClient{Conn: clientConn, LanguageName: language}
+	})
+}
+
+func TestLSPActivities_OpenDocumentsBeforeRequests(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.tsx"), []byte("export function App() {\n  return App\n}\n"), 0644))
+	devEnv, err := env.NewLocalEnv(context.Background(), env.LocalEnvParams{RepoDir: dir})
+	require.NoError(t, err)
+	envContainer := env.EnvContainer{Env: devEnv}
+
+	tests := []struct {
+		name    string
+		request string
+		call    func(lspa *LSPActivities) error
+	}{
+		{
+			name:    "definition",
+			request: "textDocument/definition",
+			call: func(lspa *LSPActivities) error {
+				_, err := lspa.GetSingleFileDefinitions(context.Background(), LSPDefinitionLocationsRequest{
+					RepoDir:  dir,
+					FilePath: "app.tsx",
+					Symbols:  []string{"App"},
+				})
+				return err
+			},
+		},
+		{
+			name:    "references",
+			request: "textDocument/references",
+			call: func(lspa *LSPActivities) error {
+				_, err := lspa.FindReferencesActivity(context.Background(), FindReferencesActivityInput{
+					EnvContainer:     envContainer,
+					RelativeFilePath: "app.tsx",
+					SymbolText:       "App",
+				})
+				return err
+			},
+		},
+		{
+			name:    "code actions",
+			request: "textDocument/codeAction",
+			call: func(lspa *LSPActivities) error {
+				_, err := lspa.AutofixActivity(context.Background(), AutofixActivityInput{
+					EnvContainer: envContainer,
+					DocumentURI:  "file://" + filepath.Join(dir, "app.tsx"),
+				})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeLanguageServer{}
+			lspa := newFakeLanguageServerActivities(t, server)
+
+			require.NoError(t, tt.call(lspa))
+
+			// didClose is a notification, so it may arrive after the call returns
+			assert.Eventually(t, func() bool {
+				methods := server.received()
+				return len(methods) > 0 && methods[len(methods)-1] == "textDocument/didClose"
+			}, time.Second, 10*time.Millisecond)
+
+			methods := server.received()
+			require.GreaterOrEqual(t, len(methods), 5)
+			assert.Equal(t, []string{"initialize", "initialized", "textDocument/didOpen"}, methods[:3])
+			for _, method := range methods[3 : len(methods)-1] {
+				assert.Equal(t, tt.request, method)
+			}
+			assert.Equal(t, []string{"typescriptreact"}, server.languageIds)
+		})
+	}
+}
+
+func TestLspLanguageId(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "go", lspLanguageId("golang"))
+	assert.Equal(t, "typescriptreact", lspLanguageId("tsx"))
+	assert.Equal(t, "javascriptreact", lspLanguageId("jsx"))
+	assert.Equal(t, "python", lspLanguageId("python"))
+}
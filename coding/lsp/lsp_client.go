@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -51,18 +52,8 @@ func (rwc *ReadWriteCloser) Close() error {
 
 var ErrUnsupportedLanguage = errors.New("unsupported language")
 
-func lspServerStdioReadWriteCloser(languageName string) (*ReadWriteCloser, error) {
-	var cmd *exec.Cmd
-	switch languageName {
-	case "golang":
-		goplsPath, err := common.FindOrInstallGopls()
-		if err != nil {
-			return nil, fmt.Errorf("failed to find or install gopls: %w", err)
-		}
-		cmd = exec.Command(goplsPath, "-remote=auto", "-logfile=auto", "-debug=:0", "-remote.debug=:0", "-rpc.trace", "-remote.listen.timeout=0")
-	default:
-		return nil, fmt.Errorf("%v: %s", ErrUnsupportedLanguage, languageName)
-	}
+func lspServerStdioReadWriteCloser(server common.LanguageServerConfig) (*ReadWriteCloser, error) {
+	cmd := exec.Command(server.Command, server.Args...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("cmd.StdinPipe() failed: %v", err)
@@ -81,21 +72,49 @@ type noopHandler struct{}
 
 func (noopHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	//fmt.Printf("\nnoopHandler.Handle called with req: %v\n", req)
+	if req.Notif {
+		return
+	}
+
+	// some language servers (eg pyright, jdtls) wait on replies to their own
+	// requests, so we reply with empty results rather than leaving them hanging
+	var result interface{}
+	if req.Method == "workspace/configuration" && req.Params != nil {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			result = make([]interface{}, len(params.Items))
+		}
+	}
+	_ = conn.Reply(ctx, req.ID, result)
 }
 
-// TODO make the ReadWriteCloser a parameter, so that multiple different language servers can be supported
+// Initialize starts the language server for the client's language and
+// initializes it. When Conn is already set, the server it's connected to is
+// initialized instead.
 func (l *Jsonrpc2LSPClient) Initialize(ctx context.Context, params InitializeParams) (InitializeResponse, error) {
-	// start lsp server (if needed) and connect to it
-	rwc, err := lspServerStdioReadWriteCloser(l.LanguageName)
-	if err != nil {
-		return InitializeResponse{}, fmt.Errorf("gopls failure: %v", err)
+	if l.Conn == nil {
+		server, err := resolveLanguageServer(l.LanguageName, loadLanguageServerOverrides())
+		if err != nil {
+			return InitializeResponse{}, err
+		}
+		if params.InitializationOptions == nil {
+			params.InitializationOptions = server.InitializationOptions
+		}
+
+		// start lsp server (if needed) and connect to it
+		rwc, err := lspServerStdioReadWriteCloser(server)
+		if err != nil {
+			return InitializeResponse{}, fmt.Errorf("%s failure: %w", server.Command, err)
+		}
+		// Setup JSON-RPC 2.0 connection
+		(*l).Conn = jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{}), &noopHandler{})
 	}
-	// Setup JSON-RPC 2.0 connection
-	(*l).Conn = jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{}), &noopHandler{})
 
 	// Send request and handle response
 	var resp InitializeResponse
-	err = l.Conn.Call(ctx, "initialize", &params, &resp)
+	err := l.Conn.Call(ctx, "initialize", &params, &resp)
 	if err != nil {
 		return InitializeResponse{}, fmt.Errorf("initialize call failed: %v", err)
 	}
@@ -104,7 +123,7 @@ func (l *Jsonrpc2LSPClient) Initialize(ctx context.Context, params InitializePar
 	l.ServerCapabilities = resp.Capabilities
 
 	// let the LSP server know that we initialized successfully
-	err = l.Conn.Call(ctx, "initialized", &map[string]string{}, &map[string]string{})
+	err = l.Conn.Notify(ctx, "initialized", &map[string]string{})
 	if err != nil {
 		return InitializeResponse{}, fmt.Errorf("initialized response failed: %v", err)
 	}
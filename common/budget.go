@@ -0,0 +1,91 @@
+package common
+
+import "fmt"
+
+// BudgetConfig limits how much LLM spend a flow may incur before it pauses to
+// ask the user whether to continue. Zero values mean no limit. Daily limits
+// apply to the total spend of all tasks in the workspace during the current
+// UTC day.
+type BudgetConfig struct {
+	MaxTaskCost    float64 `toml:"max_task_cost,omitempty" json:"maxTaskCost,omitempty"`
+	MaxTaskTokens  int     `toml:"max_task_tokens,omitempty" json:"maxTaskTokens,omitempty"`
+	MaxDailyCost   float64 `toml:"max_daily_cost,omitempty" json:"maxDailyCost,omitempty"`
+	MaxDailyTokens int     `toml:"max_daily_tokens,omitempty" json:"maxDailyTokens,omitempty"`
+}
+
+// IsEnabled returns true if any budget limit is set.
+func (b BudgetConfig) IsEnabled() bool {
+	return b.MaxTaskCost > 0 || b.MaxTaskTokens > 0 || b.MaxDailyCost > 0 || b.MaxDailyTokens > 0
+}
+
+// BudgetLimitType identifies one of the limits in a BudgetConfig.
+type BudgetLimitType string
+
+const (
+	BudgetLimitTaskCost    BudgetLimitType = "task_cost"
+	BudgetLimitTaskTokens  BudgetLimitType = "task_tokens"
+	BudgetLimitDailyCost   BudgetLimitType = "daily_cost"
+	BudgetLimitDailyTokens BudgetLimitType = "daily_tokens"
+)
+
+// ExceededBudgetLimit describes a budget limit that spend has reached.
+type ExceededBudgetLimit struct {
+	Type  BudgetLimitType `json:"type"`
+	Limit float64         `json:"limit"`
+	Spent float64         `json:"spent"`
+}
+
+func (e ExceededBudgetLimit) String() string {
+	switch e.Type {
+	case BudgetLimitTaskCost:
+		return fmt.Sprintf("task cost $%.2f has reached the limit of $%.2f", e.Spent, e.Limit)
+	case BudgetLimitTaskTokens:
+		return fmt.Sprintf("task token usage %.0f has reached the limit of %.0f", e.Spent, e.Limit)
+	case BudgetLimitDailyCost:
+		return fmt.Sprintf("today's cost $%.2f has reached the daily limit of $%.2f", e.Spent, e.Limit)
+	case BudgetLimitDailyTokens:
+		return fmt.Sprintf("today's token usage %.0f has reached the daily limit of %.0f", e.Spent, e.Limit)
+	default:
+		return fmt.Sprintf("%s %v has reached the limit of %v", e.Type, e.Spent, e.Limit)
+	}
+}
+
+// ExceededLimits returns the limits that the given task and daily usage have
+// reached, in a stable order.
+func (b BudgetConfig) ExceededLimits(taskUsage, dailyUsage LlmUsage) []ExceededBudgetLimit {
+	var exceeded []ExceededBudgetLimit
+	if b.MaxTaskCost > 0 && taskUsage.Cost >= b.MaxTaskCost {
+		exceeded = append(exceeded, ExceededBudgetLimit{Type: BudgetLimitTaskCost, Limit: b.MaxTaskCost, Spent: taskUsage.Cost})
+	}
+	if b.MaxTaskTokens > 0 && taskUsage.TotalTokens() >= b.MaxTaskTokens {
+		exceeded = append(exceeded, ExceededBudgetLimit{Type: BudgetLimitTaskTokens, Limit: float64(b.MaxTaskTokens), Spent: float64(taskUsage.TotalTokens())})
+	}
+	if b.MaxDailyCost > 0 && dailyUsage.Cost >= b.MaxDailyCost {
+		exceeded = append(exceeded, ExceededBudgetLimit{Type: BudgetLimitDailyCost, Limit: b.MaxDailyCost, Spent: dailyUsage.Cost})
+	}
+	if b.MaxDailyTokens > 0 && dailyUsage.TotalTokens() >= b.MaxDailyTokens {
+		exceeded = append(exceeded, ExceededBudgetLimit{Type: BudgetLimitDailyTokens, Limit: float64(b.MaxDailyTokens), Spent: float64(dailyUsage.TotalTokens())})
+	}
+	return exceeded
+}
+
+// Raise returns a copy of the budget where each of the exceeded limits is
+// raised by its originally configured amount on top of what has been spent,
+// so that the flow can make roughly as much progress again before the next
+// check trips.
+func (b BudgetConfig) Raise(original BudgetConfig, exceeded []ExceededBudgetLimit) BudgetConfig {
+	raised := b
+	for _, e := range exceeded {
+		switch e.Type {
+		case BudgetLimitTaskCost:
+			raised.MaxTaskCost = e.Spent + original.MaxTaskCost
+		case BudgetLimitTaskTokens:
+			raised.MaxTaskTokens = int(e.Spent) + original.MaxTaskTokens
+		case BudgetLimitDailyCost:
+			raised.MaxDailyCost = e.Spent + original.MaxDailyCost
+		case BudgetLimitDailyTokens:
+			raised.MaxDailyTokens = int(e.Spent) + original.MaxDailyTokens
+		}
+	}
+	return raised
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetConfig_IsEnabled(t *testing.T) {
+	t.Parallel()
+	assert.False(t, BudgetConfig{}.IsEnabled())
+	assert.True(t, BudgetConfig{MaxTaskCost: 1}.IsEnabled())
+	assert.True(t, BudgetConfig{MaxDailyTokens: 1}.IsEnabled())
+}
+
+func TestBudgetConfig_ExceededLimits(t *testing.T) {
+	t.Parallel()
+	budget := BudgetConfig{
+		MaxTaskCost:    1,
+		MaxTaskTokens:  1000,
+		MaxDailyCost:   10,
+		MaxDailyTokens: 100000,
+	}
+
+	t.Run("under budget", func(t *testing.T) {
+		t.Parallel()
+		exceeded := budget.ExceededLimits(
+			LlmUsage{InputTokens: 500, Cost: 0.5},
+			LlmUsage{InputTokens: 5000, Cost: 5},
+		)
+		assert.Empty(t, exceeded)
+	})
+
+	t.Run("task and daily limits reached", func(t *testing.T) {
+		t.Parallel()
+		exceeded := budget.ExceededLimits(
+			LlmUsage{InputTokens: 900, OutputTokens: 100, Cost: 0.5},
+			LlmUsage{InputTokens: 5000, Cost: 12},
+		)
+		require.Len(t, exceeded, 2)
+		assert.Equal(t, ExceededBudgetLimit{Type: BudgetLimitTaskTokens, Limit: 1000, Spent: 1000}, exceeded[0])
+		assert.Equal(t, ExceededBudgetLimit{Type: BudgetLimitDailyCost, Limit: 10, Spent: 12}, exceeded[1])
+	})
+
+	t.Run("zero limits are ignored", func(t *testing.T) {
+		t.Parallel()
+		exceeded := BudgetConfig{}.ExceededLimits(
+			LlmUsage{InputTokens: 1e9, Cost: 1e9},
+			LlmUsage{InputTokens: 1e9, Cost: 1e9},
+		)
+		assert.Empty(t, exceeded)
+	})
+}
+
+func TestBudgetConfig_Raise(t *testing.T) {
+	t.Parallel()
+	original := BudgetConfig{MaxTaskCost: 2, MaxDailyTokens: 1000}
+	exceeded := []ExceededBudgetLimit{
+		{Type: BudgetLimitTaskCost, Limit: 2, Spent: 2.5},
+	}
+
+	raised := original.Raise(original, exceeded)
+
+	assert.Equal(t, 4.5, raised.MaxTaskCost)
+	assert.Equal(t, 1000, raised.MaxDailyTokens, "limits that were not exceeded are left as-is")
+}
@@ -0,0 +1,29 @@
+package common
+
+import "fmt"
+
+// ConcurrencyConfig limits how many flows run at the same time, to avoid
+// overloading the machine, hitting provider rate limits or spending too
+// quickly. Tasks started beyond a limit are queued until running flows finish
+// or start waiting on a human. Zero values mean no limit.
+type ConcurrencyConfig struct {
+	// MaxRunningFlows limits running flows across all workspaces.
+	MaxRunningFlows int `koanf:"max_running_flows,omitempty"`
+	// MaxRunningFlowsPerWorkspace limits running flows within each workspace.
+	MaxRunningFlowsPerWorkspace int `koanf:"max_running_flows_per_workspace,omitempty"`
+}
+
+// IsEnabled returns true if any concurrency limit is set.
+func (c ConcurrencyConfig) IsEnabled() bool {
+	return c.MaxRunningFlows > 0 || c.MaxRunningFlowsPerWorkspace > 0
+}
+
+func (c ConcurrencyConfig) Validate() error {
+	if c.MaxRunningFlows < 0 {
+		return fmt.Errorf("max_running_flows must not be negative")
+	}
+	if c.MaxRunningFlowsPerWorkspace < 0 {
+		return fmt.Errorf("max_running_flows_per_workspace must not be negative")
+	}
+	return nil
+}
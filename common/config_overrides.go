@@ -14,6 +14,7 @@ type ConfigOverrides struct {
 	TestCommands            *[]CommandConfig `json:"testCommands,omitempty"`
 	IntegrationTestCommands *[]CommandConfig `json:"integrationTestCommands,omitempty"`
 	WorktreeSetup           *string          `json:"worktreeSetup,omitempty"`
+	Budget                  *BudgetConfig    `json:"budget,omitempty"`
 
 	AgentConfig map[string]*AgentUseCaseConfig `json:"agentConfig,omitempty"`
 
@@ -55,6 +56,9 @@ func (o ConfigOverrides) ApplyToRepoConfig(c *RepoConfig) {
 	if o.WorktreeSetup != nil {
 		c.WorktreeSetup = *o.WorktreeSetup
 	}
+	if o.Budget != nil {
+		c.Budget = *o.Budget
+	}
 	if o.AgentConfig != nil {
 		if c.AgentConfig == nil {
 			c.AgentConfig = make(map[string]AgentUseCaseConfig)
@@ -69,6 +69,17 @@ func TestConfigOverrides_ApplyToRepoConfig(t *testing.T) {
 		assert.Equal(t, newCommands, config.CheckCommands)
 	})
 
+	t.Run("budget override", func(t *testing.T) {
+		t.Parallel()
+		config := RepoConfig{Budget: BudgetConfig{MaxTaskCost: 5, MaxDailyCost: 20}}
+		newBudget := BudgetConfig{MaxTaskTokens: 1000000}
+		overrides := ConfigOverrides{Budget: &newBudget}
+
+		overrides.ApplyToRepoConfig(&config)
+
+		assert.Equal(t, newBudget, config.Budget)
+	})
+
 	t.Run("agent config override merges with existing", func(t *testing.T) {
 		t.Parallel()
 		config := RepoConfig{
@@ -0,0 +1,26 @@
+package common
+
+// ContainerConfig configures the container used by the container environment
+// type, in which commands are run inside a docker or podman container with the
+// worktree bind-mounted into it.
+type ContainerConfig struct {
+	// Image is the container image to run, eg "golang:1.24" or a project
+	// specific dev image. Required to use the container environment.
+	Image string `toml:"image,omitempty" json:"image,omitempty"`
+
+	// Runtime is the container CLI to use, either "docker" or "podman".
+	// Defaults to whichever of these is found first in PATH, in that order.
+	Runtime string `toml:"runtime,omitempty" json:"runtime,omitempty"`
+
+	// User is passed to the runtime via --user, eg "1000:1000". Setting this to
+	// the host user avoids files created in the container being owned by root
+	// on the host. Defaults to the image's user.
+	User string `toml:"user,omitempty" json:"user,omitempty"`
+
+	// EnvVars are set for every command run in the container, as KEY=VALUE.
+	EnvVars []string `toml:"env_vars,omitempty" json:"envVars,omitempty"`
+
+	// RunArgs are extra arguments passed to the runtime's run command when
+	// creating the container, eg ["--network=none"] or additional mounts.
+	RunArgs []string `toml:"run_args,omitempty" json:"runArgs,omitempty"`
+}
@@ -0,0 +1,12 @@
+package common
+
+// CustomFlowConfig defines a flow composed from built-in steps, run in order
+// when a task is started with the custom flow type and this flow's name. See
+// the dev package for the available steps.
+type CustomFlowConfig struct {
+	Description string `toml:"description,omitempty" json:"description,omitempty"`
+
+	// Steps are the names of the steps to run, in order, eg
+	// ["prepare_code_context", "edit_code", "run_tests", "user_approval", "merge"]
+	Steps []string `toml:"steps" json:"steps"`
+}
@@ -0,0 +1,17 @@
+package common
+
+// DevPodConfig configures the DevPod workspace used by the devpod environment
+// type, in which commands are run in a DevPod workspace provisioned from the
+// flow's git worktree.
+type DevPodConfig struct {
+	// Provider is the DevPod provider to create the workspace with, eg
+	// "docker" or "kubernetes". Defaults to DevPod's default provider.
+	Provider string `toml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Binary is the devpod CLI to use. Defaults to "devpod" from PATH.
+	Binary string `toml:"binary,omitempty" json:"binary,omitempty"`
+
+	// UpArgs are extra arguments passed to "devpod up" when provisioning the
+	// workspace, eg ["--devcontainer-path", ".devcontainer/ci.json"].
+	UpArgs []string `toml:"up_args,omitempty" json:"upArgs,omitempty"`
+}
@@ -1,8 +1,60 @@
 package common
 
+import "fmt"
+
+// LocalEmbeddingProvider is the built-in provider that embeds in-process,
+// without sending content to any external API
+const LocalEmbeddingProvider = "local"
+
 type EmbeddingConfig struct {
 	Defaults       []ModelConfig            `json:"defaults"`
 	UseCaseConfigs map[string][]ModelConfig `json:"useCaseConfigs"`
+	// Retrieval configures how content is ranked, keyed by use case, with the
+	// "default" key applying to use cases without their own config
+	Retrieval map[string]RetrievalConfig `json:"retrieval,omitempty"`
+}
+
+// RetrievalMode determines which rankings are fused to rank content
+type RetrievalMode string
+
+const (
+	// RetrievalModeHybrid fuses a lexical ranking with the embedding ranking,
+	// which helps find content mentioned by name, eg functions in requirements
+	RetrievalModeHybrid RetrievalMode = "hybrid"
+	// RetrievalModeVector only ranks by embedding similarity
+	RetrievalModeVector RetrievalMode = "vector"
+)
+
+type RetrievalConfig struct {
+	// Mode defaults to hybrid when empty
+	Mode RetrievalMode `koanf:"mode" json:"mode,omitempty"`
+}
+
+func (c RetrievalConfig) Validate() error {
+	switch c.Mode {
+	case "", RetrievalModeHybrid, RetrievalModeVector:
+		return nil
+	}
+	return fmt.Errorf("invalid mode %q, must be %q or %q", c.Mode, RetrievalModeHybrid, RetrievalModeVector)
+}
+
+// Validate checks the retrieval configs
+func (c EmbeddingConfig) Validate() error {
+	for useCase, retrieval := range c.Retrieval {
+		if err := retrieval.Validate(); err != nil {
+			return fmt.Errorf("invalid retrieval config for use case %s: %w", useCase, err)
+		}
+	}
+	return nil
+}
+
+// GetRetrievalConfig returns the retrieval config for a use case, falling back
+// to the default retrieval config
+func (c EmbeddingConfig) GetRetrievalConfig(key string) RetrievalConfig {
+	if config, ok := c.Retrieval[key]; ok {
+		return config
+	}
+	return c.Retrieval[DefaultKey]
 }
 
 // GetModels returns the models for a specific use case in Embedding configuration.
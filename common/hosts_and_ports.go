@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"go.temporal.io/sdk/client"
 )
@@ -33,6 +34,17 @@ func GetServerPort() int {
 	return intPort
 }
 
+// GetServerURL returns the base URL users reach the side api server at, eg for
+// links in notifications. SIDE_SERVER_URL overrides it when the server is
+// exposed elsewhere, eg behind a reverse proxy or tunnel.
+func GetServerURL() string {
+	serverURL := os.Getenv("SIDE_SERVER_URL")
+	if serverURL != "" {
+		return strings.TrimSuffix(serverURL, "/")
+	}
+	return fmt.Sprintf("http://%s:%d", GetServerHost(), GetServerPort())
+}
+
 func GetTemporalNamespace() string {
 	temporalNamespace := os.Getenv("SIDE_TEMPORAL_NAMESPACE")
 	if temporalNamespace == "" {
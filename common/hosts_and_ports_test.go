@@ -46,3 +46,17 @@ func TestGetServerPort(t *testing.T) {
 		assert.Equal(t, 9000, port)
 	})
 }
+
+func TestGetServerURL(t *testing.T) {
+	t.Run("defaults to the server host and port", func(t *testing.T) {
+		t.Setenv("SIDE_SERVER_URL", "")
+		t.Setenv("SIDE_SERVER_HOST", "")
+		t.Setenv("SIDE_SERVER_PORT", "9000")
+		assert.Equal(t, "http://127.0.0.1:9000", GetServerURL())
+	})
+
+	t.Run("returns SIDE_SERVER_URL without a trailing slash when set", func(t *testing.T) {
+		t.Setenv("SIDE_SERVER_URL", "https://sidekick.example.com/")
+		assert.Equal(t, "https://sidekick.example.com", GetServerURL())
+	})
+}
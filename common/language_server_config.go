@@ -0,0 +1,21 @@
+package common
+
+// LanguageServerConfig describes how to launch a language server that speaks
+// LSP over stdio for a given language.
+type LanguageServerConfig struct {
+	// Command is the language server executable, looked up in PATH if not an
+	// absolute path. When empty in an override, the built-in server(s) for the
+	// language are still used, with the other fields of the override applied.
+	Command string `koanf:"command,omitempty" json:"command,omitempty"`
+	// Args are passed to the command as-is.
+	Args []string `koanf:"args,omitempty" json:"args,omitempty"`
+	// InitializationOptions are sent as the initializationOptions of the LSP
+	// initialize request.
+	InitializationOptions map[string]interface{} `koanf:"initialization_options,omitempty" json:"initializationOptions,omitempty"`
+	// RootMarkers are file names that mark the root of a project, eg
+	// "tsconfig.json" or "pyproject.toml". The nearest directory containing
+	// one of them, starting from the file being worked on and going up to the
+	// repo directory, is used as the language server's root. The repo
+	// directory itself is used when empty or when no marker is found.
+	RootMarkers []string `koanf:"root_markers,omitempty" json:"rootMarkers,omitempty"`
+}
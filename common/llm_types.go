@@ -67,6 +67,10 @@ type ChatMessageResponse struct {
 	// effort requested if the model does not support reasoning or uses a
 	// different effort enum/schema)
 	ReasoningEffort string `json:"reasoningEffort"`
+
+	// dollar cost of this response based on models.dev pricing, nil if the
+	// model could not be priced
+	Cost *float64 `json:"cost,omitempty"`
 }
 
 // InputTokens must be the total prompt tokens (cached + non-cached).
@@ -103,6 +107,11 @@ func (r *ChatMessageResponse) GetOutputTokens() int {
 	return r.Usage.OutputTokens
 }
 
+// GetLlmUsage returns the usage and cost of the call that produced this response.
+func (r *ChatMessageResponse) GetLlmUsage() LlmUsage {
+	return NewLlmUsage(r.Usage, r.Cost)
+}
+
 /* based on openai's delta format */
 type ChatMessageDelta struct {
 	Role      ChatMessageRole `json:"role"`
@@ -154,6 +163,9 @@ const (
 	OpenaiCompatibleChatProvider          ChatProvider = "openai_compatible"
 	OpenaiResponsesCompatibleChatProvider ChatProvider = "openai_responses_compatible"
 	GoogleChatProvider                    ChatProvider = "google"
+	OllamaChatProvider                    ChatProvider = "ollama"
+	BedrockChatProvider                   ChatProvider = "bedrock"
+	AzureOpenaiChatProvider               ChatProvider = "azure_openai"
 )
 
 type ToolChatProviderType string
@@ -0,0 +1,113 @@
+package common
+
+// LlmUsage is the token usage and dollar cost of one or more LLM calls. It is
+// recorded per flow action and summed up for subflows, flows and tasks.
+type LlmUsage struct {
+	Calls                 int     `json:"calls"`
+	InputTokens           int     `json:"inputTokens"`
+	OutputTokens          int     `json:"outputTokens"`
+	CacheReadInputTokens  int     `json:"cacheReadInputTokens"`
+	CacheWriteInputTokens int     `json:"cacheWriteInputTokens"`
+	Cost                  float64 `json:"cost"`
+
+	// UnpricedCalls counts calls for which no pricing was known. When it's
+	// non-zero, Cost underestimates the actual spend.
+	UnpricedCalls int `json:"unpricedCalls,omitempty"`
+}
+
+// LlmUsageReporter is implemented by LLM responses that can report the usage
+// and cost of the call that produced them.
+type LlmUsageReporter interface {
+	GetLlmUsage() LlmUsage
+}
+
+// NewLlmUsage builds the usage record for a single LLM call. A nil cost means
+// the call could not be priced.
+func NewLlmUsage(usage Usage, cost *float64) LlmUsage {
+	llmUsage := LlmUsage{
+		Calls:                 1,
+		InputTokens:           usage.InputTokens,
+		OutputTokens:          usage.OutputTokens,
+		CacheReadInputTokens:  usage.CacheReadInputTokens,
+		CacheWriteInputTokens: usage.CacheWriteInputTokens,
+	}
+	if cost == nil {
+		llmUsage.UnpricedCalls = 1
+	} else {
+		llmUsage.Cost = *cost
+	}
+	return llmUsage
+}
+
+// Add accumulates other into u.
+func (u *LlmUsage) Add(other LlmUsage) {
+	u.Calls += other.Calls
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+	u.CacheWriteInputTokens += other.CacheWriteInputTokens
+	u.Cost += other.Cost
+	u.UnpricedCalls += other.UnpricedCalls
+}
+
+// Sub returns the usage remaining after subtracting other from u.
+func (u LlmUsage) Sub(other LlmUsage) LlmUsage {
+	return LlmUsage{
+		Calls:                 u.Calls - other.Calls,
+		InputTokens:           u.InputTokens - other.InputTokens,
+		OutputTokens:          u.OutputTokens - other.OutputTokens,
+		CacheReadInputTokens:  u.CacheReadInputTokens - other.CacheReadInputTokens,
+		CacheWriteInputTokens: u.CacheWriteInputTokens - other.CacheWriteInputTokens,
+		Cost:                  u.Cost - other.Cost,
+		UnpricedCalls:         u.UnpricedCalls - other.UnpricedCalls,
+	}
+}
+
+// TotalTokens returns the sum of input and output tokens.
+func (u LlmUsage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Calculate returns the dollar cost of the given usage. models.dev prices are
+// per million tokens. Cached reads and writes fall back to the regular input
+// price when the model doesn't list a separate cache price.
+func (c Cost) Calculate(usage Usage) float64 {
+	cacheReadPrice := c.CacheRead
+	if cacheReadPrice == 0 {
+		cacheReadPrice = c.Input
+	}
+	cacheWritePrice := c.CacheWrite
+	if cacheWritePrice == 0 {
+		cacheWritePrice = c.Input
+	}
+
+	uncachedInput := usage.InputTokens - usage.CacheReadInputTokens - usage.CacheWriteInputTokens
+	if uncachedInput < 0 {
+		uncachedInput = 0
+	}
+
+	total := float64(uncachedInput)*c.Input +
+		float64(usage.CacheReadInputTokens)*cacheReadPrice +
+		float64(usage.CacheWriteInputTokens)*cacheWritePrice +
+		float64(usage.OutputTokens)*c.Output
+	return total / 1_000_000
+}
+
+// CalculateLlmCost prices usage for the given provider and model using
+// models.dev. Each candidate model name is tried in order, since the model
+// reported by a provider may be a dated variant of the one that was requested.
+// Returns nil if none of the models have pricing info.
+func CalculateLlmCost(provider string, usage Usage, models ...string) *float64 {
+	for _, model := range models {
+		if model == "" {
+			continue
+		}
+		modelInfo, _ := GetModel(provider, model)
+		if modelInfo == nil {
+			continue
+		}
+		cost := modelInfo.Cost.Calculate(usage)
+		return &cost
+	}
+	return nil
+}
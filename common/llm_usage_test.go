@@ -0,0 +1,106 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCostCalculate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cost  Cost
+		usage Usage
+		want  float64
+	}{
+		{
+			name:  "input and output only",
+			cost:  Cost{Input: 3, Output: 15},
+			usage: Usage{InputTokens: 1_000_000, OutputTokens: 100_000},
+			want:  3 + 1.5,
+		},
+		{
+			name:  "cache read and write are priced separately",
+			cost:  Cost{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+			usage: Usage{InputTokens: 1_000_000, CacheReadInputTokens: 500_000, CacheWriteInputTokens: 200_000},
+			want:  0.3*3 + 0.5*0.3 + 0.2*3.75,
+		},
+		{
+			name:  "missing cache prices fall back to input price",
+			cost:  Cost{Input: 2, Output: 8},
+			usage: Usage{InputTokens: 1_000_000, CacheReadInputTokens: 1_000_000},
+			want:  2,
+		},
+		{
+			name:  "zero usage",
+			cost:  Cost{Input: 2, Output: 8},
+			usage: Usage{},
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.cost.Calculate(tt.usage), 1e-9)
+		})
+	}
+}
+
+func TestCalculateLlmCost(t *testing.T) {
+	ClearModelsCache()
+	t.Cleanup(ClearModelsCache)
+	tempDir := t.TempDir()
+	t.Setenv("SIDE_CACHE_HOME", tempDir)
+
+	sampleData := modelsDevData{
+		"openai": ProviderInfo{
+			Models: map[string]ModelInfo{
+				"gpt-5": {Cost: Cost{Input: 1.25, Output: 10}},
+			},
+		},
+	}
+	data, err := json.Marshal(sampleData)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, modelsDevFilename), data, 0644))
+
+	usage := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+
+	t.Run("first known model is used", func(t *testing.T) {
+		cost := CalculateLlmCost("openai", usage, "gpt-5-2025-08-07", "gpt-5")
+		require.NotNil(t, cost)
+		assert.InDelta(t, 11.25, *cost, 1e-9)
+	})
+
+	t.Run("unknown model returns nil", func(t *testing.T) {
+		assert.Nil(t, CalculateLlmCost("openai", usage, "unknown-model", ""))
+	})
+}
+
+func TestLlmUsageAdd(t *testing.T) {
+	cost := 0.5
+	total := LlmUsage{}
+	total.Add(NewLlmUsage(Usage{InputTokens: 100, OutputTokens: 10, CacheReadInputTokens: 50}, &cost))
+	total.Add(NewLlmUsage(Usage{InputTokens: 200, OutputTokens: 20, CacheWriteInputTokens: 30}, nil))
+
+	assert.Equal(t, LlmUsage{
+		Calls:                 2,
+		InputTokens:           300,
+		OutputTokens:          30,
+		CacheReadInputTokens:  50,
+		CacheWriteInputTokens: 30,
+		Cost:                  0.5,
+		UnpricedCalls:         1,
+	}, total)
+	assert.Equal(t, 330, total.TotalTokens())
+}
+
+func TestLlmUsageSub(t *testing.T) {
+	total := LlmUsage{Calls: 3, InputTokens: 300, OutputTokens: 30, Cost: 1.5, UnpricedCalls: 1}
+	earlier := LlmUsage{Calls: 1, InputTokens: 100, OutputTokens: 10, Cost: 0.5}
+
+	assert.Equal(t, LlmUsage{Calls: 2, InputTokens: 200, OutputTokens: 20, Cost: 1, UnpricedCalls: 1}, total.Sub(earlier))
+}
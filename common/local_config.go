@@ -14,11 +14,24 @@ import (
 
 // LocalConfig represents the local configuration file structure
 type LocalConfig struct {
-	Providers          []ModelProviderConfig    `koanf:"providers,omitempty"`
-	LLM                map[string][]ModelConfig `koanf:"llm,omitempty"`
-	Embedding          map[string][]ModelConfig `koanf:"embedding,omitempty"`
-	CommandPermissions CommandPermissionConfig  `koanf:"command_permissions,omitempty"`
-	OffHours           OffHoursConfig           `koanf:"off_hours,omitempty"`
+	Providers []ModelProviderConfig    `koanf:"providers,omitempty"`
+	LLM       map[string][]ModelConfig `koanf:"llm,omitempty"`
+	Embedding map[string][]ModelConfig `koanf:"embedding,omitempty"`
+	// Retrieval configures how content is ranked for embedding use cases,
+	// keyed by use case
+	Retrieval          map[string]RetrievalConfig `koanf:"retrieval,omitempty"`
+	CommandPermissions CommandPermissionConfig    `koanf:"command_permissions,omitempty"`
+	OffHours           OffHoursConfig             `koanf:"off_hours,omitempty"`
+	Concurrency        ConcurrencyConfig          `koanf:"concurrency,omitempty"`
+	// LanguageServers overrides the built-in language servers, keyed by
+	// language name, eg "typescript" or "python".
+	LanguageServers map[string]LanguageServerConfig `koanf:"language_servers,omitempty"`
+	// McpServers configures MCP servers, keyed by server name, whose tools are
+	// available in all flows. Servers in a repo's config take precedence.
+	McpServers map[string]McpServerConfig `koanf:"mcp_servers,omitempty"`
+	// Notifications configures where users are notified of flows waiting on
+	// them, task status changes and flow failures
+	Notifications NotificationConfig `koanf:"notifications,omitempty"`
 }
 
 // getCustomProviderNames returns a slice of custom provider names
@@ -35,6 +48,12 @@ func (c LocalConfig) validateProvider(provider string, allowAnthropicProvider bo
 	if provider == "openai" || provider == "google" {
 		return nil
 	}
+	if provider == LocalEmbeddingProvider {
+		if allowAnthropicProvider {
+			return fmt.Errorf("local provider is only supported for embeddings")
+		}
+		return nil
+	}
 	if provider == "anthropic" {
 		if !allowAnthropicProvider {
 			return fmt.Errorf("anthropic provider is not allowed for embeddings")
@@ -76,6 +95,28 @@ func (c LocalConfig) Validate() error {
 		}
 	}
 
+	for useCase, retrieval := range c.Retrieval {
+		if err := retrieval.Validate(); err != nil {
+			return fmt.Errorf("invalid retrieval config for use case %s: %w", useCase, err)
+		}
+	}
+
+	if err := c.Concurrency.Validate(); err != nil {
+		return fmt.Errorf("invalid concurrency config: %w", err)
+	}
+
+	for name, server := range c.McpServers {
+		if err := server.Validate(); err != nil {
+			return fmt.Errorf("invalid MCP server %s: %w", name, err)
+		}
+	}
+
+	for name, sink := range c.Notifications.Sinks {
+		if err := sink.Validate(); err != nil {
+			return fmt.Errorf("invalid notification sink %s: %w", name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -13,6 +13,7 @@ type LocalPublicConfig struct {
 	LLM                LLMConfig                   `json:"llm"`
 	Embedding          EmbeddingConfig             `json:"embedding"`
 	CommandPermissions CommandPermissionConfig     `json:"commandPermissions,omitempty"`
+	McpServers         map[string]McpServerConfig  `json:"mcpServers,omitempty"`
 }
 
 // ModelProviderPublicConfig represents the model provider configuration without keys
@@ -24,6 +25,9 @@ type ModelProviderPublicConfig struct {
 	SmallLLM      string            `json:"small_llm,omitempty"`
 	AuthType      ProviderAuthType  `json:"auth_type,omitempty"`
 	CustomHeaders map[string]string `json:"custom_headers,omitempty"`
+	Region        string            `json:"region,omitempty"`
+	APIVersion    string            `json:"api_version,omitempty"`
+	Deployments   map[string]string `json:"deployments,omitempty"`
 }
 
 // GetLocalConfig loads the local configuration and converts it to a format
@@ -55,6 +59,9 @@ func GetLocalConfig() (LocalPublicConfig, error) {
 			SmallLLM:      p.SmallLLM,
 			AuthType:      NormalizeProviderAuthType(string(p.AuthType)),
 			CustomHeaders: p.CustomHeaders,
+			Region:        p.Region,
+			APIVersion:    p.APIVersion,
+			Deployments:   p.Deployments,
 		}
 	}
 
@@ -76,6 +83,7 @@ func GetLocalConfig() (LocalPublicConfig, error) {
 	// Convert map-based Embedding config to structured format
 	embeddingConfig := EmbeddingConfig{
 		UseCaseConfigs: make(map[string][]ModelConfig),
+		Retrieval:      config.Retrieval,
 	}
 	if defaults, ok := config.Embedding[DefaultKey]; ok {
 		embeddingConfig.Defaults = defaults
@@ -102,5 +110,6 @@ func GetLocalConfig() (LocalPublicConfig, error) {
 		LLM:                llmConfig,
 		Embedding:          embeddingConfig,
 		CommandPermissions: config.CommandPermissions,
+		McpServers:         config.McpServers,
 	}, nil
 }
@@ -171,6 +171,128 @@ embedding:
 		assert.Contains(t, err.Error(), "anthropic provider is not allowed for embeddings")
 	})
 
+	t.Run("local provider is only valid for embedding", func(t *testing.T) {
+		configYAML := `
+embedding:
+  defaults:
+    - provider: local
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+		config, err := LoadSidekickConfig(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, "local", config.Embedding["defaults"][0].Provider)
+
+		configYAML = `
+llm:
+  defaults:
+    - provider: local
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+		_, err = LoadSidekickConfig(configPath)
+		assert.ErrorContains(t, err, "local provider is only supported for embeddings")
+	})
+
+	t.Run("bedrock and azure openai providers", func(t *testing.T) {
+		configYAML := `
+providers:
+  - name: bedrock
+    type: bedrock
+    region: us-west-2
+    default_llm: us.anthropic.claude-sonnet-4-5-20250929-v1:0
+  - name: azure
+    type: azure_openai
+    base_url: https://example.openai.azure.com
+    api_version: 2025-04-01-preview
+    auth_type: aad
+    deployments:
+      gpt-5.2: prod-gpt52
+llm:
+  defaults:
+    - provider: azure
+      model: gpt-5.2
+    - provider: bedrock
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+		config, err := LoadSidekickConfig(configPath)
+		require.NoError(t, err)
+		require.Len(t, config.Providers, 2)
+		assert.Equal(t, "us-west-2", config.Providers[0].Region)
+		assert.Equal(t, "2025-04-01-preview", config.Providers[1].APIVersion)
+		assert.Equal(t, ProviderAuthTypeAzureAD, NormalizeProviderAuthType(string(config.Providers[1].AuthType)))
+		assert.Equal(t, map[string]string{"gpt-5.2": "prod-gpt52"}, config.Providers[1].Deployments)
+
+		configYAML = `
+providers:
+  - name: azure
+    type: azure_openai
+    api_version: 2025-04-01-preview
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+		_, err = LoadSidekickConfig(configPath)
+		assert.ErrorContains(t, err, "base_url is required for azure_openai providers")
+
+		configYAML = `
+providers:
+  - name: bedrock
+    type: bedrock
+    auth_type: azure_ad
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+		_, err = LoadSidekickConfig(configPath)
+		assert.ErrorContains(t, err, "only supported for azure_openai providers")
+	})
+
+	t.Run("notification sinks", func(t *testing.T) {
+		configYAML := `
+notifications:
+  sinks:
+    phone:
+      type: ntfy
+      url: https://ntfy.sh/my-sidekick
+      events: [request_for_user, flow_failed]
+    desktop:
+      type: command
+      command: notify-send
+      args: ["${SIDEKICK_TITLE}", "${SIDEKICK_MESSAGE}"]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+		config, err := LoadSidekickConfig(configPath)
+		require.NoError(t, err)
+		require.Len(t, config.Notifications.Sinks, 2)
+		phone := config.Notifications.Sinks["phone"]
+		assert.Equal(t, NotificationSinkNtfy, phone.Type)
+		assert.True(t, phone.Receives(NotificationEventRequestForUser))
+		assert.False(t, phone.Receives(NotificationEventTaskStatusChanged))
+		assert.Equal(t, []string{"${SIDEKICK_TITLE}", "${SIDEKICK_MESSAGE}"}, config.Notifications.Sinks["desktop"].Args)
+		assert.True(t, config.Notifications.Sinks["desktop"].Receives(NotificationEventTaskStatusChanged))
+
+		configYAML = `
+notifications:
+  sinks:
+    team:
+      type: slack
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+		_, err = LoadSidekickConfig(configPath)
+		assert.ErrorContains(t, err, "invalid notification sink team: url is required for slack sinks")
+
+		configYAML = `
+notifications:
+  sinks:
+    hook:
+      type: webhook
+      url: https://example.com/hook
+      events: [task_created]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+		_, err = LoadSidekickConfig(configPath)
+		assert.ErrorContains(t, err, `unknown event "task_created"`)
+	})
+
 	t.Run("invalid config - unknown provider", func(t *testing.T) {
 		configYAML := `
 llm:
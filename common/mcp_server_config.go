@@ -0,0 +1,53 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+)
+
+// McpServerConfig describes an MCP (Model Context Protocol) server whose tools
+// are made available to the LLM during flows. Exactly one of Command (stdio
+// transport) or URL (streamable HTTP transport) must be set.
+type McpServerConfig struct {
+	// Command is the server executable for the stdio transport, looked up in
+	// PATH if not an absolute path. It is run in the flow's working directory.
+	Command string `toml:"command,omitempty" koanf:"command,omitempty" json:"command,omitempty"`
+	// Args are passed to the command as-is.
+	Args []string `toml:"args,omitempty" koanf:"args,omitempty" json:"args,omitempty"`
+	// Env sets additional environment variables for the command. Values may
+	// reference the environment Sidekick runs with, eg "${GITHUB_TOKEN}".
+	Env map[string]string `toml:"env,omitempty" koanf:"env,omitempty" json:"env,omitempty"`
+	// URL is the endpoint of a server using the streamable HTTP transport.
+	URL string `toml:"url,omitempty" koanf:"url,omitempty" json:"url,omitempty"`
+	// Headers are sent with every HTTP request, eg for authorization. Values
+	// may reference environment variables, like Env values.
+	Headers map[string]string `toml:"headers,omitempty" koanf:"headers,omitempty" json:"headers,omitempty"`
+	// Tools limits the server's tools exposed to the LLM to the given names.
+	// All tools are exposed when empty.
+	Tools []string `toml:"tools,omitempty" koanf:"tools,omitempty" json:"tools,omitempty"`
+	// Permissions decides which tool calls are auto-approved, require approval
+	// or are denied, with patterns matched against the server's tool names
+	// rather than commands. Calls require approval when no pattern matches.
+	Permissions CommandPermissionConfig `toml:"permissions,omitempty" koanf:"permissions,omitempty" json:"permissions"`
+}
+
+// Validate ensures the server has exactly one transport configured.
+func (c McpServerConfig) Validate() error {
+	if c.Command == "" && c.URL == "" {
+		return fmt.Errorf("either command or url is required")
+	}
+	if c.Command != "" && c.URL != "" {
+		return fmt.Errorf("only one of command or url may be set")
+	}
+	return nil
+}
+
+// MergeMcpServers merges MCP server configs keyed by server name, with servers
+// in later configs replacing servers of the same name in earlier ones.
+func MergeMcpServers(configs ...map[string]McpServerConfig) map[string]McpServerConfig {
+	merged := make(map[string]McpServerConfig)
+	for _, config := range configs {
+		maps.Copy(merged, config)
+	}
+	return merged
+}
@@ -17,6 +17,11 @@ type ModelConfig struct {
 
 	// Optional service tier for provider-specific routing/prioritization.
 	ServiceTier string `koanf:"service_tier" json:"serviceTier,omitempty"`
+
+	// Fallbacks are tried in order when the provider fails with a rate limit
+	// or overload error. They are not configured directly, but filled in from
+	// the other models configured for the same use case.
+	Fallbacks []ModelConfig `koanf:"-" json:"fallbacks,omitempty" jsonschema:"-"`
 }
 
 func (c ModelConfig) NormalizedProviderName() string {
@@ -6,7 +6,7 @@ import (
 )
 
 // ValidProviderTypes are the allowed provider types for custom providers
-var ValidProviderTypes = []string{"openai", "anthropic", "openai_compatible", "google", "openai_responses_compatible"}
+var ValidProviderTypes = []string{"openai", "anthropic", "openai_compatible", "google", "openai_responses_compatible", "ollama", "bedrock", "azure_openai"}
 
 // BuiltinProviders are the providers that are built into the system
 var BuiltinProviders = []string{"openai", "anthropic", "google"}
@@ -21,6 +21,16 @@ type ModelProviderConfig struct {
 	SmallLLM      string            `koanf:"small_llm,omitempty" json:"small_llm,omitempty"`
 	AuthType      ProviderAuthType  `koanf:"auth_type,omitempty" json:"auth_type,omitempty"`
 	CustomHeaders map[string]string `koanf:"custom_headers,omitempty" json:"custom_headers,omitempty"`
+
+	// Region is the AWS region of a bedrock provider. When empty, the region
+	// is resolved from the AWS environment, e.g. AWS_REGION or the profile.
+	Region string `koanf:"region,omitempty" json:"region,omitempty"`
+	// APIVersion is the api-version query parameter sent to azure_openai
+	// providers
+	APIVersion string `koanf:"api_version,omitempty" json:"api_version,omitempty"`
+	// Deployments maps model names to azure_openai deployment names. Models
+	// without an entry are assumed to be deployed under their own name.
+	Deployments map[string]string `koanf:"deployments,omitempty" json:"deployments,omitempty"`
 }
 
 // Validate ensures the CustomProviderConfig is valid
@@ -37,8 +47,14 @@ func (c ModelProviderConfig) Validate() error {
 	if err := ValidateProviderAuthType(string(c.AuthType)); err != nil {
 		return err
 	}
-	if c.Key == "" && c.AuthType == ProviderAuthTypeAPI {
+	if c.Key == "" && c.AuthType == ProviderAuthTypeAPI && c.Type != string(BedrockChatProvider) && c.Type != string(AzureOpenaiChatProvider) {
 		return fmt.Errorf("key is required for auth type %s", c.AuthType)
 	}
+	if NormalizeProviderAuthType(string(c.AuthType)) == ProviderAuthTypeAzureAD && c.Type != string(AzureOpenaiChatProvider) {
+		return fmt.Errorf("auth type %s is only supported for azure_openai providers", ProviderAuthTypeAzureAD)
+	}
+	if c.Type == string(AzureOpenaiChatProvider) && c.BaseURL == "" {
+		return fmt.Errorf("base_url is required for azure_openai providers")
+	}
 	return nil
 }
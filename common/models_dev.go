@@ -175,20 +175,28 @@ func downloadModelsDev(cachePath string) (modelsDevData, error) {
 // exists in a different provider)
 func GetModel(provider string, model string) (*ModelInfo, bool) {
 	data, err := LoadModelsDev()
-	if err != nil {
-		return nil, false
-	}
 
 	providerLower := strings.ToLower(provider)
+	providerFound := false
 	for providerKey, providerData := range data {
 		if strings.ToLower(providerKey) == providerLower {
 			if modelData, exists := providerData.Models[model]; exists {
 				return &modelData, true
 			}
-			return nil, false
+			providerFound = true
+			break
 		}
 	}
 
+	// local models aren't on models.dev, but ollama serves their info, which
+	// also works when models.dev can't be loaded
+	if modelInfo := getOllamaProviderModel(provider, model); modelInfo != nil {
+		return modelInfo, true
+	}
+	if err != nil || providerFound {
+		return nil, false
+	}
+
 	for providerKey, providerData := range data {
 		if modelData, exists := providerData.Models[model]; exists {
 			log.Debug().
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// NotificationEvent is a kind of event users can be notified of
+type NotificationEvent = string
+
+const (
+	// NotificationEventRequestForUser fires when a flow waits on a user's
+	// response, eg an approval or guidance request
+	NotificationEventRequestForUser NotificationEvent = "request_for_user"
+	// NotificationEventTaskStatusChanged fires when an existing task's status
+	// changes, eg to in_review or complete
+	NotificationEventTaskStatusChanged NotificationEvent = "task_status_changed"
+	// NotificationEventFlowFailed fires when a flow fails
+	NotificationEventFlowFailed NotificationEvent = "flow_failed"
+)
+
+var AllNotificationEvents = []NotificationEvent{
+	NotificationEventRequestForUser,
+	NotificationEventTaskStatusChanged,
+	NotificationEventFlowFailed,
+}
+
+// NotificationSinkType is the kind of destination notifications are sent to
+type NotificationSinkType = string
+
+const (
+	// NotificationSinkWebhook posts the event as JSON, signed with an HMAC of
+	// the body when a secret is configured
+	NotificationSinkWebhook NotificationSinkType = "webhook"
+	// NotificationSinkSlack posts a message to a Slack-compatible incoming
+	// webhook
+	NotificationSinkSlack NotificationSinkType = "slack"
+	// NotificationSinkNtfy publishes a message to an ntfy topic URL
+	NotificationSinkNtfy NotificationSinkType = "ntfy"
+	// NotificationSinkCommand runs a command, eg to show a desktop notification
+	NotificationSinkCommand NotificationSinkType = "command"
+)
+
+// NotificationConfig configures where users are notified of events that need
+// their attention, so that flows don't stall unnoticed when nobody has
+// Sidekick open.
+type NotificationConfig struct {
+	// Sinks are the destinations notifications are sent to, keyed by name
+	Sinks map[string]NotificationSinkConfig `toml:"sinks,omitempty" koanf:"sinks,omitempty" json:"sinks,omitempty"`
+	// CallbackSecret enables answering requests for user without opening
+	// Sidekick: request_for_user events then include a one-time callback URL
+	// that accepts replies signed with this secret. It may reference the
+	// environment, eg "${SIDEKICK_CALLBACK_SECRET}".
+	CallbackSecret string `toml:"callback_secret,omitempty" koanf:"callback_secret,omitempty" json:"callbackSecret,omitempty"`
+}
+
+// GetCallbackSecret returns the callback secret with environment variable
+// references expanded, or an empty string if callbacks are disabled
+func (c NotificationConfig) GetCallbackSecret() string {
+	return os.ExpandEnv(c.CallbackSecret)
+}
+
+// NotificationSinkConfig describes a single notification destination. Secret,
+// Token and Headers values may reference the environment Sidekick runs with,
+// eg "${NTFY_TOKEN}".
+type NotificationSinkConfig struct {
+	Type NotificationSinkType `toml:"type" koanf:"type" json:"type"`
+	// URL is the endpoint of webhook, slack and ntfy sinks. For ntfy, it
+	// includes the topic, eg "https://ntfy.sh/my-sidekick".
+	URL string `toml:"url,omitempty" koanf:"url,omitempty" json:"url,omitempty"`
+	// Secret signs webhook payloads, see the X-Sidekick-Signature header
+	Secret string `toml:"secret,omitempty" koanf:"secret,omitempty" json:"secret,omitempty"`
+	// Token authenticates with ntfy servers that require it
+	Token string `toml:"token,omitempty" koanf:"token,omitempty" json:"token,omitempty"`
+	// Headers are sent with every webhook request
+	Headers map[string]string `toml:"headers,omitempty" koanf:"headers,omitempty" json:"headers,omitempty"`
+	// Command is run for command sinks, looked up in PATH if not an absolute
+	// path. The event is passed as JSON on stdin and via SIDEKICK_* environment
+	// variables, which may also be referenced in Args, eg "${SIDEKICK_TITLE}".
+	Command string   `toml:"command,omitempty" koanf:"command,omitempty" json:"command,omitempty"`
+	Args    []string `toml:"args,omitempty" koanf:"args,omitempty" json:"args,omitempty"`
+	// Events limits the events sent to this sink. All events are sent when
+	// empty.
+	Events []NotificationEvent `toml:"events,omitempty" koanf:"events,omitempty" json:"events,omitempty"`
+	// MaxAttempts is how many times delivery is attempted before giving up,
+	// defaulting to 3
+	MaxAttempts int `toml:"max_attempts,omitempty" koanf:"max_attempts,omitempty" json:"maxAttempts,omitempty"`
+}
+
+// Validate ensures the sink has the settings its type requires
+func (c NotificationSinkConfig) Validate() error {
+	switch c.Type {
+	case NotificationSinkWebhook, NotificationSinkSlack, NotificationSinkNtfy:
+		if c.URL == "" {
+			return fmt.Errorf("url is required for %s sinks", c.Type)
+		}
+	case NotificationSinkCommand:
+		if c.Command == "" {
+			return fmt.Errorf("command is required for command sinks")
+		}
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown sink type %q", c.Type)
+	}
+	for _, event := range c.Events {
+		if !slices.Contains(AllNotificationEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	return nil
+}
+
+// Receives reports whether the sink is sent the given event
+func (c NotificationSinkConfig) Receives(event NotificationEvent) bool {
+	return len(c.Events) == 0 || slices.Contains(c.Events, event)
+}
@@ -0,0 +1,219 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// OllamaDefaultBaseURL is where a local ollama server listens by default
+	OllamaDefaultBaseURL = "http://localhost:11434"
+
+	// OllamaMaxContextTokens caps the context used with ollama models, since
+	// ollama allocates memory for the whole context window up front and many
+	// models support far larger windows than local hardware can hold
+	OllamaMaxContextTokens = 32768
+
+	ollamaMetadataTimeout = 5 * time.Second
+)
+
+// OllamaBaseURL returns the base URL of an ollama provider, defaulting to the
+// local ollama server
+func OllamaBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return OllamaDefaultBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// ollama models don't change while they're installed, so their info is cached
+// for the lifetime of the process
+var ollamaModelCache sync.Map
+
+// failed lookups are remembered for ollamaRetryInterval, so that an
+// unreachable server doesn't hold up every model lookup until it times out
+var ollamaModelFailures sync.Map
+
+const ollamaRetryInterval = time.Minute
+
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	} `json:"models"`
+}
+
+type ollamaShowResponse struct {
+	Capabilities []string       `json:"capabilities"`
+	ModelInfo    map[string]any `json:"model_info"`
+}
+
+// ListOllamaModels lists the models installed on an ollama server in the same
+// shape as models.dev data, for model discovery
+func ListOllamaModels(ctx context.Context, baseURL string) (map[string]ModelInfo, error) {
+	var tags ollamaTagsResponse
+	if err := ollamaRequest(ctx, http.MethodGet, OllamaBaseURL(baseURL)+"/api/tags", nil, &tags); err != nil {
+		return nil, fmt.Errorf("failed to list ollama models: %w", err)
+	}
+
+	models := make(map[string]ModelInfo, len(tags.Models))
+	for _, m := range tags.Models {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		models[name] = ModelInfo{ID: name, Name: name}
+	}
+	return models, nil
+}
+
+// GetOllamaModel returns the capabilities and context length of a model
+// installed on an ollama server, with the context length capped to
+// OllamaMaxContextTokens
+func GetOllamaModel(ctx context.Context, baseURL, model string) (*ModelInfo, error) {
+	baseURL = OllamaBaseURL(baseURL)
+	cacheKey := baseURL + "|" + model
+	if cached, ok := ollamaModelCache.Load(cacheKey); ok {
+		modelInfo := cached.(ModelInfo)
+		return &modelInfo, nil
+	}
+	if failedAt, ok := ollamaModelFailures.Load(cacheKey); ok && time.Since(failedAt.(time.Time)) < ollamaRetryInterval {
+		return nil, fmt.Errorf("failed to get ollama model %s recently, not retrying yet", model)
+	}
+
+	var show ollamaShowResponse
+	if err := ollamaRequest(ctx, http.MethodPost, baseURL+"/api/show", map[string]string{"model": model}, &show); err != nil {
+		ollamaModelFailures.Store(cacheKey, time.Now())
+		return nil, fmt.Errorf("failed to get ollama model %s: %w", model, err)
+	}
+	ollamaModelFailures.Delete(cacheKey)
+
+	modelInfo := ModelInfo{
+		ID:          model,
+		Name:        model,
+		Reasoning:   slices.Contains(show.Capabilities, "thinking"),
+		ToolCall:    slices.Contains(show.Capabilities, "tools"),
+		Attachment:  slices.Contains(show.Capabilities, "vision"),
+		Temperature: true,
+		OpenWeights: true,
+	}
+	modelInfo.Modalities.Input = []string{"text"}
+	if modelInfo.Attachment {
+		modelInfo.Modalities.Input = append(modelInfo.Modalities.Input, "image")
+	}
+	modelInfo.Modalities.Output = []string{"text"}
+
+	// the context length key is prefixed with the model architecture, e.g.
+	// "llama.context_length"
+	for key, value := range show.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if contextLength, ok := value.(float64); ok {
+				modelInfo.Limit.Context = min(int(contextLength), OllamaMaxContextTokens)
+			}
+		}
+	}
+
+	ollamaModelCache.Store(cacheKey, modelInfo)
+	return &modelInfo, nil
+}
+
+func ollamaRequest(ctx context.Context, method, url string, body any, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, ollamaMetadataTimeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// ollamaProviders caches the base URLs of the ollama providers in the local
+// config by provider name, so that looking up models of other providers
+// doesn't need to load the config. It's reloaded when the config file changes.
+var ollamaProviders struct {
+	sync.Mutex
+	configPath string
+	modTime    time.Time
+	baseURLs   map[string]string
+}
+
+// ollamaProviderBaseURL returns the base URL of the given provider if it is an
+// ollama provider in the local config
+func ollamaProviderBaseURL(provider string) (string, bool) {
+	configPath := GetSidekickConfigPath()
+	var modTime time.Time
+	if info, err := os.Stat(configPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ollamaProviders.Lock()
+	defer ollamaProviders.Unlock()
+	if ollamaProviders.baseURLs == nil || ollamaProviders.configPath != configPath || !ollamaProviders.modTime.Equal(modTime) {
+		baseURLs := make(map[string]string)
+		localConfig, err := LoadSidekickConfig(configPath)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to load local config for ollama providers")
+		}
+		for _, p := range localConfig.Providers {
+			if p.Type == string(OllamaChatProvider) {
+				baseURLs[p.Name] = p.BaseURL
+			}
+		}
+		ollamaProviders.configPath = configPath
+		ollamaProviders.modTime = modTime
+		ollamaProviders.baseURLs = baseURLs
+	}
+	baseURL, ok := ollamaProviders.baseURLs[provider]
+	return baseURL, ok
+}
+
+// getOllamaProviderModel looks up a model for the given provider name if it is
+// an ollama provider in the local config
+func getOllamaProviderModel(provider, model string) *ModelInfo {
+	if provider == "" || model == "" {
+		return nil
+	}
+	baseURL, ok := ollamaProviderBaseURL(provider)
+	if !ok {
+		return nil
+	}
+	modelInfo, err := GetOllamaModel(context.Background(), baseURL, model)
+	if err != nil {
+		log.Debug().Err(err).Str("provider", provider).Str("model", model).Msg("failed to get ollama model info")
+		return nil
+	}
+	return modelInfo
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaModels(t *testing.T) {
+	t.Parallel()
+	showRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"qwen3:8b","model":"qwen3:8b"},{"name":"llama3.1:latest","model":"llama3.1:latest"}]}`)
+		case "/api/show":
+			showRequests++
+			fmt.Fprint(w, `{"capabilities":["completion","tools","thinking"],"model_info":{"general.architecture":"qwen3","qwen3.context_length":40960}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	models, err := ListOllamaModels(ctx, server.URL+"/")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ModelInfo{
+		"qwen3:8b":        {ID: "qwen3:8b", Name: "qwen3:8b"},
+		"llama3.1:latest": {ID: "llama3.1:latest", Name: "llama3.1:latest"},
+	}, models)
+
+	modelInfo, err := GetOllamaModel(ctx, server.URL, "qwen3:8b")
+	require.NoError(t, err)
+	assert.True(t, modelInfo.Reasoning)
+	assert.True(t, modelInfo.ToolCall)
+	assert.False(t, modelInfo.Attachment)
+	assert.Equal(t, []string{"text"}, modelInfo.Modalities.Input)
+	// capped, as ollama allocates the whole context up front
+	assert.Equal(t, OllamaMaxContextTokens, modelInfo.Limit.Context)
+
+	// model info is cached
+	_, err = GetOllamaModel(ctx, server.URL, "qwen3:8b")
+	require.NoError(t, err)
+	assert.Equal(t, 1, showRequests)
+
+	_, err = ListOllamaModels(ctx, server.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestOllamaBaseURL(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, OllamaDefaultBaseURL, OllamaBaseURL(""))
+	assert.Equal(t, "http://gpu-box:11434", OllamaBaseURL("http://gpu-box:11434/"))
+}
+
+func TestOllamaProviderBaseURL(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "sidekick")
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	configPath := filepath.Join(configDir, "config.yaml")
+	writeConfig := func(config string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(configPath, []byte(config), 0644))
+		require.NoError(t, os.Chtimes(configPath, modTime, modTime))
+	}
+	writeConfig(`
+providers:
+  - name: local
+    type: ollama
+    base_url: http://gpu-box:11434
+  - name: hosted
+    type: openai
+`, time.Now().Add(-time.Hour))
+	// registered first so it runs after the environment is restored
+	t.Cleanup(xdg.Reload)
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("XDG_CONFIG_DIRS", tmpDir)
+	xdg.Reload()
+
+	baseURL, ok := ollamaProviderBaseURL("local")
+	assert.True(t, ok)
+	assert.Equal(t, "http://gpu-box:11434", baseURL)
+	_, ok = ollamaProviderBaseURL("hosted")
+	assert.False(t, ok)
+	assert.Nil(t, getOllamaProviderModel("hosted", "gpt-4o"))
+
+	// the config is reloaded once it changes
+	writeConfig(`
+providers:
+  - name: other
+    type: ollama
+`, time.Now())
+	_, ok = ollamaProviderBaseURL("local")
+	assert.False(t, ok)
+	_, ok = ollamaProviderBaseURL("other")
+	assert.True(t, ok)
+}
@@ -11,6 +11,9 @@ const (
 	ProviderAuthTypeAny          ProviderAuthType = "any"
 	ProviderAuthTypeAPI          ProviderAuthType = "api"
 	ProviderAuthTypeSubscription ProviderAuthType = "subscription"
+	// ProviderAuthTypeAzureAD authenticates azure_openai providers with a
+	// Microsoft Entra ID (formerly Azure AD) token instead of an API key
+	ProviderAuthTypeAzureAD ProviderAuthType = "azure_ad"
 )
 
 func NormalizeProviderAuthType(authType string) ProviderAuthType {
@@ -21,6 +24,8 @@ func NormalizeProviderAuthType(authType string) ProviderAuthType {
 		return ProviderAuthTypeAPI
 	case string(ProviderAuthTypeSubscription):
 		return ProviderAuthTypeSubscription
+	case string(ProviderAuthTypeAzureAD), "aad", "entra_id":
+		return ProviderAuthTypeAzureAD
 	default:
 		return ProviderAuthType(strings.ToLower(strings.TrimSpace(authType)))
 	}
@@ -28,7 +33,7 @@ func NormalizeProviderAuthType(authType string) ProviderAuthType {
 
 func ValidateProviderAuthType(authType string) error {
 	switch NormalizeProviderAuthType(authType) {
-	case ProviderAuthTypeAny, ProviderAuthTypeAPI, ProviderAuthTypeSubscription:
+	case ProviderAuthTypeAny, ProviderAuthTypeAPI, ProviderAuthTypeSubscription, ProviderAuthTypeAzureAD:
 		return nil
 	default:
 		return fmt.Errorf("invalid auth type: %s", authType)
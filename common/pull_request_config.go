@@ -0,0 +1,28 @@
+package common
+
+// PullRequestConfig configures opening pull requests, which can be chosen
+// instead of merging locally when approving a task's changes.
+type PullRequestConfig struct {
+	// Remote is the git remote that branches are pushed to, and whose hosting
+	// service pull requests are opened with. Defaults to "origin".
+	Remote string `toml:"remote,omitempty" json:"remote,omitempty"`
+
+	// Provider is the hosting service's API to use, either "github", "gitlab"
+	// or "gitea". Detected from the remote's host when unset, which works for
+	// github.com, gitlab.com, codeberg.org and hosts with gitlab or gitea in
+	// their name.
+	Provider string `toml:"provider,omitempty" json:"provider,omitempty"`
+
+	// ApiURL is the base URL of the provider's REST API, eg
+	// "https://git.example.com/api/v1". Derived from the remote's host when
+	// unset.
+	ApiURL string `toml:"api_url,omitempty" json:"apiUrl,omitempty"`
+}
+
+// GetRemote returns the configured remote, or "origin" if unset
+func (c PullRequestConfig) GetRemote() string {
+	if c.Remote == "" {
+		return "origin"
+	}
+	return c.Remote
+}
@@ -47,6 +47,12 @@ type RepoConfig struct {
 	 * a safety measure to prevent infinite loops. Defaults to 17 if unspecified. */
 	MaxPlanningIterations int `toml:"max_planning_iterations,omitempty"`
 
+	/** Limits on LLM cost and token spend, per task and per day. When a limit
+	 * is reached, the flow pauses and asks the user whether to raise the
+	 * budget or stop. Unlike MaxIterations, this accounts for how expensive
+	 * each iteration actually is. No limits apply if unspecified. */
+	Budget BudgetConfig `toml:"budget,omitempty"`
+
 	EditCode EditCodeConfig `toml:"edit_code,omitempty"`
 
 	/** A script that will be executed in the working directory of a local git
@@ -56,6 +62,23 @@ type RepoConfig struct {
 	 * exit code to be considered successful. */
 	WorktreeSetup string `toml:"worktree_setup,omitempty"`
 
+	/** The container to run commands in when using the container environment
+	 * type. The worktree is bind-mounted into the container at the same path
+	 * as on the host, so commands such as tests or dev-run commands run
+	 * isolated from the host while file edits remain visible to both. */
+	Container ContainerConfig `toml:"container,omitempty"`
+
+	/** The DevPod workspace to run commands in when using the devpod
+	 * environment type. The workspace is provisioned from the worktree, and
+	 * edited files are synced to it before checks run. */
+	DevPod DevPodConfig `toml:"devpod,omitempty"`
+
+	/** Custom flows, keyed by name, each composed from an ordered list of
+	 * built-in steps. A custom flow is started via the "custom" flow type,
+	 * with the flow's name given in the customFlowName flow option. This
+	 * allows tailoring the pipeline for a repo without writing Go code. */
+	Flows map[string]CustomFlowConfig `toml:"flows,omitempty"`
+
 	// AgentConfig contains per-use-case configuration for agent loops.
 	// Keys are use case names (e.g., "planning", "coding", "coding_and_verification",
 	// "step_execution_and_verification").
@@ -63,9 +86,18 @@ type RepoConfig struct {
 
 	CommandPermissions CommandPermissionConfig `toml:"command_permissions,omitempty"`
 
+	/** MCP servers, keyed by name, whose tools are made available to the LLM
+	 * in addition to the built-in tools. These replace any servers of the same
+	 * name configured in the local config. */
+	McpServers map[string]McpServerConfig `toml:"mcp_servers,omitempty"`
+
 	// DevRun configures commands for running a dev server or supervisor
 	// for pre-approval manual QA in the worktree environment.
 	DevRun DevRunConfig `toml:"dev_run,omitempty"`
+
+	/** Where and how to open pull requests when changes are approved with the
+	 * pull request merge strategy, instead of being merged locally. */
+	PullRequest PullRequestConfig `toml:"pull_request,omitempty"`
 }
 
 // GlobalState keys for workflow-specific state
@@ -0,0 +1,347 @@
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"sidekick/coding/forge"
+	"sidekick/coding/git"
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/env"
+	"sidekick/flow_action"
+	"sidekick/utils"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+type AddressReviewInput struct {
+	WorkspaceId string
+	RepoDir     string
+	// Requirements are any extra instructions for addressing the review
+	Requirements string
+	AddressReviewOptions
+}
+
+type AddressReviewOptions struct {
+	// PullRequestURL is the web URL of a pull request opened by another task,
+	// whose worktree the review is addressed in
+	PullRequestURL  string                 `json:"pullRequestUrl,omitempty"`
+	ConfigOverrides common.ConfigOverrides `json:"configOverrides"`
+}
+
+// AddressReviewWorkflow addresses the unresolved line comments left by
+// reviewers on a pull request opened by another task. The comments are mapped
+// to their current place in the task's worktree and addressed by coding, then
+// once the changes are approved they're committed as a fixup commit, pushed to
+// the pull request's branch and each addressed thread is replied to.
+func AddressReviewWorkflow(ctx workflow.Context, input AddressReviewInput) (result string, err error) {
+	// don't recover panics in development so we can debug via temporal UI, at
+	// the cost of failed tasks appearing stuck without UI feedback in sidekick
+	if SideAppEnv != "development" {
+		defer func() {
+			if r := recover(); r != nil {
+				signalWorkflowFailureOrCancel(ctx)
+				var ok bool
+				err, ok = r.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}
+		}()
+	}
+
+	ctx = utils.DefaultRetryCtx(ctx)
+
+	if strings.TrimSpace(input.PullRequestURL) == "" {
+		signalWorkflowFailureOrCancel(ctx)
+		return "", errors.New("the pullRequestUrl flow option is required")
+	}
+
+	var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
+	var pullRequestTask PullRequestTask
+	err = workflow.ExecuteActivity(ctx, ima.FindPullRequestTask, input.WorkspaceId, input.PullRequestURL).Get(ctx, &pullRequestTask)
+	if err != nil {
+		signalWorkflowFailureOrCancel(ctx)
+		return "", err
+	}
+
+	// the worktree belongs to the task that opened the pull request, so it's
+	// used in place rather than set as this flow's worktree, which would get it
+	// cleaned up when this flow is canceled
+	dCtx, err := SetupDevContext(ctx, input.WorkspaceId, pullRequestTask.Worktree.WorkingDirectory, string(env.EnvTypeLocal), nil, input.Requirements, input.ConfigOverrides)
+	if err != nil {
+		signalWorkflowFailureOrCancel(ctx)
+		return "", err
+	}
+	defer teardownEnv(dCtx)
+	defer handleFlowCancel(dCtx)
+	defer stopActiveDevRun(dCtx)
+	defer func() {
+		if err != nil && !errors.Is(dCtx.Err(), workflow.ErrCanceled) {
+			_ = signalWorkflowClosure(dCtx, "failed")
+			return
+		}
+	}()
+
+	// run in the same kind of env as the task did, provisioned for this flow
+	// on the task's worktree, so that it's torn down along with this flow
+	taskEnvType, _ := pullRequestTask.Task.FlowOptions["envType"].(string)
+	if envType := env.EnvType(taskEnvType); envType == env.EnvTypeContainer || envType == env.EnvTypeDevPod {
+		flowId := workflow.GetInfo(ctx).WorkflowExecution.ID
+		var envContainer env.EnvContainer
+		envContainer, err = provisionWorktreeEnv(dCtx.ExecContext, *dCtx.EnvContainer, envType, flowId, input.ConfigOverrides)
+		if err != nil {
+			return "", fmt.Errorf("failed to create environment: %w", err)
+		}
+		*dCtx.EnvContainer = envContainer
+	}
+
+	SetupPauseHandler(dCtx, "Paused for user input", nil)
+	SetupUserActionHandler(dCtx)
+	SetupDevRunConfigQuery(dCtx)
+	SetupDevRunStateQuery(dCtx)
+
+	err = EnsurePrerequisites(dCtx)
+	if err != nil {
+		return "", err
+	}
+
+	review, threads, err := fetchReviewThreads(dCtx, input.PullRequestURL)
+	if err != nil {
+		return "", err
+	}
+	if len(threads) == 0 {
+		result = "No review comments need addressing"
+	} else {
+		result, err = addressReviewThreads(dCtx, pullRequestTask, review.PullRequest, threads, input.Requirements)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = signalWorkflowClosure(dCtx, "completed")
+	if err != nil {
+		return "", fmt.Errorf("failed to signal workflow closure: %w", err)
+	}
+	return result, nil
+}
+
+// fetchReviewThreads fetches the pull request's review threads that need
+// addressing and locates each in the worktree
+func fetchReviewThreads(dCtx DevContext, pullRequestURL string) (forge.PullRequestReview, []forge.LocatedReviewThread, error) {
+	actionCtx := dCtx.NewActionContext("fetch_review_comments")
+	actionCtx.ActionParams = map[string]interface{}{
+		"pullRequestUrl": pullRequestURL,
+	}
+	var review forge.PullRequestReview
+	located, err := Track(actionCtx, func(trackedCtx DevActionContext, flowAction *domain.FlowAction) ([]forge.LocatedReviewThread, error) {
+		err := flow_action.PerformWithUserRetry(trackedCtx.FlowActionContext(), forge.GetPullRequestReviewActivity, &review, forge.GetPullRequestReviewActivityInput{
+			EnvContainer:   *trackedCtx.EnvContainer,
+			Secrets:        *trackedCtx.Secrets,
+			Config:         trackedCtx.RepoConfig.PullRequest,
+			PullRequestURL: pullRequestURL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch review comments: %w", err)
+		}
+
+		var threads []forge.ReviewThread
+		for _, thread := range review.Threads {
+			if thread.NeedsAddressing() {
+				threads = append(threads, thread)
+			}
+		}
+		if len(threads) == 0 {
+			return nil, nil
+		}
+
+		var located []forge.LocatedReviewThread
+		err = workflow.ExecuteActivity(trackedCtx, forge.LocateReviewThreadsActivity, forge.LocateReviewThreadsActivityInput{
+			EnvContainer: *trackedCtx.EnvContainer,
+			Threads:      threads,
+		}).Get(trackedCtx, &located)
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate review comments: %w", err)
+		}
+		return located, nil
+	})
+	if err != nil {
+		return forge.PullRequestReview{}, nil, err
+	}
+	return review, located, nil
+}
+
+// addressReviewThreads codes until the user approves the changes addressing
+// the threads, then commits and pushes them and replies to the threads
+func addressReviewThreads(dCtx DevContext, pullRequestTask PullRequestTask, pr forge.PullRequest, threads []forge.LocatedReviewThread, instructions string) (string, error) {
+	requirements := formatReviewThreadRequirements(pullRequestTask.Task, pr, threads, instructions)
+	result, err := RunSubflow(dCtx, "coding", "Coding", func(subflow domain.Subflow) (string, error) {
+		originalRequirements := requirements
+		reviewMessages := []string{}
+		for {
+			result, err := codingSubflow(dCtx, requirements, nil, "")
+			if errors.Is(err, flow_action.PendingActionError) {
+				pending := dCtx.ExecContext.GlobalState.GetPendingUserAction()
+				if pending != nil && *pending == flow_action.UserActionGoNext {
+					// skip to reviewing the changes
+					dCtx.ExecContext.GlobalState.ConsumePendingUserAction()
+					err = nil
+				}
+			}
+			if err != nil {
+				return "", err
+			}
+
+			if err := git.GitAddAll(dCtx.ExecContext); err != nil {
+				return "", fmt.Errorf("failed to git add all: %w", err)
+			}
+			gitDiff, err := git.GitDiff(dCtx.ExecContext)
+			if err != nil {
+				return "", err
+			}
+			response, err := GetUserApproval(dCtx, "changes", "Please review the changes addressing the review comments", map[string]any{"gitDiff": gitDiff})
+			if err != nil {
+				return "", err
+			}
+			if response.Approved != nil && *response.Approved {
+				return result, nil
+			}
+
+			requirements = formatRequirementsWithReview(originalRequirements, reviewMessages, "", response.Content)
+			reviewMessages = append(reviewMessages, response.Content)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	actionCtx := dCtx.NewActionContext("push_review_fixup")
+	actionCtx.ActionParams = map[string]interface{}{
+		"branch":         pullRequestTask.Worktree.Name,
+		"pullRequestUrl": pr.URL,
+	}
+	_, err = Track(actionCtx, func(trackedCtx DevActionContext, flowAction *domain.FlowAction) (string, error) {
+		subject, err := headCommitSubject(trackedCtx.ExecContext)
+		if err != nil {
+			return "", err
+		}
+		err = workflow.ExecuteActivity(trackedCtx, git.GitCommitActivity, trackedCtx.EnvContainer, git.GitCommitParams{
+			CommitMessage:  "fixup! " + strings.TrimPrefix(subject, "fixup! "),
+			CommitterName:  trackedCtx.GlobalState.GetStringValue("committerName"),
+			CommitterEmail: trackedCtx.GlobalState.GetStringValue("committerEmail"),
+		}).Get(trackedCtx, nil)
+		if err != nil {
+			if strings.Contains(err.Error(), "nothing to commit") {
+				workflow.GetLogger(trackedCtx).Warn("nothing to commit after addressing review comments")
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to commit changes: %w", err)
+		}
+		sha, err := headCommitShortSha(trackedCtx.ExecContext)
+		if err != nil {
+			return "", err
+		}
+
+		err = flow_action.PerformWithUserRetry(trackedCtx.FlowActionContext(), forge.PushBranchActivity, nil, forge.PushBranchActivityInput{
+			EnvContainer: *trackedCtx.EnvContainer,
+			Secrets:      *trackedCtx.Secrets,
+			Config:       trackedCtx.RepoConfig.PullRequest,
+			Branch:       pullRequestTask.Worktree.Name,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to push branch: %w", err)
+		}
+
+		replies := make([]forge.ReviewThreadReply, 0, len(threads))
+		for _, thread := range threads {
+			replies = append(replies, forge.ReviewThreadReply{Thread: thread.ReviewThread, Body: fmt.Sprintf("Addressed in %s.", sha)})
+		}
+		err = flow_action.PerformWithUserRetry(trackedCtx.FlowActionContext(), forge.ReplyToReviewThreadsActivity, nil, forge.ReplyToReviewThreadsActivityInput{
+			EnvContainer:      *trackedCtx.EnvContainer,
+			Secrets:           *trackedCtx.Secrets,
+			Config:            trackedCtx.RepoConfig.PullRequest,
+			PullRequestNumber: pr.Number,
+			Replies:           replies,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to reply to review comments: %w", err)
+		}
+		return sha, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// formatReviewThreadRequirements turns the review threads into requirements
+// for the coding loop, along with the original task for context
+func formatReviewThreadRequirements(task domain.Task, pr forge.PullRequest, threads []forge.LocatedReviewThread, instructions string) string {
+	var b strings.Builder
+	b.WriteString("Address the following review comments left on the pull request \"")
+	b.WriteString(pr.Title)
+	b.WriteString("\", which implemented the original requirements below. Only make the changes the review comments ask for.\n\n")
+	b.WriteString("#START Original Requirements\n\n")
+	b.WriteString(strings.TrimSpace(task.Description))
+	b.WriteString("\n\n#END Original Requirements\n\n")
+
+	b.WriteString("#START Review Comments\n\n")
+	for i, thread := range threads {
+		b.WriteString(fmt.Sprintf("%d. ", i+1))
+		switch {
+		case thread.FileDeleted:
+			b.WriteString(fmt.Sprintf("On %s, which has since been deleted", thread.Path))
+		case thread.CurrentLine > 0 && thread.Approximate:
+			b.WriteString(fmt.Sprintf("On %s, around line %d", thread.Path, thread.CurrentLine))
+		case thread.CurrentLine > 0:
+			b.WriteString(fmt.Sprintf("On %s, line %d", thread.Path, thread.CurrentLine))
+		case thread.Line > 0:
+			b.WriteString(fmt.Sprintf("On %s, on a line that has since changed (originally line %d)", thread.Path, thread.Line))
+		default:
+			b.WriteString(fmt.Sprintf("On %s", thread.Path))
+		}
+		b.WriteString(":\n\n")
+		if thread.Snippet != "" {
+			b.WriteString("```\n")
+			b.WriteString(thread.Snippet)
+			b.WriteString("```\n\n")
+		}
+		for _, comment := range thread.Comments {
+			b.WriteString(fmt.Sprintf("%s: %s\n\n", comment.Author, strings.TrimSpace(comment.Body)))
+		}
+	}
+	b.WriteString("#END Review Comments\n")
+
+	if strings.TrimSpace(instructions) != "" {
+		b.WriteString("\nAdditional instructions:\n\n")
+		b.WriteString(strings.TrimSpace(instructions))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func headCommitSubject(eCtx flow_action.ExecContext) (string, error) {
+	return runGitOutput(eCtx, "log", "-1", "--format=%s")
+}
+
+func headCommitShortSha(eCtx flow_action.ExecContext) (string, error) {
+	return runGitOutput(eCtx, "rev-parse", "--short", "HEAD")
+}
+
+func runGitOutput(eCtx flow_action.ExecContext, args ...string) (string, error) {
+	var output env.EnvRunCommandActivityOutput
+	err := workflow.ExecuteActivity(eCtx, env.EnvRunCommandActivity, env.EnvRunCommandActivityInput{
+		EnvContainer:       *eCtx.EnvContainer,
+		RelativeWorkingDir: "./",
+		Command:            "git",
+		Args:               args,
+	}).Get(eCtx, &output)
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %s: %w", strings.Join(args, " "), err)
+	}
+	if output.ExitStatus != 0 {
+		return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(output.Stderr))
+	}
+	return strings.TrimSpace(output.Stdout), nil
+}
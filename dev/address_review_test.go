@@ -0,0 +1,60 @@
+package dev
+
+import (
+	"sidekick/coding/forge"
+	"sidekick/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressReviewFlowType_BuildInput(t *testing.T) {
+	t.Parallel()
+
+	def, ok := domain.GetFlowType(domain.FlowTypeAddressReview)
+	require.True(t, ok)
+	input, err := def.BuildInput(domain.FlowLaunchParams{
+		WorkspaceId:  "ws_1",
+		RepoDir:      "/repo",
+		Requirements: "keep the public API unchanged",
+		FlowOptions: map[string]interface{}{
+			"pullRequestUrl": "https://github.com/team/side/pull/8",
+		},
+	})
+	require.NoError(t, err)
+
+	addressReviewInput, ok := input.(AddressReviewInput)
+	require.True(t, ok)
+	assert.Equal(t, "ws_1", addressReviewInput.WorkspaceId)
+	assert.Equal(t, "keep the public API unchanged", addressReviewInput.Requirements)
+	assert.Equal(t, "https://github.com/team/side/pull/8", addressReviewInput.PullRequestURL)
+}
+
+func TestFormatReviewThreadRequirements(t *testing.T) {
+	t.Parallel()
+
+	task := domain.Task{Description: "Add a login page\n"}
+	pr := forge.PullRequest{Title: "Add login page"}
+	comment := func(body string) []forge.ReviewComment {
+		return []forge.ReviewComment{{Author: "reviewer", Body: body}}
+	}
+	threads := []forge.LocatedReviewThread{
+		{ReviewThread: forge.ReviewThread{Path: "login.go", Line: 10, Comments: comment("Rename to handleLogin")}, CurrentLine: 12, Snippet: "12\tfunc login() {\n"},
+		{ReviewThread: forge.ReviewThread{Path: "util.go", Line: 3, Comments: comment("Unused")}, CurrentLine: 3, Approximate: true},
+		{ReviewThread: forge.ReviewThread{Path: "form.go", Line: 7, Comments: comment("Validate input")}},
+		{ReviewThread: forge.ReviewThread{Path: "old.go", Line: 1, Comments: comment("Why?")}, FileDeleted: true},
+	}
+
+	requirements := formatReviewThreadRequirements(task, pr, threads, "")
+	assert.Contains(t, requirements, "pull request \"Add login page\"")
+	assert.Contains(t, requirements, "#START Original Requirements\n\nAdd a login page\n\n#END Original Requirements")
+	assert.Contains(t, requirements, "1. On login.go, line 12:\n\n```\n12\tfunc login() {\n```\n\nreviewer: Rename to handleLogin\n")
+	assert.Contains(t, requirements, "2. On util.go, around line 3:\n\nreviewer: Unused\n")
+	assert.Contains(t, requirements, "3. On form.go, on a line that has since changed (originally line 7):")
+	assert.Contains(t, requirements, "4. On old.go, which has since been deleted:")
+	assert.NotContains(t, requirements, "Additional instructions")
+
+	requirements = formatReviewThreadRequirements(task, pr, threads, "Keep the public API unchanged")
+	assert.Contains(t, requirements, "Additional instructions:\n\nKeep the public API unchanged\n")
+}
@@ -138,6 +138,12 @@ func (da *DevActivities) ApplyEditBlocks(ctx context.Context, input ApplyEditBlo
 		}
 		report.DidApply = true
 
+		// environments that don't see the host working directory directly
+		// need the edit before running checks against it
+		if syncErr := env.PushFiles(ctx, input.EnvContainer, []string{block.FilePath}); syncErr != nil {
+			report.Error = fmt.Sprintf("Failed to sync edited file to the environment: %v", syncErr)
+		}
+
 		if report.Error == "" && slices.Contains(input.EnabledFlags, fflag.CheckEdits) {
 			// This block executes if CheckEdits is enabled and no prior error occurred for this edit block.
 			// report.Error is guaranteed to be empty at the start of this block.
@@ -514,6 +520,9 @@ func checkAndStageOrRestoreFile(envContainer env.EnvContainer, checkCommands []c
 				return checkResult, fmt.Errorf("%v\nFailed to remove file: %v", checkErr, err)
 			}
 		}
+		if err := env.PushFiles(ctx, envContainer, []string{filePath}); err != nil {
+			return checkResult, fmt.Errorf("%v\nFailed to sync restored file to the environment: %v", checkErr, err)
+		}
 		return checkResult, nil
 	}
 	checkResult := CheckResult{Success: true, Message: ""}
@@ -1462,6 +1471,22 @@ func runAutofixCommands(ctx context.Context, envContainer env.EnvContainer, repo
 	autofixCommands := repoConfig.AutofixCommands
 	span.SetAttributes(attribute.Int("commandCount", len(autofixCommands)))
 
+	// autofix commands run in the environment, so environments that don't see
+	// the host working directory directly need the edit first, and their
+	// fixes need to be brought back afterwards
+	filePaths := []string{report.OriginalEditBlock.FilePath}
+	if len(autofixCommands) > 0 {
+		if err := env.PushFiles(ctx, envContainer, filePaths); err != nil {
+			report.AutofixError += fmt.Sprintf("failed to sync file to the environment before autofix: %v\n", err)
+			return
+		}
+		defer func() {
+			if err := env.PullFiles(ctx, envContainer, filePaths); err != nil {
+				report.AutofixError += fmt.Sprintf("failed to sync autofixed file from the environment: %v\n", err)
+			}
+		}()
+	}
+
 	commandsRun := make([]string, 0, len(autofixCommands))
 	for i, command := range autofixCommands {
 		_, cmdSpan := applyEditBlocksTracer.Start(ctx, "autofixCommand")
@@ -1863,6 +1863,95 @@ class RST(FixedWidth):
         lines = [lines[1]] + lines + [lines[1]]
         return lines`
 
+func TestApplyEditBlocks_DevPodEnvSyncsEditedFiles(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar command not found in PATH")
+	}
+	tmpDir := t.TempDir()
+	remoteDir := t.TempDir()
+
+	// fake devpod CLI that runs "ssh --command" scripts on the host, in a
+	// separate directory standing in for the workspace
+	devPodPath := filepath.Join(t.TempDir(), "fake-devpod")
+	script := fmt.Sprintf("#!/bin/sh\n[ \"$1\" = \"ssh\" ] || exit 0\ncd %q || exit 1\nexec sh -c \"$4\"\n", remoteDir)
+	require.NoError(t, os.WriteFile(devPodPath, []byte(script), 0755))
+
+	originalContent := "original content\nline2"
+	filePath := "existing_file.txt"
+	for _, dir := range []string{tmpDir, remoteDir} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, filePath), []byte(originalContent), 0644))
+	}
+	_, err := os.Create(filepath.Join(tmpDir, "side.yml"))
+	require.NoError(t, err)
+	runGitCommand(t, tmpDir, "init")
+	runGitCommand(t, tmpDir, "config", "user.email", "test@example.com")
+	runGitCommand(t, tmpDir, "config", "user.name", "Test User")
+	runGitCommand(t, tmpDir, "add", ".")
+	runGitCommand(t, tmpDir, "commit", "-m", "Initial commit")
+
+	envContainer := env.EnvContainer{Env: &env.DevPodEnv{
+		WorkingDirectory:       tmpDir,
+		RemoteWorkingDirectory: remoteDir,
+		Binary:                 devPodPath,
+		WorkspaceId:            "sidekick-test",
+	}}
+	devActivities := &DevActivities{
+		LSPActivities: &lsp.LSPActivities{
+			LSPClientProvider: func(languageName string) lsp.LSPClient {
+				return &lsp.Jsonrpc2LSPClient{
+					LanguageName: languageName,
+				}
+			},
+			InitializedClients: map[string]lsp.LSPClient{},
+		},
+	}
+
+	t.Run("failed check restores the file in the workspace", func(t *testing.T) {
+		reports, err := devActivities.ApplyEditBlocks(context.Background(), ApplyEditBlockActivityInput{
+			EnvContainer: envContainer,
+			EditBlocks: []EditBlock{{
+				EditType: "update",
+				FilePath: filePath,
+				OldLines: strings.Split(originalContent, "\n"),
+				NewLines: []string{"broken content"},
+			}},
+			EnabledFlags:  []string{fflag.CheckEdits},
+			CheckCommands: []common.CommandConfig{{Command: "! grep -q broken " + filePath}},
+		})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.False(t, reports[0].DidApply)
+
+		remoteContent, err := os.ReadFile(filepath.Join(remoteDir, filePath))
+		require.NoError(t, err)
+		assert.Equal(t, originalContent, string(remoteContent))
+	})
+
+	t.Run("checks run against the edited file in the workspace", func(t *testing.T) {
+		modifiedContent := "modified content\nline2"
+		reports, err := devActivities.ApplyEditBlocks(context.Background(), ApplyEditBlockActivityInput{
+			EnvContainer: envContainer,
+			EditBlocks: []EditBlock{{
+				EditType: "update",
+				FilePath: filePath,
+				OldLines: strings.Split(originalContent, "\n"),
+				NewLines: strings.Split(modifiedContent, "\n"),
+			}},
+			EnabledFlags:  []string{fflag.CheckEdits},
+			CheckCommands: []common.CommandConfig{{Command: "grep -q modified " + filePath}},
+		})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.True(t, reports[0].DidApply, reports[0].Error)
+		assert.True(t, reports[0].CheckResult.Success)
+
+		remoteContent, err := os.ReadFile(filepath.Join(remoteDir, filePath))
+		require.NoError(t, err)
+		assert.Equal(t, modifiedContent, string(remoteContent))
+	})
+}
+
 func TestFindAcceptableMatchWithVisibleFileRangeAtEndEdge(t *testing.T) {
 	t.Parallel()
 	editBlock := EditBlock{
@@ -37,6 +37,9 @@ type MergeWithReviewParams struct {
 	Requirements   string
 	StartBranch    *string
 	CommitRequired bool
+	// ReviewComments are the line comments from earlier reviews, shown again
+	// for the user to resolve
+	ReviewComments []ReviewComment
 }
 
 // getDiffSinceLastReview generates a diff comparing the last review tree to current staged changes.
@@ -215,6 +218,7 @@ func BasicDevWorkflow(ctx workflow.Context, input BasicDevWorkflowInput) (result
 		signalWorkflowFailureOrCancel(ctx)
 		return "", err
 	}
+	defer teardownEnv(dCtx)
 	defer handleFlowCancel(dCtx)
 	defer stopActiveDevRun(dCtx)
 	defer func() {
@@ -269,7 +273,7 @@ func BasicDevWorkflow(ctx workflow.Context, input BasicDevWorkflowInput) (result
 	}
 
 	worktreeMergeVersion := workflow.GetVersion(dCtx, "worktree-merge", workflow.DefaultVersion, 1)
-	if dCtx.EnvContainer.Env.GetType() == env.EnvTypeLocalGitWorktree && worktreeMergeVersion >= 1 {
+	if dCtx.EnvContainer.Env.GetType().UsesGitWorktree() && worktreeMergeVersion >= 1 {
 		params := MergeWithReviewParams{
 			CommitRequired: true,
 			Requirements:   requirements,
@@ -500,7 +504,7 @@ Feedback: %s`, fulfillment.Analysis, fulfillment.FeedbackMessage),
 		return testResult.Output, nil
 	}
 
-	if dCtx.EnvContainer.Env.GetType() == env.EnvTypeLocalGitWorktree {
+	if dCtx.EnvContainer.Env.GetType().UsesGitWorktree() {
 		params := MergeWithReviewParams{
 			CommitRequired: true,
 			Requirements:   requirements,
@@ -521,7 +525,7 @@ Feedback: %s`, fulfillment.Analysis, fulfillment.FeedbackMessage),
 	return testResult.Output, nil
 }
 
-func getMergeApproval(dCtx DevContext, defaultTarget string, commitRequired bool, lastReviewTreeHash string) (MergeApprovalResponse, string, string, error) {
+func getMergeApproval(dCtx DevContext, defaultTarget string, commitRequired bool, lastReviewTreeHash string, reviewComments []ReviewComment) (MergeApprovalResponse, string, string, error) {
 	v := workflow.GetVersion(dCtx, "worktree-merge", workflow.DefaultVersion, 1)
 
 	var gitDiff string
@@ -574,6 +578,7 @@ func getMergeApproval(dCtx DevContext, defaultTarget string, commitRequired bool
 		Diff:                 gitDiff,
 		DiffSinceLastReview:  diffSinceLastReview,
 		DefaultMergeStrategy: MergeStrategySquash,
+		ReviewComments:       reviewComments,
 	}
 
 	approvalResponse, err := GetUserMergeApproval(dCtx, "Please review these changes", map[string]any{
@@ -619,6 +624,10 @@ func reviewAndResolve(dCtx DevContext, params MergeWithReviewParams) error {
 				params.StartBranch = &mergeInfo.TargetBranch
 				dCtx.ExecContext.GlobalState.SetValue(common.KeyCurrentTargetBranch, mergeInfo.TargetBranch)
 				lastReviewTreeHash = treeHash
+				// Show the line comments again next review, so they can be
+				// resolved
+				params.ReviewComments = mergeInfo.ReviewComments
+				feedback := mergeInfo.Feedback()
 
 				// Summarize diff if it exceeds the character budget
 				diffForRequirements := gitDiff
@@ -628,7 +637,7 @@ func reviewAndResolve(dCtx DevContext, params MergeWithReviewParams) error {
 					var summarizedDiff string
 					err = workflow.ExecuteActivity(dCtx, SummarizeDiffActivity, SummarizeDiffActivityInput{
 						GitDiff:                gitDiff,
-						ReviewFeedback:         mergeInfo.Message,
+						ReviewFeedback:         feedback,
 						EnvContainer:           *dCtx.EnvContainer,
 						ModelConfig:            modelConfig,
 						SecretManagerContainer: *dCtx.Secrets,
@@ -646,11 +655,15 @@ func reviewAndResolve(dCtx DevContext, params MergeWithReviewParams) error {
 					originalRequirements,
 					reviewMessages,
 					diffForRequirements,
-					mergeInfo.Message,
+					feedback,
 				)
 
-				// Add rejection message to history for next iteration
-				reviewMessages = append(reviewMessages, mergeInfo.Message)
+				// Add rejection message to history for next iteration. Line
+				// comments are left out, as unresolved ones are part of the
+				// feedback each time.
+				if mergeInfo.Message != "" || len(mergeInfo.ReviewComments) == 0 {
+					reviewMessages = append(reviewMessages, mergeInfo.Message)
+				}
 
 				// must commit before merge at this point, as codingSubflow
 				// doesn't do so inherently
@@ -680,7 +693,21 @@ func reviewAndResolve(dCtx DevContext, params MergeWithReviewParams) error {
 }
 
 func mergeWorktreeIfApproved(dCtx DevContext, params MergeWithReviewParams, lastReviewTreeHash string) (string, MergeApprovalResponse, string, error) {
+	mergeInfo, gitDiff, currentTreeHash, err := getWorktreeMergeApproval(dCtx, params, lastReviewTreeHash)
+	if err != nil {
+		return "", MergeApprovalResponse{}, "", err
+	}
 
+	if !mergeInfo.Approved {
+		return gitDiff, mergeInfo, currentTreeHash, nil
+	}
+
+	return mergeApprovedWorktree(dCtx, params, mergeInfo, gitDiff, currentTreeHash)
+}
+
+// getWorktreeMergeApproval stages all changes and asks the user to review the
+// worktree's diff against the current target branch.
+func getWorktreeMergeApproval(dCtx DevContext, params MergeWithReviewParams, lastReviewTreeHash string) (MergeApprovalResponse, string, string, error) {
 	// GlobalState is the single source of truth for the target branch, updated
 	// by set_base_branch tool and UI. Fallback covers workflow replays from
 	// before GlobalState was initialized at setup.
@@ -695,19 +722,28 @@ func mergeWorktreeIfApproved(dCtx DevContext, params MergeWithReviewParams, last
 	gitAddVersion := workflow.GetVersion(dCtx, "git-add-before-diff", workflow.DefaultVersion, 1)
 	if gitAddVersion == 1 {
 		if err := git.GitAddAll(dCtx.ExecContext); err != nil {
-			return "", MergeApprovalResponse{}, "", fmt.Errorf("failed to git add all: %w", err)
+			return MergeApprovalResponse{}, "", "", fmt.Errorf("failed to git add all: %w", err)
 		}
 	}
 
-	mergeInfo, gitDiff, currentTreeHash, err := getMergeApproval(dCtx, defaultTarget, params.CommitRequired, lastReviewTreeHash)
+	mergeInfo, gitDiff, currentTreeHash, err := getMergeApproval(dCtx, defaultTarget, params.CommitRequired, lastReviewTreeHash, params.ReviewComments)
 	if err != nil {
-		return "", MergeApprovalResponse{}, "", fmt.Errorf("failed to get merge approval: %w", err)
+		return MergeApprovalResponse{}, "", "", fmt.Errorf("failed to get merge approval: %w", err)
 	}
+	return mergeInfo, gitDiff, currentTreeHash, nil
+}
 
-	if !mergeInfo.Approved {
-		return gitDiff, mergeInfo, currentTreeHash, nil
+// mergeApprovedWorktree commits and merges the worktree branch into the
+// approved target branch, walking the user through any merge conflicts, then
+// cleans up the worktree. With the pull request strategy, a pull request is
+// opened instead of merging locally.
+func mergeApprovedWorktree(dCtx DevContext, params MergeWithReviewParams, mergeInfo MergeApprovalResponse, gitDiff string, currentTreeHash string) (string, MergeApprovalResponse, string, error) {
+	if mergeInfo.MergeStrategy == MergeStrategyPullRequest {
+		return openPullRequestForWorktree(dCtx, params, mergeInfo, gitDiff, currentTreeHash)
 	}
 
+	var err error
+
 	// Perform merge
 	actionCtx := dCtx.NewActionContext("merge")
 	actionCtx.ActionParams = map[string]interface{}{
@@ -732,15 +768,7 @@ func mergeWorktreeIfApproved(dCtx DevContext, params MergeWithReviewParams, last
 	// handling review feedback in the review and resolve flow, and related to
 	// the overall requirements in the initial basic dev flow.
 
-	commitMessage := strings.TrimSpace(params.Requirements)
-	if strings.Contains(commitMessage, "Overview:\n") {
-		commitMessage = strings.Split(commitMessage, "Overview:\n")[1]
-		commitMessage = strings.TrimSpace(commitMessage)
-	}
-	commitMessage = strings.Split(commitMessage, "\n")[0]
-	if len(commitMessage) > 100 {
-		commitMessage = commitMessage[:100] + "...\n\n..." + commitMessage[100:]
-	}
+	commitMessage := commitMessageFromRequirements(params.Requirements)
 
 	committerName := dCtx.GlobalState.GetStringValue("committerName")
 	committerEmail := dCtx.GlobalState.GetStringValue("committerEmail")
@@ -841,7 +869,7 @@ func mergeWorktreeIfApproved(dCtx DevContext, params MergeWithReviewParams, last
 				break
 			}
 
-			mergeInfo, gitDiff, currentTreeHash, err = getMergeApproval(dCtx, mergeInfo.TargetBranch, params.CommitRequired, "")
+			mergeInfo, gitDiff, currentTreeHash, err = getMergeApproval(dCtx, mergeInfo.TargetBranch, params.CommitRequired, "", params.ReviewComments)
 			if err != nil {
 				return "", MergeApprovalResponse{}, "", fmt.Errorf("failed to get final merge approval: %w", err)
 			}
@@ -899,3 +927,18 @@ func mergeWorktreeIfApproved(dCtx DevContext, params MergeWithReviewParams, last
 
 	return gitDiff, mergeInfo, currentTreeHash, err
 }
+
+// commitMessageFromRequirements uses the first line of the requirements'
+// overview, or of the requirements themselves, as the commit message
+func commitMessageFromRequirements(requirements string) string {
+	commitMessage := strings.TrimSpace(requirements)
+	if strings.Contains(commitMessage, "Overview:\n") {
+		commitMessage = strings.Split(commitMessage, "Overview:\n")[1]
+		commitMessage = strings.TrimSpace(commitMessage)
+	}
+	commitMessage = strings.Split(commitMessage, "\n")[0]
+	if len(commitMessage) > 100 {
+		commitMessage = commitMessage[:100] + "...\n\n..." + commitMessage[100:]
+	}
+	return commitMessage
+}
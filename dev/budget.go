@@ -0,0 +1,147 @@
+package dev
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sidekick/common"
+	"sidekick/flow_action"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+const (
+	// keyBudget holds the effective budget once the user has raised it
+	keyBudget = "budget"
+	// keyBudgetBaseline holds the current flow's usage at the start of the
+	// day, see budgetBaseline
+	keyBudgetBaseline = "budgetBaseline"
+)
+
+// budgetBaseline records the usage of the current flow when the UTC day last
+// changed, so that only what the flow spent since counts towards today. The
+// spend of other flows is fetched at every check instead, since flows running
+// concurrently keep adding to it.
+type budgetBaseline struct {
+	Day                 time.Time
+	FlowUsageAtDayStart common.LlmUsage
+}
+
+// CheckBudget pauses the flow to ask the user for guidance when LLM spend has
+// reached one of the limits in RepoConfig.Budget. Responding raises the
+// exceeded limits, either to the values given in the "budget" response param
+// or by the originally configured amounts. The response is returned so that
+// any guidance content can be passed on to the LLM, and is nil when within
+// budget. Without a human in the loop, ErrBudgetExceeded is returned instead.
+func CheckBudget(dCtx DevContext) (*flow_action.UserResponse, error) {
+	if !dCtx.RepoConfig.Budget.IsEnabled() || dCtx.GlobalState == nil {
+		return nil, nil
+	}
+	if v := workflow.GetVersion(dCtx, "budget-limits", workflow.DefaultVersion, 1); v < 1 {
+		return nil, nil
+	}
+
+	budget := dCtx.RepoConfig.Budget
+	if raised, ok := dCtx.GlobalState.GetValue(keyBudget).(common.BudgetConfig); ok {
+		budget = raised
+	}
+
+	taskUsage, dailyUsage, err := getBudgetSpend(dCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get llm spend: %w", err)
+	}
+
+	exceeded := budget.ExceededLimits(taskUsage, dailyUsage)
+	if len(exceeded) == 0 {
+		return nil, nil
+	}
+
+	descriptions := make([]string, 0, len(exceeded))
+	for _, e := range exceeded {
+		descriptions = append(descriptions, e.String())
+	}
+	summary := strings.Join(descriptions, "; ")
+
+	if dCtx.RepoConfig.DisableHumanInTheLoop {
+		return nil, fmt.Errorf("%w: %s", ErrBudgetExceeded, summary)
+	}
+
+	guidanceContext := fmt.Sprintf("The LLM budget was reached: %s. Respond to raise the budget and continue, optionally with some guidance, or cancel the task to stop.", summary)
+	requestParams := map[string]any{
+		"budget":         budget,
+		"budgetExceeded": exceeded,
+	}
+	response, err := GetUserGuidance(dCtx, guidanceContext, requestParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user guidance for exceeded budget: %w", err)
+	}
+
+	newBudget := budget.Raise(dCtx.RepoConfig.Budget, exceeded)
+	if requested, ok := budgetFromResponse(response); ok {
+		newBudget = requested
+	}
+	dCtx.GlobalState.SetValue(keyBudget, newBudget)
+
+	return response, nil
+}
+
+// getBudgetSpend returns the LLM usage of the task and of the workspace during
+// the current UTC day, including the usage of the current flow so far.
+func getBudgetSpend(dCtx DevContext) (taskUsage, dailyUsage common.LlmUsage, err error) {
+	now := workflow.Now(dCtx).UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	flowUsage := dCtx.GlobalState.GetLlmUsage()
+
+	baseline, ok := dCtx.GlobalState.GetValue(keyBudgetBaseline).(budgetBaseline)
+	if !baseline.Day.Equal(dayStart) {
+		if ok {
+			// a new day started since the last check
+			baseline.FlowUsageAtDayStart = flowUsage
+		}
+		baseline.Day = dayStart
+		dCtx.GlobalState.SetValue(keyBudgetBaseline, baseline)
+	}
+
+	var fa *flow_action.FlowActivities
+	var spend flow_action.LlmSpend
+	err = workflow.ExecuteActivity(dCtx, fa.GetLlmSpend, flow_action.GetLlmSpendInput{
+		WorkspaceId: dCtx.WorkspaceId,
+		FlowId:      workflow.GetInfo(dCtx).WorkflowExecution.ID,
+		Since:       dayStart,
+	}).Get(dCtx, &spend)
+	if err != nil {
+		return taskUsage, dailyUsage, err
+	}
+
+	taskUsage = spend.Task
+	taskUsage.Add(flowUsage)
+	dailyUsage = spend.Daily
+	dailyUsage.Add(flowUsage.Sub(baseline.FlowUsageAtDayStart))
+	return taskUsage, dailyUsage, nil
+}
+
+// budgetFromResponse parses a budget explicitly provided by the user in the
+// response params, if any.
+func budgetFromResponse(response *flow_action.UserResponse) (common.BudgetConfig, bool) {
+	if response == nil || response.Params == nil {
+		return common.BudgetConfig{}, false
+	}
+	raw, ok := response.Params["budget"]
+	if !ok {
+		return common.BudgetConfig{}, false
+	}
+	jsonBytes, err := json.Marshal(raw)
+	if err != nil {
+		return common.BudgetConfig{}, false
+	}
+	var budget common.BudgetConfig
+	if err := json.Unmarshal(jsonBytes, &budget); err != nil || !budget.IsEnabled() {
+		return common.BudgetConfig{}, false
+	}
+	return budget, true
+}
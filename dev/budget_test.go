@@ -0,0 +1,163 @@
+package dev
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sidekick/common"
+	"sidekick/flow_action"
+	"sidekick/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func executeCheckBudgetWorkflow(t *testing.T, budget common.BudgetConfig, flowUsage common.LlmUsage, spend flow_action.LlmSpend) (*testsuite.TestWorkflowEnvironment, error) {
+	t.Helper()
+	var testSuite testsuite.WorkflowTestSuite
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	var fa *flow_action.FlowActivities
+	env.OnActivity(fa.GetLlmSpend, mock.Anything, mock.Anything).Return(spend, nil)
+
+	wrapperWorkflow := func(ctx workflow.Context) error {
+		globalState := &flow_action.GlobalState{}
+		globalState.AddLlmUsage(flowUsage)
+		dCtx := DevContext{
+			ExecContext: flow_action.ExecContext{
+				Context:     utils.NoRetryCtx(ctx),
+				WorkspaceId: "ws_1",
+				GlobalState: globalState,
+				FlowScope:   &flow_action.FlowScope{},
+			},
+			RepoConfig: common.RepoConfig{
+				Budget:                budget,
+				DisableHumanInTheLoop: true,
+			},
+		}
+		response, err := CheckBudget(dCtx)
+		if err != nil {
+			return err
+		}
+		if response != nil {
+			return errors.New("unexpected user response")
+		}
+		return nil
+	}
+	env.RegisterWorkflow(wrapperWorkflow)
+	env.ExecuteWorkflow(wrapperWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	return env, env.GetWorkflowError()
+}
+
+func TestCheckBudget_NoBudget(t *testing.T) {
+	t.Parallel()
+	env, err := executeCheckBudgetWorkflow(t, common.BudgetConfig{}, common.LlmUsage{Cost: 100}, flow_action.LlmSpend{})
+	require.NoError(t, err)
+	env.AssertNotCalled(t, "GetLlmSpend", mock.Anything, mock.Anything)
+}
+
+func TestCheckBudget_WithinBudget(t *testing.T) {
+	t.Parallel()
+	budget := common.BudgetConfig{MaxTaskCost: 5, MaxDailyCost: 20}
+	spend := flow_action.LlmSpend{
+		Task:  common.LlmUsage{Cost: 1},
+		Daily: common.LlmUsage{Cost: 10},
+	}
+	_, err := executeCheckBudgetWorkflow(t, budget, common.LlmUsage{Cost: 2}, spend)
+	require.NoError(t, err)
+}
+
+func TestCheckBudget_ExceededWithoutHuman(t *testing.T) {
+	t.Parallel()
+	budget := common.BudgetConfig{MaxTaskCost: 5, MaxDailyCost: 20}
+	spend := flow_action.LlmSpend{
+		Task:  common.LlmUsage{Cost: 1},
+		Daily: common.LlmUsage{Cost: 10},
+	}
+	// the flow's own usage counts towards both the task and daily spend
+	_, err := executeCheckBudgetWorkflow(t, budget, common.LlmUsage{Cost: 10}, spend)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrBudgetExceeded.Error())
+	assert.Contains(t, err.Error(), "task cost $11.00 has reached the limit of $5.00")
+	assert.Contains(t, err.Error(), "today's cost $20.00 has reached the daily limit of $20.00")
+}
+
+func TestCheckBudget_RefetchesSpendAndResetsDailyUsage(t *testing.T) {
+	t.Parallel()
+	var testSuite testsuite.WorkflowTestSuite
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetStartTime(time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC))
+
+	var fa *flow_action.FlowActivities
+	env.OnActivity(fa.GetLlmSpend, mock.Anything, mock.Anything).Return(flow_action.LlmSpend{Daily: common.LlmUsage{Cost: 5}}, nil).Once()
+	env.OnActivity(fa.GetLlmSpend, mock.Anything, mock.Anything).Return(flow_action.LlmSpend{}, nil).Once()
+	env.OnActivity(fa.GetLlmSpend, mock.Anything, mock.Anything).Return(flow_action.LlmSpend{Daily: common.LlmUsage{Cost: 19}}, nil).Once()
+
+	wrapperWorkflow := func(ctx workflow.Context) error {
+		globalState := &flow_action.GlobalState{}
+		dCtx := DevContext{
+			ExecContext: flow_action.ExecContext{
+				Context:     utils.NoRetryCtx(ctx),
+				WorkspaceId: "ws_1",
+				GlobalState: globalState,
+				FlowScope:   &flow_action.FlowScope{},
+			},
+			RepoConfig: common.RepoConfig{
+				Budget:                common.BudgetConfig{MaxTaskCost: 100, MaxDailyCost: 20},
+				DisableHumanInTheLoop: true,
+			},
+		}
+
+		globalState.AddLlmUsage(common.LlmUsage{Cost: 3})
+		if _, err := CheckBudget(dCtx); err != nil {
+			return err
+		}
+
+		// usage from before the day changed no longer counts towards today
+		globalState.AddLlmUsage(common.LlmUsage{Cost: 4})
+		if err := workflow.Sleep(ctx, time.Hour); err != nil {
+			return err
+		}
+		if _, err := CheckBudget(dCtx); err != nil {
+			return err
+		}
+
+		// other flows spent more since the last check
+		globalState.AddLlmUsage(common.LlmUsage{Cost: 1})
+		_, err := CheckBudget(dCtx)
+		return err
+	}
+	env.RegisterWorkflow(wrapperWorkflow)
+	env.ExecuteWorkflow(wrapperWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+
+	err := env.GetWorkflowError()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "today's cost $20.00 has reached the daily limit of $20.00")
+	assert.NotContains(t, err.Error(), "task cost")
+	env.AssertExpectations(t)
+}
+
+func TestBudgetFromResponse(t *testing.T) {
+	t.Parallel()
+
+	_, ok := budgetFromResponse(nil)
+	assert.False(t, ok)
+
+	_, ok = budgetFromResponse(&flow_action.UserResponse{Content: "continue"})
+	assert.False(t, ok)
+
+	_, ok = budgetFromResponse(&flow_action.UserResponse{Params: map[string]any{"budget": map[string]any{}}})
+	assert.False(t, ok, "an empty budget doesn't remove limits")
+
+	budget, ok := budgetFromResponse(&flow_action.UserResponse{Params: map[string]any{
+		"budget": map[string]any{"maxTaskCost": 12.5, "maxDailyTokens": 2000000},
+	}})
+	require.True(t, ok)
+	assert.Equal(t, common.BudgetConfig{MaxTaskCost: 12.5, MaxDailyTokens: 2000000}, budget)
+}
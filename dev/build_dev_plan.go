@@ -549,6 +549,7 @@ func generateDevPlan(dCtx DevContext, chatHistory *persisted_ai.ChatHistoryConta
 	if !dCtx.RepoConfig.DisableHumanInTheLoop {
 		tools = append(tools, &getHelpOrInputTool)
 	}
+	tools = append(tools, mcpTools(dCtx)...)
 
 	chatOptions := llm2.Options{
 		Tools: tools,
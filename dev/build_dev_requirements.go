@@ -443,6 +443,7 @@ func generateDevRequirements(dCtx DevContext, chatHistory *persisted_ai.ChatHist
 	if !dCtx.RepoConfig.DisableHumanInTheLoop {
 		tools = append(tools, &getHelpOrInputTool)
 	}
+	tools = append(tools, mcpTools(dCtx)...)
 
 	// random order of tools to avoid bias in the LLM's use of the tools
 	// NOTE: disabled shuffling as it can mess with cache hit rate
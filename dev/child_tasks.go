@@ -0,0 +1,237 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sidekick/domain"
+	"sidekick/srv"
+)
+
+type CreateChildTasksInput struct {
+	WorkspaceId string
+	// FlowId is the id of the flow that split its task into the child tasks
+	FlowId       string
+	ChildTasks   []ChildTaskSpec
+	ChildTaskIds []string
+	FlowType     string
+	FlowOptions  map[string]interface{}
+}
+
+// NewChildTasks builds the child tasks of the given parent task from a task
+// breakdown, using the given ids in order. Child tasks are linked to the
+// parent, and to the child tasks they depend on via blocked_by links. Child
+// tasks with dependencies are blocked until those are complete.
+func NewChildTasks(parent domain.Task, input CreateChildTasksInput) ([]domain.Task, error) {
+	if len(input.ChildTaskIds) != len(input.ChildTasks) {
+		return nil, fmt.Errorf("expected %d child task ids, got %d", len(input.ChildTasks), len(input.ChildTaskIds))
+	}
+
+	idsByKey := make(map[string]string, len(input.ChildTasks))
+	for i, spec := range input.ChildTasks {
+		idsByKey[spec.Key] = input.ChildTaskIds[i]
+	}
+
+	now := time.Now()
+	children := make([]domain.Task, len(input.ChildTasks))
+	for i, spec := range input.ChildTasks {
+		child := domain.Task{
+			WorkspaceId: parent.WorkspaceId,
+			Id:          input.ChildTaskIds[i],
+			Title:       spec.Title,
+			Description: spec.Requirements,
+			Status:      domain.TaskStatusToDo,
+			AgentType:   domain.AgentTypeLLM,
+			FlowType:    input.FlowType,
+			FlowOptions: input.FlowOptions,
+			Created:     now,
+			Updated:     now,
+		}
+		child.AddLink(domain.LinkTypeChild, parent.Id)
+		for _, key := range spec.DependsOn {
+			blockerId, ok := idsByKey[key]
+			if !ok {
+				return nil, fmt.Errorf("child task %s depends on unknown child task %s", spec.Key, key)
+			}
+			child.AddLink(domain.LinkTypeBlockedBy, blockerId)
+			child.Status = domain.TaskStatusBlocked
+		}
+		children[i] = child
+	}
+
+	// inverse links, now that all child ids are known
+	childrenById := make(map[string]*domain.Task, len(children))
+	for i := range children {
+		childrenById[children[i].Id] = &children[i]
+	}
+	for _, child := range children {
+		for _, blockerId := range child.LinkedTaskIds(domain.LinkTypeBlockedBy) {
+			childrenById[blockerId].AddLink(domain.LinkTypeBlocks, child.Id)
+		}
+	}
+
+	return children, nil
+}
+
+// CreateChildTasks persists the child tasks of the task the given flow runs
+// for, linking the task to them as their parent. Persisting is idempotent, as
+// the child task ids are provided.
+func CreateChildTasks(ctx context.Context, storage srv.Storage, input CreateChildTasksInput) ([]domain.Task, error) {
+	flow, err := storage.GetFlow(ctx, input.WorkspaceId, input.FlowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flow %s: %w", input.FlowId, err)
+	}
+	parent, err := storage.GetTask(ctx, input.WorkspaceId, flow.ParentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent task %s: %w", flow.ParentId, err)
+	}
+
+	children, err := NewChildTasks(parent, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if err := storage.PersistTask(ctx, child); err != nil {
+			return nil, fmt.Errorf("failed to persist child task %s: %w", child.Id, err)
+		}
+		parent.AddLink(domain.LinkTypeParent, child.Id)
+	}
+
+	parent.Updated = time.Now()
+	if err := storage.PersistTask(ctx, parent); err != nil {
+		return nil, fmt.Errorf("failed to link parent task %s to child tasks: %w", parent.Id, err)
+	}
+	return children, nil
+}
+
+// GetChildTasksToStart returns the child tasks of the given task that are
+// ready to be started, i.e. to_do tasks that don't have any flows yet. Child
+// tasks that depend on other child tasks are blocked instead, and started when
+// those complete.
+func GetChildTasksToStart(ctx context.Context, storage srv.Storage, workspaceId, taskId string) ([]domain.Task, error) {
+	task, err := storage.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task %s: %w", taskId, err)
+	}
+
+	var toStart []domain.Task
+	for _, childId := range task.LinkedTaskIds(domain.LinkTypeParent) {
+		child, err := storage.GetTask(ctx, workspaceId, childId)
+		if err != nil {
+			if errors.Is(err, srv.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get child task %s: %w", childId, err)
+		}
+		if child.Status != domain.TaskStatusToDo {
+			continue
+		}
+		flows, err := storage.GetFlowsForTask(ctx, workspaceId, childId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flows for child task %s: %w", childId, err)
+		}
+		if len(flows) == 0 {
+			toStart = append(toStart, child)
+		}
+	}
+	return toStart, nil
+}
+
+// AggregateChildTaskStatus returns the status of a parent task given its child
+// tasks, and whether all of them are finished. The parent is complete once all
+// child tasks are, and otherwise failed if any child task failed, or canceled.
+func AggregateChildTaskStatus(children []domain.Task) (domain.TaskStatus, bool) {
+	anyFailed := false
+	anyCanceled := false
+	for _, child := range children {
+		switch child.Status {
+		case domain.TaskStatusComplete:
+		case domain.TaskStatusFailed:
+			anyFailed = true
+		case domain.TaskStatusCanceled:
+			anyCanceled = true
+		default:
+			return domain.TaskStatusInProgress, false
+		}
+	}
+	switch {
+	case anyFailed:
+		return domain.TaskStatusFailed, true
+	case anyCanceled:
+		return domain.TaskStatusCanceled, true
+	default:
+		return domain.TaskStatusComplete, true
+	}
+}
+
+// getChildTasks returns the child tasks of the given task, ignoring ones that
+// no longer exist
+func getChildTasks(ctx context.Context, storage srv.Storage, task domain.Task) ([]domain.Task, error) {
+	var children []domain.Task
+	for _, childId := range task.LinkedTaskIds(domain.LinkTypeParent) {
+		child, err := storage.GetTask(ctx, task.WorkspaceId, childId)
+		if err != nil {
+			if errors.Is(err, srv.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get child task %s: %w", childId, err)
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// UpdateParentTaskStatuses updates the status of the parents of the given
+// task to the aggregate status of their child tasks, once all of those are
+// finished. This cascades to their own parents in turn. The ids of the parent
+// tasks that were finished are returned.
+func UpdateParentTaskStatuses(ctx context.Context, storage srv.Storage, workspaceId, taskId string) ([]string, error) {
+	task, err := storage.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task %s: %w", taskId, err)
+	}
+
+	var finishedIds []string
+	for _, parentId := range task.LinkedTaskIds(domain.LinkTypeChild) {
+		parent, err := storage.GetTask(ctx, workspaceId, parentId)
+		if err != nil {
+			if errors.Is(err, srv.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get parent task %s: %w", parentId, err)
+		}
+		if isFinishedTaskStatus(parent.Status) {
+			continue
+		}
+
+		children, err := getChildTasks(ctx, storage, parent)
+		if err != nil {
+			return nil, err
+		}
+		status, finished := AggregateChildTaskStatus(children)
+		if !finished {
+			continue
+		}
+
+		parent.Status = status
+		parent.AgentType = domain.AgentTypeNone
+		parent.Updated = time.Now()
+		if err := storage.PersistTask(ctx, parent); err != nil {
+			return nil, fmt.Errorf("failed to update parent task %s: %w", parent.Id, err)
+		}
+		finishedIds = append(finishedIds, parent.Id)
+
+		ancestorIds, err := UpdateParentTaskStatuses(ctx, storage, workspaceId, parent.Id)
+		if err != nil {
+			return nil, err
+		}
+		finishedIds = append(finishedIds, ancestorIds...)
+	}
+	return finishedIds, nil
+}
+
+func isFinishedTaskStatus(status domain.TaskStatus) bool {
+	return status == domain.TaskStatusComplete || status == domain.TaskStatusFailed || status == domain.TaskStatusCanceled
+}
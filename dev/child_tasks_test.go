@@ -0,0 +1,160 @@
+package dev
+
+import (
+	"context"
+	"sidekick/domain"
+	"sidekick/srv/sqlite"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChildTasks(t *testing.T) {
+	t.Parallel()
+
+	parent := domain.Task{WorkspaceId: "ws_1", Id: "task_parent"}
+	children, err := NewChildTasks(parent, CreateChildTasksInput{
+		ChildTasks: []ChildTaskSpec{
+			{Key: "1", Title: "Add model", Requirements: "Add the model"},
+			{Key: "2", Title: "Add API", Requirements: "Add the API", DependsOn: []string{"1"}},
+		},
+		ChildTaskIds: []string{"task_1", "task_2"},
+		FlowType:     domain.FlowTypePlannedDev,
+		FlowOptions:  map[string]interface{}{"determineRequirements": false},
+	})
+	require.NoError(t, err)
+	require.Len(t, children, 2)
+
+	assert.Equal(t, "task_1", children[0].Id)
+	assert.Equal(t, "ws_1", children[0].WorkspaceId)
+	assert.Equal(t, "Add model", children[0].Title)
+	assert.Equal(t, "Add the model", children[0].Description)
+	assert.Equal(t, domain.FlowTypePlannedDev, children[0].FlowType)
+	assert.Equal(t, domain.TaskStatusToDo, children[0].Status)
+	assert.Equal(t, domain.AgentTypeLLM, children[0].AgentType)
+	assert.Equal(t, []domain.TaskLink{
+		{LinkType: domain.LinkTypeChild, TargetTaskId: "task_parent"},
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_2"},
+	}, children[0].Links)
+
+	assert.Equal(t, domain.TaskStatusBlocked, children[1].Status)
+	assert.Equal(t, []domain.TaskLink{
+		{LinkType: domain.LinkTypeChild, TargetTaskId: "task_parent"},
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_1"},
+	}, children[1].Links)
+
+	_, err = NewChildTasks(parent, CreateChildTasksInput{
+		ChildTasks:   []ChildTaskSpec{{Key: "1"}},
+		ChildTaskIds: []string{},
+	})
+	assert.Error(t, err)
+}
+
+func TestAggregateChildTaskStatus(t *testing.T) {
+	t.Parallel()
+
+	children := func(statuses ...domain.TaskStatus) []domain.Task {
+		var tasks []domain.Task
+		for _, status := range statuses {
+			tasks = append(tasks, domain.Task{Status: status})
+		}
+		return tasks
+	}
+
+	testCases := []struct {
+		name             string
+		children         []domain.Task
+		expectedStatus   domain.TaskStatus
+		expectedFinished bool
+	}{
+		{"all complete", children(domain.TaskStatusComplete, domain.TaskStatusComplete), domain.TaskStatusComplete, true},
+		{"some in progress", children(domain.TaskStatusComplete, domain.TaskStatusInProgress), domain.TaskStatusInProgress, false},
+		{"some blocked", children(domain.TaskStatusFailed, domain.TaskStatusBlocked), domain.TaskStatusInProgress, false},
+		{"some failed", children(domain.TaskStatusComplete, domain.TaskStatusFailed, domain.TaskStatusCanceled), domain.TaskStatusFailed, true},
+		{"some canceled", children(domain.TaskStatusComplete, domain.TaskStatusCanceled), domain.TaskStatusCanceled, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, finished := AggregateChildTaskStatus(tc.children)
+			assert.Equal(t, tc.expectedStatus, status)
+			assert.Equal(t, tc.expectedFinished, finished)
+		})
+	}
+}
+
+func TestCreateChildTasks(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "child_tasks_test")
+	workspaceId := "ws_1"
+
+	parent := domain.Task{WorkspaceId: workspaceId, Id: "task_parent", Status: domain.TaskStatusInProgress, FlowType: domain.FlowTypeDecompose}
+	persistTasks(t, storage, parent)
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_1", ParentId: parent.Id, Type: domain.FlowTypeDecompose}))
+
+	input := CreateChildTasksInput{
+		WorkspaceId: workspaceId,
+		FlowId:      "flow_1",
+		ChildTasks: []ChildTaskSpec{
+			{Key: "1", Title: "Add model", Requirements: "Add the model"},
+			{Key: "2", Title: "Add API", Requirements: "Add the API", DependsOn: []string{"1"}},
+		},
+		ChildTaskIds: []string{"task_1", "task_2"},
+		FlowType:     domain.FlowTypePlannedDev,
+	}
+	_, err := CreateChildTasks(ctx, storage, input)
+	require.NoError(t, err)
+
+	// retrying doesn't duplicate anything
+	_, err = CreateChildTasks(ctx, storage, input)
+	require.NoError(t, err)
+
+	parent, err = storage.GetTask(ctx, workspaceId, parent.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task_1", "task_2"}, parent.LinkedTaskIds(domain.LinkTypeParent))
+
+	toStart, err := GetChildTasksToStart(ctx, storage, workspaceId, parent.Id)
+	require.NoError(t, err)
+	require.Len(t, toStart, 1)
+	assert.Equal(t, "task_1", toStart[0].Id)
+}
+
+func TestUpdateParentTaskStatuses(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "child_tasks_test")
+	workspaceId := "ws_1"
+
+	// the epic is split into the feature, which is split into tasks 1 and 2
+	epic := domain.Task{WorkspaceId: workspaceId, Id: "task_epic", Status: domain.TaskStatusInProgress, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeParent, TargetTaskId: "task_feature"},
+	}}
+	feature := domain.Task{WorkspaceId: workspaceId, Id: "task_feature", Status: domain.TaskStatusInProgress, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeChild, TargetTaskId: "task_epic"},
+		{LinkType: domain.LinkTypeParent, TargetTaskId: "task_1"},
+		{LinkType: domain.LinkTypeParent, TargetTaskId: "task_2"},
+	}}
+	task1 := domain.Task{WorkspaceId: workspaceId, Id: "task_1", Status: domain.TaskStatusComplete, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeChild, TargetTaskId: "task_feature"},
+	}}
+	task2 := domain.Task{WorkspaceId: workspaceId, Id: "task_2", Status: domain.TaskStatusInProgress, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeChild, TargetTaskId: "task_feature"},
+	}}
+	persistTasks(t, storage, epic, feature, task1, task2)
+
+	finishedIds, err := UpdateParentTaskStatuses(ctx, storage, workspaceId, task1.Id)
+	require.NoError(t, err)
+	assert.Empty(t, finishedIds)
+
+	task2.Status = domain.TaskStatusComplete
+	persistTasks(t, storage, task2)
+	finishedIds, err = UpdateParentTaskStatuses(ctx, storage, workspaceId, task2.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{feature.Id, epic.Id}, finishedIds)
+
+	for _, id := range finishedIds {
+		task, err := storage.GetTask(ctx, workspaceId, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TaskStatusComplete, task.Status)
+		assert.Equal(t, domain.AgentTypeNone, task.AgentType)
+	}
+}
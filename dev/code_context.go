@@ -153,11 +153,12 @@ var GetRepoSummaryForPrompt = GetRankedRepoSummary
 func GetRankedRepoSummary(dCtx DevContext, rankQuery string, charLimit int) (string, error) {
 	options := persisted_ai.RankedDirSignatureOutlineOptions{
 		RankedViaEmbeddingOptions: persisted_ai.RankedViaEmbeddingOptions{
-			WorkspaceId:  dCtx.WorkspaceId,
-			EnvContainer: *dCtx.EnvContainer,
-			RankQuery:    rankQuery,
-			Secrets:      *dCtx.Secrets,
-			ModelConfig:  dCtx.GetEmbeddingModelConfig(common.DefaultKey),
+			WorkspaceId:     dCtx.WorkspaceId,
+			EnvContainer:    *dCtx.EnvContainer,
+			RankQuery:       rankQuery,
+			Secrets:         *dCtx.Secrets,
+			ModelConfig:     dCtx.GetEmbeddingModelConfig(common.DefaultKey),
+			RetrievalConfig: dCtx.GetRetrievalConfig(common.DefaultKey),
 		},
 		CharLimit: charLimit,
 	}
@@ -0,0 +1,409 @@
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+
+	"sidekick/coding/git"
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/env"
+	"sidekick/persisted_ai"
+	"sidekick/utils"
+)
+
+// Steps that custom flows defined in the repo config can be composed from.
+const (
+	CustomFlowStepDetermineRequirements = "determine_requirements"
+	CustomFlowStepPrepareCodeContext    = "prepare_code_context"
+	CustomFlowStepEditCode              = "edit_code"
+	CustomFlowStepRunTests              = "run_tests"
+	CustomFlowStepCheckCriteria         = "check_criteria"
+	CustomFlowStepAutoFormat            = "auto_format"
+	CustomFlowStepUserApproval          = "user_approval"
+	CustomFlowStepMerge                 = "merge"
+)
+
+// customFlowStepNames maps each available step to the name of the subflow it
+// is tracked as
+var customFlowStepNames = map[string]string{
+	CustomFlowStepDetermineRequirements: "Determine requirements",
+	CustomFlowStepPrepareCodeContext:    "Prepare code context",
+	CustomFlowStepEditCode:              "Edit code",
+	CustomFlowStepRunTests:              "Run tests",
+	CustomFlowStepCheckCriteria:         "Check criteria",
+	CustomFlowStepAutoFormat:            "Auto-format code",
+	CustomFlowStepUserApproval:          "Review changes",
+	CustomFlowStepMerge:                 "Merge",
+}
+
+type CustomFlowInput struct {
+	WorkspaceId  string
+	RepoDir      string
+	Requirements string
+	CustomFlowOptions
+}
+
+type CustomFlowOptions struct {
+	// CustomFlowName is the name of the flow to run, as defined under flows
+	// in the repo config
+	CustomFlowName  string                 `json:"customFlowName,omitempty"`
+	EnvType         env.EnvType            `json:"envType,omitempty" default:"local"`
+	StartBranch     *string                `json:"startBranch,omitempty"`
+	ConfigOverrides common.ConfigOverrides `json:"configOverrides"`
+}
+
+// CustomFlowWorkflow runs a flow defined in the repo config as an ordered list
+// of steps, each tracked as its own subflow. When tests fail, criteria are not
+// met or the user rejects the changes, the flow returns to the most recent
+// edit_code step with that feedback, until max_iterations is reached.
+func CustomFlowWorkflow(ctx workflow.Context, input CustomFlowInput) (result string, err error) {
+	// don't recover panics in development so we can debug via temporal UI, at
+	// the cost of failed tasks appearing stuck without UI feedback in sidekick
+	if SideAppEnv != "development" {
+		defer func() {
+			if r := recover(); r != nil {
+				signalWorkflowFailureOrCancel(ctx)
+				var ok bool
+				err, ok = r.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}
+		}()
+	}
+
+	ctx = utils.DefaultRetryCtx(ctx)
+
+	dCtx, err := SetupDevContext(ctx, input.WorkspaceId, input.RepoDir, string(input.EnvType), input.StartBranch, input.Requirements, input.ConfigOverrides)
+	if err != nil {
+		signalWorkflowFailureOrCancel(ctx)
+		return "", err
+	}
+	defer teardownEnv(dCtx)
+	defer handleFlowCancel(dCtx)
+	defer stopActiveDevRun(dCtx)
+	defer func() {
+		if err != nil && !errors.Is(dCtx.Err(), workflow.ErrCanceled) {
+			_ = signalWorkflowClosure(dCtx, "failed")
+			return
+		}
+	}()
+
+	SetupPauseHandler(dCtx, "Paused for user input", nil)
+	SetupUserActionHandler(dCtx)
+	SetupDevRunConfigQuery(dCtx)
+	SetupDevRunStateQuery(dCtx)
+
+	err = EnsurePrerequisites(dCtx)
+	if err != nil {
+		return "", err
+	}
+
+	flowConfig, err := resolveCustomFlow(dCtx.RepoConfig, input.CustomFlowName, dCtx.EnvContainer.Env.GetType())
+	if err != nil {
+		return "", err
+	}
+
+	runner := &customFlowRunner{
+		dCtx:         dCtx,
+		requirements: input.Requirements,
+		startBranch:  input.StartBranch,
+		chatHistory:  NewVersionedChatHistory(dCtx, dCtx.WorkspaceId),
+	}
+	result, err = runner.run(flowConfig.Steps)
+	if err != nil {
+		return "", err
+	}
+
+	err = signalWorkflowClosure(dCtx, "completed")
+	if err != nil {
+		return "", fmt.Errorf("failed to signal workflow closure: %w", err)
+	}
+	return result, nil
+}
+
+// resolveCustomFlow looks up the named custom flow in the repo config and
+// validates it can run in the given environment type.
+func resolveCustomFlow(repoConfig common.RepoConfig, name string, envType env.EnvType) (common.CustomFlowConfig, error) {
+	available := make([]string, 0, len(repoConfig.Flows))
+	for flowName := range repoConfig.Flows {
+		available = append(available, flowName)
+	}
+	sort.Strings(available)
+
+	if len(available) == 0 {
+		return common.CustomFlowConfig{}, errors.New("no custom flows are defined in the repo config: add them under \"flows\" in side.yml or side.toml")
+	}
+	if name == "" {
+		return common.CustomFlowConfig{}, fmt.Errorf("the customFlowName flow option is required, available custom flows: %s", strings.Join(available, ", "))
+	}
+	flowConfig, ok := repoConfig.Flows[name]
+	if !ok {
+		return common.CustomFlowConfig{}, fmt.Errorf("custom flow %q is not defined in the repo config, available custom flows: %s", name, strings.Join(available, ", "))
+	}
+	if err := validateCustomFlow(flowConfig, envType); err != nil {
+		return common.CustomFlowConfig{}, fmt.Errorf("invalid custom flow %q: %w", name, err)
+	}
+	return flowConfig, nil
+}
+
+func validateCustomFlow(flowConfig common.CustomFlowConfig, envType env.EnvType) error {
+	if len(flowConfig.Steps) == 0 {
+		return errors.New("at least one step is required")
+	}
+	for i, step := range flowConfig.Steps {
+		if _, ok := customFlowStepNames[step]; !ok {
+			known := make([]string, 0, len(customFlowStepNames))
+			for name := range customFlowStepNames {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown step %q, available steps: %s", step, strings.Join(known, ", "))
+		}
+		if step == CustomFlowStepMerge {
+			if !envType.UsesGitWorktree() {
+				return fmt.Errorf("the %s step requires an environment type that uses a git worktree, got %q", step, envType)
+			}
+			// the worktree is cleaned up after merging, so nothing can follow
+			if i != len(flowConfig.Steps)-1 {
+				return fmt.Errorf("the %s step must be the last step", step)
+			}
+		}
+	}
+	return nil
+}
+
+// customFlowRunner holds the state that is passed between the steps of a
+// custom flow
+type customFlowRunner struct {
+	dCtx                 DevContext
+	requirements         string
+	startBranch          *string
+	codeContext          string
+	contextSizeExtension int
+	chatHistory          *persisted_ai.ChatHistoryContainer
+
+	// promptInfo is the prompt for the next edit_code step, nil until the
+	// first edit
+	promptInfo         PromptInfo
+	testOutput         string
+	approval           *MergeApprovalResponse
+	lastReviewTreeHash string
+	reviewComments     []ReviewComment
+	iterations         int
+	stepRuns           map[string]int
+}
+
+func (r *customFlowRunner) run(steps []string) (string, error) {
+	maxIterations := 17
+	if r.dCtx.RepoConfig.MaxIterations > 0 {
+		maxIterations = r.dCtx.RepoConfig.MaxIterations
+	}
+	r.stepRuns = map[string]int{}
+
+	for i := 0; i < len(steps); {
+		step := steps[i]
+		r.stepRuns[step]++
+		subflowName := customFlowStepNames[step]
+		if r.stepRuns[step] > 1 {
+			subflowName = fmt.Sprintf("%s (%d)", subflowName, r.stepRuns[step])
+		}
+
+		feedback, err := RunSubflow(r.dCtx, step, subflowName, func(_ domain.Subflow) (*FeedbackInfo, error) {
+			return r.runStep(step)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if feedback == nil {
+			i++
+			continue
+		}
+
+		editIndex := lastStepIndex(steps[:i], CustomFlowStepEditCode)
+		if editIndex < 0 {
+			return "", fmt.Errorf("%s step did not pass and there is no earlier edit_code step to address it:\n\n%s", step, feedback.Feedback)
+		}
+		r.iterations++
+		if r.iterations >= maxIterations {
+			return "", fmt.Errorf("%s step did not pass, max iterations reached", step)
+		}
+		r.promptInfo = *feedback
+		i = editIndex
+	}
+
+	return r.testOutput, nil
+}
+
+func lastStepIndex(steps []string, step string) int {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i] == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// runStep runs a single step, returning feedback for the previous edit_code
+// step when the step did not pass
+func (r *customFlowRunner) runStep(step string) (*FeedbackInfo, error) {
+	dCtx := r.dCtx
+	switch step {
+	case CustomFlowStepDetermineRequirements:
+		devRequirements, err := BuildDevRequirements(dCtx, InitialDevRequirementsInfo{Requirements: r.requirements})
+		if err != nil {
+			return nil, err
+		}
+		r.requirements = devRequirements.String()
+
+	case CustomFlowStepPrepareCodeContext:
+		codeContext, fullCodeContext, err := PrepareInitialCodeContext(dCtx, r.requirements, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare code context: %w", err)
+		}
+		r.codeContext = codeContext
+		r.contextSizeExtension = len(fullCodeContext) - len(codeContext)
+
+	case CustomFlowStepEditCode:
+		promptInfo := r.promptInfo
+		if promptInfo == nil {
+			promptInfo = InitialCodeInfo{CodeContext: r.codeContext, Requirements: r.requirements}
+		}
+		modelConfig := dCtx.GetModelConfig(common.CodingKey, 0, "default")
+		if err := EditCode(dCtx, modelConfig, r.contextSizeExtension, r.chatHistory, promptInfo); err != nil {
+			return nil, fmt.Errorf("failed to write edit blocks: %w", err)
+		}
+		// subsequent edits continue from the chat history unless a later step
+		// provides feedback, and changes must be approved again
+		r.promptInfo = SkipInfo{}
+		r.approval = nil
+
+	case CustomFlowStepRunTests:
+		testResult, err := RunTests(dCtx, dCtx.RepoConfig.TestCommands)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run tests: %w", err)
+		}
+		if !testResult.TestsPassed && !testResult.TestsSkipped {
+			return &FeedbackInfo{Feedback: testResult.Output, Type: FeedbackTypeTestFailure}, nil
+		}
+		testOutput := ""
+		if !testResult.TestsSkipped {
+			testOutput = testResult.Output
+		}
+
+		if len(dCtx.RepoConfig.IntegrationTestCommands) > 0 {
+			integrationTestResult, err := RunTests(dCtx, dCtx.RepoConfig.IntegrationTestCommands)
+			if err != nil {
+				return nil, fmt.Errorf("failed to run integration tests: %w", err)
+			}
+			if !integrationTestResult.TestsPassed && !integrationTestResult.TestsSkipped {
+				return &FeedbackInfo{Feedback: integrationTestResult.Output, Type: FeedbackTypeTestFailure}, nil
+			}
+			if !integrationTestResult.TestsSkipped {
+				testOutput += "\n\n" + integrationTestResult.Output
+			}
+		}
+		r.testOutput = testOutput
+
+	case CustomFlowStepCheckCriteria:
+		baseBranch := dCtx.ExecContext.GlobalState.GetStringValue(common.KeyCurrentTargetBranch)
+		if baseBranch == "" && r.startBranch != nil {
+			baseBranch = *r.startBranch
+		}
+		fulfillment, err := CheckWorkMeetsCriteria(dCtx, CheckWorkInfo{
+			Requirements:       r.requirements,
+			AutoChecks:         r.testOutput,
+			LastReviewTreeHash: r.lastReviewTreeHash,
+			BaseBranch:         baseBranch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if requirements are fulfilled: %w", err)
+		}
+		if !fulfillment.IsFulfilled {
+			return &FeedbackInfo{
+				Feedback: fmt.Sprintf("The requirements were not fulfilled.\n\nAnalysis: %s\n\nFeedback: %s", fulfillment.Analysis, fulfillment.FeedbackMessage),
+				Type:     FeedbackTypeAutoReview,
+			}, nil
+		}
+
+	case CustomFlowStepAutoFormat:
+		if err := AutoFormatCode(dCtx); err != nil {
+			return nil, err
+		}
+
+	case CustomFlowStepUserApproval:
+		if !dCtx.EnvContainer.Env.GetType().UsesGitWorktree() {
+			return r.getLocalApproval()
+		}
+		approval, _, treeHash, err := getWorktreeMergeApproval(dCtx, r.mergeParams(), r.lastReviewTreeHash)
+		if err != nil {
+			return nil, err
+		}
+		if !approval.Approved {
+			return r.rejected(approval, treeHash), nil
+		}
+		r.approval = &approval
+
+	case CustomFlowStepMerge:
+		if r.approval != nil {
+			_, _, _, err := mergeApprovedWorktree(dCtx, r.mergeParams(), *r.approval, "", "")
+			return nil, err
+		}
+		_, approval, treeHash, err := mergeWorktreeIfApproved(dCtx, r.mergeParams(), r.lastReviewTreeHash)
+		if err != nil {
+			return nil, err
+		}
+		if !approval.Approved {
+			return r.rejected(approval, treeHash), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown custom flow step %q", step)
+	}
+	return nil, nil
+}
+
+func (r *customFlowRunner) mergeParams() MergeWithReviewParams {
+	return MergeWithReviewParams{
+		CommitRequired: true,
+		Requirements:   r.requirements,
+		StartBranch:    r.startBranch,
+		ReviewComments: r.reviewComments,
+	}
+}
+
+// rejected records a rejected merge approval so that the next review shows
+// the changes made since, and returns the review as feedback
+func (r *customFlowRunner) rejected(approval MergeApprovalResponse, treeHash string) *FeedbackInfo {
+	// retain the chosen target branch for the next review, in case it changed
+	r.startBranch = &approval.TargetBranch
+	r.dCtx.ExecContext.GlobalState.SetValue(common.KeyCurrentTargetBranch, approval.TargetBranch)
+	r.lastReviewTreeHash = treeHash
+	r.reviewComments = approval.ReviewComments
+	return &FeedbackInfo{Feedback: approval.Feedback(), Type: FeedbackTypeUserGuidance}
+}
+
+// getLocalApproval asks the user to approve uncommitted changes in an
+// environment without a worktree to merge
+func (r *customFlowRunner) getLocalApproval() (*FeedbackInfo, error) {
+	gitDiff, err := git.GitDiff(r.dCtx.ExecContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git diff: %w", err)
+	}
+	response, err := GetUserApproval(r.dCtx, "changes", "Please review these changes", map[string]any{
+		"gitDiff": gitDiff,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Approved == nil || !*response.Approved {
+		return &FeedbackInfo{Feedback: response.Content, Type: FeedbackTypeUserGuidance}, nil
+	}
+	return nil, nil
+}
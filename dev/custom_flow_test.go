@@ -0,0 +1,132 @@
+package dev
+
+import (
+	"testing"
+
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/env"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRepoConfigActivity_CustomFlows(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		yamlContent := `
+flows:
+  quick_fix:
+    description: Fix without review
+    steps: [prepare_code_context, edit_code, run_tests]
+  reviewed:
+    steps:
+      - determine_requirements
+      - edit_code
+      - user_approval
+      - merge
+`
+		config, err := GetRepoConfigActivity(setupTestEnvWithFilename(t, "side.yml", yamlContent, "", ""))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]common.CustomFlowConfig{
+			"quick_fix": {Description: "Fix without review", Steps: []string{"prepare_code_context", "edit_code", "run_tests"}},
+			"reviewed":  {Steps: []string{"determine_requirements", "edit_code", "user_approval", "merge"}},
+		}, config.Flows)
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		tomlContent := `
+[flows.quick_fix]
+steps = ["edit_code", "run_tests", "check_criteria"]
+`
+		config, err := GetRepoConfigActivity(setupTestEnvWithFilename(t, "side.toml", tomlContent, "", ""))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"edit_code", "run_tests", "check_criteria"}, config.Flows["quick_fix"].Steps)
+	})
+}
+
+func TestResolveCustomFlow(t *testing.T) {
+	t.Parallel()
+
+	repoConfig := common.RepoConfig{
+		Flows: map[string]common.CustomFlowConfig{
+			"quick_fix": {Steps: []string{"prepare_code_context", "edit_code", "run_tests"}},
+			"reviewed":  {Steps: []string{"edit_code", "user_approval", "merge"}},
+			"typo":      {Steps: []string{"edit_code", "run_test"}},
+			"early":     {Steps: []string{"edit_code", "merge", "run_tests"}},
+			"empty":     {},
+		},
+	}
+
+	flowConfig, err := resolveCustomFlow(repoConfig, "quick_fix", env.EnvTypeLocal)
+	require.NoError(t, err)
+	assert.Equal(t, repoConfig.Flows["quick_fix"], flowConfig)
+
+	flowConfig, err = resolveCustomFlow(repoConfig, "reviewed", env.EnvTypeLocalGitWorktree)
+	require.NoError(t, err)
+	assert.Equal(t, repoConfig.Flows["reviewed"], flowConfig)
+
+	_, err = resolveCustomFlow(repoConfig, "reviewed", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, `invalid custom flow "reviewed": the merge step requires an environment type that uses a git worktree`)
+
+	_, err = resolveCustomFlow(repoConfig, "typo", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, `unknown step "run_test", available steps: auto_format, check_criteria,`)
+
+	_, err = resolveCustomFlow(repoConfig, "early", env.EnvTypeLocalGitWorktree)
+	assert.ErrorContains(t, err, "the merge step must be the last step")
+
+	_, err = resolveCustomFlow(repoConfig, "empty", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, "at least one step is required")
+
+	_, err = resolveCustomFlow(repoConfig, "missing", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, `custom flow "missing" is not defined in the repo config, available custom flows: early, empty, quick_fix, reviewed, typo`)
+
+	_, err = resolveCustomFlow(repoConfig, "", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, "the customFlowName flow option is required")
+
+	_, err = resolveCustomFlow(common.RepoConfig{}, "quick_fix", env.EnvTypeLocal)
+	assert.ErrorContains(t, err, "no custom flows are defined in the repo config")
+}
+
+func TestCustomFlowType_BuildInput(t *testing.T) {
+	t.Parallel()
+
+	def, ok := domain.GetFlowType(domain.FlowTypeCustom)
+	require.True(t, ok)
+	assert.NoError(t, domain.ValidateFlowOptions(domain.FlowTypeCustom, map[string]interface{}{
+		"customFlowName":        "quick_fix",
+		"determineRequirements": true,
+		"envType":               "local_git_worktree",
+	}))
+	assert.Error(t, domain.ValidateFlowOptions(domain.FlowTypeCustom, map[string]interface{}{"customFlowName": 1}))
+
+	input, err := def.BuildInput(domain.FlowLaunchParams{
+		WorkspaceId:  "ws_1",
+		RepoDir:      "/repo",
+		Requirements: "do the thing",
+		FlowOptions: map[string]interface{}{
+			"customFlowName": "quick_fix",
+			"envType":        "local_git_worktree",
+			"startBranch":    "main",
+		},
+	})
+	require.NoError(t, err)
+
+	customInput, ok := input.(CustomFlowInput)
+	require.True(t, ok)
+	assert.Equal(t, "ws_1", customInput.WorkspaceId)
+	assert.Equal(t, "/repo", customInput.RepoDir)
+	assert.Equal(t, "do the thing", customInput.Requirements)
+	assert.Equal(t, "quick_fix", customInput.CustomFlowName)
+	assert.Equal(t, env.EnvTypeLocalGitWorktree, customInput.EnvType)
+	require.NotNil(t, customInput.StartBranch)
+	assert.Equal(t, "main", *customInput.StartBranch)
+}
+
+func TestLastStepIndex(t *testing.T) {
+	t.Parallel()
+
+	steps := []string{"edit_code", "run_tests", "edit_code", "check_criteria"}
+	assert.Equal(t, 2, lastStepIndex(steps, "edit_code"))
+	assert.Equal(t, 0, lastStepIndex(steps[:2], "edit_code"))
+	assert.Equal(t, -1, lastStepIndex(steps, "merge"))
+}
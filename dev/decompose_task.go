@@ -0,0 +1,431 @@
+package dev
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/env"
+	"sidekick/flow_action"
+	"sidekick/llm"
+	"sidekick/llm2"
+	"sidekick/persisted_ai"
+	"sidekick/utils"
+
+	"github.com/invopop/jsonschema"
+	"github.com/sashabaranov/go-openai"
+	"go.temporal.io/sdk/workflow"
+)
+
+type DecomposeTaskInput struct {
+	WorkspaceId  string
+	RepoDir      string
+	Requirements string
+	DecomposeTaskOptions
+}
+
+type DecomposeTaskOptions struct {
+	DetermineRequirements bool `json:"determineRequirements"`
+	// ChildFlowType is the flow type the child tasks are started with
+	ChildFlowType string `json:"childFlowType,omitempty" default:"planned_dev"`
+	// EnvType, StartBranch and ConfigOverrides are passed on to the child
+	// tasks. Splitting the task itself only reads the repo, so always runs in
+	// the local environment.
+	EnvType         env.EnvType            `json:"envType,omitempty" default:"local"`
+	StartBranch     *string                `json:"startBranch,omitempty"`
+	ConfigOverrides common.ConfigOverrides `json:"configOverrides"`
+}
+
+type TaskBreakdown struct {
+	Analysis   string          `json:"analysis" jsonschema:"description=High-level analysis of how the task should be split\\, before defining the child tasks."`
+	ChildTasks []ChildTaskSpec `json:"child_tasks" jsonschema:"description=The child tasks that together fulfill the requirements of the original task."`
+}
+
+type ChildTaskSpec struct {
+	Key          string   `json:"key" jsonschema:"description=Short unique identifier for the child task\\, eg \"1\"\\, used to refer to it in depends_on."`
+	Title        string   `json:"title" jsonschema:"description=Short title summarizing the child task."`
+	Requirements string   `json:"requirements" jsonschema:"description=Self-contained requirements for the child task formatted with markdown\\, including acceptance criteria. Must make sense without the original requirements or the other child tasks."`
+	DependsOn    []string `json:"depends_on,omitempty" jsonschema:"description=Keys of the child tasks that must be completed before this one can start\\, as it builds on their changes."`
+}
+
+var recordTaskBreakdownTool = llm.Tool{
+	Name:        "record_task_breakdown",
+	Description: "Records how a large task is split into smaller child tasks, along with the dependencies between them.",
+	Parameters:  (&jsonschema.Reflector{DoNotReference: true}).Reflect(&TaskBreakdown{}),
+}
+
+// DecomposeTaskWorkflow splits a task that is too large for a single flow into
+// child tasks. Once the user approves the breakdown, the child tasks are
+// created, linked to the task as its children, and started in dependency
+// order. The task is complete once all its child tasks are.
+func DecomposeTaskWorkflow(ctx workflow.Context, input DecomposeTaskInput) (breakdown TaskBreakdown, err error) {
+	// don't recover panics in development so we can debug via temporal UI, at
+	// the cost of failed tasks appearing stuck without UI feedback in sidekick
+	if SideAppEnv != "development" {
+		defer func() {
+			if r := recover(); r != nil {
+				signalWorkflowFailureOrCancel(ctx)
+				var ok bool
+				err, ok = r.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}
+		}()
+	}
+
+	ctx = utils.DefaultRetryCtx(ctx)
+
+	childFlowType := input.ChildFlowType
+	if childFlowType == "" {
+		childFlowType = domain.FlowTypePlannedDev
+	}
+	if _, ok := domain.GetFlowType(childFlowType); !ok {
+		signalWorkflowFailureOrCancel(ctx)
+		return TaskBreakdown{}, fmt.Errorf("invalid child flow type '%s'", childFlowType)
+	}
+
+	dCtx, err := SetupDevContext(ctx, input.WorkspaceId, input.RepoDir, string(env.EnvTypeLocal), nil, input.Requirements, input.ConfigOverrides)
+	if err != nil {
+		signalWorkflowFailureOrCancel(ctx)
+		return TaskBreakdown{}, fmt.Errorf("failed to setup dev context: %v", err)
+	}
+	defer teardownEnv(dCtx)
+	defer handleFlowCancel(dCtx)
+	defer func() {
+		if err != nil && !errors.Is(dCtx.Err(), workflow.ErrCanceled) {
+			_ = signalWorkflowClosure(dCtx, "failed")
+			return
+		}
+	}()
+
+	SetupPauseHandler(dCtx, "Paused for user input", nil)
+	SetupUserActionHandler(dCtx)
+
+	err = EnsurePrerequisites(dCtx)
+	if err != nil {
+		return TaskBreakdown{}, err
+	}
+
+	requirements := input.Requirements
+	if input.DetermineRequirements {
+		refinedRequirements, err := BuildDevRequirements(dCtx, InitialDevRequirementsInfo{Requirements: requirements})
+		if err != nil {
+			return TaskBreakdown{}, err
+		}
+		requirements = refinedRequirements.String()
+	}
+
+	approvedBreakdown, err := BreakDownTask(dCtx, requirements)
+	if err != nil {
+		return TaskBreakdown{}, err
+	}
+
+	// ids are generated here rather than in the activity, so that retrying it
+	// doesn't create duplicate tasks
+	childTaskIds := make([]string, len(approvedBreakdown.ChildTasks))
+	for i := range childTaskIds {
+		childTaskIds[i] = "task_" + ksuidSideEffect(dCtx)
+	}
+
+	var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
+	err = workflow.ExecuteActivity(dCtx, ima.CreateChildTasks, CreateChildTasksInput{
+		WorkspaceId:  input.WorkspaceId,
+		FlowId:       workflow.GetInfo(dCtx).WorkflowExecution.ID,
+		ChildTasks:   approvedBreakdown.ChildTasks,
+		ChildTaskIds: childTaskIds,
+		FlowType:     childFlowType,
+		FlowOptions:  childFlowOptions(input.DecomposeTaskOptions),
+	}).Get(dCtx, nil)
+	if err != nil {
+		return TaskBreakdown{}, fmt.Errorf("failed to create child tasks: %w", err)
+	}
+
+	// the child tasks are started once the manager workflow sees this flow
+	// complete
+	err = signalWorkflowClosure(dCtx, "completed")
+	if err != nil {
+		return TaskBreakdown{}, fmt.Errorf("failed to signal workflow closure: %v", err)
+	}
+
+	return *approvedBreakdown, nil
+}
+
+// childFlowOptions returns the flow options child tasks are created with.
+// Requirements were already determined for the child tasks while splitting,
+// and child tasks that depend on other child tasks start from the branch
+// those were merged into.
+func childFlowOptions(options DecomposeTaskOptions) map[string]interface{} {
+	flowOptions := map[string]interface{}{
+		"determineRequirements": false,
+	}
+	if options.EnvType != "" {
+		flowOptions["envType"] = string(options.EnvType)
+	}
+	if options.StartBranch != nil {
+		flowOptions["startBranch"] = *options.StartBranch
+	}
+	if options.EnvType.UsesGitWorktree() {
+		flowOptions[FlowOptionStartFromBlockerBranch] = true
+	}
+	var configOverrides map[string]interface{}
+	utils.Transcode(options.ConfigOverrides, &configOverrides)
+	if len(configOverrides) > 0 {
+		flowOptions["configOverrides"] = configOverrides
+	}
+	return flowOptions
+}
+
+type breakDownTaskState struct {
+	contextSizeExtension int
+}
+
+func BreakDownTask(dCtx DevContext, requirements string) (*TaskBreakdown, error) {
+	return RunSubflow(dCtx, "task_breakdown", "Break Down Task", func(_ domain.Subflow) (*TaskBreakdown, error) {
+		return breakDownTaskSubflow(dCtx, requirements)
+	})
+}
+
+func breakDownTaskSubflow(dCtx DevContext, requirements string) (*TaskBreakdown, error) {
+	codeContext, fullCodeContext, err := PrepareInitialCodeContext(dCtx, requirements, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare code context: %w", err)
+	}
+
+	chatHistory := NewVersionedChatHistory(dCtx, dCtx.WorkspaceId)
+	data := map[string]interface{}{
+		"codeContext":                     codeContext,
+		"requirements":                    requirements,
+		"recordTaskBreakdownFunctionName": recordTaskBreakdownTool.Name,
+	}
+	if !dCtx.RepoConfig.DisableHumanInTheLoop {
+		data["getHelpOrInputFunctionName"] = getHelpOrInputTool.Name
+	}
+	err = AppendChatHistory(dCtx.ExecContext, chatHistory, llm.ChatMessage{
+		Role:         llm.ChatMessageRoleUser,
+		Content:      RenderPrompt(TaskBreakdownInitial, data),
+		CacheControl: "ephemeral",
+		ContextType:  ContextTypeInitialInstructions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	maxIterations := 17
+	if dCtx.RepoConfig.MaxPlanningIterations > 0 {
+		maxIterations = dCtx.RepoConfig.MaxPlanningIterations
+	}
+	feedbackIterations := 9
+	if cfg, ok := dCtx.RepoConfig.AgentConfig[common.PlanningKey]; ok && cfg.AutoIterations > 0 {
+		feedbackIterations = cfg.AutoIterations
+	}
+
+	return LlmLoop(
+		dCtx,
+		chatHistory,
+		breakDownTaskIteration,
+		WithInitialState(&breakDownTaskState{contextSizeExtension: len(fullCodeContext) - len(codeContext)}),
+		WithFeedbackEvery(feedbackIterations),
+		WithMaxIterations(maxIterations),
+	)
+}
+
+func breakDownTaskIteration(iteration *LlmIteration) (*TaskBreakdown, error) {
+	state, ok := iteration.State.(*breakDownTaskState)
+	if !ok {
+		return nil, fmt.Errorf("Invalid llm iteration state type, expected *breakDownTaskState: %v", iteration.State)
+	}
+
+	modelConfig := iteration.ExecCtx.GetModelConfig(common.PlanningKey, 0, "default")
+	maxLength := min(defaultMaxChatHistoryLength+state.contextSizeExtension, extendedMaxChatHistoryLength)
+	ManageChatHistory(iteration.ExecCtx, iteration.ChatHistory, iteration.ExecCtx.WorkspaceId, maxLength, modelConfig)
+
+	chatResponse, err := generateTaskBreakdown(iteration.ExecCtx, iteration.ChatHistory)
+	if err != nil {
+		return nil, fmt.Errorf("error generating task breakdown: %w", err)
+	}
+	if err := AppendChatHistory(iteration.ExecCtx.ExecContext, iteration.ChatHistory, chatResponse.GetMessage()); err != nil {
+		return nil, err
+	}
+
+	if len(chatResponse.GetMessage().GetToolCalls()) == 0 {
+		if chatResponse.GetStopReason() == string(openai.FinishReasonStop) || chatResponse.GetStopReason() == string(openai.FinishReasonToolCalls) {
+			feedback := "Expected a tool call to record the task breakdown, but didn't get it. Embedding the json in the content is not sufficient. Please record the task breakdown via the " + recordTaskBreakdownTool.Name + " tool."
+			if err := AppendChatHistory(iteration.ExecCtx.ExecContext, iteration.ChatHistory, llm.ChatMessage{
+				Role:    llm.ChatMessageRoleUser,
+				Content: renderGeneralFeedbackPrompt(feedback, ""),
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil // continue the loop
+	}
+
+	var approvedBreakdown *TaskBreakdown
+	customHandlers := map[string]func(DevContext, llm.ToolCall) (llm2.ToolResultBlock, error){
+		recordTaskBreakdownTool.Name: func(dCtx DevContext, toolCall llm.ToolCall) (llm2.ToolResultBlock, error) {
+			result := llm2.ToolResultBlock{
+				Name:       toolCall.Name,
+				ToolCallId: toolCall.Id,
+			}
+
+			var breakdown TaskBreakdown
+			if err := json.Unmarshal([]byte(llm.RepairJson(toolCall.Arguments)), &breakdown); err != nil {
+				result.IsError = true
+				result.Content = llm2.TextContentBlocks("Please record the task breakdown again: it failed to be parsed and was NOT recorded: " + err.Error())
+				return result, nil
+			}
+			if err := ValidateTaskBreakdown(breakdown); err != nil {
+				result.IsError = true
+				result.Content = llm2.TextContentBlocks("Please record the task breakdown again: it failed validation and was NOT recorded: " + err.Error())
+				return result, nil
+			}
+
+			userResponse, err := ApproveTaskBreakdown(dCtx, breakdown)
+			if err != nil {
+				return llm2.ToolResultBlock{}, fmt.Errorf("error getting task breakdown approval: %w", err)
+			}
+			iteration.AutoIterationCount = 0
+
+			if userResponse.Approved != nil && *userResponse.Approved {
+				approvedBreakdown = &breakdown
+				result.Content = llm2.TextContentBlocks("Task breakdown approved")
+			} else {
+				result.Content = llm2.TextContentBlocks(fmt.Sprintf("Task breakdown was not approved. Current breakdown:\n%s\n\nPlease record the task breakdown again, taking this feedback into account:\n\n%s", breakdown.String(), userResponse.Content))
+			}
+			return result, nil
+		},
+	}
+
+	toolCallResults, err := handleToolCalls(iteration.ExecCtx, chatResponse.GetMessage().GetToolCalls(), iteration.ChatHistory, customHandlers)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range toolCallResults {
+		if len(res.TextContent()) > 5000 {
+			state.contextSizeExtension += len(res.TextContent()) - 5000
+		}
+		if res.Name == getHelpOrInputTool.Name {
+			iteration.AutoIterationCount = 0
+		}
+	}
+
+	return approvedBreakdown, nil
+}
+
+func generateTaskBreakdown(dCtx DevContext, chatHistory *persisted_ai.ChatHistoryContainer) (common.MessageResponse, error) {
+	modelConfig := dCtx.GetModelConfig(common.PlanningKey, 0, "default")
+
+	tools := []*llm.Tool{
+		&recordTaskBreakdownTool,
+		currentGetSymbolDefinitionsTool(),
+		&bulkSearchRepositoryTool,
+		&bulkReadFileTool,
+	}
+	if supportsImageToolResults(modelConfig) {
+		tools = append(tools, &readImageTool)
+	}
+	if !dCtx.RepoConfig.DisableHumanInTheLoop {
+		tools = append(tools, &getHelpOrInputTool)
+	}
+	tools = append(tools, mcpTools(dCtx)...)
+
+	options := llm2.Options{
+		Tools: tools,
+		ToolChoice: llm.ToolChoice{
+			Type: llm.ToolChoiceTypeAuto,
+		},
+		ModelConfig: modelConfig,
+	}
+	return TrackedToolChat(dCtx, "task_breakdown", options, chatHistory)
+}
+
+// ValidateTaskBreakdown checks that a task breakdown has at least two child
+// tasks with unique keys, and that their dependencies refer to other child
+// tasks without forming a cycle.
+func ValidateTaskBreakdown(breakdown TaskBreakdown) error {
+	if len(breakdown.ChildTasks) < 2 {
+		return errors.New("the task must be split into at least two child tasks")
+	}
+
+	childTasksByKey := make(map[string]ChildTaskSpec, len(breakdown.ChildTasks))
+	for _, child := range breakdown.ChildTasks {
+		if child.Key == "" {
+			return errors.New("every child task must have a key")
+		}
+		if strings.TrimSpace(child.Requirements) == "" {
+			return fmt.Errorf("child task %s has no requirements", child.Key)
+		}
+		if _, ok := childTasksByKey[child.Key]; ok {
+			return fmt.Errorf("duplicate child task key %s", child.Key)
+		}
+		childTasksByKey[child.Key] = child
+	}
+
+	for _, child := range breakdown.ChildTasks {
+		for _, key := range child.DependsOn {
+			if _, ok := childTasksByKey[key]; !ok {
+				return fmt.Errorf("child task %s depends on unknown child task %s", child.Key, key)
+			}
+		}
+	}
+
+	// depth-first search for dependency cycles
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		path = append(path, key)
+		if visiting[key] {
+			return fmt.Errorf("child task dependencies form a cycle: %s", domain.FormatLinkCycle(path))
+		}
+		if visited[key] {
+			return nil
+		}
+		visiting[key] = true
+		for _, dependency := range childTasksByKey[key].DependsOn {
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+		visiting[key] = false
+		visited[key] = true
+		return nil
+	}
+	for _, child := range breakdown.ChildTasks {
+		if err := visit(child.Key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (breakdown TaskBreakdown) String() string {
+	writer := &strings.Builder{}
+	if breakdown.Analysis != "" {
+		writer.WriteString(breakdown.Analysis)
+		writer.WriteString("\n\n")
+	}
+	for i, child := range breakdown.ChildTasks {
+		if i > 0 {
+			writer.WriteString("\n\n")
+		}
+		writer.WriteString(fmt.Sprintf("#### Task %s: %s\n", child.Key, child.Title))
+		if len(child.DependsOn) > 0 {
+			writer.WriteString(fmt.Sprintf("_Depends on: %s_\n", strings.Join(child.DependsOn, ", ")))
+		}
+		writer.WriteString(strings.Trim(child.Requirements, "\n"))
+	}
+	return writer.String()
+}
+
+func ApproveTaskBreakdown(dCtx DevContext, breakdown TaskBreakdown) (*flow_action.UserResponse, error) {
+	req := flow_action.RequestForUser{
+		Content:       "Please approve or reject this breakdown into child tasks:\n\n" + breakdown.String() + "\n\nDo you approve these child tasks? If not, please provide feedback on what needs to be changed.",
+		RequestParams: map[string]interface{}{"approveTag": "approve_breakdown", "rejectTag": "reject_breakdown"},
+	}
+	return GetUserApproval(dCtx, "task_breakdown", req.Content, req.RequestParams)
+}
@@ -0,0 +1,137 @@
+package dev
+
+import (
+	"testing"
+
+	"sidekick/common"
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTaskBreakdown(t *testing.T) {
+	t.Parallel()
+
+	valid := TaskBreakdown{ChildTasks: []ChildTaskSpec{
+		{Key: "1", Title: "Add model", Requirements: "Add the model"},
+		{Key: "2", Title: "Add API", Requirements: "Add the API", DependsOn: []string{"1"}},
+		{Key: "3", Title: "Add UI", Requirements: "Add the UI", DependsOn: []string{"1", "2"}},
+	}}
+	assert.NoError(t, ValidateTaskBreakdown(valid))
+
+	testCases := []struct {
+		name          string
+		childTasks    []ChildTaskSpec
+		expectedError string
+	}{
+		{
+			name:          "single child task",
+			childTasks:    []ChildTaskSpec{{Key: "1", Requirements: "do it all"}},
+			expectedError: "at least two child tasks",
+		},
+		{
+			name:          "missing key",
+			childTasks:    []ChildTaskSpec{{Key: "1", Requirements: "a"}, {Requirements: "b"}},
+			expectedError: "must have a key",
+		},
+		{
+			name:          "missing requirements",
+			childTasks:    []ChildTaskSpec{{Key: "1", Requirements: "a"}, {Key: "2", Requirements: " "}},
+			expectedError: "child task 2 has no requirements",
+		},
+		{
+			name:          "duplicate key",
+			childTasks:    []ChildTaskSpec{{Key: "1", Requirements: "a"}, {Key: "1", Requirements: "b"}},
+			expectedError: "duplicate child task key 1",
+		},
+		{
+			name:          "unknown dependency",
+			childTasks:    []ChildTaskSpec{{Key: "1", Requirements: "a"}, {Key: "2", Requirements: "b", DependsOn: []string{"3"}}},
+			expectedError: "depends on unknown child task 3",
+		},
+		{
+			name: "cycle",
+			childTasks: []ChildTaskSpec{
+				{Key: "1", Requirements: "a", DependsOn: []string{"3"}},
+				{Key: "2", Requirements: "b", DependsOn: []string{"1"}},
+				{Key: "3", Requirements: "c", DependsOn: []string{"2"}},
+			},
+			expectedError: "cycle: 1 -> 3 -> 2 -> 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTaskBreakdown(TaskBreakdown{ChildTasks: tc.childTasks})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestTaskBreakdownString(t *testing.T) {
+	t.Parallel()
+
+	breakdown := TaskBreakdown{
+		Analysis: "The API needs the model first.",
+		ChildTasks: []ChildTaskSpec{
+			{Key: "1", Title: "Add model", Requirements: "Add the model\n"},
+			{Key: "2", Title: "Add API", Requirements: "Add the API", DependsOn: []string{"1"}},
+		},
+	}
+	expected := "The API needs the model first.\n\n" +
+		"#### Task 1: Add model\nAdd the model\n\n" +
+		"#### Task 2: Add API\n_Depends on: 1_\nAdd the API"
+	assert.Equal(t, expected, breakdown.String())
+}
+
+func TestChildFlowOptions(t *testing.T) {
+	t.Parallel()
+
+	startBranch := "main"
+	disableHumanInTheLoop := true
+	options := childFlowOptions(DecomposeTaskOptions{
+		EnvType:     "local_git_worktree",
+		StartBranch: &startBranch,
+	})
+	assert.Equal(t, map[string]interface{}{
+		"determineRequirements":          false,
+		"envType":                        "local_git_worktree",
+		"startBranch":                    "main",
+		FlowOptionStartFromBlockerBranch: true,
+	}, options)
+
+	options = childFlowOptions(DecomposeTaskOptions{EnvType: "local"})
+	assert.NotContains(t, options, FlowOptionStartFromBlockerBranch)
+	assert.NotContains(t, options, "configOverrides")
+
+	options = childFlowOptions(DecomposeTaskOptions{ConfigOverrides: common.ConfigOverrides{
+		DisableHumanInTheLoop: &disableHumanInTheLoop,
+	}})
+	assert.Equal(t, map[string]interface{}{"disableHumanInTheLoop": true}, options["configOverrides"])
+}
+
+func TestDecomposeFlowType_BuildInput(t *testing.T) {
+	t.Parallel()
+
+	def, ok := domain.GetFlowType(domain.FlowTypeDecompose)
+	require.True(t, ok)
+	input, err := def.BuildInput(domain.FlowLaunchParams{
+		WorkspaceId:  "ws_1",
+		RepoDir:      "/repo",
+		Requirements: "build the epic",
+		FlowOptions: map[string]interface{}{
+			"childFlowType": "basic_dev",
+			"envType":       "local_git_worktree",
+			"startBranch":   "main",
+		},
+	})
+	require.NoError(t, err)
+
+	decomposeInput, ok := input.(DecomposeTaskInput)
+	require.True(t, ok)
+	assert.Equal(t, "build the epic", decomposeInput.Requirements)
+	assert.Equal(t, domain.FlowTypeBasicDev, decomposeInput.ChildFlowType)
+	require.NotNil(t, decomposeInput.StartBranch)
+	assert.Equal(t, "main", *decomposeInput.StartBranch)
+}
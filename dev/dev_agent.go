@@ -28,13 +28,12 @@ func (ia *DevAgent) getRunID(ctx context.Context, workflowID string) string {
 	return handle.GetRunID()
 }
 
-func (ia *DevAgent) workRequest(ctx context.Context, parentId, request, flowType string, flowOptions map[string]interface{}) (domain.Flow, error) {
+func (ia *DevAgent) workRequest(ctx context.Context, workRequest WorkRequest) (WorkRequestResult, error) {
 	devManagerWorkflowId, err := ia.findOrStartDevAgentManagerWorkflow(ctx, ia.WorkspaceId)
 	if err != nil {
-		return domain.Flow{}, fmt.Errorf("error finding or starting dev manager workflow: %w", err)
+		return WorkRequestResult{}, fmt.Errorf("error finding or starting dev manager workflow: %w", err)
 	}
 
-	workRequest := WorkRequest{ParentId: parentId, Input: request, FlowType: flowType, FlowOptions: flowOptions}
 	//updateHandle, err := ia.TemporalClient.UpdateWorkflow(ctx, devManagerWorkflowId, "", UpdateNameWorkRequest, workRequest)
 	updateRequest := client.UpdateWorkflowOptions{
 		UpdateID:   uuid.New().String(),
@@ -48,15 +47,15 @@ func (ia *DevAgent) workRequest(ctx context.Context, parentId, request, flowType
 	}
 	updateHandle, err := ia.TemporalClient.UpdateWorkflow(ctx, updateRequest)
 	if err != nil {
-		return domain.Flow{}, fmt.Errorf("error issuing Update request: %w\n%v", err, updateRequest)
+		return WorkRequestResult{}, fmt.Errorf("error issuing Update request: %w\n%v", err, updateRequest)
 	}
 
-	var flow domain.Flow
-	err = updateHandle.Get(ctx, &flow)
+	var result WorkRequestResult
+	err = updateHandle.Get(ctx, &result)
 	if err != nil {
-		return domain.Flow{}, fmt.Errorf("update encountered an error: %w", err)
+		return WorkRequestResult{}, fmt.Errorf("update encountered an error: %w", err)
 	}
-	return flow, nil
+	return result, nil
 }
 
 func (ia *DevAgent) RelayResponse(ctx context.Context, userResponse flow_action.UserResponse) error {
@@ -70,8 +69,12 @@ func (ia *DevAgent) RelayResponse(ctx context.Context, userResponse flow_action.
 	return err
 }
 
+func devAgentManagerWorkflowId(workspaceId string) string {
+	return workspaceId + "_dev_manager"
+}
+
 func (ia DevAgent) findOrStartDevAgentManagerWorkflow(ctx context.Context, workspaceId string) (string, error) {
-	workflowId := workspaceId + "_dev_manager"
+	workflowId := devAgentManagerWorkflowId(workspaceId)
 	workflowRetryPolicy := &temporal.RetryPolicy{
 		InitialInterval:        time.Second,
 		BackoffCoefficient:     2.0,
@@ -100,7 +103,13 @@ func (ia DevAgent) findOrStartDevAgentManagerWorkflow(ctx context.Context, works
 
 func (ia DevAgent) HandleNewTask(ctx context.Context, task *domain.Task) error {
 	// perform a work request where the parentId is the taskId and the task description is the request
-	_, err := ia.workRequest(ctx, task.Id, task.Description, task.FlowType, task.FlowOptions)
+	_, err := ia.workRequest(ctx, WorkRequest{
+		ParentId:    task.Id,
+		Input:       task.Description,
+		FlowType:    task.FlowType,
+		FlowOptions: task.FlowOptions,
+		Priority:    task.Priority,
+	})
 	if err != nil {
 		return err
 	}
@@ -24,11 +24,14 @@ import (
 type DevAgentManagerActivities struct {
 	Storage        srv.Storage
 	TemporalClient client.Client
+	flowSlots      flowSlots
 }
 
 type TaskUpdate struct {
 	Status    domain.TaskStatus
 	AgentType domain.AgentType
+	// PullRequestURL is recorded on the task when set
+	PullRequestURL string
 }
 
 func (ima *DevAgentManagerActivities) UpdateTask(ctx context.Context, workspaceId, workflowId string, update TaskUpdate) error {
@@ -63,6 +66,9 @@ func (ima *DevAgentManagerActivities) UpdateTask(ctx context.Context, workspaceI
 
 	task.AgentType = update.AgentType
 	task.Status = update.Status
+	if update.PullRequestURL != "" {
+		task.PullRequestURL = update.PullRequestURL
+	}
 	task.Updated = time.Now()
 
 	return ima.Storage.PersistTask(ctx, task)
@@ -130,6 +136,27 @@ func (ima *DevAgentManagerActivities) CompleteFlowParentTask(ctx context.Context
 	}
 	task.Status = taskStatus
 	task.AgentType = domain.AgentTypeNone
+
+	// a task whose changes went up as a pull request stays in review until
+	// the pull request is dealt with
+	if taskStatus == domain.TaskStatusComplete && task.PullRequestURL != "" {
+		task.Status = domain.TaskStatusInReview
+		task.AgentType = domain.AgentTypeHuman
+	}
+
+	// a task split into child tasks is only finished once they all are
+	if taskStatus == domain.TaskStatusComplete && len(task.LinkedTaskIds(domain.LinkTypeParent)) > 0 {
+		children, err := getChildTasks(ctx, ima.Storage, task)
+		if err != nil {
+			return err
+		}
+		var finished bool
+		task.Status, finished = AggregateChildTaskStatus(children)
+		if !finished {
+			task.AgentType = domain.AgentTypeLLM
+		}
+	}
+
 	task.Updated = time.Now()
 	err = ima.Storage.PersistTask(ctx, task)
 	if err != nil {
@@ -139,6 +166,99 @@ func (ima *DevAgentManagerActivities) CompleteFlowParentTask(ctx context.Context
 	return nil
 }
 
+// UpdateTaskById updates a task's status and agent type, like UpdateTask but
+// for a task rather than the flow running it.
+func (ima *DevAgentManagerActivities) UpdateTaskById(ctx context.Context, workspaceId, taskId string, update TaskUpdate) error {
+	task, err := ima.Storage.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve task record for taskId %s: %v", taskId, err)
+	}
+
+	task.AgentType = update.AgentType
+	task.Status = update.Status
+	task.Updated = time.Now()
+
+	return ima.Storage.PersistTask(ctx, task)
+}
+
+// ResolveDependentTasks updates the tasks waiting on the given finished task,
+// returning the ones that are now ready to start. See ResolveDependentTasks.
+func (ima *DevAgentManagerActivities) ResolveDependentTasks(ctx context.Context, workspaceId, taskId string) ([]domain.Task, error) {
+	return ResolveDependentTasks(ctx, ima.Storage, workspaceId, taskId)
+}
+
+// CreateChildTasks persists the child tasks a task was split into. See
+// CreateChildTasks.
+func (ima *DevAgentManagerActivities) CreateChildTasks(ctx context.Context, input CreateChildTasksInput) ([]domain.Task, error) {
+	return CreateChildTasks(ctx, ima.Storage, input)
+}
+
+// GetChildTasksToStart returns the child tasks of a task that are ready to be
+// started. See GetChildTasksToStart.
+func (ima *DevAgentManagerActivities) GetChildTasksToStart(ctx context.Context, workspaceId, taskId string) ([]domain.Task, error) {
+	return GetChildTasksToStart(ctx, ima.Storage, workspaceId, taskId)
+}
+
+// UpdateParentTaskStatuses finishes the parents of a task once all their
+// child tasks are finished. See UpdateParentTaskStatuses.
+func (ima *DevAgentManagerActivities) UpdateParentTaskStatuses(ctx context.Context, workspaceId, taskId string) ([]string, error) {
+	return UpdateParentTaskStatuses(ctx, ima.Storage, workspaceId, taskId)
+}
+
+func loadConcurrencyLimits() (common.ConcurrencyConfig, error) {
+	config, err := common.LoadSidekickConfig(common.GetSidekickConfigPath())
+	if err != nil {
+		return common.ConcurrencyConfig{}, fmt.Errorf("failed to load concurrency limits: %w", err)
+	}
+	return config.Concurrency, nil
+}
+
+// QueueTask queues a task instead of starting it when the configured
+// concurrency limits are reached. See flowSlots.QueueTask.
+func (ima *DevAgentManagerActivities) QueueTask(ctx context.Context, input QueueTaskInput) (bool, error) {
+	limits, err := loadConcurrencyLimits()
+	if err != nil {
+		return false, err
+	}
+	return ima.flowSlots.QueueTask(ctx, ima.Storage, limits, input)
+}
+
+// DequeueTasks returns the queued tasks that may start now given the
+// configured concurrency limits. See flowSlots.DequeueTasks.
+func (ima *DevAgentManagerActivities) DequeueTasks(ctx context.Context, workspaceId string) ([]domain.Task, error) {
+	limits, err := loadConcurrencyLimits()
+	if err != nil {
+		return nil, err
+	}
+	return ima.flowSlots.DequeueTasks(ctx, ima.Storage, limits, workspaceId)
+}
+
+// NotifyQueuedWorkspaces signals the dev manager workflows of other workspaces
+// with queued tasks to start them, as a slot under the global concurrency
+// limit may have been freed up.
+func (ima *DevAgentManagerActivities) NotifyQueuedWorkspaces(ctx context.Context, workspaceId string) error {
+	limits, err := loadConcurrencyLimits()
+	if err != nil {
+		return err
+	}
+	if limits.MaxRunningFlows == 0 {
+		return nil
+	}
+
+	workspaceIds, err := GetWorkspacesWithQueuedTasks(ctx, ima.Storage, workspaceId)
+	if err != nil {
+		return err
+	}
+	for _, otherWorkspaceId := range workspaceIds {
+		err := ima.TemporalClient.SignalWorkflow(ctx, devAgentManagerWorkflowId(otherWorkspaceId), "", SignalNameStartQueuedTasks, nil)
+		if err != nil {
+			// the workspace's queue is drained once its manager runs again
+			log.Warn().Err(err).Str("workspaceId", otherWorkspaceId).Msg("Failed to notify workspace with queued tasks")
+		}
+	}
+	return nil
+}
+
 func (ima *DevAgentManagerActivities) PassOnUserResponse(userResponse flow_action.UserResponse) (err error) {
 	err = ima.TemporalClient.SignalWorkflow(context.Background(), userResponse.TargetWorkflowId, "", SignalNameUserResponse, userResponse)
 	if err != nil && err.Error() == "workflow execution already completed" {
@@ -202,6 +322,46 @@ func (ima *DevAgentManagerActivities) FindWorkspaceById(ctx context.Context, wor
 	return workspace, nil
 }
 
+// PullRequestTask is the task that opened a pull request, along with the
+// worktree its branch is checked out in
+type PullRequestTask struct {
+	Task     domain.Task
+	Worktree domain.Worktree
+}
+
+// FindPullRequestTask finds the task that opened the pull request with the
+// given URL and the worktree it was opened from, which is kept for follow-up
+// changes until the task is finished
+func (ima *DevAgentManagerActivities) FindPullRequestTask(ctx context.Context, workspaceId, pullRequestURL string) (PullRequestTask, error) {
+	tasks, err := ima.Storage.GetTasks(ctx, workspaceId, domain.AllTaskStatuses)
+	if err != nil {
+		return PullRequestTask{}, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	pullRequestURL = strings.TrimSuffix(strings.TrimSpace(pullRequestURL), "/")
+	for _, task := range tasks {
+		if task.PullRequestURL == "" || strings.TrimSuffix(task.PullRequestURL, "/") != pullRequestURL {
+			continue
+		}
+		flows, err := ima.Storage.GetFlowsForTask(ctx, workspaceId, task.Id)
+		if err != nil {
+			return PullRequestTask{}, fmt.Errorf("failed to get flows for task %s: %w", task.Id, err)
+		}
+		for _, flow := range flows {
+			worktrees, err := ima.Storage.GetWorktreesForFlow(ctx, workspaceId, flow.Id)
+			if err != nil {
+				return PullRequestTask{}, fmt.Errorf("failed to get worktrees for flow %s: %w", flow.Id, err)
+			}
+			for _, worktree := range worktrees {
+				if _, err := os.Stat(worktree.WorkingDirectory); err == nil {
+					return PullRequestTask{Task: task, Worktree: worktree}, nil
+				}
+			}
+		}
+		return PullRequestTask{}, fmt.Errorf("the worktree of task %s, which opened pull request %s, no longer exists", task.Id, pullRequestURL)
+	}
+	return PullRequestTask{}, fmt.Errorf("no task opened pull request %s", pullRequestURL)
+}
+
 type StaleWorktreeCandidate struct {
 	Path    string `json:"path"`
 	Reason  string `json:"reason"`
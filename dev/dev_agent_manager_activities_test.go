@@ -97,6 +97,86 @@ func TestUpdateTask(t *testing.T) {
 	assert.False(t, updatedTask.Updated.IsZero(), "Updated time should be set")
 }
 
+func TestUpdateTask_PullRequestKeepsTaskInReview(t *testing.T) {
+	ima := newDevAgentManagerActivities(t)
+	storage := ima.Storage
+	ctx := context.Background()
+
+	workspaceId := "testWorkspace"
+	task := domain.Task{
+		WorkspaceId: workspaceId,
+		Id:          "task_pullRequestTest",
+		Status:      domain.TaskStatusInProgress,
+		AgentType:   domain.AgentTypeLLM,
+	}
+	flow := domain.Flow{
+		WorkspaceId: workspaceId,
+		Id:          "workflow_pullRequestTest",
+		ParentId:    task.Id,
+	}
+	require.NoError(t, storage.PersistTask(ctx, task))
+	require.NoError(t, storage.PersistFlow(ctx, flow))
+
+	err := ima.UpdateTask(ctx, workspaceId, flow.Id, TaskUpdate{
+		Status:         domain.TaskStatusInReview,
+		AgentType:      domain.AgentTypeHuman,
+		PullRequestURL: "https://github.com/team/side/pull/8",
+	})
+	require.NoError(t, err)
+
+	// the flow finishing doesn't complete the task while its pull request
+	// is open
+	require.NoError(t, ima.CompleteFlowParentTask(ctx, workspaceId, task.Id, "completed"))
+	updatedTask, err := storage.GetTask(ctx, workspaceId, task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatusInReview, updatedTask.Status)
+	assert.Equal(t, domain.AgentTypeHuman, updatedTask.AgentType)
+	assert.Equal(t, "https://github.com/team/side/pull/8", updatedTask.PullRequestURL)
+}
+
+func TestFindPullRequestTask(t *testing.T) {
+	ima := newDevAgentManagerActivities(t)
+	storage := ima.Storage
+	ctx := context.Background()
+
+	workspaceId := "testWorkspace"
+	pullRequestURL := "https://github.com/team/side/pull/8"
+	task := domain.Task{
+		WorkspaceId:    workspaceId,
+		Id:             "task_findPullRequest",
+		Status:         domain.TaskStatusInReview,
+		PullRequestURL: pullRequestURL,
+	}
+	flow := domain.Flow{
+		WorkspaceId: workspaceId,
+		Id:          "workflow_findPullRequest",
+		ParentId:    task.Id,
+	}
+	worktree := domain.Worktree{
+		Id:               "wt_findPullRequest",
+		FlowId:           flow.Id,
+		Name:             "side/add-login",
+		WorkspaceId:      workspaceId,
+		WorkingDirectory: t.TempDir(),
+	}
+	require.NoError(t, storage.PersistTask(ctx, task))
+	require.NoError(t, storage.PersistFlow(ctx, flow))
+	require.NoError(t, storage.PersistWorktree(ctx, worktree))
+
+	found, err := ima.FindPullRequestTask(ctx, workspaceId, pullRequestURL+"/")
+	require.NoError(t, err)
+	assert.Equal(t, task.Id, found.Task.Id)
+	assert.Equal(t, worktree.Name, found.Worktree.Name)
+	assert.Equal(t, worktree.WorkingDirectory, found.Worktree.WorkingDirectory)
+
+	_, err = ima.FindPullRequestTask(ctx, workspaceId, "https://github.com/team/side/pull/9")
+	assert.ErrorContains(t, err, "no task opened pull request")
+
+	require.NoError(t, os.RemoveAll(worktree.WorkingDirectory))
+	_, err = ima.FindPullRequestTask(ctx, workspaceId, pullRequestURL)
+	assert.ErrorContains(t, err, "no longer exists")
+}
+
 func TestCreatePendingUserRequest(t *testing.T) {
 	ima := newDevAgentManagerActivities(t)
 	storage := ima.Storage
@@ -41,12 +41,21 @@ func DevAgentManagerWorkflow(ctx workflow.Context, input DevAgentManagerWorkflow
 	ctx = setActivityOptions(ctx)
 	var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
 	future, settable := workflow.NewFuture(ctx)
+	queue := &flowQueue{}
 
 	workflow.Go(ctx, func(ctx workflow.Context) {
-		err := handleSignals(ctx, input, ima, &count)
+		err := handleSignals(ctx, input, ima, &count, queue)
 		settable.Set(nil, err)
 	})
 
+	if v := workflow.GetVersion(ctx, flowQueueChangeId, workflow.DefaultVersion, 1); v == 1 {
+		// slots may have been freed while this workflow wasn't running, eg
+		// before continuing as new
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			queue.startQueuedTasks(ctx, input.WorkspaceId, ima)
+		})
+	}
+
 	if v := workflow.GetVersion(ctx, "stale-worktree-cleaner", workflow.DefaultVersion, 1); v == 1 {
 		workflow.Go(ctx, func(ctx workflow.Context) {
 			ao := workflow.ActivityOptions{
@@ -80,7 +89,7 @@ func DevAgentManagerWorkflow(ctx workflow.Context, input DevAgentManagerWorkflow
 		})
 	}
 
-	err := handleWorkRequests(ctx, input.WorkspaceId, ima, &count)
+	err := handleWorkRequests(ctx, input.WorkspaceId, ima, &count, queue)
 	if err != nil {
 		return err
 	}
@@ -98,14 +107,25 @@ type WorkRequest struct {
 	Input       string
 	FlowType    string
 	FlowOptions map[string]interface{}
+	// Priority of the task the work is for, used to order the queue when
+	// concurrency limits are reached
+	Priority int
 }
 
 type WorkRequestResult struct {
-	WorkflowId string
+	// Flow started for the work request, unset when Queued
+	Flow domain.Flow
+	// Queued is set when the work request's task was queued until running
+	// flows finish, per the concurrency limits
+	Queued bool
 }
 
 const SignalNameCancel = "cancel"
 
+// SignalNameStartQueuedTasks is sent to the dev manager workflow of a
+// workspace with queued tasks when running flows elsewhere finish.
+const SignalNameStartQueuedTasks = "startQueuedTasks"
+
 func handleCancel(ctx workflow.Context, c workflow.ReceiveChannel, ima *DevAgentManagerActivities) {
 	// FIXME remove the argument and use the below commented out code instead
 	// var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
@@ -141,7 +161,7 @@ func handleCancel(ctx workflow.Context, c workflow.ReceiveChannel, ima *DevAgent
 	// }
 }
 
-func handleRequestForUser(ctx workflow.Context, c workflow.ReceiveChannel, input DevAgentManagerWorkflowInput, version workflow.Version) {
+func handleRequestForUser(ctx workflow.Context, c workflow.ReceiveChannel, input DevAgentManagerWorkflowInput, version workflow.Version, queue *flowQueue) {
 	var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
 
 	workspaceId := input.WorkspaceId
@@ -179,6 +199,12 @@ func handleRequestForUser(ctx workflow.Context, c workflow.ReceiveChannel, input
 				log.Error("Failed to execute UpdateTask activity", "Error", err)
 				return
 			}
+
+			// flows waiting on a human don't count towards the concurrency
+			// limits, so queued tasks may start meanwhile
+			if v := workflow.GetVersion(ctx, flowQueueChangeId, workflow.DefaultVersion, 1); v >= 1 {
+				queue.startQueuedTasks(ctx, workspaceId, ima)
+			}
 		} else {
 			err = workflow.ExecuteActivity(ctx, ima.UpdateTaskForUserRequest, workspaceId, req.OriginWorkflowId).Get(ctx, nil)
 			if err != nil {
@@ -206,7 +232,7 @@ func handleUserResponse(ctx workflow.Context, c workflow.ReceiveChannel, ima *De
 	}
 }
 
-func handleWorkflowClosure(ctx workflow.Context, c workflow.ReceiveChannel, input DevAgentManagerWorkflowInput, ima *DevAgentManagerActivities) {
+func handleWorkflowClosure(ctx workflow.Context, c workflow.ReceiveChannel, input DevAgentManagerWorkflowInput, ima *DevAgentManagerActivities, queue *flowQueue) {
 	var closure WorkflowClosure
 	c.Receive(ctx, &closure)
 	log := workflow.GetLogger(ctx)
@@ -233,14 +259,204 @@ func handleWorkflowClosure(ctx workflow.Context, c workflow.ReceiveChannel, inpu
 			log.Error("Failed to complete parent task", "Error", err)
 			return
 		}
+
+		// queued tasks were waiting longer than the tasks that were waiting
+		// on this one, so they get the freed slot first
+		if v := workflow.GetVersion(ctx, flowQueueChangeId, workflow.DefaultVersion, 1); v >= 1 {
+			queue.startQueuedTasks(ctx, input.WorkspaceId, ima)
+			err = workflow.ExecuteActivity(ctx, ima.NotifyQueuedWorkspaces, input.WorkspaceId).Get(ctx, nil)
+			if err != nil {
+				log.Error("Failed to notify workspaces with queued tasks", "Error", err)
+			}
+		}
+
+		if v := workflow.GetVersion(ctx, "task-dependencies", workflow.DefaultVersion, 1); v >= 1 {
+			startUnblockedTasks(ctx, input.WorkspaceId, flow.ParentId, ima, queue)
+		}
+
+		if v := workflow.GetVersion(ctx, "task-decomposition", workflow.DefaultVersion, 1); v >= 1 {
+			startChildTasks(ctx, input.WorkspaceId, flow.ParentId, ima, queue)
+			finishParentTasks(ctx, input.WorkspaceId, flow.ParentId, ima, queue)
+		}
+	}
+}
+
+// startChildTasks starts the child tasks of the given task that are ready to
+// start, i.e. those that don't depend on other child tasks.
+func startChildTasks(ctx workflow.Context, workspaceId, taskId string, ima *DevAgentManagerActivities, queue *flowQueue) {
+	log := workflow.GetLogger(ctx)
+
+	var childTasks []domain.Task
+	err := workflow.ExecuteActivity(ctx, ima.GetChildTasksToStart, workspaceId, taskId).Get(ctx, &childTasks)
+	if err != nil {
+		log.Error("Failed to get child tasks to start", "Error", err, "TaskId", taskId)
+		return
+	}
+	startTasks(ctx, workspaceId, childTasks, ima, queue)
+}
+
+// finishParentTasks finishes the parents of the given task once all of their
+// child tasks are finished, then resolves the tasks waiting on those parents.
+func finishParentTasks(ctx workflow.Context, workspaceId, taskId string, ima *DevAgentManagerActivities, queue *flowQueue) {
+	log := workflow.GetLogger(ctx)
+
+	var finishedParentIds []string
+	err := workflow.ExecuteActivity(ctx, ima.UpdateParentTaskStatuses, workspaceId, taskId).Get(ctx, &finishedParentIds)
+	if err != nil {
+		log.Error("Failed to update parent task statuses", "Error", err, "TaskId", taskId)
+		return
+	}
+	for _, parentId := range finishedParentIds {
+		startUnblockedTasks(ctx, workspaceId, parentId, ima, queue)
+	}
+}
+
+// startUnblockedTasks starts the tasks that were waiting on the given task to
+// finish and are no longer blocked. Waiting tasks are failed or canceled along
+// with the task instead, if it didn't complete.
+func startUnblockedTasks(ctx workflow.Context, workspaceId, taskId string, ima *DevAgentManagerActivities, queue *flowQueue) {
+	log := workflow.GetLogger(ctx)
+
+	var readyTasks []domain.Task
+	err := workflow.ExecuteActivity(ctx, ima.ResolveDependentTasks, workspaceId, taskId).Get(ctx, &readyTasks)
+	if err != nil {
+		log.Error("Failed to resolve dependent tasks", "Error", err, "TaskId", taskId)
+		return
+	}
+	startTasks(ctx, workspaceId, readyTasks, ima, queue)
+}
+
+// startTasks starts flows for tasks that haven't been started yet, marking
+// them as in progress, or as drafting if starting fails. Tasks are queued
+// instead when the concurrency limits are reached.
+func startTasks(ctx workflow.Context, workspaceId string, tasks []domain.Task, ima *DevAgentManagerActivities, queue *flowQueue) {
+	v := workflow.GetVersion(ctx, flowQueueChangeId, workflow.DefaultVersion, 1)
+	for _, task := range tasks {
+		if v >= 1 {
+			queue.requestTask(ctx, workspaceId, task, ima)
+		} else {
+			startTask(ctx, workspaceId, task, ima)
+		}
+	}
+}
+
+// startTask starts a flow for the task, marking it as in progress, or as
+// drafting if starting fails.
+func startTask(ctx workflow.Context, workspaceId string, task domain.Task, ima *DevAgentManagerActivities) {
+	log := workflow.GetLogger(ctx)
+
+	update := TaskUpdate{Status: domain.TaskStatusInProgress, AgentType: domain.AgentTypeLLM}
+	_, err := executeWorkRequest(ctx, workspaceId, taskWorkRequest(task), ima)
+	if err != nil {
+		// revert to drafting so the user can retry, like when starting a
+		// task via the API fails
+		log.Error("Failed to start task", "Error", err, "TaskId", task.Id)
+		update = TaskUpdate{Status: domain.TaskStatusDrafting, AgentType: domain.AgentTypeHuman}
+	}
+	err = workflow.ExecuteActivity(ctx, ima.UpdateTaskById, workspaceId, task.Id, update).Get(ctx, nil)
+	if err != nil {
+		log.Error("Failed to update started task", "Error", err, "TaskId", task.Id)
+	}
+}
+
+func taskWorkRequest(task domain.Task) WorkRequest {
+	return WorkRequest{
+		ParentId:    task.Id,
+		Input:       task.Description,
+		FlowType:    task.FlowType,
+		FlowOptions: task.FlowOptions,
+		Priority:    task.Priority,
+	}
+}
+
+const flowQueueChangeId = "flow-queue"
+
+// flowQueue serializes starting flows within the dev manager workflow, so that
+// concurrent work requests and workflow closures don't race when checking the
+// concurrency limits. The queue itself is persisted via the tasks' queue
+// positions, so it survives continuing as new.
+type flowQueue struct {
+	busy bool
+}
+
+func (q *flowQueue) lock(ctx workflow.Context) {
+	_ = workflow.Await(ctx, func() bool { return !q.busy })
+	q.busy = true
+}
+
+func (q *flowQueue) unlock() {
+	q.busy = false
+}
+
+// requestWork starts a flow for the work request, unless it's for a task that
+// must wait for the concurrency limits, in which case the task is queued.
+func (q *flowQueue) requestWork(ctx workflow.Context, workspaceId string, workRequest WorkRequest, ima *DevAgentManagerActivities) (WorkRequestResult, error) {
+	if strings.HasPrefix(workRequest.ParentId, "task_") {
+		q.lock(ctx)
+		defer q.unlock()
+
+		queued, err := queueTask(ctx, workspaceId, workRequest, ima)
+		if err != nil || queued {
+			return WorkRequestResult{Queued: queued}, err
+		}
+	}
+
+	flow, err := executeWorkRequest(ctx, workspaceId, workRequest, ima)
+	return WorkRequestResult{Flow: flow}, err
+}
+
+// requestTask starts a flow for the task like startTask, unless the task must
+// wait for the concurrency limits, in which case it is queued.
+func (q *flowQueue) requestTask(ctx workflow.Context, workspaceId string, task domain.Task, ima *DevAgentManagerActivities) {
+	q.lock(ctx)
+	defer q.unlock()
+
+	queued, err := queueTask(ctx, workspaceId, taskWorkRequest(task), ima)
+	if err != nil {
+		// the limits are best-effort, so we don't hold up the task
+		workflow.GetLogger(ctx).Error("Failed to check concurrency limits", "Error", err, "TaskId", task.Id)
+	}
+	if !queued {
+		startTask(ctx, workspaceId, task, ima)
+	}
+}
+
+// startQueuedTasks starts as many queued tasks as the concurrency limits
+// allow, in queue order.
+func (q *flowQueue) startQueuedTasks(ctx workflow.Context, workspaceId string, ima *DevAgentManagerActivities) {
+	q.lock(ctx)
+	defer q.unlock()
+
+	var tasks []domain.Task
+	err := workflow.ExecuteActivity(ctx, ima.DequeueTasks, workspaceId).Get(ctx, &tasks)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to dequeue tasks", "Error", err)
+		return
+	}
+	for _, task := range tasks {
+		startTask(ctx, workspaceId, task, ima)
 	}
 }
 
-func handleSignals(ctx workflow.Context, input DevAgentManagerWorkflowInput, ima *DevAgentManagerActivities, count *int) error {
+func queueTask(ctx workflow.Context, workspaceId string, workRequest WorkRequest, ima *DevAgentManagerActivities) (bool, error) {
+	var queued bool
+	err := workflow.ExecuteActivity(ctx, ima.QueueTask, QueueTaskInput{
+		WorkspaceId: workspaceId,
+		TaskId:      workRequest.ParentId,
+		Priority:    workRequest.Priority,
+	}).Get(ctx, &queued)
+	if queued {
+		workflow.GetLogger(ctx).Info("Queued task until running flows finish", "TaskId", workRequest.ParentId)
+	}
+	return queued, err
+}
+
+func handleSignals(ctx workflow.Context, input DevAgentManagerWorkflowInput, ima *DevAgentManagerActivities, count *int, queue *flowQueue) error {
 	cancelSigChan := workflow.GetSignalChannel(ctx, SignalNameCancel)
 	requestForUserSigChan := workflow.GetSignalChannel(ctx, SignalNameRequestForUser)
 	userResponseSigChan := workflow.GetSignalChannel(ctx, SignalNameUserResponse)
 	workflowClosedSignalChan := workflow.GetSignalChannel(ctx, SignalNameWorkflowClosed)
+	startQueuedTasksSignalChan := workflow.GetSignalChannel(ctx, SignalNameStartQueuedTasks)
 
 	// overallVersion is used to avoid redundant calls to get the latest version in the loop.
 	// If the workflow was started with a version that supports review status, use it.
@@ -263,12 +479,16 @@ func handleSignals(ctx workflow.Context, input DevAgentManagerWorkflowInput, ima
 		selector := workflow.NewNamedSelector(ctx, "signalSelector")
 		selector.AddReceive(cancelSigChan, func(c workflow.ReceiveChannel, _ bool) { handleCancel(ctx, c, ima) })
 		selector.AddReceive(requestForUserSigChan, func(c workflow.ReceiveChannel, _ bool) {
-			handleRequestForUser(ctx, c, input, effectiveVersion)
+			handleRequestForUser(ctx, c, input, effectiveVersion, queue)
 		})
 		selector.AddReceive(userResponseSigChan, func(c workflow.ReceiveChannel, _ bool) {
 			handleUserResponse(ctx, c, ima)
 		})
-		selector.AddReceive(workflowClosedSignalChan, func(c workflow.ReceiveChannel, _ bool) { handleWorkflowClosure(ctx, c, input, ima) })
+		selector.AddReceive(workflowClosedSignalChan, func(c workflow.ReceiveChannel, _ bool) { handleWorkflowClosure(ctx, c, input, ima, queue) })
+		selector.AddReceive(startQueuedTasksSignalChan, func(c workflow.ReceiveChannel, _ bool) {
+			c.Receive(ctx, nil)
+			queue.startQueuedTasks(ctx, input.WorkspaceId, ima)
+		})
 		selector.Select(ctx)
 
 		*count++
@@ -315,30 +535,22 @@ func executeWorkRequest(ctx workflow.Context, workspaceId string, workRequest Wo
 	// it an unrelated workflow rather than a child workflow: we aren't using
 	// the fact that it is a child workflow really, unless we're using the
 	// parent workflow id somewhere
-	var childWorkflowFuture workflow.ChildWorkflowFuture
-	untypedOptions := workRequest.FlowOptions
-	if workRequest.FlowType == "basic_dev" {
-		var options BasicDevOptions
-		utils.Transcode(untypedOptions, &options)
-		childWorkflowFuture = workflow.ExecuteChildWorkflow(childCtx, BasicDevWorkflow, BasicDevWorkflowInput{
-			WorkspaceId:     workspaceId,
-			Requirements:    workRequest.Input,
-			RepoDir:         repoDir,
-			BasicDevOptions: options,
-		})
-	} else if workRequest.FlowType == "planned_dev" {
-		var options PlannedDevOptions
-		utils.Transcode(untypedOptions, &options)
-		childWorkflowFuture = workflow.ExecuteChildWorkflow(childCtx, PlannedDevWorkflow, PlannedDevInput{
-			WorkspaceId:       workspaceId,
-			Requirements:      workRequest.Input,
-			RepoDir:           repoDir,
-			PlannedDevOptions: options,
-		})
-	} else {
+	flowType, ok := domain.GetFlowType(workRequest.FlowType)
+	if !ok {
 		log.Error("Invalid flow type", "FlowType", workRequest.FlowType)
-		return domain.Flow{}, fmt.Errorf("Invalid flow type '%s'. Valid values are 'basic_dev' and 'planned_dev'", workRequest.FlowType)
+		return domain.Flow{}, fmt.Errorf("Invalid flow type '%s'", workRequest.FlowType)
 	}
+	input, err := flowType.BuildInput(domain.FlowLaunchParams{
+		WorkspaceId:  workspaceId,
+		RepoDir:      repoDir,
+		Requirements: workRequest.Input,
+		FlowOptions:  workRequest.FlowOptions,
+	})
+	if err != nil {
+		log.Error("Failed to build flow input", "Error", err, "FlowType", workRequest.FlowType)
+		return domain.Flow{}, err
+	}
+	childWorkflowFuture := workflow.ExecuteChildWorkflow(childCtx, flowType.Workflow, input)
 
 	var we workflow.Execution
 	err = childWorkflowFuture.GetChildWorkflowExecution().Get(childCtx, &we)
@@ -364,15 +576,19 @@ func executeWorkRequest(ctx workflow.Context, workspaceId string, workRequest Wo
 
 const UpdateNameWorkRequest = "workRequest"
 
-func handleWorkRequests(ctx workflow.Context, workspaceId string, ima *DevAgentManagerActivities, count *int) error {
+func handleWorkRequests(ctx workflow.Context, workspaceId string, ima *DevAgentManagerActivities, count *int, queue *flowQueue) error {
 	// FIXME remove the argument and use the below commented out code instead
 	// var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
 	err := workflow.SetUpdateHandlerWithOptions(
 		ctx, UpdateNameWorkRequest,
-		func(ctx workflow.Context, workRequest WorkRequest) (domain.Flow, error) {
+		func(ctx workflow.Context, workRequest WorkRequest) (WorkRequestResult, error) {
 			*count++
 			ctx = setActivityOptions(ctx)
-			return executeWorkRequest(ctx, workspaceId, workRequest, ima)
+			if v := workflow.GetVersion(ctx, flowQueueChangeId, workflow.DefaultVersion, 1); v >= 1 {
+				return queue.requestWork(ctx, workspaceId, workRequest, ima)
+			}
+			flow, err := executeWorkRequest(ctx, workspaceId, workRequest, ima)
+			return WorkRequestResult{Flow: flow}, err
 		},
 		workflow.UpdateHandlerOptions{},
 	)
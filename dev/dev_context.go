@@ -9,6 +9,7 @@ import (
 	"sidekick/domain"
 	"sidekick/env"
 	"sidekick/flow_action"
+	"sidekick/mcp"
 	"sidekick/secret_manager"
 	"sidekick/srv"
 	"sidekick/utils"
@@ -23,6 +24,8 @@ type DevContext struct {
 	flow_action.ExecContext
 	Worktree   *domain.Worktree
 	RepoConfig common.RepoConfig
+	// McpTools are the tools of the MCP servers configured for the repo
+	McpTools []mcp.ServerTool
 }
 
 // WithContext returns a new DevContext with the workflow.Context updated.
@@ -123,7 +126,7 @@ func setupDevContextAction(ctx workflow.Context, workspaceId string, repoDir str
 			return DevContext{}, fmt.Errorf("failed to create environment: %v", err)
 		}
 		envContainer = env.EnvContainer{Env: devEnv}
-	case string(env.EnvTypeLocalGitWorktree):
+	case string(env.EnvTypeLocalGitWorktree), string(env.EnvTypeContainer), string(env.EnvTypeDevPod):
 		flowId := workflow.GetInfo(ctx).WorkflowExecution.ID
 
 		// Generate branch name based on workflow version
@@ -196,6 +199,13 @@ func setupDevContextAction(ctx workflow.Context, workspaceId string, repoDir str
 		if err != nil {
 			return DevContext{}, fmt.Errorf("failed to persist worktree: %v", err)
 		}
+
+		if envType == string(env.EnvTypeContainer) || envType == string(env.EnvTypeDevPod) {
+			envContainer, err = provisionWorktreeEnv(tempLocalExecContext, envContainer, env.EnvType(envType), flowId, configOverrides)
+			if err != nil {
+				return DevContext{}, fmt.Errorf("failed to create environment: %v", err)
+			}
+		}
 	default:
 		return DevContext{}, fmt.Errorf("unsupported environment type: %s", envType)
 	}
@@ -271,9 +281,10 @@ func setupDevContextAction(ctx workflow.Context, workspaceId string, repoDir str
 		repoConfig.CommandPermissions,
 		workspaceConfig.CommandPermissions,
 	)
+	repoConfig.McpServers = common.MergeMcpServers(localConfig.McpServers, repoConfig.McpServers)
 
 	// Execute worktree setup script if configured and using git worktree environment
-	if envType == string(env.EnvTypeLocalGitWorktree) && repoConfig.WorktreeSetup != "" {
+	if env.EnvType(envType).UsesGitWorktree() && repoConfig.WorktreeSetup != "" {
 		var output env.EnvRunCommandActivityOutput
 		err = workflow.ExecuteActivity(ctx, env.EnvRunCommandActivity, env.EnvRunCommandActivityInput{
 			EnvContainer: envContainer,
@@ -298,6 +309,15 @@ func setupDevContextAction(ctx workflow.Context, workspaceId string, repoDir str
 		RepoConfig:  repoConfig,
 	}
 
+	if len(repoConfig.McpServers) > 0 {
+		if v := workflow.GetVersion(ctx, "mcp-tools", workflow.DefaultVersion, 1); v >= 1 {
+			devCtx.McpTools, err = listMcpTools(devCtx)
+			if err != nil {
+				return DevContext{}, err
+			}
+		}
+	}
+
 	// Fetch and store git user config for commit authorship
 	if v := workflow.GetVersion(ctx, "git-user-config-in-global-state", workflow.DefaultVersion, 1); v >= 1 {
 		var gitUserConfig git.GitUserConfig
@@ -348,6 +368,7 @@ func stopActiveDevRun(dCtx DevContext) {
 			WorkspaceId:  dCtx.WorkspaceId,
 			FlowId:       flowInfo.WorkflowExecution.ID,
 			WorktreeDir:  dCtx.EnvContainer.Env.GetWorkingDirectory(),
+			EnvContainer: dCtx.EnvContainer,
 			SourceBranch: dCtx.Worktree.Name,
 		}
 		var dra *DevRunActivities
@@ -367,6 +388,61 @@ func stopActiveDevRun(dCtx DevContext) {
 	ClearDevRunEntry(dCtx.ExecContext.GlobalState)
 }
 
+// provisionWorktreeEnv provisions a container or DevPod workspace from the
+// given worktree environment's working directory, using the corresponding
+// config from the repo config found in the worktree.
+func provisionWorktreeEnv(eCtx flow_action.ExecContext, worktreeEnvContainer env.EnvContainer, envType env.EnvType, flowId string, configOverrides common.ConfigOverrides) (env.EnvContainer, error) {
+	eCtx.EnvContainer = &worktreeEnvContainer
+	repoConfig, err := GetRepoConfig(eCtx)
+	if err != nil {
+		return env.EnvContainer{}, fmt.Errorf("failed to get repo config: %v", err)
+	}
+	configOverrides.ApplyToRepoConfig(&repoConfig)
+
+	var envContainer env.EnvContainer
+	switch envType {
+	case env.EnvTypeContainer:
+		err = workflow.ExecuteActivity(eCtx, env.NewContainerEnvActivity, env.ContainerEnvParams{
+			WorkingDirectory: worktreeEnvContainer.Env.GetWorkingDirectory(),
+			ContainerName:    "sidekick-" + flowId,
+			Config:           repoConfig.Container,
+		}).Get(eCtx, &envContainer)
+	case env.EnvTypeDevPod:
+		err = workflow.ExecuteActivity(eCtx, env.NewDevPodEnvActivity, env.DevPodEnvParams{
+			WorkingDirectory: worktreeEnvContainer.Env.GetWorkingDirectory(),
+			WorkspaceId:      "sidekick-" + flowId,
+			Config:           repoConfig.DevPod,
+		}).Get(eCtx, &envContainer)
+	default:
+		return env.EnvContainer{}, fmt.Errorf("unsupported environment type: %s", envType)
+	}
+	if err != nil {
+		return env.EnvContainer{}, err
+	}
+	return envContainer, nil
+}
+
+// teardownEnv releases the container or DevPod workspace backing the flow's
+// environment once the flow finishes, however it finishes, after stopping the
+// flow's MCP servers. Other environment types hold no such resources, so
+// nothing else is done for them.
+func teardownEnv(dCtx DevContext) {
+	closeMcpClients(dCtx)
+	if dCtx.EnvContainer == nil {
+		return
+	}
+	if _, ok := dCtx.EnvContainer.Env.(env.Teardowner); !ok {
+		return
+	}
+
+	// Use disconnected context to ensure teardown can complete during cancellation
+	disconnectedCtx, _ := workflow.NewDisconnectedContext(dCtx)
+	err := workflow.ExecuteActivity(disconnectedCtx, env.TeardownEnvActivity, *dCtx.EnvContainer).Get(disconnectedCtx, nil)
+	if err != nil {
+		workflow.GetLogger(dCtx).Warn("Failed to tear down environment", "envType", dCtx.EnvContainer.Env.GetType(), "error", err)
+	}
+}
+
 // cleanup on cancel for resources created during setupDevContextAction
 func handleFlowCancel(dCtx DevContext) {
 	if !errors.Is(dCtx.Err(), workflow.ErrCanceled) {
@@ -390,6 +466,7 @@ func handleFlowCancel(dCtx DevContext) {
 						WorkspaceId:  dCtx.WorkspaceId,
 						FlowId:       flowInfo.WorkflowExecution.ID,
 						WorktreeDir:  dCtx.EnvContainer.Env.GetWorkingDirectory(),
+						EnvContainer: dCtx.EnvContainer,
 						SourceBranch: dCtx.Worktree.Name,
 					}
 					var dra *DevRunActivities
@@ -506,6 +583,16 @@ func mergeConfigs(localLLM common.LLMConfig, localEmbedding common.EmbeddingConf
 	for key, models := range workspaceEmbedding.UseCaseConfigs {
 		finalEmbeddingConfig.UseCaseConfigs[key] = models
 	}
+	if len(workspaceEmbedding.Retrieval) > 0 {
+		retrieval := make(map[string]common.RetrievalConfig, len(localEmbedding.Retrieval)+len(workspaceEmbedding.Retrieval))
+		for key, config := range localEmbedding.Retrieval {
+			retrieval[key] = config
+		}
+		for key, config := range workspaceEmbedding.Retrieval {
+			retrieval[key] = config
+		}
+		finalEmbeddingConfig.Retrieval = retrieval
+	}
 
 	return finalLLMConfig, finalEmbeddingConfig
 }
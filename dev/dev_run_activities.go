@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -18,6 +19,7 @@ import (
 
 	"sidekick/common"
 	"sidekick/domain"
+	"sidekick/env"
 	"sidekick/flow_action"
 )
 
@@ -41,6 +43,10 @@ type DevRunContext struct {
 	SourceBranch string
 	BaseBranch   string
 	TargetBranch string
+	// EnvContainer is the flow's environment. Dev Run commands are run inside
+	// the container when this is a container environment, and on the host
+	// otherwise.
+	EnvContainer *env.EnvContainer
 }
 
 // StartDevRunInput contains the input for starting a Dev Run.
@@ -306,9 +312,20 @@ func (a *DevRunActivities) startCommand(
 	// activity/worker restarts. Lifecycle is managed explicitly: workflow
 	// cleanup (stopActiveDevRun, handleFlowCancel) calls StopDevRun which
 	// terminates processes via session-level signals (SIGINT→SIGKILL).
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Dir = workingDir
-	cmd.Env = append(os.Environ(), envVars...)
+	var cmd *exec.Cmd
+	if remoteEnv := devRunRemoteEnv(devRunCtx); remoteEnv != nil {
+		// record the pid of the remote command, since stopping the local
+		// process on the host doesn't stop the remote command itself
+		wrapper := `echo $$ > "$0"; exec sh -c "$1"`
+		argv := remoteEnv.RemoteCommand(workingDir, envVars, "sh", "-c", wrapper, devRunContainerPidFile(devRunCtx.DevRunId), command)
+		cmd = exec.Command(argv[0], argv[1:]...)
+		cmd.Dir = remoteEnv.GetWorkingDirectory()
+		cmd.Env = os.Environ()
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+		cmd.Dir = workingDir
+		cmd.Env = append(os.Environ(), envVars...)
+	}
 
 	// Create a new session so processes survive worker restarts
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -453,6 +470,10 @@ func (a *DevRunActivities) StopDevRun(ctx context.Context, input StopDevRunInput
 
 	input.Context.DevRunId = instance.DevRunId
 
+	if remoteEnv := devRunRemoteEnv(input.Context); remoteEnv != nil {
+		terminateRemote(ctx, remoteEnv, devRunContainerPidFile(instance.DevRunId), timeout)
+	}
+
 	// Terminate process by session ID
 	terminateBySessionId(instance.SessionId, timeout)
 
@@ -663,6 +684,40 @@ func (a *DevRunActivities) terminateActiveRun(run *activeDevRun, timeoutSeconds
 	}
 }
 
+func devRunRemoteEnv(devRunCtx DevRunContext) env.RemoteCommandEnv {
+	if devRunCtx.EnvContainer == nil {
+		return nil
+	}
+	remoteEnv, _ := devRunCtx.EnvContainer.Env.(env.RemoteCommandEnv)
+	return remoteEnv
+}
+
+func devRunContainerPidFile(devRunId string) string {
+	return fmt.Sprintf("/tmp/sidekick-devrun-%s.pid", devRunId)
+}
+
+// terminateRemote terminates a Dev Run command running in a container or
+// DevPod workspace using the pid recorded in pidFile. Sends SIGINT, waits up to timeoutSeconds
+// for exit, then sends SIGKILL if needed, mirroring terminateBySessionId.
+func terminateRemote(ctx context.Context, remoteEnv env.RemoteCommandEnv, pidFile string, timeoutSeconds int) {
+	script := `pid=$(cat "$0" 2>/dev/null) || exit 0
+kill -INT "$pid" 2>/dev/null
+i=0
+while kill -0 "$pid" 2>/dev/null && [ "$i" -lt "$1" ]; do sleep 1; i=$((i+1)); done
+kill -KILL "$pid" 2>/dev/null
+rm -f "$0"
+exit 0`
+	output, err := remoteEnv.RunCommand(ctx, env.EnvRunCommandInput{
+		Command: "sh",
+		Args:    []string{"-c", script, pidFile, strconv.Itoa(timeoutSeconds)},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("envType", string(remoteEnv.GetType())).Msg("Failed to terminate remote Dev Run")
+	} else if output.ExitStatus != 0 {
+		log.Warn().Str("stderr", output.Stderr).Str("envType", string(remoteEnv.GetType())).Msg("Failed to terminate remote Dev Run")
+	}
+}
+
 // terminateBySessionId terminates a process session by session ID.
 // Sends SIGINT, waits up to timeoutSeconds for exit, then sends SIGKILL if needed.
 func terminateBySessionId(sessionId int, timeoutSeconds int) {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +15,7 @@ import (
 
 	"sidekick/common"
 	"sidekick/domain"
+	"sidekick/env"
 	"sidekick/flow_action"
 )
 
@@ -1258,3 +1260,87 @@ func TestStartDevRun_NoExistingInstanceStartsFresh(t *testing.T) {
 		Instance:     output.Instance,
 	})
 }
+
+func TestDevRun_ContainerEnv(t *testing.T) {
+	t.Parallel()
+
+	streamer := newMockFlowEventStreamer()
+	activities := &DevRunActivities{Streamer: streamer}
+
+	tmpDir := t.TempDir()
+	workspaceId := "ws_container_" + t.Name()
+	flowId := "flow_container_" + t.Name()
+
+	// fake container runtime that logs its args and runs exec commands on the host
+	runtimeDir := t.TempDir()
+	logPath := filepath.Join(runtimeDir, "invocations.log")
+	runtimePath := filepath.Join(runtimeDir, "fake-docker")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$1 $2 $3" >> %q
+[ "$1" = "exec" ] || exit 0
+shift
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --workdir) cd "$2" || exit 1; shift 2 ;;
+    --env) export "$2"; shift 2 ;;
+    *) break ;;
+  esac
+done
+shift
+exec "$@"
+`, logPath)
+	require.NoError(t, os.WriteFile(runtimePath, []byte(script), 0755))
+
+	devRunCtx := DevRunContext{
+		WorkspaceId:  workspaceId,
+		FlowId:       flowId,
+		WorktreeDir:  tmpDir,
+		SourceBranch: "feature/container",
+		EnvContainer: &env.EnvContainer{Env: &env.ContainerEnv{
+			WorkingDirectory: tmpDir,
+			Runtime:          runtimePath,
+			ContainerName:    "sidekick-test",
+		}},
+	}
+	devRunConfig := common.DevRunConfig{
+		"test": {Command: "echo $SOURCE_BRANCH; sleep 60", StopTimeoutSeconds: 2},
+	}
+
+	startOutput, err := activities.StartDevRun(context.Background(), StartDevRunInput{
+		DevRunConfig: devRunConfig,
+		CommandId:    "test",
+		Context:      devRunCtx,
+	})
+	require.NoError(t, err)
+	require.True(t, startOutput.Started)
+
+	pidFile := devRunContainerPidFile(startOutput.DevRunId)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(pidFile)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "pid file should be written inside the container")
+
+	devRunCtx.DevRunId = startOutput.DevRunId
+	stopOutput, err := activities.StopDevRun(context.Background(), StopDevRunInput{
+		DevRunConfig: devRunConfig,
+		CommandId:    "test",
+		Context:      devRunCtx,
+		Instance:     startOutput.Instance,
+	})
+	require.NoError(t, err)
+	assert.True(t, stopOutput.Stopped)
+
+	_, err = os.Stat(pidFile)
+	assert.True(t, os.IsNotExist(err), "pid file should be removed after stopping")
+
+	logContent, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	invocations := strings.Split(strings.TrimSpace(string(logContent)), "\n")
+	require.Len(t, invocations, 2)
+	assert.Equal(t, "exec --workdir "+tmpDir, invocations[0])
+	assert.Equal(t, "exec --workdir "+tmpDir, invocations[1])
+
+	output, err := os.ReadFile(startOutput.Instance.OutputFilePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "feature/container")
+}
@@ -299,6 +299,25 @@ func authorEditBlocks(dCtx DevContext, codingModelConfig common.ModelConfig, con
 			attemptsSinceLastEditBlockOrFeedback = 0
 		}
 
+		// budget checkpoint
+		if response, err := CheckBudget(dCtx); err != nil {
+			return nil, err
+		} else if response != nil && response.Content != "" {
+			switch info := promptInfo.(type) {
+			case InitialCodeInfo, InitialDevStepInfo:
+				// Flush initial instructions into chat history before replacing with guidance
+				if _, err := buildAuthorEditBlockInput(dCtx, codingModelConfig, chatHistory, promptInfo, doneRequired, hasPlan, environmentContext); err != nil {
+					return nil, err
+				}
+				promptInfo = FeedbackInfo{Feedback: response.Content, Type: FeedbackTypeUserGuidance}
+			case FeedbackInfo:
+				promptInfo = FeedbackInfo{Feedback: info.Feedback + "\n\n" + response.Content, Type: FeedbackTypeUserGuidance}
+			default:
+				promptInfo = FeedbackInfo{Feedback: response.Content, Type: FeedbackTypeUserGuidance}
+			}
+			attemptsSinceLastEditBlockOrFeedback = 0
+		}
+
 		// Inject proactive system message based on tool-call thresholds, merging
 		// with any existing pending feedback to avoid overwriting it.
 		if msg, ok := ThresholdMessageForCounter(feedbackIterations, attemptsSinceLastEditBlockOrFeedback); ok {
@@ -623,6 +642,7 @@ func buildAuthorEditBlockInput(dCtx DevContext, codingModelConfig common.ModelCo
 	if !dCtx.RepoConfig.DisableHumanInTheLoop {
 		tools = append(tools, &getHelpOrInputTool)
 	}
+	tools = append(tools, mcpTools(dCtx)...)
 
 	options := llm2.Options{
 		Tools: tools,
@@ -0,0 +1,333 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/srv"
+)
+
+// slotReservationTimeout is how long a slot granted to a task is held while
+// its flow isn't persisted as running yet, e.g. when starting it failed.
+const slotReservationTimeout = time.Minute
+
+type flowSlotKey struct {
+	workspaceId string
+	taskId      string
+}
+
+// flowSlots is the single place where slots under the concurrency limits are
+// checked and granted, for all workspaces. The dev manager workflows of
+// different workspaces start flows concurrently, so granted slots stay
+// reserved until their flows are persisted as running, to keep two workspaces
+// from both taking the last free slot. The zero value is ready to use.
+type flowSlots struct {
+	mu       sync.Mutex
+	reserved map[flowSlotKey]time.Time
+}
+
+func (s *flowSlots) reserve(tasks ...domain.Task) {
+	if s.reserved == nil {
+		s.reserved = make(map[flowSlotKey]time.Time)
+	}
+	for _, task := range tasks {
+		s.reserved[flowSlotKey{task.WorkspaceId, task.Id}] = time.Now()
+	}
+}
+
+// countRunningFlows counts the running flows per workspace, including the
+// slots reserved for flows that are about to start. Must be called with the
+// lock held.
+func (s *flowSlots) countRunningFlows(ctx context.Context, storage srv.Storage) (map[string]int, error) {
+	running, started, err := countRunningTaskFlows(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	for key, reservedAt := range s.reserved {
+		if started[key] || time.Since(reservedAt) > slotReservationTimeout {
+			delete(s.reserved, key)
+			continue
+		}
+		// the flow may also have started and finished already
+		flows, err := storage.GetFlowsForTask(ctx, key.workspaceId, key.taskId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flows for task %s: %w", key.taskId, err)
+		}
+		if slices.ContainsFunc(flows, func(flow domain.Flow) bool { return !flow.Created.Before(reservedAt) }) {
+			delete(s.reserved, key)
+			continue
+		}
+		running[key.workspaceId]++
+	}
+	return running, nil
+}
+
+// countRunningTaskFlows counts the flows running for tasks per workspace, and
+// returns which tasks they are for. Flows waiting on a human, i.e. for blocked
+// or in review tasks, aren't counted, as they don't use any resources until
+// the human responds.
+func countRunningTaskFlows(ctx context.Context, storage srv.Storage) (map[string]int, map[flowSlotKey]bool, error) {
+	flows, err := storage.GetFlowsWithStatus(ctx, "in_progress")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get running flows: %w", err)
+	}
+
+	running := make(map[string]int)
+	started := make(map[flowSlotKey]bool)
+	for _, flow := range flows {
+		task, err := storage.GetTask(ctx, flow.WorkspaceId, flow.ParentId)
+		if errors.Is(err, srv.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to get task %s: %w", flow.ParentId, err)
+		}
+		// to_do tasks are included since the flow for a task is started
+		// before the task is moved to in_progress
+		if task.Status != domain.TaskStatusToDo && task.Status != domain.TaskStatusInProgress {
+			continue
+		}
+		running[flow.WorkspaceId]++
+		started[flowSlotKey{flow.WorkspaceId, flow.ParentId}] = true
+	}
+	return running, started, nil
+}
+
+// CountRunningFlows counts the flows running for tasks in the given workspace.
+// Flows waiting on a human, i.e. for blocked or in review tasks, aren't
+// counted, as they don't use any resources until the human responds.
+func CountRunningFlows(ctx context.Context, storage srv.Storage, workspaceId string) (int, error) {
+	running, _, err := countRunningTaskFlows(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+	return running[workspaceId], nil
+}
+
+// availableFlowSlots returns how many more flows may start in the given
+// workspace without exceeding the concurrency limits, or -1 if there is no
+// limit. Must be called with the lock held.
+func (s *flowSlots) availableFlowSlots(ctx context.Context, storage srv.Storage, limits common.ConcurrencyConfig, workspaceId string) (int, error) {
+	if limits.MaxRunningFlowsPerWorkspace <= 0 && limits.MaxRunningFlows <= 0 {
+		return -1, nil
+	}
+	running, err := s.countRunningFlows(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+
+	available := -1
+	if limits.MaxRunningFlowsPerWorkspace > 0 {
+		available = max(limits.MaxRunningFlowsPerWorkspace-running[workspaceId], 0)
+	}
+	if limits.MaxRunningFlows > 0 {
+		total := 0
+		for _, count := range running {
+			total += count
+		}
+		globalAvailable := max(limits.MaxRunningFlows-total, 0)
+		if available < 0 || globalAvailable < available {
+			available = globalAvailable
+		}
+	}
+	return available, nil
+}
+
+// GetQueuedTasks returns the tasks in the given workspace's queue, in the
+// order they will start.
+func GetQueuedTasks(ctx context.Context, storage srv.Storage, workspaceId string) ([]domain.Task, error) {
+	tasks, err := storage.GetTasks(ctx, workspaceId, []domain.TaskStatus{domain.TaskStatusToDo})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	var queued []domain.Task
+	for _, task := range tasks {
+		if task.QueuePosition > 0 {
+			queued = append(queued, task)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queued[i].QueuePosition < queued[j].QueuePosition
+	})
+	return queued, nil
+}
+
+// SortTaskQueue orders queued tasks by priority, keeping the existing order
+// among tasks with the same priority.
+func SortTaskQueue(queue []domain.Task) {
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].Priority > queue[j].Priority
+	})
+}
+
+// persistQueuePositions sets the queue position of each task to its index in
+// the queue, persisting the tasks whose position changed so that the change is
+// streamed.
+func persistQueuePositions(ctx context.Context, storage srv.Storage, queue []domain.Task) error {
+	for i := range queue {
+		if queue[i].QueuePosition == i+1 {
+			continue
+		}
+		queue[i].QueuePosition = i + 1
+		queue[i].Updated = time.Now()
+		if err := storage.PersistTask(ctx, queue[i]); err != nil {
+			return fmt.Errorf("failed to update queue position of task %s: %w", queue[i].Id, err)
+		}
+	}
+	return nil
+}
+
+// UpdateQueuePositions renumbers the given workspace's queue, e.g. after a
+// queued task was canceled.
+func UpdateQueuePositions(ctx context.Context, storage srv.Storage, workspaceId string) error {
+	queue, err := GetQueuedTasks(ctx, storage, workspaceId)
+	if err != nil {
+		return err
+	}
+	SortTaskQueue(queue)
+	return persistQueuePositions(ctx, storage, queue)
+}
+
+type QueueTaskInput struct {
+	WorkspaceId string
+	TaskId      string
+	Priority    int
+}
+
+// QueueTask adds the given task to its workspace's queue if starting it would
+// exceed the concurrency limits, or if tasks with the same or a higher
+// priority are already waiting. Returns whether the task was queued; if not,
+// a slot is reserved for it and it may start right away.
+func (s *flowSlots) QueueTask(ctx context.Context, storage srv.Storage, limits common.ConcurrencyConfig, input QueueTaskInput) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := storage.GetTask(ctx, input.WorkspaceId, input.TaskId)
+	if err != nil {
+		return false, fmt.Errorf("failed to get task %s: %w", input.TaskId, err)
+	}
+	task.Priority = input.Priority
+
+	queue, err := GetQueuedTasks(ctx, storage, input.WorkspaceId)
+	if err != nil {
+		return false, err
+	}
+
+	waitingAhead := false
+	for i := range queue {
+		if queue[i].Id == task.Id {
+			// already queued, e.g. when the task was edited, so remove it
+			// from its old position and re-queue it below
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	for _, queued := range queue {
+		if queued.Priority >= task.Priority {
+			waitingAhead = true
+			break
+		}
+	}
+
+	if !waitingAhead {
+		available, err := s.availableFlowSlots(ctx, storage, limits, input.WorkspaceId)
+		if err != nil {
+			return false, err
+		}
+		if available != 0 {
+			if available > 0 {
+				s.reserve(task)
+			}
+			if task.QueuePosition > 0 {
+				task.QueuePosition = 0
+				task.Updated = time.Now()
+				if err := storage.PersistTask(ctx, task); err != nil {
+					return false, fmt.Errorf("failed to dequeue task %s: %w", task.Id, err)
+				}
+				return false, persistQueuePositions(ctx, storage, queue)
+			}
+			return false, nil
+		}
+	}
+
+	task.Status = domain.TaskStatusToDo
+	task.AgentType = domain.AgentTypeLLM
+	// ensures the task is persisted even if its position doesn't change
+	task.QueuePosition = 0
+	queue = append(queue, task)
+	SortTaskQueue(queue)
+	if err := persistQueuePositions(ctx, storage, queue); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DequeueTasks removes as many tasks from the front of the given workspace's
+// queue as the concurrency limits allow to start now, reserving slots for
+// them, and returns them.
+func (s *flowSlots) DequeueTasks(ctx context.Context, storage srv.Storage, limits common.ConcurrencyConfig, workspaceId string) ([]domain.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue, err := GetQueuedTasks(ctx, storage, workspaceId)
+	if err != nil || len(queue) == 0 {
+		return nil, err
+	}
+	SortTaskQueue(queue)
+
+	available, err := s.availableFlowSlots(ctx, storage, limits, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	limited := available >= 0
+	if available < 0 || available > len(queue) {
+		available = len(queue)
+	}
+	if limited {
+		s.reserve(queue[:available]...)
+	}
+
+	dequeued := queue[:available]
+	for i := range dequeued {
+		dequeued[i].QueuePosition = 0
+		dequeued[i].Updated = time.Now()
+		if err := storage.PersistTask(ctx, dequeued[i]); err != nil {
+			return nil, fmt.Errorf("failed to dequeue task %s: %w", dequeued[i].Id, err)
+		}
+	}
+	if err := persistQueuePositions(ctx, storage, queue[available:]); err != nil {
+		return nil, err
+	}
+	return dequeued, nil
+}
+
+// GetWorkspacesWithQueuedTasks returns the ids of the workspaces, other than
+// the given one, that have tasks waiting in their queue.
+func GetWorkspacesWithQueuedTasks(ctx context.Context, storage srv.Storage, exceptWorkspaceId string) ([]string, error) {
+	workspaces, err := storage.GetAllWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+
+	var workspaceIds []string
+	for _, workspace := range workspaces {
+		if workspace.Id == exceptWorkspaceId {
+			continue
+		}
+		queue, err := GetQueuedTasks(ctx, storage, workspace.Id)
+		if err != nil {
+			return nil, err
+		}
+		if len(queue) > 0 {
+			workspaceIds = append(workspaceIds, workspace.Id)
+		}
+	}
+	return workspaceIds, nil
+}
@@ -0,0 +1,190 @@
+package dev
+
+import (
+	"context"
+	"testing"
+
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/srv"
+	"sidekick/srv/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortTaskQueue(t *testing.T) {
+	t.Parallel()
+
+	queue := []domain.Task{
+		{Id: "task_1"},
+		{Id: "task_2", Priority: 1},
+		{Id: "task_3"},
+		{Id: "task_4", Priority: 1},
+		{Id: "task_5", Priority: -1},
+	}
+	SortTaskQueue(queue)
+
+	var ids []string
+	for _, task := range queue {
+		ids = append(ids, task.Id)
+	}
+	assert.Equal(t, []string{"task_2", "task_4", "task_1", "task_3", "task_5"}, ids)
+}
+
+func queuePositions(t *testing.T, storage srv.Storage, workspaceId string, taskIds ...string) []int {
+	t.Helper()
+	var positions []int
+	for _, taskId := range taskIds {
+		task, err := storage.GetTask(context.Background(), workspaceId, taskId)
+		require.NoError(t, err)
+		positions = append(positions, task.QueuePosition)
+	}
+	return positions
+}
+
+func TestQueueTask(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "flow_queue_test")
+	workspaceId := "ws_1"
+	limits := common.ConcurrencyConfig{MaxRunningFlowsPerWorkspace: 1}
+	slots := &flowSlots{}
+
+	running := domain.Task{WorkspaceId: workspaceId, Id: "task_running", Status: domain.TaskStatusInProgress}
+	waiting := domain.Task{WorkspaceId: workspaceId, Id: "task_waiting", Status: domain.TaskStatusInReview}
+	low := domain.Task{WorkspaceId: workspaceId, Id: "task_low", Status: domain.TaskStatusToDo}
+	high := domain.Task{WorkspaceId: workspaceId, Id: "task_high", Status: domain.TaskStatusBlocked}
+	persistTasks(t, storage, running, waiting, low, high)
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_running", ParentId: running.Id, Status: "in_progress"}))
+	// flows waiting on a human don't count towards the limits
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_waiting", ParentId: waiting.Id, Status: "in_progress"}))
+
+	count, err := CountRunningFlows(ctx, storage, workspaceId)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	queued, err := slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: workspaceId, TaskId: low.Id})
+	require.NoError(t, err)
+	assert.True(t, queued)
+	queued, err = slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: workspaceId, TaskId: high.Id, Priority: 1})
+	require.NoError(t, err)
+	assert.True(t, queued)
+	assert.Equal(t, []int{2, 1}, queuePositions(t, storage, workspaceId, low.Id, high.Id))
+
+	high, err = storage.GetTask(ctx, workspaceId, high.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatusToDo, high.Status)
+	assert.Equal(t, domain.AgentTypeLLM, high.AgentType)
+	assert.Equal(t, 1, high.Priority)
+
+	// nothing can start until the running flow finishes
+	dequeued, err := slots.DequeueTasks(ctx, storage, limits, workspaceId)
+	require.NoError(t, err)
+	assert.Empty(t, dequeued)
+
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_running", ParentId: running.Id, Status: "completed"}))
+	dequeued, err = slots.DequeueTasks(ctx, storage, limits, workspaceId)
+	require.NoError(t, err)
+	require.Len(t, dequeued, 1)
+	assert.Equal(t, high.Id, dequeued[0].Id)
+	assert.Equal(t, []int{1, 0}, queuePositions(t, storage, workspaceId, low.Id, high.Id))
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_high", ParentId: high.Id, Status: "completed"}))
+
+	// a slot is free, but the queued task is waiting ahead of a new task with
+	// the same priority
+	other := domain.Task{WorkspaceId: workspaceId, Id: "task_other", Status: domain.TaskStatusToDo}
+	persistTasks(t, storage, other)
+	queued, err = slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: workspaceId, TaskId: other.Id})
+	require.NoError(t, err)
+	assert.True(t, queued)
+	assert.Equal(t, []int{1, 2}, queuePositions(t, storage, workspaceId, low.Id, other.Id))
+
+	// raising the priority of a queued task moves it to the front, and
+	// starts it right away when there is a free slot
+	queued, err = slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: workspaceId, TaskId: other.Id, Priority: 2})
+	require.NoError(t, err)
+	assert.False(t, queued)
+	assert.Equal(t, []int{1, 0}, queuePositions(t, storage, workspaceId, low.Id, other.Id))
+}
+
+func TestAvailableFlowSlots_GlobalLimit(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "flow_queue_test")
+	slots := &flowSlots{}
+
+	for _, workspaceId := range []string{"ws_1", "ws_2"} {
+		require.NoError(t, storage.PersistWorkspace(ctx, domain.Workspace{Id: workspaceId}))
+		task := domain.Task{WorkspaceId: workspaceId, Id: "task_" + workspaceId, Status: domain.TaskStatusInProgress}
+		persistTasks(t, storage, task)
+		require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_" + workspaceId, ParentId: task.Id, Status: "in_progress"}))
+	}
+
+	available, err := slots.availableFlowSlots(ctx, storage, common.ConcurrencyConfig{}, "ws_1")
+	require.NoError(t, err)
+	assert.Equal(t, -1, available)
+
+	available, err = slots.availableFlowSlots(ctx, storage, common.ConcurrencyConfig{MaxRunningFlowsPerWorkspace: 3}, "ws_1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, available)
+
+	available, err = slots.availableFlowSlots(ctx, storage, common.ConcurrencyConfig{MaxRunningFlows: 3, MaxRunningFlowsPerWorkspace: 3}, "ws_1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, available)
+
+	available, err = slots.availableFlowSlots(ctx, storage, common.ConcurrencyConfig{MaxRunningFlows: 2}, "ws_1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, available)
+}
+
+func TestQueueTask_ReservesGlobalSlot(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "flow_queue_test")
+	slots := &flowSlots{}
+	limits := common.ConcurrencyConfig{MaxRunningFlows: 1}
+
+	first := domain.Task{WorkspaceId: "ws_1", Id: "task_first", Status: domain.TaskStatusToDo}
+	second := domain.Task{WorkspaceId: "ws_2", Id: "task_second", Status: domain.TaskStatusToDo}
+	persistTasks(t, storage, first, second)
+
+	queued, err := slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: first.WorkspaceId, TaskId: first.Id})
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	// the slot is taken before the first task's flow is persisted, so another
+	// workspace can't take it too
+	queued, err = slots.QueueTask(ctx, storage, limits, QueueTaskInput{WorkspaceId: second.WorkspaceId, TaskId: second.Id})
+	require.NoError(t, err)
+	assert.True(t, queued)
+
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: first.WorkspaceId, Id: "flow_first", ParentId: first.Id, Status: "in_progress"}))
+	dequeued, err := slots.DequeueTasks(ctx, storage, limits, second.WorkspaceId)
+	require.NoError(t, err)
+	assert.Empty(t, dequeued)
+
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: first.WorkspaceId, Id: "flow_first", ParentId: first.Id, Status: "completed"}))
+	dequeued, err = slots.DequeueTasks(ctx, storage, limits, second.WorkspaceId)
+	require.NoError(t, err)
+	require.Len(t, dequeued, 1)
+	assert.Equal(t, second.Id, dequeued[0].Id)
+}
+
+func TestDequeueTasks_ReservesSlotsBelowLimit(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "flow_queue_test")
+	slots := &flowSlots{}
+	limits := common.ConcurrencyConfig{MaxRunningFlows: 3}
+
+	queued := domain.Task{WorkspaceId: "ws_1", Id: "task_queued", Status: domain.TaskStatusToDo, QueuePosition: 1}
+	persistTasks(t, storage, queued)
+
+	// the limit leaves more slots than there are queued tasks
+	dequeued, err := slots.DequeueTasks(ctx, storage, limits, queued.WorkspaceId)
+	require.NoError(t, err)
+	require.Len(t, dequeued, 1)
+	assert.Equal(t, queued.Id, dequeued[0].Id)
+
+	// the dequeued task holds its slot before its flow is persisted
+	available, err := slots.availableFlowSlots(ctx, storage, limits, "ws_2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, available)
+}
@@ -0,0 +1,98 @@
+package dev
+
+import (
+	"sidekick/domain"
+	"sidekick/utils"
+)
+
+func init() {
+	domain.RegisterFlowType(domain.FlowTypeDefinition{
+		Name:          domain.FlowTypeBasicDev,
+		Label:         "Just Code",
+		Description:   "Edits code to fulfill the requirements directly, without a separate planning step.",
+		OptionsSchema: domain.FlowOptionsSchema(&BasicDevOptions{}),
+		Workflow:      BasicDevWorkflow,
+		BuildInput: func(params domain.FlowLaunchParams) (interface{}, error) {
+			var options BasicDevOptions
+			utils.Transcode(params.FlowOptions, &options)
+			return BasicDevWorkflowInput{
+				WorkspaceId:     params.WorkspaceId,
+				Requirements:    params.Requirements,
+				RepoDir:         params.RepoDir,
+				BasicDevOptions: options,
+			}, nil
+		},
+	})
+
+	domain.RegisterFlowType(domain.FlowTypeDefinition{
+		Name:          domain.FlowTypePlannedDev,
+		Label:         "Plan Then Code",
+		Description:   "Creates a step-by-step plan for the requirements, then edits code to complete each step.",
+		OptionsSchema: domain.FlowOptionsSchema(&PlannedDevOptions{}),
+		Workflow:      PlannedDevWorkflow,
+		BuildInput: func(params domain.FlowLaunchParams) (interface{}, error) {
+			var options PlannedDevOptions
+			utils.Transcode(params.FlowOptions, &options)
+			return PlannedDevInput{
+				WorkspaceId:       params.WorkspaceId,
+				Requirements:      params.Requirements,
+				RepoDir:           params.RepoDir,
+				PlannedDevOptions: options,
+			}, nil
+		},
+	})
+
+	domain.RegisterFlowType(domain.FlowTypeDefinition{
+		Name:          domain.FlowTypeCustom,
+		Label:         "Custom",
+		Description:   "Runs a flow defined under flows in the repo config (side.yml or side.toml), named by the customFlowName option.",
+		OptionsSchema: domain.FlowOptionsSchema(&CustomFlowOptions{}),
+		Workflow:      CustomFlowWorkflow,
+		BuildInput: func(params domain.FlowLaunchParams) (interface{}, error) {
+			var options CustomFlowOptions
+			utils.Transcode(params.FlowOptions, &options)
+			return CustomFlowInput{
+				WorkspaceId:       params.WorkspaceId,
+				Requirements:      params.Requirements,
+				RepoDir:           params.RepoDir,
+				CustomFlowOptions: options,
+			}, nil
+		},
+	})
+
+	domain.RegisterFlowType(domain.FlowTypeDefinition{
+		Name:          domain.FlowTypeDecompose,
+		Label:         "Split Into Tasks",
+		Description:   "Splits a large task into child tasks with their own requirements and dependencies, then runs each child task with the childFlowType flow once approved.",
+		OptionsSchema: domain.FlowOptionsSchema(&DecomposeTaskOptions{}),
+		Workflow:      DecomposeTaskWorkflow,
+		BuildInput: func(params domain.FlowLaunchParams) (interface{}, error) {
+			var options DecomposeTaskOptions
+			utils.Transcode(params.FlowOptions, &options)
+			return DecomposeTaskInput{
+				WorkspaceId:          params.WorkspaceId,
+				Requirements:         params.Requirements,
+				RepoDir:              params.RepoDir,
+				DecomposeTaskOptions: options,
+			}, nil
+		},
+	})
+
+	domain.RegisterFlowType(domain.FlowTypeDefinition{
+		Name:          domain.FlowTypeAddressReview,
+		Label:         "Address Review",
+		Description:   "Addresses unresolved review comments on a pull request opened by another task, named by the pullRequestUrl option, then pushes the changes to it and replies to the comments.",
+		OptionsSchema: domain.FlowOptionsSchema(&AddressReviewOptions{}),
+		Workflow:      AddressReviewWorkflow,
+		BuildInput: func(params domain.FlowLaunchParams) (interface{}, error) {
+			var options AddressReviewOptions
+			utils.Transcode(params.FlowOptions, &options)
+			return AddressReviewInput{
+				WorkspaceId:          params.WorkspaceId,
+				Requirements:         params.Requirements,
+				RepoDir:              params.RepoDir,
+				AddressReviewOptions: options,
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,102 @@
+package dev
+
+import (
+	"testing"
+
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinFlowTypesRegistered(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{domain.FlowTypeBasicDev, domain.FlowTypePlannedDev} {
+		flowType, err := domain.StringToFlowType(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, flowType)
+
+		def, ok := domain.GetFlowType(name)
+		require.True(t, ok)
+		assert.NotEmpty(t, def.Label)
+		assert.NotNil(t, def.OptionsSchema)
+	}
+
+	_, err := domain.StringToFlowType("nonexistent_flow")
+	assert.Error(t, err)
+}
+
+func TestBuiltinFlowTypes_BuildInput(t *testing.T) {
+	t.Parallel()
+
+	def, ok := domain.GetFlowType(domain.FlowTypePlannedDev)
+	require.True(t, ok)
+	input, err := def.BuildInput(domain.FlowLaunchParams{
+		WorkspaceId:  "ws_1",
+		RepoDir:      "/repo",
+		Requirements: "do the thing",
+		FlowOptions: map[string]interface{}{
+			"planningPrompt": "plan carefully",
+			"envType":        "local_git_worktree",
+			"startBranch":    "main",
+		},
+	})
+	require.NoError(t, err)
+
+	plannedInput, ok := input.(PlannedDevInput)
+	require.True(t, ok)
+	assert.Equal(t, "ws_1", plannedInput.WorkspaceId)
+	assert.Equal(t, "/repo", plannedInput.RepoDir)
+	assert.Equal(t, "do the thing", plannedInput.Requirements)
+	assert.Equal(t, "plan carefully", plannedInput.PlanningPrompt)
+	require.NotNil(t, plannedInput.StartBranch)
+	assert.Equal(t, "main", *plannedInput.StartBranch)
+}
+
+func TestBuiltinFlowTypes_ValidateFlowOptions(t *testing.T) {
+	t.Parallel()
+
+	// typical options sent by the UI, including ones meant for other flow types
+	validOptions := map[string]interface{}{
+		"planningPrompt":        "",
+		"determineRequirements": true,
+		"envType":               "devpod",
+		"startBranch":           "main",
+		"configOverrides": map[string]interface{}{
+			"disableHumanInTheLoop": true,
+			"maxIterations":         5,
+			"checkCommands":         []interface{}{map[string]interface{}{"command": "go vet ./..."}},
+			"llm": map[string]interface{}{
+				"defaults": []interface{}{map[string]interface{}{"provider": "anthropic", "model": "claude-sonnet-4-5"}},
+				"useCaseConfigs": map[string]interface{}{
+					"planning": []interface{}{map[string]interface{}{"provider": "openai", "reasoningEffort": "high"}},
+				},
+			},
+		},
+	}
+	for _, name := range []string{domain.FlowTypeBasicDev, domain.FlowTypePlannedDev} {
+		assert.NoError(t, domain.ValidateFlowOptions(name, validOptions), name)
+		assert.NoError(t, domain.ValidateFlowOptions(name, nil), name)
+	}
+
+	err := domain.ValidateFlowOptions(domain.FlowTypeBasicDev, map[string]interface{}{"determineRequirements": "yes"})
+	assert.ErrorContains(t, err, "determineRequirements: expected boolean, got string")
+
+	err = domain.ValidateFlowOptions(domain.FlowTypeBasicDev, map[string]interface{}{
+		"configOverrides": map[string]interface{}{"maxIterations": 2.5},
+	})
+	assert.ErrorContains(t, err, "configOverrides.maxIterations: expected integer")
+
+	err = domain.ValidateFlowOptions(domain.FlowTypeBasicDev, map[string]interface{}{
+		"configOverrides": map[string]interface{}{
+			"llm": map[string]interface{}{
+				"useCaseConfigs": map[string]interface{}{"planning": "openai"},
+			},
+		},
+	})
+	assert.ErrorContains(t, err, "configOverrides.llm.useCaseConfigs.planning: expected array")
+
+	err = domain.ValidateFlowOptions("nonexistent_flow", validOptions)
+	assert.Error(t, err)
+}
@@ -248,6 +248,10 @@ func handleToolCall(dCtx DevContext, toolCall llm.ToolCall) (ToolCallOutput, err
 				return SetBaseBranch(trackedDCtx, setBaseBranchParams)
 			})
 		default:
+			if serverTool, ok := findMcpTool(trackedDCtx, toolCall.Name); ok {
+				response, err = CallMcpTool(trackedDCtx, serverTool, llm.RepairJson(toolCall.Arguments))
+				break
+			}
 			// FIXME this should be non-retryable but is being retried now (openai can rarely use a function name that we don't support)
 			response, err = "", fmt.Errorf("unknown function name: %s", toolCall.Name)
 		}
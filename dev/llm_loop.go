@@ -110,6 +110,21 @@ func LlmLoop[T any](dCtx DevContext, chatHistory *persisted_ai.ChatHistoryContai
 			iteration.AutoIterationCount = 0
 		}
 
+		// Check the budget before spending more on the next iteration
+		budgetResponse, err := CheckBudget(dCtx)
+		if err != nil {
+			return nil, err
+		}
+		if budgetResponse != nil && budgetResponse.Content != "" {
+			if err := AppendChatHistory(dCtx.ExecContext, iteration.ChatHistory, llm.ChatMessage{
+				Role:    "user",
+				Content: renderGeneralFeedbackPrompt(budgetResponse.Content, FeedbackTypeUserGuidance),
+			}); err != nil {
+				return nil, err
+			}
+			iteration.AutoIterationCount = 0
+		}
+
 		// Inject proactive system message when nearing per-cycle tool-call limits
 		if msg, ok := ThresholdMessageForCounter(config.autoIterations, iteration.AutoIterationCount); ok {
 			if err := AppendChatHistory(dCtx.ExecContext, iteration.ChatHistory, llm.ChatMessage{
@@ -0,0 +1,162 @@
+package dev
+
+import (
+	"encoding/json"
+	"fmt"
+	"sidekick/common"
+	"sidekick/llm"
+	"sidekick/mcp"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"go.temporal.io/sdk/workflow"
+)
+
+// listMcpTools lists the tools of the MCP servers configured for the repo,
+// to be offered to the LLM alongside the built-in tools.
+func listMcpTools(dCtx DevContext) ([]mcp.ServerTool, error) {
+	var ma *mcp.McpActivities
+	var serverTools []mcp.ServerTool
+	err := workflow.ExecuteActivity(dCtx, ma.ListMcpTools, mcp.ListMcpToolsInput{
+		Servers:      dCtx.RepoConfig.McpServers,
+		FlowId:       workflow.GetInfo(dCtx).WorkflowExecution.ID,
+		EnvContainer: *dCtx.EnvContainer,
+	}).Get(dCtx, &serverTools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MCP tools: %w", err)
+	}
+	return serverTools, nil
+}
+
+// closeMcpClients stops the flow's MCP servers, which must happen before its
+// environment is torn down, since stdio servers may be running inside it.
+func closeMcpClients(dCtx DevContext) {
+	if len(dCtx.RepoConfig.McpServers) == 0 {
+		return
+	}
+	if v := workflow.GetVersion(dCtx, "mcp-client-teardown", workflow.DefaultVersion, 1); v < 1 {
+		return
+	}
+
+	// Use disconnected context to ensure the servers are stopped during cancellation
+	disconnectedCtx, _ := workflow.NewDisconnectedContext(dCtx)
+	var ma *mcp.McpActivities
+	err := workflow.ExecuteActivity(disconnectedCtx, ma.CloseMcpClients, workflow.GetInfo(dCtx).WorkflowExecution.ID).Get(disconnectedCtx, nil)
+	if err != nil {
+		workflow.GetLogger(dCtx).Warn("Failed to close MCP clients", "error", err)
+	}
+}
+
+// mcpTools returns the MCP tools listed for the dev context as LLM tools.
+func mcpTools(dCtx DevContext) []*llm.Tool {
+	tools := make([]*llm.Tool, 0, len(dCtx.McpTools))
+	for _, serverTool := range dCtx.McpTools {
+		tools = append(tools, mcpToolToLlmTool(serverTool))
+	}
+	return tools
+}
+
+func mcpToolToLlmTool(serverTool mcp.ServerTool) *llm.Tool {
+	parameters := &jsonschema.Schema{Type: "object"}
+	if len(serverTool.Tool.InputSchema) > 0 {
+		var schema jsonschema.Schema
+		if err := json.Unmarshal(serverTool.Tool.InputSchema, &schema); err == nil {
+			parameters = &schema
+		}
+	}
+
+	description := serverTool.Tool.Description
+	if description == "" {
+		description = serverTool.Tool.Title
+	}
+	description = strings.TrimSpace(fmt.Sprintf("[%s MCP server] %s", serverTool.Server, description))
+
+	return &llm.Tool{
+		Name:        serverTool.ExposedName,
+		Description: description,
+		Parameters:  parameters,
+	}
+}
+
+func findMcpTool(dCtx DevContext, exposedName string) (mcp.ServerTool, bool) {
+	for _, serverTool := range dCtx.McpTools {
+		if serverTool.ExposedName == exposedName {
+			return serverTool, true
+		}
+	}
+	return mcp.ServerTool{}, false
+}
+
+// CallMcpTool calls an MCP server's tool after checking the server's
+// permissions, which are matched against the tool's name. Calls that aren't
+// auto-approved require the user's approval, the same as commands.
+func CallMcpTool(dCtx DevContext, serverTool mcp.ServerTool, arguments string) (string, error) {
+	config := dCtx.RepoConfig.McpServers[serverTool.Server]
+
+	var permission CheckCommandPermissionOutput
+	err := workflow.ExecuteActivity(dCtx, CheckCommandPermissionActivity, CheckCommandPermissionInput{
+		CommandPermissions: config.Permissions,
+		Command:            serverTool.Tool.Name,
+	}).Get(dCtx, &permission)
+	if err != nil {
+		return "", fmt.Errorf("failed to check MCP tool permission: %v", err)
+	}
+
+	switch permission.Result {
+	case common.PermissionDeny:
+		return fmt.Sprintf("MCP tool call denied: %s", permission.Message), nil
+	case common.PermissionRequireApproval:
+		approvalPrompt := fmt.Sprintf("Allow calling the `%s` tool of the `%s` MCP server with the following arguments?\n\n```json\n%s\n```",
+			serverTool.Tool.Name, serverTool.Server, prettyJson(arguments))
+		userResponse, err := GetUserApproval(dCtx, "mcp_tool_call", approvalPrompt, map[string]any{
+			"server":    serverTool.Server,
+			"tool":      serverTool.Tool.Name,
+			"arguments": arguments,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get user approval: %v", err)
+		}
+		if userResponse == nil || userResponse.Approved == nil || !*userResponse.Approved {
+			content := ""
+			if userResponse != nil {
+				content = userResponse.Content
+			}
+			return "MCP tool call was not approved by user. They said:\n\n" + content, nil
+		}
+	}
+
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	var ma *mcp.McpActivities
+	var result mcp.CallToolResult
+	err = workflow.ExecuteActivity(dCtx, ma.CallMcpTool, mcp.CallMcpToolInput{
+		Server:       serverTool.Server,
+		Config:       config,
+		FlowId:       workflow.GetInfo(dCtx).WorkflowExecution.ID,
+		EnvContainer: *dCtx.EnvContainer,
+		Tool:         serverTool.Tool.Name,
+		Arguments:    json.RawMessage(arguments),
+	}).Get(dCtx, &result)
+	if err != nil {
+		return "", err
+	}
+
+	text := result.Text()
+	if result.IsError {
+		return "", fmt.Errorf("MCP tool %s failed: %s", serverTool.Tool.Name, text)
+	}
+	return text, nil
+}
+
+func prettyJson(arguments string) string {
+	var value any
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		return arguments
+	}
+	pretty, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return arguments
+	}
+	return string(pretty)
+}
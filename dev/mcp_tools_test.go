@@ -0,0 +1,37 @@
+package dev
+
+import (
+	"encoding/json"
+	"testing"
+
+	"sidekick/mcp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMcpToolToLlmTool(t *testing.T) {
+	t.Parallel()
+
+	tool := mcpToolToLlmTool(mcp.ServerTool{
+		Server:      "github",
+		ExposedName: "mcp_github_create_issue",
+		Tool: mcp.Tool{
+			Name:        "create_issue",
+			Description: "Creates an issue",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"title":{"type":"string"}},"required":["title"]}`),
+		},
+	})
+	assert.Equal(t, "mcp_github_create_issue", tool.Name)
+	assert.Equal(t, "[github MCP server] Creates an issue", tool.Description)
+	require.NotNil(t, tool.Parameters)
+	assert.Equal(t, "object", tool.Parameters.Type)
+	assert.Equal(t, []string{"title"}, tool.Parameters.Required)
+	_, ok := tool.Parameters.Properties.Get("title")
+	assert.True(t, ok)
+
+	// tools without an input schema take no arguments
+	tool = mcpToolToLlmTool(mcp.ServerTool{Server: "s", ExposedName: "mcp_s_ping", Tool: mcp.Tool{Name: "ping"}})
+	assert.Equal(t, "object", tool.Parameters.Type)
+	assert.Equal(t, "[s MCP server]", tool.Description)
+}
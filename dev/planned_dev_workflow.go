@@ -58,6 +58,7 @@ func PlannedDevWorkflow(ctx workflow.Context, input PlannedDevInput) (planExec D
 		signalWorkflowFailureOrCancel(ctx)
 		return DevPlanExecution{}, fmt.Errorf("failed to setup dev context: %v", err)
 	}
+	defer teardownEnv(dCtx)
 	defer handleFlowCancel(dCtx)
 	defer stopActiveDevRun(dCtx)
 	defer func() {
@@ -114,7 +115,7 @@ func PlannedDevWorkflow(ctx workflow.Context, input PlannedDevInput) (planExec D
 
 	// Handle merge if using worktree and workflow version is new enough
 	v := workflow.GetVersion(ctx, "git-worktree-merge", workflow.DefaultVersion, 1)
-	if input.EnvType == env.EnvTypeLocalGitWorktree && v == 1 {
+	if input.EnvType.UsesGitWorktree() && v == 1 {
 		err := reviewAndResolve(dCtx, MergeWithReviewParams{
 			CommitRequired: false, // planned dev flow writes commits already
 			Requirements: input.Requirements + `
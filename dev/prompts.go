@@ -58,6 +58,7 @@ var promptsFS embed.FS
 var GeneralFeedback = panicParseMustache(promptsFS, "general_feedback")
 var AuthorEditBlockFeedback = panicParseMustache(promptsFS, "author_edit_block/feedback")
 var RecordPlanInitial = panicParseMustache(promptsFS, "record_plan/initial")
+var TaskBreakdownInitial = panicParseMustache(promptsFS, "task_breakdown/initial")
 var AuthorEditBlockInitial = panicParseMustache(promptsFS, "author_edit_block/initial")
 var AuthorEditBlockInitialWithPlan = panicParseMustache(promptsFS, "author_edit_block/initial_with_plan")
 var CodeContextInitial = panicParseMustache(promptsFS, "code_context/initial")
@@ -0,0 +1,176 @@
+package dev
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sidekick/coding/forge"
+	"sidekick/coding/git"
+	"sidekick/common"
+	"sidekick/domain"
+	"sidekick/flow_action"
+	"sidekick/llm"
+	"sidekick/persisted_ai"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"go.temporal.io/sdk/workflow"
+)
+
+type SubmitPullRequestParams struct {
+	Title       string `json:"title" jsonschema:"description=The pull request title: a single imperative line of at most 72 characters"`
+	Description string `json:"description" jsonschema:"description=The pull request description in markdown"`
+}
+
+var describePullRequestTool = llm.Tool{
+	Name:        "submit_pull_request",
+	Description: "Submit the title and description of a pull request for the given changes.",
+	Parameters:  (&jsonschema.Reflector{DoNotReference: true}).Reflect(&SubmitPullRequestParams{}),
+}
+
+var describePullRequestPrompt = panicParseMustache(promptsFS, "pull_request/describe")
+
+// openPullRequestForWorktree commits any pending changes, pushes the worktree
+// branch and opens a pull request from it into the approved target branch. The
+// task is then put in review with the pull request's URL. Unlike a local
+// merge, the worktree is kept, so follow-up changes can be pushed to the same
+// branch.
+func openPullRequestForWorktree(dCtx DevContext, params MergeWithReviewParams, mergeInfo MergeApprovalResponse, gitDiff string, currentTreeHash string) (string, MergeApprovalResponse, string, error) {
+	if gitDiff == "" {
+		var err error
+		gitDiff, err = GetGitDiff(dCtx, mergeInfo.TargetBranch, false)
+		if err != nil {
+			return "", MergeApprovalResponse{}, "", fmt.Errorf("failed to get git diff: %w", err)
+		}
+	}
+
+	commitMessage := commitMessageFromRequirements(params.Requirements)
+	title, body := describePullRequest(dCtx, params.Requirements, gitDiff, commitMessage)
+
+	actionCtx := dCtx.NewActionContext("open_pull_request")
+	actionCtx.ActionParams = map[string]interface{}{
+		"sourceBranch": dCtx.Worktree.Name,
+		"targetBranch": mergeInfo.TargetBranch,
+		"title":        title,
+	}
+	pr, err := Track(actionCtx, func(trackedCtx DevActionContext, flowAction *domain.FlowAction) (forge.PullRequest, error) {
+		var pr forge.PullRequest
+		if params.CommitRequired {
+			err := workflow.ExecuteActivity(trackedCtx, git.GitCommitActivity, trackedCtx.EnvContainer, git.GitCommitParams{
+				CommitMessage:  commitMessage,
+				CommitterName:  trackedCtx.GlobalState.GetStringValue("committerName"),
+				CommitterEmail: trackedCtx.GlobalState.GetStringValue("committerEmail"),
+			}).Get(trackedCtx, nil)
+			if err != nil {
+				if strings.Contains(err.Error(), "nothing to commit") {
+					workflow.GetLogger(trackedCtx).Warn("nothing to commit before opening pull request")
+				} else {
+					return pr, fmt.Errorf("failed to commit changes: %w", err)
+				}
+			}
+		}
+
+		err := flow_action.PerformWithUserRetry(trackedCtx.FlowActionContext(), forge.OpenPullRequestActivity, &pr, forge.OpenPullRequestActivityInput{
+			EnvContainer: *trackedCtx.EnvContainer,
+			Secrets:      *trackedCtx.Secrets,
+			Config:       trackedCtx.RepoConfig.PullRequest,
+			SourceBranch: trackedCtx.Worktree.Name,
+			TargetBranch: mergeInfo.TargetBranch,
+			Title:        title,
+			Body:         body,
+		})
+		if err != nil {
+			return pr, fmt.Errorf("failed to open pull request: %w", err)
+		}
+		return pr, nil
+	})
+	if err != nil {
+		return "", MergeApprovalResponse{}, "", err
+	}
+
+	var ima *DevAgentManagerActivities // use a nil struct pointer to call activities that are part of a structure
+	err = workflow.ExecuteActivity(dCtx, ima.UpdateTask, dCtx.WorkspaceId, workflow.GetInfo(dCtx).WorkflowExecution.ID, TaskUpdate{
+		Status:         domain.TaskStatusInReview,
+		AgentType:      domain.AgentTypeHuman,
+		PullRequestURL: pr.URL,
+	}).Get(dCtx, nil)
+	if err != nil {
+		// the pull request is open regardless, so don't fail the flow
+		workflow.GetLogger(dCtx).Error("Failed to record pull request on task", "error", err)
+	}
+
+	return gitDiff, mergeInfo, currentTreeHash, nil
+}
+
+// describePullRequest generates a pull request title and description from the
+// requirements and diff, falling back to the commit message and requirements
+// if generation fails.
+func describePullRequest(dCtx DevContext, requirements, gitDiff, commitMessage string) (string, string) {
+	fallbackTitle := strings.SplitN(commitMessage, "\n", 2)[0]
+	fallbackBody := strings.TrimSpace(requirements)
+
+	var summarizedDiff string
+	err := workflow.ExecuteActivity(dCtx, SummarizeDiffActivity, SummarizeDiffActivityInput{
+		GitDiff:                gitDiff,
+		ReviewFeedback:         requirements,
+		EnvContainer:           *dCtx.EnvContainer,
+		ModelConfig:            dCtx.ExecContext.GetEmbeddingModelConfig("diff_summarize"),
+		SecretManagerContainer: *dCtx.Secrets,
+	}).Get(dCtx, &summarizedDiff)
+	if err != nil {
+		workflow.GetLogger(dCtx).Warn("Failed to summarize diff for pull request", "error", err)
+		summarizedDiff = gitDiff
+		if len(summarizedDiff) > DiffSummarizeMaxChars {
+			summarizedDiff = summarizedDiff[:DiffSummarizeMaxChars]
+		}
+	}
+
+	description, err := generatePullRequestDescription(dCtx.ExecContext, requirements, summarizedDiff)
+	if err != nil {
+		workflow.GetLogger(dCtx).Warn("Failed to generate pull request description", "error", err)
+		return fallbackTitle, fallbackBody
+	}
+	title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(description.Title), "\n", 2)[0])
+	if title == "" {
+		title = fallbackTitle
+	}
+	body := strings.TrimSpace(description.Description)
+	if body == "" {
+		body = fallbackBody
+	}
+	return title, body
+}
+
+func generatePullRequestDescription(eCtx flow_action.ExecContext, requirements, diff string) (SubmitPullRequestParams, error) {
+	chatHistory := NewVersionedChatHistory(eCtx, eCtx.WorkspaceId)
+	if err := AppendChatHistory(eCtx, chatHistory, llm.ChatMessage{
+		Role: llm.ChatMessageRoleUser,
+		Content: RenderPrompt(describePullRequestPrompt, map[string]any{
+			"requirements": requirements,
+			"diff":         diff,
+		}),
+	}); err != nil {
+		return SubmitPullRequestParams{}, err
+	}
+
+	modelConfig := eCtx.GetModelConfig(common.SummarizationKey, 0, "small")
+	actionCtx := eCtx.NewActionContext("generate.pull_request_description")
+	toolNameMapping, err := resolveStreamToolNameMapping(modelConfig, *actionCtx.Secrets)
+	if err != nil {
+		return SubmitPullRequestParams{}, fmt.Errorf("failed to resolve tool name mapping: %v", err)
+	}
+	msgResponse, err := persisted_ai.ForceToolCallWithTrackOptionsV2(actionCtx, flow_action.TrackOptions{FailuresOnly: true}, modelConfig, chatHistory, toolNameMapping, &describePullRequestTool)
+	if err != nil {
+		return SubmitPullRequestParams{}, fmt.Errorf("failed to force tool call: %v", err)
+	}
+
+	toolCalls := msgResponse.GetMessage().GetToolCalls()
+	if len(toolCalls) == 0 {
+		return SubmitPullRequestParams{}, errors.New("no tool call in response")
+	}
+	var description SubmitPullRequestParams
+	if err := json.Unmarshal([]byte(llm.RepairJson(toolCalls[0].Arguments)), &description); err != nil {
+		return SubmitPullRequestParams{}, fmt.Errorf("%w: %v", llm.ErrToolCallUnmarshal, err)
+	}
+	return description, nil
+}
@@ -0,0 +1,208 @@
+package dev
+
+import (
+	"fmt"
+	"sidekick/coding/diffanalysis"
+	"sidekick/utils"
+	"strings"
+)
+
+// reviewCommentHunkContext is the number of lines of the reviewed diff shown
+// before and after a review comment's lines
+const reviewCommentHunkContext = 3
+
+// ReviewCommentSide tells which version of a file a review comment's lines
+// are in
+type ReviewCommentSide string
+
+const (
+	ReviewCommentSideNew ReviewCommentSide = "new"
+	// ReviewCommentSideOld is for comments on removed lines
+	ReviewCommentSideOld ReviewCommentSide = "old"
+)
+
+// ReviewComment is a comment the user left on a range of lines in the diff
+// they reviewed when approving a merge. Comments are carried over to later
+// reviews until the user marks them resolved.
+type ReviewComment struct {
+	Id        string            `json:"id"`
+	Path      string            `json:"path"`
+	Side      ReviewCommentSide `json:"side,omitempty"`
+	StartLine int               `json:"startLine"`
+	EndLine   int               `json:"endLine"`
+	Body      string            `json:"body"`
+	Resolved  bool              `json:"resolved,omitempty"`
+	// Round is the review the comment was left in, starting at 1
+	Round int `json:"round,omitempty"`
+	// DiffHunk is the part of the reviewed diff the comment was left on
+	DiffHunk string `json:"diffHunk,omitempty"`
+}
+
+// mergeReviewComments combines the comments carried over from earlier
+// reviews with the ones in the user's response, which may resolve earlier
+// comments or add new ones. New comments get their hunk from the first of the
+// reviewed diffs that includes their lines, as the user may have reviewed
+// either the full diff or just the changes since the last review.
+func mergeReviewComments(previous []ReviewComment, params map[string]interface{}, reviewedDiffs ...string) []ReviewComment {
+	var responded []ReviewComment
+	if params != nil && params["reviewComments"] != nil {
+		utils.Transcode(params["reviewComments"], &responded)
+	}
+
+	round := 1
+	merged := make([]ReviewComment, 0, len(previous)+len(responded))
+	indexes := make(map[string]int, len(previous))
+	for _, comment := range previous {
+		round = max(round, comment.Round+1)
+		indexes[comment.Id] = len(merged)
+		merged = append(merged, comment)
+	}
+
+	var parsedDiffs [][]diffanalysis.FileDiff
+	for _, comment := range responded {
+		if i, ok := indexes[comment.Id]; ok && comment.Id != "" {
+			// only the resolved state of earlier comments can change
+			merged[i].Resolved = comment.Resolved
+			continue
+		}
+		if strings.TrimSpace(comment.Body) == "" || comment.Path == "" || comment.StartLine <= 0 {
+			continue
+		}
+		if comment.Side == "" {
+			comment.Side = ReviewCommentSideNew
+		}
+		if comment.EndLine < comment.StartLine {
+			comment.EndLine = comment.StartLine
+		}
+		if comment.Id == "" {
+			comment.Id = fmt.Sprintf("%d.%d", round, len(merged)+1)
+		}
+		comment.Round = round
+		comment.Resolved = false
+		if parsedDiffs == nil {
+			for _, diff := range reviewedDiffs {
+				// a diff that fails to parse just leaves the comments without hunks
+				fileDiffs, _ := diffanalysis.ParseUnifiedDiff(diff)
+				parsedDiffs = append(parsedDiffs, fileDiffs)
+			}
+		}
+		for _, fileDiffs := range parsedDiffs {
+			if comment.DiffHunk = reviewCommentHunk(fileDiffs, comment); comment.DiffHunk != "" {
+				break
+			}
+		}
+		merged = append(merged, comment)
+	}
+	return merged
+}
+
+// reviewCommentHunk returns the lines of the diff around the comment's lines,
+// with a hunk header matching them
+func reviewCommentHunk(fileDiffs []diffanalysis.FileDiff, comment ReviewComment) string {
+	for _, fileDiff := range fileDiffs {
+		path := fileDiff.NewPath
+		if comment.Side == ReviewCommentSideOld || fileDiff.IsDeleted {
+			path = fileDiff.OldPath
+		}
+		if path != comment.Path {
+			continue
+		}
+
+		var b strings.Builder
+		for _, hunk := range fileDiff.Hunks {
+			first, last := -1, -1
+			for i, line := range hunk.Lines {
+				lineNumber := line.NewLine
+				if comment.Side == ReviewCommentSideOld {
+					lineNumber = line.OldLine
+				}
+				if lineNumber == 0 {
+					continue
+				}
+				if lineNumber >= comment.StartLine-reviewCommentHunkContext && lineNumber <= comment.EndLine+reviewCommentHunkContext {
+					if first == -1 {
+						first = i
+					}
+					last = i
+				}
+			}
+			if first == -1 {
+				continue
+			}
+			b.WriteString(formatHunkLines(hunk.Lines[first : last+1]))
+		}
+		return b.String()
+	}
+	return ""
+}
+
+func formatHunkLines(lines []diffanalysis.DiffLine) string {
+	oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+	var body strings.Builder
+	for _, line := range lines {
+		prefix := " "
+		switch line.Type {
+		case diffanalysis.LineAdded:
+			prefix = "+"
+		case diffanalysis.LineRemoved:
+			prefix = "-"
+		}
+		if line.OldLine > 0 {
+			if oldStart == 0 {
+				oldStart = line.OldLine
+			}
+			oldCount++
+		}
+		if line.NewLine > 0 {
+			if newStart == 0 {
+				newStart = line.NewLine
+			}
+			newCount++
+		}
+		body.WriteString(prefix + line.Content + "\n")
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", oldStart, oldCount, newStart, newCount, body.String())
+}
+
+// formatReviewFeedback combines the review message with the unresolved
+// review comments, each shown with the part of the diff it was left on
+func formatReviewFeedback(message string, comments []ReviewComment) string {
+	var unresolved []ReviewComment
+	for _, comment := range comments {
+		if !comment.Resolved {
+			unresolved = append(unresolved, comment)
+		}
+	}
+	if len(unresolved) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	if strings.TrimSpace(message) != "" {
+		b.WriteString(message)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Address these review comments on specific lines of the diff:\n")
+	for i, comment := range unresolved {
+		lines := fmt.Sprintf("line %d", comment.StartLine)
+		if comment.EndLine > comment.StartLine {
+			lines = fmt.Sprintf("lines %d-%d", comment.StartLine, comment.EndLine)
+		}
+		if comment.Side == ReviewCommentSideOld {
+			lines = "removed " + lines
+		}
+		b.WriteString(fmt.Sprintf("\n%d. %s, %s", i+1, comment.Path, lines))
+		if comment.Round > 0 {
+			b.WriteString(fmt.Sprintf(" (from review %d)", comment.Round))
+		}
+		b.WriteString(":\n")
+		if comment.DiffHunk != "" {
+			b.WriteString("```diff\n")
+			b.WriteString(comment.DiffHunk)
+			b.WriteString("```\n")
+		}
+		b.WriteString(strings.TrimSpace(comment.Body))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reviewedDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,12 +1,13 @@
+ package main
+ 
+ import "fmt"
+ 
+ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
++	fmt.Println("world")
+ }
+ 
+ func a() {}
+ 
+ func b() {}
+`
+
+func TestMergeReviewComments(t *testing.T) {
+	t.Parallel()
+
+	params := map[string]interface{}{
+		"reviewComments": []interface{}{
+			map[string]interface{}{"path": "main.go", "startLine": 7, "endLine": 7, "body": "Print both in one call"},
+			map[string]interface{}{"path": "main.go", "side": "old", "startLine": 6, "body": "Keep the greeting"},
+			map[string]interface{}{"path": "main.go", "startLine": 3, "body": "  "},
+		},
+	}
+	comments := mergeReviewComments(nil, params, reviewedDiff)
+	require.Len(t, comments, 2)
+
+	assert.Equal(t, "1.1", comments[0].Id)
+	assert.Equal(t, ReviewCommentSideNew, comments[0].Side)
+	assert.Equal(t, 7, comments[0].EndLine)
+	assert.Equal(t, 1, comments[0].Round)
+	assert.Equal(t, "@@ -4,6 +4,7 @@\n \n func main() {\n-\tfmt.Println(\"hi\")\n+\tfmt.Println(\"hello\")\n+\tfmt.Println(\"world\")\n }\n \n func a() {}\n", comments[0].DiffHunk)
+
+	assert.Equal(t, "1.2", comments[1].Id)
+	assert.Equal(t, ReviewCommentSideOld, comments[1].Side)
+	assert.Equal(t, 6, comments[1].EndLine, "a single line comment ends where it starts")
+	assert.Contains(t, comments[1].DiffHunk, "-\tfmt.Println(\"hi\")")
+
+	// the next review resolves the first comment and adds another
+	params = map[string]interface{}{
+		"reviewComments": []interface{}{
+			map[string]interface{}{"id": "1.1", "path": "main.go", "startLine": 7, "body": "edited", "resolved": true},
+			map[string]interface{}{"path": "other.go", "startLine": 1, "endLine": 2, "body": "Not in the diff"},
+		},
+	}
+	comments = mergeReviewComments(comments, params, reviewedDiff)
+	require.Len(t, comments, 3)
+	assert.True(t, comments[0].Resolved)
+	assert.Equal(t, "Print both in one call", comments[0].Body, "only the resolved state of earlier comments changes")
+	assert.False(t, comments[1].Resolved)
+	assert.Equal(t, 2, comments[2].Round)
+	assert.Equal(t, "2.3", comments[2].Id)
+	assert.Empty(t, comments[2].DiffHunk)
+
+	// without a response, earlier comments are kept as they are
+	assert.Equal(t, comments, mergeReviewComments(comments, nil, reviewedDiff))
+}
+
+func TestMergeReviewComments_FallsBackToDiffSinceLastReview(t *testing.T) {
+	t.Parallel()
+
+	sinceLastReview := `diff --git a/util.go b/util.go
+--- a/util.go
++++ b/util.go
+@@ -1,2 +1,3 @@
+ package main
++
++func unused() {}
+`
+	params := map[string]interface{}{
+		"reviewComments": []interface{}{
+			map[string]interface{}{"path": "util.go", "startLine": 3, "body": "Remove this"},
+		},
+	}
+	comments := mergeReviewComments(nil, params, reviewedDiff, sinceLastReview)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "@@ -1,1 +1,3 @@\n package main\n+\n+func unused() {}\n", comments[0].DiffHunk)
+}
+
+func TestMergeApprovalResponseFeedback(t *testing.T) {
+	t.Parallel()
+
+	response := MergeApprovalResponse{Message: "Almost there"}
+	assert.Equal(t, "Almost there", response.Feedback())
+
+	response.ReviewComments = []ReviewComment{
+		{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 7, EndLine: 8, Body: "Print both in one call", Round: 1, DiffHunk: "@@ -6,1 +6,2 @@\n+a\n+b\n"},
+		{Path: "main.go", Side: ReviewCommentSideOld, StartLine: 6, EndLine: 6, Body: "Keep the greeting", Round: 2},
+		{Path: "main.go", StartLine: 1, EndLine: 1, Body: "Resolved already", Resolved: true},
+	}
+	feedback := response.Feedback()
+	assert.Equal(t, "Almost there\n\nAddress these review comments on specific lines of the diff:\n"+
+		"\n1. main.go, lines 7-8 (from review 1):\n```diff\n@@ -6,1 +6,2 @@\n+a\n+b\n```\nPrint both in one call\n"+
+		"\n2. main.go, removed line 6 (from review 2):\nKeep the greeting\n", feedback)
+
+	response.Message = ""
+	assert.NotContains(t, response.Feedback(), "Almost there")
+	assert.Contains(t, response.Feedback(), "Address these review comments")
+}
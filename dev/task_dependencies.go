@@ -0,0 +1,157 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sidekick/domain"
+	"sidekick/srv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FlowOptionStartFromBlockerBranch is a flow option that, when true, starts a
+// task blocked by other tasks from the branch the most recently merged of its
+// blockers was merged into, rather than from its own startBranch.
+const FlowOptionStartFromBlockerBranch = "startFromBlockerBranch"
+
+// GetIncompleteBlockers returns the tasks blocking the given task that are not
+// complete yet. Blocking tasks that no longer exist are ignored.
+func GetIncompleteBlockers(ctx context.Context, storage srv.Storage, task domain.Task) ([]domain.Task, error) {
+	var incomplete []domain.Task
+	for _, blockerId := range task.LinkedTaskIds(domain.LinkTypeBlockedBy) {
+		blocker, err := storage.GetTask(ctx, task.WorkspaceId, blockerId)
+		if err != nil {
+			if errors.Is(err, srv.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get blocking task %s: %w", blockerId, err)
+		}
+		if blocker.Status != domain.TaskStatusComplete {
+			incomplete = append(incomplete, blocker)
+		}
+	}
+	return incomplete, nil
+}
+
+// IsWaitingOnBlockers reports whether the task was held back from starting
+// because of its blockers, as opposed to being blocked on a user's input while
+// its flow runs.
+func IsWaitingOnBlockers(ctx context.Context, storage srv.Storage, task domain.Task) (bool, error) {
+	if task.Status != domain.TaskStatusBlocked || len(task.LinkedTaskIds(domain.LinkTypeBlockedBy)) == 0 {
+		return false, nil
+	}
+	flows, err := storage.GetFlowsForTask(ctx, task.WorkspaceId, task.Id)
+	if err != nil {
+		return false, fmt.Errorf("failed to get flows for task %s: %w", task.Id, err)
+	}
+	return len(flows) == 0, nil
+}
+
+// ResolveDependentTasks updates the tasks waiting on the given finished task.
+// When it completed, the waiting tasks that have no other incomplete blockers
+// are returned, to be started by the caller. When it failed or was canceled,
+// the waiting tasks can no longer start, so they are marked as failed or
+// canceled too, cascading to the tasks waiting on them in turn.
+func ResolveDependentTasks(ctx context.Context, storage srv.Storage, workspaceId, taskId string) ([]domain.Task, error) {
+	finished, err := storage.GetTask(ctx, workspaceId, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task %s: %w", taskId, err)
+	}
+
+	var ready []domain.Task
+	for _, dependentId := range finished.LinkedTaskIds(domain.LinkTypeBlocks) {
+		dependent, err := storage.GetTask(ctx, workspaceId, dependentId)
+		if err != nil {
+			if errors.Is(err, srv.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get dependent task %s: %w", dependentId, err)
+		}
+		waiting, err := IsWaitingOnBlockers(ctx, storage, dependent)
+		if err != nil {
+			return nil, err
+		}
+		if !waiting {
+			continue
+		}
+
+		switch finished.Status {
+		case domain.TaskStatusComplete:
+			incomplete, err := GetIncompleteBlockers(ctx, storage, dependent)
+			if err != nil {
+				return nil, err
+			}
+			if len(incomplete) > 0 {
+				continue
+			}
+			if dependent.FlowOptions[FlowOptionStartFromBlockerBranch] == true {
+				if err := setStartBranchFromBlockers(ctx, storage, &dependent); err != nil {
+					return nil, err
+				}
+			}
+			ready = append(ready, dependent)
+
+		case domain.TaskStatusFailed, domain.TaskStatusCanceled:
+			log.Info().Str("taskId", dependent.Id).Str("blockerId", finished.Id).Str("status", string(finished.Status)).Msg("Cascading blocking task status to waiting task")
+			dependent.Status = finished.Status
+			dependent.AgentType = domain.AgentTypeNone
+			dependent.Updated = time.Now()
+			if err := storage.PersistTask(ctx, dependent); err != nil {
+				return nil, fmt.Errorf("failed to update dependent task %s: %w", dependent.Id, err)
+			}
+			if _, err := ResolveDependentTasks(ctx, storage, workspaceId, dependent.Id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ready, nil
+}
+
+// setStartBranchFromBlockers sets the task's startBranch flow option to the
+// target branch of the most recent merge performed by its blockers' flows, if
+// any, and persists the task.
+func setStartBranchFromBlockers(ctx context.Context, storage srv.Storage, task *domain.Task) error {
+	var latestMerge *domain.FlowAction
+	for _, blockerId := range task.LinkedTaskIds(domain.LinkTypeBlockedBy) {
+		flows, err := storage.GetFlowsForTask(ctx, task.WorkspaceId, blockerId)
+		if err != nil {
+			return fmt.Errorf("failed to get flows for blocking task %s: %w", blockerId, err)
+		}
+		for _, flow := range flows {
+			flowActions, err := storage.GetFlowActions(ctx, task.WorkspaceId, flow.Id)
+			if err != nil {
+				return fmt.Errorf("failed to get flow actions for flow %s: %w", flow.Id, err)
+			}
+			for i := range flowActions {
+				flowAction := flowActions[i]
+				if flowAction.ActionType != "merge" || flowAction.ActionStatus != domain.ActionStatusComplete {
+					continue
+				}
+				if _, ok := flowAction.ActionParams["targetBranch"].(string); !ok {
+					continue
+				}
+				if latestMerge == nil || flowAction.Updated.After(latestMerge.Updated) {
+					latestMerge = &flowAction
+				}
+			}
+		}
+	}
+	if latestMerge == nil {
+		return nil
+	}
+
+	flowOptions := make(map[string]interface{}, len(task.FlowOptions)+1)
+	for key, value := range task.FlowOptions {
+		flowOptions[key] = value
+	}
+	flowOptions["startBranch"] = latestMerge.ActionParams["targetBranch"]
+	task.FlowOptions = flowOptions
+	task.Updated = time.Now()
+	if err := storage.PersistTask(ctx, *task); err != nil {
+		return fmt.Errorf("failed to update start branch for task %s: %w", task.Id, err)
+	}
+	return nil
+}
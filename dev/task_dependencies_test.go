@@ -0,0 +1,133 @@
+package dev
+
+import (
+	"context"
+	"sidekick/domain"
+	"sidekick/srv"
+	"sidekick/srv/sqlite"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func persistTasks(t *testing.T, storage srv.Storage, tasks ...domain.Task) {
+	t.Helper()
+	for _, task := range tasks {
+		require.NoError(t, storage.PersistTask(context.Background(), task))
+	}
+}
+
+func TestResolveDependentTasks_Complete(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "task_dependencies_test")
+	workspaceId := "ws_1"
+
+	// a and b both block c, a blocks d which starts from a's merged branch
+	a := domain.Task{WorkspaceId: workspaceId, Id: "task_a", Status: domain.TaskStatusComplete, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_c"},
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_d"},
+	}}
+	b := domain.Task{WorkspaceId: workspaceId, Id: "task_b", Status: domain.TaskStatusInProgress, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_c"},
+	}}
+	c := domain.Task{WorkspaceId: workspaceId, Id: "task_c", Status: domain.TaskStatusBlocked, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_a"},
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_b"},
+	}}
+	d := domain.Task{WorkspaceId: workspaceId, Id: "task_d", Status: domain.TaskStatusBlocked, FlowOptions: map[string]interface{}{
+		"envType":                        "local_git_worktree",
+		"startBranch":                    "main",
+		FlowOptionStartFromBlockerBranch: true,
+	}, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_a"},
+	}}
+	persistTasks(t, storage, a, b, c, d)
+
+	flow := domain.Flow{WorkspaceId: workspaceId, Id: "flow_a", ParentId: a.Id, Type: domain.FlowTypeBasicDev, Status: "completed"}
+	require.NoError(t, storage.PersistFlow(ctx, flow))
+	require.NoError(t, storage.PersistFlowAction(ctx, domain.FlowAction{
+		WorkspaceId:  workspaceId,
+		Id:           "fa_merge",
+		FlowId:       flow.Id,
+		ActionType:   "merge",
+		ActionStatus: domain.ActionStatusComplete,
+		ActionParams: map[string]interface{}{"targetBranch": "feature"},
+		Created:      time.Now(),
+		Updated:      time.Now(),
+	}))
+
+	ready, err := ResolveDependentTasks(ctx, storage, workspaceId, a.Id)
+	require.NoError(t, err)
+	require.Len(t, ready, 1)
+	assert.Equal(t, d.Id, ready[0].Id)
+	assert.Equal(t, "feature", ready[0].FlowOptions["startBranch"])
+
+	persisted, err := storage.GetTask(ctx, workspaceId, d.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", persisted.FlowOptions["startBranch"])
+
+	// c becomes ready once b completes too
+	b.Status = domain.TaskStatusComplete
+	persistTasks(t, storage, b)
+	ready, err = ResolveDependentTasks(ctx, storage, workspaceId, b.Id)
+	require.NoError(t, err)
+	require.Len(t, ready, 1)
+	assert.Equal(t, c.Id, ready[0].Id)
+	assert.Equal(t, domain.TaskStatusBlocked, ready[0].Status)
+}
+
+func TestResolveDependentTasks_CascadesFailure(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "task_dependencies_test")
+	workspaceId := "ws_1"
+
+	// a blocks b blocks c
+	a := domain.Task{WorkspaceId: workspaceId, Id: "task_a", Status: domain.TaskStatusFailed, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_b"},
+	}}
+	b := domain.Task{WorkspaceId: workspaceId, Id: "task_b", Status: domain.TaskStatusBlocked, AgentType: domain.AgentTypeLLM, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_a"},
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_c"},
+	}}
+	c := domain.Task{WorkspaceId: workspaceId, Id: "task_c", Status: domain.TaskStatusBlocked, AgentType: domain.AgentTypeLLM, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_b"},
+	}}
+	persistTasks(t, storage, a, b, c)
+
+	ready, err := ResolveDependentTasks(ctx, storage, workspaceId, a.Id)
+	require.NoError(t, err)
+	assert.Empty(t, ready)
+
+	for _, id := range []string{b.Id, c.Id} {
+		task, err := storage.GetTask(ctx, workspaceId, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TaskStatusFailed, task.Status)
+		assert.Equal(t, domain.AgentTypeNone, task.AgentType)
+	}
+}
+
+func TestResolveDependentTasks_IgnoresTasksWithFlows(t *testing.T) {
+	ctx := context.Background()
+	storage := sqlite.NewTestSqliteStorage(t, "task_dependencies_test")
+	workspaceId := "ws_1"
+
+	// b is blocked on user input in its own flow, not on a
+	a := domain.Task{WorkspaceId: workspaceId, Id: "task_a", Status: domain.TaskStatusCanceled, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlocks, TargetTaskId: "task_b"},
+	}}
+	b := domain.Task{WorkspaceId: workspaceId, Id: "task_b", Status: domain.TaskStatusBlocked, Links: []domain.TaskLink{
+		{LinkType: domain.LinkTypeBlockedBy, TargetTaskId: "task_a"},
+	}}
+	persistTasks(t, storage, a, b)
+	require.NoError(t, storage.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: "flow_b", ParentId: b.Id, Type: domain.FlowTypeBasicDev}))
+
+	ready, err := ResolveDependentTasks(ctx, storage, workspaceId, a.Id)
+	require.NoError(t, err)
+	assert.Empty(t, ready)
+
+	task, err := storage.GetTask(ctx, workspaceId, b.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatusBlocked, task.Status)
+}
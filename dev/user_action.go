@@ -108,6 +108,7 @@ func handleDevRunStart(dCtx DevContext) {
 			WorkspaceId:  dCtx.WorkspaceId,
 			FlowId:       flowInfo.WorkflowExecution.ID,
 			WorktreeDir:  dCtx.EnvContainer.Env.GetWorkingDirectory(),
+			EnvContainer: dCtx.EnvContainer,
 			SourceBranch: dCtx.Worktree.Name,
 			BaseBranch:   targetBranch,
 			TargetBranch: targetBranch,
@@ -177,6 +178,7 @@ func handleDevRunStop(dCtx DevContext) {
 			WorkspaceId:  dCtx.WorkspaceId,
 			FlowId:       flowInfo.WorkflowExecution.ID,
 			WorktreeDir:  dCtx.EnvContainer.Env.GetWorkingDirectory(),
+			EnvContainer: dCtx.EnvContainer,
 			SourceBranch: dCtx.Worktree.Name,
 			BaseBranch:   targetBranch,
 			TargetBranch: targetBranch,
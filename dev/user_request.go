@@ -41,6 +41,9 @@ type MergeStrategy string
 const (
 	MergeStrategySquash MergeStrategy = "squash"
 	MergeStrategyMerge  MergeStrategy = "merge"
+	// MergeStrategyPullRequest pushes the branch and opens a pull request
+	// instead of merging locally
+	MergeStrategyPullRequest MergeStrategy = "pull_request"
 )
 
 // MergeApprovalParams contains parameters specific to merge approval requests
@@ -50,13 +53,24 @@ type MergeApprovalParams struct {
 	Diff                 string        `json:"diff"`
 	DiffSinceLastReview  string        `json:"diffSinceLastReview,omitempty"`
 	DefaultMergeStrategy MergeStrategy `json:"defaultMergeStrategy,omitempty"` // default merge strategy, defaults to squash
+	// ReviewComments are the line comments left in earlier reviews, which the
+	// user can mark resolved
+	ReviewComments []ReviewComment `json:"reviewComments,omitempty"`
 }
 
 type MergeApprovalResponse struct {
 	Approved      bool          `json:"approved"`
 	TargetBranch  string        `json:"targetBranch"`  // actual target branch selected by the user
 	Message       string        `json:"message"`       // feedback message when not approved
-	MergeStrategy MergeStrategy `json:"mergeStrategy"` // selected merge strategy (squash or merge)
+	MergeStrategy MergeStrategy `json:"mergeStrategy"` // selected merge strategy (squash, merge or pull_request)
+	// ReviewComments are the line comments from this and earlier reviews
+	ReviewComments []ReviewComment `json:"reviewComments,omitempty"`
+}
+
+// Feedback returns the review message along with the unresolved line
+// comments, for addressing a rejected review
+func (r MergeApprovalResponse) Feedback() string {
+	return formatReviewFeedback(r.Message, r.ReviewComments)
 }
 
 func GetUserMergeApproval(
@@ -200,6 +214,14 @@ func GetUserMergeApproval(
 
 			// if Approved is non-nil, this isn't just a branch switch update, we're done either approving or rejecting
 			if currentResponse.Approved != nil {
+				// the line comments are stored with the response on the flow
+				// action, including earlier ones and the hunks they're on
+				if comments := mergeReviewComments(mergeApprovalInfo.ReviewComments, currentResponse.Params, mergeApprovalInfo.Diff, mergeApprovalInfo.DiffSinceLastReview); len(comments) > 0 {
+					if currentResponse.Params == nil {
+						currentResponse.Params = map[string]interface{}{}
+					}
+					currentResponse.Params["reviewComments"] = comments
+				}
 				return currentResponse, nil
 			}
 
@@ -216,11 +238,13 @@ func GetUserMergeApproval(
 		return MergeApprovalResponse{}, err
 	}
 
+	reviewComments, _ := userResponse.Params["reviewComments"].([]ReviewComment)
 	return MergeApprovalResponse{
-		Approved:      *userResponse.Approved,
-		TargetBranch:  finalTarget,
-		MergeStrategy: finalMergeStrategy,
-		Message:       userResponse.Content,
+		Approved:       *userResponse.Approved,
+		TargetBranch:   finalTarget,
+		MergeStrategy:  finalMergeStrategy,
+		Message:        userResponse.Content,
+		ReviewComments: reviewComments,
 	}, nil
 }
 
@@ -33,24 +33,25 @@ func (f Flow) MarshalJSON() ([]byte, error) {
 
 const FlowStatusPaused = "paused"
 
-// TODO /gen remove type: we want to support external flow types not defined in
-// this codebase
+// FlowType identifies the kind of workflow a flow runs. Besides the built-in
+// flow types below, any flow type registered via RegisterFlowType is valid.
 type FlowType = string
 
 const (
 	FlowTypeBasicDev   FlowType = "basic_dev"
 	FlowTypePlannedDev FlowType = "planned_dev"
+	FlowTypeCustom     FlowType = "custom"
+	FlowTypeDecompose  FlowType = "decompose"
+	// FlowTypeAddressReview addresses review comments on a pull request
+	// opened by another task
+	FlowTypeAddressReview FlowType = "address_review"
 )
 
 func StringToFlowType(s string) (FlowType, error) {
-	switch s {
-	case "basic_dev":
-		return FlowTypeBasicDev, nil
-	case "planned_dev":
-		return FlowTypePlannedDev, nil
-	default:
+	if _, ok := GetFlowType(s); !ok {
 		return "", fmt.Errorf("Invalid flow type: \"%s\"", s)
 	}
+	return s, nil
 }
 
 // FlowStorage defines the interface for flow-related database operations
@@ -58,5 +59,8 @@ type FlowStorage interface {
 	PersistFlow(ctx context.Context, flow Flow) error
 	GetFlow(ctx context.Context, workspaceId, flowId string) (Flow, error)
 	GetFlowsForTask(ctx context.Context, workspaceId, taskId string) ([]Flow, error)
+	// GetFlowsWithStatus returns the flows with the given status across all
+	// workspaces
+	GetFlowsWithStatus(ctx context.Context, status string) ([]Flow, error)
 	DeleteFlow(ctx context.Context, workspaceId, flowId string) error
 }
@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"sidekick/common"
 	"time"
 )
 
@@ -40,6 +41,7 @@ type FlowAction struct {
 	IsHumanAction      bool                   `json:"isHumanAction"`
 	IsCallbackAction   bool                   `json:"isCallbackAction"`
 	TemporalActivities []TemporalActivityRef  `json:"temporalActivities,omitempty"`
+	LlmUsage           *common.LlmUsage       `json:"llmUsage,omitempty"`
 }
 
 func (fa FlowAction) MarshalJSON() ([]byte, error) {
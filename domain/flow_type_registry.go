@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"sidekick/utils"
+
+	"github.com/invopop/jsonschema"
+)
+
+// FlowLaunchParams are the generic parameters every flow is launched with,
+// from which a flow type builds its workflow's input.
+type FlowLaunchParams struct {
+	WorkspaceId  string
+	RepoDir      string
+	Requirements string
+	FlowOptions  map[string]interface{}
+}
+
+// FlowTypeDefinition describes a flow type that tasks can be started with.
+// Flow types are registered via RegisterFlowType, typically from an init
+// function in the package defining the workflow, so that the API, CLI and UI
+// can validate and launch them without knowing about them specifically.
+type FlowTypeDefinition struct {
+	Name FlowType `json:"name"`
+	// Label is a short human-readable name, eg for display in the UI
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+
+	// OptionsSchema is the JSON schema that a task's flow options must
+	// conform to. See FlowOptionsSchema for deriving it from a Go type.
+	OptionsSchema *jsonschema.Schema `json:"optionsSchema,omitempty"`
+
+	// Workflow is the temporal workflow function started for the flow type.
+	// It must take a workflow.Context and the input returned by BuildInput,
+	// and must be registered with the worker (see RegisterWorkflows).
+	Workflow interface{} `json:"-"`
+
+	// BuildInput returns the input to start the workflow with
+	BuildInput func(params FlowLaunchParams) (interface{}, error) `json:"-"`
+}
+
+var (
+	flowTypesMu sync.RWMutex
+	flowTypes   = map[FlowType]FlowTypeDefinition{}
+)
+
+// RegisterFlowType makes a flow type available to start tasks with. It panics
+// if the definition is incomplete or the name is already registered, since
+// registration happens at init time and these are programming errors.
+func RegisterFlowType(def FlowTypeDefinition) {
+	if def.Name == "" {
+		panic("flow type name is required")
+	}
+	if def.Workflow == nil || def.BuildInput == nil {
+		panic(fmt.Sprintf("flow type %q requires a workflow and input builder", def.Name))
+	}
+
+	flowTypesMu.Lock()
+	defer flowTypesMu.Unlock()
+	if _, exists := flowTypes[def.Name]; exists {
+		panic(fmt.Sprintf("flow type %q is already registered", def.Name))
+	}
+	flowTypes[def.Name] = def
+}
+
+// GetFlowType returns the registered definition for the given flow type.
+func GetFlowType(name string) (FlowTypeDefinition, bool) {
+	flowTypesMu.RLock()
+	defer flowTypesMu.RUnlock()
+	def, ok := flowTypes[name]
+	return def, ok
+}
+
+// ListFlowTypes returns all registered flow types, sorted by name.
+func ListFlowTypes() []FlowTypeDefinition {
+	flowTypesMu.RLock()
+	defer flowTypesMu.RUnlock()
+	defs := make([]FlowTypeDefinition, 0, len(flowTypes))
+	for _, def := range flowTypes {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// ValidateFlowOptions validates flow options against the given flow type's
+// options schema, if it has one.
+func ValidateFlowOptions(flowType FlowType, flowOptions map[string]interface{}) error {
+	def, ok := GetFlowType(flowType)
+	if !ok {
+		return fmt.Errorf("Invalid flow type: \"%s\"", flowType)
+	}
+	if def.OptionsSchema == nil || flowOptions == nil {
+		return nil
+	}
+
+	// normalize to plain JSON values, as flow options may have been built in
+	// go rather than decoded from JSON
+	var normalized interface{}
+	data, err := json.Marshal(flowOptions)
+	if err != nil {
+		return fmt.Errorf("invalid flow options: %w", err)
+	}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return fmt.Errorf("invalid flow options: %w", err)
+	}
+
+	if err := utils.ValidateJSONSchema(def.OptionsSchema, normalized); err != nil {
+		return fmt.Errorf("invalid flow options for flow type \"%s\": %w", flowType, err)
+	}
+	return nil
+}
+
+// FlowOptionsSchema reflects the JSON schema for a flow options struct. Only
+// fields tagged with `jsonschema:"required"` are required, and unknown
+// properties are allowed, since flow options are shared across flow types by
+// clients such as the UI.
+func FlowOptionsSchema(options interface{}) *jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		DoNotReference:             true,
+		RequiredFromJSONSchemaTags: true,
+		AllowAdditionalProperties:  true,
+	}
+	return reflector.Reflect(options)
+}
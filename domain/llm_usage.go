@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"sidekick/common"
+	"sort"
+)
+
+// SubflowLlmUsage is the LLM usage of a subflow, including the usage of all
+// of its descendant subflows.
+type SubflowLlmUsage struct {
+	SubflowId       string          `json:"subflowId"`
+	Name            string          `json:"name"`
+	Type            *string         `json:"type,omitempty"`
+	ParentSubflowId string          `json:"parentSubflowId,omitempty"`
+	Usage           common.LlmUsage `json:"usage"`
+}
+
+// FlowLlmUsage is the LLM usage of a flow, broken down by subflow.
+type FlowLlmUsage struct {
+	FlowId   string            `json:"flowId"`
+	Usage    common.LlmUsage   `json:"usage"`
+	Subflows []SubflowLlmUsage `json:"subflows,omitempty"`
+}
+
+// TaskLlmUsage is the LLM usage of a task, broken down by flow.
+type TaskLlmUsage struct {
+	TaskId string          `json:"taskId"`
+	Title  string          `json:"title"`
+	Status TaskStatus      `json:"status"`
+	Usage  common.LlmUsage `json:"usage"`
+	Flows  []FlowLlmUsage  `json:"flows,omitempty"`
+}
+
+// WorkspaceLlmUsage is the LLM usage of all tasks in a workspace.
+type WorkspaceLlmUsage struct {
+	WorkspaceId string          `json:"workspaceId"`
+	Usage       common.LlmUsage `json:"usage"`
+	Tasks       []TaskLlmUsage  `json:"tasks"`
+}
+
+// SummarizeFlowLlmUsage totals the usage recorded on the given flow actions.
+// Usage of flow actions within a subflow is also rolled up to every ancestor
+// of that subflow. Only subflows with at least one LLM call are included,
+// in the same order as given.
+func SummarizeFlowLlmUsage(flowId string, subflows []Subflow, flowActions []FlowAction) FlowLlmUsage {
+	summary := FlowLlmUsage{FlowId: flowId}
+
+	subflowsById := make(map[string]Subflow, len(subflows))
+	for _, subflow := range subflows {
+		subflowsById[subflow.Id] = subflow
+	}
+
+	usageBySubflowId := make(map[string]*common.LlmUsage)
+	for _, flowAction := range flowActions {
+		if flowAction.LlmUsage == nil {
+			continue
+		}
+		summary.Usage.Add(*flowAction.LlmUsage)
+
+		// walk up the subflow tree, guarding against cycles in bad data
+		visited := make(map[string]bool)
+		subflowId := flowAction.SubflowId
+		for subflowId != "" && !visited[subflowId] {
+			visited[subflowId] = true
+			usage, ok := usageBySubflowId[subflowId]
+			if !ok {
+				usage = &common.LlmUsage{}
+				usageBySubflowId[subflowId] = usage
+			}
+			usage.Add(*flowAction.LlmUsage)
+			subflowId = subflowsById[subflowId].ParentSubflowId
+		}
+	}
+
+	for _, subflow := range subflows {
+		usage, ok := usageBySubflowId[subflow.Id]
+		if !ok {
+			continue
+		}
+		summary.Subflows = append(summary.Subflows, SubflowLlmUsage{
+			SubflowId:       subflow.Id,
+			Name:            subflow.Name,
+			Type:            subflow.Type,
+			ParentSubflowId: subflow.ParentSubflowId,
+			Usage:           *usage,
+		})
+	}
+
+	return summary
+}
+
+// SummarizeTaskLlmUsage totals the usage of the given flows of a task.
+func SummarizeTaskLlmUsage(task Task, flows []FlowLlmUsage) TaskLlmUsage {
+	summary := TaskLlmUsage{
+		TaskId: task.Id,
+		Title:  task.Title,
+		Status: task.Status,
+		Flows:  flows,
+	}
+	for _, flow := range flows {
+		summary.Usage.Add(flow.Usage)
+	}
+	return summary
+}
+
+// SummarizeWorkspaceLlmUsage totals the usage of the given tasks, which are
+// sorted by descending cost so the most expensive tasks come first.
+func SummarizeWorkspaceLlmUsage(workspaceId string, tasks []TaskLlmUsage) WorkspaceLlmUsage {
+	summary := WorkspaceLlmUsage{
+		WorkspaceId: workspaceId,
+		Tasks:       make([]TaskLlmUsage, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		summary.Usage.Add(task.Usage)
+		summary.Tasks = append(summary.Tasks, task)
+	}
+	sort.SliceStable(summary.Tasks, func(i, j int) bool {
+		return summary.Tasks[i].Usage.Cost > summary.Tasks[j].Usage.Cost
+	})
+	return summary
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"sidekick/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeFlowLlmUsage(t *testing.T) {
+	subflows := []Subflow{
+		{Id: "sf_parent", Name: "parent"},
+		{Id: "sf_child", Name: "child", ParentSubflowId: "sf_parent"},
+		{Id: "sf_unused", Name: "unused"},
+	}
+	flowActions := []FlowAction{
+		{Id: "fa_1", SubflowId: "sf_child", LlmUsage: &common.LlmUsage{Calls: 1, InputTokens: 100, OutputTokens: 10, Cost: 0.25}},
+		{Id: "fa_2", SubflowId: "sf_parent", LlmUsage: &common.LlmUsage{Calls: 1, InputTokens: 50, OutputTokens: 5, Cost: 0.5}},
+		{Id: "fa_3", SubflowId: "sf_child"}, // not an llm call
+		{Id: "fa_4", LlmUsage: &common.LlmUsage{Calls: 1, InputTokens: 1, OutputTokens: 1, UnpricedCalls: 1}},
+	}
+
+	summary := SummarizeFlowLlmUsage("flow_1", subflows, flowActions)
+
+	assert.Equal(t, "flow_1", summary.FlowId)
+	assert.Equal(t, 3, summary.Usage.Calls)
+	assert.Equal(t, 151, summary.Usage.InputTokens)
+	assert.InDelta(t, 0.75, summary.Usage.Cost, 1e-9)
+	assert.Equal(t, 1, summary.Usage.UnpricedCalls)
+
+	require.Len(t, summary.Subflows, 2)
+	assert.Equal(t, "sf_parent", summary.Subflows[0].SubflowId)
+	assert.Equal(t, 2, summary.Subflows[0].Usage.Calls)
+	assert.InDelta(t, 0.75, summary.Subflows[0].Usage.Cost, 1e-9)
+	assert.Equal(t, "sf_child", summary.Subflows[1].SubflowId)
+	assert.Equal(t, "sf_parent", summary.Subflows[1].ParentSubflowId)
+	assert.Equal(t, 1, summary.Subflows[1].Usage.Calls)
+	assert.InDelta(t, 0.25, summary.Subflows[1].Usage.Cost, 1e-9)
+}
+
+func TestSummarizeFlowLlmUsage_SubflowCycle(t *testing.T) {
+	subflows := []Subflow{
+		{Id: "sf_a", ParentSubflowId: "sf_b"},
+		{Id: "sf_b", ParentSubflowId: "sf_a"},
+	}
+	flowActions := []FlowAction{
+		{SubflowId: "sf_a", LlmUsage: &common.LlmUsage{Calls: 1}},
+	}
+
+	summary := SummarizeFlowLlmUsage("flow_1", subflows, flowActions)
+
+	require.Len(t, summary.Subflows, 2)
+	assert.Equal(t, 1, summary.Subflows[0].Usage.Calls)
+	assert.Equal(t, 1, summary.Subflows[1].Usage.Calls)
+}
+
+func TestSummarizeWorkspaceLlmUsage(t *testing.T) {
+	cheap := SummarizeTaskLlmUsage(Task{Id: "task_cheap"}, []FlowLlmUsage{
+		{FlowId: "flow_1", Usage: common.LlmUsage{Calls: 1, Cost: 0.1}},
+	})
+	expensive := SummarizeTaskLlmUsage(Task{Id: "task_expensive"}, []FlowLlmUsage{
+		{FlowId: "flow_2", Usage: common.LlmUsage{Calls: 2, Cost: 1}},
+		{FlowId: "flow_3", Usage: common.LlmUsage{Calls: 3, Cost: 2}},
+	})
+	assert.Equal(t, 5, expensive.Usage.Calls)
+	assert.InDelta(t, 3, expensive.Usage.Cost, 1e-9)
+
+	summary := SummarizeWorkspaceLlmUsage("ws_1", []TaskLlmUsage{cheap, expensive})
+
+	assert.Equal(t, 6, summary.Usage.Calls)
+	assert.InDelta(t, 3.1, summary.Usage.Cost, 1e-9)
+	require.Len(t, summary.Tasks, 2)
+	assert.Equal(t, "task_expensive", summary.Tasks[0].TaskId)
+	assert.Equal(t, "task_cheap", summary.Tasks[1].TaskId)
+}
@@ -97,7 +97,16 @@ type Task struct {
 	Created     time.Time              `json:"created"`
 	Updated     time.Time              `json:"updated"`
 	FlowOptions map[string]interface{} `json:"flowOptions,omitempty"`
-	StreamId    string                 `json:"streamId,omitempty"`
+	// Priority orders queued tasks: higher priority tasks start first, and
+	// tasks with the same priority start in the order they were queued
+	Priority int `json:"priority,omitempty"`
+	// QueuePosition is the 1-based position of the task in its workspace's
+	// queue while it waits for running flows to finish, or 0 if not queued
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// PullRequestURL links to the pull request opened with the task's
+	// changes, if they were approved for a pull request rather than merged
+	PullRequestURL string `json:"pullRequestUrl,omitempty"`
+	StreamId       string `json:"streamId,omitempty"`
 }
 
 func (t Task) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Links are read as "<task> <link type> <target task>", eg a task with a
+// blocked_by link to another task is blocked by it, and a task with a parent
+// link to another task is that task's parent. Links are stored on both tasks,
+// with the inverse link type on the target task.
+
+// InverseLinkType returns the link type that the target of a link has back
+// to the task the link belongs to.
+func InverseLinkType(linkType LinkType) (LinkType, error) {
+	switch linkType {
+	case LinkTypeBlocks:
+		return LinkTypeBlockedBy, nil
+	case LinkTypeBlockedBy:
+		return LinkTypeBlocks, nil
+	case LinkTypeParent:
+		return LinkTypeChild, nil
+	case LinkTypeChild:
+		return LinkTypeParent, nil
+	default:
+		return "", fmt.Errorf("invalid link type: \"%s\"", linkType)
+	}
+}
+
+// LinkedTaskIds returns the ids of the tasks this task has links of the given
+// type to, in the order the links were added.
+func (t Task) LinkedTaskIds(linkType LinkType) []string {
+	var ids []string
+	for _, link := range t.Links {
+		if link.LinkType == linkType {
+			ids = append(ids, link.TargetTaskId)
+		}
+	}
+	return ids
+}
+
+// HasLink reports whether the task has a link of the given type to the target.
+func (t Task) HasLink(linkType LinkType, targetTaskId string) bool {
+	for _, link := range t.Links {
+		if link.LinkType == linkType && link.TargetTaskId == targetTaskId {
+			return true
+		}
+	}
+	return false
+}
+
+// AddLink adds a link unless the task already has it, reporting whether it
+// was added.
+func (t *Task) AddLink(linkType LinkType, targetTaskId string) bool {
+	if t.HasLink(linkType, targetTaskId) {
+		return false
+	}
+	t.Links = append(t.Links, TaskLink{LinkType: linkType, TargetTaskId: targetTaskId})
+	return true
+}
+
+// RemoveLink removes a link if the task has it, reporting whether it was
+// removed.
+func (t *Task) RemoveLink(linkType LinkType, targetTaskId string) bool {
+	for i, link := range t.Links {
+		if link.LinkType == linkType && link.TargetTaskId == targetTaskId {
+			t.Links = append(t.Links[:i:i], t.Links[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindLinkCycle returns the ids of the tasks forming a cycle, if adding a link
+// of the given type from one task to another would create one, or nil
+// otherwise. Only blocks/blocked_by and parent/child links can form cycles,
+// each among themselves. getTask is used to look up linked tasks.
+func FindLinkCycle(taskId string, linkType LinkType, targetTaskId string, getTask func(taskId string) (Task, error)) ([]string, error) {
+	// normalize the new link to a forward edge, i.e. blocks or parent
+	from, to := taskId, targetTaskId
+	var forward LinkType
+	switch linkType {
+	case LinkTypeBlocks, LinkTypeParent:
+		forward = linkType
+	case LinkTypeBlockedBy, LinkTypeChild:
+		forward, _ = InverseLinkType(linkType)
+		from, to = targetTaskId, taskId
+	default:
+		return nil, fmt.Errorf("invalid link type: \"%s\"", linkType)
+	}
+	if from == to {
+		return []string{from, to}, nil
+	}
+
+	// depth-first search for a path from the new edge's target back to its
+	// source, which the new edge would close into a cycle
+	visited := map[string]bool{}
+	var path []string
+	var search func(id string) (bool, error)
+	search = func(id string) (bool, error) {
+		path = append(path, id)
+		if id == from {
+			return true, nil
+		}
+		if visited[id] {
+			path = path[:len(path)-1]
+			return false, nil
+		}
+		visited[id] = true
+
+		task, err := getTask(id)
+		if err != nil {
+			return false, err
+		}
+		for _, nextId := range task.LinkedTaskIds(forward) {
+			found, err := search(nextId)
+			if err != nil || found {
+				return found, err
+			}
+		}
+		path = path[:len(path)-1]
+		return false, nil
+	}
+
+	found, err := search(to)
+	if err != nil || !found {
+		return nil, err
+	}
+	return append([]string{from}, path...), nil
+}
+
+// FormatLinkCycle formats a cycle returned by FindLinkCycle for display.
+func FormatLinkCycle(cycle []string) string {
+	return strings.Join(cycle, " -> ")
+}
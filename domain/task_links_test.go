@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInverseLinkType(t *testing.T) {
+	for linkType, inverse := range map[LinkType]LinkType{
+		LinkTypeBlocks:    LinkTypeBlockedBy,
+		LinkTypeBlockedBy: LinkTypeBlocks,
+		LinkTypeParent:    LinkTypeChild,
+		LinkTypeChild:     LinkTypeParent,
+	} {
+		actual, err := InverseLinkType(linkType)
+		require.NoError(t, err)
+		assert.Equal(t, inverse, actual)
+	}
+
+	_, err := InverseLinkType("related")
+	assert.Error(t, err)
+}
+
+func TestTaskAddAndRemoveLink(t *testing.T) {
+	task := Task{}
+	assert.True(t, task.AddLink(LinkTypeBlockedBy, "task_a"))
+	assert.False(t, task.AddLink(LinkTypeBlockedBy, "task_a"))
+	assert.True(t, task.AddLink(LinkTypeParent, "task_a"))
+	assert.True(t, task.AddLink(LinkTypeBlockedBy, "task_b"))
+
+	assert.Equal(t, []string{"task_a", "task_b"}, task.LinkedTaskIds(LinkTypeBlockedBy))
+	assert.True(t, task.HasLink(LinkTypeParent, "task_a"))
+	assert.False(t, task.HasLink(LinkTypeChild, "task_a"))
+
+	assert.True(t, task.RemoveLink(LinkTypeBlockedBy, "task_a"))
+	assert.False(t, task.RemoveLink(LinkTypeBlockedBy, "task_a"))
+	assert.Equal(t, []TaskLink{
+		{LinkType: LinkTypeParent, TargetTaskId: "task_a"},
+		{LinkType: LinkTypeBlockedBy, TargetTaskId: "task_b"},
+	}, task.Links)
+}
+
+func TestFindLinkCycle(t *testing.T) {
+	// a blocks b blocks c, and d is the parent of a
+	tasks := map[string]Task{
+		"a": {Id: "a", Links: []TaskLink{{LinkTypeBlocks, "b"}, {LinkTypeChild, "d"}}},
+		"b": {Id: "b", Links: []TaskLink{{LinkTypeBlockedBy, "a"}, {LinkTypeBlocks, "c"}}},
+		"c": {Id: "c", Links: []TaskLink{{LinkTypeBlockedBy, "b"}}},
+		"d": {Id: "d", Links: []TaskLink{{LinkTypeParent, "a"}}},
+	}
+	getTask := func(id string) (Task, error) {
+		task, ok := tasks[id]
+		if !ok {
+			return Task{}, errors.New("not found: " + id)
+		}
+		return task, nil
+	}
+
+	cycle, err := FindLinkCycle("a", LinkTypeBlockedBy, "c", getTask)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a", "b", "c"}, cycle)
+	assert.Equal(t, "c -> a -> b -> c", FormatLinkCycle(cycle))
+
+	cycle, err = FindLinkCycle("c", LinkTypeBlocks, "a", getTask)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a", "b", "c"}, cycle)
+
+	cycle, err = FindLinkCycle("a", LinkTypeBlocks, "a", getTask)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a"}, cycle)
+
+	// parent/child links form cycles separately from blocking links
+	cycle, err = FindLinkCycle("b", LinkTypeParent, "d", getTask)
+	require.NoError(t, err)
+	assert.Nil(t, cycle)
+	cycle, err = FindLinkCycle("a", LinkTypeParent, "d", getTask)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "d", "a"}, cycle)
+
+	cycle, err = FindLinkCycle("c", LinkTypeBlockedBy, "a", getTask)
+	require.NoError(t, err)
+	assert.Nil(t, cycle)
+
+	_, err = FindLinkCycle("a", "related", "c", getTask)
+	assert.Error(t, err)
+
+	_, err = FindLinkCycle("c", LinkTypeBlocks, "missing", getTask)
+	assert.ErrorContains(t, err, "not found: missing")
+}
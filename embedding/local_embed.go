@@ -0,0 +1,111 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"sidekick/utils"
+)
+
+const (
+	// LocalDefaultModel is a deterministic hashed n-gram embedding model that
+	// runs in-process. Changing how features are extracted or weighted requires
+	// a new model name, since the model name is part of cached embedding keys.
+	LocalDefaultModel = "hashed-ngram-v1"
+
+	localHashedNgramDimensions = 512
+
+	// feature weights: whole words and identifier parts carry the most meaning,
+	// while character trigrams let related spellings (e.g. "embed" and
+	// "embedder") overlap
+	localWordWeight    = 1.0
+	localBigramWeight  = 0.5
+	localTrigramWeight = 0.25
+)
+
+// LocalEmbedder embeds text in-process without any network access, for
+// workspaces that can't send code to external embedding APIs. Its hashed
+// n-gram embeddings capture lexical rather than semantic similarity, so they
+// are less effective than those of hosted models, but need no setup at all.
+type LocalEmbedder struct{}
+
+func (le LocalEmbedder) Embed(ctx context.Context, modelConfig common.ModelConfig, secretManager secret_manager.SecretManager, inputs []string, taskType string) ([]EmbeddingVector, error) {
+	// taskType is ignored, as documents and queries are embedded the same way
+	model := modelConfig.Model
+	if model == "" {
+		model = LocalDefaultModel
+	}
+	if model != LocalDefaultModel {
+		return nil, fmt.Errorf("unsupported local embedding model %q, expected %q", model, LocalDefaultModel)
+	}
+
+	results := make([]EmbeddingVector, 0, len(inputs))
+	for _, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results = append(results, hashedNgramEmbedding(input, localHashedNgramDimensions))
+	}
+	return results, nil
+}
+
+// hashedNgramEmbedding hashes the terms (words and their identifier parts),
+// term bigrams and character trigrams of the text into a vector with the given
+// dimensions, using a sign bit from the hash so that collisions cancel out on
+// average. Feature counts are dampened logarithmically and the vector is
+// L2-normalized.
+func hashedNgramEmbedding(text string, dimensions int) EmbeddingVector {
+	features := make(map[string]float64)
+	terms := utils.CodeTerms(text)
+	for i, term := range terms {
+		features["w:"+term] += localWordWeight
+		if i > 0 {
+			features["b:"+terms[i-1]+" "+term] += localBigramWeight
+		}
+		padded := []rune("^" + term + "$")
+		for j := 0; j+3 <= len(padded); j++ {
+			features["t:"+string(padded[j:j+3])] += localTrigramWeight
+		}
+	}
+
+	vector := make(EmbeddingVector, dimensions)
+	if len(features) == 0 {
+		// a constant direction rather than a zero vector, which has no cosine
+		// similarity to anything
+		vector[0] = 1
+		return vector
+	}
+
+	for feature, weight := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		index := int(sum % uint64(dimensions))
+		value := float32(1 + math.Log(weight))
+		if weight < 1 {
+			value = float32(weight)
+		}
+		if sum&(1<<63) != 0 {
+			value = -value
+		}
+		vector[index] += value
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
@@ -0,0 +1,68 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"sidekick/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cosineSimilarity(a, b EmbeddingVector) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestLocalEmbedder(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	embedder := LocalEmbedder{}
+	modelConfig := common.ModelConfig{Provider: common.LocalEmbeddingProvider}
+
+	inputs := []string{
+		"func GetRankedRepoSummary(dCtx DevContext, rankQuery string) (string, error)",
+		"func (ra *RagActivities) RankedDirSignatureOutline(ctx context.Context) (string, error)",
+		"func OpenRepo(dir string) (Repo, error)",
+		"",
+	}
+	vectors, err := embedder.Embed(ctx, modelConfig, nil, inputs, TaskTypeRetrievalDocument)
+	require.NoError(t, err)
+	require.Len(t, vectors, len(inputs))
+
+	for _, vector := range vectors {
+		assert.Len(t, vector, localHashedNgramDimensions)
+		assert.InDelta(t, 1.0, math.Sqrt(cosineSimilarity(vector, vector)), 1e-5)
+	}
+
+	// embeddings are deterministic and don't depend on the task type
+	queries, err := embedder.Embed(ctx, modelConfig, nil, []string{inputs[0], "ranked repo summary"}, TaskTypeRetrievalQuery)
+	require.NoError(t, err)
+	assert.Equal(t, vectors[0], queries[0])
+
+	// identifier parts make related code more similar than unrelated code
+	query := queries[1]
+	assert.Greater(t, cosineSimilarity(query, vectors[0]), cosineSimilarity(query, vectors[1]))
+	assert.Greater(t, cosineSimilarity(query, vectors[0]), cosineSimilarity(query, vectors[2]))
+
+	_, err = embedder.Embed(ctx, common.ModelConfig{Provider: common.LocalEmbeddingProvider, Model: "unknown"}, nil, inputs, "")
+	assert.ErrorContains(t, err, "unsupported local embedding model")
+}
+
+func TestLocalEmbedderLimits(t *testing.T) {
+	t.Parallel()
+	modelConfig := common.ModelConfig{Provider: common.LocalEmbeddingProvider}
+
+	maxChars, err := GetModelMaxChars(modelConfig)
+	require.NoError(t, err)
+	assert.Equal(t, int(math.Ceil(2048*charsPerToken)), maxChars)
+
+	maxTokens, err := GetModelMaxTokens(common.ModelConfig{Provider: common.LocalEmbeddingProvider, Model: LocalDefaultModel})
+	require.NoError(t, err)
+	assert.Equal(t, 2048, maxTokens)
+}
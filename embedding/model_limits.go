@@ -35,6 +35,8 @@ var modelTokenLimits = map[string]int{
 	"text-embedding-004":     2048,
 	"gemini-embedding-001":   2048,
 	"text-embedding-005":     2048,
+	// no hard limit, but hashed n-gram embeddings of long inputs are less distinct
+	LocalDefaultModel: 2048,
 }
 
 // modelBatchTokenLimits maps known embedding model names to their maximum batch token limits.
@@ -51,12 +53,14 @@ var modelBatchSizeLimits = map[string]int{
 var providerBatchTokenLimits = map[string]int{
 	string(common.OpenaiChatProvider): 300000,
 	string(common.GoogleChatProvider): 20000,
+	common.LocalEmbeddingProvider:     1000000,
 }
 
 // providerBatchSizeLimits maps provider names to their default maximum batch size (number of inputs).
 var providerBatchSizeLimits = map[string]int{
 	string(common.OpenaiChatProvider): 2048,
 	string(common.GoogleChatProvider): 250,
+	common.LocalEmbeddingProvider:     1000,
 }
 
 // BatchEmbeddingRequests splits a list of inputs into batches that stay under both token and size limits.
@@ -119,6 +123,8 @@ func GetModelMaxTokens(modelConfig common.ModelConfig) (int, error) {
 			modelName = OpenaiDefaultModel
 		case string(common.GoogleChatProvider):
 			modelName = GoogleDefaultModel
+		case common.LocalEmbeddingProvider:
+			modelName = LocalDefaultModel
 		default:
 			if providerName == "" {
 				return 0, fmt.Errorf("provider name is empty and model name is empty, cannot determine default model")
@@ -167,6 +173,8 @@ func GetMaxBatchSize(modelConfig common.ModelConfig) (int, error) {
 			modelName = OpenaiDefaultModel
 		case string(common.GoogleChatProvider):
 			modelName = GoogleDefaultModel
+		case common.LocalEmbeddingProvider:
+			modelName = LocalDefaultModel
 		}
 	}
 
@@ -209,6 +217,8 @@ func GetMaxBatchTokens(modelConfig common.ModelConfig) (int, error) {
 			modelName = OpenaiDefaultModel
 		case string(common.GoogleChatProvider):
 			modelName = GoogleDefaultModel
+		case common.LocalEmbeddingProvider:
+			modelName = LocalDefaultModel
 		}
 	}
 
@@ -120,6 +120,21 @@ func TestBatchEmbeddingRequests(t *testing.T) {
 			wantBatches: 2,
 			wantErr:     false,
 		},
+		{
+			name: "local provider batch size limit",
+			inputs: func() []string {
+				inputs := make([]string, 1500)
+				for i := range inputs {
+					inputs[i] = "small"
+				}
+				return inputs
+			}(),
+			modelConfig: common.ModelConfig{
+				Provider: common.LocalEmbeddingProvider,
+			},
+			wantBatches: 2,
+			wantErr:     false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,238 @@
+package env
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sidekick/coding/unix"
+	"sidekick/common"
+)
+
+// ErrContainerRuntimeNotFound is returned when neither the configured
+// container runtime nor any of the default ones can be found in PATH.
+var ErrContainerRuntimeNotFound = errors.New("container runtime not found")
+
+var defaultContainerRuntimes = []string{"docker", "podman"}
+
+var invalidContainerNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// ContainerEnv runs commands inside a long-lived docker or podman container.
+// The working directory, typically a git worktree, lives on the host and is
+// bind-mounted into the container at the same path, so file reads and writes
+// done directly on the host are visible in the container and vice versa.
+type ContainerEnv struct {
+	WorkingDirectory string
+	Runtime          string
+	ContainerName    string
+}
+
+type ContainerEnvParams struct {
+	// WorkingDirectory is the host directory to bind-mount into the container
+	WorkingDirectory string
+	// ContainerName is the name of the container to create. It is sanitized
+	// to only contain characters allowed by docker and podman.
+	ContainerName string
+	Config        common.ContainerConfig
+}
+
+func NewContainerEnvActivity(ctx context.Context, params ContainerEnvParams) (EnvContainer, error) {
+	env, err := NewContainerEnv(ctx, params)
+	if err != nil {
+		return EnvContainer{}, err
+	}
+	return EnvContainer{Env: env}, nil
+}
+
+// NewContainerEnv starts a container from the configured image with the
+// working directory bind-mounted into it. Any existing container with the same
+// name is replaced, which keeps this idempotent across activity retries.
+func NewContainerEnv(ctx context.Context, params ContainerEnvParams) (*ContainerEnv, error) {
+	if params.Config.Image == "" {
+		return nil, fmt.Errorf("container image is required for the %s environment: set container.image in the repo config", EnvTypeContainer)
+	}
+	if params.ContainerName == "" {
+		return nil, fmt.Errorf("container name is required")
+	}
+
+	runtime, err := findContainerRuntime(params.Config.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	workingDir, err := filepath.Abs(params.WorkingDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute working directory: %w", err)
+	}
+
+	env := &ContainerEnv{
+		WorkingDirectory: workingDir,
+		Runtime:          runtime,
+		ContainerName:    invalidContainerNameChars.ReplaceAllString(params.ContainerName, "-"),
+	}
+
+	// ignore failures, since there usually isn't an existing container
+	_ = env.Remove(ctx)
+
+	runOutput, err := unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: workingDir,
+		Command:    runtime,
+		Args:       env.runArgs(ctx, params.Config),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s run command: %w", runtime, err)
+	}
+	if runOutput.ExitStatus != 0 {
+		return nil, fmt.Errorf("%s run command failed with exit status %d: %s", runtime, runOutput.ExitStatus, runOutput.Stderr)
+	}
+
+	return env, nil
+}
+
+func (e *ContainerEnv) runArgs(ctx context.Context, config common.ContainerConfig) []string {
+	args := []string{
+		"run", "--detach", "--init",
+		"--name", e.ContainerName,
+		"--volume", e.WorkingDirectory + ":" + e.WorkingDirectory,
+		"--workdir", e.WorkingDirectory,
+	}
+
+	// the repo's git dir is mounted read-only, including when it's within the
+	// working directory, so that code running in the container can't add hooks
+	// or config that git on the host would then run. A git worktree only has a
+	// .git file pointing into the main repo's git dir, which is mounted too so
+	// that git can read the repo inside the container, and is read-only as well
+	// so it can't be pointed elsewhere.
+	if commonDir := gitCommonDir(ctx, e.WorkingDirectory); commonDir != "" {
+		args = append(args, "--volume", commonDir+":"+commonDir+":ro")
+		dotGit := filepath.Join(e.WorkingDirectory, ".git")
+		if _, err := os.Lstat(dotGit); err == nil && dotGit != commonDir {
+			args = append(args, "--volume", dotGit+":"+dotGit+":ro")
+		}
+	}
+
+	if config.User != "" {
+		args = append(args, "--user", config.User)
+	}
+	for _, envVar := range config.EnvVars {
+		args = append(args, "--env", envVar)
+	}
+	args = append(args, config.RunArgs...)
+
+	// keep the container alive regardless of the image's default command
+	return append(args, "--entrypoint", "tail", config.Image, "-f", "/dev/null")
+}
+
+func (e *ContainerEnv) GetType() EnvType {
+	return EnvTypeContainer
+}
+
+func (e *ContainerEnv) GetWorkingDirectory() string {
+	return e.WorkingDirectory
+}
+
+func (e *ContainerEnv) RunCommand(ctx context.Context, input EnvRunCommandInput) (EnvRunCommandOutput, error) {
+	workingDir := filepath.Join(e.WorkingDirectory, input.RelativeWorkingDir)
+	envVars := append(input.EnvVars, envVarsToInject...)
+
+	// git commands issued directly by sidekick (commits, diffs, merges etc)
+	// run on the host, since the image may not have git installed and the
+	// repo's git dir is owned by the host user
+	if input.Command == "git" {
+		return unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+			WorkingDir: workingDir,
+			Command:    input.Command,
+			Args:       hostGitArgs(input.Args),
+			EnvVars:    envVars,
+		})
+	}
+
+	return unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: e.WorkingDirectory,
+		Command:    e.Runtime,
+		Args:       e.ExecArgs(workingDir, envVars, input.Command, input.Args...),
+	})
+}
+
+// ExecArgs returns the arguments to pass to the container runtime in order to
+// run the given command inside the container.
+func (e *ContainerEnv) ExecArgs(workingDir string, envVars []string, command string, args ...string) []string {
+	execArgs := []string{"exec", "--workdir", workingDir}
+	for _, envVar := range envVars {
+		execArgs = append(execArgs, "--env", envVar)
+	}
+	execArgs = append(execArgs, e.ContainerName, command)
+	return append(execArgs, args...)
+}
+
+// RemoteCommand returns the host command line that runs the given command
+// inside the container.
+func (e *ContainerEnv) RemoteCommand(workingDir string, envVars []string, command string, args ...string) []string {
+	return append([]string{e.Runtime}, e.ExecArgs(workingDir, envVars, command, args...)...)
+}
+
+// RemoteStdioCommand returns the host command line that runs the given command
+// inside the container with stdin attached.
+func (e *ContainerEnv) RemoteStdioCommand(workingDir string, envVars []string, command string, args ...string) []string {
+	execArgs := e.ExecArgs(workingDir, envVars, command, args...)
+	return slices.Concat([]string{e.Runtime, execArgs[0], "--interactive"}, execArgs[1:])
+}
+
+// Teardown removes the container.
+func (e *ContainerEnv) Teardown(ctx context.Context) error {
+	return e.Remove(ctx)
+}
+
+// Remove force-removes the container, stopping it if it's running.
+func (e *ContainerEnv) Remove(ctx context.Context) error {
+	output, err := unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: e.WorkingDirectory,
+		Command:    e.Runtime,
+		Args:       []string{"rm", "--force", e.ContainerName},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run %s rm command: %w", e.Runtime, err)
+	}
+	if output.ExitStatus != 0 {
+		return fmt.Errorf("%s rm command failed with exit status %d: %s", e.Runtime, output.ExitStatus, output.Stderr)
+	}
+	return nil
+}
+
+func findContainerRuntime(configured string) (string, error) {
+	candidates := defaultContainerRuntimes
+	if configured != "" {
+		candidates = []string{configured}
+	}
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: tried %s", ErrContainerRuntimeNotFound, strings.Join(candidates, ", "))
+}
+
+// hostGitArgs disables hooks and fsmonitor commands for git commands run on
+// the host against a working directory that commands in an isolated
+// environment can write to, so nothing written there is executed on the host.
+func hostGitArgs(args []string) []string {
+	return append([]string{"-c", "core.hooksPath=/dev/null", "-c", "core.fsmonitor="}, args...)
+}
+
+func gitCommonDir(ctx context.Context, dir string) string {
+	output, err := unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: dir,
+		Command:    "git",
+		Args:       []string{"rev-parse", "--path-format=absolute", "--git-common-dir"},
+	})
+	if err != nil || output.ExitStatus != 0 {
+		return ""
+	}
+	return strings.TrimSpace(output.Stdout)
+}
@@ -0,0 +1,243 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sidekick/common"
+	"sidekick/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupFakeContainerRuntime writes a fake docker-compatible CLI that logs its
+// arguments and runs "exec" commands directly on the host. It returns the path
+// to the fake runtime and a function that reads the logged invocations.
+func setupFakeContainerRuntime(t *testing.T) (string, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "invocations.log")
+	runtimePath := filepath.Join(dir, "fake-docker")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %q
+case "$1" in
+  exec)
+    shift
+    while [ $# -gt 0 ]; do
+      case "$1" in
+        --workdir) cd "$2" || exit 1; shift 2 ;;
+        --env) export "$2"; shift 2 ;;
+        *) break ;;
+      esac
+    done
+    shift
+    exec "$@"
+    ;;
+esac
+`, logPath)
+	require.NoError(t, os.WriteFile(runtimePath, []byte(script), 0755))
+
+	return runtimePath, func() []string {
+		content, err := os.ReadFile(logPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+}
+
+func TestNewContainerEnv(t *testing.T) {
+	ctx := context.Background()
+	runtime, invocations := setupFakeContainerRuntime(t)
+	workingDir := t.TempDir()
+
+	containerEnv, err := NewContainerEnv(ctx, ContainerEnvParams{
+		WorkingDirectory: workingDir,
+		ContainerName:    "sidekick-flow_123/abc",
+		Config: common.ContainerConfig{
+			Image:   "golang:1.24",
+			Runtime: runtime,
+			User:    "1000:1000",
+			EnvVars: []string{"CI=true"},
+			RunArgs: []string{"--network=none"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, EnvTypeContainer, containerEnv.GetType())
+	assert.Equal(t, workingDir, containerEnv.GetWorkingDirectory())
+	assert.Equal(t, "sidekick-flow_123-abc", containerEnv.ContainerName)
+
+	calls := invocations()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "rm --force sidekick-flow_123-abc", calls[0])
+	runCall := calls[1]
+	assert.True(t, strings.HasPrefix(runCall, "run --detach --init --name sidekick-flow_123-abc"), runCall)
+	assert.Contains(t, runCall, "--volume "+workingDir+":"+workingDir)
+	assert.Contains(t, runCall, "--workdir "+workingDir)
+	assert.Contains(t, runCall, "--user 1000:1000")
+	assert.Contains(t, runCall, "--env CI=true")
+	assert.Contains(t, runCall, "--network=none")
+	assert.True(t, strings.HasSuffix(runCall, "--entrypoint tail golang:1.24 -f /dev/null"), runCall)
+}
+
+func TestNewContainerEnv_MountsGitCommonDirForWorktree(t *testing.T) {
+	ctx := context.Background()
+	runtime, invocations := setupFakeContainerRuntime(t)
+	repoDir := setupTestGitRepo(t)
+
+	worktreeEnv, err := NewLocalGitWorktreeEnv(ctx, LocalEnvParams{
+		RepoDir:         repoDir,
+		WorktreeBaseDir: t.TempDir(),
+	}, domain.Worktree{Name: "side/container-test", WorkspaceId: "ws_1"})
+	require.NoError(t, err)
+
+	_, err = NewContainerEnv(ctx, ContainerEnvParams{
+		WorkingDirectory: worktreeEnv.GetWorkingDirectory(),
+		ContainerName:    "sidekick-test",
+		Config:           common.ContainerConfig{Image: "alpine", Runtime: runtime},
+	})
+	require.NoError(t, err)
+
+	gitDir, err := filepath.EvalSymlinks(filepath.Join(repoDir, ".git"))
+	require.NoError(t, err)
+	dotGitFile := filepath.Join(worktreeEnv.GetWorkingDirectory(), ".git")
+	calls := invocations()
+	require.Len(t, calls, 2)
+	assert.Contains(t, calls[1], "--volume "+gitDir+":"+gitDir+":ro")
+	assert.Contains(t, calls[1], "--volume "+dotGitFile+":"+dotGitFile+":ro")
+}
+
+func TestNewContainerEnv_MountsGitDirReadOnly(t *testing.T) {
+	ctx := context.Background()
+	runtime, invocations := setupFakeContainerRuntime(t)
+	repoDir, err := filepath.EvalSymlinks(setupTestGitRepo(t))
+	require.NoError(t, err)
+
+	_, err = NewContainerEnv(ctx, ContainerEnvParams{
+		WorkingDirectory: repoDir,
+		ContainerName:    "sidekick-test",
+		Config:           common.ContainerConfig{Image: "alpine", Runtime: runtime},
+	})
+	require.NoError(t, err)
+
+	gitDir := filepath.Join(repoDir, ".git")
+	calls := invocations()
+	require.Len(t, calls, 2)
+	assert.Contains(t, calls[1], "--volume "+repoDir+":"+repoDir+" ")
+	assert.Contains(t, calls[1], "--volume "+gitDir+":"+gitDir+":ro")
+	assert.Equal(t, 1, strings.Count(calls[1], "--volume "+gitDir), calls[1])
+}
+
+func TestNewContainerEnv_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewContainerEnv(ctx, ContainerEnvParams{
+		WorkingDirectory: t.TempDir(),
+		ContainerName:    "sidekick-test",
+	})
+	assert.ErrorContains(t, err, "container image is required")
+
+	_, err = NewContainerEnv(ctx, ContainerEnvParams{
+		WorkingDirectory: t.TempDir(),
+		ContainerName:    "sidekick-test",
+		Config:           common.ContainerConfig{Image: "alpine", Runtime: "definitely-not-a-container-runtime"},
+	})
+	assert.ErrorIs(t, err, ErrContainerRuntimeNotFound)
+}
+
+func TestContainerEnv_RunCommand(t *testing.T) {
+	ctx := context.Background()
+	runtime, invocations := setupFakeContainerRuntime(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(workingDir, "sub"), 0755))
+
+	containerEnv := &ContainerEnv{
+		WorkingDirectory: workingDir,
+		Runtime:          runtime,
+		ContainerName:    "sidekick-test",
+	}
+
+	output, err := containerEnv.RunCommand(ctx, EnvRunCommandInput{
+		RelativeWorkingDir: "sub",
+		Command:            "sh",
+		Args:               []string{"-c", "pwd; echo $FOO; echo $GIT_EDITOR"},
+		EnvVars:            []string{"FOO=bar"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, output.ExitStatus)
+	assert.Equal(t, filepath.Join(workingDir, "sub")+"\nbar\ntrue\n", output.Stdout)
+
+	calls := invocations()
+	require.Len(t, calls, 1)
+	assert.True(t, strings.HasPrefix(calls[0], "exec --workdir "+filepath.Join(workingDir, "sub")+" --env FOO=bar --env GIT_EDITOR=true sidekick-test sh -c"), calls[0])
+
+	t.Run("git runs on the host", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git command not found in PATH")
+		}
+		output, err := containerEnv.RunCommand(ctx, EnvRunCommandInput{
+			Command: "git",
+			Args:    []string{"--version"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, output.ExitStatus)
+		assert.Len(t, invocations(), 1)
+	})
+
+	t.Run("git on the host ignores hooks in the repo", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git command not found in PATH")
+		}
+		repoDir := setupTestGitRepo(t)
+		marker := filepath.Join(t.TempDir(), "hook-ran")
+		hook := "#!/bin/sh\ntouch " + marker + "\n"
+		require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".git", "hooks"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".git", "hooks", "pre-commit"), []byte(hook), 0755))
+
+		repoEnv := &ContainerEnv{WorkingDirectory: repoDir, Runtime: runtime, ContainerName: "sidekick-test"}
+		output, err := repoEnv.RunCommand(ctx, EnvRunCommandInput{
+			Command: "git",
+			Args:    []string{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "test"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 0, output.ExitStatus, output.Stderr)
+		assert.NoFileExists(t, marker)
+	})
+}
+
+func TestContainerEnv_Remove(t *testing.T) {
+	runtime, invocations := setupFakeContainerRuntime(t)
+	containerEnv := &ContainerEnv{
+		WorkingDirectory: t.TempDir(),
+		Runtime:          runtime,
+		ContainerName:    "sidekick-test",
+	}
+
+	require.NoError(t, containerEnv.Remove(context.Background()))
+	assert.Equal(t, []string{"rm --force sidekick-test"}, invocations())
+}
+
+func TestEnvContainer_ContainerEnvJSON(t *testing.T) {
+	original := EnvContainer{Env: &ContainerEnv{
+		WorkingDirectory: "/tmp/worktree",
+		Runtime:          "/usr/bin/podman",
+		ContainerName:    "sidekick-flow_1",
+	}}
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+
+	var decoded EnvContainer
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, original, decoded)
+	assert.True(t, decoded.Env.GetType().UsesGitWorktree())
+}
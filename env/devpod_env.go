@@ -0,0 +1,409 @@
+package env
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sidekick/coding/unix"
+	"sidekick/common"
+
+	"al.essio.dev/pkg/shellescape"
+)
+
+// ErrDevPodNotFound is returned when the devpod CLI can't be found in PATH.
+var ErrDevPodNotFound = errors.New("devpod CLI not found")
+
+const defaultDevPodBinary = "devpod"
+
+// devpod only allows lowercase alphanumerics and dashes in workspace ids, with
+// a maximum length of 48 characters
+var invalidDevPodIdChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+const maxDevPodIdLength = 48
+
+// DevPodEnv runs commands in a DevPod workspace over "devpod ssh". The
+// workspace is provisioned from the working directory, typically a git
+// worktree on the host, which remains the source of truth: files are read and
+// edited on the host, git commands run on the host, and edited files are
+// pushed to the workspace via PushFiles before they are checked or tested.
+// Files changed in the workspace are pulled back to the host after each
+// command.
+type DevPodEnv struct {
+	// WorkingDirectory is the host directory the workspace was provisioned from
+	WorkingDirectory string
+	// RemoteWorkingDirectory is the corresponding directory in the workspace
+	RemoteWorkingDirectory string
+	Binary                 string
+	WorkspaceId            string
+}
+
+type DevPodEnvParams struct {
+	// WorkingDirectory is the host directory to provision the workspace from
+	WorkingDirectory string
+	// WorkspaceId is the id of the DevPod workspace to create. It is sanitized
+	// to only contain characters allowed by devpod.
+	WorkspaceId string
+	Config      common.DevPodConfig
+}
+
+func NewDevPodEnvActivity(ctx context.Context, params DevPodEnvParams) (EnvContainer, error) {
+	env, err := NewDevPodEnv(ctx, params)
+	if err != nil {
+		return EnvContainer{}, err
+	}
+	return EnvContainer{Env: env}, nil
+}
+
+// NewDevPodEnv provisions a DevPod workspace from the working directory via
+// "devpod up", then determines the workspace's working directory. "devpod up"
+// reuses an existing workspace with the same id, which keeps this idempotent
+// across activity retries.
+func NewDevPodEnv(ctx context.Context, params DevPodEnvParams) (*DevPodEnv, error) {
+	workspaceId := sanitizeDevPodId(params.WorkspaceId)
+	if workspaceId == "" {
+		return nil, fmt.Errorf("devpod workspace id is required")
+	}
+
+	binary := params.Config.Binary
+	if binary == "" {
+		binary = defaultDevPodBinary
+	}
+	binaryPath, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDevPodNotFound, binary)
+	}
+
+	workingDir, err := filepath.Abs(params.WorkingDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute working directory: %w", err)
+	}
+
+	env := &DevPodEnv{
+		WorkingDirectory: workingDir,
+		Binary:           binaryPath,
+		WorkspaceId:      workspaceId,
+	}
+
+	upArgs := []string{"up", workingDir, "--id", workspaceId, "--ide", "none"}
+	if params.Config.Provider != "" {
+		upArgs = append(upArgs, "--provider", params.Config.Provider)
+	}
+	upArgs = append(upArgs, params.Config.UpArgs...)
+	upOutput, err := env.runDevPod(ctx, upArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run devpod up command: %w", err)
+	}
+	if upOutput.ExitStatus != 0 {
+		return nil, fmt.Errorf("devpod up command failed with exit status %d: %s", upOutput.ExitStatus, upOutput.Stderr)
+	}
+
+	// the workspace's directory depends on the provider and devcontainer
+	// config, and is where "devpod ssh" starts by default
+	pwdOutput, err := env.runDevPod(ctx, "ssh", workspaceId, "--command", "pwd")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devpod workspace directory: %w", err)
+	}
+	if pwdOutput.ExitStatus != 0 {
+		return nil, fmt.Errorf("failed to get devpod workspace directory, exit status %d: %s", pwdOutput.ExitStatus, pwdOutput.Stderr)
+	}
+	env.RemoteWorkingDirectory = strings.TrimSpace(pwdOutput.Stdout)
+	if env.RemoteWorkingDirectory == "" {
+		return nil, fmt.Errorf("devpod workspace directory is empty")
+	}
+
+	// records the workspace's files, so later changes can be pulled back
+	if err := env.pullChanges(ctx); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func (e *DevPodEnv) GetType() EnvType {
+	return EnvTypeDevPod
+}
+
+func (e *DevPodEnv) GetWorkingDirectory() string {
+	return e.WorkingDirectory
+}
+
+func (e *DevPodEnv) RunCommand(ctx context.Context, input EnvRunCommandInput) (EnvRunCommandOutput, error) {
+	envVars := append(input.EnvVars, envVarsToInject...)
+
+	// as with ContainerEnv, git runs on the host: the workspace's copy of the
+	// worktree doesn't necessarily have access to the repo's git dir
+	if input.Command == "git" {
+		return unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+			WorkingDir: filepath.Join(e.WorkingDirectory, input.RelativeWorkingDir),
+			Command:    input.Command,
+			Args:       hostGitArgs(input.Args),
+			EnvVars:    envVars,
+		})
+	}
+
+	workingDir := filepath.Join(e.WorkingDirectory, input.RelativeWorkingDir)
+	argv := e.RemoteCommand(workingDir, envVars, input.Command, input.Args...)
+	output, err := unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: e.WorkingDirectory,
+		Command:    argv[0],
+		Args:       argv[1:],
+	})
+	if err != nil {
+		return output, err
+	}
+
+	// commands such as tests or code generators may change files, which later
+	// diffs and merges on the host need to see
+	if err := e.pullChanges(ctx); err != nil {
+		return output, err
+	}
+	return output, nil
+}
+
+// devPodDeletedMarker separates changed files from deleted ones in the output
+// of devPodSyncScript
+const devPodDeletedMarker = "--sidekick-deleted--"
+
+// devPodSyncScript prints the files in the workspace changed since the last
+// time it ran, followed by devPodDeletedMarker and the files deleted since
+// then. It records a timestamp and the list of files for the next run, and
+// prints nothing the first time it runs.
+const devPodSyncScript = `stamp="${TMPDIR:-/tmp}/sidekick-sync-%[1]s.stamp"; files="${TMPDIR:-/tmp}/sidekick-sync-%[1]s.files"; ` +
+	`touch "$stamp.new" && find . -path ./.git -prune -o -type f -print | sort > "$files.new" || exit 1; ` +
+	`if [ -f "$stamp" ] && [ -f "$files" ]; then ` +
+	`find . -path ./.git -prune -o -type f -newer "$stamp" -print; echo %[2]s; comm -23 "$files" "$files.new"; ` +
+	`fi; mv "$stamp.new" "$stamp" && mv "$files.new" "$files"`
+
+// pullChanges copies files changed in the workspace since the last time it
+// ran back to the host, and deletes files from the host that were deleted
+// from the workspace. Changes made between commands, eg by dev run commands,
+// are pulled after the next command.
+func (e *DevPodEnv) pullChanges(ctx context.Context) error {
+	// workspace ids only contain lowercase alphanumerics and dashes
+	script := "cd " + shellescape.Quote(e.RemoteWorkingDirectory) + " && " +
+		fmt.Sprintf(devPodSyncScript, e.WorkspaceId, devPodDeletedMarker)
+	stdout, err := e.sshWithStdin(ctx, script, nil)
+	if err != nil {
+		return fmt.Errorf("failed to find files changed in devpod workspace: %w", err)
+	}
+
+	var changed, deleted []string
+	inDeleted := false
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if line == devPodDeletedMarker {
+			inDeleted = true
+			continue
+		}
+		relPath := filepath.FromSlash(strings.TrimPrefix(line, "./"))
+		if relPath == "" || validateRelPath(relPath) != nil {
+			continue
+		}
+		if inDeleted {
+			deleted = append(deleted, relPath)
+		} else {
+			changed = append(changed, relPath)
+		}
+	}
+
+	for _, relPath := range deleted {
+		if err := os.Remove(filepath.Join(e.WorkingDirectory, relPath)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", relPath, err)
+		}
+	}
+	return e.PullFiles(ctx, changed)
+}
+
+// RemoteCommand returns the host command line that runs the given command in
+// the workspace, in the workspace directory corresponding to the given host
+// working directory.
+func (e *DevPodEnv) RemoteCommand(workingDir string, envVars []string, command string, args ...string) []string {
+	script := "cd " + shellescape.Quote(e.remotePath(workingDir)) + " && exec env " +
+		shellescape.QuoteCommand(slices.Concat(envVars, []string{command}, args))
+	return []string{e.Binary, "ssh", e.WorkspaceId, "--command", script}
+}
+
+// RemoteStdioCommand is the same as RemoteCommand, since stdin is always
+// forwarded over devpod ssh.
+func (e *DevPodEnv) RemoteStdioCommand(workingDir string, envVars []string, command string, args ...string) []string {
+	return e.RemoteCommand(workingDir, envVars, command, args...)
+}
+
+// remotePath maps a path within the host working directory to the
+// corresponding path in the workspace.
+func (e *DevPodEnv) remotePath(hostPath string) string {
+	rel, err := filepath.Rel(e.WorkingDirectory, hostPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return hostPath
+	}
+	return filepath.ToSlash(filepath.Join(e.RemoteWorkingDirectory, rel))
+}
+
+// PushFiles copies the given files, relative to the working directory, from
+// the host to the workspace. Files that no longer exist on the host are
+// deleted from the workspace.
+func (e *DevPodEnv) PushFiles(ctx context.Context, relPaths []string) error {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	var deleted []string
+	for _, relPath := range relPaths {
+		if err := validateRelPath(relPath); err != nil {
+			return err
+		}
+		hostPath := filepath.Join(e.WorkingDirectory, relPath)
+		info, err := os.Stat(hostPath)
+		if os.IsNotExist(err) {
+			deleted = append(deleted, relPath)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", relPath, err)
+		}
+		if err := addFileToTar(tw, hostPath, filepath.ToSlash(relPath), info); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	script := "cd " + shellescape.Quote(e.RemoteWorkingDirectory)
+	if len(deleted) > 0 {
+		script += " && rm -f -- " + shellescape.QuoteCommand(deleted)
+	}
+	script += " && tar -xf -"
+
+	if _, err := e.sshWithStdin(ctx, script, &archive); err != nil {
+		return fmt.Errorf("failed to push files to devpod workspace: %w", err)
+	}
+	return nil
+}
+
+// PullFiles copies the given files, relative to the working directory, from
+// the workspace to the host. This is used after running commands that may
+// modify files in the workspace, such as autofix commands.
+func (e *DevPodEnv) PullFiles(ctx context.Context, relPaths []string) error {
+	if len(relPaths) == 0 {
+		return nil
+	}
+	for _, relPath := range relPaths {
+		if err := validateRelPath(relPath); err != nil {
+			return err
+		}
+	}
+
+	script := "cd " + shellescape.Quote(e.RemoteWorkingDirectory) + " && tar -cf - -- " + shellescape.QuoteCommand(relPaths)
+	stdout, err := e.sshWithStdin(ctx, script, nil)
+	if err != nil {
+		return fmt.Errorf("failed to pull files from devpod workspace: %w", err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(stdout))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read archive from devpod workspace: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		relPath := filepath.FromSlash(header.Name)
+		if err := validateRelPath(relPath); err != nil {
+			return err
+		}
+		hostPath := filepath.Join(e.WorkingDirectory, relPath)
+		if err := os.MkdirAll(filepath.Dir(hostPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", relPath, err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %w", relPath, err)
+		}
+		if err := os.WriteFile(hostPath, content, os.FileMode(header.Mode).Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", relPath, err)
+		}
+	}
+}
+
+// Teardown deletes the workspace, including any of its provider resources.
+func (e *DevPodEnv) Teardown(ctx context.Context) error {
+	output, err := e.runDevPod(ctx, "delete", e.WorkspaceId, "--force")
+	if err != nil {
+		return fmt.Errorf("failed to run devpod delete command: %w", err)
+	}
+	if output.ExitStatus != 0 {
+		return fmt.Errorf("devpod delete command failed with exit status %d: %s", output.ExitStatus, output.Stderr)
+	}
+	return nil
+}
+
+func (e *DevPodEnv) runDevPod(ctx context.Context, args ...string) (EnvRunCommandOutput, error) {
+	return unix.RunCommandActivity(ctx, unix.RunCommandActivityInput{
+		WorkingDir: e.WorkingDirectory,
+		Command:    e.Binary,
+		Args:       args,
+	})
+}
+
+// sshWithStdin runs a script in the workspace with the given stdin, returning
+// its raw stdout. Unlike RunCommand, stdout isn't assumed to be text.
+func (e *DevPodEnv) sshWithStdin(ctx context.Context, script string, stdin io.Reader) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, e.Binary, "ssh", e.WorkspaceId, "--command", script)
+	cmd.Dir = e.WorkingDirectory
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func addFileToTar(tw *tar.Writer, hostPath, name string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to create archive header for %s: %w", name, err)
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive header for %s: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	return nil
+}
+
+func validateRelPath(relPath string) error {
+	if relPath == "" || filepath.IsAbs(relPath) || !filepath.IsLocal(relPath) {
+		return fmt.Errorf("path must be relative to the working directory: %s", relPath)
+	}
+	return nil
+}
+
+func sanitizeDevPodId(id string) string {
+	id = invalidDevPodIdChars.ReplaceAllString(strings.ToLower(id), "-")
+	if len(id) > maxDevPodIdLength {
+		id = id[:maxDevPodIdLength]
+	}
+	return strings.Trim(id, "-")
+}
@@ -0,0 +1,286 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sidekick/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupFakeDevPod writes a fake devpod CLI that logs its arguments and runs
+// "ssh --command" scripts on the host, starting in a separate directory that
+// stands in for the workspace, with its own TMPDIR. It returns the path to the
+// fake binary, the workspace directory and a function that reads the logged
+// invocations.
+func setupFakeDevPod(t *testing.T) (string, string, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	remoteDir := t.TempDir()
+	remoteTmpDir := t.TempDir()
+	logPath := filepath.Join(dir, "invocations.log")
+	binaryPath := filepath.Join(dir, "fake-devpod")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %q
+case "$1" in
+  ssh)
+    [ "$3" = "--command" ] || exit 2
+    cd %q || exit 1
+    export TMPDIR=%q
+    exec sh -c "$4"
+    ;;
+esac
+`, logPath, remoteDir, remoteTmpDir)
+	require.NoError(t, os.WriteFile(binaryPath, []byte(script), 0755))
+
+	return binaryPath, remoteDir, func() []string {
+		content, err := os.ReadFile(logPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+}
+
+func TestNewDevPodEnv(t *testing.T) {
+	ctx := context.Background()
+	binary, remoteDir, invocations := setupFakeDevPod(t)
+	workingDir := t.TempDir()
+
+	devPodEnv, err := NewDevPodEnv(ctx, DevPodEnvParams{
+		WorkingDirectory: workingDir,
+		WorkspaceId:      "sidekick-flow_123ABC",
+		Config: common.DevPodConfig{
+			Binary:   binary,
+			Provider: "docker",
+			UpArgs:   []string{"--devcontainer-path", ".devcontainer/ci.json"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, EnvTypeDevPod, devPodEnv.GetType())
+	assert.Equal(t, workingDir, devPodEnv.GetWorkingDirectory())
+	assert.Equal(t, remoteDir, devPodEnv.RemoteWorkingDirectory)
+	assert.Equal(t, "sidekick-flow-123abc", devPodEnv.WorkspaceId)
+
+	calls := invocations()
+	require.Len(t, calls, 3)
+	assert.Equal(t, []string{
+		"up " + workingDir + " --id sidekick-flow-123abc --ide none --provider docker --devcontainer-path .devcontainer/ci.json",
+		"ssh sidekick-flow-123abc --command pwd",
+	}, calls[:2])
+	assert.True(t, strings.HasPrefix(calls[2], "ssh sidekick-flow-123abc --command cd "+remoteDir+" && stamp="), calls[2])
+}
+
+func TestNewDevPodEnv_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewDevPodEnv(ctx, DevPodEnvParams{
+		WorkingDirectory: t.TempDir(),
+		WorkspaceId:      "___",
+	})
+	assert.ErrorContains(t, err, "devpod workspace id is required")
+
+	_, err = NewDevPodEnv(ctx, DevPodEnvParams{
+		WorkingDirectory: t.TempDir(),
+		WorkspaceId:      "sidekick-test",
+		Config:           common.DevPodConfig{Binary: "definitely-not-devpod"},
+	})
+	assert.ErrorIs(t, err, ErrDevPodNotFound)
+
+	failingBinary := filepath.Join(t.TempDir(), "failing-devpod")
+	require.NoError(t, os.WriteFile(failingBinary, []byte("#!/bin/sh\necho 'provider not found' >&2\nexit 1\n"), 0755))
+	_, err = NewDevPodEnv(ctx, DevPodEnvParams{
+		WorkingDirectory: t.TempDir(),
+		WorkspaceId:      "sidekick-test",
+		Config:           common.DevPodConfig{Binary: failingBinary},
+	})
+	assert.ErrorContains(t, err, "devpod up command failed with exit status 1: provider not found")
+}
+
+func TestDevPodEnv_RunCommand(t *testing.T) {
+	ctx := context.Background()
+	binary, remoteDir, invocations := setupFakeDevPod(t)
+	require.NoError(t, os.Mkdir(filepath.Join(remoteDir, "sub"), 0755))
+
+	devPodEnv := &DevPodEnv{
+		WorkingDirectory:       t.TempDir(),
+		RemoteWorkingDirectory: remoteDir,
+		Binary:                 binary,
+		WorkspaceId:            "sidekick-test",
+	}
+
+	output, err := devPodEnv.RunCommand(ctx, EnvRunCommandInput{
+		RelativeWorkingDir: "sub",
+		Command:            "sh",
+		Args:               []string{"-c", "pwd; echo \"$FOO\"; echo $GIT_EDITOR"},
+		EnvVars:            []string{"FOO=it's bar"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, output.ExitStatus)
+	assert.Equal(t, filepath.Join(remoteDir, "sub")+"\nit's bar\ntrue\n", output.Stdout)
+
+	output, err = devPodEnv.RunCommand(ctx, EnvRunCommandInput{
+		Command: "sh",
+		Args:    []string{"-c", "exit 3"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, output.ExitStatus)
+
+	// each command is followed by pulling back changes
+	calls := invocations()
+	require.Len(t, calls, 4)
+	assert.True(t, strings.HasPrefix(calls[0], "ssh sidekick-test --command cd "+filepath.Join(remoteDir, "sub")+" && exec env"), calls[0])
+	assert.True(t, strings.HasPrefix(calls[1], "ssh sidekick-test --command cd "+remoteDir+" && stamp="), calls[1])
+
+	t.Run("git runs on the host", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git command not found in PATH")
+		}
+		output, err := devPodEnv.RunCommand(ctx, EnvRunCommandInput{
+			Command: "git",
+			Args:    []string{"--version"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, output.ExitStatus)
+		assert.Len(t, invocations(), 4)
+	})
+}
+
+func TestDevPodEnv_RunCommandPullsChanges(t *testing.T) {
+	for _, command := range []string{"tar", "find", "comm"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s command not found in PATH", command)
+		}
+	}
+	ctx := context.Background()
+	binary, remoteDir, _ := setupFakeDevPod(t)
+	workingDir := t.TempDir()
+
+	for _, dir := range []string{workingDir, remoteDir} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "kept.txt"), []byte("kept"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "removed.txt"), []byte("removed"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "changed.txt"), []byte("old"), 0644))
+	}
+
+	devPodEnv := &DevPodEnv{
+		WorkingDirectory:       workingDir,
+		RemoteWorkingDirectory: remoteDir,
+		Binary:                 binary,
+		WorkspaceId:            "sidekick-test",
+	}
+	// the first sync only records the workspace's files
+	require.NoError(t, devPodEnv.pullChanges(ctx))
+
+	output, err := devPodEnv.RunCommand(ctx, EnvRunCommandInput{
+		Command: "sh",
+		Args:    []string{"-c", "echo new > changed.txt && rm removed.txt && mkdir -p gen && echo gen > gen/out.txt"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, output.ExitStatus, output.Stderr)
+
+	content, err := os.ReadFile(filepath.Join(workingDir, "changed.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	content, err = os.ReadFile(filepath.Join(workingDir, "gen", "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "gen\n", string(content))
+	assert.NoFileExists(t, filepath.Join(workingDir, "removed.txt"))
+	assert.FileExists(t, filepath.Join(workingDir, "kept.txt"))
+
+	// host edits that weren't changed in the workspace are left alone
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "kept.txt"), []byte("edited on host"), 0644))
+	_, err = devPodEnv.RunCommand(ctx, EnvRunCommandInput{Command: "true"})
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(workingDir, "kept.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "edited on host", string(content))
+}
+
+func TestDevPodEnv_PushAndPullFiles(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar command not found in PATH")
+	}
+	ctx := context.Background()
+	binary, remoteDir, _ := setupFakeDevPod(t)
+	workingDir := t.TempDir()
+
+	devPodEnv := &DevPodEnv{
+		WorkingDirectory:       workingDir,
+		RemoteWorkingDirectory: remoteDir,
+		Binary:                 binary,
+		WorkspaceId:            "sidekick-test",
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(workingDir, "sub", "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "sub", "dir", "b.sh"), []byte("b"), 0755))
+
+	require.NoError(t, devPodEnv.PushFiles(ctx, []string{"a.txt", "sub/dir/b.sh"}))
+	content, err := os.ReadFile(filepath.Join(remoteDir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+	info, err := os.Stat(filepath.Join(remoteDir, "sub", "dir", "b.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// files deleted on the host are deleted from the workspace
+	require.NoError(t, os.Remove(filepath.Join(workingDir, "a.txt")))
+	require.NoError(t, devPodEnv.PushFiles(ctx, []string{"a.txt"}))
+	_, err = os.Stat(filepath.Join(remoteDir, "a.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.WriteFile(filepath.Join(remoteDir, "sub", "dir", "b.sh"), []byte("fixed b"), 0755))
+	require.NoError(t, devPodEnv.PullFiles(ctx, []string{"sub/dir/b.sh"}))
+	content, err = os.ReadFile(filepath.Join(workingDir, "sub", "dir", "b.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "fixed b", string(content))
+
+	assert.Error(t, devPodEnv.PullFiles(ctx, []string{"missing.txt"}))
+	assert.ErrorContains(t, devPodEnv.PushFiles(ctx, []string{"../outside.txt"}), "path must be relative to the working directory")
+}
+
+func TestDevPodEnv_Teardown(t *testing.T) {
+	binary, remoteDir, invocations := setupFakeDevPod(t)
+	devPodEnv := &DevPodEnv{
+		WorkingDirectory:       t.TempDir(),
+		RemoteWorkingDirectory: remoteDir,
+		Binary:                 binary,
+		WorkspaceId:            "sidekick-test",
+	}
+
+	require.NoError(t, TeardownEnvActivity(context.Background(), EnvContainer{Env: devPodEnv}))
+	assert.Equal(t, []string{"delete sidekick-test --force"}, invocations())
+}
+
+func TestEnvContainer_DevPodEnvJSON(t *testing.T) {
+	original := EnvContainer{Env: &DevPodEnv{
+		WorkingDirectory:       "/tmp/worktree",
+		RemoteWorkingDirectory: "/workspaces/worktree",
+		Binary:                 "/usr/local/bin/devpod",
+		WorkspaceId:            "sidekick-flow-1",
+	}}
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+
+	var decoded EnvContainer
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, original, decoded)
+	assert.True(t, decoded.Env.GetType().UsesGitWorktree())
+}
+
+func TestSanitizeDevPodId(t *testing.T) {
+	assert.Equal(t, "sidekick-flow-2abc", sanitizeDevPodId("sidekick-flow_2ABC"))
+	assert.Equal(t, "a-b", sanitizeDevPodId("--a//b--"))
+	assert.Len(t, sanitizeDevPodId(strings.Repeat("x", 100)), maxDevPodIdLength)
+}
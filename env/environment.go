@@ -36,10 +36,17 @@ const (
 	EnvTypeLocal            EnvType = "local"
 	EnvTypeLocalGitWorktree EnvType = "local_git_worktree"
 	EnvTypeDevPod           EnvType = "devpod"
+	EnvTypeContainer        EnvType = "container"
 )
 
 func (e EnvType) IsValid() bool {
-	return e == EnvTypeLocal || e == EnvTypeLocalGitWorktree || e == EnvTypeDevPod
+	return e == EnvTypeLocal || e == EnvTypeLocalGitWorktree || e == EnvTypeDevPod || e == EnvTypeContainer
+}
+
+// UsesGitWorktree reports whether environments of this type work in a
+// dedicated git worktree, which is merged back and cleaned up when done.
+func (e EnvType) UsesGitWorktree() bool {
+	return e == EnvTypeLocalGitWorktree || e == EnvTypeContainer || e == EnvTypeDevPod
 }
 
 type Env interface {
@@ -48,6 +55,35 @@ type Env interface {
 	RunCommand(ctx context.Context, input EnvRunCommandInput) (EnvRunCommandOutput, error)
 }
 
+// RemoteCommandEnv is implemented by environments that run commands somewhere
+// other than directly on the host, such as in a container or DevPod workspace.
+type RemoteCommandEnv interface {
+	Env
+	// RemoteCommand returns the host command line, including the executable,
+	// that runs the given command remotely in the location corresponding to
+	// the given host working directory.
+	RemoteCommand(workingDir string, envVars []string, command string, args ...string) []string
+	// RemoteStdioCommand is like RemoteCommand, but keeps the host command's
+	// stdin attached to the remote command, for long-running commands that
+	// are talked to over stdio.
+	RemoteStdioCommand(workingDir string, envVars []string, command string, args ...string) []string
+}
+
+// FileSyncer is implemented by environments whose commands don't see files in
+// the host working directory directly, so edits made on the host must be
+// pushed explicitly, and changes made by commands pulled back.
+type FileSyncer interface {
+	PushFiles(ctx context.Context, relPaths []string) error
+	PullFiles(ctx context.Context, relPaths []string) error
+}
+
+// Teardowner is implemented by environments that hold on to resources, such
+// as containers or remote workspaces, which must be released once the flow
+// using the environment finishes.
+type Teardowner interface {
+	Teardown(ctx context.Context) error
+}
+
 type EnvRunCommandInput struct {
 	// the directory relative to the environment's working directory. must not contain ".."
 	RelativeWorkingDir string
@@ -233,6 +269,18 @@ func (ec *EnvContainer) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		ec.Env = lgwe
+	case string(EnvTypeContainer):
+		var ce *ContainerEnv
+		if err := json.Unmarshal(v.Env, &ce); err != nil {
+			return err
+		}
+		ec.Env = ce
+	case string(EnvTypeDevPod):
+		var de *DevPodEnv
+		if err := json.Unmarshal(v.Env, &de); err != nil {
+			return err
+		}
+		ec.Env = de
 	case "":
 		ec.Env = nil
 	default:
@@ -290,6 +338,37 @@ func GetEnvironmentInfoActivity(ctx context.Context, input GetEnvironmentInfoInp
 	return GetEnvironmentInfoOutput{OS: parts[0], Arch: parts[1]}, nil
 }
 
+// PushFiles pushes the given files from the host working directory to
+// the environment, if it requires syncing files. Otherwise it's a no-op.
+func PushFiles(ctx context.Context, envContainer EnvContainer, relPaths []string) error {
+	syncer, ok := envContainer.Env.(FileSyncer)
+	if !ok || len(relPaths) == 0 {
+		return nil
+	}
+	return syncer.PushFiles(ctx, relPaths)
+}
+
+// PullFiles pulls the given files from the environment back to the host
+// working directory, if it requires syncing files. Otherwise it's a no-op.
+func PullFiles(ctx context.Context, envContainer EnvContainer, relPaths []string) error {
+	syncer, ok := envContainer.Env.(FileSyncer)
+	if !ok || len(relPaths) == 0 {
+		return nil
+	}
+	return syncer.PullFiles(ctx, relPaths)
+}
+
+// TeardownEnvActivity releases any resources held by the environment, such as
+// a container or DevPod workspace. Environments without such resources are
+// left untouched.
+func TeardownEnvActivity(ctx context.Context, envContainer EnvContainer) error {
+	teardowner, ok := envContainer.Env.(Teardowner)
+	if !ok {
+		return nil
+	}
+	return teardowner.Teardown(ctx)
+}
+
 // EnvRunCommandActivity runs a command in the environment contained in the provided EnvContainer.
 func EnvRunCommandActivity(ctx context.Context, input EnvRunCommandActivityInput) (EnvRunCommandActivityOutput, error) {
 	type result struct {
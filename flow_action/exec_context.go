@@ -83,7 +83,43 @@ func (fs *FlowScope) GetSubflowId() string {
 }
 
 func (eCtx *ExecContext) GetModelConfig(key string, iteration int, fallback string) common.ModelConfig {
+	modelConfig, isDefault, count := eCtx.resolveModelConfig(key, iteration, fallback)
+
+	metadata := eCtx.FetchModelMetadata(modelConfig.Provider, modelConfig.Model)
+	if !metadata.Reasoning {
+		modelConfig.ReasoningEffort = ""
+	} else if isDefault && fallback == "small" {
+		// Claude models are excluded because they error with "Thinking may
+		// not be enabled when tool_choice forces tool use."
+		if !strings.Contains(strings.ToLower(modelConfig.Model), "claude") {
+			modelConfig.ReasoningEffort = "low"
+		}
+	}
+
+	// the other models for the use case, in rotation order, are fallbacks for
+	// when the provider is rate limited or overloaded. Their reasoning effort
+	// is adjusted when they are used, to avoid fetching metadata for each.
+	for i := 1; i < count; i++ {
+		fallbackConfig, _, _ := eCtx.resolveModelConfig(key, iteration+i, fallback)
+		isDuplicate := fallbackConfig.Provider == modelConfig.Provider && fallbackConfig.Model == modelConfig.Model
+		for _, existing := range modelConfig.Fallbacks {
+			isDuplicate = isDuplicate || (fallbackConfig.Provider == existing.Provider && fallbackConfig.Model == existing.Model)
+		}
+		if !isDuplicate {
+			modelConfig.Fallbacks = append(modelConfig.Fallbacks, fallbackConfig)
+		}
+	}
+
+	return modelConfig
+}
+
+// resolveModelConfig picks the model config for the given use case key and
+// iteration, returning whether it came from the defaults and how many models
+// it was picked from
+func (eCtx *ExecContext) resolveModelConfig(key string, iteration int, fallback string) (common.ModelConfig, bool, int) {
 	modelConfig, isDefault := eCtx.LLMConfig.GetModelConfig(key, iteration)
+	models, _ := eCtx.LLMConfig.GetModelsOrDefault(key)
+	count := len(models)
 	if isDefault && fallback != "default" {
 		if fallback == "small" {
 			provider, err := common.StringToToolChatProviderType(modelConfig.Provider)
@@ -102,21 +138,11 @@ func (eCtx *ExecContext) GetModelConfig(key string, iteration int, fallback stri
 			}
 		} else {
 			modelConfig, _ = eCtx.LLMConfig.GetModelConfig(fallback, iteration)
+			models, _ = eCtx.LLMConfig.GetModelsOrDefault(fallback)
+			count = len(models)
 		}
 	}
-
-	metadata := eCtx.FetchModelMetadata(modelConfig.Provider, modelConfig.Model)
-	if !metadata.Reasoning {
-		modelConfig.ReasoningEffort = ""
-	} else if isDefault && fallback == "small" {
-		// Claude models are excluded because they error with "Thinking may
-		// not be enabled when tool_choice forces tool use."
-		if !strings.Contains(strings.ToLower(modelConfig.Model), "claude") {
-			modelConfig.ReasoningEffort = "low"
-		}
-	}
-
-	return modelConfig
+	return modelConfig, isDefault, count
 }
 
 func (eCtx *ExecContext) FetchModelMetadata(provider, model string) common.ModelMetadata {
@@ -138,3 +164,7 @@ func (eCtx *ExecContext) GetEmbeddingModelConfig(key string) common.ModelConfig
 	modelConfig := eCtx.EmbeddingConfig.GetModelConfig(key)
 	return modelConfig
 }
+
+func (eCtx *ExecContext) GetRetrievalConfig(key string) common.RetrievalConfig {
+	return eCtx.EmbeddingConfig.GetRetrievalConfig(key)
+}
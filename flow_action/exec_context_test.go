@@ -188,3 +188,64 @@ func TestGetModelConfig_NoReasoningForNonReasoningModel(t *testing.T) {
 	assert.Equal(t, "claude-3-5-sonnet-20241022", modelConfig.Model)
 	assert.Equal(t, "", modelConfig.ReasoningEffort)
 }
+
+func TestGetModelConfig_FallbacksFromOtherUseCaseModels(t *testing.T) {
+	setupModelsCache(t, map[string]interface{}{})
+
+	eCtx := &ExecContext{
+		LLMConfig: common.LLMConfig{
+			Defaults: []common.ModelConfig{
+				{Provider: "openai", Model: "gpt-5"},
+			},
+			UseCaseConfigs: map[string][]common.ModelConfig{
+				common.CodingKey: {
+					{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+					{Provider: "openai", Model: "gpt-5", ReasoningEffort: "high"},
+					{Provider: "google", Model: "gemini-2.5-pro"},
+					{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+				},
+			},
+		},
+	}
+
+	suite := &testsuite.WorkflowTestSuite{}
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&FlowActivities{})
+	env.ExecuteWorkflow(runGetModelConfigWorkflow(eCtx, common.CodingKey, 1, "default"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var modelConfig common.ModelConfig
+	require.NoError(t, env.GetWorkflowResult(&modelConfig))
+	assert.Equal(t, "openai", modelConfig.Provider)
+	assert.Equal(t, "gpt-5", modelConfig.Model)
+	// fallbacks follow the rotation order, skipping duplicates, and keep their
+	// configured reasoning effort until they are used
+	assert.Equal(t, []common.ModelConfig{
+		{Provider: "google", Model: "gemini-2.5-pro"},
+		{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+	}, modelConfig.Fallbacks)
+}
+
+func TestGetModelConfig_NoFallbacksForSingleModel(t *testing.T) {
+	setupModelsCache(t, map[string]interface{}{})
+
+	eCtx := &ExecContext{
+		LLMConfig: common.LLMConfig{
+			Defaults: []common.ModelConfig{
+				{Provider: "openai", Model: "gpt-5"},
+			},
+		},
+	}
+
+	suite := &testsuite.WorkflowTestSuite{}
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&FlowActivities{})
+	env.ExecuteWorkflow(runGetModelConfigWorkflow(eCtx, common.CodingKey, 0, "default"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var modelConfig common.ModelConfig
+	require.NoError(t, env.GetWorkflowResult(&modelConfig))
+	assert.Empty(t, modelConfig.Fallbacks)
+}
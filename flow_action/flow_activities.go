@@ -2,6 +2,8 @@ package flow_action
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"sidekick/common"
 	"sidekick/domain"
@@ -23,3 +25,75 @@ func (fa *FlowActivities) PersistSubflow(ctx context.Context, subflow domain.Sub
 func (fa *FlowActivities) GetModelMetadata(ctx context.Context, provider string, model string) (common.ModelMetadata, error) {
 	return common.GetModelMetadata(provider, model), nil
 }
+
+type GetLlmSpendInput struct {
+	WorkspaceId string
+	// FlowId is the flow requesting its spend. Its own usage is excluded from
+	// the result, since the flow tracks that itself.
+	FlowId string
+	// Since is the start of the period for the daily spend.
+	Since time.Time
+}
+
+type LlmSpend struct {
+	// Task is the usage of the other flows of the same task.
+	Task common.LlmUsage
+	// Daily is the usage of the other flows in the workspace since the given
+	// start of the day.
+	Daily common.LlmUsage
+}
+
+// GetLlmSpend totals the LLM usage recorded on flow actions of other flows,
+// for the purpose of enforcing per-task and per-day budgets.
+func (fa *FlowActivities) GetLlmSpend(ctx context.Context, input GetLlmSpendInput) (LlmSpend, error) {
+	var spend LlmSpend
+
+	flow, err := fa.Service.GetFlow(ctx, input.WorkspaceId, input.FlowId)
+	if err != nil {
+		return spend, fmt.Errorf("failed to get flow %s: %w", input.FlowId, err)
+	}
+
+	tasks, err := fa.Service.GetTasks(ctx, input.WorkspaceId, domain.AllTaskStatuses)
+	if err != nil {
+		return spend, fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	for _, task := range tasks {
+		isSameTask := task.Id == flow.ParentId
+		if !isSameTask && isFinishedTaskStatus(task.Status) && task.Updated.Before(input.Since) {
+			// finished before the period started, so nothing was spent in it
+			continue
+		}
+
+		flows, err := fa.Service.GetFlowsForTask(ctx, input.WorkspaceId, task.Id)
+		if err != nil {
+			return spend, fmt.Errorf("failed to get flows for task %s: %w", task.Id, err)
+		}
+		for _, otherFlow := range flows {
+			if otherFlow.Id == input.FlowId {
+				continue
+			}
+			flowActions, err := fa.Service.GetFlowActions(ctx, input.WorkspaceId, otherFlow.Id)
+			if err != nil {
+				return spend, fmt.Errorf("failed to get flow actions for flow %s: %w", otherFlow.Id, err)
+			}
+			for _, flowAction := range flowActions {
+				if flowAction.LlmUsage == nil {
+					continue
+				}
+				if isSameTask {
+					spend.Task.Add(*flowAction.LlmUsage)
+				}
+				if !flowAction.Created.Before(input.Since) {
+					spend.Daily.Add(*flowAction.LlmUsage)
+				}
+			}
+		}
+	}
+
+	return spend, nil
+}
+
+func isFinishedTaskStatus(status domain.TaskStatus) bool {
+	return status == domain.TaskStatusComplete || status == domain.TaskStatusFailed || status == domain.TaskStatusCanceled
+}
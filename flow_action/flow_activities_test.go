@@ -3,11 +3,14 @@ package flow_action
 import (
 	"context"
 	"testing"
+	"time"
 
+	"sidekick/common"
 	"sidekick/domain"
 	"sidekick/srv"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPersistFlowAction(t *testing.T) {
@@ -30,3 +33,49 @@ func TestPersistFlowAction(t *testing.T) {
 
 	assert.Equal(t, flowAction, persistedFlowAction)
 }
+
+func TestGetLlmSpend(t *testing.T) {
+	ctx := context.Background()
+	service := srv.NewTestService(t)
+	fa := FlowActivities{Service: service}
+
+	workspaceId := "ws_spend"
+	startOfDay := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	yesterday := startOfDay.Add(-12 * time.Hour)
+	today := startOfDay.Add(6 * time.Hour)
+
+	persistTaskWithUsage := func(taskId string, status domain.TaskStatus, flowIds []string, created time.Time, cost float64) {
+		require.NoError(t, service.PersistTask(ctx, domain.Task{WorkspaceId: workspaceId, Id: taskId, Status: status, Created: created, Updated: created}))
+		for _, flowId := range flowIds {
+			require.NoError(t, service.PersistFlow(ctx, domain.Flow{WorkspaceId: workspaceId, Id: flowId, ParentId: taskId}))
+			require.NoError(t, service.PersistFlowAction(ctx, domain.FlowAction{
+				WorkspaceId:  workspaceId,
+				FlowId:       flowId,
+				Id:           "fa_" + flowId,
+				ActionType:   "generate.code",
+				ActionStatus: domain.ActionStatusComplete,
+				Created:      created,
+				Updated:      created,
+				LlmUsage:     &common.LlmUsage{Calls: 1, InputTokens: 100, Cost: cost},
+			}))
+		}
+	}
+
+	persistTaskWithUsage("task_current", domain.TaskStatusInProgress, []string{"flow_current", "flow_earlier"}, yesterday, 1)
+	persistTaskWithUsage("task_other_today", domain.TaskStatusComplete, []string{"flow_other_today"}, today, 2)
+	persistTaskWithUsage("task_other_yesterday", domain.TaskStatusComplete, []string{"flow_other_yesterday"}, yesterday, 4)
+
+	spend, err := fa.GetLlmSpend(ctx, GetLlmSpendInput{
+		WorkspaceId: workspaceId,
+		FlowId:      "flow_current",
+		Since:       startOfDay,
+	})
+	require.NoError(t, err)
+
+	// only the other flow of the same task counts towards the task spend
+	assert.Equal(t, 1, spend.Task.Calls)
+	assert.InDelta(t, 1, spend.Task.Cost, 1e-9)
+	// only other flows with usage since the start of the day count towards the daily spend
+	assert.Equal(t, 1, spend.Daily.Calls)
+	assert.InDelta(t, 2, spend.Daily.Cost, 1e-9)
+}
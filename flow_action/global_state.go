@@ -2,6 +2,7 @@ package flow_action
 
 import (
 	"errors"
+	"sidekick/common"
 	"sync"
 )
 
@@ -24,6 +25,9 @@ type GlobalState struct {
 	// This allows different workflow types to store custom state without
 	// polluting the GlobalState struct with use-case-specific fields.
 	values map[string]any
+	// llmUsage is the total LLM usage of all flow actions tracked in this
+	// workflow so far.
+	llmUsage common.LlmUsage
 }
 
 // InitValues initializes the values map if it's nil.
@@ -112,3 +116,17 @@ func (g *GlobalState) GetStringValue(key string) string {
 	str, _ := value.(string)
 	return str
 }
+
+// AddLlmUsage adds to the total LLM usage of the workflow.
+func (g *GlobalState) AddLlmUsage(usage common.LlmUsage) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.llmUsage.Add(usage)
+}
+
+// GetLlmUsage returns the total LLM usage of the workflow so far.
+func (g *GlobalState) GetLlmUsage() common.LlmUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.llmUsage
+}
@@ -1,6 +1,7 @@
 package flow_action
 
 import (
+	"sidekick/common"
 	"testing"
 )
 
@@ -158,3 +159,18 @@ func TestGlobalState_Values(t *testing.T) {
 		}
 	})
 }
+
+func TestGlobalState_LlmUsage(t *testing.T) {
+	gs := &GlobalState{}
+	if usage := gs.GetLlmUsage(); usage.Calls != 0 {
+		t.Errorf("Expected no usage initially, got %+v", usage)
+	}
+
+	gs.AddLlmUsage(common.LlmUsage{Calls: 1, InputTokens: 100, Cost: 0.5})
+	gs.AddLlmUsage(common.LlmUsage{Calls: 1, OutputTokens: 20, Cost: 0.25})
+
+	usage := gs.GetLlmUsage()
+	if usage.Calls != 2 || usage.InputTokens != 100 || usage.OutputTokens != 20 || usage.Cost != 0.75 {
+		t.Errorf("Unexpected accumulated usage: %+v", usage)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"sidekick/common"
 	"sidekick/domain"
 	"sidekick/temporalmeta"
 	"sidekick/utils"
@@ -274,6 +276,10 @@ func trackFlowAction[T any](actionCtx ActionContext, isHumanAction bool, f func(
 		return defaultT, fmt.Errorf("failed to convert val to json: %v", err)
 	}
 	flowAction.ActionResult = string(jsonVal)
+	flowAction.LlmUsage = llmUsageOf(val)
+	if flowAction.LlmUsage != nil && eCtx.GlobalState != nil {
+		eCtx.GlobalState.AddLlmUsage(*flowAction.LlmUsage)
+	}
 	updateECtx := eCtx
 	if v := workflow.GetVersion(eCtx, "disconnected-context", workflow.DefaultVersion, 1); v == 1 {
 		disconnectedWorkflowCtx, _ := workflow.NewDisconnectedContext(eCtx.Context)
@@ -291,6 +297,20 @@ func trackFlowAction[T any](actionCtx ActionContext, isHumanAction bool, f func(
 	return val, nil
 }
 
+// llmUsageOf returns the usage reported by val when it's an LLM response, so
+// that the cost of LLM calls is recorded on the flow action that made them.
+func llmUsageOf(val any) *common.LlmUsage {
+	reporter, ok := val.(common.LlmUsageReporter)
+	if !ok {
+		return nil
+	}
+	if v := reflect.ValueOf(reporter); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+	llmUsage := reporter.GetLlmUsage()
+	return &llmUsage
+}
+
 // decorateFlowActionWithActivities asynchronously fetches the temporal activity
 // history for a completed/failed flow action and persists the activity refs.
 func decorateFlowActionWithActivities(eCtx ExecContext, flowAction domain.FlowAction) {
@@ -0,0 +1,39 @@
+package flow_action
+
+import (
+	"sidekick/common"
+	"sidekick/llm2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLlmUsageOf(t *testing.T) {
+	cost := 0.02
+
+	t.Run("llm2 response", func(t *testing.T) {
+		var response common.MessageResponse = &llm2.MessageResponse{
+			Usage: llm2.Usage{InputTokens: 10, OutputTokens: 5, CacheReadInputTokens: 4},
+			Cost:  &cost,
+		}
+		usage := llmUsageOf(response)
+		require.NotNil(t, usage)
+		assert.Equal(t, common.LlmUsage{Calls: 1, InputTokens: 10, OutputTokens: 5, CacheReadInputTokens: 4, Cost: cost}, *usage)
+	})
+
+	t.Run("legacy response without pricing", func(t *testing.T) {
+		usage := llmUsageOf(&common.ChatMessageResponse{Usage: common.Usage{InputTokens: 3, OutputTokens: 1}})
+		require.NotNil(t, usage)
+		assert.Equal(t, common.LlmUsage{Calls: 1, InputTokens: 3, OutputTokens: 1, UnpricedCalls: 1}, *usage)
+	})
+
+	t.Run("nil response", func(t *testing.T) {
+		var response *llm2.MessageResponse
+		assert.Nil(t, llmUsageOf(response))
+	})
+
+	t.Run("non-llm value", func(t *testing.T) {
+		assert.Nil(t, llmUsageOf("some result"))
+	})
+}
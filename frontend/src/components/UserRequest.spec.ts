@@ -2,6 +2,7 @@ import { describe, it, expect, vi, beforeEach, afterEach } from 'vitest'
 import { config, mount } from '@vue/test-utils'
 import PrimeVue from 'primevue/config'
 import UserRequest from './UserRequest.vue'
+import UnifiedDiffViewer from './UnifiedDiffViewer.vue'
 import type { FlowAction } from '../lib/models'
 
 config.global.plugins.push(PrimeVue)
@@ -219,4 +220,74 @@ describe('UserRequest', () => {
       wrapper.unmount()
     })
   })
-})
\ No newline at end of file
+
+  describe('review comments', () => {
+    const findCompleteBody = () => {
+      const completeCall = fetchMock.mock.calls.find(
+        (call: unknown[]) => typeof call[0] === 'string' && call[0].includes('/flow_actions/') && call[0].includes('/complete')
+      )
+      expect(completeCall).toBeTruthy()
+      return JSON.parse((completeCall![1] as RequestInit).body as string)
+    }
+
+    it('rejects with line comments added on the diff even without a rejection reason', async () => {
+      const wrapper = mount(UserRequest, {
+        props: {
+          flowAction: createMergeApprovalFlowAction(),
+          expand: true,
+          level: 0,
+        },
+        attachTo: document.body,
+      })
+
+      const comment = { path: 'file.txt', side: 'new', startLine: 1, endLine: 1, body: 'Use a better word' }
+      wrapper.findComponent(UnifiedDiffViewer).vm.$emit('addComment', comment)
+      await wrapper.vm.$nextTick()
+
+      expect(wrapper.find('.review-comments').text()).toContain('file.txt:1')
+      const rejectButton = wrapper.findAll('button').find(button => button.text().includes('Reject'))
+      expect(rejectButton!.attributes('disabled')).toBeUndefined()
+
+      await wrapper.find('form').trigger('keydown', { key: 'Enter', ctrlKey: true })
+
+      await vi.waitFor(() => {
+        const body = findCompleteBody()
+        expect(body.userResponse.approved).toBe(false)
+        expect(body.userResponse.params.reviewComments).toEqual([comment])
+      })
+
+      wrapper.unmount()
+    })
+
+    it('sends earlier review comments with their resolved state', async () => {
+      const flowAction = createMergeApprovalFlowAction()
+      flowAction.actionParams.mergeApprovalInfo.reviewComments = [
+        { id: '1.1', path: 'file.txt', side: 'new', startLine: 1, endLine: 1, body: 'First', round: 1 },
+        { id: '1.2', path: 'file.txt', side: 'old', startLine: 1, endLine: 1, body: 'Second', round: 1 },
+      ]
+      const wrapper = mount(UserRequest, {
+        props: {
+          flowAction,
+          expand: true,
+          level: 0,
+        },
+        attachTo: document.body,
+      })
+
+      await wrapper.find('.review-comments input[type="checkbox"]').setValue(true)
+      await wrapper.find('textarea').setValue('Still one thing left')
+      await wrapper.find('form').trigger('keydown', { key: 'Enter', ctrlKey: true })
+
+      await vi.waitFor(() => {
+        const body = findCompleteBody()
+        expect(body.userResponse.approved).toBe(false)
+        expect(body.userResponse.params.reviewComments.map((c: { id: string, resolved: boolean }) => [c.id, c.resolved])).toEqual([
+          ['1.1', true],
+          ['1.2', false],
+        ])
+      })
+
+      wrapper.unmount()
+    })
+  })
+})
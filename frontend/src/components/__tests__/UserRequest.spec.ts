@@ -244,6 +244,18 @@ describe('UserRequest', () => {
       expect(vm.mergeStrategy).toBe('merge')
     })
 
+    it('loads persisted pull_request mergeStrategy from localStorage', async () => {
+      localStorage.setItem('mergeApproval.mergeStrategy', 'pull_request')
+
+      const flowAction = createMergeApprovalFlowAction()
+      mountComponent(flowAction)
+
+      await wrapper.vm.$nextTick()
+
+      const vm = wrapper.vm as any
+      expect(vm.mergeStrategy).toBe('pull_request')
+    })
+
     it('sends devRunAction start via user action API', async () => {
       const fetchMock = vi.fn().mockResolvedValue({
         ok: true,
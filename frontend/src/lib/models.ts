@@ -9,9 +9,14 @@ export interface LLMConfig {
   useCaseConfigs: { [key: string]: ModelConfig[] }
 }
 
+export interface RetrievalConfig {
+  mode?: 'hybrid' | 'vector'
+}
+
 export interface EmbeddingConfig {
   defaults: ModelConfig[]
   useCaseConfigs: { [key: string]: ModelConfig[] }
+  retrieval?: { [key: string]: RetrievalConfig }
 }
 
 export interface Workspace {
@@ -59,6 +64,9 @@ export interface Task {
   flows?: Flow[]
   flowType?: string
   flowOptions?: null | { [key: string]: any }
+  priority?: number
+  queuePosition?: number
+  pullRequestUrl?: string
   archived?: Date | null
 }
 
@@ -110,6 +118,20 @@ export interface FlowAction {
   streamingData?: StreamingData
 }
 
+/* A review comment left on a range of lines of a merge approval diff. Matches
+ * dev.ReviewComment in the backend */
+export interface ReviewComment {
+  id?: string
+  path: string
+  side: 'old' | 'new'
+  startLine: number
+  endLine: number
+  body: string
+  resolved?: boolean
+  round?: number
+  diffHunk?: string
+}
+
 export interface SubflowTree {
   name: string;
   id?: string;
@@ -52,6 +52,9 @@ const (
 	OpenaiCompatibleToolChatProviderType          ToolChatProviderType = ToolChatProviderType(common.OpenaiCompatibleChatProvider)
 	OpenaiResponsesCompatibleToolChatProviderType ToolChatProviderType = ToolChatProviderType(common.OpenaiResponsesCompatibleChatProvider)
 	GoogleToolChatProviderType                    ToolChatProviderType = ToolChatProviderType(common.GoogleChatProvider)
+	OllamaToolChatProviderType                    ToolChatProviderType = ToolChatProviderType(common.OllamaChatProvider)
+	BedrockToolChatProviderType                   ToolChatProviderType = ToolChatProviderType(common.BedrockChatProvider)
+	AzureOpenaiToolChatProviderType               ToolChatProviderType = ToolChatProviderType(common.AzureOpenaiChatProvider)
 )
 
 type ToolChatOptions struct {
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sidekick/common"
+	"sidekick/llm"
 	"strings"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/bedrock"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/anthropics/anthropic-sdk-go/shared/constant"
 	"github.com/rs/zerolog/log"
@@ -36,6 +40,11 @@ type AnthropicProvider struct {
 	AnthropicCompatible bool
 	AuthType            common.ProviderAuthType
 	CustomHeaders       map[string]string
+
+	// Bedrock sends requests to Anthropic models hosted on AWS Bedrock in the
+	// given Region, with AWS credentials rather than Anthropic ones
+	Bedrock bool
+	Region  string
 }
 
 func anthropicBetaHeader(model string, useOAuth bool, tools []*common.Tool, assumeAnthropicModelNames bool) string {
@@ -83,32 +92,54 @@ func anthropicRequestHeaders(model string, useOAuth bool, accessToken string, to
 }
 
 func (p AnthropicProvider) Stream(ctx context.Context, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
-	messages := request.Messages
+	// breakpoints are planned per request since those stored with the chat
+	// history go stale as messages are appended
+	messages := PlanCacheBreakpoints(request.Messages)
 	options := request.Options
 
 	model := options.Model
 	if model == "" {
 		model = p.DefaultModel
-		if model == "" {
+		if model == "" && !p.Bedrock {
 			model = anthropicDefaultModel
 		}
 	}
-	assumeAnthropicModelNames := !p.AnthropicCompatible
-
-	oauthCreds, token, useOAuth, err := anthropicCredentialsForRequest(
-		request.SecretManager,
-		options.ModelConfig.NormalizedProviderName(),
-		p.AuthType,
-	)
-	if err != nil {
-		return nil, err
+	if model == "" {
+		// bedrock model ids are account and region specific, e.g. inference
+		// profiles, so there is no sensible default
+		return nil, fmt.Errorf("no model given for bedrock provider %s", options.Provider)
 	}
+	assumeAnthropicModelNames := !p.AnthropicCompatible
 
 	var client anthropic.Client
 	httpClient := &http.Client{Timeout: 45 * time.Minute}
 	clientOptions := []option.RequestOption{
 		option.WithHTTPClient(httpClient),
 	}
+
+	var oauthCreds *llm.OAuthCredentials
+	var token string
+	var useOAuth bool
+	var err error
+	if p.Bedrock {
+		awsConfig, err := bedrockAWSConfig(ctx, request.SecretManager, options.ModelConfig.NormalizedProviderName(), p.Region, p.AuthType)
+		if err != nil {
+			return nil, err
+		}
+		// sets the regional bedrock endpoint, so must precede any base URL
+		// override
+		clientOptions = append(clientOptions, bedrock.WithConfig(awsConfig))
+	} else {
+		oauthCreds, token, useOAuth, err = anthropicCredentialsForRequest(
+			request.SecretManager,
+			options.ModelConfig.NormalizedProviderName(),
+			p.AuthType,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if p.BaseURL != "" {
 		clientOptions = append(clientOptions, option.WithBaseURL(p.BaseURL))
 	}
@@ -129,9 +160,11 @@ func (p AnthropicProvider) Stream(ctx context.Context, request StreamRequest, ev
 		client = newAnthropicClient(clientOptions...)
 	} else {
 		headers := anthropicRequestHeaders(model, false, "", options.Tools, assumeAnthropicModelNames)
+		if !p.Bedrock {
+			clientOptions = append(clientOptions, option.WithAPIKey(token))
+		}
 		clientOptions = append(
 			clientOptions,
-			option.WithAPIKey(token),
 			option.WithHeader("Accept", headers["Accept"]),
 			option.WithHeader("anthropic-dangerous-direct-browser-access", headers["anthropic-dangerous-direct-browser-access"]),
 			option.WithHeader("anthropic-beta", headers["anthropic-beta"]),
@@ -162,6 +195,16 @@ func (p AnthropicProvider) Stream(ctx context.Context, request StreamRequest, ev
 		params.ServiceTier = anthropic.MessageNewParamsServiceTier(options.ServiceTier)
 	}
 
+	// bedrock and other anthropic-compatible APIs may lag behind in supporting
+	// structured outputs, so they get the schema in the prompt instead
+	nativeResponseFormat := options.ResponseFormat != nil && !p.Bedrock && assumeAnthropicModelNames && anthropicSupportsStructuredOutputs(model)
+	if options.ResponseFormat != nil && !nativeResponseFormat {
+		messages, err = options.ResponseFormat.withInstructions(messages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	anthropicMessages, err := messagesToAnthropicParams(messages)
 	if err != nil {
 		return nil, err
@@ -237,6 +280,14 @@ func (p AnthropicProvider) Stream(ctx context.Context, request StreamRequest, ev
 	// When resolvedEffort is "" and ReasoningEffort was "lowest", thinking is
 	// intentionally skipped (no params.Thinking set).
 
+	if nativeResponseFormat {
+		schema, err := options.ResponseFormat.schemaMap()
+		if err != nil {
+			return nil, err
+		}
+		params.OutputConfig.Format = anthropic.JSONOutputFormatParam{Schema: schema}
+	}
+
 	stream := client.Messages.NewStreaming(ctx, params)
 
 	var finalMessage anthropic.Message
@@ -356,8 +407,9 @@ func (p AnthropicProvider) Stream(ctx context.Context, request StreamRequest, ev
 		}
 	}
 
-	if stream.Err() != nil {
-		return nil, stream.Err()
+	// the bedrock event stream decoder reports the end of the stream as EOF
+	if err := stream.Err(); err != nil && !(p.Bedrock && errors.Is(err, io.EOF)) {
+		return nil, err
 	}
 
 	if startedBlocks != stoppedBlocks {
@@ -437,6 +489,21 @@ func anthropicSupportsAdaptiveThinking(model string) bool {
 	return major > 4 || (major == 4 && minor >= 6)
 }
 
+// anthropicSupportsStructuredOutputs returns true for models that accept a
+// JSON schema output format (Opus and Sonnet 4.5+).
+func anthropicSupportsStructuredOutputs(model string) bool {
+	major, minor, ok := parseAnthropicVersion(model)
+	if !ok {
+		return false
+	}
+	// a date snapshot suffix, as in claude-sonnet-4-20250514, is parsed as
+	// the minor version
+	if minor > 99 {
+		minor = 0
+	}
+	return major > 4 || (major == 4 && minor >= 5)
+}
+
 // parseAnthropicVersion extracts the major and minor version from an Anthropic
 // model name for the opus or sonnet family. Returns false if the model is not
 // a recognized opus/sonnet model or the version cannot be parsed.
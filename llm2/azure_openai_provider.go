@@ -0,0 +1,190 @@
+package llm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// azureOpenAIDefaultAPIVersion is the first api-version supporting all the
+// chat completions parameters we send, including reasoning_effort
+const azureOpenAIDefaultAPIVersion = "2025-04-01-preview"
+
+const azureCognitiveServicesScope = "https://cognitiveservices.azure.com/.default"
+
+// azureADAuthorityURL is where service principal credentials are exchanged for
+// Microsoft Entra ID tokens. It's a variable so tests can stand in for it.
+var azureADAuthorityURL = "https://login.microsoftonline.com"
+
+// AzureOpenAIProvider streams chat completions from models deployed to an
+// Azure OpenAI resource, authenticating with either an API key or a Microsoft
+// Entra ID (formerly Azure AD) token.
+type AzureOpenAIProvider struct {
+	// BaseURL is the resource endpoint, e.g. https://<resource>.openai.azure.com
+	BaseURL      string
+	APIVersion   string
+	DefaultModel string
+	// Deployments maps model names to deployment names. Models without an
+	// entry are assumed to be deployed under their own name.
+	Deployments   map[string]string
+	AuthType      common.ProviderAuthType
+	CustomHeaders map[string]string
+}
+
+func (p AzureOpenAIProvider) Stream(ctx context.Context, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
+	options := request.Options
+	if p.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required for azure_openai provider %s", options.Provider)
+	}
+
+	model := options.Model
+	if model == "" {
+		model = p.DefaultModel
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no model given for azure_openai provider %s", options.Provider)
+	}
+	deployment := model
+	if d := p.Deployments[model]; d != "" {
+		deployment = d
+	}
+
+	apiVersion := p.APIVersion
+	if apiVersion == "" {
+		apiVersion = azureOpenAIDefaultAPIVersion
+	}
+
+	authOption, err := azureOpenAIAuthOption(ctx, request.SecretManager, options.ModelConfig.NormalizedProviderName(), p.AuthType)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: the service is constructed directly rather than via
+	// openai.NewClient, which would pick up OPENAI_* env variables meant for
+	// the openai provider
+	clientOptions := []option.RequestOption{
+		option.WithHTTPClient(&http.Client{Timeout: 45 * time.Minute}),
+		option.WithBaseURL(fmt.Sprintf("%s/openai/deployments/%s/", strings.TrimSuffix(p.BaseURL, "/"), url.PathEscape(deployment))),
+		option.WithQuery("api-version", apiVersion),
+		authOption,
+	}
+	for k, v := range p.CustomHeaders {
+		clientOptions = append(clientOptions, option.WithHeader(k, v))
+	}
+
+	return streamChatCompletions(ctx, openai.NewChatCompletionService(clientOptions...), model, request, eventChan)
+}
+
+// azureOpenAIAuthOption authenticates requests with the api-key header, using
+// <PROVIDER>_API_KEY, or with an Entra ID bearer token. The token is either
+// given directly in <PROVIDER>_AZURE_AD_TOKEN or obtained for a service
+// principal given by <PROVIDER>_AZURE_TENANT_ID, <PROVIDER>_AZURE_CLIENT_ID and
+// <PROVIDER>_AZURE_CLIENT_SECRET. The any auth type prefers an API key.
+func azureOpenAIAuthOption(ctx context.Context, secretManager secret_manager.SecretManager, providerName string, authType common.ProviderAuthType) (option.RequestOption, error) {
+	authType = common.NormalizeProviderAuthType(string(authType))
+	switch authType {
+	case common.ProviderAuthTypeAPI, common.ProviderAuthTypeAny:
+		apiKey, err := secretManager.GetSecret(fmt.Sprintf("%s_API_KEY", providerName))
+		if apiKey != "" {
+			return option.WithHeader("api-key", apiKey), nil
+		}
+		if authType == common.ProviderAuthTypeAPI {
+			if err == nil {
+				err = fmt.Errorf("empty API key for azure_openai provider %s", providerName)
+			}
+			return nil, err
+		}
+		fallthrough
+	case common.ProviderAuthTypeAzureAD:
+		token, err := azureADToken(ctx, secretManager, providerName)
+		if err != nil {
+			return nil, err
+		}
+		return option.WithHeader("Authorization", "Bearer "+token), nil
+	default:
+		return nil, fmt.Errorf("unsupported azure_openai auth type: %s", authType)
+	}
+}
+
+type azureADCachedToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// service principal tokens are valid for about an hour, so they are cached
+// and reused across requests until shortly before they expire
+var azureADTokenCache sync.Map
+
+func azureADToken(ctx context.Context, secretManager secret_manager.SecretManager, providerName string) (string, error) {
+	if token, _ := secretManager.GetSecret(fmt.Sprintf("%s_AZURE_AD_TOKEN", providerName)); token != "" {
+		return token, nil
+	}
+
+	tenantId, _ := secretManager.GetSecret(fmt.Sprintf("%s_AZURE_TENANT_ID", providerName))
+	clientId, _ := secretManager.GetSecret(fmt.Sprintf("%s_AZURE_CLIENT_ID", providerName))
+	clientSecret, _ := secretManager.GetSecret(fmt.Sprintf("%s_AZURE_CLIENT_SECRET", providerName))
+	if tenantId == "" || clientId == "" || clientSecret == "" {
+		return "", fmt.Errorf("no Entra ID credentials found for azure_openai provider %s: run `side auth` or set %s_AZURE_AD_TOKEN", providerName, providerName)
+	}
+
+	cacheKey := strings.Join([]string{azureADAuthorityURL, tenantId, clientId}, "|")
+	if cached, ok := azureADTokenCache.Load(cacheKey); ok {
+		token := cached.(azureADCachedToken)
+		if time.Now().Add(5 * time.Minute).Before(token.expiresAt) {
+			return token.accessToken, nil
+		}
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientId)
+	form.Set("client_secret", clientSecret)
+	form.Set("scope", azureCognitiveServicesScope)
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", azureADAuthorityURL, url.PathEscape(tenantId))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Entra ID token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Entra ID token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get Entra ID token: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to parse Entra ID token response: %w", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("received empty Entra ID access token")
+	}
+
+	azureADTokenCache.Store(cacheKey, azureADCachedToken{
+		accessToken: tokenResponse.AccessToken,
+		expiresAt:   time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second),
+	})
+	return tokenResponse.AccessToken, nil
+}
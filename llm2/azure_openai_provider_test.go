@@ -0,0 +1,134 @@
+package llm2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAzureOpenAITestServer stands in for an Azure OpenAI resource as well as
+// the Entra ID token endpoint, capturing the last chat completions request
+func newAzureOpenAITestServer(t *testing.T, capturedRequest **http.Request, tokenRequests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tenant-1/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("client_secret") != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "https://cognitiveservices.azure.com/.default", r.PostForm.Get("scope"))
+		fmt.Fprint(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"entra-token"}`)
+	})
+	mux.HandleFunc("POST /openai/deployments/{deployment}/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		*capturedRequest = r
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4.1-2025-04-14\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello from Azure\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4.1-2025-04-14\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":3,\"total_tokens\":12}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	originalAuthorityURL := azureADAuthorityURL
+	azureADAuthorityURL = server.URL
+	t.Cleanup(func() { azureADAuthorityURL = originalAuthorityURL })
+	return server
+}
+
+func TestAzureOpenAIProvider(t *testing.T) {
+	var capturedRequest *http.Request
+	var tokenRequests atomic.Int32
+	server := newAzureOpenAITestServer(t, &capturedRequest, &tokenRequests)
+
+	stream := func(provider AzureOpenAIProvider, model string, secretManager secret_manager.SecretManager) (*MessageResponse, error) {
+		eventChan := make(chan Event, 100)
+		defer close(eventChan)
+		return provider.Stream(context.Background(), StreamRequest{
+			Messages: []Message{{Role: RoleUser, Content: TextContentBlocks("Hi")}},
+			Options: Options{
+				ModelConfig: common.ModelConfig{Provider: "corp-azure", Model: model},
+			},
+			SecretManager: secretManager,
+		}, eventChan)
+	}
+
+	t.Run("api key auth with deployment mapping", func(t *testing.T) {
+		provider := AzureOpenAIProvider{
+			BaseURL:     server.URL + "/",
+			APIVersion:  "2024-10-21",
+			Deployments: map[string]string{"gpt-4.1": "gpt41-prod"},
+		}
+		response, err := stream(provider, "gpt-4.1", mapSecretManager{"CORP_AZURE_API_KEY": "azure-key"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Hello from Azure", response.Output.GetContentString())
+		assert.Equal(t, "gpt-4.1-2025-04-14", response.Model)
+		assert.Equal(t, Usage{InputTokens: 9, OutputTokens: 3}, response.Usage)
+
+		assert.Equal(t, "/openai/deployments/gpt41-prod/chat/completions", capturedRequest.URL.Path)
+		assert.Equal(t, "2024-10-21", capturedRequest.URL.Query().Get("api-version"))
+		assert.Equal(t, "azure-key", capturedRequest.Header.Get("api-key"))
+		assert.Empty(t, capturedRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("unmapped models use their own name as the deployment", func(t *testing.T) {
+		_, err := stream(AzureOpenAIProvider{BaseURL: server.URL, DefaultModel: "gpt-5-mini"}, "", mapSecretManager{"CORP_AZURE_API_KEY": "azure-key"})
+		require.NoError(t, err)
+		assert.Equal(t, "/openai/deployments/gpt-5-mini/chat/completions", capturedRequest.URL.Path)
+		assert.Equal(t, azureOpenAIDefaultAPIVersion, capturedRequest.URL.Query().Get("api-version"))
+	})
+
+	t.Run("entra id service principal auth", func(t *testing.T) {
+		provider := AzureOpenAIProvider{BaseURL: server.URL, AuthType: common.ProviderAuthTypeAzureAD}
+		secrets := mapSecretManager{
+			"CORP_AZURE_API_KEY":             "ignored",
+			"CORP_AZURE_AZURE_TENANT_ID":     "tenant-1",
+			"CORP_AZURE_AZURE_CLIENT_ID":     "client-1",
+			"CORP_AZURE_AZURE_CLIENT_SECRET": "client-secret",
+		}
+		for range 2 {
+			_, err := stream(provider, "gpt-4.1", secrets)
+			require.NoError(t, err)
+			assert.Equal(t, "Bearer entra-token", capturedRequest.Header.Get("Authorization"))
+			assert.Empty(t, capturedRequest.Header.Get("api-key"))
+		}
+		// the token is cached across requests
+		assert.Equal(t, int32(1), tokenRequests.Load())
+	})
+
+	t.Run("any auth type falls back to a given entra id token", func(t *testing.T) {
+		_, err := stream(AzureOpenAIProvider{BaseURL: server.URL}, "gpt-4.1", mapSecretManager{"CORP_AZURE_AZURE_AD_TOKEN": "given-token"})
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer given-token", capturedRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := stream(AzureOpenAIProvider{BaseURL: server.URL}, "gpt-4.1", mapSecretManager{})
+		assert.ErrorContains(t, err, "no Entra ID credentials found")
+
+		_, err = stream(AzureOpenAIProvider{BaseURL: server.URL, AuthType: common.ProviderAuthTypeAPI}, "gpt-4.1", mapSecretManager{"CORP_AZURE_AZURE_AD_TOKEN": "given-token"})
+		assert.ErrorContains(t, err, "CORP_AZURE_API_KEY")
+
+		_, err = stream(AzureOpenAIProvider{BaseURL: server.URL, AuthType: common.ProviderAuthTypeAzureAD}, "gpt-4.1", mapSecretManager{
+			"CORP_AZURE_AZURE_TENANT_ID":     "tenant-1",
+			"CORP_AZURE_AZURE_CLIENT_ID":     "client-2",
+			"CORP_AZURE_AZURE_CLIENT_SECRET": "wrong",
+		})
+		assert.ErrorContains(t, err, "invalid_client")
+
+		_, err = stream(AzureOpenAIProvider{BaseURL: server.URL}, "", mapSecretManager{"CORP_AZURE_API_KEY": "azure-key"})
+		assert.ErrorContains(t, err, "no model given")
+	})
+}
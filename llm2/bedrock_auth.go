@@ -0,0 +1,68 @@
+package llm2
+
+import (
+	"context"
+	"fmt"
+	"sidekick/common"
+	"sidekick/secret_manager"
+
+	"github.com/anthropics/anthropic-sdk-go/bedrock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// bedrockAWSConfig resolves the AWS config used to call a bedrock provider.
+// Credentials are looked up in this order:
+//
+//  1. <PROVIDER>_AWS_ACCESS_KEY_ID and <PROVIDER>_AWS_SECRET_ACCESS_KEY (plus
+//     an optional <PROVIDER>_AWS_SESSION_TOKEN), used for SigV4 signing
+//  2. a bedrock API key in <PROVIDER>_API_KEY, sent as a bearer token
+//  3. the default AWS credential chain (environment, shared config and
+//     profiles, SSO, instance roles), used for SigV4 signing
+//
+// The api auth type requires a bedrock API key.
+func bedrockAWSConfig(ctx context.Context, secretManager secret_manager.SecretManager, providerName, region string, authType common.ProviderAuthType) (aws.Config, error) {
+	var loadOptions []func(*config.LoadOptions) error
+	if region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(region))
+	}
+
+	apiKey := ""
+	switch common.NormalizeProviderAuthType(string(authType)) {
+	case common.ProviderAuthTypeAPI:
+		key, err := secretManager.GetSecret(fmt.Sprintf("%s_API_KEY", providerName))
+		if err != nil {
+			return aws.Config{}, err
+		}
+		apiKey = key
+	case common.ProviderAuthTypeAny:
+		accessKeyId, _ := secretManager.GetSecret(fmt.Sprintf("%s_AWS_ACCESS_KEY_ID", providerName))
+		if accessKeyId != "" {
+			secretAccessKey, err := secretManager.GetSecret(fmt.Sprintf("%s_AWS_SECRET_ACCESS_KEY", providerName))
+			if err != nil {
+				return aws.Config{}, fmt.Errorf("found %s_AWS_ACCESS_KEY_ID without a secret access key: %w", providerName, err)
+			}
+			sessionToken, _ := secretManager.GetSecret(fmt.Sprintf("%s_AWS_SESSION_TOKEN", providerName))
+			loadOptions = append(loadOptions, config.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(accessKeyId, secretAccessKey, sessionToken),
+			))
+		} else {
+			apiKey, _ = secretManager.GetSecret(fmt.Sprintf("%s_API_KEY", providerName))
+		}
+	default:
+		return aws.Config{}, fmt.Errorf("unsupported bedrock auth type: %s", authType)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if apiKey != "" {
+		awsConfig.BearerAuthTokenProvider = bedrock.NewStaticBearerTokenProvider(apiKey)
+	}
+	if awsConfig.Region == "" {
+		return aws.Config{}, fmt.Errorf("no AWS region configured for bedrock provider: set region in the provider config or AWS_REGION")
+	}
+	return awsConfig, nil
+}
@@ -0,0 +1,150 @@
+package llm2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapSecretManager map[string]string
+
+func (m mapSecretManager) GetSecret(secretName string) (string, error) {
+	if value, ok := m[secretName]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %s", secret_manager.ErrSecretNotFound, secretName)
+}
+
+func (m mapSecretManager) GetType() secret_manager.SecretManagerType {
+	return secret_manager.MockSecretManagerType
+}
+
+// isolateAWSEnvironment keeps the developer's AWS config and credentials out
+// of tests that load the default AWS config, and avoids network lookups
+func isolateAWSEnvironment(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	// avoid fetching models.dev when looking up model info
+	t.Setenv("SIDE_CACHE_HOME", dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "models.dev.json"), []byte("{}"), 0644))
+	common.ClearModelsCache()
+	t.Cleanup(common.ClearModelsCache)
+	for _, name := range []string{"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_BEARER_TOKEN_BEDROCK"} {
+		t.Setenv(name, "")
+	}
+}
+
+// newBedrockTestServer stands in for the bedrock runtime, responding to
+// streaming invocations with the given anthropic events encoded as an AWS
+// event stream
+func newBedrockTestServer(t *testing.T, events []string, capturedRequest **http.Request, capturedBody *map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		*capturedRequest = r
+		require.NoError(t, json.Unmarshal(body, capturedBody))
+
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		encoder := eventstream.NewEncoder()
+		for _, event := range events {
+			payload, err := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(event))})
+			require.NoError(t, err)
+			msg := eventstream.Message{Payload: payload}
+			msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+			msg.Headers.Set(":event-type", eventstream.StringValue("chunk"))
+			require.NoError(t, encoder.Encode(w, msg))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var bedrockTestEvents = []string{
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello from Bedrock"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestAnthropicProvider_Bedrock(t *testing.T) {
+	isolateAWSEnvironment(t)
+	const model = "us.anthropic.claude-sonnet-4-5-20250929-v1:0"
+
+	var capturedRequest *http.Request
+	var capturedBody map[string]any
+	server := newBedrockTestServer(t, bedrockTestEvents, &capturedRequest, &capturedBody)
+
+	stream := func(provider AnthropicProvider, secretManager secret_manager.SecretManager) (*MessageResponse, error) {
+		eventChan := make(chan Event, 100)
+		defer close(eventChan)
+		return provider.Stream(context.Background(), StreamRequest{
+			Messages: []Message{{Role: RoleUser, Content: TextContentBlocks("Hi")}},
+			Options: Options{
+				ModelConfig: common.ModelConfig{Provider: "corp-bedrock"},
+			},
+			SecretManager: secretManager,
+		}, eventChan)
+	}
+
+	t.Run("signs requests with access keys", func(t *testing.T) {
+		response, err := stream(AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL, DefaultModel: model}, mapSecretManager{
+			"CORP_BEDROCK_AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+			"CORP_BEDROCK_AWS_SECRET_ACCESS_KEY": "secret",
+			"CORP_BEDROCK_API_KEY":               "unused",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello from Bedrock", response.Output.GetContentString())
+		assert.Equal(t, "end_turn", response.StopReason)
+
+		assert.Equal(t, "/model/"+model+"/invoke-with-response-stream", capturedRequest.URL.Path)
+		authorization := capturedRequest.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), authorization)
+		assert.Contains(t, authorization, "/us-west-2/bedrock/aws4_request")
+		assert.Empty(t, capturedRequest.Header.Get("X-Api-Key"))
+		assert.Equal(t, "bedrock-2023-05-31", capturedBody["anthropic_version"])
+		assert.NotContains(t, capturedBody, "model")
+	})
+
+	t.Run("uses a bedrock api key as a bearer token", func(t *testing.T) {
+		_, err := stream(AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL, DefaultModel: model}, mapSecretManager{
+			"CORP_BEDROCK_API_KEY": "bedrock-key",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer bedrock-key", capturedRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("requires a region and model", func(t *testing.T) {
+		_, err := stream(AnthropicProvider{Bedrock: true, BaseURL: server.URL, DefaultModel: model}, mapSecretManager{})
+		assert.ErrorContains(t, err, "no AWS region configured")
+
+		_, err = stream(AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL}, mapSecretManager{})
+		assert.ErrorContains(t, err, "no model given")
+	})
+
+	t.Run("api auth type requires an api key", func(t *testing.T) {
+		_, err := stream(AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL, DefaultModel: model, AuthType: common.ProviderAuthTypeAPI}, mapSecretManager{
+			"CORP_BEDROCK_AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+			"CORP_BEDROCK_AWS_SECRET_ACCESS_KEY": "secret",
+		})
+		assert.ErrorContains(t, err, "CORP_BEDROCK_API_KEY")
+	})
+}
@@ -0,0 +1,114 @@
+package llm2
+
+// CacheControlEphemeral marks a content block as the end of a prompt prefix
+// that the provider should cache
+const CacheControlEphemeral = "ephemeral"
+
+const (
+	// maxCacheBreakpoints is the most blocks Anthropic allows to be marked
+	// with cache control in a single request
+	maxCacheBreakpoints = 4
+
+	// cacheLookbackBlocks is how many blocks before a breakpoint Anthropic
+	// checks for an earlier cache entry. Breakpoints further apart than this
+	// miss the cache even when the prefix is unchanged.
+	cacheLookbackBlocks = 20
+)
+
+// PlanCacheBreakpoints returns a copy of the messages with cache breakpoints
+// placed to maximize prompt cache hits across successive requests in a chat.
+// Any existing cache control is replaced. Breakpoints go at the end of the
+// first message, which usually holds the initial instructions and context, and
+// at the end of the last message, so that the next request can read the whole
+// conversation so far from the cache. The remaining breakpoints are spread
+// backwards from the last one, so a cache entry from an earlier request is
+// found even when many blocks were added since.
+func PlanCacheBreakpoints(messages []Message) []Message {
+	type cacheablePosition struct {
+		message int
+		block   int
+		// index of the block across all messages
+		flatIndex int
+	}
+
+	planned := make([]Message, len(messages))
+	var positions []cacheablePosition
+	flatIndex := 0
+	for i, msg := range messages {
+		planned[i] = msg
+		planned[i].Content = make([]ContentBlock, len(msg.Content))
+		for j, block := range msg.Content {
+			block.CacheControl = ""
+			planned[i].Content[j] = block
+			if isCacheableBlock(block) {
+				positions = append(positions, cacheablePosition{message: i, block: j, flatIndex: flatIndex})
+			}
+			flatIndex++
+		}
+	}
+	if len(positions) == 0 {
+		return planned
+	}
+
+	mark := func(k int) {
+		planned[positions[k].message].Content[positions[k].block].CacheControl = CacheControlEphemeral
+	}
+
+	last := len(positions) - 1
+	mark(last)
+	breakpoints := 1
+
+	// the last cacheable block of the first message, if it has one
+	first := -1
+	for k := 0; k < len(positions) && positions[k].message == 0; k++ {
+		first = k
+	}
+	if first >= 0 && first != last {
+		mark(first)
+		breakpoints++
+	}
+
+	current := last
+	for breakpoints < maxCacheBreakpoints {
+		target := positions[current].flatIndex - cacheLookbackBlocks
+		if target <= 0 || (first >= 0 && target <= positions[first].flatIndex) {
+			// the first breakpoint or the start of the prompt is within reach
+			break
+		}
+		next := -1
+		for k := current - 1; k > first && positions[k].flatIndex >= target; k-- {
+			next = k
+		}
+		if next == -1 {
+			// no cacheable block within the lookback window, so place the
+			// breakpoint on the closest one before it
+			for k := current - 1; k > first; k-- {
+				if positions[k].flatIndex < target {
+					next = k
+					break
+				}
+			}
+		}
+		if next == -1 {
+			break
+		}
+		mark(next)
+		breakpoints++
+		current = next
+	}
+
+	return planned
+}
+
+// isCacheableBlock reports whether cache control may be set on the block.
+// Anthropic rejects it on thinking blocks and empty text blocks.
+func isCacheableBlock(block ContentBlock) bool {
+	switch block.Type {
+	case ContentBlockTypeText:
+		return block.Text != ""
+	case ContentBlockTypeToolUse, ContentBlockTypeToolResult, ContentBlockTypeImage, ContentBlockTypeRefusal:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,146 @@
+package llm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sidekick/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cacheBreakpoints(messages []Message) [][2]int {
+	var breakpoints [][2]int
+	for i, msg := range messages {
+		for j, block := range msg.Content {
+			if block.CacheControl != "" {
+				breakpoints = append(breakpoints, [2]int{i, j})
+			}
+		}
+	}
+	return breakpoints
+}
+
+// toolLoopMessages returns a chat where the model made the given number of
+// tool calls, one per turn, after the initial instructions
+func toolLoopMessages(toolCalls int) []Message {
+	messages := []Message{
+		{Role: RoleUser, Content: []ContentBlock{
+			{Type: ContentBlockTypeText, Text: "instructions"},
+			{Type: ContentBlockTypeText, Text: "context", CacheControl: CacheControlEphemeral},
+		}},
+	}
+	for i := range toolCalls {
+		id := fmt.Sprintf("call_%d", i)
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: []ContentBlock{
+				{Type: ContentBlockTypeReasoning, Reasoning: &ReasoningBlock{Text: "thinking"}},
+				{Type: ContentBlockTypeToolUse, ToolUse: &ToolUseBlock{Id: id, Name: "search", Arguments: "{}"}},
+			}},
+			Message{Role: RoleUser, Content: []ContentBlock{
+				{Type: ContentBlockTypeToolResult, ToolResult: &ToolResultBlock{ToolCallId: id, Content: TextContentBlocks("result")}},
+			}},
+		)
+	}
+	return messages
+}
+
+func TestPlanCacheBreakpoints(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Empty(t, PlanCacheBreakpoints(nil))
+	})
+
+	t.Run("single message", func(t *testing.T) {
+		planned := PlanCacheBreakpoints(toolLoopMessages(0))
+		assert.Equal(t, [][2]int{{0, 1}}, cacheBreakpoints(planned))
+	})
+
+	t.Run("short chat marks the first and last messages", func(t *testing.T) {
+		messages := toolLoopMessages(3)
+		planned := PlanCacheBreakpoints(messages)
+		assert.Equal(t, [][2]int{{0, 1}, {6, 0}}, cacheBreakpoints(planned))
+
+		// the input is left untouched
+		assert.Equal(t, CacheControlEphemeral, messages[0].Content[1].CacheControl)
+		assert.Empty(t, messages[6].Content[0].CacheControl)
+	})
+
+	t.Run("long chat spreads breakpoints within the lookback window", func(t *testing.T) {
+		// 2 + 3*30 = 92 blocks
+		planned := PlanCacheBreakpoints(toolLoopMessages(30))
+		breakpoints := cacheBreakpoints(planned)
+		require.Len(t, breakpoints, maxCacheBreakpoints)
+		assert.Equal(t, [2]int{0, 1}, breakpoints[0])
+		assert.Equal(t, [2]int{60, 0}, breakpoints[3])
+
+		flatIndex := func(position [2]int) int {
+			index := 0
+			for i := range position[0] {
+				index += len(planned[i].Content)
+			}
+			return index + position[1]
+		}
+		for i := 2; i < len(breakpoints); i++ {
+			assert.LessOrEqual(t, flatIndex(breakpoints[i])-flatIndex(breakpoints[i-1]), cacheLookbackBlocks)
+		}
+		for _, position := range breakpoints {
+			assert.NotEqual(t, ContentBlockTypeReasoning, planned[position[0]].Content[position[1]].Type)
+		}
+	})
+
+	t.Run("skips blocks that can't be cached", func(t *testing.T) {
+		planned := PlanCacheBreakpoints([]Message{
+			{Role: RoleUser, Content: []ContentBlock{{Type: ContentBlockTypeText, Text: "hi"}}},
+			{Role: RoleAssistant, Content: []ContentBlock{
+				{Type: ContentBlockTypeText, Text: "hello"},
+				{Type: ContentBlockTypeReasoning, Reasoning: &ReasoningBlock{Text: "thinking"}},
+				{Type: ContentBlockTypeText, Text: ""},
+			}},
+		})
+		assert.Equal(t, [][2]int{{0, 0}, {1, 0}}, cacheBreakpoints(planned))
+	})
+}
+
+func TestAnthropicProvider_PlansCacheBreakpoints(t *testing.T) {
+	isolateAWSEnvironment(t)
+
+	var capturedRequest *http.Request
+	var capturedBody map[string]any
+	server := newBedrockTestServer(t, bedrockTestEvents, &capturedRequest, &capturedBody)
+
+	messages := toolLoopMessages(2)
+	// a stale breakpoint from when this was the last message
+	messages[2].Content[0].CacheControl = CacheControlEphemeral
+
+	eventChan := make(chan Event, 100)
+	defer close(eventChan)
+	provider := AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL, DefaultModel: "us.anthropic.claude-sonnet-4-5-20250929-v1:0"}
+	_, err := provider.Stream(context.Background(), StreamRequest{
+		Messages:      messages,
+		Options:       Options{ModelConfig: common.ModelConfig{Provider: "corp-bedrock"}},
+		SecretManager: mapSecretManager{"CORP_BEDROCK_API_KEY": "bedrock-key"},
+	}, eventChan)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(capturedBody["messages"])
+	require.NoError(t, err)
+	var sentMessages []struct {
+		Content []map[string]any `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal(body, &sentMessages))
+
+	var breakpoints [][2]int
+	for i, msg := range sentMessages {
+		for j, block := range msg.Content {
+			if block["cache_control"] != nil {
+				breakpoints = append(breakpoints, [2]int{i, j})
+			}
+		}
+	}
+	assert.Equal(t, [][2]int{{0, 1}, {4, 0}}, breakpoints)
+}
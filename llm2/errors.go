@@ -0,0 +1,147 @@
+package llm2
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v3"
+	"google.golang.org/genai"
+)
+
+// ErrorClass classifies errors from providers by how they should be handled:
+// whether retrying, falling back to another model or giving up is warranted.
+type ErrorClass string
+
+const (
+	// ErrorClassRateLimit is a rate limit or exhausted quota
+	ErrorClassRateLimit ErrorClass = "rate_limit"
+	// ErrorClassOverloaded is a temporary failure on the provider side, e.g.
+	// a 5xx error or an overloaded model
+	ErrorClassOverloaded ErrorClass = "overloaded"
+	// ErrorClassContextLength means the request exceeded the model's context
+	// window, so retrying it as is will fail the same way
+	ErrorClassContextLength ErrorClass = "context_length"
+	// ErrorClassAuth is a missing, invalid or insufficiently privileged
+	// credential
+	ErrorClassAuth ErrorClass = "auth"
+	// ErrorClassUnknown is any other error
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// ShouldFallback reports whether another model may succeed where one failed
+// with this class of error
+func (c ErrorClass) ShouldFallback() bool {
+	return c == ErrorClassRateLimit || c == ErrorClassOverloaded
+}
+
+// Retryable reports whether retrying the same request against the same model
+// may succeed
+func (c ErrorClass) Retryable() bool {
+	return c != ErrorClassContextLength && c != ErrorClassAuth
+}
+
+// apiStatusError is an error response from a provider API whose message has
+// been reworded for readability, keeping the status code for classification
+type apiStatusError struct {
+	message    string
+	statusCode int
+	err        error
+}
+
+func (e *apiStatusError) Error() string {
+	return e.message
+}
+
+func (e *apiStatusError) Unwrap() error {
+	return e.err
+}
+
+// error messages are matched case-insensitively, to classify errors that
+// arrive mid-stream or without a status code, e.g. bedrock exceptions
+var (
+	contextLengthErrorMarkers = []string{
+		"context_length_exceeded", "context length", "context window", "prompt is too long",
+		"input is too long", "maximum context", "exceeds the maximum number of tokens", "too many tokens",
+	}
+	rateLimitErrorMarkers = []string{
+		"rate limit", "rate_limit", "ratelimit", "too many requests", "resource_exhausted",
+		"quota", "throttl",
+	}
+	overloadedErrorMarkers = []string{
+		"overloaded", "unavailable", "internal server error", "internal_error", "api_error",
+		"bad gateway", "gateway timeout", "modelnotready",
+	}
+	authErrorMarkers = []string{
+		"authentication_error", "permission_error", "permission_denied", "unauthenticated",
+		"unauthorized", "invalid api key", "invalid x-api-key", "invalid_api_key", "incorrect api key",
+		"accessdenied", "access denied", "unrecognizedclient", "expiredtoken", "invalidsignature",
+	}
+)
+
+// ClassifyError classifies an error returned by a provider's Stream. Errors
+// not from the provider API, e.g. invalid requests, are ErrorClassUnknown.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	statusCode := errorStatusCode(err)
+	message := strings.ToLower(err.Error())
+	switch {
+	// context length errors are reported with various 4xx status codes, so
+	// they are matched by message first
+	case statusCode == http.StatusRequestEntityTooLarge || containsAny(message, contextLengthErrorMarkers):
+		return ErrorClassContextLength
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorClassAuth
+	case statusCode >= 500:
+		// anthropic uses 529 for overloaded
+		return ErrorClassOverloaded
+	case statusCode != 0:
+		return ErrorClassUnknown
+	case containsAny(message, rateLimitErrorMarkers):
+		return ErrorClassRateLimit
+	case containsAny(message, authErrorMarkers):
+		return ErrorClassAuth
+	case containsAny(message, overloadedErrorMarkers):
+		return ErrorClassOverloaded
+	}
+	return ErrorClassUnknown
+}
+
+func errorStatusCode(err error) int {
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	var genaiErr genai.APIError
+	if errors.As(err, &genaiErr) {
+		return genaiErr.Code
+	}
+	var genaiErrPtr *genai.APIError
+	if errors.As(err, &genaiErrPtr) {
+		return genaiErrPtr.Code
+	}
+	return 0
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
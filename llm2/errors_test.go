@@ -0,0 +1,65 @@
+package llm2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ""},
+		{"rate limit status", &apiStatusError{message: "slow down", statusCode: http.StatusTooManyRequests}, ErrorClassRateLimit},
+		{"wrapped rate limit status", fmt.Errorf("stream failed: %w", &apiStatusError{message: "slow down", statusCode: 429}), ErrorClassRateLimit},
+		{"openai sdk error", &openai.Error{
+			StatusCode: http.StatusServiceUnavailable,
+			Request:    httptest.NewRequest(http.MethodPost, "https://api.example.com/v1/chat/completions", nil),
+			Response:   &http.Response{StatusCode: http.StatusServiceUnavailable},
+		}, ErrorClassOverloaded},
+		{"anthropic overloaded status", &apiStatusError{message: "Overloaded", statusCode: 529}, ErrorClassOverloaded},
+		{"server error", &apiStatusError{message: "oops", statusCode: http.StatusInternalServerError}, ErrorClassOverloaded},
+		{"unauthorized", &apiStatusError{message: "bad key", statusCode: http.StatusUnauthorized}, ErrorClassAuth},
+		{"forbidden", &apiStatusError{message: "no access", statusCode: http.StatusForbidden}, ErrorClassAuth},
+		{"payload too large", &apiStatusError{message: "too big", statusCode: http.StatusRequestEntityTooLarge}, ErrorClassContextLength},
+		{"context length bad request", &apiStatusError{message: "This model's maximum context length is 128000 tokens", statusCode: http.StatusBadRequest}, ErrorClassContextLength},
+		{"other bad request", &apiStatusError{message: "invalid tool schema: rate limit", statusCode: http.StatusBadRequest}, ErrorClassUnknown},
+		{"mid-stream overloaded event", errors.New(`received error while streaming: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`), ErrorClassOverloaded},
+		{"bedrock throttling", errors.New("ThrottlingException: Too many requests, please wait before trying again."), ErrorClassRateLimit},
+		{"gemini quota", errors.New("Error 429, Message: Resource has been exhausted (e.g. check quota)., Status: RESOURCE_EXHAUSTED"), ErrorClassRateLimit},
+		{"bedrock access denied", errors.New("AccessDeniedException: You don't have access to the model"), ErrorClassAuth},
+		{"prompt too long", errors.New("prompt is too long: 210000 tokens > 200000 maximum"), ErrorClassContextLength},
+		{"unrelated", errors.New("failed to parse tool call arguments"), ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, ClassifyError(tt.err))
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, ErrorClassRateLimit.ShouldFallback())
+	assert.True(t, ErrorClassOverloaded.ShouldFallback())
+	assert.False(t, ErrorClassAuth.ShouldFallback())
+	assert.False(t, ErrorClassContextLength.ShouldFallback())
+	assert.False(t, ErrorClassUnknown.ShouldFallback())
+
+	assert.True(t, ErrorClassRateLimit.Retryable())
+	assert.True(t, ErrorClassUnknown.Retryable())
+	assert.False(t, ErrorClassAuth.Retryable())
+	assert.False(t, ErrorClassContextLength.Retryable())
+}
@@ -0,0 +1,127 @@
+package llm2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// googleContextCacheTTL is how long explicit context caches live. Dev loops
+// send their next request well within it, while caches left behind by finished
+// flows don't accrue storage costs for long.
+const googleContextCacheTTL = 15 * time.Minute
+
+type googleCachedContent struct {
+	name      string
+	expiresAt time.Time
+}
+
+// googleContextCaches maps a hash of a cached prompt prefix to the context
+// cache holding it
+var googleContextCaches sync.Map
+
+// googleCachePrefixLength returns how many leading messages to put in an
+// explicit context cache: the first turn, which holds the initial instructions
+// and context that are re-sent with every request in a chat. It's 0 when there
+// is nothing after the first turn, as there's nothing to reuse the cache yet.
+func googleCachePrefixLength(messages []Message) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	role := googleRole(messages[0].Role)
+	n := 1
+	for n < len(messages) && googleRole(messages[n].Role) == role {
+		n++
+	}
+	if n == len(messages) {
+		return 0
+	}
+	return n
+}
+
+// googleMinCacheTokens is the smallest prefix the Gemini API accepts for an
+// explicit context cache, which depends on the model
+func googleMinCacheTokens(model string) int {
+	if strings.Contains(strings.ToLower(model), "pro") {
+		return 4096
+	}
+	return 1024
+}
+
+// googleContextCache returns the name of a context cache holding the given
+// prompt prefix along with the tools, creating the cache if there is none yet.
+// It also returns the number of tokens written to the cache, which is 0 when
+// an existing cache was reused. The name is empty when the prefix is too small
+// to cache.
+func googleContextCache(ctx context.Context, client *genai.Client, apiKey, model string, prefix []*genai.Content, tools []*genai.Tool, toolConfig *genai.ToolConfig) (string, int, error) {
+	prefixJson, err := json.Marshal(struct {
+		Contents   []*genai.Content  `json:"contents"`
+		Tools      []*genai.Tool     `json:"tools,omitempty"`
+		ToolConfig *genai.ToolConfig `json:"toolConfig,omitempty"`
+	}{prefix, tools, toolConfig})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to marshal context cache prefix: %w", err)
+	}
+	// a rough estimate of 4 bytes per token is enough to skip creating caches
+	// that would be rejected
+	if len(prefixJson)/4 < googleMinCacheTokens(model) {
+		return "", 0, nil
+	}
+
+	// caches are scoped to the project the API key belongs to
+	hash := sha256.New()
+	hash.Write([]byte(apiKey))
+	hash.Write([]byte{0})
+	hash.Write([]byte(model))
+	hash.Write([]byte{0})
+	hash.Write(prefixJson)
+	cacheKey := hex.EncodeToString(hash.Sum(nil))
+
+	if cached, ok := googleContextCaches.Load(cacheKey); ok {
+		cachedContent := cached.(googleCachedContent)
+		if time.Now().Add(time.Minute).Before(cachedContent.expiresAt) {
+			return cachedContent.name, 0, nil
+		}
+	}
+
+	created, err := client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		TTL:         googleContextCacheTTL,
+		DisplayName: "sidekick-" + cacheKey[:16],
+		Contents:    prefix,
+		Tools:       tools,
+		ToolConfig:  toolConfig,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create context cache: %w", err)
+	}
+
+	expiresAt := created.ExpireTime
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(googleContextCacheTTL)
+	}
+	googleContextCaches.Store(cacheKey, googleCachedContent{name: created.Name, expiresAt: expiresAt})
+
+	writtenTokens := 0
+	if created.UsageMetadata != nil {
+		writtenTokens = int(created.UsageMetadata.TotalTokenCount)
+	}
+	return created.Name, writtenTokens, nil
+}
+
+// forgetGoogleContextCache drops a context cache that failed to be used, e.g.
+// because it was deleted, so that the next request creates a new one
+func forgetGoogleContextCache(name string) {
+	googleContextCaches.Range(func(key, value any) bool {
+		if value.(googleCachedContent).name == name {
+			googleContextCaches.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,177 @@
+package llm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleCachePrefixLength(t *testing.T) {
+	t.Parallel()
+
+	user := Message{Role: RoleUser, Content: TextContentBlocks("hi")}
+	system := Message{Role: RoleSystem, Content: TextContentBlocks("instructions")}
+	assistant := Message{Role: RoleAssistant, Content: TextContentBlocks("hello")}
+
+	assert.Equal(t, 0, googleCachePrefixLength(nil))
+	assert.Equal(t, 0, googleCachePrefixLength([]Message{system, user}))
+	assert.Equal(t, 2, googleCachePrefixLength([]Message{system, user, assistant}))
+	assert.Equal(t, 1, googleCachePrefixLength([]Message{user, assistant, user}))
+}
+
+// newGeminiTestServer stands in for the Gemini API, capturing the last
+// generate request and counting context caches created
+func newGeminiTestServer(t *testing.T, capturedBody *map[string]any, cachesCreated *atomic.Int32) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1beta/cachedContents", func(w http.ResponseWriter, r *http.Request) {
+		n := cachesCreated.Add(1)
+		fmt.Fprintf(w, `{"name":"cachedContents/cache-%d","model":"models/gemini-2.5-flash","expireTime":"2999-01-01T00:00:00Z","usageMetadata":{"totalTokenCount":3000}}`, n)
+	})
+	mux.HandleFunc("POST /v1beta/models/{model}", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		*capturedBody = nil
+		require.NoError(t, json.Unmarshal(body, capturedBody))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hello from Gemini\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":3100,\"candidatesTokenCount\":4,\"cachedContentTokenCount\":3000}}\n\n")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	originalBaseURL := googleBaseURL
+	googleBaseURL = server.URL
+	t.Cleanup(func() { googleBaseURL = originalBaseURL })
+}
+
+func TestGoogleProvider_ContextCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("SIDE_CACHE_HOME", cacheDir)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "models.dev.json"), []byte("{}"), 0644))
+	common.ClearModelsCache()
+	t.Cleanup(common.ClearModelsCache)
+
+	var capturedBody map[string]any
+	var cachesCreated atomic.Int32
+	newGeminiTestServer(t, &capturedBody, &cachesCreated)
+
+	tools := []*common.Tool{{Name: "search", Description: "Search the code"}}
+	stream := func(messages []Message) *MessageResponse {
+		eventChan := make(chan Event, 100)
+		defer close(eventChan)
+		response, err := GoogleProvider{}.Stream(context.Background(), StreamRequest{
+			Messages: messages,
+			Options: Options{
+				ModelConfig: common.ModelConfig{Provider: "google", Model: "gemini-2.5-flash"},
+				Tools:       tools,
+			},
+			SecretManager: secret_manager.MockSecretManager{},
+		}, eventChan)
+		require.NoError(t, err)
+		return response
+	}
+
+	largeContext := Message{Role: RoleUser, Content: TextContentBlocks(strings.Repeat("func main() {}\n", 1000))}
+	messages := []Message{
+		largeContext,
+		{Role: RoleAssistant, Content: TextContentBlocks("Looking")},
+		{Role: RoleUser, Content: TextContentBlocks("Go on")},
+	}
+
+	response := stream(messages)
+	assert.Equal(t, "Hello from Gemini", response.Output.GetContentString())
+	assert.Equal(t, int32(1), cachesCreated.Load())
+	assert.Equal(t, "cachedContents/cache-1", capturedBody["cachedContent"])
+	assert.NotContains(t, capturedBody, "tools")
+	assert.NotContains(t, capturedBody, "toolConfig")
+	assert.Len(t, capturedBody["contents"], 2, "the cached first turn is not sent again")
+	assert.Equal(t, Usage{InputTokens: 6100, OutputTokens: 4, CacheReadInputTokens: 3000, CacheWriteInputTokens: 3000}, response.Usage)
+
+	// the cache is reused by later requests with the same first turn
+	messages = append(messages, Message{Role: RoleAssistant, Content: TextContentBlocks("Done")}, Message{Role: RoleUser, Content: TextContentBlocks("Thanks")})
+	response = stream(messages)
+	assert.Equal(t, int32(1), cachesCreated.Load())
+	assert.Equal(t, "cachedContents/cache-1", capturedBody["cachedContent"])
+	assert.Len(t, capturedBody["contents"], 4)
+	assert.Equal(t, 0, response.Usage.CacheWriteInputTokens)
+
+	// small prompts aren't cached
+	stream([]Message{
+		{Role: RoleUser, Content: TextContentBlocks("hi")},
+		{Role: RoleAssistant, Content: TextContentBlocks("hello")},
+		{Role: RoleUser, Content: TextContentBlocks("bye")},
+	})
+	assert.Equal(t, int32(1), cachesCreated.Load())
+	assert.NotContains(t, capturedBody, "cachedContent")
+	assert.Contains(t, capturedBody, "tools")
+	assert.Len(t, capturedBody["contents"], 3)
+}
+
+func TestGoogleProvider_ContextCacheRetriesWithoutCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("SIDE_CACHE_HOME", cacheDir)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "models.dev.json"), []byte("{}"), 0644))
+	common.ClearModelsCache()
+	t.Cleanup(common.ClearModelsCache)
+
+	var requestBodies []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1beta/cachedContents", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name":"cachedContents/expired","model":"models/gemini-2.5-flash","expireTime":"2999-01-01T00:00:00Z","usageMetadata":{"totalTokenCount":3000}}`)
+	})
+	mux.HandleFunc("POST /v1beta/models/{model}", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requestBodies = append(requestBodies, body)
+		if body["cachedContent"] != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"code":403,"message":"CachedContent not found (or permission denied)","status":"PERMISSION_DENIED"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hello from Gemini\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":3100,\"candidatesTokenCount\":4}}\n\n")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	originalBaseURL := googleBaseURL
+	googleBaseURL = server.URL
+	t.Cleanup(func() { googleBaseURL = originalBaseURL })
+
+	eventChan := make(chan Event, 100)
+	defer close(eventChan)
+	response, err := GoogleProvider{}.Stream(context.Background(), StreamRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: TextContentBlocks(strings.Repeat("package main\n", 1000))},
+			{Role: RoleAssistant, Content: TextContentBlocks("Looking")},
+			{Role: RoleUser, Content: TextContentBlocks("Go on")},
+		},
+		Options: Options{
+			ModelConfig: common.ModelConfig{Provider: "google", Model: "gemini-2.5-flash"},
+			Tools:       []*common.Tool{{Name: "search", Description: "Search the code"}},
+		},
+		SecretManager: secret_manager.MockSecretManager{},
+	}, eventChan)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from Gemini", response.Output.GetContentString())
+
+	require.Len(t, requestBodies, 2)
+	assert.Equal(t, "cachedContents/expired", requestBodies[0]["cachedContent"])
+	// the retry sends the whole prompt, including tools, instead
+	assert.NotContains(t, requestBodies[1], "cachedContent")
+	assert.Contains(t, requestBodies[1], "tools")
+	assert.Len(t, requestBodies[1]["contents"], 3)
+}
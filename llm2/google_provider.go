@@ -27,6 +27,10 @@ var googleLegacyThinkingBudgetLlm2 = map[string]int32{
 	"high":   24576,
 }
 
+// googleBaseURL overrides the Gemini API endpoint when set. It's a variable so
+// tests can stand in for the API.
+var googleBaseURL = ""
+
 type GoogleProvider struct {
 	AuthType      common.ProviderAuthType
 	CustomHeaders map[string]string
@@ -88,9 +92,10 @@ func (p GoogleProvider) Stream(ctx context.Context, request StreamRequest, event
 		}
 	}
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:     apiKey,
-		Backend:    genai.BackendGeminiAPI,
-		HTTPClient: httpClient,
+		APIKey:      apiKey,
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  httpClient,
+		HTTPOptions: genai.HTTPOptions{BaseURL: googleBaseURL},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create %s client: %w", providerName, err)
@@ -104,6 +109,16 @@ func (p GoogleProvider) Stream(ctx context.Context, request StreamRequest, event
 	modelInfo, _ := common.GetModel(options.Provider, model)
 	isReasoningModel := modelInfo != nil && modelInfo.Reasoning
 
+	// gemini models before 3 can't combine a JSON response schema with
+	// function calling, so they get the schema in the prompt instead
+	nativeResponseFormat := options.ResponseFormat != nil && (len(options.Tools) == 0 || strings.Contains(model, "gemini-3"))
+	if options.ResponseFormat != nil && !nativeResponseFormat {
+		messages, err = options.ResponseFormat.withInstructions(messages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	contents, err := googleFromLlm2Messages(messages, isReasoningModel, model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert messages: %w", err)
@@ -164,30 +179,52 @@ func (p GoogleProvider) Stream(ctx context.Context, request StreamRequest, event
 		config.MaxOutputTokens = int32(options.MaxTokens)
 	}
 
-	stream := client.Models.GenerateContentStream(ctx, model, contents, config)
-
-	var events []Event
-	var lastResult *genai.GenerateContentResponse
-	state := &googleStreamState{}
-
-	for result, err := range stream {
+	if nativeResponseFormat {
+		schema, err := options.ResponseFormat.schemaMap()
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate on %s stream: %w", providerName, err)
+			return nil, err
 		}
-		lastResult = result
-
-		newEvents := googleResultToEvents(result, state)
-		for _, ev := range newEvents {
-			events = append(events, ev)
-			eventChan <- ev
+		config.ResponseMIMEType = "application/json"
+		config.ResponseJsonSchema = schema
+	}
+
+	// the first turn is cached explicitly, since implicit caching isn't
+	// guaranteed to hit. Tools must be cached along with it.
+	cacheWriteTokens := 0
+	uncachedContents, uncachedTools, uncachedToolConfig := contents, config.Tools, config.ToolConfig
+	if prefixLength := googleCachePrefixLength(messages); prefixLength > 0 {
+		prefixContents, prefixErr := googleFromLlm2Messages(messages[:prefixLength], isReasoningModel, model)
+		restContents, restErr := googleFromLlm2Messages(messages[prefixLength:], isReasoningModel, model)
+		if prefixErr == nil && restErr == nil {
+			cacheName, writtenTokens, err := googleContextCache(ctx, client, apiKey, model, prefixContents, config.Tools, config.ToolConfig)
+			if err != nil {
+				log.Warn().Err(err).Str("model", model).Msg("continuing without a context cache")
+			} else if cacheName != "" {
+				config.CachedContent = cacheName
+				config.Tools = nil
+				config.ToolConfig = nil
+				contents = restContents
+				cacheWriteTokens = writtenTokens
+			}
 		}
 	}
 
-	// Finalize any open blocks
-	finalEvents := googleFinalizeStream(state)
-	for _, ev := range finalEvents {
-		events = append(events, ev)
-		eventChan <- ev
+	events, lastResult, err := googleStream(ctx, client, model, contents, config, eventChan)
+	if err != nil && config.CachedContent != "" {
+		forgetGoogleContextCache(config.CachedContent)
+		// the cache may have expired or been deleted since it was looked up,
+		// so try once more without it, unless the reply already started
+		if len(events) > 0 {
+			return nil, fmt.Errorf("failed to iterate on %s stream: %w", providerName, err)
+		}
+		log.Warn().Err(err).Str("model", model).Msg("retrying without the context cache")
+		config.CachedContent = ""
+		config.Tools = uncachedTools
+		config.ToolConfig = uncachedToolConfig
+		events, lastResult, err = googleStream(ctx, client, model, uncachedContents, config, eventChan)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate on %s stream: %w", providerName, err)
 	}
 
 	output := accumulateGoogleEventsToMessage(events)
@@ -198,6 +235,9 @@ func (p GoogleProvider) Stream(ctx context.Context, request StreamRequest, event
 		usage.OutputTokens = int(lastResult.UsageMetadata.CandidatesTokenCount) + int(lastResult.UsageMetadata.ThoughtsTokenCount)
 		usage.CacheReadInputTokens = int(lastResult.UsageMetadata.CachedContentTokenCount)
 	}
+	// creating a context cache is billed as input, on top of reading from it
+	usage.InputTokens += cacheWriteTokens
+	usage.CacheWriteInputTokens = cacheWriteTokens
 
 	stopReason := ""
 	if lastResult != nil && len(lastResult.Candidates) > 0 {
@@ -215,6 +255,36 @@ func (p GoogleProvider) Stream(ctx context.Context, request StreamRequest, event
 	}, nil
 }
 
+// googleStream streams a reply, sending its events to eventChan. The events
+// sent are returned along with the last streamed response, including when the
+// stream fails part way.
+func googleStream(ctx context.Context, client *genai.Client, model string, contents []*genai.Content, config *genai.GenerateContentConfig, eventChan chan<- Event) ([]Event, *genai.GenerateContentResponse, error) {
+	var events []Event
+	var lastResult *genai.GenerateContentResponse
+	state := &googleStreamState{}
+
+	for result, err := range client.Models.GenerateContentStream(ctx, model, contents, config) {
+		if err != nil {
+			return events, lastResult, err
+		}
+		lastResult = result
+
+		newEvents := googleResultToEvents(result, state)
+		for _, ev := range newEvents {
+			events = append(events, ev)
+			eventChan <- ev
+		}
+	}
+
+	// Finalize any open blocks
+	finalEvents := googleFinalizeStream(state)
+	for _, ev := range finalEvents {
+		events = append(events, ev)
+		eventChan <- ev
+	}
+	return events, lastResult, nil
+}
+
 // googleStreamState tracks the current streaming state across multiple
 // GenerateContentResponse chunks to properly coalesce text deltas.
 type googleStreamState struct {
@@ -517,6 +587,13 @@ func mimeTypeFromImageURL(rawURL string) string {
 	}
 }
 
+func googleRole(role Role) string {
+	if role == RoleAssistant {
+		return "model"
+	}
+	return "user"
+}
+
 func googleFromLlm2Messages(messages []Message, isReasoningModel bool, model string) ([]*genai.Content, error) {
 	var contents []*genai.Content
 	var currentRole string
@@ -533,15 +610,7 @@ func googleFromLlm2Messages(messages []Message, isReasoningModel bool, model str
 	}
 
 	for _, msg := range messages {
-		var role string
-		switch msg.Role {
-		case RoleUser, RoleSystem:
-			role = "user"
-		case RoleAssistant:
-			role = "model"
-		default:
-			role = "user"
-		}
+		role := googleRole(msg.Role)
 
 		if role != currentRole && currentRole != "" {
 			addContent()
@@ -0,0 +1,463 @@
+package llm2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sidekick/common"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// OllamaProvider streams chat responses from an ollama server via its native
+// chat API, which, unlike its OpenAI-compatible API, supports images and
+// thinking together with tool calls.
+type OllamaProvider struct {
+	BaseURL       string
+	DefaultModel  string
+	CustomHeaders map[string]string
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Think    *bool           `json:"think,omitempty"`
+	// Format is a JSON schema constraining the reply
+	Format  map[string]any `json:"format,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Id       string             `json:"id,omitempty"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaToolFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type ollamaTool struct {
+	Type     string               `json:"type"`
+	Function ollamaToolDefinition `json:"function"`
+}
+
+type ollamaToolDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p OllamaProvider) Stream(ctx context.Context, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
+	options := request.Options
+
+	model := options.Model
+	if model == "" {
+		model = p.DefaultModel
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no model given for ollama provider %s and no default_llm configured", options.Provider)
+	}
+
+	chatMessages, err := ollamaFromMessages(request.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build messages: %w", err)
+	}
+
+	chatRequest := ollamaChatRequest{
+		Model:    model,
+		Messages: chatMessages,
+		Stream:   true,
+		Options:  map[string]any{},
+	}
+
+	if options.ResponseFormat != nil {
+		chatRequest.Format, err = options.ResponseFormat.schemaMap()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(options.Tools) > 0 {
+		// ollama can't be made to use a tool, but we can at least limit which
+		// tool it may use
+		toolsToUse := options.Tools
+		if options.ToolChoice.Type == common.ToolChoiceTypeTool {
+			toolsToUse = filterToolsByName(options.Tools, options.ToolChoice.Name)
+		}
+		chatRequest.Tools, err = ollamaFromTools(toolsToUse)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert tools: %w", err)
+		}
+	}
+
+	if options.Temperature != nil {
+		chatRequest.Options["temperature"] = *options.Temperature
+	}
+	if options.MaxTokens > 0 {
+		chatRequest.Options["num_predict"] = options.MaxTokens
+	}
+
+	// ollama uses a small context window unless told otherwise, so use the
+	// (capped) context length we budget prompts against
+	modelInfo, _ := common.GetModel(options.Provider, model)
+	if modelInfo != nil && modelInfo.Limit.Context > 0 {
+		chatRequest.Options["num_ctx"] = modelInfo.Limit.Context
+	}
+
+	actualReasoningEffort := ""
+	if modelInfo != nil && modelInfo.Reasoning && options.ReasoningEffort != "" {
+		think := options.ReasoningEffort != "none" && options.ReasoningEffort != "lowest"
+		chatRequest.Think = &think
+		actualReasoningEffort = options.ReasoningEffort
+		if !think {
+			actualReasoningEffort = "none"
+		}
+	}
+
+	body, err := json.Marshal(chatRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama request: %w", err)
+	}
+
+	chatURL := common.OllamaBaseURL(p.BaseURL) + "/api/chat"
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, chatURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	// ollama itself has no authentication, but may be behind a proxy that does
+	if request.SecretManager != nil {
+		providerNameNormalized := options.ModelConfig.NormalizedProviderName()
+		if token, err := request.SecretManager.GetSecret(fmt.Sprintf("%s_API_KEY", providerNameNormalized)); err == nil && token != "" {
+			httpRequest.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	for k, v := range p.CustomHeaders {
+		httpRequest.Header.Set(k, v)
+	}
+
+	httpClient := &http.Client{Timeout: 45 * time.Minute}
+	resp, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ollama at %s: %w", chatURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &apiStatusError{
+			message:    fmt.Sprintf("POST %q: %d - response body: %s", chatURL, resp.StatusCode, strings.TrimSpace(string(respBody))),
+			statusCode: resp.StatusCode,
+		}
+	}
+
+	state := &ollamaStreamState{openIndex: -1}
+	var events []Event
+	emit := func(newEvents []Event) {
+		for _, evt := range newEvents {
+			eventChan <- evt
+			events = append(events, evt)
+		}
+	}
+
+	var final ollamaChatResponse
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var chunk ollamaChatResponse
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				return nil, fmt.Errorf("failed to parse ollama stream chunk: %w", err)
+			}
+			if chunk.Error != "" {
+				return nil, fmt.Errorf("ollama error: %s", chunk.Error)
+			}
+			emit(ollamaChunkToEvents(state, chunk.Message))
+			if chunk.Done {
+				final = chunk
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read ollama stream: %w", readErr)
+		}
+	}
+	if !final.Done {
+		return nil, fmt.Errorf("ollama stream ended before the response was done")
+	}
+	emit(state.closeOpenBlock())
+
+	stopReason := final.DoneReason
+	if state.hasToolCalls {
+		stopReason = "tool_calls"
+	} else if stopReason == "" {
+		stopReason = "stop"
+	}
+
+	responseModel := final.Model
+	if responseModel == "" {
+		responseModel = model
+	}
+
+	return &MessageResponse{
+		Model:      responseModel,
+		Provider:   options.Provider,
+		Output:     accumulateGoogleEventsToMessage(events),
+		StopReason: stopReason,
+		Usage: Usage{
+			InputTokens:  final.PromptEvalCount,
+			OutputTokens: final.EvalCount,
+		},
+		ReasoningEffort: actualReasoningEffort,
+	}, nil
+}
+
+// ollamaStreamState tracks the block that streamed text is appended to.
+// Thinking and content stream as separate fields of each chunk, while tool
+// calls always arrive whole.
+type ollamaStreamState struct {
+	openIndex    int
+	openType     ContentBlockType
+	nextIndex    int
+	hasToolCalls bool
+}
+
+func (s *ollamaStreamState) closeOpenBlock() []Event {
+	if s.openIndex < 0 {
+		return nil
+	}
+	evt := Event{Type: EventBlockDone, Index: s.openIndex}
+	s.openIndex = -1
+	return []Event{evt}
+}
+
+func (s *ollamaStreamState) appendText(blockType ContentBlockType, text string) []Event {
+	var events []Event
+	if s.openIndex < 0 || s.openType != blockType {
+		events = append(events, s.closeOpenBlock()...)
+		block := &ContentBlock{Type: blockType}
+		if blockType == ContentBlockTypeReasoning {
+			block.Reasoning = &ReasoningBlock{}
+		}
+		s.openIndex = s.nextIndex
+		s.openType = blockType
+		s.nextIndex++
+		events = append(events, Event{Type: EventBlockStarted, Index: s.openIndex, ContentBlock: block})
+	}
+	return append(events, Event{Type: EventTextDelta, Index: s.openIndex, Delta: text})
+}
+
+func ollamaChunkToEvents(state *ollamaStreamState, message ollamaMessage) []Event {
+	var events []Event
+	if message.Thinking != "" {
+		events = append(events, state.appendText(ContentBlockTypeReasoning, message.Thinking)...)
+	}
+	if message.Content != "" {
+		events = append(events, state.appendText(ContentBlockTypeText, message.Content)...)
+	}
+	for _, toolCall := range message.ToolCalls {
+		events = append(events, state.closeOpenBlock()...)
+		id := toolCall.Id
+		if id == "" {
+			id = "call_" + ksuid.New().String()
+		}
+		arguments := string(toolCall.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		index := state.nextIndex
+		state.nextIndex++
+		state.hasToolCalls = true
+		events = append(events,
+			Event{
+				Type:  EventBlockStarted,
+				Index: index,
+				ContentBlock: &ContentBlock{
+					Id:      id,
+					Type:    ContentBlockTypeToolUse,
+					ToolUse: &ToolUseBlock{Id: id, Name: toolCall.Function.Name},
+				},
+			},
+			Event{Type: EventTextDelta, Index: index, Delta: arguments},
+			Event{Type: EventBlockDone, Index: index},
+		)
+	}
+	return events
+}
+
+func ollamaFromMessages(messages []Message) ([]ollamaMessage, error) {
+	var result []ollamaMessage
+	// tool results are matched to tool calls by name rather than id
+	toolNames := make(map[string]string)
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			var sb strings.Builder
+			for _, block := range msg.Content {
+				if block.Type == ContentBlockTypeText {
+					sb.WriteString(block.Text)
+				}
+			}
+			result = append(result, ollamaMessage{Role: "system", Content: sb.String()})
+
+		case RoleUser:
+			userMsg := ollamaMessage{Role: "user"}
+			var texts []string
+			for _, block := range msg.Content {
+				switch block.Type {
+				case ContentBlockTypeText:
+					texts = append(texts, block.Text)
+				case ContentBlockTypeImage:
+					if block.Image == nil {
+						return nil, fmt.Errorf("image block missing Image data")
+					}
+					image, err := ollamaFromImageURL(block.Image.Url)
+					if err != nil {
+						return nil, err
+					}
+					userMsg.Images = append(userMsg.Images, image)
+				case ContentBlockTypeToolResult:
+					if block.ToolResult == nil {
+						return nil, fmt.Errorf("tool_result block missing ToolResult data")
+					}
+					toolName := block.ToolResult.Name
+					if toolName == "" {
+						toolName = toolNames[block.ToolResult.ToolCallId]
+					}
+					toolMsg := ollamaMessage{
+						Role:     "tool",
+						Content:  block.ToolResult.TextContent(),
+						ToolName: toolName,
+					}
+					for _, cb := range block.ToolResult.Content {
+						if cb.Type == ContentBlockTypeImage && cb.Image != nil {
+							image, err := ollamaFromImageURL(cb.Image.Url)
+							if err != nil {
+								return nil, err
+							}
+							toolMsg.Images = append(toolMsg.Images, image)
+						}
+					}
+					result = append(result, toolMsg)
+				default:
+					return nil, fmt.Errorf("unsupported content block type %s for user role", block.Type)
+				}
+			}
+			if len(texts) > 0 || len(userMsg.Images) > 0 {
+				userMsg.Content = strings.Join(texts, "\n\n")
+				result = append(result, userMsg)
+			}
+
+		case RoleAssistant:
+			assistantMsg := ollamaMessage{Role: "assistant"}
+			var texts []string
+			for _, block := range msg.Content {
+				switch block.Type {
+				case ContentBlockTypeText:
+					texts = append(texts, block.Text)
+				case ContentBlockTypeReasoning:
+					if block.Reasoning != nil {
+						assistantMsg.Thinking += block.Reasoning.Text
+					}
+				case ContentBlockTypeToolUse:
+					if block.ToolUse == nil {
+						return nil, fmt.Errorf("tool_use block missing ToolUse data")
+					}
+					toolNames[block.ToolUse.Id] = block.ToolUse.Name
+					arguments := json.RawMessage(block.ToolUse.Arguments)
+					if !json.Valid(arguments) {
+						arguments = json.RawMessage("{}")
+					}
+					assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, ollamaToolCall{
+						Id: block.ToolUse.Id,
+						Function: ollamaToolFunction{
+							Name:      block.ToolUse.Name,
+							Arguments: arguments,
+						},
+					})
+				case ContentBlockTypeRefusal:
+					if block.Refusal != nil {
+						texts = append(texts, block.Refusal.Reason)
+					}
+				default:
+					return nil, fmt.Errorf("unsupported content block type %s for assistant role", block.Type)
+				}
+			}
+			assistantMsg.Content = strings.Join(texts, "")
+			if assistantMsg.Content != "" || assistantMsg.Thinking != "" || len(assistantMsg.ToolCalls) > 0 {
+				result = append(result, assistantMsg)
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported role: %s", msg.Role)
+		}
+	}
+
+	return result, nil
+}
+
+// ollamaFromImageURL converts an image data URL to the raw base64 data ollama
+// expects. Remote image URLs aren't supported, as ollama doesn't fetch them.
+func ollamaFromImageURL(url string) (string, error) {
+	if !strings.HasPrefix(url, "data:") {
+		return "", fmt.Errorf("unsupported image URL for ollama, only data URLs are supported: %s", url)
+	}
+	_, _, data, err := PrepareImageDataURLForLimits(url, 20*1024*1024, 2048)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare image for ollama: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func ollamaFromTools(tools []*common.Tool) ([]ollamaTool, error) {
+	result := make([]ollamaTool, 0, len(tools))
+	for _, tool := range tools {
+		params, err := jsonSchemaToMap(tool.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert parameters for tool %s: %w", tool.Name, err)
+		}
+		result = append(result, ollamaTool{
+			Type: "function",
+			Function: ollamaToolDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  params,
+			},
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,241 @@
+package llm2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOllamaTestServer stands in for an ollama server, responding to chat
+// requests with the given newline-delimited JSON chunks and capturing the
+// request body
+func newOllamaTestServer(t *testing.T, chunks []string, captured *ollamaChatRequest) *httptest.Server {
+	t.Helper()
+	// avoid fetching models.dev when looking up model info
+	cacheHome := t.TempDir()
+	t.Setenv("SIDE_CACHE_HOME", cacheHome)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheHome, "models.dev.json"), []byte("{}"), 0644))
+	common.ClearModelsCache()
+	t.Cleanup(common.ClearModelsCache)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if captured != nil {
+			require.NoError(t, json.Unmarshal(body, captured))
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		for _, chunk := range chunks {
+			fmt.Fprintln(w, chunk)
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func streamOllamaTest(t *testing.T, provider OllamaProvider, request StreamRequest) (*MessageResponse, []Event, error) {
+	t.Helper()
+	eventChan := make(chan Event, 100)
+	response, err := provider.Stream(context.Background(), request, eventChan)
+	close(eventChan)
+	var events []Event
+	for evt := range eventChan {
+		events = append(events, evt)
+	}
+	return response, events, err
+}
+
+func TestOllamaProvider_StreamTextAndThinking(t *testing.T) {
+	var captured ollamaChatRequest
+	server := newOllamaTestServer(t, []string{
+		`{"model":"qwen3:8b","message":{"role":"assistant","content":"","thinking":"Let me "},"done":false}`,
+		`{"model":"qwen3:8b","message":{"role":"assistant","content":"","thinking":"think."},"done":false}`,
+		`{"model":"qwen3:8b","message":{"role":"assistant","content":"Hello"},"done":false}`,
+		`{"model":"qwen3:8b","message":{"role":"assistant","content":" world"},"done":false}`,
+		`{"model":"qwen3:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":7}`,
+	}, &captured)
+
+	temperature := float32(0.2)
+	response, events, err := streamOllamaTest(t, OllamaProvider{BaseURL: server.URL, DefaultModel: "qwen3:8b"}, StreamRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: TextContentBlocks("Be brief.")},
+			{Role: RoleUser, Content: TextContentBlocks("Hi")},
+		},
+		Options: Options{
+			Temperature: &temperature,
+			MaxTokens:   100,
+			ModelConfig: common.ModelConfig{Provider: "ollama"},
+		},
+		SecretManager: &secret_manager.MockSecretManager{},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "qwen3:8b", captured.Model)
+	assert.True(t, captured.Stream)
+	assert.Equal(t, []ollamaMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+	}, captured.Messages)
+	assert.InDelta(t, 0.2, captured.Options["temperature"], 0.0001)
+	assert.Equal(t, float64(100), captured.Options["num_predict"])
+
+	assert.Equal(t, "qwen3:8b", response.Model)
+	assert.Equal(t, "ollama", response.Provider)
+	assert.Equal(t, "stop", response.StopReason)
+	assert.Equal(t, Usage{InputTokens: 12, OutputTokens: 7}, response.Usage)
+
+	require.Len(t, response.Output.Content, 2)
+	assert.Equal(t, ContentBlockTypeReasoning, response.Output.Content[0].Type)
+	assert.Equal(t, "Let me think.", response.Output.Content[0].Reasoning.Text)
+	assert.Equal(t, ContentBlockTypeText, response.Output.Content[1].Type)
+	assert.Equal(t, "Hello world", response.Output.Content[1].Text)
+
+	var eventTypes []EventType
+	for _, evt := range events {
+		eventTypes = append(eventTypes, evt.Type)
+	}
+	assert.Equal(t, []EventType{
+		EventBlockStarted, EventTextDelta, EventTextDelta, EventBlockDone,
+		EventBlockStarted, EventTextDelta, EventTextDelta, EventBlockDone,
+	}, eventTypes)
+}
+
+func TestOllamaProvider_ToolCalls(t *testing.T) {
+	var captured ollamaChatRequest
+	server := newOllamaTestServer(t, []string{
+		`{"model":"llama3.1","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}},{"id":"call_given","function":{"name":"get_time","arguments":{}}}]},"done":false}`,
+		`{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":30,"eval_count":10}`,
+	}, &captured)
+
+	type weatherParams struct {
+		City string `json:"city"`
+	}
+	tool := &common.Tool{
+		Name:        "get_weather",
+		Description: "Gets the weather",
+		Parameters:  (&jsonschema.Reflector{DoNotReference: true}).Reflect(&weatherParams{}),
+	}
+	response, _, err := streamOllamaTest(t, OllamaProvider{BaseURL: server.URL}, StreamRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: TextContentBlocks("Weather in Lyon?")},
+			{Role: RoleAssistant, Content: []ContentBlock{
+				{Type: ContentBlockTypeReasoning, Reasoning: &ReasoningBlock{Text: "Need weather."}},
+				{Type: ContentBlockTypeToolUse, ToolUse: &ToolUseBlock{Id: "call_1", Name: "get_weather", Arguments: `{"city":"Lyon"}`}},
+			}},
+			{Role: RoleUser, Content: []ContentBlock{
+				{Type: ContentBlockTypeToolResult, ToolResult: &ToolResultBlock{ToolCallId: "call_1", Content: TextContentBlocks("Sunny")}},
+				{Type: ContentBlockTypeText, Text: "And Paris?"},
+			}},
+		},
+		Options: Options{
+			Tools:       []*common.Tool{tool},
+			ToolChoice:  common.ToolChoice{Type: common.ToolChoiceTypeAuto},
+			ModelConfig: common.ModelConfig{Provider: "ollama", Model: "llama3.1"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, captured.Tools, 1)
+	assert.Equal(t, "function", captured.Tools[0].Type)
+	assert.Equal(t, "get_weather", captured.Tools[0].Function.Name)
+	assert.Equal(t, "object", captured.Tools[0].Function.Parameters["type"])
+
+	require.Len(t, captured.Messages, 4)
+	assert.Equal(t, "assistant", captured.Messages[1].Role)
+	assert.Equal(t, "Need weather.", captured.Messages[1].Thinking)
+	require.Len(t, captured.Messages[1].ToolCalls, 1)
+	assert.JSONEq(t, `{"city":"Lyon"}`, string(captured.Messages[1].ToolCalls[0].Function.Arguments))
+	// tool results are matched by tool name, found from the tool call
+	assert.Equal(t, ollamaMessage{Role: "tool", Content: "Sunny", ToolName: "get_weather"}, captured.Messages[2])
+	assert.Equal(t, ollamaMessage{Role: "user", Content: "And Paris?"}, captured.Messages[3])
+
+	assert.Equal(t, "tool_calls", response.StopReason)
+	toolCalls := response.Output.GetToolCalls()
+	require.Len(t, toolCalls, 2)
+	assert.Equal(t, "get_weather", toolCalls[0].Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Arguments)
+	assert.NotEmpty(t, toolCalls[0].Id)
+	assert.Equal(t, common.ToolCall{Id: "call_given", Name: "get_time", Arguments: "{}"}, toolCalls[1])
+}
+
+func TestOllamaProvider_ImageInput(t *testing.T) {
+	var captured ollamaChatRequest
+	server := newOllamaTestServer(t, []string{
+		`{"model":"llava","message":{"role":"assistant","content":"It says OK."},"done":true,"done_reason":"stop"}`,
+	}, &captured)
+
+	rawImage := RenderTextImage("OK", 24)
+	dataURL := BuildDataURL("image/png", rawImage)
+
+	response, _, err := streamOllamaTest(t, OllamaProvider{BaseURL: server.URL}, StreamRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: []ContentBlock{
+				{Type: ContentBlockTypeText, Text: "What is this?"},
+				{Type: ContentBlockTypeImage, Image: &ImageRef{Url: dataURL}},
+			}},
+		},
+		Options: Options{ModelConfig: common.ModelConfig{Provider: "ollama", Model: "llava"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "It says OK.", response.Output.GetContentString())
+
+	require.Len(t, captured.Messages, 1)
+	assert.Equal(t, "What is this?", captured.Messages[0].Content)
+	assert.Equal(t, []string{base64.StdEncoding.EncodeToString(rawImage)}, captured.Messages[0].Images)
+
+	_, _, err = streamOllamaTest(t, OllamaProvider{BaseURL: server.URL}, StreamRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: []ContentBlock{
+				{Type: ContentBlockTypeImage, Image: &ImageRef{Url: "https://example.com/image.png"}},
+			}},
+		},
+		Options: Options{ModelConfig: common.ModelConfig{Provider: "ollama", Model: "llava"}},
+	})
+	assert.ErrorContains(t, err, "only data URLs are supported")
+}
+
+func TestOllamaProvider_Errors(t *testing.T) {
+	server := newOllamaTestServer(t, []string{
+		`{"model":"llama3.1","message":{"role":"assistant","content":"Hel"},"done":false}`,
+		`{"error":"model runner has unexpectedly stopped"}`,
+	}, nil)
+	options := Options{ModelConfig: common.ModelConfig{Provider: "ollama", Model: "llama3.1"}}
+	messages := []Message{{Role: RoleUser, Content: TextContentBlocks("Hi")}}
+
+	_, _, err := streamOllamaTest(t, OllamaProvider{BaseURL: server.URL}, StreamRequest{Messages: messages, Options: options})
+	assert.ErrorContains(t, err, "model runner has unexpectedly stopped")
+
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"llama3.1\" not found, try pulling it first"}`)
+	}))
+	defer notFound.Close()
+	_, _, err = streamOllamaTest(t, OllamaProvider{BaseURL: notFound.URL}, StreamRequest{Messages: messages, Options: options})
+	assert.ErrorContains(t, err, "404")
+	assert.ErrorContains(t, err, "try pulling it first")
+
+	_, _, err = streamOllamaTest(t, OllamaProvider{BaseURL: server.URL}, StreamRequest{
+		Messages: messages,
+		Options:  Options{ModelConfig: common.ModelConfig{Provider: "ollama"}},
+	})
+	assert.ErrorContains(t, err, "no model given")
+}
@@ -72,7 +72,6 @@ func resolveOpenAIReasoningEffort(effort, model string) string {
 }
 
 func (p OpenAIProvider) Stream(ctx context.Context, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
-	messages := request.Messages
 	options := request.Options
 
 	providerNameNormalized := options.ModelConfig.NormalizedProviderName()
@@ -94,7 +93,6 @@ func (p OpenAIProvider) Stream(ctx context.Context, request StreamRequest, event
 	for k, v := range p.CustomHeaders {
 		clientOptions = append(clientOptions, option.WithHeader(k, v))
 	}
-	client := openai.NewClient(clientOptions...)
 
 	model := options.Model
 	if model == "" {
@@ -105,6 +103,16 @@ func (p OpenAIProvider) Stream(ctx context.Context, request StreamRequest, event
 		}
 	}
 
+	client := openai.NewClient(clientOptions...)
+	return streamChatCompletions(ctx, client.Chat.Completions, model, request, eventChan)
+}
+
+// streamChatCompletions streams a response from a chat completions API, shared
+// by providers that differ only in how the client is configured
+func streamChatCompletions(ctx context.Context, completions openai.ChatCompletionService, model string, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
+	messages := request.Messages
+	options := request.Options
+
 	chatMessages, err := messagesToChatCompletionParams(messages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build messages: %w", err)
@@ -149,12 +157,28 @@ func (p OpenAIProvider) Stream(ctx context.Context, request StreamRequest, event
 		params.ToolChoice = openaiChatFromToolChoice(options.ToolChoice, toolsToUse)
 	}
 
+	if options.ResponseFormat != nil {
+		schema, err := options.ResponseFormat.strictSchemaMap()
+		if err != nil {
+			return nil, err
+		}
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   options.ResponseFormat.name(),
+					Schema: schema,
+					Strict: openai.Bool(true),
+				},
+			},
+		}
+	}
+
 	var extraBodyOptions []option.RequestOption
 	for key, value := range options.ExtraBody {
 		extraBodyOptions = append(extraBodyOptions, option.WithJSONSet(key, value))
 	}
 
-	stream := client.Chat.Completions.NewStreaming(ctx, params, extraBodyOptions...)
+	stream := completions.NewStreaming(ctx, params, extraBodyOptions...)
 
 	var events []Event
 	var finishReason string
@@ -655,10 +679,14 @@ func wrapOpenAIError(err error) error {
 	// If the library successfully parsed the error body (e.g. standard OpenAI
 	// format), the Message field will be populated — use it directly.
 	if apiErr.Message != "" {
-		return fmt.Errorf("%s %q: %d %s (message: %s, type: %s, code: %s)",
-			apiErr.Request.Method, apiErr.Request.URL,
-			apiErr.StatusCode, apiErr.Type,
-			apiErr.Message, apiErr.Type, apiErr.Code)
+		return &apiStatusError{
+			message: fmt.Sprintf("%s %q: %d %s (message: %s, type: %s, code: %s)",
+				apiErr.Request.Method, apiErr.Request.URL,
+				apiErr.StatusCode, apiErr.Type,
+				apiErr.Message, apiErr.Type, apiErr.Code),
+			statusCode: apiErr.StatusCode,
+			err:        apiErr,
+		}
 	}
 
 	// Otherwise, dump the response to capture the raw body from non-standard
@@ -673,9 +701,13 @@ func wrapOpenAIError(err error) error {
 				break
 			}
 		}
-		return fmt.Errorf("%s %q: %d - response body: %s",
-			apiErr.Request.Method, apiErr.Request.URL,
-			apiErr.StatusCode, string(body))
+		return &apiStatusError{
+			message: fmt.Sprintf("%s %q: %d - response body: %s",
+				apiErr.Request.Method, apiErr.Request.URL,
+				apiErr.StatusCode, string(body)),
+			statusCode: apiErr.StatusCode,
+			err:        apiErr,
+		}
 	}
 
 	return err
@@ -111,6 +111,20 @@ func (p OpenAIResponsesProvider) Stream(ctx context.Context, request StreamReque
 
 	params.Store = openai.Bool(false)
 
+	if options.ResponseFormat != nil {
+		schema, err := options.ResponseFormat.strictSchemaMap()
+		if err != nil {
+			return nil, err
+		}
+		params.Text.Format = responses.ResponseFormatTextConfigUnionParam{
+			OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+				Name:   options.ResponseFormat.name(),
+				Schema: schema,
+				Strict: openai.Bool(true),
+			},
+		}
+	}
+
 	var actualReasoningEffort string
 	modelInfo, _ := common.GetModel(options.Provider, model)
 	if modelInfo != nil && modelInfo.Reasoning {
@@ -11,5 +11,7 @@ type Options struct {
 	ParallelToolCalls *bool             `json:"parallelToolCalls,omitempty"`
 	Temperature       *float32          `json:"temperature,omitempty"`
 	MaxTokens         int               `json:"maxTokens,omitempty"`
+	// ResponseFormat requests a JSON reply matching a schema, see StreamStructured
+	ResponseFormat *ResponseFormat `json:"responseFormat,omitempty"`
 	common.ModelConfig
 }
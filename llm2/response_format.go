@@ -0,0 +1,247 @@
+package llm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sidekick/llm"
+	"sidekick/utils"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// ResponseFormat constrains a model's text reply to JSON matching a schema.
+// Providers use their native structured outputs where the model supports
+// them, and otherwise include the schema in the prompt.
+type ResponseFormat struct {
+	// Name identifies the schema to providers that require one. It may only
+	// contain letters, digits, underscores and dashes.
+	Name   string             `json:"name"`
+	Schema *jsonschema.Schema `json:"schema"`
+}
+
+// NewResponseFormat returns a response format for the JSON encoding of T,
+// reflecting its schema the same way tool parameters are reflected
+func NewResponseFormat[T any](name string) *ResponseFormat {
+	return &ResponseFormat{
+		Name:   name,
+		Schema: (&jsonschema.Reflector{DoNotReference: true}).Reflect(new(T)),
+	}
+}
+
+func (f ResponseFormat) name() string {
+	if f.Name == "" {
+		return "response"
+	}
+	return f.Name
+}
+
+// schemaMap returns the schema as a map, without the $schema and $id keywords
+// that some providers reject
+func (f ResponseFormat) schemaMap() (map[string]any, error) {
+	schema, err := jsonSchemaToMap(f.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s response format schema: %w", f.name(), err)
+	}
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	return schema, nil
+}
+
+// strictSchemaMap returns the schema as a map, adapted to the subset of JSON
+// schema supported by OpenAI's strict structured outputs: objects don't allow
+// additional properties, and all their properties are required, with optional
+// ones made nullable instead. Null values of optional properties are dropped
+// before validating replies, so they still match the original schema.
+func (f ResponseFormat) strictSchemaMap() (map[string]any, error) {
+	schema, err := f.schemaMap()
+	if err != nil {
+		return nil, err
+	}
+	makeSchemaStrict(schema)
+	return schema, nil
+}
+
+func makeSchemaStrict(schema map[string]any) {
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		required := make(map[string]bool)
+		if list, ok := schema["required"].([]any); ok {
+			for _, name := range list {
+				if name, ok := name.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		names := make([]string, 0, len(properties))
+		for name, property := range properties {
+			names = append(names, name)
+			if property, ok := property.(map[string]any); ok && !required[name] {
+				makeSchemaNullable(property)
+			}
+		}
+		sort.Strings(names)
+		allRequired := make([]any, len(names))
+		for i, name := range names {
+			allRequired[i] = name
+		}
+		schema["required"] = allRequired
+		schema["additionalProperties"] = false
+	}
+
+	for _, key := range []string{"properties", "$defs", "definitions"} {
+		if subschemas, ok := schema[key].(map[string]any); ok {
+			for _, subschema := range subschemas {
+				if subschema, ok := subschema.(map[string]any); ok {
+					makeSchemaStrict(subschema)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		makeSchemaStrict(items)
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if subschemas, ok := schema[key].([]any); ok {
+			for _, subschema := range subschemas {
+				if subschema, ok := subschema.(map[string]any); ok {
+					makeSchemaStrict(subschema)
+				}
+			}
+		}
+	}
+}
+
+func makeSchemaNullable(schema map[string]any) {
+	switch schemaType := schema["type"].(type) {
+	case string:
+		if schemaType != "null" {
+			schema["type"] = []any{schemaType, "null"}
+		}
+	case []any:
+		if !slices.Contains(schemaType, any("null")) {
+			schema["type"] = append(schemaType, "null")
+		}
+	default:
+		if anyOf, ok := schema["anyOf"].([]any); ok {
+			schema["anyOf"] = append(anyOf, map[string]any{"type": "null"})
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, nil) {
+		schema["enum"] = append(enum, nil)
+	}
+}
+
+// dropNullProperties removes object properties with null values, such as the
+// optional properties filled in as null with strict structured outputs
+func dropNullProperties(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, property := range value {
+			if property == nil {
+				delete(value, key)
+			} else {
+				value[key] = dropNullProperties(property)
+			}
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = dropNullProperties(item)
+		}
+	}
+	return value
+}
+
+// withInstructions appends a user message describing the response format to
+// the messages, for models without native structured outputs
+func (f ResponseFormat) withInstructions(messages []Message) ([]Message, error) {
+	schema, err := f.schemaMap()
+	if err != nil {
+		return nil, err
+	}
+	schemaJson, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s response format schema: %w", f.name(), err)
+	}
+	instructions := fmt.Sprintf("Respond with only a JSON value matching this JSON schema, without any other text:\n\n```json\n%s\n```", schemaJson)
+	return append(slices.Clip(messages), Message{Role: RoleUser, Content: TextContentBlocks(instructions)}), nil
+}
+
+// Validate checks that the text of the message is JSON matching the schema
+func (f ResponseFormat) Validate(message Message) error {
+	var value any
+	if err := DecodeStructuredOutput(message, &value); err != nil {
+		return err
+	}
+	return utils.ValidateJSONSchema(f.Schema, dropNullProperties(value))
+}
+
+var jsonCodeFenceRegex = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\\n(.*?)\\n?```$")
+
+// DecodeStructuredOutput unmarshals the JSON reply to a request with a
+// response format into v
+func DecodeStructuredOutput(message Message, v any) error {
+	text := strings.TrimSpace(message.GetContentString())
+	if text == "" {
+		return fmt.Errorf("expected a JSON reply, got no text")
+	}
+	// models replying without native structured outputs tend to use markdown
+	if match := jsonCodeFenceRegex.FindStringSubmatch(text); match != nil {
+		text = strings.TrimSpace(match[1])
+	}
+	if err := json.Unmarshal([]byte(llm.RepairJson(text)), v); err != nil {
+		return fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	return nil
+}
+
+// StreamStructured streams a response like provider.Stream, additionally
+// validating the reply against the request's response format, if any. A reply
+// that doesn't match is sent back to the model once, along with the validation
+// error, to be repaired. Replies with tool calls aren't validated.
+func StreamStructured(ctx context.Context, provider Provider, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
+	response, err := provider.Stream(ctx, request, eventChan)
+	format := request.Options.ResponseFormat
+	if err != nil || format == nil || len(response.Output.GetToolCalls()) > 0 {
+		return response, err
+	}
+
+	validationErr := format.Validate(response.Output)
+	if validationErr == nil {
+		return response, nil
+	}
+
+	repairRequest := request
+	repairRequest.Messages = append(slices.Clip(request.Messages), response.Output, Message{
+		Role: RoleUser,
+		Content: TextContentBlocks(fmt.Sprintf(
+			"Your reply doesn't match the required JSON schema: %v\n\nReply again with only the corrected JSON.", validationErr,
+		)),
+	})
+	// the repair attempt isn't streamed, as it would be appended to the reply
+	// already streamed
+	repairEvents := make(chan Event)
+	go func() {
+		for range repairEvents {
+		}
+	}()
+	repaired, err := provider.Stream(ctx, repairRequest, repairEvents)
+	close(repairEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	// both attempts are billed
+	repaired.Usage.InputTokens += response.Usage.InputTokens
+	repaired.Usage.OutputTokens += response.Usage.OutputTokens
+	repaired.Usage.CacheReadInputTokens += response.Usage.CacheReadInputTokens
+	repaired.Usage.CacheWriteInputTokens += response.Usage.CacheWriteInputTokens
+
+	if err := format.Validate(repaired.Output); err != nil {
+		return repaired, fmt.Errorf("reply doesn't match the %s response format after a repair attempt: %w", format.name(), err)
+	}
+	return repaired, nil
+}
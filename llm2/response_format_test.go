@@ -0,0 +1,313 @@
+package llm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/common"
+	"sidekick/secret_manager"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBranchNames struct {
+	Candidates []string `json:"candidates" jsonschema:"description=Branch name candidates"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+// scriptedProvider replies with the given messages in turn, capturing the
+// requests it receives
+type scriptedProvider struct {
+	replies  []Message
+	requests []StreamRequest
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, request StreamRequest, eventChan chan<- Event) (*MessageResponse, error) {
+	if len(p.requests) >= len(p.replies) {
+		return nil, fmt.Errorf("unexpected request")
+	}
+	p.requests = append(p.requests, request)
+	if eventChan != nil {
+		eventChan <- Event{Type: EventTextDelta, Delta: p.replies[len(p.requests)-1].GetContentString()}
+	}
+	return &MessageResponse{
+		Output: p.replies[len(p.requests)-1],
+		Usage:  Usage{InputTokens: 10, OutputTokens: 5},
+	}, nil
+}
+
+func assistantText(text string) Message {
+	return Message{Role: RoleAssistant, Content: TextContentBlocks(text)}
+}
+
+func TestDecodeStructuredOutput(t *testing.T) {
+	t.Parallel()
+
+	for _, text := range []string{
+		`{"candidates":["fix-login"]}`,
+		"```json\n{\"candidates\":[\"fix-login\"]}\n```",
+		"  ```\n{\"candidates\":[\"fix-login\"]}```  ",
+	} {
+		var names testBranchNames
+		require.NoError(t, DecodeStructuredOutput(assistantText(text), &names), text)
+		assert.Equal(t, []string{"fix-login"}, names.Candidates)
+	}
+
+	var names testBranchNames
+	assert.ErrorContains(t, DecodeStructuredOutput(assistantText(""), &names), "no text")
+	assert.ErrorContains(t, DecodeStructuredOutput(assistantText("Sure! Here are some names"), &names), "not valid JSON")
+}
+
+func TestResponseFormat_Validate(t *testing.T) {
+	t.Parallel()
+	format := NewResponseFormat[testBranchNames]("branch_names")
+
+	assert.NoError(t, format.Validate(assistantText(`{"candidates":["a","b"]}`)))
+	assert.Error(t, format.Validate(assistantText(`{"reason":"no candidates"}`)))
+	assert.Error(t, format.Validate(assistantText(`{"candidates":"a"}`)))
+	assert.Error(t, format.Validate(assistantText(`{"candidates":["a"],"extra":true}`)))
+	assert.NoError(t, format.Validate(assistantText(`{"candidates":["a"],"reason":null}`)), "null optional properties are dropped")
+	assert.Error(t, format.Validate(assistantText(`{"candidates":null}`)))
+
+	schema, err := format.schemaMap()
+	require.NoError(t, err)
+	assert.NotContains(t, schema, "$schema")
+	assert.NotContains(t, schema, "$id")
+	assert.Equal(t, "object", schema["type"])
+}
+
+func TestResponseFormat_StrictSchemaMap(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name  string `json:"name"`
+		Notes string `json:"notes,omitempty" jsonschema:"enum=a,enum=b"`
+	}
+	type strictTest struct {
+		Items []item `json:"items"`
+		Count int    `json:"count,omitempty"`
+	}
+	schema, err := NewResponseFormat[strictTest]("strict_test").strictSchemaMap()
+	require.NoError(t, err)
+
+	assert.Equal(t, false, schema["additionalProperties"])
+	assert.Equal(t, []any{"count", "items"}, schema["required"])
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, []any{"integer", "null"}, properties["count"].(map[string]any)["type"])
+	assert.Equal(t, "array", properties["items"].(map[string]any)["type"])
+
+	itemSchema := properties["items"].(map[string]any)["items"].(map[string]any)
+	assert.Equal(t, false, itemSchema["additionalProperties"])
+	assert.Equal(t, []any{"name", "notes"}, itemSchema["required"])
+	notes := itemSchema["properties"].(map[string]any)["notes"].(map[string]any)
+	assert.Equal(t, []any{"string", "null"}, notes["type"])
+	assert.Equal(t, []any{"a", "b", nil}, notes["enum"])
+}
+
+func TestStreamStructured(t *testing.T) {
+	t.Parallel()
+	request := StreamRequest{
+		Messages: []Message{{Role: RoleUser, Content: TextContentBlocks("Name a branch")}},
+		Options:  Options{ResponseFormat: NewResponseFormat[testBranchNames]("branch_names")},
+	}
+
+	t.Run("valid reply", func(t *testing.T) {
+		provider := &scriptedProvider{replies: []Message{assistantText(`{"candidates":["a"]}`)}}
+		response, err := StreamStructured(context.Background(), provider, request, nil)
+		require.NoError(t, err)
+		assert.Len(t, provider.requests, 1)
+		assert.Equal(t, Usage{InputTokens: 10, OutputTokens: 5}, response.Usage)
+	})
+
+	t.Run("repairs an invalid reply", func(t *testing.T) {
+		invalid := assistantText(`{"candidates":"a"}`)
+		provider := &scriptedProvider{replies: []Message{invalid, assistantText(`{"candidates":["a"]}`)}}
+		eventChan := make(chan Event, 10)
+		response, err := StreamStructured(context.Background(), provider, request, eventChan)
+		require.NoError(t, err)
+		require.Len(t, provider.requests, 2)
+		close(eventChan)
+		var deltas []string
+		for event := range eventChan {
+			deltas = append(deltas, event.Delta)
+		}
+		assert.Equal(t, []string{invalid.GetContentString()}, deltas, "the repair attempt isn't streamed")
+
+		repairMessages := provider.requests[1].Messages
+		require.Len(t, repairMessages, 3)
+		assert.Equal(t, invalid, repairMessages[1])
+		assert.Contains(t, repairMessages[2].GetContentString(), "doesn't match the required JSON schema")
+		assert.Len(t, request.Messages, 1, "the original request is left untouched")
+
+		var names testBranchNames
+		require.NoError(t, DecodeStructuredOutput(response.Output, &names))
+		assert.Equal(t, []string{"a"}, names.Candidates)
+		assert.Equal(t, Usage{InputTokens: 20, OutputTokens: 10}, response.Usage)
+	})
+
+	t.Run("fails after one repair attempt", func(t *testing.T) {
+		provider := &scriptedProvider{replies: []Message{assistantText("nope"), assistantText("still nope")}}
+		_, err := StreamStructured(context.Background(), provider, request, nil)
+		assert.ErrorContains(t, err, "doesn't match the branch_names response format after a repair attempt")
+	})
+
+	t.Run("tool calls and requests without a format aren't validated", func(t *testing.T) {
+		toolCall := Message{Role: RoleAssistant, Content: []ContentBlock{
+			{Type: ContentBlockTypeToolUse, ToolUse: &ToolUseBlock{Id: "call_1", Name: "search", Arguments: "{}"}},
+		}}
+		provider := &scriptedProvider{replies: []Message{toolCall}}
+		_, err := StreamStructured(context.Background(), provider, request, nil)
+		require.NoError(t, err)
+
+		provider = &scriptedProvider{replies: []Message{assistantText("free text")}}
+		_, err = StreamStructured(context.Background(), provider, StreamRequest{Messages: request.Messages}, nil)
+		require.NoError(t, err)
+	})
+}
+
+func TestAnthropicSupportsStructuredOutputs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		model             string
+		structuredOutputs bool
+		adaptiveThinking  bool
+	}{
+		{"claude-sonnet-4-5", true, false},
+		{"claude-sonnet-4-5-20250929", true, false},
+		{"claude-opus-4-1", false, false},
+		{"claude-opus-4-1-20250805", false, false},
+		{"claude-opus-4-6", true, true},
+		{"claude-sonnet-4-6", true, true},
+		{"claude-sonnet-4-0", false, false},
+		{"claude-3-5-haiku-latest", false, false},
+		{"claude-3-5-haiku-20241022", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.structuredOutputs, anthropicSupportsStructuredOutputs(tt.model))
+			assert.Equal(t, tt.adaptiveThinking, anthropicSupportsAdaptiveThinking(tt.model))
+		})
+	}
+
+	// a date snapshot suffix without a minor version isn't taken for one
+	assert.False(t, anthropicSupportsStructuredOutputs("claude-sonnet-4-20250514"))
+	assert.False(t, anthropicSupportsStructuredOutputs("claude-opus-4-20250514"))
+}
+
+func TestResponseFormat_NativeRequests(t *testing.T) {
+	format := NewResponseFormat[testBranchNames]("branch_names")
+	options := func(provider, model string) Options {
+		return Options{
+			ModelConfig:    common.ModelConfig{Provider: provider, Model: model},
+			ResponseFormat: format,
+		}
+	}
+
+	t.Run("chat completions", func(t *testing.T) {
+		var captured map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(body, &captured))
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4.1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"{\\\"candidates\\\":[\\\"a\\\"]}\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		t.Cleanup(server.Close)
+
+		eventChan := make(chan Event, 100)
+		defer close(eventChan)
+		response, err := OpenAIProvider{BaseURL: server.URL}.Stream(context.Background(), StreamRequest{
+			Messages:      []Message{{Role: RoleUser, Content: TextContentBlocks("Name a branch")}},
+			Options:       options("openai_compatible", "gpt-4.1"),
+			SecretManager: secret_manager.MockSecretManager{},
+		}, eventChan)
+		require.NoError(t, err)
+		assert.NoError(t, format.Validate(response.Output))
+
+		responseFormat := captured["response_format"].(map[string]any)
+		assert.Equal(t, "json_schema", responseFormat["type"])
+		jsonSchema := responseFormat["json_schema"].(map[string]any)
+		assert.Equal(t, "branch_names", jsonSchema["name"])
+		assert.Equal(t, "object", jsonSchema["schema"].(map[string]any)["type"])
+		assert.Equal(t, true, jsonSchema["strict"])
+		assert.Equal(t, false, jsonSchema["schema"].(map[string]any)["additionalProperties"])
+	})
+
+	t.Run("ollama", func(t *testing.T) {
+		var captured ollamaChatRequest
+		server := newOllamaTestServer(t, []string{
+			`{"model":"qwen3:8b","message":{"role":"assistant","content":"{\"candidates\":[\"a\"]}"},"done":true,"done_reason":"stop"}`,
+		}, &captured)
+		_, _, err := streamOllamaTest(t, OllamaProvider{BaseURL: server.URL, DefaultModel: "qwen3:8b"}, StreamRequest{
+			Messages:      []Message{{Role: RoleUser, Content: TextContentBlocks("Name a branch")}},
+			Options:       options("ollama", ""),
+			SecretManager: secret_manager.MockSecretManager{},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "object", captured.Format["type"])
+		assert.Contains(t, captured.Format, "properties")
+	})
+
+	t.Run("google", func(t *testing.T) {
+		isolateAWSEnvironment(t)
+		var captured map[string]any
+		var cachesCreated atomic.Int32
+		newGeminiTestServer(t, &captured, &cachesCreated)
+
+		stream := func(tools []*common.Tool) {
+			eventChan := make(chan Event, 100)
+			defer close(eventChan)
+			requestOptions := options("google", "gemini-2.5-flash")
+			requestOptions.Tools = tools
+			_, err := GoogleProvider{}.Stream(context.Background(), StreamRequest{
+				Messages:      []Message{{Role: RoleUser, Content: TextContentBlocks("Name a branch")}},
+				Options:       requestOptions,
+				SecretManager: secret_manager.MockSecretManager{},
+			}, eventChan)
+			require.NoError(t, err)
+		}
+
+		stream(nil)
+		generationConfig := captured["generationConfig"].(map[string]any)
+		assert.Equal(t, "application/json", generationConfig["responseMimeType"])
+		assert.Equal(t, "object", generationConfig["responseJsonSchema"].(map[string]any)["type"])
+
+		// with tools, the schema is in the prompt instead
+		stream([]*common.Tool{{Name: "search", Description: "Search the code"}})
+		generationConfig, _ = captured["generationConfig"].(map[string]any)
+		assert.NotContains(t, generationConfig, "responseJsonSchema")
+		contents := captured["contents"].([]any)
+		parts := contents[len(contents)-1].(map[string]any)["parts"].([]any)
+		assert.Contains(t, parts[len(parts)-1].(map[string]any)["text"], "matching this JSON schema")
+	})
+
+	t.Run("bedrock", func(t *testing.T) {
+		isolateAWSEnvironment(t)
+		var capturedRequest *http.Request
+		var capturedBody map[string]any
+		server := newBedrockTestServer(t, bedrockTestEvents, &capturedRequest, &capturedBody)
+
+		eventChan := make(chan Event, 100)
+		defer close(eventChan)
+		_, err := AnthropicProvider{Bedrock: true, Region: "us-west-2", BaseURL: server.URL}.Stream(context.Background(), StreamRequest{
+			Messages:      []Message{{Role: RoleUser, Content: TextContentBlocks("Name a branch")}},
+			Options:       options("corp-bedrock", "us.anthropic.claude-sonnet-4-5-20250929-v1:0"),
+			SecretManager: mapSecretManager{"CORP_BEDROCK_API_KEY": "bedrock-key"},
+		}, eventChan)
+		require.NoError(t, err)
+
+		assert.NotContains(t, capturedBody, "output_config")
+		body, err := json.Marshal(capturedBody["messages"])
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "matching this JSON schema")
+	})
+}
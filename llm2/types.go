@@ -239,6 +239,23 @@ type MessageResponse struct {
 	// effort requested if the model does not support reasoning or uses a
 	// different effort enum/schema)
 	ReasoningEffort string `json:"reasoningEffort"`
+
+	// dollar cost of this response based on models.dev pricing, nil if the
+	// model could not be priced
+	Cost *float64 `json:"cost,omitempty"`
+
+	// models that failed before the one that served this response, in the
+	// order they were tried
+	FallbackAttempts []FallbackAttempt `json:"fallbackAttempts,omitempty"`
+}
+
+// FallbackAttempt records a model that failed with an error warranting a
+// fallback to the next configured model
+type FallbackAttempt struct {
+	Provider   string     `json:"provider"`
+	Model      string     `json:"model,omitempty"`
+	ErrorClass ErrorClass `json:"errorClass"`
+	Error      string     `json:"error"`
 }
 
 // GetMessage returns the Output message as a common.Message interface.
@@ -266,6 +283,11 @@ func (r MessageResponse) GetOutputTokens() int {
 	return r.Usage.OutputTokens
 }
 
+// GetLlmUsage returns the usage and cost of the call that produced this response.
+func (r MessageResponse) GetLlmUsage() common.LlmUsage {
+	return common.NewLlmUsage(common.Usage(r.Usage), r.Cost)
+}
+
 // EventType enumerates provider-agnostic streaming event kinds for content blocks.
 // This is a small whitelist we can reliably map from OpenAI/Anthropic, etc.
 // No message-level start/stop or usage events are included by design.
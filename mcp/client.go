@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"sidekick/common"
+	"sidekick/env"
+)
+
+// transport sends JSON-RPC messages to an MCP server
+type transport interface {
+	Call(ctx context.Context, method string, params, result any) error
+	Notify(ctx context.Context, method string, params any) error
+	Close() error
+}
+
+// Client is a connection to an MCP server, initialized and ready for use.
+type Client struct {
+	transport       transport
+	ServerInfo      Implementation
+	ProtocolVersion string
+	Instructions    string
+}
+
+// Connect starts or connects to the given MCP server, depending on its
+// transport, and initializes the connection. Stdio servers are run in the
+// given environment's working directory, inside the container or DevPod
+// workspace for remote environments.
+func Connect(ctx context.Context, config common.McpServerConfig, environment env.Env) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var t transport
+	var err error
+	if config.Command != "" {
+		envVars := make(map[string]string, len(config.Env))
+		for key, value := range config.Env {
+			envVars[key] = os.ExpandEnv(value)
+		}
+		command, args := config.Command, config.Args
+		if remoteEnv, ok := environment.(env.RemoteCommandEnv); ok {
+			argv := remoteEnv.RemoteStdioCommand(remoteEnv.GetWorkingDirectory(), envVarList(envVars), command, args...)
+			command, args, envVars = argv[0], argv[1:], nil
+		}
+		t, err = newStdioTransport(ctx, command, args, envVars, environment.GetWorkingDirectory())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		headers := make(map[string]string, len(config.Headers))
+		for key, value := range config.Headers {
+			headers[key] = os.ExpandEnv(value)
+		}
+		t = newHTTPTransport(config.URL, headers)
+	}
+
+	client, err := newClient(ctx, t)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// envVarList returns the environment variables as sorted KEY=value pairs
+func envVarList(envVars map[string]string) []string {
+	list := make([]string, 0, len(envVars))
+	for key, value := range envVars {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func newClient(ctx context.Context, t transport) (*Client, error) {
+	var result initializeResult
+	err := t.Call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "sidekick", Version: "1.0.0"},
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MCP connection: %w", err)
+	}
+	if ht, ok := t.(*httpTransport); ok {
+		ht.setProtocolVersion(result.ProtocolVersion)
+	}
+	if err := t.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, fmt.Errorf("failed to send initialized notification: %w", err)
+	}
+
+	return &Client{
+		transport:       t,
+		ServerInfo:      result.ServerInfo,
+		ProtocolVersion: result.ProtocolVersion,
+		Instructions:    result.Instructions,
+	}, nil
+}
+
+// ListTools returns all tools offered by the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var result listToolsResult
+		if err := c.transport.Call(ctx, "tools/list", listToolsParams{Cursor: cursor}, &result); err != nil {
+			return nil, fmt.Errorf("failed to list MCP tools: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool calls the named tool with the given JSON object of arguments.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (CallToolResult, error) {
+	var result CallToolResult
+	if err := c.transport.Call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return CallToolResult{}, fmt.Errorf("failed to call MCP tool %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// Close closes the connection, stopping the server for the stdio transport.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
@@ -0,0 +1,318 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sidekick/common"
+	"sidekick/env"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeServerEnvVar = "SIDEKICK_FAKE_MCP_SERVER"
+
+// TestMain runs the test binary as a fake stdio MCP server when requested, so
+// that the stdio transport can be tested against a real subprocess.
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnvVar) != "" {
+		serveFakeStdio()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func serveFakeStdio() {
+	stream := jsonrpc2.NewPlainObjectStream(stdioStream{os.Stdin, os.Stdout})
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(handleFake))
+	<-conn.DisconnectNotify()
+}
+
+// handleFake implements a fake MCP server with two pages of tools: "echo",
+// which returns its arguments, and "fail", which returns a tool error.
+func handleFake(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	var params map[string]any
+	if req.Params != nil {
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return initializeResult{
+			ProtocolVersion: params["protocolVersion"].(string),
+			ServerInfo:      Implementation{Name: "fake", Version: "0.1.0"},
+		}, nil
+	case "notifications/initialized":
+		return nil, nil
+	case "tools/list":
+		if params["cursor"] == nil {
+			return listToolsResult{
+				Tools:      []Tool{{Name: "echo", Description: "Echoes the arguments", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)}},
+				NextCursor: "page2",
+			}, nil
+		}
+		return listToolsResult{Tools: []Tool{{Name: "fail"}}}, nil
+	case "tools/call":
+		switch params["name"] {
+		case "echo":
+			arguments, _ := json.Marshal(params["arguments"])
+			return CallToolResult{Content: []Content{{Type: "text", Text: string(arguments)}}}, nil
+		case "fail":
+			return CallToolResult{Content: []Content{{Type: "text", Text: "it failed"}}, IsError: true}, nil
+		}
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %v", params["name"])}
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: req.Method}
+}
+
+func fakeStdioServerConfig(t *testing.T) common.McpServerConfig {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	return common.McpServerConfig{
+		Command: executable,
+		Env:     map[string]string{fakeServerEnvVar: "1"},
+	}
+}
+
+func TestClient_Stdio(t *testing.T) {
+	ctx := context.Background()
+	client, err := Connect(ctx, fakeStdioServerConfig(t), &env.LocalEnv{WorkingDirectory: t.TempDir()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert.Equal(t, "fake", client.ServerInfo.Name)
+	assert.Equal(t, ProtocolVersion, client.ProtocolVersion)
+
+	tools, err := client.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "echo", tools[0].Name)
+	assert.JSONEq(t, `{"type":"object","properties":{"text":{"type":"string"}}}`, string(tools[0].InputSchema))
+	assert.Equal(t, "fail", tools[1].Name)
+
+	result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.JSONEq(t, `{"text":"hi"}`, result.Text())
+
+	result, err = client.CallTool(ctx, "fail", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "it failed", result.Text())
+
+	_, err = client.CallTool(ctx, "missing", nil)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code)
+}
+
+// newFakeHTTPServer serves the fake MCP server over the streamable HTTP
+// transport, responding with SSE streams when sse is true.
+func newFakeHTTPServer(t *testing.T, sse bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var message struct {
+			Id     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+			Params *json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		if message.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		} else {
+			assert.Equal(t, "session-1", r.Header.Get("Mcp-Session-Id"))
+			assert.Equal(t, ProtocolVersion, r.Header.Get("MCP-Protocol-Version"))
+		}
+		if message.Id == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result, err := handleFake(r.Context(), nil, &jsonrpc2.Request{Method: message.Method, Params: message.Params})
+		response := map[string]any{"jsonrpc": "2.0", "id": message.Id}
+		if err != nil {
+			response["error"] = err
+		} else {
+			response["result"] = result
+		}
+		body, err := json.Marshal(response)
+		require.NoError(t, err)
+
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", `{"jsonrpc":"2.0","method":"notifications/progress","params":{}}`)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", body)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_HTTP(t *testing.T) {
+	for _, sse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sse=%v", sse), func(t *testing.T) {
+			ctx := context.Background()
+			t.Setenv("FAKE_MCP_TOKEN", "secret")
+			server := newFakeHTTPServer(t, sse)
+
+			client, err := Connect(ctx, common.McpServerConfig{
+				URL:     server.URL,
+				Headers: map[string]string{"Authorization": "Bearer ${FAKE_MCP_TOKEN}"},
+			}, nil)
+			require.NoError(t, err)
+			defer client.Close()
+
+			tools, err := client.ListTools(ctx)
+			require.NoError(t, err)
+			require.Len(t, tools, 2)
+
+			result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"text":"hi"}`, result.Text())
+
+			_, err = client.CallTool(ctx, "missing", nil)
+			var rpcErr *RPCError
+			require.ErrorAs(t, err, &rpcErr)
+			assert.Contains(t, rpcErr.Message, "unknown tool")
+		})
+	}
+}
+
+// fakeContainerRuntime writes a script that runs "exec" commands on the host
+// like a container runtime would inside the container.
+func fakeContainerRuntime(t *testing.T) string {
+	script := `#!/bin/sh
+[ "$1" = exec ] && [ "$2" = --interactive ] && [ "$3" = --workdir ] || exit 1
+cd "$4" || exit 1
+shift 4
+while [ "$1" = --env ]; do export "$2"; shift 2; done
+shift
+exec "$@"
+`
+	path := filepath.Join(t.TempDir(), "fake-runtime")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestConnect_StdioInContainer(t *testing.T) {
+	ctx := context.Background()
+	containerEnv := &env.ContainerEnv{
+		WorkingDirectory: t.TempDir(),
+		Runtime:          fakeContainerRuntime(t),
+		ContainerName:    "sidekick-test",
+	}
+
+	// the server's env is passed to the container rather than set on the
+	// host command, so the fake server only starts if it's run via the runtime
+	client, err := Connect(ctx, fakeStdioServerConfig(t), containerEnv)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, "fake", client.ServerInfo.Name)
+}
+
+func TestMcpActivities(t *testing.T) {
+	ctx := context.Background()
+	activities := &McpActivities{}
+	config := fakeStdioServerConfig(t)
+	config.Tools = []string{"echo"}
+	envContainer := env.EnvContainer{Env: &env.LocalEnv{WorkingDirectory: t.TempDir()}}
+
+	serverTools, err := activities.ListMcpTools(ctx, ListMcpToolsInput{
+		Servers: map[string]common.McpServerConfig{
+			"fake":   config,
+			"broken": {Command: "/nonexistent/mcp-server"},
+		},
+		FlowId:       "flow_1",
+		EnvContainer: envContainer,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ServerTool{{
+		Server:      "fake",
+		ExposedName: "mcp_fake_echo",
+		Tool: Tool{
+			Name:        "echo",
+			Description: "Echoes the arguments",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		},
+	}}, serverTools)
+
+	result, err := activities.CallMcpTool(ctx, CallMcpToolInput{
+		Server:       "fake",
+		Config:       config,
+		FlowId:       "flow_1",
+		EnvContainer: envContainer,
+		Tool:         "echo",
+		Arguments:    json.RawMessage(`{"text":"hi"}`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"hi"}`, result.Text())
+	// the server started for listing tools is reused
+	assert.Len(t, activities.clients, 1)
+
+	// other flows get their own server
+	_, err = activities.CallMcpTool(ctx, CallMcpToolInput{
+		Server:       "fake",
+		Config:       config,
+		FlowId:       "flow_2",
+		EnvContainer: envContainer,
+		Tool:         "echo",
+		Arguments:    json.RawMessage(`{"text":"hi"}`),
+	})
+	require.NoError(t, err)
+	assert.Len(t, activities.clients, 2)
+
+	require.NoError(t, activities.CloseMcpClients(ctx, "flow_1"))
+	require.Len(t, activities.clients, 1)
+	for _, client := range activities.clients {
+		assert.Equal(t, "flow_2", client.flowId)
+		client.lastUsed = time.Now().Add(-clientIdleTimeout - time.Minute)
+	}
+	// idle clients are closed in case their flow never closes them
+	activities.mu.Lock()
+	activities.closeIdleClients()
+	activities.mu.Unlock()
+	assert.Empty(t, activities.clients)
+}
+
+func TestExposedToolName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "mcp_github_create_issue", ExposedToolName("github", "create_issue"))
+	assert.Equal(t, "mcp_my_server_tools_list", ExposedToolName("my server", "tools.list"))
+	long := ExposedToolName("server", strings.Repeat("x", 100))
+	assert.Len(t, long, maxToolNameLength)
+}
+
+func TestCallToolResult_Text(t *testing.T) {
+	t.Parallel()
+
+	result := CallToolResult{Content: []Content{
+		{Type: "text", Text: "first"},
+		{Type: "image", Data: "aGk=", MimeType: "image/png"},
+		{Type: "resource", Resource: &ResourceContents{URI: "file:///a.txt", Text: "contents"}},
+	}}
+	assert.Equal(t, "first\n\n[image content (image/png) omitted]\n\nResource file:///a.txt:\ncontents", result.Text())
+
+	result = CallToolResult{StructuredContent: json.RawMessage(`{"ok":true}`)}
+	assert.Equal(t, `{"ok":true}`, result.Text())
+}
@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// httpTransport implements the streamable HTTP transport: each message is
+// POSTed to the server's endpoint, which replies with either a JSON response
+// or an SSE stream that eventually contains the response.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextId  atomic.Int64
+
+	mu              sync.Mutex
+	sessionId       string
+	protocolVersion string
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	Id      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcMessage struct {
+	Id     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
+
+func newHTTPTransport(url string, headers map[string]string) *httpTransport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = version
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionId != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionId)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	return req, nil
+}
+
+func (t *httpTransport) post(ctx context.Context, message rpcRequest) (*http.Response, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sessionId := resp.Header.Get("Mcp-Session-Id"); sessionId != "" {
+		t.mu.Lock()
+		t.sessionId = sessionId
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("MCP server responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) Call(ctx context.Context, method string, params, result any) error {
+	id := t.nextId.Add(1)
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", Id: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response *rpcMessage
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		response, err = readSSEResponse(resp.Body, id)
+	} else {
+		response = &rpcMessage{}
+		err = json.NewDecoder(resp.Body).Decode(response)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read MCP response: %w", err)
+	}
+
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// readSSEResponse reads server-sent events until the response with the given
+// id is found. Other messages, eg progress notifications, are skipped.
+func readSSEResponse(body io.Reader, id int64) (*rpcMessage, error) {
+	expectedId := fmt.Sprint(id)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var data strings.Builder
+	for {
+		more := scanner.Scan()
+		line := scanner.Text()
+		if more && line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(value, " "))
+				data.WriteString("\n")
+			}
+			continue
+		}
+
+		// a blank line (or the end of the stream) dispatches the event
+		if data.Len() > 0 {
+			var message rpcMessage
+			if err := json.Unmarshal([]byte(data.String()), &message); err == nil &&
+				message.Method == "" && string(message.Id) == expectedId {
+				return &message, nil
+			}
+			data.Reset()
+		}
+		if !more {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("event stream ended without a response to request %d", id)
+		}
+	}
+}
+
+func (t *httpTransport) Notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Close ends the session, if the server assigned one.
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	hasSession := t.sessionId != ""
+	t.mu.Unlock()
+	if !hasSession {
+		return nil
+	}
+
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"sidekick/common"
+	"sidekick/env"
+
+	"github.com/rs/zerolog/log"
+)
+
+// McpActivities keeps connections to MCP servers open across activities, so
+// that stdio servers aren't restarted for every tool call. Connections are
+// per flow, and closed by CloseMcpClients once the flow finishes, or after
+// being idle for a while in case that never happens, eg when the worker
+// restarted in between.
+type McpActivities struct {
+	clients map[string]*flowClient
+	mu      sync.Mutex
+}
+
+type flowClient struct {
+	*Client
+	flowId   string
+	lastUsed time.Time
+}
+
+// clientIdleTimeout is how long a connection may go unused before it's closed
+const clientIdleTimeout = 30 * time.Minute
+
+// ServerTool is a tool of a configured MCP server, along with the name it is
+// exposed to the LLM as.
+type ServerTool struct {
+	Server      string `json:"server"`
+	ExposedName string `json:"exposedName"`
+	Tool        Tool   `json:"tool"`
+}
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// maxToolNameLength is the shortest tool name length limit among providers
+const maxToolNameLength = 64
+
+// ExposedToolName returns the name the given server's tool is exposed to the
+// LLM as. The server name is included so tools of different servers don't
+// clash with each other or with built-in tools.
+func ExposedToolName(server, tool string) string {
+	name := invalidToolNameChars.ReplaceAllString("mcp_"+server+"_"+tool, "_")
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+type ListMcpToolsInput struct {
+	Servers map[string]common.McpServerConfig
+	FlowId  string
+	// EnvContainer is the flow's environment, which stdio servers run in
+	EnvContainer env.EnvContainer
+	// Deprecated: WorkingDir is used when EnvContainer isn't set, for
+	// activities scheduled before EnvContainer was added
+	WorkingDir string
+}
+
+// ListMcpTools lists the tools of all the given servers, limited to each
+// server's allowed tools. Servers that fail to connect are skipped, so that a
+// misconfigured or unavailable server doesn't prevent flows from running.
+func (a *McpActivities) ListMcpTools(ctx context.Context, input ListMcpToolsInput) ([]ServerTool, error) {
+	serverNames := make([]string, 0, len(input.Servers))
+	for name := range input.Servers {
+		serverNames = append(serverNames, name)
+	}
+	sort.Strings(serverNames)
+
+	var serverTools []ServerTool
+	exposedNames := make(map[string]bool)
+	for _, serverName := range serverNames {
+		config := input.Servers[serverName]
+		tools, err := a.listTools(ctx, serverName, config, input.FlowId, inputEnv(input.EnvContainer, input.WorkingDir))
+		if err != nil {
+			log.Warn().Err(err).Str("server", serverName).Msg("Skipping tools of MCP server")
+			continue
+		}
+		for _, tool := range tools {
+			if len(config.Tools) > 0 && !slices.Contains(config.Tools, tool.Name) {
+				continue
+			}
+			exposedName := ExposedToolName(serverName, tool.Name)
+			if exposedNames[exposedName] {
+				log.Warn().Str("server", serverName).Str("tool", tool.Name).Msgf("Skipping MCP tool, as another tool is already named %s", exposedName)
+				continue
+			}
+			exposedNames[exposedName] = true
+			serverTools = append(serverTools, ServerTool{Server: serverName, ExposedName: exposedName, Tool: tool})
+		}
+	}
+	return serverTools, nil
+}
+
+func (a *McpActivities) listTools(ctx context.Context, serverName string, config common.McpServerConfig, flowId string, environment env.Env) ([]Tool, error) {
+	client, err := a.getClient(ctx, serverName, config, flowId, environment)
+	if err != nil {
+		return nil, err
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		a.dropClient(serverName, config, flowId, environment, err)
+	}
+	return tools, err
+}
+
+// inputEnv returns the environment given to an activity, falling back to a
+// local one for the deprecated working directory
+func inputEnv(envContainer env.EnvContainer, workingDir string) env.Env {
+	if envContainer.Env != nil {
+		return envContainer.Env
+	}
+	return &env.LocalEnv{WorkingDirectory: workingDir}
+}
+
+type CallMcpToolInput struct {
+	Server       string
+	Config       common.McpServerConfig
+	FlowId       string
+	EnvContainer env.EnvContainer
+	// Deprecated: see ListMcpToolsInput.WorkingDir
+	WorkingDir string
+	Tool       string
+	// Arguments is the JSON object of arguments for the tool
+	Arguments json.RawMessage
+}
+
+// CallMcpTool calls a tool of the given server.
+func (a *McpActivities) CallMcpTool(ctx context.Context, input CallMcpToolInput) (CallToolResult, error) {
+	environment := inputEnv(input.EnvContainer, input.WorkingDir)
+	client, err := a.getClient(ctx, input.Server, input.Config, input.FlowId, environment)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	result, err := client.CallTool(ctx, input.Tool, input.Arguments)
+	if err != nil {
+		a.dropClient(input.Server, input.Config, input.FlowId, environment, err)
+	}
+	return result, err
+}
+
+// CloseMcpClients closes the connections to MCP servers opened for the given
+// flow, stopping its stdio servers.
+func (a *McpActivities) CloseMcpClients(ctx context.Context, flowId string) error {
+	a.mu.Lock()
+	var toClose []*flowClient
+	for key, client := range a.clients {
+		if client.flowId == flowId {
+			toClose = append(toClose, client)
+			delete(a.clients, key)
+		}
+	}
+	a.mu.Unlock()
+
+	var errs []error
+	for _, client := range toClose {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func clientKey(serverName string, config common.McpServerConfig, flowId string, environment env.Env) string {
+	configJson, _ := json.Marshal(config)
+	envJson, _ := json.Marshal(env.EnvContainer{Env: environment})
+	return serverName + "\x00" + flowId + "\x00" + string(envJson) + "\x00" + string(configJson)
+}
+
+func (a *McpActivities) getClient(ctx context.Context, serverName string, config common.McpServerConfig, flowId string, environment env.Env) (*Client, error) {
+	key := clientKey(serverName, config, flowId, environment)
+
+	// connecting is done while holding the lock, which is simpler than
+	// per-server locks and only slow the first time a server is used
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closeIdleClients()
+	if client, ok := a.clients[key]; ok {
+		client.lastUsed = time.Now()
+		return client.Client, nil
+	}
+
+	client, err := Connect(ctx, config, environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MCP server %s: %w", serverName, err)
+	}
+	if a.clients == nil {
+		a.clients = make(map[string]*flowClient)
+	}
+	a.clients[key] = &flowClient{Client: client, flowId: flowId, lastUsed: time.Now()}
+	return client, nil
+}
+
+// closeIdleClients closes the clients that haven't been used for a while. Must
+// be called with the lock held.
+func (a *McpActivities) closeIdleClients() {
+	for key, client := range a.clients {
+		if time.Since(client.lastUsed) > clientIdleTimeout {
+			delete(a.clients, key)
+			go client.Close()
+		}
+	}
+}
+
+// dropClient closes the client after a transport error, so that the next use
+// reconnects, eg after the server process exited. Errors returned by the
+// server itself leave the connection intact.
+func (a *McpActivities) dropClient(serverName string, config common.McpServerConfig, flowId string, environment env.Env, err error) {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return
+	}
+
+	key := clientKey(serverName, config, flowId, environment)
+	a.mu.Lock()
+	client, ok := a.clients[key]
+	delete(a.clients, key)
+	a.mu.Unlock()
+	if ok {
+		_ = client.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// supportedProtocolVersions are the protocol versions the server can speak,
+// newest first
+var supportedProtocolVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ToolHandler handles a call of a server tool, given the JSON object of
+// arguments. Returned errors are reported to the client as tool errors.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (CallToolResult, error)
+
+// Server is an MCP server offering tools over the stdio transport.
+type Server struct {
+	info         Implementation
+	instructions string
+
+	mu       sync.RWMutex
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+// NewServer creates a server with no tools. The instructions are sent to the
+// client on initialization, to tell its LLM how to use the server.
+func NewServer(info Implementation, instructions string) *Server {
+	return &Server{
+		info:         info,
+		instructions: instructions,
+		handlers:     make(map[string]ToolHandler),
+	}
+}
+
+// AddTool adds a tool to the server, replacing any tool of the same name.
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools = slices.DeleteFunc(s.tools, func(t Tool) bool { return t.Name == tool.Name })
+	s.tools = append(s.tools, tool)
+	s.handlers[tool.Name] = handler
+}
+
+type stdioStream struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdioStream) Close() error { return nil }
+
+// ServeStdio serves newline-delimited JSON-RPC messages read from in, writing
+// responses to out, until in is closed or ctx is done. Requests are handled
+// concurrently, so that slow tool calls don't block other requests.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	stream := jsonrpc2.NewPlainObjectStream(stdioStream{in, out})
+	conn := jsonrpc2.NewConn(ctx, stream, jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(s.handle)))
+	select {
+	case <-conn.DisconnectNotify():
+		return nil
+	case <-ctx.Done():
+		conn.Close()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	var params json.RawMessage
+	if req.Params != nil {
+		params = *req.Params
+	}
+
+	switch req.Method {
+	case "initialize":
+		var initParams initializeParams
+		if err := json.Unmarshal(params, &initParams); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedProtocolVersions, initParams.ProtocolVersion) {
+			version = initParams.ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+			Instructions:    s.instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return listToolsResult{Tools: slices.Clone(s.tools)}, nil
+	case "tools/call":
+		var callParams callToolParams
+		if err := json.Unmarshal(params, &callParams); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		s.mu.RLock()
+		handler, ok := s.handlers[callParams.Name]
+		s.mu.RUnlock()
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", callParams.Name)}
+		}
+		arguments := callParams.Arguments
+		if len(arguments) == 0 || string(arguments) == "null" {
+			arguments = json.RawMessage("{}")
+		}
+		result, err := handler(ctx, arguments)
+		if err != nil {
+			return ErrorResult(err), nil
+		}
+		return result, nil
+	}
+
+	if req.Notif {
+		// eg notifications/initialized, which needs no handling
+		return nil, nil
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+func invalidParamsError(err error) *jsonrpc2.Error {
+	return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+}
+
+// InputSchema returns the JSON schema of the given arguments struct, for use
+// as a tool's input schema. Fields without omitempty are required.
+func InputSchema(args any) json.RawMessage {
+	schema := (&jsonschema.Reflector{DoNotReference: true}).Reflect(args)
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal input schema: %v", err))
+	}
+	return data
+}
+
+// TextResult returns a tool result with the given text content.
+func TextResult(text string) CallToolResult {
+	return CallToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// JSONResult returns a tool result with the given value as indented JSON text
+// content, as well as structured content.
+func JSONResult(value any) (CallToolResult, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return CallToolResult{}, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	result := TextResult(string(data))
+	// structured content must be an object
+	if data[0] == '{' {
+		result.StructuredContent = data
+	}
+	return result, nil
+}
+
+// ErrorResult returns a tool result reporting the given error.
+func ErrorResult(err error) CallToolResult {
+	result := TextResult(err.Error())
+	result.IsError = true
+	return result
+}
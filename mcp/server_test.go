@@ -0,0 +1,72 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectToServer connects a client to the server over in-memory pipes
+func connectToServer(t *testing.T, server *Server) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	go server.ServeStdio(ctx, serverReader, serverWriter)
+
+	client, err := newClient(ctx, newStreamTransport(ctx, readWriteCloser{clientReader, clientWriter}, nil))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		cancel()
+	})
+	return client
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer(Implementation{Name: "test", Version: "1.0.0"}, "Use the tools")
+	server.AddTool(Tool{Name: "greet", InputSchema: json.RawMessage(`{"type":"object"}`)}, func(ctx context.Context, arguments json.RawMessage) (CallToolResult, error) {
+		var args struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return CallToolResult{}, err
+		}
+		if args.Name == "" {
+			return CallToolResult{}, errors.New("name is required")
+		}
+		return JSONResult(map[string]string{"greeting": "hello " + args.Name})
+	})
+
+	client := connectToServer(t, server)
+	assert.Equal(t, "test", client.ServerInfo.Name)
+	assert.Equal(t, ProtocolVersion, client.ProtocolVersion)
+	assert.Equal(t, "Use the tools", client.Instructions)
+
+	tools, err := client.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "greet", tools[0].Name)
+
+	result, err := client.CallTool(ctx, "greet", json.RawMessage(`{"name":"world"}`))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.JSONEq(t, `{"greeting":"hello world"}`, result.Text())
+	assert.JSONEq(t, `{"greeting":"hello world"}`, string(result.StructuredContent))
+
+	// handler errors are tool errors, so the client's LLM can see them
+	result, err = client.CallTool(ctx, "greet", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "name is required", result.Text())
+
+	_, err = client.CallTool(ctx, "missing", nil)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Contains(t, rpcErr.Message, "unknown tool: missing")
+}
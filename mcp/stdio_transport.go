@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// streamTransport exchanges newline-delimited JSON-RPC messages over a stream,
+// as used by the stdio transport
+type streamTransport struct {
+	conn    *jsonrpc2.Conn
+	onClose func() error
+}
+
+type readWriteCloser struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (rwc readWriteCloser) Close() error {
+	return errors.Join(rwc.WriteCloser.Close(), rwc.ReadCloser.Close())
+}
+
+func newStreamTransport(ctx context.Context, rwc io.ReadWriteCloser, onClose func() error) *streamTransport {
+	conn := jsonrpc2.NewConn(context.WithoutCancel(ctx), jsonrpc2.NewPlainObjectStream(rwc), serverRequestHandler{})
+	return &streamTransport{conn: conn, onClose: onClose}
+}
+
+// newStdioTransport starts the server command, communicating with it over its
+// stdin and stdout. The command's stderr is passed through for debugging.
+func newStdioTransport(ctx context.Context, command string, args []string, env map[string]string, workingDir string) (*streamTransport, error) {
+	// not tied to ctx: the server outlives the activity that started it
+	cmd := exec.Command(command, args...)
+	cmd.Dir = workingDir
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdinPipe() failed: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdoutPipe() failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %v", command, err)
+	}
+
+	return newStreamTransport(ctx, readWriteCloser{stdout, stdin}, func() error {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil
+	}), nil
+}
+
+func (t *streamTransport) Call(ctx context.Context, method string, params, result any) error {
+	err := t.conn.Call(ctx, method, params, result)
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		var data []byte
+		if rpcErr.Data != nil {
+			data = *rpcErr.Data
+		}
+		return &RPCError{Code: rpcErr.Code, Message: rpcErr.Message, Data: data}
+	}
+	return err
+}
+
+func (t *streamTransport) Notify(ctx context.Context, method string, params any) error {
+	return t.conn.Notify(ctx, method, params)
+}
+
+func (t *streamTransport) Close() error {
+	err := t.conn.Close()
+	if errors.Is(err, jsonrpc2.ErrClosed) {
+		err = nil
+	}
+	if t.onClose != nil {
+		err = errors.Join(err, t.onClose())
+	}
+	return err
+}
+
+// serverRequestHandler handles requests and notifications sent by the server.
+// Only pings are supported, as no client capabilities are declared.
+type serverRequestHandler struct{}
+
+func (serverRequestHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Notif {
+		return
+	}
+	if req.Method == "ping" {
+		_ = conn.Reply(ctx, req.ID, struct{}{})
+		return
+	}
+	_ = conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeMethodNotFound,
+		Message: fmt.Sprintf("method not supported: %s", req.Method),
+	})
+}
@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion is the MCP protocol version requested when initializing a
+// connection. Servers may respond with an older version they support.
+const ProtocolVersion = "2025-06-18"
+
+// Implementation identifies an MCP client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type initializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      Implementation `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+// Tool is a tool offered by an MCP server.
+type Tool struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the JSON schema of the tool's arguments object.
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+type listToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type listToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type callToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ResourceContents is the content of a resource embedded in a tool result.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Content is a single content item of a tool result. Type is one of "text",
+// "image", "audio", "resource_link" or "resource".
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // base64-encoded image or audio
+	MimeType string            `json:"mimeType,omitempty"`
+	URI      string            `json:"uri,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// CallToolResult is the result of calling a tool. Errors raised by the tool
+// itself are reported via IsError rather than as protocol errors, so that the
+// LLM can see them and self-correct.
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Text renders the result as text for the LLM. Binary content is described
+// rather than included.
+func (r CallToolResult) Text() string {
+	var parts []string
+	for _, content := range r.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource == nil {
+				continue
+			}
+			if content.Resource.Text != "" {
+				parts = append(parts, fmt.Sprintf("Resource %s:\n%s", content.Resource.URI, content.Resource.Text))
+			} else {
+				parts = append(parts, fmt.Sprintf("[binary resource %s (%s) omitted]", content.Resource.URI, content.Resource.MimeType))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("Resource link: %s %s", content.Name, content.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content (%s) omitted]", content.Type, content.MimeType))
+		}
+	}
+	if len(parts) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// RPCError is a JSON-RPC error returned by an MCP server.
+type RPCError struct {
+	Code    int64           `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
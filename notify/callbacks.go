@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sidekick/common"
+	"strconv"
+	"time"
+)
+
+// callbackWorkspaceId holds callback tokens in KV storage, outside of any real
+// workspace, as the callback endpoint only knows the token
+const callbackWorkspaceId = "__notification_callbacks"
+
+const callbackTokenKeyPrefix = "callback_token/"
+
+// CallbackTokenTTL is how long a callback token can be used to answer a
+// request for user
+const CallbackTokenTTL = 7 * 24 * time.Hour
+
+// MaxCallbackSignatureAge bounds the age of a signed callback's timestamp,
+// so that captured requests can't be replayed later
+const MaxCallbackSignatureAge = 5 * time.Minute
+
+var ErrInvalidCallbackToken = errors.New("unknown or expired callback token")
+
+// CallbackToken scopes a callback to a single pending flow action
+type CallbackToken struct {
+	WorkspaceId  string    `json:"workspaceId"`
+	FlowId       string    `json:"flowId"`
+	FlowActionId string    `json:"flowActionId"`
+	Expires      time.Time `json:"expires"`
+}
+
+// tokens are stored by hash, so that storage contents can't be used to answer
+// requests
+func callbackTokenKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return callbackTokenKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+// IssueCallbackToken stores a new random token for answering the given flow
+// action and returns it
+func IssueCallbackToken(ctx context.Context, storage common.KeyValueStorage, callbackToken CallbackToken) (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate callback token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	if callbackToken.Expires.IsZero() {
+		callbackToken.Expires = time.Now().Add(CallbackTokenTTL).UTC()
+	}
+	tokenJson, err := json.Marshal(callbackToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal callback token: %w", err)
+	}
+	if err := storage.MSetRaw(ctx, callbackWorkspaceId, map[string][]byte{callbackTokenKey(token): tokenJson}); err != nil {
+		return "", fmt.Errorf("failed to store callback token: %w", err)
+	}
+	return token, nil
+}
+
+// LookupCallbackToken returns what the token scopes a callback to, or
+// ErrInvalidCallbackToken if it was never issued, was revoked or has expired
+func LookupCallbackToken(ctx context.Context, storage common.KeyValueStorage, token string) (CallbackToken, error) {
+	values, err := storage.MGet(ctx, callbackWorkspaceId, []string{callbackTokenKey(token)})
+	if err != nil {
+		return CallbackToken{}, fmt.Errorf("failed to get callback token: %w", err)
+	}
+	if len(values) != 1 || values[0] == nil {
+		return CallbackToken{}, ErrInvalidCallbackToken
+	}
+	var callbackToken CallbackToken
+	if err := json.Unmarshal(values[0], &callbackToken); err != nil {
+		return CallbackToken{}, fmt.Errorf("failed to unmarshal callback token: %w", err)
+	}
+	if time.Now().After(callbackToken.Expires) {
+		return CallbackToken{}, ErrInvalidCallbackToken
+	}
+	return callbackToken, nil
+}
+
+// RevokeCallbackToken deletes the token, so it can't be used again
+func RevokeCallbackToken(ctx context.Context, storage common.KeyValueStorage, token string) error {
+	if err := storage.DeletePrefix(ctx, callbackWorkspaceId, callbackTokenKey(token)); err != nil {
+		return fmt.Errorf("failed to revoke callback token: %w", err)
+	}
+	return nil
+}
+
+// VerifySignature checks a signature made with SignPayload, rejecting
+// timestamps more than MaxCallbackSignatureAge away from now
+func VerifySignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("missing signature")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > MaxCallbackSignatureAge || age < -MaxCallbackSignatureAge {
+		return fmt.Errorf("signature timestamp is too old or in the future")
+	}
+	if !hmac.Equal([]byte(SignPayload(secret, timestamp, body)), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// CallbackAction is how a callback reply answers a request for user
+type CallbackAction = string
+
+const (
+	// CallbackActionApprove approves an approval or merge approval request,
+	// with optional feedback in the reply's content
+	CallbackActionApprove CallbackAction = "approve"
+	// CallbackActionReject rejects an approval or merge approval request, with
+	// feedback on what to change in the reply's content
+	CallbackActionReject CallbackAction = "reject"
+	// CallbackActionAnswer answers a free-form request or asks a flow waiting
+	// to continue to do so, with the reply's content
+	CallbackActionAnswer CallbackAction = "answer"
+	// CallbackActionChoose picks one of a multiple-choice request's options,
+	// given as the reply's choice
+	CallbackActionChoose CallbackAction = "choose"
+)
+
+// CallbackReply is the body of a signed request to a callback URL
+type CallbackReply struct {
+	Action  CallbackAction         `json:"action"`
+	Content string                 `json:"content,omitempty"`
+	Choice  string                 `json:"choice,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
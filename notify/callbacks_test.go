@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/common"
+	"sidekick/domain"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackTokens(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	storage := newMemStorage()
+
+	scope := CallbackToken{WorkspaceId: "ws_1", FlowId: "flow_1", FlowActionId: "fa_1"}
+	token, err := IssueCallbackToken(ctx, storage, scope)
+	require.NoError(t, err)
+	other, err := IssueCallbackToken(ctx, storage, scope)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	// only a hash of the token is stored
+	for key := range storage.kv {
+		assert.NotContains(t, key, token)
+	}
+
+	callbackToken, err := LookupCallbackToken(ctx, storage, token)
+	require.NoError(t, err)
+	assert.Equal(t, "fa_1", callbackToken.FlowActionId)
+	assert.WithinDuration(t, time.Now().Add(CallbackTokenTTL), callbackToken.Expires, time.Minute)
+
+	_, err = LookupCallbackToken(ctx, storage, "not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidCallbackToken)
+
+	require.NoError(t, RevokeCallbackToken(ctx, storage, token))
+	_, err = LookupCallbackToken(ctx, storage, token)
+	assert.ErrorIs(t, err, ErrInvalidCallbackToken)
+	_, err = LookupCallbackToken(ctx, storage, other)
+	assert.NoError(t, err)
+
+	scope.Expires = time.Now().Add(-time.Second)
+	expired, err := IssueCallbackToken(ctx, storage, scope)
+	require.NoError(t, err)
+	_, err = LookupCallbackToken(ctx, storage, expired)
+	assert.ErrorIs(t, err, ErrInvalidCallbackToken)
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	body := []byte(`{"action":"approve"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignPayload("s3cret", timestamp, body)
+
+	assert.NoError(t, VerifySignature("s3cret", timestamp, signature, body, now))
+	assert.Error(t, VerifySignature("wrong", timestamp, signature, body, now))
+	assert.Error(t, VerifySignature("s3cret", timestamp, signature, []byte(`{"action":"reject"}`), now))
+	assert.Error(t, VerifySignature("s3cret", timestamp, "", body, now))
+	assert.Error(t, VerifySignature("s3cret", "", signature, body, now))
+	assert.Error(t, VerifySignature("s3cret", "yesterday", signature, body, now))
+	assert.Error(t, VerifySignature("s3cret", timestamp, signature, body, now.Add(MaxCallbackSignatureAge+time.Second)))
+	assert.Error(t, VerifySignature("s3cret", timestamp, signature, body, now.Add(-MaxCallbackSignatureAge-time.Second)))
+}
+
+func TestNotifier_CallbackURL(t *testing.T) {
+	t.Setenv("SIDE_SERVER_URL", "https://sidekick.example.com")
+	t.Setenv("TEST_CALLBACK_SECRET", "s3cret")
+
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received = append(received, event)
+	}))
+	t.Cleanup(server.Close)
+
+	storage := newMemStorage()
+	storage.flows["flow_1"] = domain.Flow{WorkspaceId: "ws_1", Id: "flow_1", ParentId: "task_1"}
+	storage.tasks["task_1"] = domain.Task{WorkspaceId: "ws_1", Id: "task_1", Title: "Fix login"}
+	notifier, err := NewNotifier(common.NotificationConfig{
+		Sinks:          map[string]common.NotificationSinkConfig{"hook": {Type: common.NotificationSinkWebhook, URL: server.URL}},
+		CallbackSecret: "${TEST_CALLBACK_SECRET}",
+	}, storage)
+	require.NoError(t, err)
+
+	notifier.FlowActionChanged(domain.FlowAction{
+		Id:            "fa_1",
+		WorkspaceId:   "ws_1",
+		FlowId:        "flow_1",
+		ActionStatus:  domain.ActionStatusPending,
+		IsHumanAction: true,
+		ActionParams:  map[string]any{"requestKind": "free_form", "requestContent": "Which database?"},
+	})
+	notifier.Wait()
+
+	require.Len(t, received, 1)
+	token, ok := strings.CutPrefix(received[0].CallbackURL, "https://sidekick.example.com/api/v1/callbacks/")
+	require.True(t, ok, received[0].CallbackURL)
+	callbackToken, err := LookupCallbackToken(context.Background(), storage, token)
+	require.NoError(t, err)
+	assert.Equal(t, CallbackToken{WorkspaceId: "ws_1", FlowId: "flow_1", FlowActionId: "fa_1", Expires: callbackToken.Expires}, callbackToken)
+}
@@ -0,0 +1,400 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sidekick/common"
+	"sidekick/domain"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Event is a notification sent to sinks. Webhook sinks receive it as JSON.
+type Event struct {
+	// Id is stable across repeated notifications of the same change, so
+	// receivers can deduplicate them
+	Id           string                   `json:"id"`
+	Type         common.NotificationEvent `json:"type"`
+	WorkspaceId  string                   `json:"workspaceId"`
+	TaskId       string                   `json:"taskId,omitempty"`
+	FlowId       string                   `json:"flowId,omitempty"`
+	FlowActionId string                   `json:"flowActionId,omitempty"`
+	Title        string                   `json:"title"`
+	Message      string                   `json:"message"`
+	// URL links to where the user can act on the event in Sidekick
+	URL string `json:"url,omitempty"`
+	// Status and PreviousStatus are the task or flow statuses involved
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previousStatus,omitempty"`
+	// RequestKind is the kind of request for user, eg "approval"
+	RequestKind string `json:"requestKind,omitempty"`
+	// CallbackURL answers a request for user when sent a signed reply, see
+	// CallbackReply. It's only set when callbacks are enabled, and can be used
+	// once.
+	CallbackURL string    `json:"callbackUrl,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Storage is the storage the notifier needs, to describe events and log their
+// delivery
+type Storage interface {
+	GetTask(ctx context.Context, workspaceId, taskId string) (domain.Task, error)
+	GetFlow(ctx context.Context, workspaceId, flowId string) (domain.Flow, error)
+	common.KeyValueStorage
+}
+
+type DeliveryStatus = string
+
+const (
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+// Delivery records the outcome of sending an event to a sink
+type Delivery struct {
+	EventId   string                   `json:"eventId"`
+	EventType common.NotificationEvent `json:"eventType"`
+	Sink      string                   `json:"sink"`
+	Status    DeliveryStatus           `json:"status"`
+	Attempts  int                      `json:"attempts"`
+	Error     string                   `json:"error,omitempty"`
+	Updated   time.Time                `json:"updated"`
+}
+
+const deliveryKeyPrefix = "notification_delivery:"
+
+func deliveryKey(sink, eventId string) string {
+	return deliveryKeyPrefix + sink + ":" + eventId
+}
+
+const defaultMaxAttempts = 3
+
+// retryBaseDelay is the delay before the first retry, doubling with each
+// subsequent one
+var retryBaseDelay = 2 * time.Second
+
+// maxMessageLength bounds messages built from request content, which can be
+// long, eg when it includes a plan to approve
+const maxMessageLength = 500
+
+type namedSink struct {
+	name   string
+	config common.NotificationSinkConfig
+	sink   Sink
+}
+
+// Notifier sends notifications of changes reported by the service to the
+// configured sinks. Delivery happens in the background, with retries, and is
+// logged in KV storage.
+type Notifier struct {
+	storage          Storage
+	sinks            []namedSink
+	callbacksEnabled bool
+	inFlight         sync.Map
+	wg               sync.WaitGroup
+}
+
+// NewNotifier returns a notifier for the configured sinks
+func NewNotifier(config common.NotificationConfig, storage Storage) (*Notifier, error) {
+	n := &Notifier{storage: storage, callbacksEnabled: config.GetCallbackSecret() != ""}
+	names := make([]string, 0, len(config.Sinks))
+	for name := range config.Sinks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sink, err := NewSink(config.Sinks[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification sink %s: %w", name, err)
+		}
+		n.sinks = append(n.sinks, namedSink{name: name, config: config.Sinks[name], sink: sink})
+	}
+	return n, nil
+}
+
+// Wait blocks until notifications sent so far are delivered or given up on
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+/* implements srv.ChangeNotifier interface */
+func (n *Notifier) TaskChanged(previousStatus domain.TaskStatus, task domain.Task) {
+	// new tasks were created by the user, who needs no notification of them.
+	// The update time tells repeated transitions apart, eg to blocked on each
+	// request for user input, while persisting the same change again, eg when
+	// an activity retries, dedupes.
+	if previousStatus == "" || previousStatus == task.Status {
+		return
+	}
+	n.notify(Event{
+		Id:             fmt.Sprintf("%s:%s:%s->%s:%d", common.NotificationEventTaskStatusChanged, task.Id, previousStatus, task.Status, task.Updated.UnixNano()),
+		Type:           common.NotificationEventTaskStatusChanged,
+		WorkspaceId:    task.WorkspaceId,
+		TaskId:         task.Id,
+		Title:          taskTitle(task),
+		Message:        fmt.Sprintf("Task moved from %s to %s", humanizeStatus(string(previousStatus)), humanizeStatus(string(task.Status))),
+		URL:            common.GetServerURL() + "/kanban",
+		Status:         string(task.Status),
+		PreviousStatus: string(previousStatus),
+	})
+}
+
+/* implements srv.ChangeNotifier interface */
+func (n *Notifier) FlowStatusChanged(previousStatus string, flow domain.Flow) {
+	if flow.Status != "failed" || previousStatus == flow.Status {
+		return
+	}
+	n.notify(Event{
+		Id:             fmt.Sprintf("%s:%s", common.NotificationEventFlowFailed, flow.Id),
+		Type:           common.NotificationEventFlowFailed,
+		WorkspaceId:    flow.WorkspaceId,
+		FlowId:         flow.Id,
+		Title:          "Flow failed",
+		Message:        fmt.Sprintf("The %s flow failed", humanizeStatus(flow.Type)),
+		URL:            flowURL(flow.Id),
+		Status:         flow.Status,
+		PreviousStatus: previousStatus,
+	})
+}
+
+/* implements srv.ChangeNotifier interface */
+func (n *Notifier) FlowActionChanged(flowAction domain.FlowAction) {
+	// pending requests may be persisted more than once, but their event id is
+	// the same, so they're only delivered once
+	if !flowAction.IsHumanAction || flowAction.ActionStatus != domain.ActionStatusPending {
+		return
+	}
+
+	requestKind, _ := flowAction.ActionParams["requestKind"].(string)
+	message, _ := flowAction.ActionParams["requestContent"].(string)
+	message = strings.TrimSpace(message)
+	if message == "" {
+		message = defaultRequestMessage(requestKind)
+	}
+	if len(message) > maxMessageLength {
+		message = strings.ToValidUTF8(message[:maxMessageLength], "") + "…"
+	}
+
+	n.notify(Event{
+		Id:           fmt.Sprintf("%s:%s", common.NotificationEventRequestForUser, flowAction.Id),
+		Type:         common.NotificationEventRequestForUser,
+		WorkspaceId:  flowAction.WorkspaceId,
+		FlowId:       flowAction.FlowId,
+		FlowActionId: flowAction.Id,
+		Title:        "Sidekick needs your input",
+		Message:      message,
+		URL:          flowURL(flowAction.FlowId),
+		RequestKind:  requestKind,
+	})
+}
+
+func defaultRequestMessage(requestKind string) string {
+	switch requestKind {
+	case "approval":
+		return "Approval requested"
+	case "merge_approval":
+		return "Ready to merge, approval requested"
+	case "continue":
+		return "Waiting for you to continue"
+	default:
+		return "Waiting for your response"
+	}
+}
+
+func flowURL(flowId string) string {
+	return common.GetServerURL() + "/flows/" + flowId
+}
+
+func taskTitle(task domain.Task) string {
+	if task.Title != "" {
+		return task.Title
+	}
+	return "Task " + task.Id
+}
+
+func humanizeStatus(status string) string {
+	return strings.ReplaceAll(status, "_", " ")
+}
+
+// notify delivers the event to each sink that receives it, in the background
+func (n *Notifier) notify(event Event) {
+	var sinks []namedSink
+	for _, s := range n.sinks {
+		if s.config.Receives(event.Type) {
+			sinks = append(sinks, s)
+		}
+	}
+	if len(sinks) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx := context.Background()
+		var pending []namedSink
+		for _, s := range sinks {
+			if n.claim(ctx, s, event) {
+				pending = append(pending, s)
+				defer n.inFlight.Delete(event.WorkspaceId + ":" + deliveryKey(s.name, event.Id))
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+		n.describeTask(ctx, &event)
+		n.addCallbackURL(ctx, &event)
+
+		var wg sync.WaitGroup
+		for _, s := range pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n.deliver(ctx, s, event)
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+// claim reports whether the event still needs delivering to the sink, marking
+// it in flight if so. Events already delivered or being delivered to the sink
+// are skipped, as the same change may be persisted more than once, eg when an
+// activity retries.
+func (n *Notifier) claim(ctx context.Context, s namedSink, event Event) bool {
+	key := deliveryKey(s.name, event.Id)
+	if _, loaded := n.inFlight.LoadOrStore(event.WorkspaceId+":"+key, struct{}{}); loaded {
+		return false
+	}
+
+	values, err := n.storage.MGet(ctx, event.WorkspaceId, []string{key})
+	if err == nil && len(values) == 1 && values[0] != nil {
+		var existing Delivery
+		if json.Unmarshal(values[0], &existing) == nil && existing.Status == DeliveryStatusDelivered {
+			n.inFlight.Delete(event.WorkspaceId + ":" + key)
+			return false
+		}
+	}
+	return true
+}
+
+// describeTask adds the task a flow event belongs to, so that notifications
+// say what they're about
+func (n *Notifier) describeTask(ctx context.Context, event *Event) {
+	if event.TaskId != "" || event.FlowId == "" {
+		return
+	}
+	flow, err := n.storage.GetFlow(ctx, event.WorkspaceId, event.FlowId)
+	if err != nil || !strings.HasPrefix(flow.ParentId, "task_") {
+		return
+	}
+	task, err := n.storage.GetTask(ctx, event.WorkspaceId, flow.ParentId)
+	if err != nil {
+		return
+	}
+	event.TaskId = task.Id
+	switch event.Type {
+	case common.NotificationEventRequestForUser:
+		event.Title = "Input needed: " + taskTitle(task)
+	case common.NotificationEventFlowFailed:
+		event.Title = "Flow failed: " + taskTitle(task)
+	}
+}
+
+// addCallbackURL issues a one-time callback token for answering a request for
+// user, when callbacks are enabled
+func (n *Notifier) addCallbackURL(ctx context.Context, event *Event) {
+	if !n.callbacksEnabled || event.Type != common.NotificationEventRequestForUser {
+		return
+	}
+	token, err := IssueCallbackToken(ctx, n.storage, CallbackToken{
+		WorkspaceId:  event.WorkspaceId,
+		FlowId:       event.FlowId,
+		FlowActionId: event.FlowActionId,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("eventId", event.Id).Msg("Failed to issue callback token, notifying without a callback URL")
+		return
+	}
+	event.CallbackURL = common.GetServerURL() + "/api/v1/callbacks/" + token
+}
+
+// deliver sends the event to the sink, retrying with exponential backoff, and
+// logs the outcome
+func (n *Notifier) deliver(ctx context.Context, s namedSink, event Event) {
+	key := deliveryKey(s.name, event.Id)
+	var err error
+	maxAttempts := s.config.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	delivery := Delivery{EventId: event.Id, EventType: event.Type, Sink: s.name}
+	for delivery.Attempts < maxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(retryBaseDelay << (delivery.Attempts - 1))
+		}
+		delivery.Attempts++
+		err = s.sink.Send(ctx, event)
+		if err == nil || errors.As(err, &permanentError{}) {
+			break
+		}
+	}
+
+	delivery.Status = DeliveryStatusDelivered
+	if err != nil {
+		delivery.Status = DeliveryStatusFailed
+		delivery.Error = err.Error()
+		log.Warn().Err(err).Str("sink", s.name).Str("eventId", event.Id).Int("attempts", delivery.Attempts).Msg("Failed to deliver notification")
+	}
+	delivery.Updated = time.Now().UTC()
+
+	deliveryJson, err := json.Marshal(delivery)
+	if err == nil {
+		err = n.storage.MSetRaw(ctx, event.WorkspaceId, map[string][]byte{key: deliveryJson})
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("sink", s.name).Str("eventId", event.Id).Msg("Failed to log notification delivery")
+	}
+}
+
+// Deliveries returns the logged notification deliveries for a workspace,
+// oldest first
+func Deliveries(ctx context.Context, storage common.KeyValueStorage, workspaceId string) ([]Delivery, error) {
+	keys, err := storage.GetKeysWithPrefix(ctx, workspaceId, deliveryKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	values, err := storage.MGet(ctx, workspaceId, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification deliveries: %w", err)
+	}
+
+	deliveries := make([]Delivery, 0, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		var delivery Delivery
+		if err := json.Unmarshal(value, &delivery); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal notification delivery %s: %w", keys[i], err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].Updated.Before(deliveries[j].Updated)
+	})
+	return deliveries, nil
+}
@@ -0,0 +1,327 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sidekick/common"
+	"sidekick/domain"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage is an in-memory Storage holding a fixed set of tasks and flows
+type memStorage struct {
+	mu    sync.Mutex
+	tasks map[string]domain.Task
+	flows map[string]domain.Flow
+	kv    map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{tasks: map[string]domain.Task{}, flows: map[string]domain.Flow{}, kv: map[string][]byte{}}
+}
+
+func (m *memStorage) GetTask(_ context.Context, workspaceId, taskId string) (domain.Task, error) {
+	task, ok := m.tasks[taskId]
+	if !ok {
+		return domain.Task{}, common.ErrNotFound
+	}
+	return task, nil
+}
+
+func (m *memStorage) GetFlow(_ context.Context, workspaceId, flowId string) (domain.Flow, error) {
+	flow, ok := m.flows[flowId]
+	if !ok {
+		return domain.Flow{}, common.ErrNotFound
+	}
+	return flow, nil
+}
+
+func (m *memStorage) MGet(_ context.Context, workspaceId string, keys []string) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.kv[workspaceId+"/"+key]
+	}
+	return values, nil
+}
+
+func (m *memStorage) MSet(_ context.Context, workspaceId string, values map[string]interface{}) error {
+	panic("unused")
+}
+
+func (m *memStorage) MSetRaw(_ context.Context, workspaceId string, values map[string][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, value := range values {
+		m.kv[workspaceId+"/"+key] = value
+	}
+	return nil
+}
+
+func (m *memStorage) DeletePrefix(_ context.Context, workspaceId string, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.kv {
+		if strings.HasPrefix(key, workspaceId+"/"+prefix) {
+			delete(m.kv, key)
+		}
+	}
+	return nil
+}
+
+func (m *memStorage) GetKeysWithPrefix(_ context.Context, workspaceId string, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.kv {
+		if rest, ok := strings.CutPrefix(key, workspaceId+"/"); ok && strings.HasPrefix(rest, prefix) {
+			keys = append(keys, rest)
+		}
+	}
+	return keys, nil
+}
+
+// fastRetries shortens the retry delay for the duration of the test
+func fastRetries(t *testing.T) {
+	original := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = original })
+}
+
+func newTestNotifier(t *testing.T, storage Storage, sinks map[string]common.NotificationSinkConfig) *Notifier {
+	t.Helper()
+	notifier, err := NewNotifier(common.NotificationConfig{Sinks: sinks}, storage)
+	require.NoError(t, err)
+	return notifier
+}
+
+func TestNotifier_RequestForUser(t *testing.T) {
+	fastRetries(t)
+	t.Setenv("SIDE_SERVER_URL", "https://sidekick.example.com")
+
+	var received []Event
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	storage := newMemStorage()
+	storage.flows["flow_1"] = domain.Flow{WorkspaceId: "ws_1", Id: "flow_1", ParentId: "task_1"}
+	storage.tasks["task_1"] = domain.Task{WorkspaceId: "ws_1", Id: "task_1", Title: "Fix login"}
+	notifier := newTestNotifier(t, storage, map[string]common.NotificationSinkConfig{
+		"hook":   {Type: common.NotificationSinkWebhook, URL: server.URL},
+		"failed": {Type: common.NotificationSinkWebhook, URL: server.URL, Events: []string{common.NotificationEventFlowFailed}},
+	})
+
+	flowAction := domain.FlowAction{
+		Id:            "fa_1",
+		WorkspaceId:   "ws_1",
+		FlowId:        "flow_1",
+		ActionType:    "user_request.approve.plan",
+		ActionStatus:  domain.ActionStatusPending,
+		IsHumanAction: true,
+		ActionParams:  map[string]any{"requestKind": "approval", "requestContent": "Approve the plan?"},
+	}
+	notifier.FlowActionChanged(flowAction)
+	// persisting the pending action again, eg on an activity retry, doesn't
+	// notify again
+	notifier.FlowActionChanged(flowAction)
+	notifier.Wait()
+	notifier.FlowActionChanged(flowAction)
+	notifier.Wait()
+
+	completed := flowAction
+	completed.ActionStatus = domain.ActionStatusComplete
+	notifier.FlowActionChanged(completed)
+	notifier.FlowActionChanged(domain.FlowAction{Id: "fa_2", WorkspaceId: "ws_1", ActionStatus: domain.ActionStatusPending})
+	notifier.Wait()
+
+	require.Len(t, received, 1)
+	event := received[0]
+	assert.Equal(t, "request_for_user:fa_1", event.Id)
+	assert.Equal(t, "task_1", event.TaskId)
+	assert.Equal(t, "Input needed: Fix login", event.Title)
+	assert.Equal(t, "Approve the plan?", event.Message)
+	assert.Equal(t, "approval", event.RequestKind)
+	assert.Equal(t, "https://sidekick.example.com/flows/flow_1", event.URL)
+	assert.False(t, event.Time.IsZero())
+
+	deliveries, err := Deliveries(context.Background(), storage, "ws_1")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "hook", deliveries[0].Sink)
+	assert.Equal(t, DeliveryStatusDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestNotifier_RetriesAndLogsFailures(t *testing.T) {
+	fastRetries(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var rejectedRequests atomic.Int32
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejectedRequests.Add(1)
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	t.Cleanup(rejecting.Close)
+
+	storage := newMemStorage()
+	notifier := newTestNotifier(t, storage, map[string]common.NotificationSinkConfig{
+		"flaky":    {Type: common.NotificationSinkSlack, URL: server.URL, MaxAttempts: 5},
+		"rejected": {Type: common.NotificationSinkSlack, URL: rejecting.URL},
+	})
+
+	task := domain.Task{WorkspaceId: "ws_1", Id: "task_1", Title: "Fix login", Status: domain.TaskStatusInReview}
+	notifier.TaskChanged(domain.TaskStatusInProgress, task)
+	notifier.Wait()
+
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, int32(1), rejectedRequests.Load(), "client errors aren't retried")
+
+	deliveries, err := Deliveries(context.Background(), storage, "ws_1")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	bySink := map[string]Delivery{}
+	for _, delivery := range deliveries {
+		bySink[delivery.Sink] = delivery
+		assert.Equal(t, common.NotificationEventTaskStatusChanged, delivery.EventType)
+	}
+	assert.Equal(t, DeliveryStatusDelivered, bySink["flaky"].Status)
+	assert.Equal(t, 3, bySink["flaky"].Attempts)
+	assert.Equal(t, DeliveryStatusFailed, bySink["rejected"].Status)
+	assert.Equal(t, 1, bySink["rejected"].Attempts)
+	assert.Contains(t, bySink["rejected"].Error, "no such hook")
+
+	// a failed delivery is attempted again when the same event recurs
+	notifier.TaskChanged(domain.TaskStatusInProgress, task)
+	notifier.Wait()
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, int32(2), rejectedRequests.Load())
+}
+
+func TestNotifier_TaskAndFlowChanges(t *testing.T) {
+	t.Setenv("SIDE_SERVER_URL", "")
+	t.Setenv("SIDE_SERVER_HOST", "")
+	t.Setenv("SIDE_SERVER_PORT", "")
+	var received []Event
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	storage := newMemStorage()
+	storage.tasks["task_1"] = domain.Task{WorkspaceId: "ws_1", Id: "task_1", Title: "Fix login"}
+	notifier := newTestNotifier(t, storage, map[string]common.NotificationSinkConfig{
+		"hook": {Type: common.NotificationSinkWebhook, URL: server.URL},
+	})
+
+	task := domain.Task{WorkspaceId: "ws_1", Id: "task_1", Title: "Fix login", Status: domain.TaskStatusToDo}
+	// new tasks and unchanged statuses aren't notified
+	notifier.TaskChanged("", task)
+	notifier.TaskChanged(task.Status, task)
+	notifier.Wait()
+	assert.Empty(t, received)
+
+	inProgress := task
+	inProgress.Status = domain.TaskStatusInProgress
+	inProgress.Updated = time.Unix(0, 42)
+	notifier.TaskChanged(task.Status, inProgress)
+	notifier.Wait()
+	require.Len(t, received, 1)
+	assert.Equal(t, "task_status_changed:task_1:to_do->in_progress:42", received[0].Id)
+	assert.Equal(t, "Fix login", received[0].Title)
+	assert.Equal(t, "Task moved from to do to in progress", received[0].Message)
+	assert.Equal(t, "in_progress", received[0].Status)
+	assert.Equal(t, "to_do", received[0].PreviousStatus)
+	assert.Equal(t, "http://127.0.0.1:8855/kanban", received[0].URL)
+
+	flow := domain.Flow{WorkspaceId: "ws_1", Id: "flow_1", ParentId: "task_1", Type: domain.FlowTypeBasicDev, Status: "in_progress"}
+	storage.flows["flow_1"] = flow
+	completed := flow
+	completed.Status = "completed"
+	notifier.FlowStatusChanged(flow.Status, completed)
+	failed := flow
+	failed.Status = "failed"
+	notifier.FlowStatusChanged(flow.Status, failed)
+	notifier.Wait()
+	require.Len(t, received, 2)
+	assert.Equal(t, "flow_failed:flow_1", received[1].Id)
+	assert.Equal(t, "Flow failed: Fix login", received[1].Title)
+	assert.Equal(t, "The basic dev flow failed", received[1].Message)
+	assert.Equal(t, "task_1", received[1].TaskId)
+}
+
+func TestNotifier_RepeatedTaskTransitions(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	t.Cleanup(server.Close)
+
+	notifier := newTestNotifier(t, newMemStorage(), map[string]common.NotificationSinkConfig{
+		"hook": {Type: common.NotificationSinkWebhook, URL: server.URL, Events: []string{common.NotificationEventTaskStatusChanged}},
+	})
+
+	// tasks are blocked on each request for user input
+	task := domain.Task{WorkspaceId: "ws_1", Id: "task_1", Status: domain.TaskStatusInProgress, Updated: time.Unix(100, 0)}
+	transition := func(status domain.TaskStatus) {
+		previousStatus := task.Status
+		task.Status = status
+		task.Updated = task.Updated.Add(time.Second)
+		notifier.TaskChanged(previousStatus, task)
+		notifier.Wait()
+	}
+	transition(domain.TaskStatusBlocked)
+	// persisting the same change again doesn't notify again
+	notifier.TaskChanged(domain.TaskStatusInProgress, task)
+	notifier.Wait()
+	transition(domain.TaskStatusInProgress)
+	transition(domain.TaskStatusBlocked)
+
+	deliveries, err := Deliveries(context.Background(), notifier.storage, "ws_1")
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 3)
+	blocked := 0
+	for _, delivery := range deliveries {
+		if strings.Contains(delivery.EventId, "->blocked") {
+			blocked++
+		}
+	}
+	assert.Equal(t, 2, blocked)
+	assert.Equal(t, int32(3), received.Load())
+}
+
+func TestNewNotifier_InvalidSink(t *testing.T) {
+	t.Parallel()
+	_, err := NewNotifier(common.NotificationConfig{Sinks: map[string]common.NotificationSinkConfig{
+		"phone": {Type: common.NotificationSinkNtfy},
+	}}, newMemStorage())
+	assert.ErrorContains(t, err, "invalid notification sink phone: url is required for ntfy sinks")
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"sidekick/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sink delivers notifications to a single destination
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// permanentError is a delivery failure that retrying won't fix, eg a rejected
+// request
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// commandTimeout bounds how long a command sink's command may run
+const commandTimeout = 30 * time.Second
+
+// NewSink returns the sink described by the given config, expanding
+// environment variable references in its secrets and headers
+func NewSink(config common.NotificationSinkConfig) (Sink, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	switch config.Type {
+	case common.NotificationSinkWebhook:
+		headers := make(map[string]string, len(config.Headers))
+		for key, value := range config.Headers {
+			headers[key] = os.ExpandEnv(value)
+		}
+		return webhookSink{url: config.URL, secret: os.ExpandEnv(config.Secret), headers: headers}, nil
+	case common.NotificationSinkSlack:
+		return slackSink{url: config.URL}, nil
+	case common.NotificationSinkNtfy:
+		return ntfySink{url: config.URL, token: os.ExpandEnv(config.Token)}, nil
+	case common.NotificationSinkCommand:
+		return commandSink{command: config.Command, args: config.Args}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", config.Type)
+	}
+}
+
+// SignPayload returns the signature sent in the X-Sidekick-Signature header:
+// a hex-encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed with
+// the secret and prefixed with "sha256=". Receivers should recompute it,
+// compare in constant time and reject stale timestamps.
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSink posts events as JSON to an arbitrary endpoint
+type webhookSink struct {
+	url     string
+	secret  string
+	headers map[string]string
+}
+
+func (s webhookSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to marshal event: %w", err)}
+	}
+	headers := maps.Clone(s.headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers["Content-Type"] = "application/json"
+	headers["X-Sidekick-Event"] = event.Type
+	headers["X-Sidekick-Delivery"] = event.Id
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers["X-Sidekick-Timestamp"] = timestamp
+		headers["X-Sidekick-Signature"] = SignPayload(s.secret, timestamp, body)
+	}
+	return post(ctx, s.url, body, headers)
+}
+
+// slackSink posts a message to a Slack-compatible incoming webhook, which
+// Mattermost, Rocket.Chat and others also accept
+type slackSink struct {
+	url string
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (s slackSink) Send(ctx context.Context, event Event) error {
+	text := fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(event.Title), slackEscaper.Replace(event.Message))
+	if event.URL != "" {
+		text += fmt.Sprintf("\n<%s|Open in Sidekick>", event.URL)
+	}
+	if event.CallbackURL != "" {
+		text += fmt.Sprintf("\n<%s|Answer>", event.CallbackURL)
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to marshal slack message: %w", err)}
+	}
+	return post(ctx, s.url, body, map[string]string{"Content-Type": "application/json"})
+}
+
+// ntfySink publishes a message to an ntfy topic
+type ntfySink struct {
+	url   string
+	token string
+}
+
+var ntfyTags = map[common.NotificationEvent]string{
+	common.NotificationEventRequestForUser:    "raising_hand",
+	common.NotificationEventTaskStatusChanged: "clipboard",
+	common.NotificationEventFlowFailed:        "x",
+}
+
+func (s ntfySink) Send(ctx context.Context, event Event) error {
+	headers := map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+		// header values must be ASCII, titles may not be
+		"Title": mime.QEncoding.Encode("utf-8", event.Title),
+		"Tags":  ntfyTags[event.Type],
+	}
+	if event.URL != "" {
+		headers["Click"] = event.URL
+	}
+	if event.CallbackURL != "" {
+		headers["Actions"] = "view, Answer, " + event.CallbackURL
+	}
+	if event.Type != common.NotificationEventTaskStatusChanged {
+		headers["Priority"] = "high"
+	}
+	if s.token != "" {
+		headers["Authorization"] = "Bearer " + s.token
+	}
+	return post(ctx, s.url, []byte(event.Message), headers)
+}
+
+func post(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("User-Agent", "sidekick")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
+
+// commandSink runs a command per event, eg notify-send or osascript to show a
+// desktop notification
+type commandSink struct {
+	command string
+	args    []string
+}
+
+// commandEnv returns the SIDEKICK_* environment variables describing the event
+func commandEnv(event Event) map[string]string {
+	return map[string]string{
+		"SIDEKICK_EVENT_ID":     event.Id,
+		"SIDEKICK_EVENT_TYPE":   event.Type,
+		"SIDEKICK_TITLE":        event.Title,
+		"SIDEKICK_MESSAGE":      event.Message,
+		"SIDEKICK_URL":          event.URL,
+		"SIDEKICK_CALLBACK_URL": event.CallbackURL,
+		"SIDEKICK_WORKSPACE_ID": event.WorkspaceId,
+		"SIDEKICK_TASK_ID":      event.TaskId,
+		"SIDEKICK_FLOW_ID":      event.FlowId,
+	}
+}
+
+func (s commandSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to marshal event: %w", err)}
+	}
+
+	vars := commandEnv(event)
+	expand := func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = os.Expand(arg, expand)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.command, args...)
+	cmd.Env = os.Environ()
+	for key, value := range vars {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			// the command couldn't be started, eg it isn't installed
+			return permanentError{err}
+		}
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sidekick/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = Event{
+	Id:          "request_for_user:fa_1",
+	Type:        common.NotificationEventRequestForUser,
+	WorkspaceId: "ws_1",
+	FlowId:      "flow_1",
+	Title:       "Input needed: Fix <login>",
+	Message:     "Approve the plan?",
+	URL:         "http://127.0.0.1:8855/flows/flow_1",
+	RequestKind: "approval",
+}
+
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, *[]capturedRequest) {
+	t.Helper()
+	var requests []capturedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests = append(requests, capturedRequest{header: r.Header, body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	server, requests := newCaptureServer(t, http.StatusNoContent)
+
+	sink, err := NewSink(common.NotificationSinkConfig{
+		Type:    common.NotificationSinkWebhook,
+		URL:     server.URL,
+		Secret:  "${TEST_WEBHOOK_SECRET}",
+		Headers: map[string]string{"X-Team": "platform"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), testEvent))
+
+	require.Len(t, *requests, 1)
+	request := (*requests)[0]
+	var sent Event
+	require.NoError(t, json.Unmarshal(request.body, &sent))
+	assert.Equal(t, testEvent, sent)
+	assert.Equal(t, "platform", request.header.Get("X-Team"))
+	assert.Equal(t, common.NotificationEventRequestForUser, request.header.Get("X-Sidekick-Event"))
+	assert.Equal(t, testEvent.Id, request.header.Get("X-Sidekick-Delivery"))
+
+	timestamp := request.header.Get("X-Sidekick-Timestamp")
+	require.NotEmpty(t, timestamp)
+	assert.Equal(t, SignPayload("s3cret", timestamp, request.body), request.header.Get("X-Sidekick-Signature"))
+	assert.NotEqual(t, SignPayload("wrong", timestamp, request.body), request.header.Get("X-Sidekick-Signature"))
+}
+
+func TestSlackSink(t *testing.T) {
+	t.Parallel()
+	server, requests := newCaptureServer(t, http.StatusOK)
+
+	sink, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkSlack, URL: server.URL})
+	require.NoError(t, err)
+	event := testEvent
+	event.CallbackURL = "http://127.0.0.1:8855/api/v1/callbacks/tok"
+	require.NoError(t, sink.Send(context.Background(), event))
+
+	var message map[string]string
+	require.NoError(t, json.Unmarshal((*requests)[0].body, &message))
+	assert.Equal(t, "*Input needed: Fix &lt;login&gt;*\nApprove the plan?\n<http://127.0.0.1:8855/flows/flow_1|Open in Sidekick>\n<http://127.0.0.1:8855/api/v1/callbacks/tok|Answer>", message["text"])
+}
+
+func TestNtfySink(t *testing.T) {
+	t.Parallel()
+	server, requests := newCaptureServer(t, http.StatusOK)
+
+	sink, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkNtfy, URL: server.URL, Token: "tk_123"})
+	require.NoError(t, err)
+
+	event := testEvent
+	event.Title = "Input needed: Corriger l'été"
+	event.CallbackURL = "http://127.0.0.1:8855/api/v1/callbacks/tok"
+	require.NoError(t, sink.Send(context.Background(), event))
+
+	request := (*requests)[0]
+	assert.Equal(t, "Approve the plan?", string(request.body))
+	assert.Equal(t, "=?utf-8?q?Input_needed:_Corriger_l'=C3=A9t=C3=A9?=", request.header.Get("Title"))
+	assert.Equal(t, event.URL, request.header.Get("Click"))
+	assert.Equal(t, "view, Answer, http://127.0.0.1:8855/api/v1/callbacks/tok", request.header.Get("Actions"))
+	assert.Equal(t, "high", request.header.Get("Priority"))
+	assert.Equal(t, "raising_hand", request.header.Get("Tags"))
+	assert.Equal(t, "Bearer tk_123", request.header.Get("Authorization"))
+}
+
+func TestHTTPSinkErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadGateway, false},
+	} {
+		server, _ := newCaptureServer(t, tc.status)
+		sink, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkSlack, URL: server.URL})
+		require.NoError(t, err)
+
+		err = sink.Send(context.Background(), testEvent)
+		require.Error(t, err)
+		assert.Equal(t, tc.permanent, errors.As(err, &permanentError{}), "status %d", tc.status)
+	}
+}
+
+func TestCommandSink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+
+	// the event is passed on stdin
+	stdinPath := filepath.Join(dir, "stdin")
+	sink, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkCommand, Command: "tee", Args: []string{stdinPath}})
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), testEvent))
+	stdin, err := os.ReadFile(stdinPath)
+	require.NoError(t, err)
+	eventJson, err := json.Marshal(testEvent)
+	require.NoError(t, err)
+	assert.Equal(t, string(eventJson), string(stdin))
+
+	// and via environment variables, which args may reference
+	outputPath := filepath.Join(dir, "output")
+	sink, err = NewSink(common.NotificationSinkConfig{
+		Type:    common.NotificationSinkCommand,
+		Command: "sh",
+		Args:    []string{"-c", `printf '%s|' "${SIDEKICK_TITLE}" > "${OUTPUT}"; env | grep ^SIDEKICK_URL= >> "${OUTPUT}"`},
+	})
+	require.NoError(t, err)
+	t.Setenv("OUTPUT", outputPath)
+	require.NoError(t, sink.Send(context.Background(), testEvent))
+	output, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "Input needed: Fix <login>|SIDEKICK_URL=http://127.0.0.1:8855/flows/flow_1\n", string(output))
+
+	failing, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkCommand, Command: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}})
+	require.NoError(t, err)
+	err = failing.Send(context.Background(), testEvent)
+	assert.ErrorContains(t, err, "oops")
+	assert.False(t, errors.As(err, &permanentError{}))
+
+	missing, err := NewSink(common.NotificationSinkConfig{Type: common.NotificationSinkCommand, Command: "sidekick-no-such-command"})
+	require.NoError(t, err)
+	err = missing.Send(context.Background(), testEvent)
+	assert.True(t, errors.As(err, &permanentError{}))
+}
@@ -46,7 +46,7 @@ func (ca *ChatHistoryActivities) ManageV3(
 		return nil, fmt.Errorf("failed to manage chat history: %w", err)
 	}
 
-	// Apply cache-control breakpoints (mirrors legacy behavior)
+	// Apply cache-control breakpoints, used by providers that honor them
 	applyCacheControlBreakpointsLlm2(managedMessages)
 
 	// Preserve refs for unchanged messages, compute changed indices
@@ -238,33 +238,10 @@ func (ca *ChatHistoryActivities) ExtractVisibleCodeBlocks(
 	return codeBlocks, nil
 }
 
-// applyCacheControlBreakpointsLlm2 sets CacheControl on llm2 messages.
-// It clears all existing CacheControl values and sets "ephemeral" on the
-// first and last messages (mirroring the current legacy behavior).
+// applyCacheControlBreakpointsLlm2 replaces the CacheControl values on llm2
+// messages with freshly planned cache breakpoints.
 func applyCacheControlBreakpointsLlm2(messages []llm2.Message) {
-	if len(messages) == 0 {
-		return
-	}
-
-	// Clear all existing CacheControl values
-	for i := range messages {
-		for j := range messages[i].Content {
-			messages[i].Content[j].CacheControl = ""
-		}
-	}
-
-	// Set ephemeral on all blocks of the first message
-	for j := range messages[0].Content {
-		messages[0].Content[j].CacheControl = "ephemeral"
-	}
-
-	// Set ephemeral on all blocks of the last message (if different from first)
-	lastIdx := len(messages) - 1
-	if lastIdx > 0 {
-		for j := range messages[lastIdx].Content {
-			messages[lastIdx].Content[j].CacheControl = "ephemeral"
-		}
-	}
+	copy(messages, llm2.PlanCacheBreakpoints(messages))
 }
 
 // deepCopyMessages creates a deep copy of a message slice for equality comparison.
@@ -41,6 +41,8 @@ func GetEmbedder(config common.ModelConfig) (embedding.Embedder, error) {
 			}
 		}
 		return nil, fmt.Errorf("configuration not found for provider named: %s", config.Provider)
+	case llm.ToolChatProviderType(common.LocalEmbeddingProvider):
+		embedder = &embedding.LocalEmbedder{}
 	case llm.ToolChatProviderType("mock"):
 		return &embedding.MockEmbedder{}, nil
 	default:
@@ -193,6 +195,10 @@ func constructEmbeddingKey(options embeddingKeyOptions) (string, error) {
 			{
 				model = embedding.GoogleDefaultModel
 			}
+		case common.LocalEmbeddingProvider:
+			{
+				model = embedding.LocalDefaultModel
+			}
 		default:
 			{
 				return "", fmt.Errorf("No embedding model given for provider %s", options.provider)
@@ -0,0 +1,211 @@
+package persisted_ai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"sidekick/utils"
+
+	"github.com/kelindar/binary"
+)
+
+// BM25 parameters, using the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// LexicalIndexes holds in-memory BM25 indexes of content, one per workspace
+// and content type. Like VectorIndexes, they are updated incrementally with the
+// content of subkeys they don't have yet, which never changes for a subkey.
+type LexicalIndexes struct {
+	mu      sync.Mutex
+	indexes map[lexicalIndexId]*lexicalIndex
+}
+
+func NewLexicalIndexes() *LexicalIndexes {
+	return &LexicalIndexes{indexes: make(map[lexicalIndexId]*lexicalIndex)}
+}
+
+type lexicalIndexId struct {
+	workspaceId string
+	contentType string
+}
+
+type lexicalIndex struct {
+	mu   sync.Mutex
+	docs map[string]lexicalDocument
+}
+
+// lexicalDocument holds the term frequencies of the content of a subkey
+type lexicalDocument struct {
+	termFreqs map[string]int
+	length    int
+}
+
+func newLexicalIndex() *lexicalIndex {
+	return &lexicalIndex{docs: make(map[string]lexicalDocument)}
+}
+
+func (li *LexicalIndexes) get(workspaceId, contentType string) *lexicalIndex {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	id := lexicalIndexId{workspaceId: workspaceId, contentType: contentType}
+	index, ok := li.indexes[id]
+	if !ok {
+		index = newLexicalIndex()
+		li.indexes[id] = index
+	}
+	return index
+}
+
+type lexicalSearchOptions struct {
+	WorkspaceId string
+	ContentType string
+	Subkeys     []string
+	Query       string
+	Limit       uint
+}
+
+// lexicalSearch ranks the given subkeys by the BM25 score of their content for
+// the query, omitting subkeys whose content doesn't match any query term.
+// Scores only depend on the given subkeys, so they're the same whatever else
+// the index holds. Without indexes, a temporary index is built for the search.
+func (ra *RagActivities) lexicalSearch(ctx context.Context, options lexicalSearchOptions) ([]string, error) {
+	var index *lexicalIndex
+	if ra.LexicalIndexes != nil {
+		index = ra.LexicalIndexes.get(options.WorkspaceId, options.ContentType)
+	} else {
+		index = newLexicalIndex()
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	subkeys := make([]string, 0, len(options.Subkeys))
+	requested := make(map[string]bool, len(options.Subkeys))
+	missingContentKeys := make([]string, 0)
+	missingSubkeys := make([]string, 0)
+	for _, subkey := range options.Subkeys {
+		if requested[subkey] {
+			continue
+		}
+		requested[subkey] = true
+		subkeys = append(subkeys, subkey)
+		if _, ok := index.docs[subkey]; !ok {
+			missingSubkeys = append(missingSubkeys, subkey)
+			missingContentKeys = append(missingContentKeys, fmt.Sprintf("%s:%s", options.ContentType, subkey))
+		}
+	}
+
+	if len(missingContentKeys) > 0 {
+		values, err := ra.DatabaseAccessor.MGet(ctx, options.WorkspaceId, missingContentKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content for lexical index: %w", err)
+		}
+		for i, value := range values {
+			if value == nil {
+				return nil, fmt.Errorf("missing value for content key: %s", missingContentKeys[i])
+			}
+			var text string
+			if err := binary.Unmarshal(value, &text); err != nil {
+				return nil, fmt.Errorf("value for key %s failed to unmarshal: %w", missingContentKeys[i], err)
+			}
+			index.docs[missingSubkeys[i]] = newLexicalDocument(text)
+		}
+	}
+
+	// drop content that is no longer requested once it makes up most of the
+	// index, as with vector indexes
+	if len(index.docs) > 2*len(subkeys) {
+		for subkey := range index.docs {
+			if !requested[subkey] {
+				delete(index.docs, subkey)
+			}
+		}
+	}
+
+	return index.rank(subkeys, options.Query, options.Limit), nil
+}
+
+func newLexicalDocument(text string) lexicalDocument {
+	terms := utils.CodeTerms(text)
+	termFreqs := make(map[string]int)
+	for _, term := range terms {
+		termFreqs[term]++
+	}
+	return lexicalDocument{termFreqs: termFreqs, length: len(terms)}
+}
+
+func (index *lexicalIndex) rank(subkeys []string, query string, limit uint) []string {
+	if len(subkeys) == 0 {
+		return []string{}
+	}
+	if limit == 0 {
+		limit = DefaultVectorSearchLimit
+	}
+
+	queryTerms := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, term := range utils.CodeTerms(query) {
+		if !seen[term] {
+			seen[term] = true
+			queryTerms = append(queryTerms, term)
+		}
+	}
+
+	totalLength := 0
+	docFreqs := make(map[string]int, len(queryTerms))
+	for _, subkey := range subkeys {
+		doc := index.docs[subkey]
+		totalLength += doc.length
+		for _, term := range queryTerms {
+			if doc.termFreqs[term] > 0 {
+				docFreqs[term]++
+			}
+		}
+	}
+	numDocs := float64(len(subkeys))
+	avgLength := float64(totalLength) / numDocs
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	type scoredSubkey struct {
+		subkey string
+		score  float64
+	}
+	scored := make([]scoredSubkey, 0)
+	for _, subkey := range subkeys {
+		doc := index.docs[subkey]
+		score := 0.0
+		for _, term := range queryTerms {
+			termFreq := float64(doc.termFreqs[term])
+			if termFreq == 0 {
+				continue
+			}
+			docFreq := float64(docFreqs[term])
+			idf := math.Log(1 + (numDocs-docFreq+0.5)/(docFreq+0.5))
+			score += idf * termFreq * (bm25K1 + 1) / (termFreq + bm25K1*(1-bm25B+bm25B*float64(doc.length)/avgLength))
+		}
+		if score > 0 {
+			scored = append(scored, scoredSubkey{subkey, score})
+		}
+	}
+
+	// stable, so that ties keep the order the subkeys were given in
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	results := make([]string, 0, min(len(scored), int(limit)))
+	for _, s := range scored {
+		if uint(len(results)) == limit {
+			break
+		}
+		results = append(results, s.subkey)
+	}
+	return results
+}
@@ -0,0 +1,77 @@
+package persisted_ai
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLexicalSearch(t *testing.T) {
+	ctx := context.Background()
+	dbAccessor := newTestDB(t)
+	wsID := "test-lexical-ws"
+	contentType := "file_signature"
+
+	contents := map[string]string{
+		"summary":  "dev/code_context.go\nfunc GetRankedRepoSummary(dCtx DevContext, rankQuery string, charLimit int) (string, error)",
+		"outline":  "persisted_ai/rag_activities.go\nfunc (ra *RagActivities) RankedDirSignatureOutline(ctx context.Context, options RankedDirSignatureOutlineOptions) (string, error)",
+		"vector":   "persisted_ai/vector_activities.go\nfunc (va VectorActivities) VectorSearch(options VectorSearchOptions) ([]string, error)",
+		"repo":     "coding/git/repo.go\nfunc OpenRepo(dir string) (Repo, error)",
+		"noterms":  "README.md",
+		"repoRepo": "coding/git/repo_list.go\nfunc ListRepos() []Repo\nfunc FindRepo(name string) Repo",
+	}
+	kvs := make(map[string]interface{}, len(contents))
+	for subkey, content := range contents {
+		kvs[fmt.Sprintf("%s:%s", contentType, subkey)] = content
+	}
+	require.NoError(t, dbAccessor.MSet(ctx, wsID, kvs))
+
+	subkeys := []string{"summary", "outline", "vector", "repo", "noterms", "repoRepo"}
+	for _, lexicalIndexes := range []*LexicalIndexes{nil, NewLexicalIndexes()} {
+		ra := RagActivities{DatabaseAccessor: dbAccessor, LexicalIndexes: lexicalIndexes}
+
+		// exact identifiers rank first, and subkeys without matching terms are omitted
+		results, err := ra.lexicalSearch(ctx, lexicalSearchOptions{
+			WorkspaceId: wsID,
+			ContentType: contentType,
+			Subkeys:     subkeys,
+			Query:       "Update GetRankedRepoSummary to include more files",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Equal(t, "summary", results[0])
+		assert.NotContains(t, results, "noterms")
+		assert.NotContains(t, results, "vector")
+
+		results, err = ra.lexicalSearch(ctx, lexicalSearchOptions{
+			WorkspaceId: wsID,
+			ContentType: contentType,
+			Subkeys:     subkeys,
+			Query:       "vector search",
+			Limit:       1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"vector"}, results)
+
+		// only the given subkeys are ranked
+		results, err = ra.lexicalSearch(ctx, lexicalSearchOptions{
+			WorkspaceId: wsID,
+			ContentType: contentType,
+			Subkeys:     []string{"repo", "outline", "repo"},
+			Query:       "repo",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo"}, results)
+
+		_, err = ra.lexicalSearch(ctx, lexicalSearchOptions{
+			WorkspaceId: wsID,
+			ContentType: contentType,
+			Subkeys:     []string{"missing"},
+			Query:       "repo",
+		})
+		assert.ErrorContains(t, err, "missing value for content key")
+	}
+}
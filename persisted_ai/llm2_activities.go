@@ -14,6 +14,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 // StreamInput is the activity input for LLM streaming that carries chat history for hydration.
@@ -117,13 +118,6 @@ func (la *Llm2Activities) Stream(ctx context.Context, input StreamInput) (*llm2.
 		}
 	}()
 
-	provider, err := getLlm2Provider(input.Options.ModelConfig, input.Providers)
-	if err != nil {
-		close(eventChan)
-		log.Error().Err(err).Msg("failed to get llm2 provider")
-		return nil, err
-	}
-
 	llm2History, ok := input.ChatHistory.History.(*Llm2ChatHistory)
 	if !ok {
 		close(eventChan)
@@ -137,17 +131,74 @@ func (la *Llm2Activities) Stream(ctx context.Context, input StreamInput) (*llm2.
 		messages = mapMessagesToolNames(messages, input.ToolNameMapping)
 	}
 
-	request := llm2.StreamRequest{
-		Messages:      messages,
-		Options:       options,
-		SecretManager: input.Secrets.SecretManager,
-	}
+	// the configured model is tried first, then each fallback model in turn
+	// when a provider is rate limited or overloaded
+	candidates := append([]common.ModelConfig{input.Options.ModelConfig}, input.Options.ModelConfig.Fallbacks...)
+	var fallbackAttempts []llm2.FallbackAttempt
+	var response *llm2.MessageResponse
+	var modelConfig common.ModelConfig
+	var err error
+	for i, candidate := range candidates {
+		modelConfig = candidate
+		modelConfig.Fallbacks = nil
+		if i > 0 && !common.GetModelMetadata(modelConfig.Provider, modelConfig.Model).Reasoning {
+			modelConfig.ReasoningEffort = ""
+		}
+
+		var provider llm2.Provider
+		provider, err = getLlm2Provider(modelConfig, input.Providers)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get llm2 provider")
+		} else {
+			request := llm2.StreamRequest{
+				Messages:      messages,
+				Options:       options,
+				SecretManager: input.Secrets.SecretManager,
+			}
+			request.Options.ModelConfig = modelConfig
+			attempt := newAttemptStream(eventChan)
+			response, err = llm2.StreamStructured(ctx, provider, request, attempt.events)
+			streamed := attempt.finish(err == nil)
+			if err == nil {
+				break
+			}
+			if streamed {
+				// falling back now would mix the next model's reply into the
+				// partial one already streamed
+				break
+			}
+		}
 
-	response, err := provider.Stream(ctx, request, eventChan)
+		errorClass := llm2.ClassifyError(err)
+		if i == len(candidates)-1 || !errorClass.ShouldFallback() || ctx.Err() != nil {
+			break
+		}
+		log.Warn().Err(err).
+			Str("provider", modelConfig.Provider).
+			Str("model", modelConfig.Model).
+			Str("errorClass", string(errorClass)).
+			Msg("falling back to the next configured model")
+		fallbackAttempts = append(fallbackAttempts, llm2.FallbackAttempt{
+			Provider:   modelConfig.Provider,
+			Model:      modelConfig.Model,
+			ErrorClass: errorClass,
+			Error:      err.Error(),
+		})
+		response = nil
+	}
 	close(eventChan)
 
+	if err != nil {
+		if errorClass := llm2.ClassifyError(err); !errorClass.Retryable() {
+			// retrying the same request against the same model won't help
+			return response, temporal.NewNonRetryableApplicationError(err.Error(), string(errorClass), err)
+		}
+	}
+
 	if response != nil {
-		response.Provider = input.Options.ModelConfig.Provider
+		response.Provider = modelConfig.Provider
+		response.Cost = common.CalculateLlmCost(modelConfig.Provider, common.Usage(response.Usage), response.Model, modelConfig.Model)
+		response.FallbackAttempts = fallbackAttempts
 		if input.ToolNameMapping != nil {
 			response.Output = reverseMapMessageToolNames(response.Output, input.ToolNameMapping)
 		}
@@ -157,6 +208,61 @@ func (la *Llm2Activities) Stream(ctx context.Context, input StreamInput) (*llm2.
 	return response, err
 }
 
+// attemptStream passes on the events of one model attempt, holding them back
+// until the first delta arrives. Providers usually fail before streaming any
+// content, so such failed attempts can fall back to the next model without
+// their events mixing with the next model's.
+type attemptStream struct {
+	events    chan llm2.Event
+	done      chan struct{}
+	eventChan chan<- llm2.Event
+	held      []llm2.Event
+	streamed  bool
+}
+
+func newAttemptStream(eventChan chan<- llm2.Event) *attemptStream {
+	a := &attemptStream{
+		events:    make(chan llm2.Event, 10),
+		done:      make(chan struct{}),
+		eventChan: eventChan,
+	}
+	go func() {
+		defer close(a.done)
+		for event := range a.events {
+			if a.streamed {
+				a.eventChan <- event
+				continue
+			}
+			a.held = append(a.held, event)
+			switch event.Type {
+			case llm2.EventTextDelta, llm2.EventSummaryTextDelta, llm2.EventSignatureDelta:
+				a.release()
+			}
+		}
+	}()
+	return a
+}
+
+func (a *attemptStream) release() {
+	for _, event := range a.held {
+		a.eventChan <- event
+	}
+	a.held = nil
+	a.streamed = true
+}
+
+// finish waits until all events of the attempt are passed on, dropping the
+// held back ones unless the attempt succeeded. Returns whether any events
+// were streamed.
+func (a *attemptStream) finish(succeeded bool) bool {
+	close(a.events)
+	<-a.done
+	if succeeded && len(a.held) > 0 {
+		a.release()
+	}
+	return a.streamed
+}
+
 // convertLlm2EventToFlowEvent converts an llm2.Event to a domain.FlowEvent for streaming.
 func convertLlm2EventToFlowEvent(event llm2.Event, flowActionId string) domain.FlowEvent {
 	switch event.Type {
@@ -243,6 +349,39 @@ func getLlm2Provider(config common.ModelConfig, providers []common.ModelProvider
 			}, nil
 		}
 		return nil, fmt.Errorf("configuration not found for provider named: %s", config.Provider)
+	case llm.OllamaToolChatProviderType:
+		if providerConfig != nil && providerConfig.Type == string(providerType) {
+			return llm2.OllamaProvider{
+				BaseURL:       providerConfig.BaseURL,
+				DefaultModel:  providerConfig.DefaultLLM,
+				CustomHeaders: providerConfig.CustomHeaders,
+			}, nil
+		}
+		return nil, fmt.Errorf("configuration not found for provider named: %s", config.Provider)
+	case llm.BedrockToolChatProviderType:
+		if providerConfig != nil && providerConfig.Type == string(providerType) {
+			return llm2.AnthropicProvider{
+				Bedrock:       true,
+				Region:        providerConfig.Region,
+				BaseURL:       providerConfig.BaseURL,
+				DefaultModel:  providerConfig.DefaultLLM,
+				AuthType:      authType,
+				CustomHeaders: providerConfig.CustomHeaders,
+			}, nil
+		}
+		return nil, fmt.Errorf("configuration not found for provider named: %s", config.Provider)
+	case llm.AzureOpenaiToolChatProviderType:
+		if providerConfig != nil && providerConfig.Type == string(providerType) {
+			return llm2.AzureOpenAIProvider{
+				BaseURL:       providerConfig.BaseURL,
+				APIVersion:    providerConfig.APIVersion,
+				DefaultModel:  providerConfig.DefaultLLM,
+				Deployments:   providerConfig.Deployments,
+				AuthType:      authType,
+				CustomHeaders: providerConfig.CustomHeaders,
+			}, nil
+		}
+		return nil, fmt.Errorf("configuration not found for provider named: %s", config.Provider)
 	case llm.AnthropicToolChatProviderType:
 		for _, p := range providers {
 			if p.Type == string(providerType) && p.Name == config.Provider {
@@ -1,11 +1,21 @@
 package persisted_ai
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"sidekick/common"
 	"sidekick/llm2"
 	"sidekick/secret_manager"
+
+	"go.temporal.io/sdk/temporal"
 )
 
 func newTestStreamInput(options llm2.Options) StreamInput {
@@ -465,3 +475,205 @@ func TestGetLlm2Provider_AuthType(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLlm2Provider_BedrockAndAzureOpenAI(t *testing.T) {
+	t.Parallel()
+
+	providers := []common.ModelProviderPublicConfig{
+		{
+			Name:       "corp-bedrock",
+			Type:       "bedrock",
+			Region:     "eu-central-1",
+			DefaultLLM: "eu.anthropic.claude-sonnet-4-5-20250929-v1:0",
+		},
+		{
+			Name:        "corp-azure",
+			Type:        "azure_openai",
+			BaseURL:     "https://corp.openai.azure.com",
+			APIVersion:  "2024-10-21",
+			DefaultLLM:  "gpt-4.1",
+			Deployments: map[string]string{"gpt-4.1": "gpt41-prod"},
+			AuthType:    common.ProviderAuthTypeAzureAD,
+		},
+	}
+
+	provider, err := getLlm2Provider(common.ModelConfig{Provider: "corp-bedrock"}, providers)
+	if err != nil {
+		t.Fatalf("getLlm2Provider returned error: %v", err)
+	}
+	bedrockProvider, ok := provider.(llm2.AnthropicProvider)
+	if !ok {
+		t.Fatalf("provider type = %T, want llm2.AnthropicProvider", provider)
+	}
+	if !bedrockProvider.Bedrock || bedrockProvider.Region != "eu-central-1" || bedrockProvider.DefaultModel != providers[0].DefaultLLM {
+		t.Fatalf("provider = %#v, want bedrock provider for %#v", bedrockProvider, providers[0])
+	}
+
+	provider, err = getLlm2Provider(common.ModelConfig{Provider: "corp-azure"}, providers)
+	if err != nil {
+		t.Fatalf("getLlm2Provider returned error: %v", err)
+	}
+	azureProvider, ok := provider.(llm2.AzureOpenAIProvider)
+	if !ok {
+		t.Fatalf("provider type = %T, want llm2.AzureOpenAIProvider", provider)
+	}
+	if azureProvider.BaseURL != "https://corp.openai.azure.com" ||
+		azureProvider.APIVersion != "2024-10-21" ||
+		azureProvider.Deployments["gpt-4.1"] != "gpt41-prod" ||
+		azureProvider.AuthType != common.ProviderAuthTypeAzureAD {
+		t.Fatalf("provider = %#v, want azure provider for %#v", azureProvider, providers[1])
+	}
+
+	_, err = getLlm2Provider(common.ModelConfig{Provider: "bedrock"}, nil)
+	if err == nil {
+		t.Fatal("expected an error for a bedrock provider without configuration")
+	}
+}
+
+// newChatCompletionsTestServer stands in for an openai-compatible provider,
+// responding with the given status code or, for 200, a streamed reply
+func newChatCompletionsTestServer(t *testing.T, statusCode int, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if statusCode != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			fmt.Fprintf(w, `{"error":{"message":"status %d","type":"error"}}`, statusCode)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"served-model\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"served-model\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStream_FallsBackToNextModel(t *testing.T) {
+	// avoid fetching models.dev when looking up model metadata and pricing
+	cacheDir := t.TempDir()
+	t.Setenv("SIDE_CACHE_HOME", cacheDir)
+	if err := os.WriteFile(filepath.Join(cacheDir, "models.dev.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	common.ClearModelsCache()
+	t.Cleanup(common.ClearModelsCache)
+
+	var requests [4]int
+	providers := []common.ModelProviderPublicConfig{
+		{Name: "limited", Type: "openai_compatible", BaseURL: newChatCompletionsTestServer(t, http.StatusTooManyRequests, &requests[0]).URL},
+		{Name: "overloaded", Type: "openai_compatible", BaseURL: newChatCompletionsTestServer(t, http.StatusServiceUnavailable, &requests[1]).URL},
+		{Name: "healthy", Type: "openai_compatible", BaseURL: newChatCompletionsTestServer(t, http.StatusOK, &requests[2]).URL},
+		{Name: "unauthorized", Type: "openai_compatible", BaseURL: newChatCompletionsTestServer(t, http.StatusUnauthorized, &requests[3]).URL},
+	}
+
+	stream := func(modelConfig common.ModelConfig) (*llm2.MessageResponse, error) {
+		chatHistory := NewLlm2ChatHistory("flow-1", "workspace-1")
+		chatHistory.Append(&llm2.Message{Role: llm2.RoleUser, Content: llm2.TextContentBlocks("Hi")})
+		input := newTestStreamInput(llm2.Options{ModelConfig: modelConfig})
+		input.ChatHistory = &ChatHistoryContainer{History: chatHistory}
+		input.Providers = providers
+		la := &Llm2Activities{}
+		return la.Stream(context.Background(), input)
+	}
+
+	t.Run("falls back on rate limits and overload", func(t *testing.T) {
+		response, err := stream(common.ModelConfig{
+			Provider:        "limited",
+			Model:           "model-a",
+			ReasoningEffort: "high",
+			Fallbacks: []common.ModelConfig{
+				{Provider: "overloaded", Model: "model-b"},
+				{Provider: "healthy", Model: "model-c", ReasoningEffort: "high"},
+				{Provider: "unauthorized", Model: "model-d"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Stream returned error: %v", err)
+		}
+		if response.Provider != "healthy" || response.Output.GetContentString() != "Hello" {
+			t.Fatalf("response = %#v, want a reply from the healthy provider", response)
+		}
+		if len(response.FallbackAttempts) != 2 {
+			t.Fatalf("FallbackAttempts = %#v, want 2 attempts", response.FallbackAttempts)
+		}
+		if attempt := response.FallbackAttempts[0]; attempt.Provider != "limited" || attempt.Model != "model-a" || attempt.ErrorClass != llm2.ErrorClassRateLimit {
+			t.Fatalf("FallbackAttempts[0] = %#v, want a rate limited attempt with limited/model-a", attempt)
+		}
+		if attempt := response.FallbackAttempts[1]; attempt.Provider != "overloaded" || attempt.ErrorClass != llm2.ErrorClassOverloaded {
+			t.Fatalf("FallbackAttempts[1] = %#v, want an overloaded attempt", attempt)
+		}
+		if requests[3] != 0 {
+			t.Fatalf("expected no requests after a model served the call, got %d", requests[3])
+		}
+	})
+
+	t.Run("does not fall back on auth errors", func(t *testing.T) {
+		healthyRequests := requests[2]
+		_, err := stream(common.ModelConfig{
+			Provider:  "unauthorized",
+			Model:     "model-d",
+			Fallbacks: []common.ModelConfig{{Provider: "healthy", Model: "model-c"}},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		var applicationErr *temporal.ApplicationError
+		if !errors.As(err, &applicationErr) || !applicationErr.NonRetryable() || applicationErr.Type() != string(llm2.ErrorClassAuth) {
+			t.Fatalf("err = %#v, want a non-retryable auth application error", err)
+		}
+		if requests[2] != healthyRequests {
+			t.Fatal("expected no fallback request after an auth error")
+		}
+	})
+
+	t.Run("returns the last error when every model fails", func(t *testing.T) {
+		_, err := stream(common.ModelConfig{
+			Provider:  "limited",
+			Model:     "model-a",
+			Fallbacks: []common.ModelConfig{{Provider: "overloaded", Model: "model-b"}},
+		})
+		if err == nil || llm2.ClassifyError(err) != llm2.ErrorClassOverloaded {
+			t.Fatalf("err = %v, want the overloaded provider's error", err)
+		}
+	})
+}
+
+func TestAttemptStream(t *testing.T) {
+	t.Parallel()
+	started := llm2.Event{Type: llm2.EventBlockStarted, Index: 0}
+	delta := llm2.Event{Type: llm2.EventTextDelta, Index: 0, Delta: "Hi"}
+	done := llm2.Event{Type: llm2.EventBlockDone, Index: 0}
+
+	collect := func(succeeded bool, events ...llm2.Event) ([]llm2.Event, bool) {
+		eventChan := make(chan llm2.Event, 10)
+		attempt := newAttemptStream(eventChan)
+		for _, event := range events {
+			attempt.events <- event
+		}
+		streamed := attempt.finish(succeeded)
+		close(eventChan)
+		var received []llm2.Event
+		for event := range eventChan {
+			received = append(received, event)
+		}
+		return received, streamed
+	}
+
+	received, streamed := collect(false, started)
+	if streamed || len(received) != 0 {
+		t.Fatalf("failed attempt without deltas passed on %v, want nothing", received)
+	}
+
+	received, streamed = collect(false, started, delta)
+	if !streamed || !reflect.DeepEqual(received, []llm2.Event{started, delta}) {
+		t.Fatalf("received = %v, streamed = %v, want the events up to the first delta", received, streamed)
+	}
+
+	received, _ = collect(true, started, done)
+	if !reflect.DeepEqual(received, []llm2.Event{started, done}) {
+		t.Fatalf("received = %v, want the held back events of a successful attempt", received)
+	}
+}
@@ -105,9 +105,16 @@ func (la *LlmActivities) ChatStream(ctx context.Context, options ChatStreamOptio
 	// Check for empty response
 	if len(response.Content) == 0 && len(response.ToolCalls) == 0 {
 		log.Debug().Msg("Received empty response, attempting retry with modified prompt")
-		return retryChatStreamOnEmptyResponse(ctx, options.ToolChatOptions, response, toolChatter, deltaChan, progressChan)
+		retryResponse, err := retryChatStreamOnEmptyResponse(ctx, options.ToolChatOptions, response, toolChatter, deltaChan, progressChan)
+		if retryResponse != nil {
+			modelConfig := options.Params.ModelConfig
+			retryResponse.Cost = common.CalculateLlmCost(modelConfig.Provider, retryResponse.Usage, retryResponse.Model, modelConfig.Model)
+		}
+		return retryResponse, err
 	}
 
+	modelConfig := options.Params.ModelConfig
+	response.Cost = common.CalculateLlmCost(modelConfig.Provider, response.Usage, response.Model, modelConfig.Model)
 	return response, nil
 }
 
@@ -247,8 +254,16 @@ func getProviderType(s string) (llm.ToolChatProviderType, error) {
 		return llm.OpenaiCompatibleToolChatProviderType, nil
 	case "openai_responses_compatible":
 		return llm.OpenaiResponsesCompatibleToolChatProviderType, nil
+	case "ollama":
+		return llm.OllamaToolChatProviderType, nil
+	case "bedrock":
+		return llm.BedrockToolChatProviderType, nil
+	case "azure_openai":
+		return llm.AzureOpenaiToolChatProviderType, nil
 	case "mock":
 		return llm.ToolChatProviderType("mock"), nil
+	case common.LocalEmbeddingProvider:
+		return llm.ToolChatProviderType(common.LocalEmbeddingProvider), nil
 	}
 
 	// TODO first try workspace config to determine provider type, then fallback to local config
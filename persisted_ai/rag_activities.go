@@ -19,6 +19,10 @@ import (
 
 type RagActivities struct {
 	DatabaseAccessor srv.Storage
+	// VectorIndexes and LexicalIndexes are shared by all RAG searches.
+	// Optional: without them, each search builds temporary indexes.
+	VectorIndexes  *VectorIndexes
+	LexicalIndexes *LexicalIndexes
 }
 
 type RankedDirSignatureOutlineOptions struct {
@@ -32,6 +36,9 @@ type RankedViaEmbeddingOptions struct {
 	RankQuery    string
 	Secrets      secret_manager.SecretManagerContainer
 	ModelConfig  common.ModelConfig
+	// RetrievalConfig determines whether a lexical ranking is fused with
+	// the embedding ranking
+	RetrievalConfig common.RetrievalConfig
 }
 
 func (options RankedDirSignatureOutlineOptions) ActionParams() map[string]any {
@@ -40,6 +47,7 @@ func (options RankedDirSignatureOutlineOptions) ActionParams() map[string]any {
 		"charLimit": options.CharLimit,
 		"provider":  options.ModelConfig.Provider,
 		"model":     options.ModelConfig.Model,
+		"retrieval": options.RetrievalConfig.Mode,
 	}
 }
 
@@ -104,7 +112,7 @@ func (ra *RagActivities) RankedSubkeys(ctx context.Context, options RankedSubkey
 		return []string{}, err
 	}
 
-	va := VectorActivities{DatabaseAccessor: ra.DatabaseAccessor}
+	va := VectorActivities{DatabaseAccessor: ra.DatabaseAccessor, Indexes: ra.VectorIndexes}
 
 	// Get model-specific character limits
 	maxQueryChars, err := embedding.GetModelMaxChars(options.ModelConfig)
@@ -151,6 +159,20 @@ func (ra *RagActivities) RankedSubkeys(ctx context.Context, options RankedSubkey
 		return []string{}, fmt.Errorf("failed multi-vector search: %w", err)
 	}
 
+	if options.RetrievalConfig.Mode != common.RetrievalModeVector {
+		lexicalResults, err := ra.lexicalSearch(ctx, lexicalSearchOptions{
+			WorkspaceId: options.WorkspaceId,
+			ContentType: options.ContentType,
+			Subkeys:     options.Subkeys,
+			Query:       options.RankQuery,
+			Limit:       1000,
+		})
+		if err != nil {
+			return []string{}, fmt.Errorf("failed lexical search: %w", err)
+		}
+		resultSets = append(resultSets, lexicalResults)
+	}
+
 	// rank-fusion to merge result sets. note: still works if there's just one result set
 	return FuseResultsRRF(resultSets), nil
 }
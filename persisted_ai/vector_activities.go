@@ -33,6 +33,9 @@ type MultiVectorSearchOptions struct {
 
 type VectorActivities struct {
 	DatabaseAccessor db.Storage
+	// Indexes, when set, are searched instead of building a temporary index
+	// from all the embeddings for every search
+	Indexes *VectorIndexes
 }
 
 // staticVectoreStore holds a temporary and non-updatable usearch index, and
@@ -77,6 +80,25 @@ func (va VectorActivities) buildStaticVectorStore(ctx context.Context, workspace
 		return staticVectoreStore{}, fmt.Errorf("failed to reserve space in index: %v", err)
 	}
 
+	vectors, err := va.getEmbeddings(ctx, workspaceId, provider, model, contentType, subkeys, numDimensions)
+	if err != nil {
+		index.Destroy()
+		return staticVectoreStore{}, err
+	}
+
+	for i, ev := range vectors {
+		if err := index.Add(usearch.Key(i), ev); err != nil {
+			index.Destroy()
+			return staticVectoreStore{}, fmt.Errorf("failed to add embedding for key %s to index: %v", subkeys[i], err)
+		}
+	}
+
+	return staticVectoreStore{index: index, subkeys: subkeys}, nil
+}
+
+// getEmbeddings gets the stored embeddings of the given subkeys, in the same
+// order, checking that they all have the expected number of dimensions.
+func (va VectorActivities) getEmbeddings(ctx context.Context, workspaceId string, provider string, model string, contentType string, subkeys []string, numDimensions int) ([]embedding.EmbeddingVector, error) {
 	embeddingKeys := make([]string, 0, len(subkeys))
 	for _, subKey := range subkeys {
 		embeddingKey, keyErr := constructEmbeddingKey(embeddingKeyOptions{
@@ -86,49 +108,39 @@ func (va VectorActivities) buildStaticVectorStore(ctx context.Context, workspace
 			subKey:      subKey,
 		})
 		if keyErr != nil {
-			index.Destroy()
-			return staticVectoreStore{}, fmt.Errorf("failed to construct embedding key for subkey %s: %w", subKey, keyErr)
+			return nil, fmt.Errorf("failed to construct embedding key for subkey %s: %w", subKey, keyErr)
 		}
 		embeddingKeys = append(embeddingKeys, embeddingKey)
 	}
 
 	values, mgetErr := va.DatabaseAccessor.MGet(ctx, workspaceId, embeddingKeys)
 	if mgetErr != nil {
-		index.Destroy()
-		return staticVectoreStore{}, fmt.Errorf("failed to MGet embeddings: %w", mgetErr)
+		return nil, fmt.Errorf("failed to MGet embeddings: %w", mgetErr)
 	}
 
+	vectors := make([]embedding.EmbeddingVector, 0, len(values))
 	for i, value := range values {
 		if value == nil {
-			index.Destroy()
-			return staticVectoreStore{}, fmt.Errorf("embedding is missing for key: %s", embeddingKeys[i])
+			return nil, fmt.Errorf("embedding is missing for key: %s", embeddingKeys[i])
 		}
 
 		var stringValue string
 		if err := binary.Unmarshal(value, &stringValue); err != nil {
-			index.Destroy()
-			return staticVectoreStore{}, fmt.Errorf("embedding value for key %s failed to unmarshal to string: %w", embeddingKeys[i], err)
+			return nil, fmt.Errorf("embedding value for key %s failed to unmarshal to string: %w", embeddingKeys[i], err)
 		}
 		byteValue := []byte(stringValue)
 		var ev embedding.EmbeddingVector
 		if err := ev.UnmarshalBinary(byteValue); err != nil {
-			index.Destroy()
-			return staticVectoreStore{}, fmt.Errorf("embedding value for key %s failed to unmarshal to EmbeddingVector: %w", embeddingKeys[i], err)
+			return nil, fmt.Errorf("embedding value for key %s failed to unmarshal to EmbeddingVector: %w", embeddingKeys[i], err)
 		}
 
 		if len(ev) != numDimensions {
-			index.Destroy()
 			log.Error().Str("embeddingKey", embeddingKeys[i]).Msg("dimension mismatch")
-			return staticVectoreStore{}, fmt.Errorf("dimension mismatch for key %s: expected %d, got %d", subkeys[i], numDimensions, len(ev))
-		}
-
-		if err := index.Add(usearch.Key(i), ev); err != nil {
-			index.Destroy()
-			return staticVectoreStore{}, fmt.Errorf("failed to add embedding for key %s to index: %v", subkeys[i], err)
+			return nil, fmt.Errorf("dimension mismatch for key %s: expected %d, got %d", subkeys[i], numDimensions, len(ev))
 		}
+		vectors = append(vectors, ev)
 	}
-
-	return staticVectoreStore{index: index, subkeys: subkeys}, nil
+	return vectors, nil
 }
 
 var DefaultVectorSearchLimit uint = 1000
@@ -204,6 +216,22 @@ func (va VectorActivities) VectorSearch(options VectorSearchOptions) ([]string,
 	}
 	numDimensions := len(options.Query)
 
+	if va.Indexes != nil {
+		results, err := va.Indexes.multiSearch(ctx, va, MultiVectorSearchOptions{
+			WorkspaceId: options.WorkspaceId,
+			ContentType: options.ContentType,
+			Subkeys:     options.Subkeys,
+			Provider:    options.Provider,
+			Model:       options.Model,
+			Queries:     []embedding.EmbeddingVector{options.Query},
+			Limit:       options.Limit,
+		}, numDimensions)
+		if err != nil {
+			return []string{}, fmt.Errorf("failed to search vector index: %w", err)
+		}
+		return results[0], nil
+	}
+
 	store, err := va.buildStaticVectorStore(ctx,
 		options.WorkspaceId,
 		options.Provider,
@@ -243,6 +271,14 @@ func (va VectorActivities) MultiVectorSearch(options MultiVectorSearchOptions) (
 		}
 	}
 
+	if va.Indexes != nil {
+		results, err := va.Indexes.multiSearch(ctx, va, options, numDimensions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search vector index: %w", err)
+		}
+		return results, nil
+	}
+
 	store, err := va.buildStaticVectorStore(ctx,
 		options.WorkspaceId,
 		options.Provider,
@@ -0,0 +1,358 @@
+package persisted_ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sidekick/common"
+	"sidekick/embedding"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	usearch "github.com/unum-cloud/usearch/golang"
+)
+
+// VectorIndexes holds persistent usearch indexes of embeddings, one per
+// workspace, provider, model and content type. Each index is loaded from disk
+// on first use and kept in memory, then updated incrementally with the
+// embeddings of subkeys it doesn't have yet. As subkeys are content hashes, the
+// embedding of an indexed subkey never changes, so only new content requires
+// reading embeddings from storage.
+type VectorIndexes struct {
+	dir     string
+	mu      sync.Mutex
+	indexes map[vectorIndexId]*vectorIndex
+}
+
+// NewVectorIndexes creates a set of vector indexes persisted to the given
+// directory.
+func NewVectorIndexes(dir string) *VectorIndexes {
+	return &VectorIndexes{
+		dir:     dir,
+		indexes: make(map[vectorIndexId]*vectorIndex),
+	}
+}
+
+// DefaultVectorIndexesDir returns the directory vector indexes are persisted
+// to by default, within the sidekick cache home.
+func DefaultVectorIndexesDir() (string, error) {
+	cacheHome, err := common.GetSidekickCacheHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheHome, "vector_indexes"), nil
+}
+
+// Close releases the memory of all loaded indexes. Indexes are saved as they
+// are updated, so there's nothing left to write. It must only be called once
+// no activities are using the indexes anymore.
+func (vi *VectorIndexes) Close() {
+	vi.mu.Lock()
+	defer vi.mu.Unlock()
+	for id, index := range vi.indexes {
+		index.mu.Lock()
+		index.destroy()
+		index.mu.Unlock()
+		delete(vi.indexes, id)
+	}
+}
+
+type vectorIndexId struct {
+	workspaceId string
+	provider    string
+	model       string
+	contentType string
+}
+
+// vectorIndex is a usearch index along with the mapping between its keys and
+// the subkeys of the embeddings they were added for.
+type vectorIndex struct {
+	mu         sync.Mutex
+	path       string // without extension
+	dimensions int
+	index      *usearch.Index
+	keys       map[string]usearch.Key
+	subkeys    map[usearch.Key]string
+	nextKey    usearch.Key
+}
+
+// vectorIndexMetadata is persisted alongside the usearch index file
+type vectorIndexMetadata struct {
+	Dimensions int                    `json:"dimensions"`
+	NextKey    uint64                 `json:"nextKey"`
+	Keys       map[string]usearch.Key `json:"keys"`
+}
+
+func (vi *VectorIndexes) get(id vectorIndexId) *vectorIndex {
+	vi.mu.Lock()
+	defer vi.mu.Unlock()
+	index, ok := vi.indexes[id]
+	if !ok {
+		hash := sha256.Sum256([]byte(id.provider + "\x00" + id.model + "\x00" + id.contentType))
+		index = &vectorIndex{path: filepath.Join(vi.dir, id.workspaceId, hex.EncodeToString(hash[:16]))}
+		vi.indexes[id] = index
+	}
+	return index
+}
+
+// multiSearch searches the index for the given options, after adding the
+// embeddings of any of its subkeys that aren't indexed yet. Only the given
+// subkeys are returned, in the same order they would be if the index contained
+// nothing else.
+func (vi *VectorIndexes) multiSearch(ctx context.Context, va VectorActivities, options MultiVectorSearchOptions, numDimensions int) ([][]string, error) {
+	index := vi.get(vectorIndexId{
+		workspaceId: options.WorkspaceId,
+		provider:    options.Provider,
+		model:       options.Model,
+		contentType: options.ContentType,
+	})
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if err := index.load(numDimensions); err != nil {
+		return nil, err
+	}
+
+	subkeyCounts := make(map[string]int, len(options.Subkeys))
+	missingSubkeys := make([]string, 0)
+	for _, subkey := range options.Subkeys {
+		if _, ok := index.keys[subkey]; !ok && subkeyCounts[subkey] == 0 {
+			missingSubkeys = append(missingSubkeys, subkey)
+		}
+		subkeyCounts[subkey]++
+	}
+
+	changed := false
+	if len(missingSubkeys) > 0 {
+		vectors, err := va.getEmbeddings(ctx, options.WorkspaceId, options.Provider, options.Model, options.ContentType, missingSubkeys, numDimensions)
+		if err != nil {
+			return nil, err
+		}
+		if err := index.add(missingSubkeys, vectors); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	// drop content that is no longer requested once it makes up most of the
+	// index, so that searches don't slow down as content changes over time
+	if len(index.keys) > 2*len(subkeyCounts) {
+		if err := index.retainOnly(subkeyCounts); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := index.save(); err != nil {
+			// the index is still usable in memory, so this isn't fatal
+			log.Warn().Err(err).Str("path", index.path).Msg("failed to save vector index")
+		}
+	}
+
+	limit := options.Limit
+	if limit == 0 {
+		limit = DefaultVectorSearchLimit
+	}
+	results := make([][]string, len(options.Queries))
+	for i, query := range options.Queries {
+		queryResults, err := index.search(query, subkeyCounts, limit)
+		if err != nil {
+			return nil, fmt.Errorf("error searching for vector %d: %w", i, err)
+		}
+		results[i] = queryResults
+	}
+	return results, nil
+}
+
+// load loads the index from disk if it isn't loaded yet, starting a new empty
+// index if there is no usable persisted index with the given dimensions.
+func (index *vectorIndex) load(numDimensions int) error {
+	if index.index != nil && index.dimensions == numDimensions {
+		return nil
+	}
+	index.destroy()
+
+	metadata, err := index.loadMetadata()
+	if err == nil && metadata.Dimensions == numDimensions {
+		usearchIndex, err := usearch.NewIndex(usearch.DefaultConfig(uint(numDimensions)))
+		if err != nil {
+			return fmt.Errorf("failed to create Index: %v", err)
+		}
+		err = usearchIndex.Load(index.path + ".usearch")
+		if err == nil {
+			var length uint
+			length, err = usearchIndex.Len()
+			if err == nil && length != uint(len(metadata.Keys)) {
+				err = fmt.Errorf("index has %d vectors but metadata has %d keys", length, len(metadata.Keys))
+			}
+		}
+		if err == nil {
+			index.set(usearchIndex, numDimensions, metadata.Keys, usearch.Key(metadata.NextKey))
+			return nil
+		}
+		usearchIndex.Destroy()
+		log.Warn().Err(err).Str("path", index.path).Msg("discarding unusable vector index")
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("path", index.path).Msg("discarding unusable vector index")
+	}
+
+	usearchIndex, err := usearch.NewIndex(usearch.DefaultConfig(uint(numDimensions)))
+	if err != nil {
+		return fmt.Errorf("failed to create Index: %v", err)
+	}
+	index.set(usearchIndex, numDimensions, map[string]usearch.Key{}, 0)
+	return nil
+}
+
+func (index *vectorIndex) loadMetadata() (vectorIndexMetadata, error) {
+	var metadata vectorIndexMetadata
+	data, err := os.ReadFile(index.path + ".json")
+	if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return metadata, fmt.Errorf("failed to unmarshal vector index metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+func (index *vectorIndex) set(usearchIndex *usearch.Index, numDimensions int, keys map[string]usearch.Key, nextKey usearch.Key) {
+	index.index = usearchIndex
+	index.dimensions = numDimensions
+	index.keys = keys
+	index.subkeys = make(map[usearch.Key]string, len(keys))
+	for subkey, key := range keys {
+		index.subkeys[key] = subkey
+	}
+	index.nextKey = nextKey
+}
+
+func (index *vectorIndex) destroy() {
+	if index.index != nil {
+		index.index.Destroy()
+		index.index = nil
+	}
+}
+
+func (index *vectorIndex) add(subkeys []string, vectors []embedding.EmbeddingVector) error {
+	if err := index.index.Reserve(uint(len(index.keys) + len(subkeys))); err != nil {
+		return fmt.Errorf("failed to reserve space in index: %v", err)
+	}
+	for i, subkey := range subkeys {
+		key := index.nextKey
+		if err := index.index.Add(key, vectors[i]); err != nil {
+			return fmt.Errorf("failed to add embedding for key %s to index: %v", subkey, err)
+		}
+		index.nextKey++
+		index.keys[subkey] = key
+		index.subkeys[key] = subkey
+	}
+	return nil
+}
+
+// retainOnly rebuilds the index with only the given subkeys, reusing the
+// vectors already in the index.
+func (index *vectorIndex) retainOnly(subkeys map[string]int) error {
+	rebuilt, err := usearch.NewIndex(usearch.DefaultConfig(uint(index.dimensions)))
+	if err != nil {
+		return fmt.Errorf("failed to create Index: %v", err)
+	}
+	if err := rebuilt.Reserve(uint(len(subkeys))); err != nil {
+		rebuilt.Destroy()
+		return fmt.Errorf("failed to reserve space in index: %v", err)
+	}
+
+	keys := make(map[string]usearch.Key, len(subkeys))
+	for subkey := range subkeys {
+		key := index.keys[subkey]
+		vector, err := index.index.Get(key, 1)
+		if err != nil || vector == nil {
+			rebuilt.Destroy()
+			return fmt.Errorf("failed to get vector for key %s from index: %v", subkey, err)
+		}
+		if err := rebuilt.Add(key, vector); err != nil {
+			rebuilt.Destroy()
+			return fmt.Errorf("failed to add embedding for key %s to index: %v", subkey, err)
+		}
+		keys[subkey] = key
+	}
+
+	nextKey := index.nextKey
+	index.destroy()
+	index.set(rebuilt, index.dimensions, keys, nextKey)
+	return nil
+}
+
+// save persists the index and its metadata, replacing any previous files
+func (index *vectorIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(index.path), 0755); err != nil {
+		return fmt.Errorf("failed to create vector index directory: %w", err)
+	}
+	data, err := json.Marshal(vectorIndexMetadata{
+		Dimensions: index.dimensions,
+		NextKey:    uint64(index.nextKey),
+		Keys:       index.keys,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal vector index metadata: %w", err)
+	}
+
+	// write to temporary files first, so that a crash can't leave a partial
+	// index behind. a crash between the renames leaves an index that doesn't
+	// match its metadata, which is detected and discarded on load.
+	if err := index.index.Save(index.path + ".usearch.tmp"); err != nil {
+		return fmt.Errorf("failed to save index: %v", err)
+	}
+	if err := os.WriteFile(index.path+".json.tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write vector index metadata: %w", err)
+	}
+	if err := os.Rename(index.path+".usearch.tmp", index.path+".usearch"); err != nil {
+		return err
+	}
+	return os.Rename(index.path+".json.tmp", index.path+".json")
+}
+
+// search returns the subkeys closest to the query, among the given subkeys
+// only. Subkeys given multiple times are returned as many times, as they would
+// be if each was indexed separately.
+func (index *vectorIndex) search(query embedding.EmbeddingVector, subkeyCounts map[string]int, limit uint) ([]string, error) {
+	length, err := index.index.Len()
+	if err != nil {
+		return nil, fmt.Errorf("error getting index length: %v", err)
+	}
+	if length == 0 {
+		return []string{}, nil
+	}
+
+	// when the index has other subkeys, search all of it so that the closest
+	// of the given subkeys are found no matter how close the others are
+	searchLimit := limit
+	if length > uint(len(subkeyCounts)) {
+		searchLimit = length
+	}
+	keys, _, err := index.index.Search(query, searchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching index: %w", err)
+	}
+
+	results := make([]string, 0, min(limit, uint(len(keys))))
+	for _, key := range keys {
+		subkey, ok := index.subkeys[key]
+		if !ok {
+			return nil, fmt.Errorf("found key %d that isn't mapped to a subkey", key)
+		}
+		for range subkeyCounts[subkey] {
+			if uint(len(results)) == limit {
+				return results, nil
+			}
+			results = append(results, subkey)
+		}
+	}
+	return results, nil
+}
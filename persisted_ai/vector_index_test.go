@@ -0,0 +1,175 @@
+package persisted_ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"sidekick/embedding"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storeTestEmbeddings(t *testing.T, va VectorActivities, wsID, provider, model, contentType string, vectors map[string]embedding.EmbeddingVector) {
+	t.Helper()
+	kvs := make(map[string]interface{}, len(vectors))
+	for subkey, vector := range vectors {
+		vectorBytes, err := vector.MarshalBinary()
+		require.NoError(t, err)
+		embeddingKey, err := constructEmbeddingKey(embeddingKeyOptions{provider: provider, model: model, contentType: contentType, subKey: subkey})
+		require.NoError(t, err)
+		kvs[embeddingKey] = vectorBytes
+	}
+	require.NoError(t, va.DatabaseAccessor.MSet(context.Background(), wsID, kvs))
+}
+
+func TestVectorIndexes_MatchesStaticStore(t *testing.T) {
+	dbAccessor := newTestDB(t)
+	staticVa := VectorActivities{DatabaseAccessor: dbAccessor}
+	indexes := NewVectorIndexes(t.TempDir())
+	defer indexes.Close()
+	indexedVa := VectorActivities{DatabaseAccessor: dbAccessor, Indexes: indexes}
+
+	wsID, provider, model, contentType := "test-index-ws", "test-index-prov", "test-index-model", "text"
+	vectors := make(map[string]embedding.EmbeddingVector)
+	allSubkeys := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		subkey := fmt.Sprintf("k%d", i)
+		vectors[subkey] = embedding.EmbeddingVector{float32(i%5) + 0.5, float32(i%7) + 0.25, float32(i%3) + 1}
+		allSubkeys = append(allSubkeys, subkey)
+	}
+	storeTestEmbeddings(t, staticVa, wsID, provider, model, contentType, vectors)
+
+	queries := []embedding.EmbeddingVector{{1, 0, 0}, {0, 1, 0}, {0.3, 0.3, 0.9}}
+	subkeySets := [][]string{
+		allSubkeys[:10],
+		allSubkeys,               // adds to the index incrementally
+		allSubkeys[5:15],         // searches a subset of the index
+		{"k1", "k2", "k2", "k3"}, // duplicates are returned as many times
+		{"k19"},                  // prunes the index, which is mostly stale now
+		{"k0", "k1", "k2", "k3", "k4", "k5"},
+	}
+	for _, subkeys := range subkeySets {
+		for _, limit := range []uint{1, 3, 0} {
+			options := MultiVectorSearchOptions{
+				WorkspaceId: wsID,
+				Provider:    provider,
+				Model:       model,
+				ContentType: contentType,
+				Subkeys:     subkeys,
+				Queries:     queries,
+				Limit:       limit,
+			}
+			expected, err := staticVa.MultiVectorSearch(options)
+			require.NoError(t, err)
+			actual, err := indexedVa.MultiVectorSearch(options)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual, "subkeys %v, limit %d", subkeys, limit)
+		}
+	}
+
+	single, err := indexedVa.VectorSearch(VectorSearchOptions{
+		WorkspaceId: wsID,
+		Provider:    provider,
+		Model:       model,
+		ContentType: contentType,
+		Subkeys:     allSubkeys,
+		Query:       vectors["k7"],
+		Limit:       1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k7"}, single)
+}
+
+func TestVectorIndexes_Persistence(t *testing.T) {
+	ctx := context.Background()
+	dbAccessor := newTestDB(t)
+	dir := t.TempDir()
+	wsID, provider, model, contentType := "test-persist-ws", "test-persist-prov", "test-persist-model", "text"
+
+	indexes := NewVectorIndexes(dir)
+	va := VectorActivities{DatabaseAccessor: dbAccessor, Indexes: indexes}
+	storeTestEmbeddings(t, va, wsID, provider, model, contentType, map[string]embedding.EmbeddingVector{
+		"a": {1, 0},
+		"b": {0, 1},
+	})
+	options := MultiVectorSearchOptions{
+		WorkspaceId: wsID,
+		Provider:    provider,
+		Model:       model,
+		ContentType: contentType,
+		Subkeys:     []string{"a", "b"},
+		Queries:     []embedding.EmbeddingVector{{0.9, 0.1}},
+		Limit:       2,
+	}
+	results, err := va.MultiVectorSearch(options)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}}, results)
+	indexes.Close()
+
+	// indexed embeddings are loaded from disk rather than read from storage again
+	require.NoError(t, dbAccessor.DeletePrefix(ctx, wsID, "embedding:"))
+	storeTestEmbeddings(t, va, wsID, provider, model, contentType, map[string]embedding.EmbeddingVector{
+		"c": {0.8, 0.2},
+	})
+	indexes = NewVectorIndexes(dir)
+	defer indexes.Close()
+	va.Indexes = indexes
+	options.Subkeys = []string{"a", "b", "c"}
+	options.Limit = 3
+	results, err = va.MultiVectorSearch(options)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "c", "b"}}, results)
+
+	// subkeys without stored embeddings are an error, as without the index
+	options.Subkeys = []string{"a", "missing"}
+	_, err = va.MultiVectorSearch(options)
+	assert.ErrorContains(t, err, "embedding is missing")
+
+	// an index with different dimensions, e.g. after a model change, is replaced
+	storeTestEmbeddings(t, va, wsID, provider, model, contentType, map[string]embedding.EmbeddingVector{
+		"d": {0, 0, 1},
+	})
+	options.Subkeys = []string{"d"}
+	options.Queries = []embedding.EmbeddingVector{{0, 0, 1}}
+	results, err = va.MultiVectorSearch(options)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"d"}}, results)
+}
+
+func TestVectorIndexes_DiscardsMismatchedFiles(t *testing.T) {
+	dbAccessor := newTestDB(t)
+	dir := t.TempDir()
+	wsID, provider, model, contentType := "test-corrupt-ws", "test-corrupt-prov", "test-corrupt-model", "text"
+
+	indexes := NewVectorIndexes(dir)
+	va := VectorActivities{DatabaseAccessor: dbAccessor, Indexes: indexes}
+	storeTestEmbeddings(t, va, wsID, provider, model, contentType, map[string]embedding.EmbeddingVector{
+		"a": {1, 0},
+		"b": {0, 1},
+	})
+	options := MultiVectorSearchOptions{
+		WorkspaceId: wsID,
+		Provider:    provider,
+		Model:       model,
+		ContentType: contentType,
+		Subkeys:     []string{"a", "b"},
+		Queries:     []embedding.EmbeddingVector{{0.1, 0.9}},
+		Limit:       1,
+	}
+	_, err := va.MultiVectorSearch(options)
+	require.NoError(t, err)
+
+	index := indexes.get(vectorIndexId{workspaceId: wsID, provider: provider, model: model, contentType: contentType})
+	require.NoError(t, os.WriteFile(index.path+".json", []byte(`{"dimensions":2,"nextKey":1,"keys":{"a":0}}`), 0644))
+	indexes.Close()
+
+	indexes = NewVectorIndexes(dir)
+	defer indexes.Close()
+	va.Indexes = indexes
+	results, err := va.MultiVectorSearch(options)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"b"}}, results)
+}
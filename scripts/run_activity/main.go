@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"sidekick/coding"
+	"sidekick/coding/forge"
 	"sidekick/coding/git"
 	"sidekick/coding/lsp"
 	"sidekick/coding/tree_sitter"
@@ -21,6 +22,7 @@ import (
 	"sidekick/env"
 	"sidekick/fflag"
 	"sidekick/flow_action"
+	"sidekick/mcp"
 	"sidekick/persisted_ai"
 	"sidekick/poll_failures"
 	"sidekick/srv"
@@ -128,11 +130,20 @@ func buildActivityRegistry() map[string]interface{} {
 	readImageActivities := &dev.ReadImageActivities{
 		Storage: service,
 	}
+	var vectorIndexes *persisted_ai.VectorIndexes
+	if vectorIndexesDir, err := persisted_ai.DefaultVectorIndexesDir(); err != nil {
+		log.Warn().Err(err).Msg("Failed to get vector indexes directory, vector searches will not use persistent indexes")
+	} else {
+		vectorIndexes = persisted_ai.NewVectorIndexes(vectorIndexesDir)
+	}
 	vectorActivities := &persisted_ai.VectorActivities{
 		DatabaseAccessor: service,
+		Indexes:          vectorIndexes,
 	}
 	ragActivities := &persisted_ai.RagActivities{
 		DatabaseAccessor: service,
+		VectorIndexes:    vectorIndexes,
+		LexicalIndexes:   persisted_ai.NewLexicalIndexes(),
 	}
 	pollFailuresActivities := &poll_failures.PollFailuresActivities{
 		TemporalClient: temporalClient,
@@ -163,6 +174,9 @@ func buildActivityRegistry() map[string]interface{} {
 	// Register standalone functions
 	standaloneFuncs := []interface{}{
 		env.NewLocalGitWorktreeActivity,
+		env.NewContainerEnvActivity,
+		env.NewDevPodEnvActivity,
+		env.TeardownEnvActivity,
 		sidekick.GithubCloneRepoActivity,
 		env.EnvRunCommandActivity,
 		env.GetEnvironmentInfoActivity,
@@ -180,6 +194,11 @@ func buildActivityRegistry() map[string]interface{} {
 		git.GetDefaultBranch,
 		git.ListLocalBranches,
 		git.WriteTreeActivity,
+		forge.OpenPullRequestActivity,
+		forge.GetPullRequestReviewActivity,
+		forge.LocateReviewThreadsActivity,
+		forge.PushBranchActivity,
+		forge.ReplyToReviewThreadsActivity,
 		dev.GetRepoConfigActivity,
 		dev.GetRepoConfigActivityV2,
 		dev.GetSymbolsActivity,
@@ -203,6 +222,7 @@ func buildActivityRegistry() map[string]interface{} {
 	registerStructMethods(registry, llm2Activities)
 	registerStructMethods(registry, pollFailuresActivities)
 	registerStructMethods(registry, lspActivities)
+	registerStructMethods(registry, &mcp.McpActivities{})
 	registerStructMethods(registry, treeSitterActivities)
 	registerStructMethods(registry, codingActivities)
 	registerStructMethods(registry, ragActivities)
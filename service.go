@@ -6,6 +6,7 @@ import (
 	"os"
 	"sidekick/common"
 	"sidekick/nats"
+	"sidekick/notify"
 	"sidekick/srv"
 	"sidekick/srv/jetstream"
 	"sidekick/srv/redis"
@@ -66,5 +67,21 @@ func GetService() (*srv.Delegator, error) {
 		log.Fatal().Str("streamer", streamerType).Msg("Unknown streamer type")
 	}
 
-	return srv.NewDelegator(storage, streamer), nil
+	service := srv.NewDelegator(storage, streamer)
+
+	// a broken config shouldn't keep the service from starting, only
+	// notifications from being sent
+	config, err := common.LoadSidekickConfig(common.GetSidekickConfigPath())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load sidekick config, notifications are disabled")
+	} else if len(config.Notifications.Sinks) > 0 {
+		notifier, err := notify.NewNotifier(config.Notifications, service)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to initialize notifications")
+		} else {
+			service.SetNotifier(notifier)
+		}
+	}
+
+	return service, nil
 }
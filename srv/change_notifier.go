@@ -0,0 +1,15 @@
+package srv
+
+import "sidekick/domain"
+
+// ChangeNotifier is told about persisted changes that users may want to be
+// notified of. Transitions are described from the change itself: the previous
+// task status is read atomically with the write, and the previous flow status
+// is empty for new flows. Implementations must not block: they are called
+// synchronously after the change is persisted, and the caller's context may be
+// done as soon as they return.
+type ChangeNotifier interface {
+	TaskChanged(previousStatus domain.TaskStatus, task domain.Task)
+	FlowStatusChanged(previousStatus string, flow domain.Flow)
+	FlowActionChanged(flowAction domain.FlowAction)
+}
@@ -12,6 +12,7 @@ import (
 type Delegator struct {
 	storage  Storage
 	streamer Streamer
+	notifier ChangeNotifier
 }
 
 func NewDelegator(storage Storage, streamer Streamer) *Delegator {
@@ -21,6 +22,12 @@ func NewDelegator(storage Storage, streamer Streamer) *Delegator {
 	}
 }
 
+// SetNotifier makes the delegator tell the given notifier about task, flow and
+// flow action changes, in addition to streaming them
+func (d *Delegator) SetNotifier(notifier ChangeNotifier) {
+	d.notifier = notifier
+}
+
 func (d *Delegator) StreamTaskChanges(ctx context.Context, workspaceId, streamMessageStartId string) (<-chan domain.Task, <-chan error) {
 	return d.streamer.StreamTaskChanges(ctx, workspaceId, streamMessageStartId)
 }
@@ -115,11 +122,28 @@ func (d Delegator) GetTask(ctx context.Context, workspaceId string, taskId strin
 
 /* implements TaskStorage interface */
 func (d Delegator) PersistTask(ctx context.Context, task domain.Task) error {
-	err := d.storage.PersistTask(ctx, task)
+	if d.notifier == nil {
+		err := d.storage.PersistTask(ctx, task)
+		if err != nil {
+			return err
+		}
+		return d.AddTaskChange(ctx, task)
+	}
+
+	_, err := d.ReplaceTask(ctx, task)
+	return err
+}
+
+/* implements Storage interface */
+func (d Delegator) ReplaceTask(ctx context.Context, task domain.Task) (domain.TaskStatus, error) {
+	previousStatus, err := d.storage.ReplaceTask(ctx, task)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if d.notifier != nil {
+		d.notifier.TaskChanged(previousStatus, task)
 	}
-	return d.AddTaskChange(ctx, task)
+	return previousStatus, d.AddTaskChange(ctx, task)
 }
 
 /* implements TaskStorage interface */
@@ -168,6 +192,9 @@ func (d Delegator) PersistFlow(ctx context.Context, flow domain.Flow) error {
 
 	// If we have a status change event, add it using the streamer
 	if statusChangeEvent != nil {
+		if d.notifier != nil {
+			d.notifier.FlowStatusChanged(existingFlow.Status, flow)
+		}
 		if err := d.streamer.AddFlowEvent(ctx, flow.WorkspaceId, flow.Id, statusChangeEvent); err != nil {
 			return fmt.Errorf("error adding flow event: %w", err)
 		}
@@ -186,6 +213,11 @@ func (d Delegator) GetFlowsForTask(ctx context.Context, workspaceId string, task
 	return d.storage.GetFlowsForTask(ctx, workspaceId, taskId)
 }
 
+/* implements FlowStorage interface */
+func (d Delegator) GetFlowsWithStatus(ctx context.Context, status string) ([]domain.Flow, error) {
+	return d.storage.GetFlowsWithStatus(ctx, status)
+}
+
 /* implements FlowStorage interface */
 func (d Delegator) DeleteFlow(ctx context.Context, workspaceId string, flowId string) error {
 	return d.storage.DeleteFlow(ctx, workspaceId, flowId)
@@ -242,6 +274,9 @@ func (d Delegator) PersistFlowAction(ctx context.Context, flowAction domain.Flow
 	if err != nil {
 		return err
 	}
+	if d.notifier != nil {
+		d.notifier.FlowActionChanged(flowAction)
+	}
 	return d.AddFlowActionChange(ctx, flowAction)
 }
 
@@ -8,6 +8,7 @@ import (
 	"sidekick/nats"
 	"sidekick/srv/jetstream"
 	"sidekick/srv/sqlite"
+	"sync"
 	"testing"
 	"time"
 
@@ -136,6 +137,73 @@ func (s *DelegatorTestSuite) TestPersistSubflow() {
 	}
 }
 
+// recordingNotifier records the changes it's told about
+type recordingNotifier struct {
+	mu          sync.Mutex
+	tasks       [][2]domain.TaskStatus
+	flows       [][2]string
+	flowActions []domain.FlowAction
+}
+
+func (n *recordingNotifier) TaskChanged(previousStatus domain.TaskStatus, task domain.Task) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tasks = append(n.tasks, [2]domain.TaskStatus{previousStatus, task.Status})
+}
+
+func (n *recordingNotifier) FlowStatusChanged(previousStatus string, flow domain.Flow) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flows = append(n.flows, [2]string{previousStatus, flow.Status})
+}
+
+func (n *recordingNotifier) FlowActionChanged(flowAction domain.FlowAction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flowActions = append(n.flowActions, flowAction)
+}
+
+func (s *DelegatorTestSuite) TestNotifierIsToldAboutChanges() {
+	s.T().Parallel()
+	notifier := &recordingNotifier{}
+	delegator := NewDelegator(s.storage, s.streamer)
+	delegator.SetNotifier(notifier)
+
+	workspaceId := "notifier-workspace"
+	task := domain.Task{WorkspaceId: workspaceId, Id: "task_notifier", Title: "Notify me", Status: domain.TaskStatusToDo}
+	s.Require().NoError(delegator.PersistTask(s.ctx, task))
+	inProgress := task
+	inProgress.Status = domain.TaskStatusInProgress
+	s.Require().NoError(delegator.PersistTask(s.ctx, inProgress))
+	persisted, err := delegator.GetTask(s.ctx, workspaceId, task.Id)
+	s.Require().NoError(err)
+	s.Equal(domain.TaskStatusInProgress, persisted.Status)
+
+	s.Equal([][2]domain.TaskStatus{
+		{"", domain.TaskStatusToDo},
+		{domain.TaskStatusToDo, domain.TaskStatusInProgress},
+	}, notifier.tasks)
+
+	flow := domain.Flow{WorkspaceId: workspaceId, Id: "flow_notifier", ParentId: task.Id, Status: "in_progress"}
+	s.Require().NoError(delegator.PersistFlow(s.ctx, flow))
+	s.Require().NoError(delegator.PersistFlow(s.ctx, flow))
+	failed := flow
+	failed.Status = "failed"
+	s.Require().NoError(delegator.PersistFlow(s.ctx, failed))
+
+	s.Equal([][2]string{{"", "in_progress"}, {"in_progress", "failed"}}, notifier.flows, "only status changes are notified")
+
+	flowAction := domain.FlowAction{WorkspaceId: workspaceId, Id: "fa_notifier", FlowId: flow.Id, ActionStatus: domain.ActionStatusPending, IsHumanAction: true}
+	s.Require().NoError(delegator.PersistFlowAction(s.ctx, flowAction))
+	completed := flowAction
+	completed.ActionStatus = domain.ActionStatusComplete
+	s.Require().NoError(delegator.PersistFlowAction(s.ctx, completed))
+
+	s.Require().Len(notifier.flowActions, 2)
+	s.Equal(domain.ActionStatusPending, notifier.flowActions[0].ActionStatus)
+	s.Equal(domain.ActionStatusComplete, notifier.flowActions[1].ActionStatus)
+}
+
 func TestDelegator(t *testing.T) {
 	suite.Run(t, new(DelegatorTestSuite))
 }
@@ -55,6 +55,35 @@ func (s Storage) GetFlowsForTask(ctx context.Context, workspaceId, taskId string
 	return s.GetChildFlows(ctx, workspaceId, parentId)
 }
 
+// GetFlowsWithStatus finds flows by going through the tasks of every
+// workspace, since flows are only indexed by their parent here.
+func (s Storage) GetFlowsWithStatus(ctx context.Context, status string) ([]domain.Flow, error) {
+	workspaces, err := s.GetAllWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+
+	flows := make([]domain.Flow, 0)
+	for _, workspace := range workspaces {
+		tasks, err := s.GetTasks(ctx, workspace.Id, domain.AllTaskStatuses)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tasks for workspace %s: %w", workspace.Id, err)
+		}
+		for _, task := range tasks {
+			taskFlows, err := s.GetFlowsForTask(ctx, workspace.Id, task.Id)
+			if err != nil {
+				return nil, err
+			}
+			for _, flow := range taskFlows {
+				if flow.Status == status {
+					flows = append(flows, flow)
+				}
+			}
+		}
+	}
+	return flows, nil
+}
+
 func (s Storage) GetChildFlows(ctx context.Context, workspaceId, parentId string) ([]domain.Flow, error) {
 	flowsKey := fmt.Sprintf("%s:%s:flows", workspaceId, parentId)
 	flowIds, err := s.Client.SMembers(ctx, flowsKey).Result()
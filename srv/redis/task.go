@@ -29,6 +29,42 @@ func (s Storage) PersistTask(ctx context.Context, task domain.Task) error {
 		return err
 	}
 
+	return s.persistTaskSets(ctx, task)
+}
+
+// ReplaceTask persists a task like PersistTask, returning the status of the
+// task it replaced, or an empty status for a new task. The previous record is
+// read by the same command that writes the new one.
+func (s Storage) ReplaceTask(ctx context.Context, task domain.Task) (domain.TaskStatus, error) {
+	taskJson, err := json.Marshal(task)
+	if err != nil {
+		log.Println("Failed to convert task record to JSON: ", err)
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s:%s", task.WorkspaceId, task.Id)
+	previousJson, err := s.Client.SetArgs(ctx, key, taskJson, redis.SetArgs{Get: true}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Println("Failed to persist task to Redis: ", err)
+		return "", err
+	}
+
+	var previousStatus domain.TaskStatus
+	if previousJson != "" {
+		var previous domain.Task
+		if err := json.Unmarshal([]byte(previousJson), &previous); err != nil {
+			log.Println("Failed to unmarshal replaced task: ", err)
+		}
+		previousStatus = previous.Status
+	}
+
+	return previousStatus, s.persistTaskSets(ctx, task)
+}
+
+// persistTaskSets updates the kanban and archived sets the task belongs to
+func (s Storage) persistTaskSets(ctx context.Context, task domain.Task) error {
+	var err error
+
 	// Handle archived tasks
 	archivedKey := fmt.Sprintf("%s:archived_tasks", task.WorkspaceId)
 	if task.Archived != nil {
@@ -97,6 +97,35 @@ func TestPersistTask(t *testing.T) {
 	assert.True(t, isMember)
 }
 
+func TestReplaceTask(t *testing.T) {
+	db := newTestRedisStorage(t)
+
+	taskRecord := domain.Task{
+		WorkspaceId: "test-workspace",
+		Id:          "test-id",
+		Title:       "test-title",
+		Status:      domain.TaskStatusToDo,
+	}
+
+	previousStatus, err := db.ReplaceTask(context.Background(), taskRecord)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.TaskStatus(""), previousStatus)
+
+	taskRecord.Status = domain.TaskStatusInProgress
+	previousStatus, err = db.ReplaceTask(context.Background(), taskRecord)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.TaskStatusToDo, previousStatus)
+
+	persistedTask, err := db.GetTask(context.Background(), taskRecord.WorkspaceId, taskRecord.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, taskRecord, persistedTask)
+
+	statusKey := fmt.Sprintf("%s:kanban:%s", taskRecord.WorkspaceId, domain.TaskStatusInProgress)
+	isMember, err := db.Client.SIsMember(context.Background(), statusKey, taskRecord.Id).Result()
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+}
+
 func TestGetTasks(t *testing.T) {
 	db := newTestRedisStorage(t)
 	ctx := context.Background()
@@ -22,6 +22,10 @@ type Storage interface {
 	domain.WorktreeStorage
 	common.KeyValueStorage
 
+	// ReplaceTask persists a task like PersistTask, returning the status of
+	// the task it replaced, or an empty status for a new task, read atomically
+	// with the write
+	ReplaceTask(ctx context.Context, task domain.Task) (domain.TaskStatus, error)
 	CheckConnection(ctx context.Context) error
 }
 
@@ -86,7 +86,25 @@ func (s *Storage) GetFlowsForTask(ctx context.Context, workspaceId, taskId strin
 		return nil, fmt.Errorf("failed to query flows for task: %w", err)
 	}
 	defer rows.Close()
+	return scanFlows(rows)
+}
+
+func (s *Storage) GetFlowsWithStatus(ctx context.Context, status string) ([]domain.Flow, error) {
+	query := `
+		SELECT workspace_id, id, type, parent_id, status, created, updated
+		FROM flows
+		WHERE status = ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flows with status %s: %w", status, err)
+	}
+	defer rows.Close()
+	return scanFlows(rows)
+}
 
+func scanFlows(rows *sql.Rows) ([]domain.Flow, error) {
 	flows := make([]domain.Flow, 0)
 	for rows.Next() {
 		var flow domain.Flow
@@ -25,12 +25,21 @@ func (s *Storage) PersistFlowAction(ctx context.Context, flowAction domain.FlowA
 		temporalActivities = sql.NullString{String: string(taJSON), Valid: true}
 	}
 
+	var llmUsage sql.NullString
+	if flowAction.LlmUsage != nil {
+		llmUsageJSON, err := json.Marshal(flowAction.LlmUsage)
+		if err != nil {
+			return fmt.Errorf("failed to marshal LlmUsage: %w", err)
+		}
+		llmUsage = sql.NullString{String: string(llmUsageJSON), Valid: true}
+	}
+
 	query := `
 		INSERT OR REPLACE INTO flow_actions (
 			id, subflow_name, subflow_description, subflow_id, flow_id, workspace_id,
 			created, updated, action_type, action_params, action_status, action_result,
-			is_human_action, is_callback_action, temporal_activities
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			is_human_action, is_callback_action, temporal_activities, llm_usage
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	flowAction.Created = flowAction.Created.UTC()
@@ -40,7 +49,7 @@ func (s *Storage) PersistFlowAction(ctx context.Context, flowAction domain.FlowA
 		flowAction.Id, flowAction.SubflowName, flowAction.SubflowDescription, flowAction.SubflowId,
 		flowAction.FlowId, flowAction.WorkspaceId, flowAction.Created, flowAction.Updated,
 		flowAction.ActionType, actionParamsJSON, flowAction.ActionStatus, flowAction.ActionResult,
-		flowAction.IsHumanAction, flowAction.IsCallbackAction, temporalActivities,
+		flowAction.IsHumanAction, flowAction.IsCallbackAction, temporalActivities, llmUsage,
 	)
 
 	if err != nil {
@@ -55,7 +64,7 @@ func (s *Storage) GetFlowActions(ctx context.Context, workspaceId, flowId string
 	query := `
 		SELECT id, subflow_name, subflow_description, subflow_id, flow_id, workspace_id,
 			   created, updated, action_type, action_params, action_status, action_result,
-			   is_human_action, is_callback_action, temporal_activities
+			   is_human_action, is_callback_action, temporal_activities, llm_usage
 		FROM flow_actions
 		WHERE workspace_id = ? AND flow_id = ?
 	`
@@ -71,12 +80,13 @@ func (s *Storage) GetFlowActions(ctx context.Context, workspaceId, flowId string
 		var fa domain.FlowAction
 		var actionParamsJSON []byte
 		var temporalActivities sql.NullString
+		var llmUsage sql.NullString
 
 		err := rows.Scan(
 			&fa.Id, &fa.SubflowName, &fa.SubflowDescription, &fa.SubflowId,
 			&fa.FlowId, &fa.WorkspaceId, &fa.Created, &fa.Updated,
 			&fa.ActionType, &actionParamsJSON, &fa.ActionStatus, &fa.ActionResult,
-			&fa.IsHumanAction, &fa.IsCallbackAction, &temporalActivities,
+			&fa.IsHumanAction, &fa.IsCallbackAction, &temporalActivities, &llmUsage,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan flow action row: %w", err)
@@ -94,6 +104,13 @@ func (s *Storage) GetFlowActions(ctx context.Context, workspaceId, flowId string
 			}
 		}
 
+		if llmUsage.Valid {
+			err = json.Unmarshal([]byte(llmUsage.String), &fa.LlmUsage)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal llm usage: %w", err)
+			}
+		}
+
 		flowActions = append(flowActions, fa)
 	}
 
@@ -109,7 +126,7 @@ func (s *Storage) GetFlowAction(ctx context.Context, workspaceId, flowActionId s
 	query := `
 		SELECT id, subflow_name, subflow_description, subflow_id, flow_id, workspace_id,
 			   created, updated, action_type, action_params, action_status, action_result,
-			   is_human_action, is_callback_action, temporal_activities
+			   is_human_action, is_callback_action, temporal_activities, llm_usage
 		FROM flow_actions
 		WHERE workspace_id = ? AND id = ?
 	`
@@ -117,12 +134,13 @@ func (s *Storage) GetFlowAction(ctx context.Context, workspaceId, flowActionId s
 	var fa domain.FlowAction
 	var actionParamsJSON []byte
 	var temporalActivities sql.NullString
+	var llmUsage sql.NullString
 
 	err := s.db.QueryRowContext(ctx, query, workspaceId, flowActionId).Scan(
 		&fa.Id, &fa.SubflowName, &fa.SubflowDescription, &fa.SubflowId,
 		&fa.FlowId, &fa.WorkspaceId, &fa.Created, &fa.Updated,
 		&fa.ActionType, &actionParamsJSON, &fa.ActionStatus, &fa.ActionResult,
-		&fa.IsHumanAction, &fa.IsCallbackAction, &temporalActivities,
+		&fa.IsHumanAction, &fa.IsCallbackAction, &temporalActivities, &llmUsage,
 	)
 
 	if err != nil {
@@ -144,6 +162,13 @@ func (s *Storage) GetFlowAction(ctx context.Context, workspaceId, flowActionId s
 		}
 	}
 
+	if llmUsage.Valid {
+		err = json.Unmarshal([]byte(llmUsage.String), &fa.LlmUsage)
+		if err != nil {
+			return domain.FlowAction{}, fmt.Errorf("failed to unmarshal llm usage: %w", err)
+		}
+	}
+
 	return fa, nil
 }
 
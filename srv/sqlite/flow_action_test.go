@@ -124,6 +124,43 @@ func TestFlowActionStorage(t *testing.T) {
 		assert.Equal(t, 987654321, retrieved.Updated.Nanosecond())
 	})
 
+	t.Run("Persists LlmUsage", func(t *testing.T) {
+		llmUsage := &common.LlmUsage{
+			Calls:                1,
+			InputTokens:          1200,
+			OutputTokens:         300,
+			CacheReadInputTokens: 800,
+			Cost:                 0.0123,
+		}
+		fa := domain.FlowAction{
+			Id:           "test-action-llm-usage",
+			FlowId:       flowId,
+			WorkspaceId:  workspaceId,
+			Created:      time.Now().UTC(),
+			Updated:      time.Now().UTC(),
+			ActionType:   "generate.test",
+			ActionParams: map[string]interface{}{},
+			ActionStatus: domain.ActionStatusComplete,
+			LlmUsage:     llmUsage,
+		}
+		err := storage.PersistFlowAction(ctx, fa)
+		assert.NoError(t, err)
+
+		retrieved, err := storage.GetFlowAction(ctx, workspaceId, fa.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, llmUsage, retrieved.LlmUsage)
+
+		flowActions, err := storage.GetFlowActions(ctx, workspaceId, flowId)
+		assert.NoError(t, err)
+		for _, action := range flowActions {
+			if action.Id == fa.Id {
+				assert.Equal(t, llmUsage, action.LlmUsage)
+			} else {
+				assert.Nil(t, action.LlmUsage)
+			}
+		}
+	})
+
 	t.Run("DeleteFlowActionsForFlow", func(t *testing.T) {
 		deleteFlowId := "delete-test-flow"
 		// Add flow actions to delete
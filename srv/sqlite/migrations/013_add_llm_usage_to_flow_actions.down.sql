@@ -0,0 +1 @@
+ALTER TABLE flow_actions DROP COLUMN llm_usage;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE flow_actions ADD COLUMN llm_usage TEXT;
\ No newline at end of file
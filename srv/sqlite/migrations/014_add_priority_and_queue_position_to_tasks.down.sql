@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN queue_position;
+ALTER TABLE tasks DROP COLUMN priority;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN queue_position INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE tasks DROP COLUMN pull_request_url;
\ No newline at end of file
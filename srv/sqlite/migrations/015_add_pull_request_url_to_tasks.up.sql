@@ -0,0 +1 @@
+ALTER TABLE tasks ADD COLUMN pull_request_url TEXT NOT NULL DEFAULT '';
\ No newline at end of file
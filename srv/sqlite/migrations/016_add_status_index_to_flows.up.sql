@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_flows_status ON flows(status);
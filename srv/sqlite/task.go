@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sidekick/common"
 	"sidekick/domain"
@@ -31,6 +32,70 @@ func (s *Storage) PersistTask(ctx context.Context, task domain.Task) error {
 		attribute.String("task_id", task.Id),
 	)
 
+	if err := insertTask(ctx, s.db, task); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceTask inserts or updates a Task like PersistTask, returning the status
+// of the task it replaced, or an empty status for a new task. The previous
+// status is read in the same transaction as the write.
+func (s *Storage) ReplaceTask(ctx context.Context, task domain.Task) (domain.TaskStatus, error) {
+	ctx, span := taskTracer.Start(ctx, "Storage.ReplaceTask")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "sqlite"),
+		attribute.String("db.operation", "INSERT"),
+		attribute.String("workspace_id", task.WorkspaceId),
+		attribute.String("task_id", task.Id),
+	)
+
+	previousStatus, err := s.replaceTask(ctx, task)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	return previousStatus, nil
+}
+
+func (s *Storage) replaceTask(ctx context.Context, task domain.Task) (domain.TaskStatus, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction for task replacement: %w", err)
+	}
+	defer tx.Rollback()
+
+	// deleting first rather than selecting makes the transaction take the
+	// write lock straight away, instead of failing to upgrade its read lock
+	// when another connection writes in between
+	var previousStatus domain.TaskStatus
+	err = tx.QueryRowContext(ctx, "DELETE FROM tasks WHERE workspace_id = ? AND id = ? RETURNING status", task.WorkspaceId, task.Id).Scan(&previousStatus)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to replace task: %w", err)
+	}
+
+	if err := insertTask(ctx, tx, task); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction for task replacement: %w", err)
+	}
+	return previousStatus, nil
+}
+
+// execer is satisfied by both trackedDB and trackedTx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertTask(ctx context.Context, db execer, task domain.Task) error {
 	linksJSON, err := json.Marshal(task.Links)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Links: %w", err)
@@ -44,8 +109,9 @@ func (s *Storage) PersistTask(ctx context.Context, task domain.Task) error {
 	query := `
 		INSERT OR REPLACE INTO tasks (
 			workspace_id, id, title, description, status, links, agent_type,
-			flow_type, archived, created, updated, flow_options
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			flow_type, archived, created, updated, flow_options, priority,
+			queue_position, pull_request_url
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	if task.Archived != nil {
@@ -55,14 +121,12 @@ func (s *Storage) PersistTask(ctx context.Context, task domain.Task) error {
 	task.Created = task.Created.UTC()
 	task.Updated = task.Updated.UTC()
 
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		task.WorkspaceId, task.Id, task.Title, task.Description, task.Status, linksJSON, task.AgentType,
-		task.FlowType, task.Archived, task.Created, task.Updated, flowOptionsJSON,
+		task.FlowType, task.Archived, task.Created, task.Updated, flowOptionsJSON, task.Priority,
+		task.QueuePosition, task.PullRequestURL,
 	)
-
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to persist task: %w", err)
 	}
 
@@ -119,12 +183,12 @@ func (s *Storage) GetTask(ctx context.Context, workspaceId, taskId string) (doma
 	var linksJSON, flowOptionsJSON []byte
 	var archivedStr *string
 
-	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options
+	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options, priority, queue_position, pull_request_url
 			  FROM tasks WHERE workspace_id = ? AND id = ?`
 	err := s.db.QueryRowContext(ctx, query, workspaceId, taskId).Scan(
 		&task.WorkspaceId, &task.Id, &task.Title, &task.Description, &task.Status,
 		&linksJSON, &task.AgentType, &task.FlowType, &archivedStr,
-		&task.Created, &task.Updated, &flowOptionsJSON)
+		&task.Created, &task.Updated, &flowOptionsJSON, &task.Priority, &task.QueuePosition, &task.PullRequestURL)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -168,7 +232,7 @@ func (s *Storage) GetTasks(ctx context.Context, workspaceId string, statuses []d
 		attribute.String("workspace_id", workspaceId),
 	)
 
-	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options
+	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options, priority, queue_position, pull_request_url
 			  FROM tasks WHERE workspace_id = ? AND archived IS NULL`
 	args := []interface{}{workspaceId}
 
@@ -198,7 +262,7 @@ func (s *Storage) GetTasks(ctx context.Context, workspaceId string, statuses []d
 		err := rows.Scan(
 			&task.WorkspaceId, &task.Id, &task.Title, &task.Description, &task.Status,
 			&linksJSON, &task.AgentType, &task.FlowType, &archivedStr,
-			&task.Created, &task.Updated, &flowOptionsJSON)
+			&task.Created, &task.Updated, &flowOptionsJSON, &task.Priority, &task.QueuePosition, &task.PullRequestURL)
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
@@ -260,7 +324,7 @@ func (s *Storage) GetArchivedTasks(ctx context.Context, workspaceId string, page
 		return nil, 0, fmt.Errorf("failed to get total count of archived tasks: %w", err)
 	}
 
-	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options
+	query := `SELECT workspace_id, id, title, description, status, links, agent_type, flow_type, archived, created, updated, flow_options, priority, queue_position, pull_request_url
 			  FROM tasks WHERE workspace_id = ? AND archived IS NOT NULL ORDER BY archived DESC, updated DESC LIMIT ? OFFSET ?`
 
 	limit := pageSize
@@ -282,7 +346,7 @@ func (s *Storage) GetArchivedTasks(ctx context.Context, workspaceId string, page
 		err := rows.Scan(
 			&task.WorkspaceId, &task.Id, &task.Title, &task.Description, &task.Status,
 			&linksJSON, &task.AgentType, &task.FlowType, &archivedStr,
-			&task.Created, &task.Updated, &flowOptionsJSON)
+			&task.Created, &task.Updated, &flowOptionsJSON, &task.Priority, &task.QueuePosition, &task.PullRequestURL)
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
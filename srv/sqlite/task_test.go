@@ -54,6 +54,35 @@ func TestPersistTask(t *testing.T) {
 	assert.Equal(t, task.FlowOptions, retrievedTask.FlowOptions)
 }
 
+func TestReplaceTask(t *testing.T) {
+	storage := NewTestSqliteStorage(t, "task_test")
+	ctx := context.Background()
+
+	task := domain.Task{
+		WorkspaceId: "workspace1",
+		Id:          "task1",
+		Title:       "Test Task",
+		Status:      domain.TaskStatusToDo,
+		Created:     time.Now().UTC(),
+		Updated:     time.Now().UTC(),
+	}
+
+	previousStatus, err := storage.ReplaceTask(ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatus(""), previousStatus)
+
+	task.Title = "Updated Test Task"
+	task.Status = domain.TaskStatusInProgress
+	previousStatus, err = storage.ReplaceTask(ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TaskStatusToDo, previousStatus)
+
+	retrievedTask, err := storage.GetTask(ctx, task.WorkspaceId, task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, task.Title, retrievedTask.Title)
+	assert.Equal(t, task.Status, retrievedTask.Status)
+}
+
 func TestDeleteTask(t *testing.T) {
 	storage := NewTestSqliteStorage(t, "task_test")
 
@@ -421,6 +421,26 @@ func (m *mockClient) GetSubflow(workspaceID, subflowID string) (domain.Subflow,
 	return args.Get(0).(domain.Subflow), args.Error(1)
 }
 
+func (m *mockClient) GetSubflows(workspaceID, flowID string) ([]domain.Subflow, error) {
+	args := m.Called(workspaceID, flowID)
+	return args.Get(0).([]domain.Subflow), args.Error(1)
+}
+
+func (m *mockClient) GetFlowActions(workspaceID, flowID string) ([]client.FlowAction, error) {
+	args := m.Called(workspaceID, flowID)
+	return args.Get(0).([]client.FlowAction), args.Error(1)
+}
+
+func (m *mockClient) GetTaskUsage(workspaceID, taskID string) (domain.TaskLlmUsage, error) {
+	args := m.Called(workspaceID, taskID)
+	return args.Get(0).(domain.TaskLlmUsage), args.Error(1)
+}
+
+func (m *mockClient) GetWorkspaceUsage(workspaceID string, since time.Time, includeArchived bool) (domain.WorkspaceLlmUsage, error) {
+	args := m.Called(workspaceID, since, includeArchived)
+	return args.Get(0).(domain.WorkspaceLlmUsage), args.Error(1)
+}
+
 func (m *mockClient) SendUserAction(workspaceID, flowID, actionType string) error {
 	args := m.Called(workspaceID, flowID, actionType)
 	return args.Error(0)
@@ -8,6 +8,7 @@ import (
 	"sidekick/domain"
 	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/stretchr/testify/mock"
@@ -62,11 +63,31 @@ func (m *mockClientForProgress) GetSubflow(workspaceID, subflowID string) (domai
 	return args.Get(0).(domain.Subflow), args.Error(1)
 }
 
+func (m *mockClientForProgress) GetSubflows(workspaceID, flowID string) ([]domain.Subflow, error) {
+	args := m.Called(workspaceID, flowID)
+	return args.Get(0).([]domain.Subflow), args.Error(1)
+}
+
+func (m *mockClientForProgress) GetFlowActions(workspaceID, flowID string) ([]client.FlowAction, error) {
+	args := m.Called(workspaceID, flowID)
+	return args.Get(0).([]client.FlowAction), args.Error(1)
+}
+
 func (m *mockClientForProgress) QueryFlow(workspaceID, flowID, query string, queryArgs any) (any, error) {
 	args := m.Called(workspaceID, flowID, query, queryArgs)
 	return args.Get(0), args.Error(1)
 }
 
+func (m *mockClientForProgress) GetTaskUsage(workspaceID, taskID string) (domain.TaskLlmUsage, error) {
+	args := m.Called(workspaceID, taskID)
+	return args.Get(0).(domain.TaskLlmUsage), args.Error(1)
+}
+
+func (m *mockClientForProgress) GetWorkspaceUsage(workspaceID string, since time.Time, includeArchived bool) (domain.WorkspaceLlmUsage, error) {
+	args := m.Called(workspaceID, since, includeArchived)
+	return args.Get(0).(domain.WorkspaceLlmUsage), args.Error(1)
+}
+
 func TestGetActionDisplayName(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// ValidateJSONSchema validates a decoded JSON value, as produced by
+// json.Unmarshal into an interface{}, against a schema. It supports the subset
+// of JSON schema produced by jsonschema.Reflector for Go types: type, enum,
+// const, properties, required, additionalProperties, items, anyOf, oneOf and
+// allOf, plus $ref to $defs within the root schema. A null value is accepted
+// for any non-required property, matching how encoding/json treats null.
+func ValidateJSONSchema(schema *jsonschema.Schema, value interface{}) error {
+	v := schemaValidator{root: schema}
+	return v.validate(schema, value, "")
+}
+
+type schemaValidator struct {
+	root *jsonschema.Schema
+}
+
+func (v schemaValidator) validate(schema *jsonschema.Schema, value interface{}, path string) error {
+	if schema == nil || schema == jsonschema.TrueSchema {
+		return nil
+	}
+	if schema == jsonschema.FalseSchema {
+		return fmt.Errorf("%s: no value is allowed", displayPath(path))
+	}
+
+	if schema.Ref != "" {
+		resolved, err := v.resolveRef(schema.Ref)
+		if err != nil {
+			return err
+		}
+		if err := v.validate(resolved, value, path); err != nil {
+			return err
+		}
+	}
+
+	if schema.Type != "" && !matchesJSONType(schema.Type, value) {
+		return fmt.Errorf("%s: expected %s, got %s", displayPath(path), schema.Type, jsonTypeName(value))
+	}
+
+	if schema.Const != nil && !jsonEqual(schema.Const, value) {
+		return fmt.Errorf("%s: expected %v", displayPath(path), schema.Const)
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", displayPath(path), value, schema.Enum)
+		}
+	}
+
+	for _, sub := range schema.AllOf {
+		if err := v.validate(sub, value, path); err != nil {
+			return err
+		}
+	}
+	if len(schema.AnyOf) > 0 && v.countMatches(schema.AnyOf, value, path) == 0 {
+		return fmt.Errorf("%s: does not match any allowed schema", displayPath(path))
+	}
+	if len(schema.OneOf) > 0 && v.countMatches(schema.OneOf, value, path) != 1 {
+		return fmt.Errorf("%s: must match exactly one allowed schema", displayPath(path))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(schema, typed, path)
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typed {
+				if err := v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v schemaValidator) validateObject(schema *jsonschema.Schema, obj map[string]interface{}, path string) error {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", displayPath(path), name)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+		propPath := key
+		if path != "" {
+			propPath = path + "." + key
+		}
+
+		var propSchema *jsonschema.Schema
+		if schema.Properties != nil {
+			propSchema, _ = schema.Properties.Get(key)
+		}
+		if propSchema == nil {
+			if schema.AdditionalProperties == jsonschema.FalseSchema {
+				return fmt.Errorf("%s: unknown property %q", displayPath(path), key)
+			}
+			propSchema = schema.AdditionalProperties
+		}
+
+		if value == nil && !isRequired(schema, key) {
+			continue
+		}
+		if err := v.validate(propSchema, value, propPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v schemaValidator) countMatches(schemas []*jsonschema.Schema, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		if v.validate(sub, value, path) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+func (v schemaValidator) resolveRef(ref string) (*jsonschema.Schema, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok || v.root == nil || v.root.Definitions == nil {
+		return nil, fmt.Errorf("unsupported schema reference: %s", ref)
+	}
+	resolved, ok := v.root.Definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema reference: %s", ref)
+	}
+	return resolved, nil
+}
+
+func isRequired(schema *jsonschema.Schema, name string) bool {
+	for _, required := range schema.Required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesJSONType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, json.Number, int, int64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// jsonEqual compares values after normalizing them through JSON, so that eg
+// an int enum value in a schema equals the float64 decoded from JSON.
+func jsonEqual(a, b interface{}) bool {
+	var normalizedA, normalizedB interface{}
+	Transcode(a, &normalizedA)
+	Transcode(b, &normalizedB)
+	return reflect.DeepEqual(normalizedA, normalizedB)
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSchemaItem struct {
+	Name string `json:"name"`
+}
+
+type testSchemaValue struct {
+	Kind     string           `json:"kind" jsonschema:"enum=a,enum=b"`
+	Count    int              `json:"count,omitempty"`
+	Optional *string          `json:"optional,omitempty"`
+	Items    []testSchemaItem `json:"items,omitempty"`
+	Labels   map[string]int   `json:"labels,omitempty"`
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := (&jsonschema.Reflector{}).Reflect(&testSchemaValue{})
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr string
+	}{
+		{name: "valid", value: map[string]interface{}{"kind": "a", "count": 2.0, "items": []interface{}{map[string]interface{}{"name": "x"}}, "labels": map[string]interface{}{"x": 1.0}}},
+		{name: "null optional", value: map[string]interface{}{"kind": "b", "optional": nil}},
+		{name: "missing required", value: map[string]interface{}{}, wantErr: `value: missing required property "kind"`},
+		{name: "not in enum", value: map[string]interface{}{"kind": "c"}, wantErr: "kind: c is not one of [a b]"},
+		{name: "non-integer", value: map[string]interface{}{"kind": "a", "count": 1.5}, wantErr: "count: expected integer, got number"},
+		{name: "unknown property", value: map[string]interface{}{"kind": "a", "other": true}, wantErr: `value: unknown property "other"`},
+		{name: "nested via ref", value: map[string]interface{}{"kind": "a", "items": []interface{}{map[string]interface{}{"name": 1.0}}}, wantErr: "items[0].name: expected string, got number"},
+		{name: "map values", value: map[string]interface{}{"kind": "a", "labels": map[string]interface{}{"x": "y"}}, wantErr: "labels.x: expected integer, got string"},
+		{name: "wrong root type", value: "a", wantErr: "value: expected object, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSONSchema(schema, tt.value)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_Combinators(t *testing.T) {
+	schema := &jsonschema.Schema{
+		AnyOf: []*jsonschema.Schema{{Type: "string"}, {Type: "integer"}},
+	}
+	assert.NoError(t, ValidateJSONSchema(schema, "x"))
+	assert.NoError(t, ValidateJSONSchema(schema, 3.0))
+	assert.Error(t, ValidateJSONSchema(schema, true))
+
+	schema = &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{{Type: "number"}, {Type: "integer"}},
+	}
+	assert.NoError(t, ValidateJSONSchema(schema, 1.5))
+	assert.Error(t, ValidateJSONSchema(schema, 1.0))
+}
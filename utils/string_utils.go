@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"math"
 	"strings"
+	"unicode"
 
 	"github.com/adrg/strutil"
 	"github.com/adrg/strutil/metrics"
@@ -120,3 +121,52 @@ func Hash256(s string) string {
 	// Convert the hash to a base64 encoded string
 	return base64.StdEncoding.EncodeToString(hash[:])
 }
+
+// CodeTerms splits text into lowercase terms, e.g. for lexical search. Besides
+// each whole identifier, its camelCase and snake_case parts are terms too, so
+// that "GetRankedRepoSummary" matches both itself and "repo summary".
+func CodeTerms(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Trim(word, "_")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, strings.ToLower(word))
+		parts := SplitIdentifier(word)
+		if len(parts) > 1 {
+			for _, part := range parts {
+				terms = append(terms, strings.ToLower(part))
+			}
+		}
+	}
+	return terms
+}
+
+// SplitIdentifier splits an identifier into its snake_case and camelCase
+// parts, keeping acronyms together, eg "parseHTTPRequest_v2" is split into
+// "parse", "HTTP", "Request" and "v2".
+func SplitIdentifier(identifier string) []string {
+	parts := make([]string, 0)
+	for _, segment := range strings.Split(identifier, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+			acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
@@ -4,6 +4,8 @@ import (
 	"math"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestStringSimilarity(t *testing.T) {
@@ -80,3 +82,35 @@ func TestSliceLevenshtein(t *testing.T) {
 		}
 	}
 }
+
+func TestCodeTerms(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		[]string{"func", "getrankedreposummary", "get", "ranked", "repo", "summary", "dctx", "d", "ctx"},
+		CodeTerms("func GetRankedRepoSummary(dCtx)"),
+	)
+	assert.Equal(t,
+		[]string{"parsehttprequest_v2", "parse", "http", "request", "v2", "x"},
+		CodeTerms("parseHTTPRequest_v2 = _x_"),
+	)
+	assert.Empty(t, CodeTerms("  {}() "))
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]string{
+		"simple":              {"simple"},
+		"camelCase":           {"camel", "Case"},
+		"PascalCase":          {"Pascal", "Case"},
+		"snake_case_name":     {"snake", "case", "name"},
+		"HTTPServer":          {"HTTP", "Server"},
+		"getID":               {"get", "ID"},
+		"sha256Sum":           {"sha256", "Sum"},
+		"Mixed_snakeAndCamel": {"Mixed", "snake", "And", "Camel"},
+	}
+	for identifier, expected := range tests {
+		assert.Equal(t, expected, SplitIdentifier(identifier), identifier)
+	}
+}
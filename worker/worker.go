@@ -14,6 +14,7 @@ import (
 
 	"sidekick"
 	"sidekick/coding"
+	"sidekick/coding/forge"
 	"sidekick/coding/git"
 	"sidekick/coding/lsp"
 	"sidekick/coding/tree_sitter"
@@ -25,9 +26,11 @@ import (
 	"sidekick/workspace"
 
 	"sidekick/dev"
+	"sidekick/domain"
 	"sidekick/env"
 	"sidekick/fflag"
 	"sidekick/flow_action"
+	"sidekick/mcp"
 	"sidekick/persisted_ai"
 	"sidekick/poll_failures"
 )
@@ -36,11 +39,16 @@ import (
 type Worker struct {
 	worker.Worker
 	shutdownTracer func(context.Context) error
+	vectorIndexes  *persisted_ai.VectorIndexes
 }
 
-// Stop stops the worker and shuts down the tracer
+// Stop stops the worker, releases the loaded vector indexes and shuts down the
+// tracer
 func (w *Worker) Stop() {
 	w.Worker.Stop()
+	if w.vectorIndexes != nil {
+		w.vectorIndexes.Close()
+	}
 	if w.shutdownTracer != nil {
 		if err := w.shutdownTracer(context.Background()); err != nil {
 			log.Error().Err(err).Msg("Failed to shutdown telemetry tracer")
@@ -136,11 +144,19 @@ func StartWorker(hostPort string, taskQueue string) *Worker {
 		TreeSitterActivities: treeSitterActivities,
 		LSPActivities:        lspActivities,
 	}
+	vectorIndexesDir, err := persisted_ai.DefaultVectorIndexesDir()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get vector indexes directory")
+	}
+	vectorIndexes := persisted_ai.NewVectorIndexes(vectorIndexesDir)
 	vectorActivities := &persisted_ai.VectorActivities{
 		DatabaseAccessor: service,
+		Indexes:          vectorIndexes,
 	}
 	ragActivities := &persisted_ai.RagActivities{
 		DatabaseAccessor: service,
+		VectorIndexes:    vectorIndexes,
+		LexicalIndexes:   persisted_ai.NewLexicalIndexes(),
 	}
 
 	pollFailuresActivities := &poll_failures.PollFailuresActivities{
@@ -170,11 +186,15 @@ func StartWorker(hostPort string, taskQueue string) *Worker {
 	RegisterWorkflows(w)
 
 	w.RegisterActivity(env.NewLocalGitWorktreeActivity)
+	w.RegisterActivity(env.NewContainerEnvActivity)
+	w.RegisterActivity(env.NewDevPodEnvActivity)
+	w.RegisterActivity(env.TeardownEnvActivity)
 	w.RegisterActivity(&srv.Activities{Service: service})
 	w.RegisterActivity(sidekick.GithubCloneRepoActivity)
 	w.RegisterActivity(llmActivities)
 	w.RegisterActivity(pollFailuresActivities)
 	w.RegisterActivity(lspActivities)
+	w.RegisterActivity(&mcp.McpActivities{})
 	w.RegisterActivity(treeSitterActivities)
 	w.RegisterActivity(codingActivities)
 	w.RegisterActivity(ragActivities)
@@ -194,6 +214,11 @@ func StartWorker(hostPort string, taskQueue string) *Worker {
 	w.RegisterActivity(git.GetDefaultBranch)
 	w.RegisterActivity(git.ListLocalBranches)
 	w.RegisterActivity(git.WriteTreeActivity)
+	w.RegisterActivity(forge.OpenPullRequestActivity)
+	w.RegisterActivity(forge.GetPullRequestReviewActivity)
+	w.RegisterActivity(forge.LocateReviewThreadsActivity)
+	w.RegisterActivity(forge.PushBranchActivity)
+	w.RegisterActivity(forge.ReplyToReviewThreadsActivity)
 	w.RegisterActivity(embedActivities)
 	w.RegisterActivity(vectorActivities)
 	w.RegisterActivity(flowActivities)
@@ -238,14 +263,16 @@ func StartWorker(hostPort string, taskQueue string) *Worker {
 	return &Worker{
 		Worker:         w,
 		shutdownTracer: shutdownTracer,
+		vectorIndexes:  vectorIndexes,
 	}
 }
 
 func RegisterWorkflows(w worker.WorkflowRegistry) {
 	w.RegisterWorkflow(persisted_ai.TestOpenAiEmbedActivityWorkflow)
 	w.RegisterWorkflow(dev.DevAgentManagerWorkflow)
-	w.RegisterWorkflow(dev.PlannedDevWorkflow)
-	w.RegisterWorkflow(dev.BasicDevWorkflow)
+	for _, flowType := range domain.ListFlowTypes() {
+		w.RegisterWorkflow(flowType.Workflow)
+	}
 	w.RegisterWorkflow(poll_failures.PollFailuresWorkflow)
 	w.RegisterWorkflow(srv.CascadeDeleteTaskWorkflow)
 	w.RegisterWorkflow(common.CodecPayloadCleanupWorkflow)